@@ -0,0 +1,89 @@
+const crypto = require('crypto');
+const { logger } = require('@librechat/data-schemas');
+const { isEnabled } = require('@librechat/api');
+
+/** Headers the chat gateway proxy vouches for, in signing order */
+const IDENTITY_HEADERS = [
+  'x-authenticated-user',
+  'x-user-org',
+  'x-user-role',
+  'x-user-super-admin',
+];
+
+/**
+ * Recomputes the proxy's HMAC-SHA256 over the timestamp, method, request URI and identity headers
+ * (see saas-api/cmd/proxy/signing.go).
+ * @param {string} secret
+ * @param {string} timestamp
+ * @param {ServerRequest} req
+ * @returns {string} hex signature
+ */
+const requestSignature = (secret, timestamp, req) => {
+  const lines = [timestamp, req.method.toUpperCase(), req.originalUrl];
+  for (const name of IDENTITY_HEADERS) {
+    lines.push(req.headers[name] ?? '');
+  }
+  return crypto.createHmac('sha256', secret).update(lines.join('\n')).digest('hex');
+};
+
+/**
+ * @param {ServerRequest} req
+ * @param {string} secret
+ * @param {number} maxSkew - seconds a signature stays valid
+ * @returns {string | null} why the signature is not valid, or null when it is
+ */
+const signatureProblem = (req, secret, maxSkew) => {
+  const timestamp = req.headers['x-proxy-timestamp'];
+  const signature = req.headers['x-proxy-signature'];
+  if (!timestamp || !signature) {
+    return 'unsigned';
+  }
+  if (!/^\d+$/.test(timestamp) || Math.abs(Date.now() / 1000 - Number(timestamp)) > maxSkew) {
+    return 'expired';
+  }
+  const expected = Buffer.from(`v1=${requestSignature(secret, timestamp, req)}`);
+  const received = Buffer.from(signature);
+  if (expected.length !== received.length || !crypto.timingSafeEqual(expected, received)) {
+    return 'invalid';
+  }
+  return null;
+};
+
+/**
+ * Middleware to trust the proxy's user headers only when it signed them. With PROXY_SIGNING_SECRET
+ * set (the same value as the proxy's), X-Authenticated-User and the X-User-* tenancy headers are
+ * removed from requests without a valid X-Proxy-Signature, so a client that reaches the backend
+ * directly cannot pose as a user. With PROXY_SIGNATURE_REQUIRED=true such requests get 401 instead.
+ * @function
+ * @param {ServerRequest} req - Express request object.
+ * @param {ServerResponse} res - Express response object.
+ * @param {import('express').NextFunction} next - Next middleware function.
+ */
+const verifyProxySignature = (req, res, next) => {
+  const secret = process.env.PROXY_SIGNING_SECRET;
+  if (!secret) {
+    return next();
+  }
+
+  const maxSkew = Number(process.env.PROXY_SIGNATURE_MAX_SKEW) || 300;
+  const problem = signatureProblem(req, secret, maxSkew);
+  if (!problem) {
+    return next();
+  }
+
+  if (isEnabled(process.env.PROXY_SIGNATURE_REQUIRED)) {
+    logger.warn(`[verifyProxySignature] Rejected ${req.method} ${req.path}: ${problem} signature`);
+    return res.status(401).json({ message: 'Invalid proxy signature' });
+  }
+  if (IDENTITY_HEADERS.some((name) => req.headers[name] != null)) {
+    logger.warn(
+      `[verifyProxySignature] Dropped user headers from ${req.method} ${req.path}: ${problem} signature`,
+    );
+  }
+  for (const name of [...IDENTITY_HEADERS, 'x-user-from-proxy']) {
+    delete req.headers[name];
+  }
+  next();
+};
+
+module.exports = verifyProxySignature;
@@ -1,4 +1,4 @@
-package main
+package api
 
 import (
 	"context"
@@ -6,47 +6,49 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"saas-api/config"
 	"saas-api/internal/auth"
 	"saas-api/internal/handlers"
 	"saas-api/internal/middleware"
+	"saas-api/internal/models"
 	"saas-api/internal/repositories"
 	"saas-api/internal/services"
+	"saas-api/pkg/captcha"
+	"saas-api/pkg/envelope"
+	"saas-api/pkg/mailer"
 	"saas-api/pkg/memorydb"
+	"saas-api/pkg/orgtime"
 	"saas-api/pkg/postgres"
+	"saas-api/pkg/sms"
+	"saas-api/pkg/storage"
+	"saas-api/pkg/utils"
 	"saas-api/pkg/weaviate"
 
 	"saas-api/cmd/configs"
 
-	"github.com/joho/godotenv"
-
 	"github.com/gin-gonic/gin"
 )
 
-func main() {
-	// Load .env file
-	envPaths := []string{
-		"../../.env", // From cmd/api/ to saas-api/.env
-		".env",       // Current directory
-	}
-
-	envLoaded := false
-	for _, path := range envPaths {
-		if err := godotenv.Load(path); err == nil {
-			log.Printf("Loaded .env from: %s", path)
-			envLoaded = true
-			break
-		}
-	}
+// Smoke checks a running deployment over HTTP (`saas-api smoke`); it needs no database
+func Smoke(ctx context.Context, args []string) error {
+	return runSmoke(ctx, args)
+}
 
-	if !envLoaded {
-		log.Println("No .env file found, using environment variables")
+// Seed loads the demo dataset (`saas-api seed`)
+func Seed(ctx context.Context, args []string) error {
+	db, err := postgres.NewDB(config.Load())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	defer db.Close()
+	return runSeed(ctx, db, args)
+}
 
+// Serve runs the API server (`saas-api serve api`) until ctx is cancelled, then shuts it down
+// gracefully
+func Serve(ctx context.Context) {
 	// Load configuration
 	cfg := config.Load()
 
@@ -70,10 +72,27 @@ func main() {
 	// fileRepo := repositories.NewFileRepository(db)
 	auditLogRepo := repositories.NewAuditLogRepository(db)
 	screenerRepo := repositories.NewScreenerRepository(db)
+	apiUsageRepo := repositories.NewAPIUsageRepository(db)
+	feedbackRepo := repositories.NewSearchFeedbackRepository(db)
+	orgSecretRepo := repositories.NewOrgSecretRepository(db)
+	accessReviewRepo := repositories.NewAccessReviewRepository(db)
+	oidcProviderRepo := repositories.NewOIDCProviderRepository(db)
+	samlProviderRepo := repositories.NewSAMLProviderRepository(db)
+	identityRepo := repositories.NewUserIdentityRepository(db)
+	twoFactorRepo := repositories.NewTwoFactorRepository(db)
+	passkeyRepo := repositories.NewPasskeyRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	oauthClientRepo := repositories.NewOAuthClientRepository(db)
+	personalTokenRepo := repositories.NewPersonalAccessTokenRepository(db)
+	featureFlagRepo := repositories.NewFeatureFlagRepository(db)
+	passwordResetRepo := repositories.NewPasswordResetRepository(db)
+	emailVerificationRepo := repositories.NewEmailVerificationRepository(db)
+	loginLinkRepo := repositories.NewLoginLinkRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	otpDeliveryRepo := repositories.NewOTPDeliveryRepository(db)
+	loginEventRepo := repositories.NewLoginEventRepository(db)
 
 	// Initialize Redis and Weaviate clients for document service
-	ctx := context.Background()
-
 	// Create minimal configs.Config for Redis and Weaviate
 	// Import configs package to use its Config type
 	type MinimalConfig struct {
@@ -139,6 +158,12 @@ func main() {
 		log.Printf("REDIS_URL not set. Document service will not be available.")
 	}
 
+	// Permission checks read each user's permissions from Redis; role and permission changes invalidate them
+	permissionCache := repositories.NewPermissionCache(redisClient, time.Duration(cfg.PermCache.TTL)*time.Second)
+	userRepo.SetPermissionCache(permissionCache)
+	roleRepo.SetPermissionCache(permissionCache)
+	permRepo.SetPermissionCache(permissionCache)
+
 	// Initialize Weaviate client
 	var weaviateClient *weaviate.WeaviateClient
 	weaviateConfigFull := &configs.Config{
@@ -163,6 +188,25 @@ func main() {
 		}
 	}()
 
+	// Detect schema drift between the code and the live Weaviate classes before serving searches
+	if weaviateClient != nil {
+		autoMigrate := os.Getenv("WEAVIATE_SCHEMA_AUTO_MIGRATE") == "true"
+		report, err := weaviateClient.VerifySchema(ctx, autoMigrate)
+		if err != nil {
+			log.Printf("Warning: Failed to verify Weaviate schema: %v", err)
+		} else {
+			for _, drift := range report.Drift {
+				log.Printf("Weaviate schema drift in %s: missing=%v mismatched=%v missing_vector=%v migrated=%v",
+					drift.Class, drift.MissingProperties, drift.TypeMismatches, drift.MissingVector, drift.Migrated)
+			}
+			if report.OK() {
+				log.Printf("Weaviate schema verified (%d document classes)", report.ClassesChecked)
+			} else {
+				log.Printf("Warning: Weaviate schema drift detected in %d classes; /readyz will report not ready", len(report.Drift))
+			}
+		}
+	}
+
 	// Initialize document repositories
 	docRepo := repositories.NewDocumentRepository(db, db)
 
@@ -180,16 +224,178 @@ func main() {
 		Persona:      personaRepo,
 		AuditLog:     auditLogRepo,
 		Screener:     screenerRepo,
+		APIUsage:     apiUsageRepo,
+		Feedback:     feedbackRepo,
 	}
 
 	// Initialize services
 	tokenService := auth.NewTokenService(cfg)
+	signingKeys, err := auth.ParseSigningKeys(cfg.JWT.SigningKeys)
+	if err != nil {
+		log.Fatalf("Invalid JWT signing key configuration: %v", err)
+	}
+	tokenService.SetSigningKeys(signingKeys)
+	if len(signingKeys) > 0 {
+		log.Printf("JWT signing keys loaded: %d, signing with %q (%d RS256 keys in /.well-known/jwks.json)", len(signingKeys), tokenService.SigningKeyID(time.Now()), len(tokenService.PublicKeys(time.Now())))
+	}
 	authService := services.NewAuthService(userRepo, tokenRepo, tokenService, cfg)
+	lockoutService := services.NewLockoutService(userRepo, orgRepo, auditLogRepo, cfg.Lockout)
+	authService.SetLockout(lockoutService)
+	authService.SetNewDeviceAlerts(services.NewNewDeviceAlertService(tokenRepo, orgRepo, auditLogRepo))
+	loginHistoryService := services.NewLoginHistoryService(loginEventRepo, userRepo)
+	authService.SetLoginHistory(loginHistoryService)
+	featureService := services.NewFeatureService(orgRepo, featureFlagRepo, auditLogRepo)
+	authService.SetFeatures(featureService)
+	proxySessions := services.NewProxySessions(cfg.Proxy)
+	authService.SetProxySessions(proxySessions)
+	subscriptionService := services.NewSubscriptionService(orgRepo, auditLogRepo)
+
+	// Org secrets are disabled (endpoints return 503) until SECRETS_ENCRYPTION_KEYS is set
+	var secretsKeyring *envelope.Keyring
+	if cfg.Secrets.EncryptionKeys != "" {
+		secretsKeyring, err = envelope.ParseKeyring(cfg.Secrets.EncryptionKeys)
+		if err != nil {
+			log.Fatalf("Invalid SECRETS_ENCRYPTION_KEYS: %v", err)
+		}
+	} else {
+		log.Println("SECRETS_ENCRYPTION_KEYS not set. Org secrets will not be available.")
+	}
+	orgSecretService := services.NewOrgSecretService(orgSecretRepo, auditLogRepo, secretsKeyring)
+
+	// OIDC sign-in answers 503 until OIDC_REDIRECT_URL is set; client secrets are org secrets
+	oidcService := services.NewOIDCService(authService, oidcProviderRepo, identityRepo, orgRepo, roleRepo, auditLogRepo, orgSecretService, cfg.OIDC)
+	if !oidcService.Enabled() {
+		log.Println("OIDC_REDIRECT_URL not set. OIDC single sign-on will not be available.")
+	}
+
+	// SAML sign-in answers 503 until SAML_BASE_URL is set; an org that enforces its IdP loses password and OTP login
+	samlService, err := services.NewSAMLService(authService, samlProviderRepo, identityRepo, orgRepo, roleRepo, auditLogRepo, cfg.SAML)
+	if err != nil {
+		log.Fatalf("Invalid SAML service provider key pair: %v", err)
+	}
+	if samlService.Enabled() {
+		authService.SetSSOPolicy(samlService)
+	} else {
+		log.Println("SAML_BASE_URL not set. SAML single sign-on will not be available.")
+	}
+
+	// Two-factor authentication seals TOTP secrets with the org secrets keys; without them it answers
+	// 503, and users who enabled it earlier cannot sign in with a password or OTP
+	twoFactorService := services.NewTwoFactorService(authService, twoFactorRepo, orgRepo, auditLogRepo, secretsKeyring, cfg.TwoFactor)
+	authService.SetTwoFactor(twoFactorService)
+	if !twoFactorService.Enabled() {
+		log.Println("SECRETS_ENCRYPTION_KEYS not set. Two-factor authentication will not be available.")
+	}
+
+	// Passkey sign-in answers 503 until WEBAUTHN_RP_ID is set
+	passkeyService, err := services.NewPasskeyService(authService, passkeyRepo, auditLogRepo, cfg.WebAuthn)
+	if err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
+	if !passkeyService.Enabled() {
+		log.Println("WEBAUTHN_RP_ID not set. Passkey sign-in will not be available.")
+	}
+
+	// Forgot-password answers 503 until PASSWORD_RESET_URL (the frontend page of the emailed link) is set
+	passwordResetService := services.NewPasswordResetService(authService, passwordResetRepo, auditLogRepo, cfg.Reset)
+	if !passwordResetService.Enabled() {
+		log.Println("PASSWORD_RESET_URL not set. Password reset will not be available.")
+	}
+
+	// Email changes answer 503 until EMAIL_CHANGE_URL (the frontend page of the confirmation link) is set
+	libreChatSync := services.NewLibreChatSync(cfg.LibreChat)
+	emailChangeService := services.NewEmailChangeService(authService, emailVerificationRepo, auditLogRepo, libreChatSync, cfg.Email)
+	if !emailChangeService.Enabled() {
+		log.Println("EMAIL_CHANGE_URL not set. Email changes will not be available.")
+	}
+
+	// Sign-in links answer 503 until LOGIN_LINK_URL (the frontend page of the emailed link) is set
+	loginLinkService := services.NewLoginLinkService(authService, loginLinkRepo, auditLogRepo, cfg.LoginLink)
+	if !loginLinkService.Enabled() {
+		log.Println("LOGIN_LINK_URL not set. Sign-in links will not be available.")
+	}
+
+	// Invitations answer 503 until INVITATION_URL (the frontend page of the emailed link) is set
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, roleRepo, orgRepo, auditLogRepo, tokenService, cfg.Invite)
+	if !invitationService.Enabled() {
+		log.Println("INVITATION_URL not set. Invitations will not be available.")
+	}
+
+	// Emails (OTP codes, links, notifications) go through MAIL_DRIVER: smtp, ses, sendgrid or log
+	emailMailer, err := mailer.New(context.Background(), mailer.Config{
+		Driver:         cfg.Mail.Driver,
+		FromEmail:      cfg.Mail.FromEmail,
+		FromName:       cfg.Mail.FromName,
+		SMTPHost:       cfg.Mail.SMTPHost,
+		SMTPPort:       cfg.Mail.SMTPPort,
+		SMTPUsername:   cfg.Mail.SMTPUsername,
+		SMTPPassword:   cfg.Mail.SMTPPassword,
+		SESRegion:      cfg.Mail.SESRegion,
+		SendGridAPIKey: cfg.Mail.SendGridAPIKey,
+		Retries:        cfg.Mail.Retries,
+		RetryDelay:     time.Duration(cfg.Mail.RetryDelay) * time.Millisecond,
+	})
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+	utils.SetMailer(emailMailer)
+
+	// OTP codes go by email unless OTP_SMS_PROVIDER (twilio or sns) is set and the user or org picks SMS
+	smsSender, err := sms.New(context.Background(), sms.Config{
+		Provider:         cfg.OTP.SMSProvider,
+		TwilioAccountSID: cfg.OTP.TwilioAccountSID,
+		TwilioAuthToken:  cfg.OTP.TwilioAuthToken,
+		TwilioFrom:       cfg.OTP.TwilioFrom,
+		SNSRegion:        cfg.OTP.SNSRegion,
+		SNSSenderID:      cfg.OTP.SNSSenderID,
+	})
+	if err != nil {
+		log.Fatalf("Invalid SMS provider configuration: %v", err)
+	}
+	if smsSender == nil {
+		log.Println("OTP_SMS_PROVIDER not set. OTP codes will only be sent by email.")
+	}
+	authService.SetOTPDelivery(services.NewOTPDelivery(userRepo, orgRepo, otpDeliveryRepo, smsSender, cfg.OTP))
+
+	// Super admins register permissions beyond the seeded system ones, for roles to be granted
+	permissionService := services.NewPermissionService(permRepo, auditLogRepo)
+
+	// API keys authenticate integrations as a service account user, limited to the key's scopes
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo, auditLogRepo)
+	oauthClientService := services.NewOAuthClientService(oauthClientRepo, userRepo, auditLogRepo, tokenService, cfg.OAuth)
+	// Personal access tokens are scoped long-lived tokens users mint for themselves
+	personalTokenService := services.NewPersonalAccessTokenService(personalTokenRepo, userRepo, auditLogRepo)
+
+	// Sessions are refresh tokens; users and their admins can revoke them remotely
+	sessionService := services.NewSessionService(tokenRepo, userRepo, auditLogRepo, libreChatSync, proxySessions)
+
+	// Super admins act as a user for support with short-lived, audited tokens
+	impersonationService := services.NewImpersonationService(userRepo, tokenService, auditLogRepo, time.Duration(cfg.JWT.ImpersonationTTL)*time.Minute)
+
+	// Sensitive operations need a sign-in or step-up within STEP_UP_MAX_AGE minutes
+	stepUpService := services.NewStepUpService(authService, auditLogRepo)
+
+	// SCIM provisioning from the orgs' identity providers, authenticated with an org API key
+	scimService := services.NewSCIMService(userRepo, roleRepo, tokenRepo, auditLogRepo)
 
 	// Initialize document service (only if Redis and Weaviate are available)
 	var documentHandler *handlers.DocumentHandler
 	log.Printf("Checking document service dependencies - Redis: %v, Weaviate: %v", redisClient != nil, weaviateClient != nil)
 
+	// Uploaded files go to local disk or S3 (see STORAGE_BACKEND)
+	fileStorage, err := storage.New(context.Background(), storage.Config{
+		Backend:          cfg.Storage.Backend,
+		LocalRoot:        cfg.Storage.LocalRoot,
+		S3Bucket:         cfg.Storage.S3Bucket,
+		S3Region:         cfg.Storage.S3Region,
+		S3Endpoint:       cfg.Storage.S3Endpoint,
+		S3Prefix:         cfg.Storage.S3Prefix,
+		S3ForcePathStyle: cfg.Storage.S3ForcePathStyle,
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure file storage: %v", err)
+	}
+
 	if redisClient != nil && weaviateClient != nil {
 		log.Println("Initializing document service...")
 		baseService := services.NewBaseService(repos, redisClient, weaviateClient)
@@ -198,6 +404,13 @@ func main() {
 		// We need to pass a configs.Config, but we'll create a minimal one
 		minimalConfigsConfig := &configs.Config{} // Empty config, document service uses env vars
 		svcs := services.NewServices(baseService, userRepo, tokenRepo, tokenService, minimalConfigsConfig)
+		if orgSecretService.Enabled() {
+			svcs.Document.WorkerPool.SetSecretResolver(orgSecretService)
+		}
+		svcs.Document.SetStorage(fileStorage, time.Duration(cfg.Storage.SignedURLTTL)*time.Second)
+		svcs.Document.SetCanary(cfg.Canary)
+		svcs.Document.FolderImport = cfg.Import
+		svcs.Document.Uploads = cfg.Uploads
 
 		// Initialize document schema
 		if err := svcs.Document.InitSchema(ctx); err != nil {
@@ -216,31 +429,105 @@ func main() {
 		log.Println("Document service not initialized (Redis or Weaviate unavailable)")
 	}
 
+	// Org timezones drive usage hour buckets, job day boundaries and report periods
+	orgTimezones := orgtime.NewResolver(orgRepo.GetTimezone, 5*time.Minute)
+
 	// Initialize middleware
 	authMW := middleware.NewAuthMiddleware(tokenService)
+	authMW.SetAPIKeys(apiKeyService)
+	authMW.SetPersonalTokens(personalTokenService)
 	rlsMW := middleware.NewRLSMiddleware(db)
 	permMW := middleware.NewPermissionMiddleware(userRepo)
+	featureMW := middleware.NewFeatureMiddleware(featureService)
+	apiUsageMW := middleware.NewAPIUsageMiddleware(apiUsageRepo, orgTimezones, time.Minute)
+	apiUsageMW.Start()
+	authRateLimitMW := middleware.NewAuthRateLimitMiddleware(redisClient, authMW, cfg.RateLimit)
+	if redisClient == nil {
+		log.Printf("REDIS_URL not set. Auth rate limiting will not be available.")
+	}
+	captchaVerifier, err := captcha.New(captcha.Config{
+		Provider:  cfg.Captcha.Provider,
+		SecretKey: cfg.Captcha.SecretKey,
+		MinScore:  cfg.Captcha.MinScore,
+		Hostname:  cfg.Captcha.Hostname,
+	})
+	if err != nil {
+		log.Fatalf("Invalid CAPTCHA configuration: %v", err)
+	}
+	if captchaVerifier == nil {
+		log.Println("CAPTCHA_PROVIDER not set. Login and OTP requests will not require a CAPTCHA.")
+	}
+	captchaMW := middleware.NewCaptchaMiddleware(captchaVerifier, authMW)
+	stepUpMW := middleware.NewStepUpMiddleware(cfg.StepUp)
+	if cfg.StepUp.MaxAge <= 0 {
+		log.Println("STEP_UP_MAX_AGE is 0. Sensitive operations will not require recent authentication.")
+	}
+
+	// Expire users stuck in "pending" (see PENDING_USER_EXPIRY_* settings)
+	pendingUserExpiry := services.NewPendingUserExpiryJob(userRepo, orgTimezones, cfg.Pending)
+	pendingUserExpiry.Start()
+
+	// Compress, expire and cap document processing artifacts (see ARTIFACT_* settings)
+	artifactLifecycle := services.NewArtifactLifecycleJob(docRepo, orgTimezones, cfg.Artifacts)
+	artifactLifecycle.Start()
+
+	// Revoke expired role assignments and warn org admins ahead of expiry (see ROLE_EXPIRY_* settings)
+	roleExpiry := services.NewRoleExpiryJob(roleRepo, userRepo, auditLogRepo, cfg.Roles)
+	roleExpiry.Start()
+
+	// Remove abandoned resumable uploads and their chunks (see UPLOAD_SESSION_* settings)
+	uploadSessionCleanup := services.NewUploadSessionCleanupJob(docRepo, fileStorage, cfg.Uploads)
+	uploadSessionCleanup.Start()
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, authMW, orgRepo)
-	userHandler := handlers.NewUserHandler(userRepo, roleRepo, orgRepo)
+	authHandler.SetOIDC(oidcService)
+	authHandler.SetSAML(samlService)
+	userHandler := handlers.NewUserHandler(userRepo, roleRepo, orgRepo, libreChatSync)
 	orgHandler := handlers.NewOrganizationHandler(orgRepo, roleRepo, permRepo)
+	orgHandler.SetSchedule(orgTimezones, apiUsageMW, pendingUserExpiry, artifactLifecycle, roleExpiry, uploadSessionCleanup)
+	subscriptionHandler := handlers.NewSubscriptionHandler(orgRepo, subscriptionService)
 	roleHandler := handlers.NewRoleHandler(roleRepo)
-	permHandler := handlers.NewPermissionHandler(permRepo)
+	permHandler := handlers.NewPermissionHandler(permissionService)
 	templateHandler := handlers.NewTemplateHandler(templateRepo)
 	personaHandler := handlers.NewPersonaHandler(personaRepo)
 	folderHandler := handlers.NewFolderHandler(folderRepo, docRepo)
 
 	// File handler removed - all file operations now use /api/v1/documents
 	// The fileHandler is no longer needed as we use a unified documents API
-	// fileHandler := handlers.NewFileHandler(folderRepo, docRepo, docService, cfg.App.StoragePath)
+	// fileHandler := handlers.NewFileHandler(folderRepo, docRepo, docService, fileStorage)
 	staticHandler := handlers.NewStaticHandler(cfg.App.StoragePath, docRepo)
+	staticHandler.SetStorage(fileStorage)
 	libreChatHandler := handlers.NewLibreChatHandler()
-	auditLogHandler := handlers.NewAuditLogHandler(auditLogRepo)
+	auditLogHandler := handlers.NewAuditLogHandler(auditLogRepo, userRepo, cfg.Proxy)
+	accessReviewHandler := handlers.NewAccessReviewHandler(services.NewAccessReviewService(accessReviewRepo, auditLogRepo))
 	screenerHandler := handlers.NewScreenerHandler(screenerRepo, userRepo)
+	apiUsageHandler := handlers.NewAPIUsageHandler(apiUsageRepo, orgTimezones)
+	feedbackHandler := handlers.NewSearchFeedbackHandler(feedbackRepo, docRepo)
+	healthHandler := handlers.NewHealthHandler(db, weaviateClient)
+	orgSecretHandler := handlers.NewOrgSecretHandler(orgSecretService, cfg.Proxy)
+	oidcProviderHandler := handlers.NewOIDCProviderHandler(oidcService)
+	samlProviderHandler := handlers.NewSAMLProviderHandler(samlService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, authMW)
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService, authMW)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	oauthClientHandler := handlers.NewOAuthClientHandler(oauthClientService, authMW)
+	personalTokenHandler := handlers.NewPersonalAccessTokenHandler(personalTokenService)
+	featureHandler := handlers.NewFeatureHandler(featureService)
+	scimHandler := handlers.NewSCIMHandler(scimService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	loginHistoryHandler := handlers.NewLoginHistoryHandler(loginHistoryService)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, authMW)
+	emailChangeHandler := handlers.NewEmailChangeHandler(emailChangeService, authMW)
+	loginLinkHandler := handlers.NewLoginLinkHandler(loginLinkService, authMW)
+	invitationHandler := handlers.NewInvitationHandler(invitationService, authMW)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService, authMW)
+	jwksHandler := handlers.NewJWKSHandler(tokenService)
+	stepUpHandler := handlers.NewStepUpHandler(stepUpService, authMW)
 
 	// Setup router
-	router := setupRouter(cfg, authHandler, userHandler, orgHandler, roleHandler, permHandler, templateHandler, personaHandler, folderHandler, staticHandler, libreChatHandler, auditLogHandler, screenerHandler, documentHandler, authMW, rlsMW, permMW)
+	router := setupRouter(cfg, authHandler, userHandler, orgHandler, subscriptionHandler, roleHandler, permHandler, templateHandler, personaHandler, folderHandler, staticHandler, libreChatHandler, auditLogHandler, screenerHandler, apiUsageHandler, feedbackHandler, healthHandler, orgSecretHandler, oidcProviderHandler, samlProviderHandler, twoFactorHandler, passkeyHandler, apiKeyHandler, scimHandler, sessionHandler, loginHistoryHandler, lockoutHandler, passwordResetHandler, emailChangeHandler, loginLinkHandler, invitationHandler, accessReviewHandler, impersonationHandler, jwksHandler, stepUpHandler, oauthClientHandler, personalTokenHandler, featureHandler, documentHandler, authMW, rlsMW, permMW, featureMW, apiUsageMW, authRateLimitMW, captchaMW, stepUpMW)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -259,19 +546,25 @@ func main() {
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Wait for an interrupt signal to gracefully shutdown the server
+	<-ctx.Done()
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Persist any API usage samples collected since the last flush
+	apiUsageMW.Stop(shutdownCtx)
+	pendingUserExpiry.Stop()
+	artifactLifecycle.Stop()
+	roleExpiry.Stop()
+	uploadSessionCleanup.Stop()
+	libreChatSync.Close(shutdownCtx)
+
 	log.Println("Server exited")
 }
 
@@ -280,6 +573,7 @@ func setupRouter(
 	authHandler *handlers.AuthHandler,
 	userHandler *handlers.UserHandler,
 	orgHandler *handlers.OrganizationHandler,
+	subscriptionHandler *handlers.SubscriptionHandler,
 	roleHandler *handlers.RoleHandler,
 	permHandler *handlers.PermissionHandler,
 	templateHandler *handlers.TemplateHandler,
@@ -290,22 +584,57 @@ func setupRouter(
 	libreChatHandler *handlers.LibreChatHandler,
 	auditLogHandler *handlers.AuditLogHandler,
 	screenerHandler *handlers.ScreenerHandler,
+	apiUsageHandler *handlers.APIUsageHandler,
+	feedbackHandler *handlers.SearchFeedbackHandler,
+	healthHandler *handlers.HealthHandler,
+	orgSecretHandler *handlers.OrgSecretHandler,
+	oidcProviderHandler *handlers.OIDCProviderHandler,
+	samlProviderHandler *handlers.SAMLProviderHandler,
+	twoFactorHandler *handlers.TwoFactorHandler,
+	passkeyHandler *handlers.PasskeyHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	scimHandler *handlers.SCIMHandler,
+	sessionHandler *handlers.SessionHandler,
+	loginHistoryHandler *handlers.LoginHistoryHandler,
+	lockoutHandler *handlers.LockoutHandler,
+	passwordResetHandler *handlers.PasswordResetHandler,
+	emailChangeHandler *handlers.EmailChangeHandler,
+	loginLinkHandler *handlers.LoginLinkHandler,
+	invitationHandler *handlers.InvitationHandler,
+	accessReviewHandler *handlers.AccessReviewHandler,
+	impersonationHandler *handlers.ImpersonationHandler,
+	jwksHandler *handlers.JWKSHandler,
+	stepUpHandler *handlers.StepUpHandler,
+	oauthClientHandler *handlers.OAuthClientHandler,
+	personalTokenHandler *handlers.PersonalAccessTokenHandler,
+	featureHandler *handlers.FeatureHandler,
 	documentHandler *handlers.DocumentHandler, // Can be nil if not initialized
 	authMW *middleware.AuthMiddleware,
 	rlsMW *middleware.RLSMiddleware,
 	permMW *middleware.PermissionMiddleware,
+	featureMW *middleware.FeatureMiddleware,
+	apiUsageMW *middleware.APIUsageMiddleware,
+	authRateLimitMW *middleware.AuthRateLimitMiddleware,
+	captchaMW *middleware.CaptchaMiddleware,
+	stepUpMW *middleware.StepUpMiddleware,
 ) *gin.Engine {
 	if cfg.App.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
+	// gin trusts every peer's X-Forwarded-For by default, which would let clients pick their own IP
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid SERVER_TRUSTED_PROXIES: %v", err)
+	}
 
 	// Global middleware
-	router.Use(gin.Logger())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.RequestLogger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.ErrorMiddleware())
+	router.Use(apiUsageMW.Track())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -315,24 +644,83 @@ func setupRouter(
 		})
 	})
 
+	// Readiness check (database + Weaviate schema drift)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	// Public keys of RS256 token signing keys
+	router.GET("/.well-known/jwks.json", jwksHandler.Keys)
+
 	// Public routes
 	v1 := router.Group("/api/v1")
 	{
 		// Auth routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/send-otp", authHandler.SendOTP)
-			auth.POST("/verify-otp", authHandler.VerifyOTP)
-			auth.POST("/resend-otp", authHandler.ResendOTP)
+			auth.POST("/login", authRateLimitMW.Limit("login"), captchaMW.Require(), authHandler.Login)
+			auth.POST("/send-otp", authRateLimitMW.Limit("send-otp"), captchaMW.Require(), authHandler.SendOTP)
+			auth.POST("/verify-otp", authRateLimitMW.Limit("verify-otp"), authHandler.VerifyOTP)
+			auth.POST("/resend-otp", authRateLimitMW.Limit("send-otp"), captchaMW.Require(), authHandler.ResendOTP)
+			auth.PUT("/otp-channel", authMW.RequireAuth(), authMW.RequireUserToken(), authHandler.SetOTPChannel)
+			auth.POST("/send-link", authRateLimitMW.Limit("send-otp"), captchaMW.Require(), loginLinkHandler.SendLink)
+			auth.POST("/verify-link", authRateLimitMW.Limit("verify-otp"), loginLinkHandler.VerifyLink)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/forgot-password", passwordResetHandler.ForgotPassword)
+			auth.POST("/reset-password", passwordResetHandler.ResetPassword)
+			auth.POST("/email-change", authMW.RequireAuth(), authMW.RequireUserToken(), emailChangeHandler.RequestChange)
+			auth.POST("/email-change/confirm", emailChangeHandler.ConfirmChange)
+			auth.POST("/proxy-exchange", authHandler.ProxyTokenExchange)
+			auth.POST("/introspect", authHandler.Introspect)
+			auth.GET("/oidc/:org_slug/login", authHandler.OIDCLogin)
+			auth.GET("/oidc/callback", authHandler.OIDCCallback)
+			auth.GET("/saml/:org_slug/metadata", authHandler.SAMLMetadata)
+			auth.GET("/saml/:org_slug/login", authHandler.SAMLLogin)
+			auth.POST("/saml/:org_slug/acs", authHandler.SAMLACS)
+			auth.POST("/2fa/verify", twoFactorHandler.Verify)
+			auth.POST("/2fa/enroll", twoFactorHandler.Enroll)
+			auth.POST("/2fa/enroll/confirm", twoFactorHandler.EnrollConfirm)
+			auth.GET("/2fa", authMW.RequireAuth(), authMW.RequireUserToken(), twoFactorHandler.Status)
+			auth.POST("/2fa/setup", authMW.RequireAuth(), authMW.RequireUserToken(), twoFactorHandler.Setup)
+			auth.POST("/2fa/confirm", authMW.RequireAuth(), authMW.RequireUserToken(), twoFactorHandler.Confirm)
+			auth.POST("/2fa/backup-codes", authMW.RequireAuth(), authMW.RequireUserToken(), twoFactorHandler.RegenerateBackupCodes)
+			auth.DELETE("/2fa", authMW.RequireAuth(), authMW.RequireUserToken(), twoFactorHandler.Disable)
+			auth.POST("/passkeys/login/begin", passkeyHandler.BeginLogin)
+			auth.POST("/passkeys/login/finish", passkeyHandler.FinishLogin)
+			auth.GET("/passkeys", authMW.RequireAuth(), authMW.RequireUserToken(), passkeyHandler.List)
+			auth.POST("/passkeys/register/begin", authMW.RequireAuth(), authMW.RequireUserToken(), passkeyHandler.BeginRegistration)
+			auth.POST("/passkeys/register/finish", authMW.RequireAuth(), authMW.RequireUserToken(), passkeyHandler.FinishRegistration)
+			auth.PATCH("/passkeys/:id", authMW.RequireAuth(), authMW.RequireUserToken(), passkeyHandler.Rename)
+			auth.DELETE("/passkeys/:id", authMW.RequireAuth(), authMW.RequireUserToken(), passkeyHandler.Delete)
+			auth.POST("/step-up", authMW.RequireAuth(), authMW.RequireUserToken(), authRateLimitMW.Limit("step-up"), stepUpHandler.StepUp)
+			auth.POST("/step-up/send-otp", authMW.RequireAuth(), authMW.RequireUserToken(), authRateLimitMW.Limit("send-otp"), stepUpHandler.SendOTP)
+			auth.GET("/sessions", authMW.RequireAuth(), authMW.RequireUserToken(), sessionHandler.List)
+			auth.DELETE("/sessions", authMW.RequireAuth(), authMW.RequireUserToken(), sessionHandler.RevokeAll)
+			auth.DELETE("/sessions/:id", authMW.RequireAuth(), authMW.RequireUserToken(), sessionHandler.Revoke)
+			auth.GET("/personal-tokens", authMW.RequireAuth(), authMW.RequireUserToken(), personalTokenHandler.List)
+			auth.POST("/personal-tokens", authMW.RequireAuth(), authMW.RequireUserToken(), stepUpMW.RequireRecentAuth(), personalTokenHandler.Create)
+			auth.DELETE("/personal-tokens/:id", authMW.RequireAuth(), authMW.RequireUserToken(), personalTokenHandler.Revoke)
 			auth.POST("/logout", authMW.RequireAuth(), authHandler.Logout)
+			auth.POST("/logout-all", authMW.RequireAuth(), authMW.RequireUserToken(), sessionHandler.LogoutAll)
 			auth.GET("/me", authMW.RequireAuth(), authHandler.Me)
+			auth.GET("/me/logins", authMW.RequireAuth(), authMW.RequireUserToken(), loginHistoryHandler.List)
+		}
+
+		// Accepting an invitation creates the account, so it needs no token
+		v1.POST("/invitations/accept", authRateLimitMW.Limit("accept-invitation"), invitationHandler.Accept)
+
+		// OAuth2 token endpoint; clients authenticate with their secret
+		v1.POST("/oauth/token", authRateLimitMW.Limit("oauth-token"), oauthClientHandler.Token)
+
+		// Service-to-service routes, authenticated with X-Proxy-Secret instead of a user token
+		internal := v1.Group("/internal")
+		{
+			internal.POST("/secrets/resolve", orgSecretHandler.Resolve)
+			internal.POST("/audit/proxy-login", auditLogHandler.RecordProxyLogin)
 		}
 
 		// LibreChat routes (protected)
 		librechat := v1.Group("/librechat")
 		librechat.Use(authMW.RequireAuth())
+		librechat.Use(authMW.RequireUserToken())
 		{
 			librechat.GET("/credentials", libreChatHandler.GetCredentials)
 			librechat.POST("/login", libreChatHandler.Login)
@@ -352,8 +740,18 @@ func setupRouter(
 				users.PUT("/:id", permMW.RequirePermission("users", "update"), userHandler.Update)
 				users.DELETE("/:id", permMW.RequirePermission("users", "delete"), userHandler.Delete)
 				users.GET("/:id/permissions", userHandler.GetPermissions)
-				users.POST("/:id/roles", permMW.RequirePermission("users", "update"), userHandler.AssignRole)
-				users.DELETE("/:id/roles/:role_id", permMW.RequirePermission("users", "update"), userHandler.RemoveRole)
+				users.POST("/:id/permissions/check", userHandler.CheckPermissions)
+				users.GET("/:id/permissions/explain", userHandler.ExplainPermission)
+				users.POST("/:id/roles", permMW.RequirePermission("users", "update"), stepUpMW.RequireRecentAuth(), userHandler.AssignRole)
+				users.PUT("/:id/roles", permMW.RequirePermission("users", "update"), stepUpMW.RequireRecentAuth(), userHandler.SetRoles)
+				users.DELETE("/:id/roles/:role_id", permMW.RequirePermission("users", "update"), stepUpMW.RequireRecentAuth(), userHandler.RemoveRole)
+				users.POST("/:id/unlock", permMW.RequirePermission("users", "update"), lockoutHandler.Unlock)
+				users.GET("/:id/sessions", permMW.RequirePermission("users", "read"), sessionHandler.ListForUser)
+				users.DELETE("/:id/sessions", permMW.RequirePermission("users", "update"), sessionHandler.RevokeAllForUser)
+				users.DELETE("/:id/sessions/:session_id", permMW.RequirePermission("users", "update"), sessionHandler.RevokeForUser)
+				users.GET("/:id/personal-tokens", permMW.RequirePermission("users", "read"), personalTokenHandler.ListForUser)
+				users.DELETE("/:id/personal-tokens/:token_id", permMW.RequirePermission("users", "update"), personalTokenHandler.RevokeForUser)
+				users.GET("/:id/login-history", permMW.RequirePermission("users", "read"), loginHistoryHandler.ListForUser)
 			}
 
 			// Organizations
@@ -362,8 +760,57 @@ func setupRouter(
 				orgs.POST("", permMW.RequirePermission("organizations", "create"), orgHandler.Create)
 				orgs.GET("", orgHandler.List)
 				orgs.GET("/:id", orgHandler.GetByID)
+				orgs.GET("/:id/schedule", orgHandler.Schedule)
 				orgs.PUT("/:id", permMW.RequirePermission("organizations", "update"), orgHandler.Update)
-				orgs.DELETE("/:id", permMW.RequirePermission("organizations", "delete"), orgHandler.Delete)
+				orgs.DELETE("/:id", permMW.RequirePermission("organizations", "delete"), stepUpMW.RequireRecentAuth(), orgHandler.Delete)
+				orgs.POST("/:id/subscription/upgrade", permMW.RequirePermission("organizations", "update"), subscriptionHandler.Upgrade)
+				orgs.POST("/:id/subscription/downgrade", permMW.RequirePermission("organizations", "update"), subscriptionHandler.Downgrade)
+				orgs.POST("/:id/subscription/cancel", permMW.RequirePermission("organizations", "update"), subscriptionHandler.Cancel)
+				orgs.GET("/:id/secrets", permMW.RequirePermission("organizations", "update"), orgSecretHandler.List)
+				orgs.POST("/:id/secrets", permMW.RequirePermission("organizations", "update"), orgSecretHandler.Create)
+				orgs.PUT("/:id/secrets/:name", permMW.RequirePermission("organizations", "update"), orgSecretHandler.Rotate)
+				orgs.DELETE("/:id/secrets/:name", permMW.RequirePermission("organizations", "update"), orgSecretHandler.Delete)
+				orgs.GET("/:id/oidc", permMW.RequirePermission("organizations", "update"), oidcProviderHandler.Get)
+				orgs.PUT("/:id/oidc", authMW.RequireUserToken(), permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), oidcProviderHandler.Set)
+				orgs.DELETE("/:id/oidc", authMW.RequireUserToken(), permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), oidcProviderHandler.Delete)
+				orgs.GET("/:id/saml", permMW.RequirePermission("organizations", "update"), samlProviderHandler.Get)
+				orgs.PUT("/:id/saml", authMW.RequireUserToken(), permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), samlProviderHandler.Set)
+				orgs.DELETE("/:id/saml", authMW.RequireUserToken(), permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), samlProviderHandler.Delete)
+				orgs.PUT("/:id/features/:feature", featureHandler.Set)
+				orgs.DELETE("/:id/features/:feature", featureHandler.Clear)
+			}
+
+			// Features - What the caller's org can use, from its plan and feature flags
+			protected.GET("/features", featureHandler.List)
+
+			// API keys - Managed by org admins from a signed-in session, never with an API key
+			apiKeys := protected.Group("/api-keys")
+			apiKeys.Use(authMW.RequireUserToken())
+			{
+				apiKeys.GET("", permMW.RequirePermission("organizations", "update"), apiKeyHandler.List)
+				apiKeys.POST("", permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), apiKeyHandler.Create)
+				apiKeys.GET("/:id", permMW.RequirePermission("organizations", "update"), apiKeyHandler.GetByID)
+				apiKeys.DELETE("/:id", permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), apiKeyHandler.Revoke)
+			}
+
+			// OAuth clients - Confidential clients for the client_credentials grant, managed like API keys
+			oauthClients := protected.Group("/oauth-clients")
+			oauthClients.Use(authMW.RequireUserToken())
+			{
+				oauthClients.GET("", permMW.RequirePermission("organizations", "update"), oauthClientHandler.List)
+				oauthClients.POST("", permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), oauthClientHandler.Create)
+				oauthClients.GET("/:id", permMW.RequirePermission("organizations", "update"), oauthClientHandler.GetByID)
+				oauthClients.DELETE("/:id", permMW.RequirePermission("organizations", "update"), stepUpMW.RequireRecentAuth(), oauthClientHandler.Revoke)
+			}
+
+			// Invitations - Org admins invite by email into one of the org's roles
+			invitations := protected.Group("/invitations")
+			invitations.Use(authMW.RequireUserToken())
+			{
+				invitations.GET("", permMW.RequirePermission("users", "read"), invitationHandler.List)
+				invitations.POST("", permMW.RequirePermission("users", "create"), permMW.RequirePermission("users", "update"), stepUpMW.RequireRecentAuth(), invitationHandler.Create)
+				invitations.DELETE("/:id", permMW.RequirePermission("users", "create"), invitationHandler.Cancel)
+				invitations.POST("/:id/resend", permMW.RequirePermission("users", "create"), permMW.RequirePermission("users", "update"), stepUpMW.RequireRecentAuth(), invitationHandler.Resend)
 			}
 
 			// Roles
@@ -371,17 +818,22 @@ func setupRouter(
 			{
 				roles.POST("", permMW.RequirePermission("roles", "create"), roleHandler.Create)
 				roles.GET("", roleHandler.List)
+				roles.GET("/expirations", permMW.RequirePermission("users", "read"), roleHandler.ListExpirations)
 				roles.GET("/:id", roleHandler.GetByID)
-				roles.PUT("/:id", permMW.RequirePermission("roles", "update"), roleHandler.Update)
-				roles.DELETE("/:id", permMW.RequirePermission("roles", "delete"), roleHandler.Delete)
+				roles.PUT("/:id", permMW.RequirePermission("roles", "update"), stepUpMW.RequireRecentAuth(), roleHandler.Update)
+				roles.DELETE("/:id", permMW.RequirePermission("roles", "delete"), stepUpMW.RequireRecentAuth(), roleHandler.Delete)
 				roles.GET("/:id/permissions", roleHandler.GetPermissions)
-				roles.POST("/:id/permissions", permMW.RequirePermission("roles", "update"), roleHandler.AssignPermissions)
+				roles.POST("/:id/permissions", permMW.RequirePermission("roles", "update"), stepUpMW.RequireRecentAuth(), roleHandler.AssignPermissions)
+				roles.PUT("/:id/parents", permMW.RequirePermission("roles", "update"), stepUpMW.RequireRecentAuth(), roleHandler.SetParents)
 			}
 
 			// Permissions
 			permissions := protected.Group("/permissions")
 			{
 				permissions.GET("", permHandler.List)
+				permissions.POST("", authMW.RequireSuperAdmin(), permHandler.Create)
+				permissions.PUT("/:id", authMW.RequireSuperAdmin(), permHandler.Update)
+				permissions.DELETE("/:id", authMW.RequireSuperAdmin(), stepUpMW.RequireRecentAuth(), permHandler.Delete)
 			}
 
 			// Templates
@@ -416,6 +868,10 @@ func setupRouter(
 				folders.GET("/:id/permissions", folderHandler.GetPermissions)
 				folders.POST("/:id/permissions", permMW.RequirePermission("folders", "update"), folderHandler.AssignPermission)
 				folders.DELETE("/:id/permissions/:role_id", permMW.RequirePermission("folders", "update"), folderHandler.RemovePermission)
+				folders.GET("/:id/metadata-rules", folderHandler.GetMetadataRule)
+				folders.PUT("/:id/metadata-rules", permMW.RequirePermission("folders", "update"), folderHandler.SetMetadataRule)
+				folders.DELETE("/:id/metadata-rules", permMW.RequirePermission("folders", "update"), folderHandler.DeleteMetadataRule)
+				folders.POST("/:id/metadata-rules/apply", permMW.RequirePermission("folders", "update"), folderHandler.ApplyMetadataRules)
 			}
 
 			// Files - Only admin and superadmin can create/update/delete
@@ -443,6 +899,7 @@ func setupRouter(
 
 			// Screeners - Save, list, run, and delete screeners
 			screeners := protected.Group("/screeners")
+			screeners.Use(featureMW.RequireFeature(models.FeatureScreeners))
 			{
 				screeners.POST("/save", screenerHandler.SaveScreener)
 				screeners.GET("/saved", screenerHandler.GetSavedScreeners)
@@ -450,19 +907,43 @@ func setupRouter(
 				screeners.DELETE("/:id", screenerHandler.DeleteScreener)
 			}
 
+			// Search feedback - Helpful/unhelpful votes on returned chunks and per-document stats
+			searchFeedback := protected.Group("/search/feedback")
+			{
+				searchFeedback.POST("", feedbackHandler.Create)
+				searchFeedback.GET("/stats", feedbackHandler.Stats)
+				searchFeedback.GET("/documents/:document_id", feedbackHandler.DocumentStats)
+			}
+
 			// Documents - Upload, list, search, and delete documents
 			// Only register if documentHandler is provided (requires Redis and Weaviate)
 			if documentHandler != nil {
 				documents := protected.Group("/documents")
+				documents.Use(featureMW.RequireFeature(models.FeatureDocuments))
 				{
-					documents.POST("/upload", authMW.RequireAuth(), documentHandler.UploadDocument())
+					documents.POST("/upload", authMW.RequireAuth(), featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.UploadDocument())
+					documents.POST("/presign", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.PresignUpload())
+					documents.POST("/:document_id/finalize", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.FinalizeUpload())
+					documents.POST("/uploads", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.CreateUploadSession())
+					documents.GET("/uploads/:session_id", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.GetUploadSession())
+					documents.HEAD("/uploads/:session_id", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.GetUploadSession())
+					documents.PATCH("/uploads/:session_id", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.AppendUploadChunk())
+					documents.POST("/uploads/:session_id/complete", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.CompleteUploadSession())
+					documents.DELETE("/uploads/:session_id", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.CancelUploadSession())
 					documents.GET("", documentHandler.GetDocumentsWithFilter())
 					documents.GET("/search", documentHandler.SearchDocuments())
 					documents.GET("/jobs/:job_id", documentHandler.GetJobStatus())
 					documents.GET("/jobs", documentHandler.GetAllJobs())
 					documents.GET("/:document_id/download", documentHandler.DownloadDocument())
 					documents.DELETE("/:document_id", documentHandler.DeleteDocument())
+					documents.DELETE("/:document_id/artifacts", documentHandler.PurgeArtifacts())
+					documents.PUT("/:document_id/content", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.ReplaceContent())
+					documents.GET("/:document_id/versions", documentHandler.ListVersions())
+					documents.GET("/:document_id/versions/:version/download", documentHandler.DownloadVersion())
+					documents.POST("/:document_id/versions/:version/restore", featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.RestoreVersion())
 				}
+				// ZIP import creates folders, so it needs the same permission as creating one
+				folders.POST("/import-zip", permMW.RequirePermission("folders", "create"), featureMW.RequireFeature(models.FeatureDocumentUpload), documentHandler.ImportFolderZip())
 				log.Println("Document routes registered: /api/v1/documents")
 			} else {
 				log.Println("Document routes NOT registered - documentHandler is nil")
@@ -476,6 +957,18 @@ func setupRouter(
 		{
 			admin.GET("/users", userHandler.List)
 			admin.GET("/organizations", orgHandler.List)
+			admin.GET("/api-usage", apiUsageHandler.Report)
+			admin.GET("/access-review", accessReviewHandler.Export)
+			admin.GET("/access-review/jobs/:id", accessReviewHandler.Job)
+			admin.GET("/access-review/jobs/:id/download", accessReviewHandler.Download)
+			admin.POST("/impersonate/:user_id", stepUpMW.RequireRecentAuth(), impersonationHandler.Impersonate)
+
+			// Document pipeline canary runs (requires Redis and Weaviate, like the document routes)
+			if documentHandler != nil {
+				admin.POST("/pipeline-canary", documentHandler.StartPipelineCanary())
+				admin.GET("/pipeline-canary", documentHandler.ComparePipelineCanary())
+				admin.DELETE("/pipeline-canary", documentHandler.DeletePipelineCanary())
+			}
 		}
 
 		// Static file serving route (protected)
@@ -487,6 +980,27 @@ func setupRouter(
 		{
 			static.GET("/resources/folder/file/*path", staticHandler.ServeFile)
 		}
+
+		// SCIM 2.0 provisioning (RFC 7644) for identity providers, with an org API key as the bearer
+		// token. The key's scopes decide what the IdP may change.
+		scim := router.Group("/scim/v2")
+		scim.Use(authMW.RequireAPIKey())
+		scim.Use(rlsMW.SetRLSContext())
+		{
+			scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+			scim.GET("/Users", permMW.RequirePermission("users", "list"), scimHandler.ListUsers)
+			scim.POST("/Users", permMW.RequirePermission("users", "create"), scimHandler.CreateUser)
+			scim.GET("/Users/:id", permMW.RequirePermission("users", "read"), scimHandler.GetUser)
+			scim.PUT("/Users/:id", permMW.RequirePermission("users", "update"), scimHandler.ReplaceUser)
+			scim.PATCH("/Users/:id", permMW.RequirePermission("users", "update"), scimHandler.PatchUser)
+			scim.DELETE("/Users/:id", permMW.RequirePermission("users", "delete"), scimHandler.DeleteUser)
+			scim.GET("/Groups", permMW.RequirePermission("roles", "list"), scimHandler.ListGroups)
+			scim.POST("/Groups", permMW.RequirePermission("roles", "create"), permMW.RequirePermission("roles", "assign"), scimHandler.CreateGroup)
+			scim.GET("/Groups/:id", permMW.RequirePermission("roles", "read"), scimHandler.GetGroup)
+			scim.PUT("/Groups/:id", permMW.RequirePermission("roles", "update"), permMW.RequirePermission("roles", "assign"), scimHandler.ReplaceGroup)
+			scim.PATCH("/Groups/:id", permMW.RequirePermission("roles", "update"), permMW.RequirePermission("roles", "assign"), scimHandler.PatchGroup)
+			scim.DELETE("/Groups/:id", permMW.RequirePermission("roles", "delete"), scimHandler.DeleteGroup)
+		}
 	}
 
 	return router
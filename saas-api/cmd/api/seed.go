@@ -0,0 +1,600 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saas-api/cmd/configs"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/postgres"
+	"saas-api/pkg/utils"
+	"saas-api/pkg/weaviate"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultSeedData is the demo dataset used when -file is not given
+//
+//go:embed seed_data.json
+var defaultSeedData []byte
+
+// SeedData describes the dataset created by `saas-api seed`
+type SeedData struct {
+	Organization struct {
+		Name             string `json:"name"`
+		Slug             string `json:"slug"`
+		SubscriptionPlan string `json:"subscription_plan"`
+		MaxUsers         int    `json:"max_users"`
+		MaxStorageGB     int    `json:"max_storage_gb"`
+	} `json:"organization"`
+	SuperAdmin seedUser      `json:"super_admin"`
+	Roles      []seedRole    `json:"roles"`
+	Users      []seedUser    `json:"users"`
+	Folders    []seedFolder  `json:"folders"`
+	Templates  []seedContent `json:"templates"`
+	Personas   []seedContent `json:"personas"`
+	Screeners  []struct {
+		Owner        string `json:"owner"` // Email of a seeded user
+		ScreenerName string `json:"screener_name"`
+		TableName    string `json:"table_name"`
+		Query        string `json:"query"`
+		UniverseList string `json:"universe_list"`
+		Explainer    string `json:"explainer"`
+	} `json:"screeners"`
+}
+
+type seedUser struct {
+	Email     string   `json:"email"`
+	Password  string   `json:"password"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	OrgRole   string   `json:"org_role"`
+	Roles     []string `json:"roles"`
+}
+
+type seedRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"` // "resource:action", or "*" for every permission
+	IsDefault   bool     `json:"is_default"`
+}
+
+type seedFolder struct {
+	Name      string         `json:"name"`
+	Children  []seedFolder   `json:"children"`
+	Documents []seedDocument `json:"documents"`
+}
+
+type seedDocument struct {
+	Name   string           `json:"name"`
+	Chunks []weaviate.Chunk `json:"chunks"`
+}
+
+// seedContent is shared by templates and personas; Template links a persona to a seeded template by name
+type seedContent struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Framework   string                 `json:"framework"`
+	Template    string                 `json:"template"`
+	Content     map[string]interface{} `json:"content"`
+}
+
+// seeder writes the whole dataset in one transaction, so a failure leaves nothing behind and the
+// next run starts over. It inserts the rows itself because the repositories run on the pool.
+type seeder struct {
+	tx             pgx.Tx
+	weaviateClient *weaviate.WeaviateClient
+	resourcesPath  string
+
+	org        *models.Organization
+	superAdmin *models.User
+	roles      map[string]uuid.UUID
+	users      map[string]uuid.UUID
+	templates  map[string]uuid.UUID
+	documents  int
+}
+
+// runSeed implements `saas-api seed [-file seed.json] [-weaviate=false]`.
+// Seeding is idempotent per organization: if the org slug already exists, only the super admin is ensured.
+// Everything is one transaction; on failure it is rolled back and the org's files are removed.
+func runSeed(ctx context.Context, db *postgres.DB, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := flags.String("file", "", "seed data JSON file (default: built-in demo dataset)")
+	useWeaviate := flags.Bool("weaviate", os.Getenv("WEAVIATE_HOST") != "", "index seeded documents in Weaviate (default: true when WEAVIATE_HOST is set)")
+	flags.Parse(args)
+
+	raw := defaultSeedData
+	if *file != "" {
+		var err error
+		if raw, err = os.ReadFile(*file); err != nil {
+			return fmt.Errorf("failed to read seed file: %w", err)
+		}
+	}
+
+	var data SeedData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("invalid seed data: %w", err)
+	}
+
+	resourcesPath := os.Getenv("RESOURCES_BASE_PATH")
+	if resourcesPath == "" {
+		resourcesPath = "uploads"
+	}
+
+	s := &seeder{
+		resourcesPath: resourcesPath,
+		roles:         make(map[string]uuid.UUID),
+		users:         make(map[string]uuid.UUID),
+		templates:     make(map[string]uuid.UUID),
+	}
+
+	if *useWeaviate {
+		s.weaviateClient = connectWeaviateForSeed()
+	}
+	if s.weaviateClient == nil {
+		log.Println("Seed: Weaviate not used - documents are marked processed with fake embeddings and will not appear in search")
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	s.tx = tx
+
+	if err := s.run(ctx, &data); err != nil {
+		if s.org != nil {
+			_ = os.RemoveAll(filepath.Join(s.resourcesPath, s.org.ID.String()))
+		}
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit seed data: %w", err)
+	}
+	s.logSummary(&data)
+	return nil
+}
+
+func connectWeaviateForSeed() (client *weaviate.WeaviateClient) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Seed: Weaviate unavailable (%v)", r)
+			client = nil
+		}
+	}()
+	return weaviate.NewWeaviateClient(&configs.Config{
+		WeaviateHost:   os.Getenv("WEAVIATE_HOST"),
+		WeaviatePort:   os.Getenv("WEAVIATE_PORT"),
+		WeaviateScheme: os.Getenv("WEAVIATE_SCHEME"),
+	})
+}
+
+func (s *seeder) run(ctx context.Context, data *SeedData) error {
+	superAdmin, _, err := s.ensureUser(ctx, data.SuperAdmin, nil, true)
+	if err != nil {
+		return fmt.Errorf("super admin: %w", err)
+	}
+	s.superAdmin = superAdmin
+
+	var existingID uuid.UUID
+	err = s.tx.QueryRow(ctx, `SELECT id FROM organizations WHERE slug = $1 AND deleted_at IS NULL`, data.Organization.Slug).Scan(&existingID)
+	if err == nil {
+		log.Printf("Seed: organization %q already exists (%s); nothing else to do", data.Organization.Slug, existingID)
+		return nil
+	}
+	if err != pgx.ErrNoRows {
+		return fmt.Errorf("organization lookup: %w", err)
+	}
+
+	if err := s.createOrganization(ctx, data); err != nil {
+		return fmt.Errorf("organization: %w", err)
+	}
+	if err := s.createRoles(ctx, data.Roles); err != nil {
+		return fmt.Errorf("roles: %w", err)
+	}
+	for _, u := range data.Users {
+		if err := s.createOrgUser(ctx, u); err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+	}
+	for _, f := range data.Folders {
+		if err := s.createFolder(ctx, f, nil); err != nil {
+			return fmt.Errorf("folder %s: %w", f.Name, err)
+		}
+	}
+	if err := s.createTemplatesAndPersonas(ctx, data); err != nil {
+		return err
+	}
+	return s.createScreeners(ctx, data)
+}
+
+func (s *seeder) logSummary(data *SeedData) {
+	if s.org == nil {
+		return
+	}
+	log.Printf("Seed: created organization %q (%s) with %d roles, %d users, %d documents, %d templates, %d personas, %d screeners",
+		s.org.Slug, s.org.ID, len(s.roles), len(s.users), s.documents, len(data.Templates), len(data.Personas), len(data.Screeners))
+	log.Printf("Seed: log in as %s or %s (passwords from the seed file)", data.SuperAdmin.Email, firstEmail(data.Users))
+}
+
+// ensureUser returns the user with the given email, creating it if missing
+func (s *seeder) ensureUser(ctx context.Context, u seedUser, orgID *uuid.UUID, superAdmin bool) (*models.User, bool, error) {
+	var existingID uuid.UUID
+	err := s.tx.QueryRow(ctx, `SELECT id FROM get_user_by_email_for_auth($1)`, u.Email).Scan(&existingID)
+	if err == nil {
+		return &models.User{ID: existingID, Email: u.Email}, false, nil
+	} else if err != pgx.ErrNoRows {
+		return nil, false, err
+	}
+
+	passwordHash, err := utils.HashPassword(u.Password)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user := &models.User{
+		ID:            uuid.New(),
+		OrgID:         orgID,
+		Email:         u.Email,
+		PasswordHash:  passwordHash,
+		FirstName:     optionalString(u.FirstName),
+		LastName:      optionalString(u.LastName),
+		IsSuperAdmin:  superAdmin,
+		OrgRole:       optionalString(u.OrgRole),
+		Status:        "active",
+		EmailVerified: true,
+		Timezone:      "UTC",
+		Locale:        "en-US",
+	}
+	if s.superAdmin != nil {
+		user.InvitedBy = &s.superAdmin.ID
+	}
+	_, err = s.tx.Exec(ctx, `
+		INSERT INTO users (
+			id, org_id, email, password_hash, first_name, last_name,
+			is_super_admin, org_role, status, email_verified, timezone, locale, invited_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`,
+		user.ID, user.OrgID, user.Email, user.PasswordHash, user.FirstName, user.LastName,
+		user.IsSuperAdmin, user.OrgRole, user.Status, user.EmailVerified, user.Timezone, user.Locale, user.InvitedBy,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+func (s *seeder) createOrganization(ctx context.Context, data *SeedData) error {
+	plan := data.Organization.SubscriptionPlan
+	if plan == "" {
+		plan = "free"
+	}
+	s.org = &models.Organization{
+		ID:                 uuid.New(),
+		Name:               data.Organization.Name,
+		Slug:               data.Organization.Slug,
+		SubscriptionPlan:   plan,
+		SubscriptionStatus: "active",
+		MaxUsers:           data.Organization.MaxUsers,
+		MaxStorageGB:       data.Organization.MaxStorageGB,
+		Timezone:           "UTC",
+		DateFormat:         "YYYY-MM-DD",
+		Locale:             "en-US",
+		Status:             "active",
+		CreatedBy:          &s.superAdmin.ID,
+		Settings:           map[string]interface{}{"seeded": true},
+		Metadata:           make(map[string]interface{}),
+	}
+	settingsJSON, _ := json.Marshal(s.org.Settings)
+	_, err := s.tx.Exec(ctx, `
+		INSERT INTO organizations (
+			id, name, slug, subscription_plan, subscription_status, max_users, max_storage_gb,
+			timezone, date_format, locale, settings, status, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`,
+		s.org.ID, s.org.Name, s.org.Slug, s.org.SubscriptionPlan, s.org.SubscriptionStatus, s.org.MaxUsers, s.org.MaxStorageGB,
+		s.org.Timezone, s.org.DateFormat, s.org.Locale, settingsJSON, s.org.Status, s.org.CreatedBy,
+	)
+	return err
+}
+
+func (s *seeder) createRoles(ctx context.Context, roles []seedRole) error {
+	rows, err := s.tx.Query(ctx, `SELECT id, resource, action FROM permissions ORDER BY resource, action`)
+	if err != nil {
+		return err
+	}
+	var permissions []*models.Permission
+	for rows.Next() {
+		p := &models.Permission{}
+		if err := rows.Scan(&p.ID, &p.Resource, &p.Action); err != nil {
+			rows.Close()
+			return err
+		}
+		permissions = append(permissions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	byName := make(map[string]uuid.UUID, len(permissions))
+	for _, p := range permissions {
+		byName[p.Resource+":"+p.Action] = p.ID
+	}
+
+	for _, r := range roles {
+		role := &models.Role{
+			ID:          uuid.New(),
+			OrgID:       &s.org.ID,
+			Name:        r.Name,
+			Type:        "org_defined",
+			Description: optionalString(r.Description),
+			IsDefault:   r.IsDefault,
+			CreatedBy:   &s.superAdmin.ID,
+		}
+		_, err := s.tx.Exec(ctx, `
+			INSERT INTO roles (id, org_id, name, type, description, is_default, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, role.ID, role.OrgID, role.Name, role.Type, role.Description, role.IsDefault, role.CreatedBy)
+		if err != nil {
+			return err
+		}
+
+		var permissionIDs []uuid.UUID
+		for _, name := range r.Permissions {
+			if name == "*" {
+				permissionIDs = permissionIDs[:0]
+				for _, p := range permissions {
+					permissionIDs = append(permissionIDs, p.ID)
+				}
+				break
+			}
+			id, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("role %s: unknown permission %q", r.Name, name)
+			}
+			permissionIDs = append(permissionIDs, id)
+		}
+		for _, permissionID := range permissionIDs {
+			_, err := s.tx.Exec(ctx, `INSERT INTO role_permissions (role_id, permission_id, granted_by) VALUES ($1, $2, $3)`,
+				role.ID, permissionID, s.superAdmin.ID)
+			if err != nil {
+				return err
+			}
+		}
+		s.roles[r.Name] = role.ID
+	}
+	return nil
+}
+
+func (s *seeder) createOrgUser(ctx context.Context, u seedUser) error {
+	user, created, err := s.ensureUser(ctx, u, &s.org.ID, false)
+	if err != nil {
+		return err
+	}
+	if !created {
+		log.Printf("Seed: user %s already exists, not adding to %s", u.Email, s.org.Slug)
+		return nil
+	}
+	for _, roleName := range u.Roles {
+		roleID, ok := s.roles[roleName]
+		if !ok {
+			return fmt.Errorf("unknown role %q", roleName)
+		}
+		_, err := s.tx.Exec(ctx, `INSERT INTO user_roles (user_id, role_id, assigned_by) VALUES ($1, $2, $3)`,
+			user.ID, roleID, s.superAdmin.ID)
+		if err != nil {
+			return err
+		}
+	}
+	s.users[u.Email] = user.ID
+	return nil
+}
+
+func (s *seeder) createFolder(ctx context.Context, f seedFolder, parent *models.Folder) error {
+	folder := &models.Folder{
+		ID:        uuid.New(),
+		OrgID:     s.org.ID,
+		Name:      f.Name,
+		Path:      "/" + f.Name,
+		CreatedBy: &s.superAdmin.ID,
+	}
+	if parent != nil {
+		folder.ParentID = &parent.ID
+		folder.Path = path.Join(parent.Path, f.Name)
+	}
+	_, err := s.tx.Exec(ctx, `
+		INSERT INTO folders (id, org_id, parent_id, name, path, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, folder.ID, folder.OrgID, folder.ParentID, folder.Name, folder.Path, folder.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range f.Documents {
+		if err := s.createDocument(ctx, folder, d); err != nil {
+			return fmt.Errorf("document %s: %w", d.Name, err)
+		}
+	}
+	for _, child := range f.Children {
+		if err := s.createFolder(ctx, child, folder); err != nil {
+			return fmt.Errorf("folder %s: %w", child.Name, err)
+		}
+	}
+	return nil
+}
+
+// createDocument writes the document and its chunks file to disk and records it as processed.
+// With Weaviate the chunks are indexed (and vectorized) like an uploaded document; without it the
+// document is flagged with fake embeddings so listings and downloads still work.
+func (s *seeder) createDocument(ctx context.Context, folder *models.Folder, d seedDocument) error {
+	relativePath := path.Join(s.org.ID.String(), strings.TrimPrefix(folder.Path, "/"), d.Name)
+	diskPath := filepath.Join(s.resourcesPath, relativePath)
+	jsonPath := strings.TrimSuffix(diskPath, filepath.Ext(diskPath)) + "_chunks.json"
+
+	var body strings.Builder
+	for _, chunk := range d.Chunks {
+		fmt.Fprintf(&body, "## %s\n\n%s\n\n", chunk.SectionTitle, chunk.Content)
+	}
+	chunksJSON, _ := json.MarshalIndent(d.Chunks, "", "  ")
+
+	if err := os.MkdirAll(filepath.Dir(diskPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(diskPath, []byte(body.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(jsonPath, chunksJSON, 0644); err != nil {
+		return err
+	}
+
+	embeddings := "fake"
+	if s.weaviateClient != nil {
+		embeddings = "weaviate"
+	}
+	mimeType := "text/markdown"
+	size := int64(body.Len())
+	now := time.Now()
+	doc := &repositories.Document{
+		OrgID:        &s.org.ID,
+		FolderID:     &folder.ID,
+		Name:         d.Name,
+		FilePath:     &relativePath,
+		JsonFilePath: &jsonPath,
+		Status:       repositories.DocumentStatusCompleted,
+		Content: repositories.DocumentContent{
+			MimeType:  &mimeType,
+			SizeBytes: &size,
+			ProcessingData: map[string]interface{}{
+				"seeded":      true,
+				"chunk_count": len(d.Chunks),
+				"embeddings":  embeddings,
+			},
+		},
+		Metadata:    map[string]interface{}{"source": "seed"},
+		CreatedBy:   &s.superAdmin.ID,
+		ProcessedAt: &now,
+	}
+	contentJSON, _ := json.Marshal(doc.Content)
+	metadataJSON, _ := json.Marshal(doc.Metadata)
+	err := s.tx.QueryRow(ctx, `
+		INSERT INTO documents (org_id, folder_id, name, file_path, json_file_path, status, content, metadata, created_by, uploaded_at, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		RETURNING id
+	`, doc.OrgID, doc.FolderID, doc.Name, doc.FilePath, doc.JsonFilePath, doc.Status, contentJSON, metadataJSON, doc.CreatedBy, now,
+	).Scan(&doc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create document: %w", err)
+	}
+
+	if s.weaviateClient != nil {
+		if err := s.weaviateClient.BatchInsertChunks(ctx, d.Chunks, weaviate.DefaultPopulateConfig(), doc.ID); err != nil {
+			return fmt.Errorf("failed to index chunks: %w", err)
+		}
+	}
+	s.documents++
+	return nil
+}
+
+func (s *seeder) createTemplatesAndPersonas(ctx context.Context, data *SeedData) error {
+	for _, t := range data.Templates {
+		template := &models.Template{
+			ID:          uuid.New(),
+			OrgID:       &s.org.ID,
+			Name:        t.Name,
+			Description: optionalString(t.Description),
+			Framework:   optionalString(t.Framework),
+			IsCustom:    t.Framework == "" || t.Framework == "custom",
+			Content:     t.Content,
+			CreatedBy:   &s.superAdmin.ID,
+		}
+		contentJSON, _ := json.Marshal(template.Content)
+		_, err := s.tx.Exec(ctx, `
+			INSERT INTO templates (id, org_id, name, description, framework, is_custom, content, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, template.ID, template.OrgID, template.Name, template.Description, template.Framework, template.IsCustom, contentJSON, template.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		s.templates[t.Name] = template.ID
+	}
+
+	for _, p := range data.Personas {
+		persona := &models.Persona{
+			ID:          uuid.New(),
+			OrgID:       &s.org.ID,
+			Name:        p.Name,
+			Description: optionalString(p.Description),
+			Content:     p.Content,
+			CreatedBy:   &s.superAdmin.ID,
+		}
+		if p.Template != "" {
+			templateID, ok := s.templates[p.Template]
+			if !ok {
+				return fmt.Errorf("persona %s: unknown template %q", p.Name, p.Template)
+			}
+			persona.TemplateID = &templateID
+		}
+		contentJSON, _ := json.Marshal(persona.Content)
+		_, err := s.tx.Exec(ctx, `
+			INSERT INTO personas (id, org_id, template_id, name, description, content, is_custom_template, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, persona.ID, persona.OrgID, persona.TemplateID, persona.Name, persona.Description, contentJSON, persona.IsCustomTemplate, persona.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("persona %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *seeder) createScreeners(ctx context.Context, data *SeedData) error {
+	for _, sc := range data.Screeners {
+		ownerID, ok := s.users[sc.Owner]
+		if !ok {
+			return fmt.Errorf("screener %s: owner %q is not a seeded user", sc.ScreenerName, sc.Owner)
+		}
+		screener := &models.Screener{
+			OrgID:        &s.org.ID,
+			UserID:       ownerID,
+			ScreenerName: sc.ScreenerName,
+			TableName:    optionalString(sc.TableName),
+			Query:        sc.Query,
+			UniverseList: optionalString(sc.UniverseList),
+			Explainer:    optionalString(sc.Explainer),
+			IsActive:     true,
+		}
+		_, err := s.tx.Exec(ctx, `
+			INSERT INTO settings (org_id, user_id, screener_name, "tableName", query, "universeList", explainer, is_active, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		`, screener.OrgID, screener.UserID, screener.ScreenerName, screener.TableName,
+			strings.Join(strings.Fields(strings.ReplaceAll(screener.Query, "\\n", " ")), " "), screener.UniverseList, screener.Explainer, screener.IsActive)
+		if err != nil {
+			return fmt.Errorf("screener %s: %w", sc.ScreenerName, err)
+		}
+	}
+	return nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func firstEmail(users []seedUser) string {
+	if len(users) == 0 {
+		return "-"
+	}
+	return users[0].Email
+}
@@ -0,0 +1,427 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// smokeStep is one line of the `saas-api smoke` report
+type smokeStep struct {
+	Name       string        `json:"name"`
+	Passed     bool          `json:"passed"`
+	Skipped    bool          `json:"skipped,omitempty"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+	Detail     string        `json:"detail,omitempty"`
+}
+
+func (s smokeStep) failed() bool {
+	return !s.Passed && !s.Skipped
+}
+
+// smokeRun talks to a running deployment over its public API, the way the frontend does
+type smokeRun struct {
+	client  *http.Client
+	baseURL string
+	orgID   string
+	token   string
+
+	marker     string // Unique text in the uploaded PDF, searched for later
+	pdf        []byte
+	folderID   string
+	documentID int64
+
+	steps []smokeStep
+}
+
+// runSmoke implements `saas-api smoke [-url ...] [-email ...] [-password ...]`.
+// It logs in, creates a folder, uploads a tiny PDF, waits for it to be processed, searches it,
+// downloads it and deletes everything again, then reports each step with its timing.
+// Any failed step makes it return an error, so the exit status can gate a deployment.
+func runSmoke(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("smoke", flag.ExitOnError)
+	apiURL := flags.String("url", getEnvDefault("SMOKE_API_URL", "http://localhost:8080"), "saas-api base URL (env SMOKE_API_URL)")
+	email := flags.String("email", os.Getenv("SMOKE_EMAIL"), "test account email (env SMOKE_EMAIL)")
+	password := flags.String("password", os.Getenv("SMOKE_PASSWORD"), "test account password (env SMOKE_PASSWORD)")
+	orgID := flags.String("org", os.Getenv("SMOKE_ORG_ID"), "organization to work in; required for super admin accounts (env SMOKE_ORG_ID)")
+	processTimeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the document to be processed")
+	search := flags.Bool("search", true, "search the processed document (disable when Weaviate is not deployed)")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	if *email == "" || *password == "" {
+		return fmt.Errorf("-email and -password (or SMOKE_EMAIL and SMOKE_PASSWORD) are required")
+	}
+
+	marker := "smoke" + uuid.NewString()[:8]
+	r := &smokeRun{
+		client:  &http.Client{Timeout: 60 * time.Second},
+		baseURL: strings.TrimSuffix(*apiURL, "/"),
+		orgID:   *orgID,
+		marker:  marker,
+		pdf:     smokePDF("saas-api smoke test " + marker),
+	}
+
+	r.step("login", func() (string, error) { return r.login(ctx, *email, *password) })
+	r.step("create folder", func() (string, error) { return r.createFolder(ctx) })
+	r.step("upload", func() (string, error) { return r.upload(ctx) })
+	r.step("wait for processing", func() (string, error) { return r.waitProcessed(ctx, *processTimeout) })
+	if *search {
+		r.step("search", func() (string, error) { return r.search(ctx) })
+	} else {
+		r.steps = append(r.steps, smokeStep{Name: "search", Skipped: true, Detail: "disabled with -search=false"})
+	}
+	r.step("download", func() (string, error) { return r.download(ctx) })
+
+	// Clean up even after a failure, so repeated runs do not leave test data behind
+	r.cleanup("delete document", r.documentID != 0, func() (string, error) { return r.deleteDocument(ctx) })
+	r.cleanup("delete folder", r.folderID != "", func() (string, error) { return r.deleteFolder(ctx) })
+
+	failed := r.report(*jsonOutput)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed against %s", failed, len(r.steps), r.baseURL)
+	}
+	return nil
+}
+
+// step runs fn unless an earlier step failed, in which case it is reported as skipped
+func (r *smokeRun) step(name string, fn func() (string, error)) {
+	for _, s := range r.steps {
+		if s.failed() {
+			r.steps = append(r.steps, smokeStep{Name: name, Skipped: true, Detail: "skipped after an earlier failure"})
+			return
+		}
+	}
+	r.run(name, fn)
+}
+
+// cleanup runs fn whenever the resource it removes was created
+func (r *smokeRun) cleanup(name string, created bool, fn func() (string, error)) {
+	if !created {
+		r.steps = append(r.steps, smokeStep{Name: name, Skipped: true, Detail: "nothing to delete"})
+		return
+	}
+	r.run(name, fn)
+}
+
+func (r *smokeRun) run(name string, fn func() (string, error)) {
+	start := time.Now()
+	detail, err := fn()
+	elapsed := time.Since(start)
+	s := smokeStep{Name: name, Passed: err == nil, Duration: elapsed, DurationMS: elapsed.Milliseconds(), Detail: detail}
+	if err != nil {
+		s.Detail = err.Error()
+	}
+	r.steps = append(r.steps, s)
+}
+
+// report prints the steps and returns how many failed
+func (r *smokeRun) report(asJSON bool) int {
+	failed := 0
+	for _, s := range r.steps {
+		if s.failed() {
+			failed++
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]interface{}{
+			"url":    r.baseURL,
+			"passed": failed == 0,
+			"steps":  r.steps,
+		})
+		return failed
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STEP\tRESULT\tTIME\tDETAIL\n")
+	var total time.Duration
+	for _, s := range r.steps {
+		result := "PASS"
+		switch {
+		case s.Skipped:
+			result = "SKIP"
+		case s.failed():
+			result = "FAIL"
+		}
+		total += s.Duration
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, result, s.Duration.Round(time.Millisecond), s.Detail)
+	}
+	w.Flush()
+
+	if failed == 0 {
+		fmt.Printf("Smoke test passed against %s in %s\n", r.baseURL, total.Round(time.Millisecond))
+	} else {
+		fmt.Printf("Smoke test FAILED against %s: %d step(s) failed\n", r.baseURL, failed)
+	}
+	return failed
+}
+
+func (r *smokeRun) login(ctx context.Context, email, password string) (string, error) {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := r.doJSON(ctx, http.MethodPost, "/api/v1/auth/login", map[string]string{"email": email, "password": password}, &resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("login returned no access token")
+	}
+	r.token = resp.AccessToken
+	return email, nil
+}
+
+func (r *smokeRun) createFolder(ctx context.Context) (string, error) {
+	body := map[string]interface{}{"name": r.marker}
+	if r.orgID != "" {
+		body["org_id"] = r.orgID
+	}
+	var folder struct {
+		ID string `json:"id"`
+	}
+	if err := r.doJSON(ctx, http.MethodPost, "/api/v1/folders", body, &folder); err != nil {
+		return "", err
+	}
+	if folder.ID == "" {
+		return "", fmt.Errorf("folder response has no id")
+	}
+	r.folderID = folder.ID
+	return r.marker, nil
+}
+
+func (r *smokeRun) upload(ctx context.Context) (string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", r.marker+".pdf")
+	if err != nil {
+		return "", err
+	}
+	part.Write(r.pdf)
+	mw.WriteField("folder_id", r.folderID)
+	if r.orgID != "" {
+		mw.WriteField("org_id", r.orgID)
+	}
+	mw.Close()
+
+	req, err := r.newRequest(ctx, http.MethodPost, "/api/v1/documents/upload", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var resp struct {
+		File struct {
+			ID int64 `json:"id"`
+		} `json:"file"`
+		Data struct {
+			DocumentID int64 `json:"document_id"`
+		} `json:"data"`
+	}
+	if err := r.send(req, &resp); err != nil {
+		return "", err
+	}
+	r.documentID = resp.File.ID
+	if r.documentID == 0 {
+		r.documentID = resp.Data.DocumentID
+	}
+	if r.documentID == 0 {
+		return "", fmt.Errorf("upload response has no document id")
+	}
+	return fmt.Sprintf("document %d (%d bytes)", r.documentID, len(r.pdf)), nil
+}
+
+func (r *smokeRun) waitProcessed(ctx context.Context, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	status := ""
+	for {
+		var resp struct {
+			Data struct {
+				Status       string  `json:"status"`
+				ErrorMessage *string `json:"error_message"`
+			} `json:"data"`
+		}
+		if err := r.doJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v1/documents/jobs/%d", r.documentID), nil, &resp); err != nil {
+			return "", err
+		}
+		status = resp.Data.Status
+
+		switch status {
+		case "completed":
+			return status, nil
+		case "failed":
+			if resp.Data.ErrorMessage != nil {
+				return "", fmt.Errorf("processing failed: %s", *resp.Data.ErrorMessage)
+			}
+			return "", fmt.Errorf("processing failed")
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("still %q after %s", status, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (r *smokeRun) search(ctx context.Context) (string, error) {
+	query := url.Values{
+		"query":         {r.marker},
+		"collection_id": {fmt.Sprint(r.documentID)},
+		"score":         {"0"},
+	}
+	var resp struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := r.doJSON(ctx, http.MethodGet, "/api/v1/documents/search?"+query.Encode(), nil, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Data) == 0 {
+		return "", fmt.Errorf("no results for %q", r.marker)
+	}
+	return fmt.Sprintf("%d result(s)", len(resp.Data)), nil
+}
+
+func (r *smokeRun) download(ctx context.Context) (string, error) {
+	req, err := r.newRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/documents/%d/download", r.documentID), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, smokeSnippet(data))
+	}
+	if !bytes.Equal(data, r.pdf) {
+		return "", fmt.Errorf("downloaded %d bytes that differ from the %d uploaded", len(data), len(r.pdf))
+	}
+	return fmt.Sprintf("%d bytes match", len(data)), nil
+}
+
+func (r *smokeRun) deleteDocument(ctx context.Context) (string, error) {
+	return "", r.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/documents/%d", r.documentID), nil, nil)
+}
+
+func (r *smokeRun) deleteFolder(ctx context.Context) (string, error) {
+	return "", r.doJSON(ctx, http.MethodDelete, "/api/v1/folders/"+r.folderID, nil, nil)
+}
+
+func (r *smokeRun) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	req.Header.Set("User-Agent", "saas-api-smoke")
+	return req, nil
+}
+
+// doJSON sends body as JSON (when not nil) and decodes a 2xx response into out (when not nil)
+func (r *smokeRun) doJSON(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := r.newRequest(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return r.send(req, out)
+}
+
+func (r *smokeRun) send(req *http.Request, out interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: HTTP %d: %s", req.Method, req.URL.Path, resp.StatusCode, smokeSnippet(data))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
+
+func smokeSnippet(data []byte) string {
+	s := strings.TrimSpace(string(data))
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
+
+// smokePDF builds a one-page PDF showing text, with a valid cross-reference table so every parser accepts it
+func smokePDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 18 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func getEnvDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,113 @@
+package bootstrap
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+// EnvFile is the .env LoadEnv loaded, empty when none was found
+var EnvFile string
+
+// processEnv holds the variables set before LoadEnv ran and fileEnv the ones EnvFile provided;
+// ReloadEnv only touches the latter
+var processEnv, fileEnv map[string]bool
+
+// LoadEnv loads ENV_FILE if set, otherwise the first .env found from the working directory up to
+// saas-api/ (so running from saas-api/ or from cmd/<service>/ both work). Variables already set in
+// the environment take precedence over the file. PROXY_ENV_FILE is still read when ENV_FILE is unset.
+func LoadEnv() {
+	processEnv = make(map[string]bool)
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		processEnv[key] = true
+	}
+
+	path := os.Getenv("ENV_FILE")
+	if path == "" {
+		path = os.Getenv("PROXY_ENV_FILE")
+	}
+	if path != "" {
+		if err := godotenv.Load(path); err != nil {
+			log.Printf("⚠️  Could not load %s: %v", path, err)
+			return
+		}
+		log.Printf("Loaded .env from: %s", path)
+		EnvFile = path
+		rememberFileEnv()
+		return
+	}
+
+	envPaths := []string{
+		".env",       // saas-api/
+		"../.env",    // Parent directory
+		"../../.env", // From cmd/<service>/ to saas-api/.env
+	}
+	for _, path := range envPaths {
+		if err := godotenv.Load(path); err == nil {
+			log.Printf("Loaded .env from: %s", path)
+			EnvFile = path
+			rememberFileEnv()
+			return
+		}
+	}
+	log.Println("No .env file found, using environment variables")
+}
+
+// ReloadEnv reads EnvFile again, so a service reloading its configuration sees the edited file.
+// As with LoadEnv, variables set in the environment take precedence; variables removed from the file
+// are unset.
+func ReloadEnv() error {
+	if EnvFile == "" {
+		return nil
+	}
+	values, err := godotenv.Read(EnvFile)
+	if err != nil {
+		return err
+	}
+	for key := range fileEnv {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+	fileEnv = make(map[string]bool, len(values))
+	for key, value := range values {
+		if processEnv[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		fileEnv[key] = true
+	}
+	return nil
+}
+
+// rememberFileEnv records which variables EnvFile provided
+func rememberFileEnv() {
+	values, err := godotenv.Read(EnvFile)
+	if err != nil {
+		return
+	}
+	fileEnv = make(map[string]bool, len(values))
+	for key := range values {
+		if !processEnv[key] {
+			fileEnv[key] = true
+		}
+	}
+}
+
+// Logging prefixes every log line with the service name, so the services can share one log stream
+func Logging(service string) {
+	log.SetPrefix("[" + service + "] ")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+}
+
+// SignalContext returns a context that is cancelled on SIGINT or SIGTERM, which starts a graceful
+// shutdown
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
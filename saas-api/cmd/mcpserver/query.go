@@ -1,17 +1,18 @@
-package main
+package mcpserver
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"saas-api/cmd/configs"
 	"saas-api/pkg/weaviate"
 
-	"github.com/joho/godotenv"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -69,20 +70,6 @@ func parseCollectionID(raw interface{}) (int, error) {
 	}
 }
 
-// Initialize weaviate client
-func init() {
-	// err := godotenvssm.Load(
-	// 	fmt.Sprintf("ssm:/insti/%s",
-	// 		os.Getenv("APP_ENV"),
-	err := godotenv.Load("/home/ec2-user/librechat_me/saas-api/.env")
-	if err != nil {
-		// log.Printf(err.Error())
-		panic(err)
-	}
-	config := configs.LoadConfig()
-	weaviateClient = weaviate.NewWeaviateClient(config)
-}
-
 // DocumentSearchTool defines the MCP tool for document search
 var DocumentSearchTool = mcp.Tool{
 	Name: "document_search",
@@ -445,13 +432,9 @@ func NewMCPServer() *MCPServer {
 
 // StartSSE starts the SSE server on the specified address
 func (s *MCPServer) StartSSE(addr string) error {
-	// Create SSE server with configuration
-	s.sseServer = server.NewSSEServer(s.mcpServer,
-		server.WithBaseURL(fmt.Sprintf("http://%s", addr)),
-		server.WithSSEEndpoint("/sse"),
-		server.WithMessageEndpoint("/message"),
-		server.WithKeepAliveInterval(30*time.Second),
-	)
+	if s.sseServer == nil {
+		s.newSSEServer(addr)
+	}
 
 	log.Printf("Starting MCP SSE server on %s", addr)
 	log.Printf("SSE endpoint: %s/sse", addr)
@@ -460,6 +443,16 @@ func (s *MCPServer) StartSSE(addr string) error {
 	return s.sseServer.Start(addr)
 }
 
+// newSSEServer creates the SSE server with configuration
+func (s *MCPServer) newSSEServer(addr string) {
+	s.sseServer = server.NewSSEServer(s.mcpServer,
+		server.WithBaseURL(fmt.Sprintf("http://%s", addr)),
+		server.WithSSEEndpoint("/sse"),
+		server.WithMessageEndpoint("/message"),
+		server.WithKeepAliveInterval(30*time.Second),
+	)
+}
+
 // StartStdio starts the server in stdio mode (for CLI tools)
 func (s *MCPServer) StartStdio() error {
 	log.Println("Starting MCP server in stdio mode")
@@ -488,14 +481,28 @@ func QueryBoth(ctx context.Context, query string, collection string, score float
 	return textResults, tableResults, nil
 }
 
-// StartMCPServer starts the MCP SSE server (backward compatibility)
-func StartMCPServer() {
-	srv := NewMCPServer()
-	if err := srv.StartSSE(":8081"); err != nil {
-		log.Fatalf("Failed to start MCP server: %v", err)
+// Serve runs the MCP SSE server (`saas-api serve mcp`) on MCP_ADDR (default :8081) until ctx is
+// cancelled
+func Serve(ctx context.Context) {
+	weaviateClient = weaviate.NewWeaviateClient(configs.LoadConfig())
+
+	addr := os.Getenv("MCP_ADDR")
+	if addr == "" {
+		addr = ":8081"
 	}
-}
+	srv := NewMCPServer()
+	srv.newSSEServer(addr) // before StartSSE runs, so shutdown always has it
+	go func() {
+		if err := srv.StartSSE(addr); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start MCP server: %v", err)
+		}
+	}()
 
-func main() {
-	StartMCPServer()
+	<-ctx.Done()
+	log.Println("Shutting down MCP server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.sseServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("MCP server forced to shutdown: %v", err)
+	}
 }
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the correlation ID to LibreChat and saas-api and back to the client
+const requestIDHeader = "X-Request-ID"
+
+type accessLogKey struct{}
+
+// accessLogEntry collects per-request details that are only known deep inside the handlers
+type accessLogEntry struct {
+	requestID string
+	upstream  string
+}
+
+var accessLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// withAccessLog assigns every request an X-Request-ID (keeping a sane inbound one) and writes one
+// structured log line per request once it completes. Websockets are logged when the session ends.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		// Set on the inbound request so the reverse proxies and the websocket dialer forward it
+		r.Header.Set(requestIDHeader, requestID)
+		w.Header().Set(requestIDHeader, requestID)
+
+		entry := &accessLogEntry{requestID: requestID}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if !cfg.AccessLog.Enabled || r.URL.Path == metricsPath || r.URL.Path == healthzPath {
+			return
+		}
+		accessLogger.Info("access",
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"upstream", entry.upstream,
+			"user", requestUserEmail(r),
+			"remote_addr", clientIP(r),
+			"trace_id", traceID(r.Context()),
+		)
+	})
+}
+
+// traceID returns the request's trace ID, or "" when it is not traced
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// requestIDFromContext returns the request's correlation ID, or "" outside a request
+func requestIDFromContext(ctx context.Context) string {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		return entry.requestID
+	}
+	return ""
+}
+
+// setRequestID copies the correlation ID and the trace context from the request context onto an
+// outgoing saas-api call
+func setRequestID(req *http.Request) {
+	if id := requestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// setAccessLogUpstream records which upstream served the request (the last one wins)
+func setAccessLogUpstream(ctx context.Context, upstream string) {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.upstream = upstream
+	}
+}
+
+// requestUserEmail reads the user from the proxy session cookie, falling back to a proxy bearer token
+func requestUserEmail(r *http.Request) string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if c, err := r.Cookie(cfg.Cookies.Session); err == nil {
+		token = c.Value
+	}
+	if token == "" {
+		return ""
+	}
+	email, err := verifyToken(token)
+	if err != nil {
+		return ""
+	}
+	return email
+}
+
+// loopbackProxies are trusted when PROXY_TRUST_FORWARDED_FOR=true is set without
+// PROXY_TRUSTED_PROXIES: nginx on the same host
+var loopbackProxies = []string{"127.0.0.0/8", "::1"}
+
+// loadClientIPConfig reads PROXY_TRUSTED_PROXIES. X-Forwarded-For is ignored unless it is set (or
+// PROXY_TRUST_FORWARDED_FOR=true, kept for older deployments, trusts a proxy on this host).
+func loadClientIPConfig() ClientIPConfig {
+	entries := splitList(os.Getenv("PROXY_TRUSTED_PROXIES"))
+	if len(entries) == 0 && os.Getenv("PROXY_TRUST_FORWARDED_FOR") == "true" {
+		entries = loopbackProxies
+	}
+	var c ClientIPConfig
+	c.TrustedProxies, c.err = parsePrefixes(entries)
+	return c
+}
+
+// clientIP is the address of the client: the connecting peer, unless it is a trusted proxy. Then
+// X-Forwarded-For is read from the right, skipping trusted proxies, and the first other hop is the
+// client. Hops further left were sent by the client and are not believed.
+func clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if i := strings.LastIndex(remote, ":"); i > 0 {
+		remote = remote[:i]
+	}
+	if !trustedProxy(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		client = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// trustedProxy tells whether ip is in PROXY_TRUSTED_PROXIES
+func trustedProxy(ip string) bool {
+	if len(cfg.ClientIP.TrustedProxies) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(strings.Trim(ip, "[]"))
+	return err == nil && prefixesContain(cfg.ClientIP.TrustedProxies, addr.Unmap().WithZone(""))
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs from an upstream load balancer as long as they are short and header-safe
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
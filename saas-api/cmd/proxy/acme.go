@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager obtains and renews certificates for TLS_ACME_DOMAINS. Certificates and the account
+// key are kept in TLS_ACME_CACHE_DIR, so restarts do not request new ones (and run into rate limits);
+// mount it on a persistent volume and share it between instances serving the same domains.
+func newACMEManager() *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.TLS.ACMEDomains...),
+		Cache:      autocert.DirCache(cfg.TLS.ACMECacheDir),
+		Email:      cfg.TLS.ACMEEmail,
+	}
+	if cfg.TLS.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.TLS.ACMEDirectoryURL}
+	}
+	return m
+}
+
+// acmeTLSConfig serves certificates from m. TLS-ALPN-01 challenges are answered on the HTTPS port
+// itself, so TLS_ACME_HTTP_PORT is only needed where port 443 is not reachable by the CA.
+func acmeTLSConfig(m *autocert.Manager) *tls.Config {
+	tlsConfig := m.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig
+}
+
+// startACMEChallengeServer answers HTTP-01 challenges on TLS_ACME_HTTP_PORT and redirects every
+// other request to HTTPS. Returns nil when the port is disabled.
+func startACMEChallengeServer(m *autocert.Manager) *http.Server {
+	if cfg.TLS.ACMEHTTPPort == "" {
+		return nil
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.TLS.ACMEHTTPPort,
+		Handler:           m.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	}
+	go func() {
+		log.Printf("ACME: serving HTTP-01 challenges and HTTPS redirects on :%s", cfg.TLS.ACMEHTTPPort)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("ACME: challenge server stopped: %v (TLS-ALPN-01 on the HTTPS port still works)", err)
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,352 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Modes for PROXY_ASSET_CACHE
+const (
+	assetCacheOff    = "off"
+	assetCacheMemory = "memory"
+	assetCacheDisk   = "disk"
+)
+
+// immutableCacheControl is sent with hashed assets: their URL changes whenever their content does
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+var (
+	proxyAssetCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_asset_cache_requests_total",
+		Help: "Hashed frontend asset requests, by result (hit or miss).",
+	}, []string{"result"})
+
+	proxyAssetCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "proxy_asset_cache_bytes",
+		Help: "Size of the frontend assets held in the asset cache.",
+	})
+)
+
+// cachedAssetHeaders are the upstream headers kept with a cached asset
+var cachedAssetHeaders = []string{"Content-Type", "Content-Encoding", "ETag", "Last-Modified"}
+
+// cachedAsset is one frontend response. With the disk cache the body lives in the store's
+// directory and is only read when served.
+type cachedAsset struct {
+	Key    string            `json:"key"`
+	Header map[string]string `json:"header"`
+	Size   int64             `json:"size"`
+	body   []byte
+}
+
+// assetCache is an LRU of frontend assets bounded by total size, kept in memory or on disk
+type assetCache struct {
+	mu       sync.Mutex
+	dir      string // empty for the memory cache
+	maxBytes int64
+	size     int64
+	order    *list.List // front is the most recently used *cachedAsset
+	entries  map[string]*list.Element
+}
+
+// assets is built in main from cfg.AssetCache; nil disables the cache
+var assets *assetCache
+
+func newAssetCache(c AssetCacheConfig) (*assetCache, error) {
+	if c.Mode == assetCacheOff {
+		return nil, nil
+	}
+	cache := &assetCache{maxBytes: c.MaxBytes, order: list.New(), entries: make(map[string]*list.Element)}
+	if c.Mode == assetCacheDisk {
+		if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+			return nil, err
+		}
+		cache.dir = c.Dir
+		cache.loadDir()
+	}
+	log.Printf("Asset cache: %s, up to %d MB of hashed assets under %s", c.Mode, c.MaxBytes>>20, strings.Join(c.Paths, ", "))
+	return cache, nil
+}
+
+// loadDir indexes the assets a previous run left on disk, least recently written first
+func (c *assetCache) loadDir() {
+	files, _ := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	type stored struct {
+		asset   *cachedAsset
+		modTime time.Time
+	}
+	var found []stored
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var asset cachedAsset
+		info, statErr := os.Stat(strings.TrimSuffix(file, ".json") + ".body")
+		if json.Unmarshal(data, &asset) != nil || statErr != nil || info.Size() != asset.Size {
+			os.Remove(file)
+			continue
+		}
+		found = append(found, stored{&asset, info.ModTime()})
+	}
+	for i := 1; i < len(found); i++ {
+		for j := i; j > 0 && found[j].modTime.Before(found[j-1].modTime); j-- {
+			found[j], found[j-1] = found[j-1], found[j]
+		}
+	}
+	for _, s := range found {
+		c.add(s.asset)
+	}
+}
+
+func (c *assetCache) filePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *assetCache) get(key string) (*cachedAsset, bool) {
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	asset := el.Value.(*cachedAsset)
+	if c.dir == "" {
+		return asset, true
+	}
+	body, err := os.ReadFile(c.filePath(key) + ".body")
+	if err != nil || int64(len(body)) != asset.Size {
+		c.remove(key)
+		return nil, false
+	}
+	return &cachedAsset{Key: asset.Key, Header: asset.Header, Size: asset.Size, body: body}, true
+}
+
+func (c *assetCache) put(asset *cachedAsset) {
+	if asset.Size > c.maxBytes/4 {
+		return
+	}
+	if c.dir != "" {
+		meta, _ := json.Marshal(asset)
+		file := c.filePath(asset.Key)
+		// Body first, so a crash never leaves metadata pointing at a missing body
+		if err := os.WriteFile(file+".body", asset.body, 0o600); err != nil {
+			log.Printf("Asset cache: could not store %s: %v", asset.Key, err)
+			return
+		}
+		if err := os.WriteFile(file+".json", meta, 0o600); err != nil {
+			log.Printf("Asset cache: could not store %s: %v", asset.Key, err)
+			return
+		}
+		asset = &cachedAsset{Key: asset.Key, Header: asset.Header, Size: asset.Size}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(asset)
+}
+
+// add indexes asset and evicts the least recently used assets beyond maxBytes; c.mu must be held
+// (or the cache not yet shared)
+func (c *assetCache) add(asset *cachedAsset) {
+	if el, ok := c.entries[asset.Key]; ok {
+		c.size -= el.Value.(*cachedAsset).Size
+		c.order.Remove(el)
+	}
+	c.entries[asset.Key] = c.order.PushFront(asset)
+	c.size += asset.Size
+	for c.size > c.maxBytes {
+		oldest := c.order.Back().Value.(*cachedAsset)
+		c.drop(oldest.Key)
+	}
+	proxyAssetCacheBytes.Set(float64(c.size))
+}
+
+func (c *assetCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop(key)
+	proxyAssetCacheBytes.Set(float64(c.size))
+}
+
+// drop forgets key and deletes its files; c.mu must be held
+func (c *assetCache) drop(key string) {
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.size -= el.Value.(*cachedAsset).Size
+	c.order.Remove(el)
+	delete(c.entries, key)
+	if c.dir != "" {
+		file := c.filePath(key)
+		os.Remove(file + ".json")
+		os.Remove(file + ".body")
+	}
+}
+
+// purge empties the cache, e.g. when the rewrite rules applied to the cached bodies change
+func (c *assetCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		c.drop(key)
+	}
+	proxyAssetCacheBytes.Set(0)
+}
+
+// withAssetCache serves the LibreChat frontend's content-hashed build assets (Vite's
+// "assets/index-BvR2Yc9x.js") from the asset cache with a one-year immutable Cache-Control, and
+// fills the cache from next on a miss. Everything else, index.html included, goes straight to next.
+func withAssetCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if assets == nil || !isHashedAsset(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.URL.Path + "|" + assetEncoding(r)
+		if asset, ok := assets.get(key); ok {
+			proxyAssetCacheRequests.WithLabelValues("hit").Inc()
+			serveCachedAsset(w, r, asset)
+			return
+		}
+		proxyAssetCacheRequests.WithLabelValues("miss").Inc()
+
+		rec := &assetRecorder{ResponseWriter: w, limit: assets.maxBytes / 4}
+		next.ServeHTTP(rec, r)
+		if !rec.cacheable || rec.overflow || r.Method != http.MethodGet {
+			return
+		}
+		if length := rec.Header().Get("Content-Length"); length != "" && length != strconv.Itoa(rec.body.Len()) {
+			return
+		}
+		asset := &cachedAsset{Key: key, Header: make(map[string]string), Size: int64(rec.body.Len()), body: rec.body.Bytes()}
+		for _, name := range cachedAssetHeaders {
+			if value := rec.Header().Get(name); value != "" {
+				asset.Header[name] = value
+			}
+		}
+		assets.put(asset)
+	})
+}
+
+// isHashedAsset matches GET and HEAD requests under PROXY_ASSET_CACHE_PATHS for files whose name
+// carries a build hash. Names without one (and requests with a query) may change in place.
+func isHashedAsset(r *http.Request) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.URL.RawQuery != "" {
+		return false
+	}
+	underPrefix := false
+	for _, prefix := range cfg.AssetCache.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			underPrefix = true
+			break
+		}
+	}
+	if !underPrefix {
+		return false
+	}
+
+	name := path.Base(r.URL.Path)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	var hash string
+	if n := len(stem); n > 9 && (stem[n-9] == '-' || stem[n-9] == '.') {
+		// Vite: "[name]-[hash]" with an 8 character hash that may itself contain "-" or "_"
+		hash = stem[n-8:]
+	} else if i := strings.LastIndexAny(stem, "-."); i >= 0 && len(stem)-i-1 >= 8 {
+		hash = stem[i+1:]
+	}
+	// A hash mixes cases and digits; an all-lowercase word like "component" is part of the name
+	return strings.ContainsAny(hash, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// assetEncoding picks the cache variant for the client's Accept-Encoding
+func assetEncoding(r *http.Request) string {
+	accept := strings.ToLower(r.Header.Get("Accept-Encoding"))
+	switch {
+	case strings.Contains(accept, "br"):
+		return "br"
+	case strings.Contains(accept, "gzip"):
+		return "gzip"
+	}
+	return "identity"
+}
+
+func serveCachedAsset(w http.ResponseWriter, r *http.Request, asset *cachedAsset) {
+	for name, value := range asset.Header {
+		if name != "Last-Modified" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("X-Proxy-Cache", "HIT")
+	modTime, _ := http.ParseTime(asset.Header["Last-Modified"])
+	// Handles If-None-Match, If-Modified-Since and HEAD
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(asset.body))
+}
+
+// assetRecorder passes a frontend response through while keeping a copy of its body, up to limit
+type assetRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+	cacheable   bool // a 200 without cookies that the frontend did not mark private
+	limit       int64
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (r *assetRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		cacheControl := strings.ToLower(r.Header().Get("Cache-Control"))
+		r.cacheable = code == http.StatusOK && r.Header().Get("Set-Cookie") == "" &&
+			!strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private")
+		if r.cacheable {
+			r.Header().Set("Cache-Control", immutableCacheControl)
+			r.Header().Set("X-Proxy-Cache", "MISS")
+		}
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *assetRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if int64(r.body.Len()+len(b)) > r.limit {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets the reverse proxy flush through the recorder
+func (r *assetRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
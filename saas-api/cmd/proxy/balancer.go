@@ -0,0 +1,339 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var proxyBackendHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "proxy_backend_healthy",
+	Help: "1 when the LibreChat backend instance is in rotation, 0 when it has been removed.",
+}, []string{"backend"})
+
+var proxyBackendActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "proxy_backend_active_requests",
+	Help: "In-flight requests and open websockets per LibreChat backend instance.",
+}, []string{"backend"})
+
+// Affinity modes for PROXY_BACKEND_AFFINITY
+const (
+	affinityNone   = "none"
+	affinityCookie = "cookie" // the instance picked first is remembered in a cookie
+	affinityUser   = "user"   // the instance follows from a hash of the user (or client IP)
+)
+
+// backendPool spreads LibreChat API traffic over the LIBRE_BACKEND instances. Instances are probed
+// every HealthInterval; UnhealthyThreshold consecutive failures (probes or proxy errors) take an
+// instance out of rotation and HealthyThreshold consecutive successful probes bring it back.
+type backendPool struct {
+	backends       []*backend
+	strategy       string
+	affinity       string
+	affinityCookie string
+	next           atomic.Uint64
+	client         *http.Client
+}
+
+type backend struct {
+	url    *url.URL
+	id     string // stable name for the affinity cookie, so the cookie does not reveal the address
+	active atomic.Int64
+
+	mu        sync.Mutex
+	healthy   bool
+	failures  int // consecutive
+	successes int // consecutive, counted while unhealthy
+}
+
+type backendContextKey struct{}
+
+func newBackendPool(targets []string, c BalancerConfig) (*backendPool, error) {
+	pool := &backendPool{
+		strategy:       c.Strategy,
+		affinity:       c.Affinity,
+		affinityCookie: c.AffinityCookie,
+		client:         &http.Client{Timeout: time.Duration(c.HealthTimeout) * time.Second},
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		pool.backends = append(pool.backends, &backend{url: u, id: backendID(u), healthy: true})
+		proxyBackendHealthy.WithLabelValues(u.Host).Set(1)
+	}
+	return pool, nil
+}
+
+// adopt takes over prev's instances that are still configured, with their health state and active
+// counts, so a reload does not put a failing instance back into rotation
+func (p *backendPool) adopt(prev *backendPool) {
+	for i, b := range p.backends {
+		for _, old := range prev.backends {
+			if old.url.String() == b.url.String() {
+				p.backends[i] = old
+				if !old.isHealthy() {
+					proxyBackendHealthy.WithLabelValues(old.url.Host).Set(0)
+				}
+				break
+			}
+		}
+	}
+}
+
+// forgetRemoved drops the health gauge of instances that are no longer in next. Requests and
+// websockets still on them finish normally.
+func (p *backendPool) forgetRemoved(next *backendPool) {
+	for _, b := range p.backends {
+		kept := false
+		for _, n := range next.backends {
+			if n == b || n.url.Host == b.url.Host {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			proxyBackendHealthy.DeleteLabelValues(b.url.Host)
+		}
+	}
+}
+
+func backendID(u *url.URL) string {
+	h := fnv.New64a()
+	h.Write([]byte(u.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// candidates returns the instances in rotation. When every instance is marked down it falls back to
+// all of them: a wrong health check should degrade to plain balancing, not to a full outage.
+func (p *backendPool) candidates() []*backend {
+	candidates := make([]*backend, 0, len(p.backends))
+	for _, b := range p.backends {
+		if b.isHealthy() {
+			candidates = append(candidates, b)
+		}
+	}
+	if len(candidates) == 0 {
+		return p.backends
+	}
+	return candidates
+}
+
+// pickFor chooses the instance for r. With PROXY_BACKEND_AFFINITY a user stays on one instance while
+// it is in rotation, so their event streams, websockets and later API calls meet the same LibreChat
+// process. w may be nil (websockets), in which case the affinity cookie is read but not set.
+func (p *backendPool) pickFor(w http.ResponseWriter, r *http.Request) *backend {
+	if len(p.backends) == 1 {
+		return p.backends[0]
+	}
+	switch p.affinity {
+	case affinityUser:
+		key := "ip:" + clientIP(r)
+		if email := requestUserEmail(r); email != "" {
+			key = "user:" + strings.ToLower(email)
+		}
+		return p.pickHashed(key)
+	case affinityCookie:
+		if c, err := r.Cookie(p.affinityCookie); err == nil {
+			for _, b := range p.backends {
+				if b.id == c.Value && b.isHealthy() {
+					return b
+				}
+			}
+		}
+		b := p.pick()
+		if w != nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     p.affinityCookie,
+				Value:    b.id,
+				Path:     "/",
+				Secure:   cfg.TLS.SecureCookies,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		return b
+	}
+	return p.pick()
+}
+
+// pickHashed chooses by rendezvous hashing: key goes to the candidate with the highest hash of key
+// and instance. Adding or removing an instance only moves the keys that land on it.
+func (p *backendPool) pickHashed(key string) *backend {
+	var best *backend
+	var bestScore uint64
+	for _, b := range p.candidates() {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte(b.id))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// pick chooses the instance for a new request by the balancing strategy
+func (p *backendPool) pick() *backend {
+	candidates := p.candidates()
+	if p.strategy == "least-connections" {
+		best := candidates[0]
+		for _, b := range candidates[1:] {
+			if b.active.Load() < best.active.Load() {
+				best = b
+			}
+		}
+		return best
+	}
+	return candidates[(p.next.Add(1)-1)%uint64(len(candidates))]
+}
+
+// balanceBackends picks an instance of the current pool per request and tracks it as active until the
+// response is done. The chosen instance travels in the request context to direct and the proxy's
+// ErrorHandler, so a reload mid-request does not move it.
+func balanceBackends(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := current().backends.pickFor(w, r)
+		release := b.acquire()
+		defer release()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), backendContextKey{}, b)))
+	})
+}
+
+// direct is the ReverseProxy Director base: it points req at the instance chosen by balance
+func (p *backendPool) direct(req *http.Request) {
+	b, ok := req.Context().Value(backendContextKey{}).(*backend)
+	if !ok {
+		b = p.pick()
+	}
+	req.URL.Scheme = b.url.Scheme
+	req.URL.Host = b.url.Host
+	if b.url.Path != "" && b.url.Path != "/" {
+		req.URL.Path = strings.TrimSuffix(b.url.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+		req.URL.RawPath = ""
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// Match httputil.NewSingleHostReverseProxy: do not let Go add its default User-Agent
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// errorHandler counts transport errors against the instance and answers 502 (503 while its circuit is
+// open, 413 or 504 when the request broke its route limits)
+func (p *backendPool) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if handleRouteLimit(w, r) || handleCircuitOpen(w, r, err) {
+		return
+	}
+	if b, ok := r.Context().Value(backendContextKey{}).(*backend); ok && !errors.Is(err, context.Canceled) {
+		log.Printf("ERROR: LibreChat backend %s failed for %s %s: %v", b.url.Host, r.Method, r.URL.Path, err)
+		b.recordFailure(current().balancer.UnhealthyThreshold, "proxy error")
+	}
+	http.Error(w, "Bad Gateway", http.StatusBadGateway)
+}
+
+// proxyBackendWebsocket proxies a websocket to an instance, which stays active for the connection lifetime
+func proxyBackendWebsocket(w http.ResponseWriter, r *http.Request, email string) {
+	b := current().backends.pickFor(nil, r)
+	release := b.acquire()
+	defer release()
+	proxyWebsocket(w, r, b.url, email)
+}
+
+// healthCheck probes every instance until ctx is done
+func (p *backendPool) healthCheck(ctx context.Context, c BalancerConfig) {
+	ticker := time.NewTicker(time.Duration(c.HealthInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, b := range p.backends {
+			wg.Add(1)
+			go func(b *backend) {
+				defer wg.Done()
+				if err := p.probe(ctx, b, c.HealthPath); err != nil {
+					b.recordFailure(c.UnhealthyThreshold, err.Error())
+				} else {
+					b.recordSuccess(c.HealthyThreshold)
+				}
+			}(b)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *backendPool) probe(ctx context.Context, b *backend, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url.JoinPath(path).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+func (b *backend) acquire() func() {
+	b.active.Add(1)
+	proxyBackendActive.WithLabelValues(b.url.Host).Inc()
+	return func() {
+		b.active.Add(-1)
+		proxyBackendActive.WithLabelValues(b.url.Host).Dec()
+	}
+}
+
+func (b *backend) isHealthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy
+}
+
+func (b *backend) recordFailure(threshold int, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.successes = 0
+	b.failures++
+	if b.healthy && b.failures >= threshold {
+		b.healthy = false
+		proxyBackendHealthy.WithLabelValues(b.url.Host).Set(0)
+		log.Printf("⚠️  LibreChat backend %s removed from rotation after %d failures (last: %s)", b.url.Host, b.failures, reason)
+	}
+}
+
+func (b *backend) recordSuccess(threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.healthy {
+		return
+	}
+	b.successes++
+	if b.successes >= threshold {
+		b.healthy = true
+		b.successes = 0
+		proxyBackendHealthy.WithLabelValues(b.url.Host).Set(1)
+		log.Printf("✅ LibreChat backend %s back in rotation", b.url.Host)
+	}
+}
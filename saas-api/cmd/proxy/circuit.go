@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var proxyCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "proxy_circuit_state",
+	Help: "Upstream circuit breaker state by upstream and host: 0 closed, 1 open, 2 half-open.",
+}, []string{"upstream", "host"})
+
+var proxyUpstreamRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_upstream_retries_total",
+	Help: "Idempotent upstream requests retried after a transport error or 502/503/504, by upstream.",
+}, []string{"upstream"})
+
+// errCircuitOpen is returned by breakerTransport without contacting the upstream
+type errCircuitOpen struct {
+	upstream   string
+	retryAfter time.Duration
+}
+
+func (e *errCircuitOpen) Error() string {
+	return fmt.Sprintf("%s circuit open, retry in %s", e.upstream, e.retryAfter.Round(time.Second))
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after FailureThreshold consecutive failures and rejects requests for
+// OpenSeconds. It then lets a single trial request through (half-open): success closes it,
+// failure opens it again.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial request is in flight
+	gauge    prometheus.Gauge
+}
+
+// allow reports whether a request may go upstream, or how long until it may
+func (b *circuitBreaker) allow(c CircuitConfig) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	openFor := time.Duration(c.OpenSeconds) * time.Second
+	switch b.state {
+	case circuitOpen:
+		if wait := openFor - time.Since(b.openedAt); wait > 0 {
+			return false, wait
+		}
+		b.setState(circuitHalfOpen)
+		b.trial = true
+		return true, 0
+	case circuitHalfOpen:
+		if b.trial {
+			return false, time.Second
+		}
+		b.trial = true
+		return true, 0
+	}
+	return true, 0
+}
+
+func (b *circuitBreaker) record(success bool, c CircuitConfig) (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return false
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= c.FailureThreshold) {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+		return true
+	}
+	return false
+}
+
+// abandon releases a half-open trial without judging the upstream
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) setState(s circuitState) {
+	b.state = s
+	b.gauge.Set(float64(s))
+}
+
+// breakerTransport guards an upstream with one circuit breaker per host (so one bad LibreChat
+// instance does not trip the others) and retries idempotent requests on transient failures.
+type breakerTransport struct {
+	upstream string
+	base     http.RoundTripper
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newBreakerTransport(upstream string, base http.RoundTripper) http.RoundTripper {
+	if !cfg.Circuit.Enabled {
+		return base
+	}
+	return &breakerTransport{upstream: upstream, base: base, breakers: make(map[string]*circuitBreaker)}
+}
+
+func (t *breakerTransport) breaker(host string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &circuitBreaker{gauge: proxyCircuitState.WithLabelValues(t.upstream, host)}
+		b.gauge.Set(0)
+		t.breakers[host] = b
+	}
+	return b
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := cfg.Circuit
+	breaker := t.breaker(req.URL.Host)
+
+	attempts := 1
+	// Only bodiless idempotent requests can be replayed safely
+	if isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody) {
+		attempts += c.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		ok, wait := breaker.allow(c)
+		if !ok {
+			return nil, &errCircuitOpen{upstream: t.upstream, retryAfter: wait}
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if _, exceeded := exceededRouteLimit(req); exceeded || req.Context().Err() != nil {
+			// The client went away or broke its route limits; that says nothing about the upstream
+			breaker.abandon()
+			return resp, err
+		}
+
+		failed := err != nil || isRetryableStatus(resp.StatusCode)
+		if breaker.record(!failed, c) {
+			log.Printf("⚠️  Circuit opened for %s (%s) after %d consecutive failures, rejecting for %ds",
+				t.upstream, req.URL.Host, c.FailureThreshold, c.OpenSeconds)
+		}
+		if !failed || attempt >= attempts {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		proxyUpstreamRetriesTotal.WithLabelValues(t.upstream).Inc()
+		if err := sleepBackoff(req.Context(), attempt, c.RetryBackoffMS); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// sleepBackoff waits base*2^(attempt-1) with up to 50% jitter, or until ctx is done
+func sleepBackoff(ctx context.Context, attempt, baseMS int) error {
+	d := time.Duration(baseMS) * time.Millisecond * time.Duration(1<<(attempt-1))
+	d += time.Duration(rand.Int64N(int64(d)/2 + 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// handleCircuitOpen answers 503 with Retry-After when err comes from an open circuit.
+// ReverseProxy error handlers call it first and fall back to their own 502 otherwise.
+func handleCircuitOpen(w http.ResponseWriter, r *http.Request, err error) bool {
+	var open *errCircuitOpen
+	if !errors.As(err, &open) {
+		return false
+	}
+	setCORSHeaders(w, r)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(open.retryAfter.Seconds()))))
+	http.Error(w, "Service temporarily unavailable, please retry shortly", http.StatusServiceUnavailable)
+	return true
+}
+
+// upstreamErrorHandler is the ReverseProxy ErrorHandler for upstreams without their own
+func upstreamErrorHandler(upstream string) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if handleRouteLimit(w, r) || handleCircuitOpen(w, r, err) {
+			return
+		}
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: %s proxy error for %s %s: %v", upstream, r.Method, r.URL.Path, err)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
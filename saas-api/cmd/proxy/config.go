@@ -0,0 +1,805 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"saas-api/cmd/bootstrap"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config holds all proxy settings. It is built by Load at startup and read through the package-level
+// cfg; the settings a reload can change are read through current() instead (see reload.go).
+type Config struct {
+	Server          ServerConfig
+	TLS             TLSConfig
+	Upstream        UpstreamConfig
+	Balancer        BalancerConfig
+	Mongo           MongoConfig
+	Cookies         CookieConfig
+	CORS            CORSConfig
+	CSRF            CSRFConfig
+	Metrics         MetricsConfig
+	AccessLog       AccessLogConfig
+	Tracing         TracingConfig
+	ClientIP        ClientIPConfig
+	RateLimit       RateLimitConfig
+	Websocket       WebsocketConfig
+	Stream          StreamConfig
+	Limits          LimitsConfig
+	AssetCache      AssetCacheConfig
+	Healthz         HealthzConfig
+	LoginAudit      LoginAuditConfig
+	IPFilter        IPFilterConfig
+	Maintenance     MaintenanceConfig
+	Circuit         CircuitConfig
+	RefreshLoop     RefreshLoopConfig
+	Revocation      RevocationConfig
+	Rewrite         RewriteConfig
+	SecurityHeaders SecurityHeadersConfig
+	UserContext     UserContextConfig
+	JWKS            JWKSConfig
+	Secrets         SecretsConfig
+}
+
+type ServerConfig struct {
+	Port            string
+	ReadTimeout     int // seconds
+	WriteTimeout    int // seconds
+	IdleTimeout     int // seconds
+	ShutdownTimeout int // seconds
+	DrainTimeout    int // seconds websockets and event streams get to close on shutdown
+}
+
+type TLSConfig struct {
+	Enabled       bool // SERVER_HTTPS: the proxy terminates TLS itself (leave false behind nginx)
+	CertFile      string
+	KeyFile       string
+	SecureCookies bool // USE_HTTPS: set the Secure flag on cookies (defaults to true)
+
+	// ACME (see acme.go): with TLS_ACME_DOMAINS set, certificates come from Let's Encrypt instead of CertFile/KeyFile
+	ACMEDomains      []string
+	ACMECacheDir     string
+	ACMEEmail        string
+	ACMEDirectoryURL string // Empty for Let's Encrypt production
+	ACMEHTTPPort     string // HTTP-01 challenges and the HTTPS redirect; empty leaves only TLS-ALPN-01
+}
+
+// ACMEEnabled reports whether certificates are obtained automatically
+func (t TLSConfig) ACMEEnabled() bool {
+	return t.Enabled && len(t.ACMEDomains) > 0
+}
+
+type UpstreamConfig struct {
+	LibreBackends []string // LIBRE_BACKEND: one or more comma-separated instances
+	LibreFrontend string
+	MainAPIURL    string
+}
+
+// BalancerConfig controls how LibreChat API traffic is spread over LibreBackends (see balancer.go)
+type BalancerConfig struct {
+	Strategy           string // round-robin | least-connections
+	HealthPath         string // probed with GET; 5xx or no answer counts as a failure
+	HealthInterval     int    // seconds
+	HealthTimeout      int    // seconds
+	UnhealthyThreshold int    // consecutive failures before an instance is removed
+	HealthyThreshold   int    // consecutive successful probes before it is readmitted
+	Affinity           string // none | cookie | user: keep a user on one instance
+	AffinityCookie     string // cookie naming the instance with Affinity cookie
+}
+
+type MongoConfig struct {
+	URI         string
+	MaxPoolSize int
+	MinPoolSize int
+}
+
+// CookieConfig names the cookies the proxy sets. The LibreChat names must match what LibreChat reads.
+type CookieConfig struct {
+	Session            string
+	LibreRefresh       string
+	LibreTokenProvider string
+}
+
+// CORSConfig is the cross-origin allowlist applied by withCORS (see cors.go). Same-origin requests
+// are always allowed; anything else must match an entry.
+type CORSConfig struct {
+	AllowedOrigins []string // exact origins, "https://*.example.com" wildcards or "*"
+}
+
+// CSRFConfig protects cookie-authenticated state-changing requests (see csrf.go)
+type CSRFConfig struct {
+	Mode        string   // off | origin | header | double-submit
+	CookieName  string   // double-submit token cookie, readable by scripts
+	HeaderName  string   // header carrying the token (double-submit) or proving a same-origin script (header)
+	ExemptPaths []string // path prefixes that skip the header/token check (the origin check still applies)
+}
+
+type MetricsConfig struct {
+	Enabled bool // Serve Prometheus metrics on /metrics; without METRICS_TOKEN they are refused unless Public
+	Public  bool // Serve /metrics without a token (PROXY_METRICS_PUBLIC=true), e.g. when only scrapers reach the proxy
+}
+
+type AccessLogConfig struct {
+	Enabled bool // One JSON line per request on stdout; X-Request-ID is generated and forwarded either way
+}
+
+// TracingConfig controls OpenTelemetry tracing (see tracing.go). The OTLP endpoint, headers and
+// protocol options come from the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Enabled       bool   // PROXY_TRACING; on by default when an OTLP endpoint is set
+	ServiceName   string // OTEL_SERVICE_NAME
+	SamplePercent int    // share of new traces recorded; inbound traceparent sampling decisions are kept
+}
+
+// RateLimitConfig sets the token buckets used by withRateLimit (see ratelimit.go)
+type RateLimitConfig struct {
+	Enabled        bool
+	LoginPerMinute int // POST /login per client IP
+	LoginBurst     int
+	APIPerMinute   int // /api, /oauth, credentials and document bridge per user (or IP when anonymous)
+	APIBurst       int
+	RedisURL       string // Optional: share buckets across proxy instances; in-memory when empty
+}
+
+// ClientIPConfig decides which X-Forwarded-For hops clientIP believes (see accesslog.go)
+type ClientIPConfig struct {
+	TrustedProxies []netip.Prefix // Peers whose X-Forwarded-For is read; none: the connecting address is the client
+	err            error          // Reported by Validate
+}
+
+// WebsocketConfig controls keepalive and limits for proxied websockets (see websocket.go)
+type WebsocketConfig struct {
+	PingInterval   int   // seconds between pings sent to each peer
+	PongTimeout    int   // seconds without any frame from a peer before it is dropped
+	WriteTimeout   int   // seconds allowed for a single write
+	MaxMessageSize int64 // bytes; larger messages close the connection with 1009
+	MaxPerUser     int   // open websockets per user (or anonymous client IP); 0 means no limit
+}
+
+// StreamConfig controls Server-Sent Events passthrough from LibreChat (see sse.go)
+type StreamConfig struct {
+	MaxDuration int // seconds an event stream may stay open, in place of PROXY_WRITE_TIMEOUT; 0 means no limit
+}
+
+// IPFilterConfig restricts which client addresses may use the proxy (see ipfilter.go)
+type IPFilterConfig struct {
+	Allow []netip.Prefix // empty allows every address not denied
+	Deny  []netip.Prefix
+	File  string // PROXY_IP_FILTER_FILE, JSON {"allow": [...], "deny": [...]}
+	err   error  // Reported by Validate
+}
+
+// MaintenanceConfig controls maintenance mode (see maintenance.go)
+type MaintenanceConfig struct {
+	Enabled    bool           // also switched at runtime by POST /internal/maintenance
+	Allow      []netip.Prefix // admin clients served as usual
+	Message    string         // shown on the maintenance page
+	RetryAfter int            // seconds, sent as Retry-After
+	PageFile   string         // PROXY_MAINTENANCE_PAGE, a complete HTML page replacing the built-in one
+	page       string         // contents of PageFile
+	err        error          // Reported by Validate
+}
+
+// LoginAuditConfig controls recording /login attempts in saas-api's audit log (see login_audit.go)
+type LoginAuditConfig struct {
+	Enabled bool // also requires PROXY_SHARED_SECRET
+}
+
+// HealthzConfig controls the /healthz dependency check (see healthz.go)
+type HealthzConfig struct {
+	Timeout  int      // seconds for all checks together
+	CacheTTL int      // seconds a result is reused; 0 checks on every request
+	Optional []string // dependencies whose failure only degrades the status instead of failing the check
+}
+
+// AssetCacheConfig controls the cache of hashed LibreChat frontend assets (see assetcache.go)
+type AssetCacheConfig struct {
+	Mode     string   // off | memory | disk
+	Dir      string   // where the disk cache keeps assets
+	MaxBytes int64    // total size; one asset may take up to a quarter
+	Paths    []string // path prefixes holding hashed build assets
+}
+
+// LimitsConfig caps request bodies and durations per route (see limits.go)
+type LimitsConfig struct {
+	MaxBodyBytes int64        // PROXY_MAX_BODY_MB; 0 means unlimited
+	Timeout      int          // PROXY_REQUEST_TIMEOUT, seconds; 0 keeps PROXY_READ_TIMEOUT and PROXY_WRITE_TIMEOUT
+	Routes       []RouteLimit // file transfer routes, then PROXY_ROUTE_LIMITS; the longest matching path wins
+	err          error        // Reported by Validate
+}
+
+// RouteLimit overrides the global limits on paths starting with Path (a * matches one segment)
+type RouteLimit struct {
+	Path         string
+	MaxBodyBytes int64
+	Timeout      int
+}
+
+// CircuitConfig controls the circuit breakers and retries wrapped around the upstream proxies (see circuit.go)
+type CircuitConfig struct {
+	Enabled          bool
+	FailureThreshold int // consecutive failures (transport error or 502/503/504) that open a circuit
+	OpenSeconds      int // how long an open circuit answers 503 before letting a trial request through
+	MaxRetries       int // extra attempts for bodiless GET/HEAD/OPTIONS requests
+	RetryBackoffMS   int // first retry delay, doubled per attempt
+	ConnectTimeout   int // seconds to establish an upstream connection
+}
+
+// RefreshLoopConfig controls detection of clients stuck retrying LibreChat's /api/auth/refresh (see refresh_loop.go)
+type RefreshLoopConfig struct {
+	Enabled     bool
+	MaxFailures int    // failed refreshes within Window that trip the client
+	Window      int    // seconds
+	Cooldown    int    // seconds a tripped client is kept away from LibreChat's refresh endpoint
+	ReloginURL  string // where the session expired page sends the user to sign in again
+}
+
+// Revocation stores (PROXY_REVOCATION_STORE)
+const (
+	revocationStoreOff    = "off"
+	revocationStoreMemory = "memory"
+	revocationStoreRedis  = "redis"
+	revocationStoreMongo  = "mongo"
+)
+
+// RevocationConfig selects the denylist for revoked proxy JWTs (see revocation.go)
+type RevocationConfig struct {
+	Store    string // off | memory | redis | mongo
+	RedisURL string // defaults to PROXY_RATE_LIMIT_REDIS_URL
+}
+
+// RewriteConfig holds the literal rewrites applied to LibreChat frontend responses (see rewrite.go)
+type RewriteConfig struct {
+	Rules []RewriteRule // PROXY_REWRITE_RULES; defaults to the frontend's websocket URLs
+	err   error         // Reported by Validate
+}
+
+// RewriteRule replaces Pattern with Replacement in responses whose media type matches ContentTypes
+type RewriteRule struct {
+	ContentTypes []string // "text/html", "text/*" or "*"
+	Pattern      string
+	Replacement  string
+}
+
+// SecurityHeadersConfig controls the security headers added to every response (see securityheaders.go)
+type SecurityHeadersConfig struct {
+	Enabled               bool     // PROXY_SECURITY_HEADERS
+	CSP                   string   // PROXY_CSP; frame-ancestors is added from FrameAncestors unless present
+	CSPReportOnly         bool     // PROXY_CSP_REPORT_ONLY: send CSP as Content-Security-Policy-Report-Only
+	FrameAncestors        []string // PROXY_FRAME_ANCESTORS: sources allowed to iframe the chat
+	ReferrerPolicy        string
+	HSTSMaxAge            int // seconds, HTTPS responses only; 0 disables
+	HSTSIncludeSubdomains bool
+	Overrides             []HeaderOverride // PROXY_SECURITY_HEADER_OVERRIDES
+	err                   error            // Reported by Validate
+}
+
+// HeaderOverride sets Header to Value on paths starting with PathPrefix; an empty Value removes it
+type HeaderOverride struct {
+	PathPrefix string
+	Header     string
+	Value      string
+}
+
+// UserContextConfig controls the X-User-Org / X-User-Role headers sent to LibreChat (see usercontext.go)
+type UserContextConfig struct {
+	Enabled  bool // PROXY_USER_CONTEXT_HEADERS; lookups need PROXY_SHARED_SECRET
+	CacheTTL int  // seconds a user's org and role are reused before asking saas-api again
+}
+
+// JWKSConfig enables RS256 verification of saas-api tokens against its public keys (see jwks.go)
+type JWKSConfig struct {
+	URL             string // PROXY_SAAS_JWKS_URL; empty disables RS256 tokens
+	RefreshInterval int    // seconds between key set refreshes; an unknown kid refreshes sooner
+}
+
+// SecretsConfig is filled by loadSecrets from the configured SecretsProvider (see secrets.go)
+type SecretsConfig struct {
+	JWTSecret             []byte
+	LibreJWTSecret        []byte
+	LibreJWTRefreshSecret []byte
+	ProxySharedSecret     string // Optional: only needed for the document bridge, user context headers and /internal/revoke
+	SaaSJWTSecret         []byte // Optional: saas-api's JWT_SECRET, validates tokens sent to /token/exchange
+	MetricsToken          string // Bearer token required by /metrics (refused without it unless PROXY_METRICS_PUBLIC=true)
+	SigningSecret         []byte // Optional: signs requests to the LibreChat backend (see signing.go)
+}
+
+var cfg *Config
+
+// Load reads .env, environment variables and secrets, applies defaults and validates the result
+func Load() (*Config, error) {
+	loadLibreChatEnv()
+
+	c := &Config{
+		Server: ServerConfig{
+			Port:            getEnv("PROXY_PORT", "7080"),
+			ReadTimeout:     getEnvInt("PROXY_READ_TIMEOUT", 30),
+			WriteTimeout:    getEnvInt("PROXY_WRITE_TIMEOUT", 30),
+			IdleTimeout:     getEnvInt("PROXY_IDLE_TIMEOUT", 120),
+			ShutdownTimeout: getEnvInt("PROXY_SHUTDOWN_TIMEOUT", 10),
+			DrainTimeout:    getEnvCount("PROXY_DRAIN_TIMEOUT", 10),
+		},
+		TLS: TLSConfig{
+			Enabled:       os.Getenv("SERVER_HTTPS") == "true",
+			CertFile:      getEnv("TLS_CERT_FILE", "cert.pem"),
+			KeyFile:       getEnv("TLS_KEY_FILE", "key.pem"),
+			SecureCookies: os.Getenv("USE_HTTPS") != "false", // Secure by default, opt out for local HTTP
+
+			ACMEDomains:      splitList(os.Getenv("TLS_ACME_DOMAINS")),
+			ACMECacheDir:     getEnv("TLS_ACME_CACHE_DIR", "acme-cache"),
+			ACMEEmail:        os.Getenv("TLS_ACME_EMAIL"),
+			ACMEDirectoryURL: os.Getenv("TLS_ACME_DIRECTORY_URL"),
+			ACMEHTTPPort:     getEnvOptional("TLS_ACME_HTTP_PORT", "80"),
+		},
+		Upstream: UpstreamConfig{
+			LibreBackends: splitList(getEnv("LIBRE_BACKEND", "http://localhost:3080")),
+			LibreFrontend: getEnv("LIBRE_FRONTEND", "http://localhost:3090"),
+			MainAPIURL:    strings.TrimSuffix(getEnv("MAIN_API_URL", "http://localhost:8080"), "/"),
+		},
+		Balancer: BalancerConfig{
+			Strategy:           getEnv("PROXY_BACKEND_BALANCE", "round-robin"),
+			HealthPath:         getEnv("PROXY_BACKEND_HEALTH_PATH", "/health"),
+			HealthInterval:     getEnvInt("PROXY_BACKEND_HEALTH_INTERVAL", 10),
+			HealthTimeout:      getEnvInt("PROXY_BACKEND_HEALTH_TIMEOUT", 3),
+			UnhealthyThreshold: getEnvInt("PROXY_BACKEND_UNHEALTHY_THRESHOLD", 3),
+			HealthyThreshold:   getEnvInt("PROXY_BACKEND_HEALTHY_THRESHOLD", 2),
+			Affinity:           getEnv("PROXY_BACKEND_AFFINITY", affinityNone),
+			AffinityCookie:     getEnv("PROXY_BACKEND_AFFINITY_COOKIE", "libre_backend"),
+		},
+		Mongo: MongoConfig{
+			URI:         getEnv("MONGO_URI", "mongodb://localhost:27017/LibreChat"),
+			MaxPoolSize: getEnvInt("MONGO_MAX_POOL_SIZE", 50),
+			MinPoolSize: getEnvInt("MONGO_MIN_POOL_SIZE", 2),
+		},
+		Cookies: CookieConfig{
+			Session:            getEnv("PROXY_COOKIE_NAME", "libre_jwt"),
+			LibreRefresh:       getEnv("LIBRE_REFRESH_COOKIE_NAME", "refreshToken"),
+			LibreTokenProvider: getEnv("LIBRE_TOKEN_PROVIDER_COOKIE_NAME", "token_provider"),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: normalizeOrigins(splitList(os.Getenv("CORS_ALLOWED_ORIGINS"))),
+		},
+		CSRF: CSRFConfig{
+			Mode:        getEnv("PROXY_CSRF_MODE", csrfOrigin),
+			CookieName:  getEnv("PROXY_CSRF_COOKIE_NAME", "csrf_token"),
+			HeaderName:  http.CanonicalHeaderKey(getEnv("PROXY_CSRF_HEADER_NAME", "X-CSRF-Token")),
+			ExemptPaths: splitList(getEnv("PROXY_CSRF_EXEMPT_PATHS", "/login,"+libreRefreshPath)),
+		},
+		Metrics: MetricsConfig{
+			Enabled: os.Getenv("PROXY_METRICS_ENABLED") != "false",
+			Public:  os.Getenv("PROXY_METRICS_PUBLIC") == "true",
+		},
+		AccessLog: AccessLogConfig{
+			Enabled: os.Getenv("PROXY_ACCESS_LOG") != "false",
+		},
+		Tracing: TracingConfig{
+			Enabled:       getEnv("PROXY_TRACING", strconv.FormatBool(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "")) == "true",
+			ServiceName:   getEnv("OTEL_SERVICE_NAME", "librechat-proxy"),
+			SamplePercent: getEnvCount("PROXY_TRACE_SAMPLE_PERCENT", 100),
+		},
+		ClientIP: loadClientIPConfig(),
+		RateLimit: RateLimitConfig{
+			Enabled:        os.Getenv("PROXY_RATE_LIMIT_ENABLED") != "false",
+			LoginPerMinute: getEnvInt("PROXY_RATE_LIMIT_LOGIN_PER_MINUTE", 10),
+			LoginBurst:     getEnvInt("PROXY_RATE_LIMIT_LOGIN_BURST", 5),
+			APIPerMinute:   getEnvInt("PROXY_RATE_LIMIT_API_PER_MINUTE", 600),
+			APIBurst:       getEnvInt("PROXY_RATE_LIMIT_API_BURST", 100),
+			RedisURL:       os.Getenv("PROXY_RATE_LIMIT_REDIS_URL"),
+		},
+		Websocket: WebsocketConfig{
+			PingInterval:   getEnvInt("PROXY_WS_PING_INTERVAL", 30),
+			PongTimeout:    getEnvInt("PROXY_WS_PONG_TIMEOUT", 60),
+			WriteTimeout:   getEnvInt("PROXY_WS_WRITE_TIMEOUT", 10),
+			MaxMessageSize: int64(getEnvInt("PROXY_WS_MAX_MESSAGE_BYTES", 4<<20)),
+			MaxPerUser:     getEnvCount("PROXY_WS_MAX_PER_USER", 20),
+		},
+		Stream: StreamConfig{
+			MaxDuration: getEnvCount("PROXY_SSE_MAX_DURATION", 1800),
+		},
+		AssetCache: AssetCacheConfig{
+			Mode:     getEnv("PROXY_ASSET_CACHE", assetCacheMemory),
+			Dir:      getEnv("PROXY_ASSET_CACHE_DIR", "asset-cache"),
+			MaxBytes: int64(getEnvInt("PROXY_ASSET_CACHE_MB", 64)) << 20,
+			Paths:    splitList(getEnv("PROXY_ASSET_CACHE_PATHS", "/proxy/assets/")),
+		},
+		Healthz: HealthzConfig{
+			Timeout:  getEnvInt("PROXY_HEALTHZ_TIMEOUT", 3),
+			CacheTTL: getEnvCount("PROXY_HEALTHZ_CACHE", 2),
+			Optional: splitList(os.Getenv("PROXY_HEALTHZ_OPTIONAL")),
+		},
+		LoginAudit: LoginAuditConfig{
+			Enabled: os.Getenv("PROXY_LOGIN_AUDIT") != "false",
+		},
+		Circuit: CircuitConfig{
+			Enabled:          os.Getenv("PROXY_CIRCUIT_BREAKER") != "false",
+			FailureThreshold: getEnvInt("PROXY_CIRCUIT_FAILURE_THRESHOLD", 5),
+			OpenSeconds:      getEnvInt("PROXY_CIRCUIT_OPEN_SECONDS", 30),
+			MaxRetries:       getEnvCount("PROXY_UPSTREAM_RETRIES", 2),
+			RetryBackoffMS:   getEnvInt("PROXY_UPSTREAM_RETRY_BACKOFF_MS", 100),
+			ConnectTimeout:   getEnvInt("PROXY_UPSTREAM_CONNECT_TIMEOUT", 5),
+		},
+		RefreshLoop: RefreshLoopConfig{
+			Enabled:     os.Getenv("PROXY_REFRESH_LOOP_DETECTION") != "false",
+			MaxFailures: getEnvInt("PROXY_REFRESH_MAX_FAILURES", 5),
+			Window:      getEnvInt("PROXY_REFRESH_FAILURE_WINDOW", 60),
+			Cooldown:    getEnvInt("PROXY_REFRESH_COOLDOWN", 300),
+			ReloginURL:  getEnv("PROXY_RELOGIN_URL", "/"),
+		},
+		Revocation: RevocationConfig{
+			Store:    getEnv("PROXY_REVOCATION_STORE", defaultRevocationStore()),
+			RedisURL: getEnv("PROXY_REVOCATION_REDIS_URL", os.Getenv("PROXY_RATE_LIMIT_REDIS_URL")),
+		},
+		UserContext: UserContextConfig{
+			Enabled:  os.Getenv("PROXY_USER_CONTEXT_HEADERS") != "false",
+			CacheTTL: getEnvInt("PROXY_USER_CONTEXT_TTL", 60),
+		},
+		JWKS: JWKSConfig{
+			URL:             strings.TrimSpace(os.Getenv("PROXY_SAAS_JWKS_URL")),
+			RefreshInterval: getEnvInt("PROXY_SAAS_JWKS_REFRESH", 300),
+		},
+	}
+	c.Rewrite = loadRewriteConfig(c)
+	c.SecurityHeaders = loadSecurityHeadersConfig(c)
+	c.Limits = loadLimitsConfig()
+	c.IPFilter = loadIPFilterConfig()
+	c.Maintenance = loadMaintenanceConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	secrets, err := loadSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.Secrets = secrets
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that every setting is usable, reporting all problems at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PROXY_PORT %q is not a valid port", c.Server.Port))
+	}
+
+	if len(c.Upstream.LibreBackends) == 0 {
+		problems = append(problems, "LIBRE_BACKEND must list at least one URL")
+	}
+	upstreams := [][2]string{
+		{"LIBRE_FRONTEND", c.Upstream.LibreFrontend},
+		{"MAIN_API_URL", c.Upstream.MainAPIURL},
+	}
+	for _, backend := range c.Upstream.LibreBackends {
+		upstreams = append(upstreams, [2]string{"LIBRE_BACKEND", backend})
+	}
+	for _, setting := range upstreams {
+		name, value := setting[0], setting[1]
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s %q must be an absolute http(s) URL", name, value))
+		}
+	}
+
+	if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+		problems = append(problems, "MONGO_URI must start with mongodb:// or mongodb+srv://")
+	}
+	if c.Mongo.MinPoolSize > c.Mongo.MaxPoolSize {
+		problems = append(problems, "MONGO_MIN_POOL_SIZE must not exceed MONGO_MAX_POOL_SIZE")
+	}
+
+	if c.AssetCache.Mode != assetCacheOff && c.AssetCache.Mode != assetCacheMemory && c.AssetCache.Mode != assetCacheDisk {
+		problems = append(problems, fmt.Sprintf("PROXY_ASSET_CACHE %q must be off, memory or disk", c.AssetCache.Mode))
+	}
+
+	if c.Tracing.SamplePercent > 100 {
+		problems = append(problems, "PROXY_TRACE_SAMPLE_PERCENT must be between 0 and 100")
+	}
+
+	if c.TLS.ACMEEnabled() {
+		for _, domain := range c.TLS.ACMEDomains {
+			if strings.ContainsAny(domain, "/:*") {
+				problems = append(problems, fmt.Sprintf("TLS_ACME_DOMAINS entry %q must be a plain host name (no scheme, port or wildcard)", domain))
+			}
+		}
+		if c.TLS.ACMEDirectoryURL != "" {
+			if u, err := url.Parse(c.TLS.ACMEDirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("TLS_ACME_DIRECTORY_URL %q must be an absolute https URL", c.TLS.ACMEDirectoryURL))
+			}
+		}
+		if c.TLS.ACMEHTTPPort != "" && c.TLS.ACMEHTTPPort == c.Server.Port {
+			problems = append(problems, "TLS_ACME_HTTP_PORT must differ from PROXY_PORT")
+		}
+	} else if c.TLS.Enabled {
+		for _, setting := range [][2]string{{"TLS_CERT_FILE", c.TLS.CertFile}, {"TLS_KEY_FILE", c.TLS.KeyFile}} {
+			name, path := setting[0], setting[1]
+			if _, err := os.Stat(path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q not readable (required with SERVER_HTTPS=true)", name, path))
+			}
+		}
+	} else if len(c.TLS.ACMEDomains) > 0 {
+		problems = append(problems, "TLS_ACME_DOMAINS requires SERVER_HTTPS=true")
+	}
+
+	for _, setting := range [][2]string{
+		{"PROXY_COOKIE_NAME", c.Cookies.Session},
+		{"PROXY_CSRF_COOKIE_NAME", c.CSRF.CookieName},
+		{"LIBRE_REFRESH_COOKIE_NAME", c.Cookies.LibreRefresh},
+		{"LIBRE_TOKEN_PROVIDER_COOKIE_NAME", c.Cookies.LibreTokenProvider},
+	} {
+		name, value := setting[0], setting[1]
+		if value == "" || strings.ContainsAny(value, " ;,=\t") {
+			problems = append(problems, fmt.Sprintf("%s %q is not a valid cookie name", name, value))
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || strings.Contains(u.Host, "*") {
+			problems = append(problems, fmt.Sprintf("CORS_ALLOWED_ORIGINS entry %q must be an origin like https://app.example.com or https://*.example.com", origin))
+		}
+	}
+
+	switch c.CSRF.Mode {
+	case csrfOff, csrfOrigin, csrfHeader, csrfDoubleSubmit:
+	default:
+		problems = append(problems, fmt.Sprintf("PROXY_CSRF_MODE %q must be off, origin, header or double-submit", c.CSRF.Mode))
+	}
+	if c.CSRF.HeaderName == "" || strings.ContainsAny(c.CSRF.HeaderName, " :,;\t") {
+		problems = append(problems, fmt.Sprintf("PROXY_CSRF_HEADER_NAME %q is not a valid header name", c.CSRF.HeaderName))
+	}
+
+	if c.Balancer.Strategy != "round-robin" && c.Balancer.Strategy != "least-connections" {
+		problems = append(problems, fmt.Sprintf("PROXY_BACKEND_BALANCE %q must be round-robin or least-connections", c.Balancer.Strategy))
+	}
+	if c.Balancer.Affinity != affinityNone && c.Balancer.Affinity != affinityCookie && c.Balancer.Affinity != affinityUser {
+		problems = append(problems, fmt.Sprintf("PROXY_BACKEND_AFFINITY %q must be none, cookie or user", c.Balancer.Affinity))
+	}
+	if !strings.HasPrefix(c.Balancer.HealthPath, "/") {
+		problems = append(problems, "PROXY_BACKEND_HEALTH_PATH must start with /")
+	}
+
+	if c.Websocket.PingInterval >= c.Websocket.PongTimeout {
+		problems = append(problems, "PROXY_WS_PING_INTERVAL must be shorter than PROXY_WS_PONG_TIMEOUT")
+	}
+
+	if c.RefreshLoop.Enabled {
+		if u, err := url.Parse(c.RefreshLoop.ReloginURL); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+			problems = append(problems, fmt.Sprintf("PROXY_RELOGIN_URL %q must be a path or an http(s) URL", c.RefreshLoop.ReloginURL))
+		}
+	}
+
+	if c.RateLimit.RedisURL != "" {
+		if _, err := redis.ParseURL(c.RateLimit.RedisURL); err != nil {
+			problems = append(problems, fmt.Sprintf("PROXY_RATE_LIMIT_REDIS_URL is not a valid redis:// URL: %v", err))
+		}
+	}
+
+	switch c.Revocation.Store {
+	case revocationStoreOff, revocationStoreMemory, revocationStoreMongo:
+	case revocationStoreRedis:
+		if _, err := redis.ParseURL(c.Revocation.RedisURL); err != nil {
+			problems = append(problems, fmt.Sprintf("PROXY_REVOCATION_REDIS_URL must be a valid redis:// URL with PROXY_REVOCATION_STORE=redis: %v", err))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("PROXY_REVOCATION_STORE %q must be off, memory, redis or mongo", c.Revocation.Store))
+	}
+
+	if c.Rewrite.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_REWRITE_RULES: %v", c.Rewrite.err))
+	}
+	if c.Limits.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_ROUTE_LIMITS: %v", c.Limits.err))
+	}
+	for _, name := range c.Healthz.Optional {
+		known := false
+		for _, dependency := range healthzDependencies {
+			known = known || strings.EqualFold(name, dependency)
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("PROXY_HEALTHZ_OPTIONAL entry %q must be one of %s", name, strings.Join(healthzDependencies, ", ")))
+		}
+	}
+	if c.ClientIP.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_TRUSTED_PROXIES: %v", c.ClientIP.err))
+	}
+	if c.IPFilter.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_IP_ALLOWLIST, PROXY_IP_DENYLIST or PROXY_IP_FILTER_FILE: %v", c.IPFilter.err))
+	}
+	if c.Maintenance.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_MAINTENANCE_ALLOWLIST or PROXY_MAINTENANCE_PAGE: %v", c.Maintenance.err))
+	}
+	if c.SecurityHeaders.err != nil {
+		problems = append(problems, fmt.Sprintf("PROXY_SECURITY_HEADER_OVERRIDES: %v", c.SecurityHeaders.err))
+	}
+	if c.SecurityHeaders.CSPReportOnly && c.SecurityHeaders.CSP == "" {
+		problems = append(problems, "PROXY_CSP_REPORT_ONLY=true requires PROXY_CSP")
+	}
+
+	if c.JWKS.URL != "" {
+		if u, err := url.Parse(c.JWKS.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("PROXY_SAAS_JWKS_URL %q must be an http(s) URL", c.JWKS.URL))
+		}
+	}
+
+	if len(c.Secrets.JWTSecret) == 0 || len(c.Secrets.LibreJWTSecret) == 0 || len(c.Secrets.LibreJWTRefreshSecret) == 0 {
+		problems = append(problems, "JWT_SECRET, LIBRE_JWT_SECRET and LIBRE_JWT_REFRESH_SECRET are required")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid proxy configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// LogSummary prints the effective (non-secret) configuration at startup
+func (c *Config) LogSummary() {
+	log.Printf("LibreChat backend: %s", strings.Join(c.Upstream.LibreBackends, ", "))
+	if len(c.Upstream.LibreBackends) > 1 {
+		log.Printf("LibreChat backends balanced %s, health check GET %s every %ds", c.Balancer.Strategy, c.Balancer.HealthPath, c.Balancer.HealthInterval)
+		if c.Balancer.Affinity != affinityNone {
+			log.Printf("LibreChat backend affinity: %s", c.Balancer.Affinity)
+		}
+	}
+	log.Printf("LibreChat frontend: %s", c.Upstream.LibreFrontend)
+	log.Printf("MongoDB URI: %s", c.Mongo.URI)
+	log.Printf("Main API URL: %s", c.Upstream.MainAPIURL)
+
+	if c.TLS.SecureCookies {
+		log.Println("✅ Cookies will have Secure=true (set USE_HTTPS=false for local HTTP development)")
+	} else {
+		log.Println("🔧 USE_HTTPS=false - Development mode: Cookies will have Secure=false")
+		log.Println("   This is only suitable for HTTP (localhost) access")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		log.Println("CORS: same-origin only (set CORS_ALLOWED_ORIGINS to allow other origins)")
+	} else {
+		log.Printf("CORS: allowed origins %s", strings.Join(c.CORS.AllowedOrigins, ", "))
+	}
+
+	log.Printf("CSRF: mode %s", c.CSRF.Mode)
+	if c.Tracing.Enabled {
+		log.Printf("Tracing: OTLP export as %s, %d%% of new traces sampled", c.Tracing.ServiceName, c.Tracing.SamplePercent)
+	}
+	if len(c.IPFilter.Allow) > 0 || len(c.IPFilter.Deny) > 0 {
+		log.Printf("IP filter: %d allowed and %d denied ranges", len(c.IPFilter.Allow), len(c.IPFilter.Deny))
+	}
+	if c.Maintenance.Enabled {
+		log.Printf("🚧 Maintenance mode on: %d admin ranges allowed through", len(c.Maintenance.Allow))
+	}
+	log.Printf("Request limits: %d MB bodies, %ds timeout; %d route overrides", c.Limits.MaxBodyBytes>>20, c.Limits.Timeout, len(c.Limits.Routes))
+	if c.LoginAudit.Enabled && c.Secrets.ProxySharedSecret == "" {
+		log.Println("⚠️  Login audit: PROXY_SHARED_SECRET not set, /login attempts will not reach saas-api's audit log")
+	} else if c.LoginAudit.Enabled {
+		log.Printf("Login audit: /login attempts recorded in saas-api's audit log (%s)", loginAuditPath)
+	}
+	if len(c.Healthz.Optional) > 0 {
+		log.Printf("Health check: %s, optional %s", healthzPath, strings.Join(c.Healthz.Optional, ", "))
+	}
+	if c.Websocket.MaxPerUser > 0 {
+		log.Printf("Websockets: up to %d open per user", c.Websocket.MaxPerUser)
+	}
+	if c.Stream.MaxDuration > 0 {
+		log.Printf("Event streams: unbuffered, open for up to %ds", c.Stream.MaxDuration)
+	} else {
+		log.Println("Event streams: unbuffered, no time limit")
+	}
+	if !c.SecurityHeaders.Enabled {
+		log.Println("Security headers: off")
+	} else if c.SecurityHeaders.CSPReportOnly {
+		log.Printf("Security headers: CSP report-only, frame-ancestors %s", strings.Join(c.SecurityHeaders.FrameAncestors, " "))
+	} else {
+		log.Printf("Security headers: frame-ancestors %s", strings.Join(c.SecurityHeaders.FrameAncestors, " "))
+	}
+	if c.UserContext.Enabled && c.Secrets.ProxySharedSecret == "" {
+		log.Println("⚠️  User context headers: PROXY_SHARED_SECRET not set, X-User-Org / X-User-Role will not be sent")
+	} else if c.UserContext.Enabled {
+		log.Printf("User context headers: org and role cached for %ds", c.UserContext.CacheTTL)
+	}
+	switch {
+	case c.JWKS.URL != "":
+		log.Printf("saas-api tokens: RS256 keys from %s (refreshed every %ds)", c.JWKS.URL, c.JWKS.RefreshInterval)
+	case len(c.Secrets.SaaSJWTSecret) > 0:
+		log.Println("saas-api tokens: HS256 with SAAS_JWT_SECRET (set PROXY_SAAS_JWKS_URL to stop sharing the signing secret)")
+	default:
+		log.Printf("saas-api tokens: PROXY_SAAS_JWKS_URL and SAAS_JWT_SECRET not set, %s will return 503", tokenExchangePath)
+	}
+	if len(c.Secrets.SigningSecret) > 0 {
+		log.Printf("LibreChat backend requests signed (%s, %s)", signatureTimestampHeader, signatureHeader)
+	}
+	for _, rule := range c.Rewrite.Rules {
+		log.Printf("Rewrite: %s -> %s in %s", rule.Pattern, rule.Replacement, strings.Join(rule.ContentTypes, ", "))
+	}
+
+	if c.TLS.ACMEEnabled() {
+		log.Printf("🔐 SERVER_HTTPS=true - Server will run with TLS, certificates from ACME for %s (cache %s)", strings.Join(c.TLS.ACMEDomains, ", "), c.TLS.ACMECacheDir)
+	} else if c.TLS.Enabled {
+		log.Printf("🔐 SERVER_HTTPS=true - Server will run with TLS (%s, %s)", c.TLS.CertFile, c.TLS.KeyFile)
+	} else {
+		log.Println("🌐 SERVER_HTTPS not set or false - Server will run on HTTP")
+		log.Println("   (Recommended when behind reverse proxy like nginx)")
+	}
+}
+
+// loadLibreChatEnv falls back to LibreChat's own .env, which provides the JWT secrets when running next
+// to a LibreChat checkout without a saas-api .env. That one is loaded before any service starts (see
+// bootstrap.LoadEnv).
+func loadLibreChatEnv() {
+	if bootstrap.EnvFile != "" || os.Getenv("LIBRE_JWT_SECRET") != "" {
+		return
+	}
+	for _, path := range []string{"../InstiLibreChat/.env", "../../InstiLibreChat/.env"} {
+		if err := godotenv.Load(path); err == nil {
+			log.Printf("✅ Loaded LibreChat .env from %s, copying JWT secrets", path)
+			return
+		}
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvOptional is getEnv for settings that can be turned off by setting them to an empty string
+func getEnvOptional(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return strings.TrimSpace(value)
+	}
+	return defaultValue
+}
+
+// normalizeOrigins lowercases origins and drops trailing slashes so they compare with the Origin header
+func normalizeOrigins(origins []string) []string {
+	for i, origin := range origins {
+		origins[i] = strings.TrimSuffix(strings.ToLower(origin), "/")
+	}
+	return origins
+}
+
+// splitList splits a comma-separated value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvCount is getEnvInt for settings where 0 is meaningful (e.g. no retries)
+func getEnvCount(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, X-Requested-With, X-Request-ID"
+	corsExposeHeaders = "X-Request-ID, Retry-After, X-Relogin-URL"
+)
+
+// originAllowed reports whether a browser at origin may make credentialed requests to the proxy.
+// Same-origin requests are always allowed; cross-origin ones must match CORS_ALLOWED_ORIGINS,
+// where an entry is an exact origin, a wildcard subdomain ("https://*.example.com", which does
+// not match the apex) or "*" for any origin.
+func originAllowed(r *http.Request, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range current().cors.AllowedOrigins {
+		if originMatches(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func originMatches(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	// "https://*.example.com" matches "https://a.example.com" and "https://a.b.example.com"
+	rest, ok := strings.CutPrefix(origin, scheme+"://")
+	return ok && strings.HasSuffix(rest, "."+host) && len(rest) > len(host)+1
+}
+
+// setCORSHeaders sets CORS headers for cross-origin requests from allowed origins. Other origins
+// get no Access-Control-* headers, so the browser blocks them from reading the response.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if !slices.Contains(h.Values("Vary"), "Origin") {
+		h.Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !originAllowed(r, origin) {
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+	h.Set("Access-Control-Allow-Headers", csrfAllowHeaders(corsAllowHeaders))
+	h.Set("Access-Control-Expose-Headers", csrfAllowHeaders(corsExposeHeaders))
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Access-Control-Max-Age", "3600")
+}
+
+// withCORS applies the allowlist to every route. Preflights are answered here (204 when allowed,
+// 403 otherwise) so they never reach LibreChat or saas-api.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w, r)
+
+		origin := r.Header.Get("Origin")
+		if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !originAllowed(r, origin) {
+				log.Printf("CORS: rejected preflight from origin %s for %s", origin, r.URL.Path)
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stripUpstreamCORS is a ReverseProxy ModifyResponse step: upstreams (saas-api allows "*") must not
+// add their own Access-Control-* headers next to the ones withCORS already set.
+func stripUpstreamCORS(resp *http.Response) {
+	for name := range resp.Header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			resp.Header.Del(name)
+		}
+	}
+}
+
+// checkWebsocketOrigin is the websocket upgrader's CheckOrigin
+func checkWebsocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !originAllowed(r, origin) {
+		log.Printf("CORS: rejected websocket from origin %s for %s", origin, r.URL.Path)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// CSRF modes (PROXY_CSRF_MODE). All of them rely on the session cookies being SameSite=Lax and only
+// check unsafe methods that carry one of the proxy's session cookies.
+const (
+	csrfOff          = "off"
+	csrfOrigin       = "origin"        // Origin (or Referer) must be same-origin or in CORS_ALLOWED_ORIGINS
+	csrfHeader       = "header"        // origin check, plus a custom header that forces a CORS preflight
+	csrfDoubleSubmit = "double-submit" // origin check, plus the CSRF cookie echoed in the CSRF header
+)
+
+var proxyCSRFRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_csrf_rejected_total",
+	Help: "State-changing requests refused by CSRF protection, by reason (origin, missing_header, token_mismatch).",
+}, []string{"reason"})
+
+// withCSRF refuses cross-site state-changing requests authenticated by the proxy's cookies. Requests
+// with an Authorization header are not checked: a browser never attaches one on its own.
+func withCSRF(next http.Handler) http.Handler {
+	if cfg.CSRF.Mode == csrfOff {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.CSRF.Mode == csrfDoubleSubmit {
+			ensureCSRFCookie(w, r)
+		}
+		if !csrfChecked(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if origin := requestOrigin(r); origin != "" && !originAllowed(r, origin) {
+			rejectCSRF(w, r, "origin", "cross-site request refused")
+			return
+		}
+		if csrfExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch cfg.CSRF.Mode {
+		case csrfHeader:
+			if r.Header.Get(cfg.CSRF.HeaderName) == "" && r.Header.Get("X-Requested-With") == "" {
+				rejectCSRF(w, r, "missing_header", "missing "+cfg.CSRF.HeaderName+" header")
+				return
+			}
+		case csrfDoubleSubmit:
+			cookie, err := r.Cookie(cfg.CSRF.CookieName)
+			header := r.Header.Get(cfg.CSRF.HeaderName)
+			if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+				rejectCSRF(w, r, "token_mismatch", cfg.CSRF.HeaderName+" header does not match the "+cfg.CSRF.CookieName+" cookie")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfChecked reports whether r is a state-changing, cookie-authenticated request subject to CSRF checks
+func csrfChecked(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, name := range []string{cfg.Cookies.Session, cfg.Cookies.LibreRefresh} {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// csrfExempt reports whether r is on a PROXY_CSRF_EXEMPT_PATHS prefix. Exempt paths skip the
+// header and token checks, not the origin check.
+func csrfExempt(r *http.Request) bool {
+	for _, prefix := range cfg.CSRF.ExemptPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOrigin is the Origin header, or the origin of the Referer when a browser omitted Origin
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	if u, err := url.Parse(r.Referer()); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return ""
+}
+
+// ensureCSRFCookie issues the double-submit token. It is readable by scripts (not HttpOnly) so the
+// frontend can copy it into the CSRF header; a cross-site page cannot read it.
+func ensureCSRFCookie(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(cfg.CSRF.CookieName); err == nil && c.Value != "" {
+		return
+	}
+	setCSRFCookie(w)
+}
+
+// setCSRFCookie sets a fresh double-submit token. Login calls it so every new session starts with its
+// own token; the token is also sent in the CSRF header for cross-origin clients that cannot read the cookie.
+func setCSRFCookie(w http.ResponseWriter) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("CSRF: failed to generate token: %v", err)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cfg.CSRF.CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+		MaxAge:   7 * 24 * 3600,
+		Secure:   cfg.TLS.SecureCookies,
+		HttpOnly: false,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set(cfg.CSRF.HeaderName, token)
+}
+
+func rejectCSRF(w http.ResponseWriter, r *http.Request, reason, message string) {
+	proxyCSRFRejectedTotal.WithLabelValues(reason).Inc()
+	log.Printf("CSRF: refused %s %s from origin %q: %s", r.Method, r.URL.Path, requestOrigin(r), message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   "csrf_failed",
+		"message": message,
+	})
+}
+
+// csrfAllowHeaders adds the CSRF header to the CORS allow/expose lists when it is not a default one
+func csrfAllowHeaders(headers string) string {
+	if cfg.CSRF.Mode == csrfOff || slices.Contains(strings.Split(headers, ", "), cfg.CSRF.HeaderName) {
+		return headers
+	}
+	return headers + ", " + cfg.CSRF.HeaderName
+}
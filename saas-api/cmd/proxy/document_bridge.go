@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document bridge: LibreChat citations link to saas-api documents, but the chat iframe only carries
+// the proxy cookie. /proxy/files/* authenticates that cookie, exchanges it server-side for a
+// short-lived saas-api token and streams the document back, so the saas token never reaches the browser.
+//
+//	/proxy/files/documents/{id}  -> GET {MAIN_API_URL}/api/v1/documents/{id}/download
+//	/proxy/files/static/{path}   -> GET {MAIN_API_URL}/static/{path}
+const documentBridgePrefix = "/proxy/files/"
+
+var documentIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// Headers forwarded from the browser to saas-api (conditional and range requests)
+var bridgeRequestHeaders = []string{"Range", "If-None-Match", "If-Modified-Since", "If-Range"}
+
+// Headers copied from saas-api back to the browser
+var bridgeResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Disposition", "Content-Range",
+	"Accept-Ranges", "ETag", "Last-Modified", "Cache-Control",
+}
+
+// No overall client timeout: large documents are streamed and bounded by the request context instead
+var bridgeClient = &http.Client{
+	Transport: &instrumentedTransport{upstream: "saas-api", base: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}},
+}
+
+type saasToken struct {
+	value     string
+	expiresAt time.Time
+	user      saasUser // Who the token was issued to (see usercontext.go)
+}
+
+// saasTokenCache keeps exchanged tokens per user until shortly before they expire
+type saasTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]saasToken
+}
+
+var saasTokens = &saasTokenCache{tokens: make(map[string]saasToken)}
+
+func (c *saasTokenCache) get(email, proxyToken string) (string, error) {
+	c.mu.Lock()
+	if t, ok := c.tokens[email]; ok && time.Now().Add(30*time.Second).Before(t.expiresAt) {
+		c.mu.Unlock()
+		return t.value, nil
+	}
+	c.mu.Unlock()
+
+	t, err := exchangeProxyToken(proxyToken)
+	if err != nil {
+		return "", err
+	}
+
+	c.put(email, t)
+	return t.value, nil
+}
+
+func (c *saasTokenCache) put(email string, t saasToken) {
+	c.mu.Lock()
+	c.tokens[email] = t
+	c.mu.Unlock()
+}
+
+func (c *saasTokenCache) invalidate(email string) {
+	c.mu.Lock()
+	delete(c.tokens, email)
+	c.mu.Unlock()
+}
+
+// exchangeProxyToken calls saas-api's server-to-server exchange endpoint
+func exchangeProxyToken(proxyToken string) (saasToken, error) {
+	if cfg.Secrets.ProxySharedSecret == "" {
+		return saasToken{}, fmt.Errorf("PROXY_SHARED_SECRET not set")
+	}
+
+	body, _ := json.Marshal(map[string]string{"token": proxyToken})
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/auth/proxy-exchange", cfg.Upstream.MainAPIURL), bytes.NewReader(body))
+	if err != nil {
+		return saasToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Proxy-Secret", cfg.Secrets.ProxySharedSecret)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return saasToken{}, fmt.Errorf("token exchange request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return saasToken{}, fmt.Errorf("token exchange returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		AccessToken string   `json:"access_token"`
+		ExpiresIn   int      `json:"expires_in"`
+		User        saasUser `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return saasToken{}, fmt.Errorf("failed to decode token exchange response: %w", err)
+	}
+	if response.AccessToken == "" {
+		return saasToken{}, fmt.Errorf("token exchange returned no access token")
+	}
+
+	return saasToken{
+		value:     response.AccessToken,
+		expiresAt: time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+		user:      response.User,
+	}, nil
+}
+
+// bridgeUpstreamPath maps a /proxy/files/* path to the saas-api path serving it
+func bridgeUpstreamPath(path string) (string, bool) {
+	rest := strings.TrimPrefix(path, documentBridgePrefix)
+
+	if id, ok := strings.CutPrefix(rest, "documents/"); ok {
+		id = strings.TrimSuffix(id, "/")
+		if !documentIDPattern.MatchString(id) {
+			return "", false
+		}
+		return "/api/v1/documents/" + id + "/download", true
+	}
+
+	if staticPath, ok := strings.CutPrefix(rest, "static/"); ok && staticPath != "" {
+		return "/static/" + staticPath, true
+	}
+
+	return "", false
+}
+
+func documentBridgeHandler(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var proxyToken string
+	if c, err := r.Cookie(cfg.Cookies.Session); err == nil {
+		proxyToken = c.Value
+	}
+	if proxyToken == "" {
+		proxyToken = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	email, err := verifyToken(proxyToken)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	upstreamPath, ok := bridgeUpstreamPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	saasAccessToken, err := saasTokens.get(email, proxyToken)
+	if err != nil {
+		log.Printf("ERROR: document bridge token exchange failed for %s: %v", email, err)
+		http.Error(w, "unable to authorize document access", http.StatusBadGateway)
+		return
+	}
+
+	upstreamURL := cfg.Upstream.MainAPIURL + upstreamPath
+	if r.URL.RawQuery != "" {
+		upstreamURL += "?" + r.URL.RawQuery
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamURL, nil)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+saasAccessToken)
+	setRequestID(req)
+	for _, h := range bridgeRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := bridgeClient.Do(req)
+	if err != nil {
+		log.Printf("ERROR: document bridge request failed for %s: %v", upstreamPath, err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// A revoked or expired token should not be reused for the next request
+	if resp.StatusCode == http.StatusUnauthorized {
+		saasTokens.invalidate(email)
+	}
+
+	for _, h := range bridgeResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if r.Method == "HEAD" {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("document bridge stream interrupted for %s: %v", upstreamPath, err)
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// activeStreams tracks the long-lived connections srv.Shutdown does not end by itself: websocket
+// relays (hijacked, so invisible to the server) and event streams (never idle, so Shutdown would
+// wait for them until its timeout).
+var activeStreams = &streamTracker{streams: make(map[*trackedStream]struct{})}
+
+type streamTracker struct {
+	mu       sync.Mutex
+	streams  map[*trackedStream]struct{}
+	draining bool
+	wg       sync.WaitGroup
+}
+
+type trackedStream struct {
+	stop func()
+}
+
+// add registers a stream; stop asks it to end. The returned done is called once the stream has
+// ended. Streams added while draining are stopped right away.
+func (t *streamTracker) add(stop func()) (done func()) {
+	s := &trackedStream{stop: stop}
+
+	t.mu.Lock()
+	draining := t.draining
+	if !draining {
+		t.streams[s] = struct{}{}
+		t.wg.Add(1)
+	}
+	t.mu.Unlock()
+
+	if draining {
+		go stop()
+		return func() {}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.streams, s)
+			t.mu.Unlock()
+			t.wg.Done()
+		})
+	}
+}
+
+// drain stops every stream and waits up to timeout for them to end. Websocket peers get a
+// 1001 close frame, event streams are cut so EventSource reconnects to another instance.
+func (t *streamTracker) drain(timeout time.Duration) {
+	t.mu.Lock()
+	t.draining = true
+	streams := make([]*trackedStream, 0, len(t.streams))
+	for s := range t.streams {
+		streams = append(streams, s)
+	}
+	t.mu.Unlock()
+
+	if len(streams) == 0 {
+		return
+	}
+	log.Printf("Draining %d websocket and event stream connections (up to %s)", len(streams), timeout)
+	for _, s := range streams {
+		go s.stop()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		log.Println("All streams closed")
+	case <-time.After(timeout):
+		t.mu.Lock()
+		left := len(t.streams)
+		t.mu.Unlock()
+		log.Printf("⚠️  %d streams still open after PROXY_DRAIN_TIMEOUT, closing them with the process", left)
+	}
+}
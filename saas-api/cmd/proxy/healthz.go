@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const healthzPath = "/healthz"
+
+// Dependencies checked by /healthz, as named in its response and PROXY_HEALTHZ_OPTIONAL
+const (
+	depLibreBackend  = "librechat_backend"
+	depLibreFrontend = "librechat_frontend"
+	depMongo         = "mongodb"
+	depMainAPI       = "main_api"
+)
+
+var healthzDependencies = []string{depLibreBackend, depLibreFrontend, depMongo, depMainAPI}
+
+var proxyDependencyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "proxy_dependency_up",
+	Help: "1 when the dependency passed the last /healthz check, 0 when it failed.",
+}, []string{"dependency"})
+
+// dependencyCheck is one dependency's entry in the /healthz response
+type dependencyCheck struct {
+	Status     string                      `json:"status"` // ok or error
+	LatencyMS  int64                       `json:"latency_ms"`
+	Error      string                      `json:"error,omitempty"`
+	InRotation *bool                       `json:"in_rotation,omitempty"` // backend instances only
+	Instances  map[string]*dependencyCheck `json:"instances,omitempty"`
+}
+
+// healthReport is the /healthz response body
+type healthReport struct {
+	Status    string                      `json:"status"` // ok, degraded (an optional dependency failed) or down
+	CheckedAt time.Time                   `json:"checked_at"`
+	Checks    map[string]*dependencyCheck `json:"checks"`
+}
+
+// healthzCache keeps the last report for PROXY_HEALTHZ_CACHE seconds. Load balancers poll every few
+// seconds from several nodes; callers arriving while a check runs wait for it instead of starting
+// their own.
+var healthzCache struct {
+	mu     sync.Mutex
+	report *healthReport
+}
+
+// healthzHandler answers load balancer health checks with the state of every upstream the proxy
+// needs: 200 when all required dependencies answer, 503 otherwise.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	report := currentHealthReport(r.Context())
+	status := http.StatusOK
+	if report.Status == "down" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+func currentHealthReport(ctx context.Context) *healthReport {
+	healthzCache.mu.Lock()
+	defer healthzCache.mu.Unlock()
+
+	ttl := time.Duration(cfg.Healthz.CacheTTL) * time.Second
+	if report := healthzCache.report; report != nil && time.Since(report.CheckedAt) < ttl {
+		return report
+	}
+	// Not bound to the caller, so a load balancer hanging up does not fail the shared result
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(cfg.Healthz.Timeout)*time.Second)
+	defer cancel()
+	healthzCache.report = checkDependencies(ctx)
+	return healthzCache.report
+}
+
+// checkDependencies checks every dependency in parallel
+func checkDependencies(ctx context.Context) *healthReport {
+	checks := map[string]func(context.Context) *dependencyCheck{
+		depLibreBackend:  checkLibreBackends,
+		depLibreFrontend: func(ctx context.Context) *dependencyCheck { return checkHTTP(ctx, cfg.Upstream.LibreFrontend) },
+		depMongo:         checkMongo,
+		depMainAPI:       func(ctx context.Context) *dependencyCheck { return checkHTTP(ctx, cfg.Upstream.MainAPIURL+"/health") },
+	}
+
+	report := &healthReport{Status: "ok", CheckedAt: time.Now(), Checks: make(map[string]*dependencyCheck, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) *dependencyCheck) {
+			defer wg.Done()
+			result := check(ctx)
+			mu.Lock()
+			report.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, name := range healthzDependencies {
+		result := report.Checks[name]
+		if result.Status == "ok" {
+			proxyDependencyUp.WithLabelValues(name).Set(1)
+			continue
+		}
+		proxyDependencyUp.WithLabelValues(name).Set(0)
+		switch {
+		case !cfg.Healthz.isOptional(name):
+			report.Status = "down"
+		case report.Status == "ok":
+			report.Status = "degraded"
+		}
+	}
+	return report
+}
+
+// timed runs check and reports its outcome and duration
+func timed(check func() error) *dependencyCheck {
+	start := time.Now()
+	err := check()
+	result := &dependencyCheck{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status, result.Error = "error", err.Error()
+	}
+	return result
+}
+
+// checkLibreBackends probes every LibreChat backend instance at PROXY_BACKEND_HEALTH_PATH. One
+// answering instance is enough to serve traffic; in_rotation is the balancer's view of it.
+func checkLibreBackends(ctx context.Context) *dependencyCheck {
+	start := time.Now()
+	s := current()
+	result := &dependencyCheck{Status: "error", Instances: make(map[string]*dependencyCheck, len(s.backends.backends))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, b := range s.backends.backends {
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+			instance := timed(func() error { return s.backends.probe(ctx, b, s.balancer.HealthPath) })
+			inRotation := b.isHealthy()
+			instance.InRotation = &inRotation
+			mu.Lock()
+			result.Instances[b.url.Host] = instance
+			mu.Unlock()
+		}(b)
+	}
+	wg.Wait()
+
+	result.LatencyMS = time.Since(start).Milliseconds()
+	failed := 0
+	for _, instance := range result.Instances {
+		if instance.Status == "ok" {
+			result.Status = "ok"
+		} else {
+			failed++
+		}
+	}
+	if result.Status != "ok" {
+		result.Error = "no backend instance answered"
+	} else if failed > 0 {
+		result.Error = fmt.Sprintf("%d of %d instances failed", failed, len(result.Instances))
+	}
+	return result
+}
+
+func checkMongo(ctx context.Context) *dependencyCheck {
+	return timed(func() error {
+		client, err := libreMongo.Client(ctx)
+		if err != nil {
+			return err
+		}
+		// Client only pings every 30 seconds, so ping here to see the current state
+		return client.Ping(ctx, nil)
+	})
+}
+
+// checkHTTP treats any answer below 500 as healthy, as the backend probes do
+func checkHTTP(ctx context.Context, target string) *dependencyCheck {
+	return timed(func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := healthzClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return errors.New(resp.Status)
+		}
+		return nil
+	})
+}
+
+// healthzClient does not follow redirects, so the frontend's redirect to a login page counts as an answer
+var healthzClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+func (c HealthzConfig) isOptional(name string) bool {
+	for _, optional := range c.Optional {
+		if strings.EqualFold(optional, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var proxyIPFilterRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_ip_filter_rejected_total",
+	Help: "Requests refused by the IP filter, by reason (denylist or not_allowlisted).",
+}, []string{"reason"})
+
+// ipFilterFile is the format of PROXY_IP_FILTER_FILE
+type ipFilterFile struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// loadIPFilterConfig reads PROXY_IP_ALLOWLIST and PROXY_IP_DENYLIST, and adds the lists in
+// PROXY_IP_FILTER_FILE to them
+func loadIPFilterConfig() IPFilterConfig {
+	f := IPFilterConfig{File: strings.TrimSpace(os.Getenv("PROXY_IP_FILTER_FILE"))}
+	allow, deny := splitList(os.Getenv("PROXY_IP_ALLOWLIST")), splitList(os.Getenv("PROXY_IP_DENYLIST"))
+
+	if f.File != "" {
+		data, err := os.ReadFile(f.File)
+		if err != nil {
+			f.err = err
+			return f
+		}
+		var lists ipFilterFile
+		if err := json.Unmarshal(data, &lists); err != nil {
+			f.err = fmt.Errorf("%s: %w", f.File, err)
+			return f
+		}
+		allow, deny = append(allow, lists.Allow...), append(deny, lists.Deny...)
+	}
+
+	if f.Allow, f.err = parsePrefixes(allow); f.err != nil {
+		return f
+	}
+	f.Deny, f.err = parsePrefixes(deny)
+	return f
+}
+
+// parsePrefixes reads CIDR ranges; a bare address is a range of one
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr parses clientIP for matching against prefixes
+func clientAddr(r *http.Request) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.Trim(clientIP(r), "[]"))
+	return addr.Unmap().WithZone(""), err
+}
+
+// withIPFilter refuses clients outside PROXY_IP_ALLOWLIST (when it is set) or inside
+// PROXY_IP_DENYLIST with 403, before any routing. The denylist wins over the allowlist. The client
+// is the one clientIP reports: X-Forwarded-For counts only as far as PROXY_TRUSTED_PROXIES vouch
+// for it, so a forged header cannot claim an allowlisted address or escape the denylist.
+func withIPFilter(next http.Handler) http.Handler {
+	if len(cfg.IPFilter.Allow) == 0 && len(cfg.IPFilter.Deny) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, err := clientAddr(r)
+
+		var reason string
+		switch {
+		case err != nil && len(cfg.IPFilter.Allow) > 0:
+			reason = "not_allowlisted"
+		case err != nil:
+		case prefixesContain(cfg.IPFilter.Deny, addr):
+			reason = "denylist"
+		case len(cfg.IPFilter.Allow) > 0 && !prefixesContain(cfg.IPFilter.Allow, addr):
+			reason = "not_allowlisted"
+		}
+		if reason == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		proxyIPFilterRejected.WithLabelValues(reason).Inc()
+		log.Printf("IP filter: refused %s %s from %s (%s)", r.Method, r.URL.Path, clientIP(r), reason)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// An unknown kid (saas-api rotated its keys) refetches the key set, but at most this often, so tokens
+// with made-up kids cannot turn the proxy into a JWKS request amplifier
+const jwksMinRefetch = 30 * time.Second
+
+var proxyJWKSRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_jwks_refreshes_total",
+	Help: "Fetches of saas-api's JWKS, by result (success or failure).",
+}, []string{"result"})
+
+// saasJWKS holds saas-api's public keys; nil when PROXY_SAAS_JWKS_URL is not set
+var saasJWKS *jwksCache
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// jwksCache keeps saas-api's RS256 keys by kid. The set is refreshed every PROXY_SAAS_JWKS_REFRESH
+// seconds and whenever a token names a kid it does not know. When saas-api is unreachable, the
+// keys already fetched keep working.
+type jwksCache struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	fetchMu sync.Mutex // One fetch at a time
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+func newJWKSCache(c JWKSConfig) *jwksCache {
+	return &jwksCache{
+		url:     c.URL,
+		refresh: time.Duration(c.RefreshInterval) * time.Second,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &instrumentedTransport{upstream: "saas-api", base: http.DefaultTransport},
+		},
+		keys: make(map[string]*rsa.PublicKey),
+	}
+}
+
+// key returns the public key for kid. A token without a kid is accepted when the set has one key.
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	key, ok, stale := c.lookup(kid)
+	if ok && !stale {
+		return key, nil
+	}
+
+	if err := c.fetch(!ok); err != nil {
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, ok, _ = c.lookup(kid); !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (c *jwksCache) lookup(kid string) (*rsa.PublicKey, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stale := time.Since(c.fetchedAt) > c.refresh
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true, stale
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok, stale
+}
+
+// fetch replaces the key set. Unless unknownKid is set, a set refreshed meanwhile by another caller
+// is kept; either way fetches are spaced by jwksMinRefetch.
+func (c *jwksCache) fetch(unknownKid bool) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	c.mu.RLock()
+	fresh := time.Since(c.fetchedAt) <= c.refresh
+	recent := time.Since(c.lastAttempt) < jwksMinRefetch
+	lastErr := c.lastErr
+	c.mu.RUnlock()
+	if recent || (fresh && !unknownKid) {
+		return lastErr
+	}
+
+	keys, err := c.download()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastAttempt = time.Now()
+	c.lastErr = err
+	if err != nil {
+		proxyJWKSRefreshes.WithLabelValues("failure").Inc()
+		log.Printf("JWKS: failed to refresh saas-api keys from %s: %v", c.url, err)
+		return err
+	}
+	proxyJWKSRefreshes.WithLabelValues("success").Inc()
+	c.keys = keys
+	c.fetchedAt = c.lastAttempt
+	return nil
+}
+
+func (c *jwksCache) download() (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest("GET", c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		key, err := k.rsaPublicKey()
+		if err != nil {
+			log.Printf("JWKS: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RS256 signing keys in the key set")
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported key size or exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// saasKeyfunc resolves the verification key of a saas-api token: RS256 from its JWKS, HS256 from
+// SAAS_JWT_SECRET
+func saasKeyfunc(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if saasJWKS == nil {
+			return nil, fmt.Errorf("RS256 token, but PROXY_SAAS_JWKS_URL is not set")
+		}
+		kid, _ := t.Header["kid"].(string)
+		return saasJWKS.key(kid)
+	case *jwt.SigningMethodHMAC:
+		if len(cfg.Secrets.SaaSJWTSecret) == 0 {
+			return nil, fmt.Errorf("HS256 token, but SAAS_JWT_SECRET is not set")
+		}
+		return cfg.Secrets.SaaSJWTSecret, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+}
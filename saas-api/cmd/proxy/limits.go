@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// errRouteTimeout is the cancel cause of requests that ran past their route's timeout
+var errRouteTimeout = errors.New("route timeout exceeded")
+
+// errBodyTooLarge is returned by request bodies that grow past their route's limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// Response writes may run this long past the route timeout, so a timed out request still gets its 504
+const timeoutWriteGrace = 5 * time.Second
+
+var proxyRequestLimitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_request_limits_total",
+	Help: "Requests cut off by a route limit, by limit (body_size or timeout).",
+}, []string{"limit"})
+
+// Uploads and downloads get PROXY_MAX_UPLOAD_MB and PROXY_FILE_TRANSFER_TIMEOUT; LibreChat's routes
+// also exist under /proxy/. A * matches one path segment.
+var fileTransferRoutes = []string{
+	"/api/v1/documents/upload",
+	"/api/v1/documents/*/content",
+	"/api/v1/documents/*/download",
+	"/api/v1/folders/import-zip",
+	"/api/files",
+	"/proxy/api/v1/documents/upload",
+	"/proxy/api/v1/documents/*/content",
+	"/proxy/api/v1/documents/*/download",
+	"/proxy/api/v1/folders/import-zip",
+	"/proxy/api/files",
+	documentBridgePrefix,
+	"/static/",
+}
+
+// loadLimitsConfig reads PROXY_MAX_BODY_MB, PROXY_REQUEST_TIMEOUT, PROXY_MAX_UPLOAD_MB,
+// PROXY_FILE_TRANSFER_TIMEOUT and PROXY_ROUTE_LIMITS. Rules from PROXY_ROUTE_LIMITS come after the
+// file transfer routes, and replace one with the same path.
+func loadLimitsConfig() LimitsConfig {
+	l := LimitsConfig{
+		MaxBodyBytes: int64(getEnvCount("PROXY_MAX_BODY_MB", 10)) << 20,
+		Timeout:      getEnvCount("PROXY_REQUEST_TIMEOUT", 30),
+	}
+
+	maxUpload := int64(getEnvCount("PROXY_MAX_UPLOAD_MB", 512)) << 20
+	transferTimeout := getEnvCount("PROXY_FILE_TRANSFER_TIMEOUT", 600)
+	for _, route := range fileTransferRoutes {
+		l.Routes = append(l.Routes, RouteLimit{Path: route, MaxBodyBytes: maxUpload, Timeout: transferTimeout})
+	}
+
+	rules, err := parseRouteLimits(os.Getenv("PROXY_ROUTE_LIMITS"), l)
+	l.err = err
+	for _, rule := range rules {
+		replaced := false
+		for i := range l.Routes {
+			if l.Routes[i].Path == rule.Path {
+				l.Routes[i], replaced = rule, true
+			}
+		}
+		if !replaced {
+			l.Routes = append(l.Routes, rule)
+		}
+	}
+	return l
+}
+
+// parseRouteLimits reads rules separated by ";" or newlines, each "path|max-body-mb|timeout-seconds".
+// An empty field keeps the global limit; 0 means unlimited.
+func parseRouteLimits(spec string, defaults LimitsConfig) ([]RouteLimit, error) {
+	var rules []RouteLimit
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("route limit %q must be path|max-body-mb|timeout-seconds", line)
+		}
+		rule := RouteLimit{Path: strings.TrimSpace(parts[0]), MaxBodyBytes: defaults.MaxBodyBytes, Timeout: defaults.Timeout}
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("route limit %q: path must start with /", line)
+		}
+		if value := strings.TrimSpace(parts[1]); value != "" {
+			mb, err := strconv.Atoi(value)
+			if err != nil || mb < 0 {
+				return nil, fmt.Errorf("route limit %q: %q is not a size in MB", line, value)
+			}
+			rule.MaxBodyBytes = int64(mb) << 20
+		}
+		if value := strings.TrimSpace(parts[2]); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return nil, fmt.Errorf("route limit %q: %q is not a number of seconds", line, value)
+			}
+			rule.Timeout = seconds
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// routeLimit returns the limits for a path: the longest matching rule, or the global limits
+func routeLimit(path string) RouteLimit {
+	limit := RouteLimit{MaxBodyBytes: cfg.Limits.MaxBodyBytes, Timeout: cfg.Limits.Timeout}
+	matched := -1
+	for _, rule := range cfg.Limits.Routes {
+		if len(rule.Path) > matched && routeMatches(rule.Path, path) {
+			limit, matched = rule, len(rule.Path)
+		}
+	}
+	return limit
+}
+
+// routeMatches reports whether path starts with pattern, where a * in pattern matches one segment
+func routeMatches(pattern, path string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.HasPrefix(path, pattern)
+	}
+	patternParts, pathParts := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(pathParts) < len(patternParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		last := i == len(patternParts)-1
+		switch {
+		case part == "*" && pathParts[i] == "":
+			return false
+		case part == "*":
+		case last && !strings.HasPrefix(pathParts[i], part):
+			return false
+		case !last && part != pathParts[i]:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestLimits caps request bodies and the time a request may take, per route (see routeLimit).
+// A body over its limit gets 413, up front when Content-Length gives it away. A request over its
+// timeout has its context cancelled, which the upstream error handlers answer with 504. The route
+// timeout replaces PROXY_READ_TIMEOUT and PROXY_WRITE_TIMEOUT, so uploads and downloads can run
+// longer than ordinary requests. Websockets are not limited, and event streams stop counting
+// against the timeout once they start (see sse.go).
+func withRequestLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := routeLimit(r.URL.Path)
+
+		if limit.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit.MaxBodyBytes {
+				proxyRequestLimitsTotal.WithLabelValues("body_size").Inc()
+				setCORSHeaders(w, r)
+				w.Header().Set("Connection", "close")
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = &limitedBody{ReadCloser: r.Body, remaining: limit.MaxBodyBytes}
+		}
+
+		lw := &limitWriter{ResponseWriter: w}
+		if limit.Timeout > 0 {
+			timeout := time.Duration(limit.Timeout) * time.Second
+			ctx, cancel := context.WithCancelCause(r.Context())
+			defer cancel(nil)
+			lw.timer = time.AfterFunc(timeout, func() {
+				proxyRequestLimitsTotal.WithLabelValues("timeout").Inc()
+				cancel(errRouteTimeout)
+			})
+			defer lw.timer.Stop()
+			r = r.WithContext(ctx)
+
+			rc := http.NewResponseController(w)
+			deadline := time.Now().Add(timeout)
+			if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Printf("⚠️  Could not set the read deadline for %s: %v", r.URL.Path, err)
+			}
+			if err := rc.SetWriteDeadline(deadline.Add(timeoutWriteGrace)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Printf("⚠️  Could not set the write deadline for %s: %v", r.URL.Path, err)
+			}
+		}
+
+		next.ServeHTTP(lw, r)
+	})
+}
+
+// limitedBody fails reads past the route's body limit with errBodyTooLarge
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  atomic.Bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// One more byte tells a body of exactly the limit from a longer one
+		var probe [1]byte
+		if n, _ := b.ReadCloser.Read(probe[:]); n == 0 {
+			return 0, io.EOF
+		}
+		b.exceeded.Store(true)
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limitWriter stops the route timeout when the response turns out to be an event stream
+type limitWriter struct {
+	http.ResponseWriter
+	timer       *time.Timer
+	wroteHeader bool
+}
+
+func (w *limitWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType == eventStreamType && w.timer != nil {
+			w.timer.Stop()
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *limitWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *limitWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *limitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// exceededRouteLimit reports which route limit, if any, made a proxied request fail. The circuit
+// breakers and health checks do not hold it against the upstream.
+func exceededRouteLimit(r *http.Request) (string, bool) {
+	if body, ok := r.Body.(*limitedBody); ok && body.exceeded.Load() {
+		return "body_size", true
+	}
+	if errors.Is(context.Cause(r.Context()), errRouteTimeout) {
+		return "timeout", true
+	}
+	return "", false
+}
+
+// handleRouteLimit answers 413 or 504 when a proxied request failed on its route's limits.
+// ReverseProxy error handlers call it first, like handleCircuitOpen.
+func handleRouteLimit(w http.ResponseWriter, r *http.Request) bool {
+	limit, ok := exceededRouteLimit(r)
+	if !ok {
+		return false
+	}
+	setCORSHeaders(w, r)
+	if limit == "body_size" {
+		proxyRequestLimitsTotal.WithLabelValues("body_size").Inc()
+		w.Header().Set("Connection", "close")
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return true
+	}
+	http.Error(w, "upstream did not answer in time", http.StatusGatewayTimeout)
+	return true
+}
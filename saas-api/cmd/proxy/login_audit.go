@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const loginAuditPath = "/api/v1/internal/audit/proxy-login"
+
+// Login outcome reasons, as recorded in the audit log
+const (
+	loginReasonInvalidRequest = "invalid_request"
+	loginReasonEmailRequired  = "email_required"
+	loginReasonSessionError   = "session_error"
+	loginReasonRateLimited    = "rate_limited"
+)
+
+// Events waiting for the sender; more are dropped, so a saas-api outage never holds up logins
+const loginAuditQueueSize = 256
+
+var proxyLoginAuditEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_login_audit_events_total",
+	Help: "Login events sent to saas-api's audit log, by result (sent, failed or dropped).",
+}, []string{"result"})
+
+// loginEvent is the body of saas-api's POST /api/v1/internal/audit/proxy-login
+type loginEvent struct {
+	Email     string `json:"email,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Outcome   string `json:"outcome"` // success or failure
+	Reason    string `json:"reason,omitempty"`
+}
+
+var loginAudit struct {
+	once   sync.Once
+	events chan loginEvent
+}
+
+// recordLogin queues a /login attempt for saas-api's audit log. Events are sent one at a time in the
+// background; without PROXY_SHARED_SECRET or with PROXY_LOGIN_AUDIT=false nothing is recorded.
+func recordLogin(r *http.Request, email, outcome, reason string) {
+	if !cfg.LoginAudit.Enabled || cfg.Secrets.ProxySharedSecret == "" {
+		return
+	}
+	loginAudit.once.Do(func() {
+		loginAudit.events = make(chan loginEvent, loginAuditQueueSize)
+		go sendLoginEvents(loginAudit.events)
+	})
+
+	event := loginEvent{
+		Email:     email,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Outcome:   outcome,
+		Reason:    reason,
+	}
+	select {
+	case loginAudit.events <- event:
+	default:
+		proxyLoginAuditEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+func sendLoginEvents(events <-chan loginEvent) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for event := range events {
+		if err := sendLoginEvent(client, event); err != nil {
+			proxyLoginAuditEvents.WithLabelValues("failed").Inc()
+			log.Printf("⚠️  Could not record login event for %q in saas-api: %v", event.Email, err)
+			continue
+		}
+		proxyLoginAuditEvents.WithLabelValues("sent").Inc()
+	}
+}
+
+func sendLoginEvent(client *http.Client, event loginEvent) error {
+	body, _ := json.Marshal(event)
+	req, err := http.NewRequest(http.MethodPost, cfg.Upstream.MainAPIURL+loginAuditPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Proxy-Secret", cfg.Secrets.ProxySharedSecret)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("saas-api returned status %d", resp.StatusCode)
+	}
+	return nil
+}
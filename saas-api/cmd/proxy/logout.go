@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const logoutPath = "/logout"
+
+// logoutHandler signs the browser out of both systems: the proxy JWT is denylisted, the LibreChat
+// session behind the refresh cookie is deleted in Mongo, so neither token can be replayed, and the
+// proxy session and LibreChat cookies are expired. Cookies are cleared even when a store is unreachable.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		setCORSHeaders(w, r)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	setCORSHeaders(w, r)
+
+	if c, err := r.Cookie(cfg.Cookies.Session); err == nil && c.Value != "" {
+		if err := revokeProxyToken(r.Context(), c.Value); err != nil {
+			log.Printf("Logout: failed to revoke proxy session: %v", err)
+		}
+	}
+	if c, err := r.Cookie(cfg.Cookies.LibreRefresh); err == nil && c.Value != "" {
+		deleted, err := deleteLibreChatSession(r.Context(), c.Value)
+		if err != nil {
+			log.Printf("Logout: failed to delete LibreChat session: %v", err)
+		} else {
+			log.Printf("Logout: deleted %d LibreChat session(s)", deleted)
+		}
+	}
+
+	refreshLoops.reset(refreshClientKey(r))
+	clearSessionCookies(w)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// deleteLibreChatSession removes the session a LibreChat refresh token belongs to. Sessions are
+// matched on refreshTokenHash, as LibreChat looks them up, and on the token's sessionId claim when
+// the token still verifies, so a session is found even if its hash was never stored.
+func deleteLibreChatSession(ctx context.Context, refreshToken string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	db, err := libreMongo.Database(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := sha256.Sum256([]byte(refreshToken))
+	filters := bson.A{bson.M{"refreshTokenHash": hex.EncodeToString(hash[:])}}
+	if sessionID, ok := refreshTokenSessionID(refreshToken); ok {
+		filters = append(filters, bson.M{"_id": sessionID})
+	}
+
+	result, err := db.Collection("sessions").DeleteMany(ctx, bson.M{"$or": filters})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete session: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// refreshTokenSessionID returns the sessionId claim of a refresh token signed with LIBRE_JWT_REFRESH_SECRET
+func refreshTokenSessionID(refreshToken string) (primitive.ObjectID, bool) {
+	if len(cfg.Secrets.LibreJWTRefreshSecret) == 0 {
+		return primitive.NilObjectID, false
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(refreshToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return cfg.Secrets.LibreJWTRefreshSecret, nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+
+	sessionID, _ := claims["sessionId"].(string)
+	objectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return objectID, true
+}
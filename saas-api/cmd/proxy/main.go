@@ -1,4 +1,4 @@
-package main
+package proxy
 
 import (
 	"context"
@@ -8,38 +8,23 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/codes"
 )
 
-var jwtSecret []byte
-var libreJWTSecret []byte
-var libreJWTRefreshSecret []byte
-var libreBackend string
-var libreFrontend string
-var cookieName = "libre_jwt"
-var mongoURI string
-var mainAPIURL string
-var useHTTPS bool    // Whether cookies should have Secure flag
-var serverHTTPS bool // Whether server itself runs HTTPS (needs certificates)
-
 // LibreChat User struct for MongoDB
 type LibreChatUser struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
@@ -73,111 +58,6 @@ type APIUser struct {
 	CreatedAt     time.Time `json:"created_at"`
 }
 
-func getLibreBackend() string {
-	if target := os.Getenv("LIBRE_BACKEND"); target != "" {
-		return target
-	}
-	return "http://localhost:3080" // Default LibreChat backend port
-}
-
-func getLibreFrontend() string {
-	if target := os.Getenv("LIBRE_FRONTEND"); target != "" {
-		return target
-	}
-	return "http://localhost:3090" // Default LibreChat frontend port
-}
-
-func getMongoURI() string {
-	if uri := os.Getenv("MONGO_URI"); uri != "" {
-		return uri
-	}
-	return "mongodb://localhost:27017/LibreChat" // Default MongoDB URI
-}
-
-func getMainAPIURL() string {
-	if url := os.Getenv("MAIN_API_URL"); url != "" {
-		return url
-	}
-	return "http://localhost:8080" // Default main API URL
-}
-
-func init() {
-	// Try multiple paths to find .env file
-	envPaths := []string{
-		"../.env",    // From cmd/proxy/ to saas-api/.env
-		"../../.env", // From cmd/proxy/ to root/.env
-		".env",       // Current directory
-		"/Users/aep014/Desktop/custotm_librechat/saas-api/.env", // Absolute path
-	}
-
-	envLoaded := false
-	for _, path := range envPaths {
-		if err := godotenv.Load(path); err == nil {
-			log.Printf("✅ Loaded .env from: %s", path)
-			envLoaded = true
-			break
-		}
-	}
-
-	if !envLoaded {
-		log.Printf("⚠️  No .env file found, trying to read from environment or set defaults")
-		// If .env not found, try to set from known values
-		if os.Getenv("LIBRE_JWT_SECRET") == "" {
-			// Try to read from LibreChat's .env if available
-			if err := godotenv.Load("../../InstiLibreChat/.env"); err == nil {
-				log.Printf("✅ Loaded LibreChat .env, copying JWT secrets")
-			}
-		}
-	}
-
-	// Read environment variables after loading .env
-	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-	libreJWTSecret = []byte(os.Getenv("LIBRE_JWT_SECRET"))
-	libreJWTRefreshSecret = []byte(os.Getenv("LIBRE_JWT_REFRESH_SECRET"))
-
-	// If still not set, try reading from JWT_SECRET and JWT_REFRESH_SECRET (LibreChat's naming)
-	if len(libreJWTSecret) == 0 {
-		libreJWTSecret = []byte(os.Getenv("JWT_SECRET"))
-	}
-	if len(libreJWTRefreshSecret) == 0 {
-		libreJWTRefreshSecret = []byte(os.Getenv("JWT_REFRESH_SECRET"))
-	}
-
-	mongoURI = getMongoURI()
-	libreBackend = getLibreBackend()
-	libreFrontend = getLibreFrontend()
-	mainAPIURL = getMainAPIURL()
-
-	if len(jwtSecret) == 0 {
-		jwtSecret = []byte("mysecret123") // fallback for development
-		log.Println("Warning: Using default JWT secret. Set JWT_SECRET environment variable in production.")
-	}
-
-	// If LibreChat JWT secrets are still not set, use the known values from LibreChat's .env
-	// These MUST match LibreChat's JWT_SECRET and JWT_REFRESH_SECRET
-	if len(libreJWTSecret) == 0 {
-		log.Println("WARNING: LIBRE_JWT_SECRET not found in .env - using hardcoded fallback")
-		libreJWTSecret = []byte("16f8c0ef4a5d391b26034086c628469d3f9f497f08163ab9b40137092f2909ef")
-		log.Println("⚠️  Using hardcoded LIBRE_JWT_SECRET - ensure this matches LibreChat's JWT_SECRET")
-	}
-	if len(libreJWTRefreshSecret) == 0 {
-		log.Println("WARNING: LIBRE_JWT_REFRESH_SECRET not found in .env - using hardcoded fallback")
-		libreJWTRefreshSecret = []byte("eaa5191f2914e30b9387fd84e254e4ba6fc51b4654968a9b0803b456a54b8418")
-		log.Println("⚠️  Using hardcoded LIBRE_JWT_REFRESH_SECRET - ensure this matches LibreChat's JWT_REFRESH_SECRET")
-	}
-
-	log.Printf("LibreChat backend: %s\n", libreBackend)
-	log.Printf("LibreChat frontend: %s\n", libreFrontend)
-	log.Printf("MongoDB URI: %s\n", mongoURI)
-	log.Printf("Main API URL: %s\n", mainAPIURL)
-	if len(libreJWTSecret) > 0 {
-		log.Printf("LIBRE_JWT_SECRET: ✅ Set (length: %d)", len(libreJWTSecret))
-	}
-	if len(libreJWTRefreshSecret) > 0 {
-		log.Printf("LIBRE_JWT_REFRESH_SECRET: ✅ Set (length: %d)", len(libreJWTRefreshSecret))
-	}
-}
-
 type LoginReq struct {
 	Email        string `json:"email"`
 	RefreshToken string `json:"refresh_token,omitempty"` // Optional: refresh token from main API
@@ -235,26 +115,13 @@ func isSecureRequest(r *http.Request) bool {
 
 	// Fallback: If USE_HTTPS is true and we're not on localhost, assume HTTPS
 	// This handles cases where reverse proxy doesn't set proper headers
-	if useHTTPS && !strings.Contains(r.Host, "localhost") && !strings.Contains(r.Host, "127.0.0.1") {
+	if cfg.TLS.SecureCookies && !strings.Contains(r.Host, "localhost") && !strings.Contains(r.Host, "127.0.0.1") {
 		return true
 	}
 
 	return false
 }
 
-// setCORSHeaders sets CORS headers for cross-origin requests
-func setCORSHeaders(w http.ResponseWriter, r *http.Request) {
-	origin := r.Header.Get("Origin")
-	if origin == "" {
-		origin = "*"
-	}
-	w.Header().Set("Access-Control-Allow-Origin", origin)
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Header().Set("Access-Control-Max-Age", "3600")
-}
-
 // generateRandomPassword generates a random password for LibreChat
 func generateRandomPassword(length int) string {
 	bytes := make([]byte, length)
@@ -266,15 +133,16 @@ func generateRandomPassword(length int) string {
 }
 
 // fetchUserFromAPI fetches user data from the main API
-func fetchUserFromAPI(email string, refreshToken string) (*APIUser, error) {
+func fetchUserFromAPI(ctx context.Context, email string, refreshToken string) (*APIUser, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	// Fetch users list from API (with high limit to find the user)
 	// Use refresh token for authentication if provided
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/users?limit=1000", mainAPIURL), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/users?limit=1000", cfg.Upstream.MainAPIURL), nil)
 	if err != nil {
 		return nil, err
 	}
+	setRequestID(req)
 
 	// Add authentication header if refresh token is provided
 	if refreshToken != "" {
@@ -330,70 +198,17 @@ func fetchUserFromAPI(email string, refreshToken string) (*APIUser, error) {
 }
 
 // createOrUpdateLibreChatUser creates or updates a user in LibreChat's MongoDB
-func createOrUpdateLibreChatUser(user *APIUser, refreshToken string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func createOrUpdateLibreChatUser(ctx context.Context, user *APIUser, refreshToken string) (string, error) {
+	// Detached from the request's cancellation, but kept in its trace
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
 	defer cancel()
 
-	log.Printf("Attempting to connect to MongoDB: %s", mongoURI)
-
-	// Connect to MongoDB
-	clientOptions := options.Client().ApplyURI(mongoURI)
-	client, err := mongo.Connect(ctx, clientOptions)
+	// Shared pooled client (see mongo.go)
+	db, err := libreMongo.Database(ctx)
 	if err != nil {
 		log.Printf("MongoDB connection error: %v", err)
-		return "", fmt.Errorf("failed to connect to MongoDB: %w", err)
-	}
-	defer func() {
-		if err := client.Disconnect(ctx); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
-		}
-	}()
-
-	// Ping MongoDB to verify connection
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Printf("MongoDB ping failed: %v", err)
-		return "", fmt.Errorf("failed to ping MongoDB: %w", err)
-	}
-	log.Printf("MongoDB connection successful")
-
-	// Extract database name from URI or use default
-	// MongoDB URI format: mongodb://host:port/database
-	dbName := "LibreChat"
-
-	// Try to parse as URL first
-	parsedURI, err := url.Parse(mongoURI)
-	if err == nil && parsedURI.Path != "" {
-		// Path will be like "/LibreChat" or "/LibreChat?options"
-		path := strings.TrimPrefix(parsedURI.Path, "/")
-		if idx := strings.Index(path, "?"); idx > 0 {
-			path = path[:idx]
-		}
-		if path != "" {
-			dbName = path
-		}
-	} else {
-		// Fallback: extract from string directly
-		// Format: mongodb://host:port/database
-		if strings.Contains(mongoURI, "/") {
-			parts := strings.Split(mongoURI, "/")
-			if len(parts) > 1 {
-				dbPart := parts[len(parts)-1]
-				// Remove query parameters if any
-				if idx := strings.Index(dbPart, "?"); idx > 0 {
-					dbPart = dbPart[:idx]
-				}
-				// Make sure it's not a port number (contains :)
-				if dbPart != "" && !strings.Contains(dbPart, ":") {
-					dbName = dbPart
-				}
-			}
-		}
+		return "", err
 	}
-
-	log.Printf("Using database: %s, collection: users", dbName)
-
-	// Get database and collection
-	db := client.Database(dbName)
 	collection := db.Collection("users")
 
 	// Generate username from email (take part before @)
@@ -548,46 +363,19 @@ func createOrUpdateLibreChatUser(user *APIUser, refreshToken string) (string, er
 }
 
 // createLibreChatSession creates a session in MongoDB for LibreChat authentication
-func createLibreChatSession(userID string) (string, error) {
+func createLibreChatSession(ctx context.Context, userID string) (string, error) {
 	if userID == "" {
 		return "", fmt.Errorf("userID is required")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
 	defer cancel()
 
-	log.Printf("Creating LibreChat session - connecting to MongoDB: %s", mongoURI)
-
-	clientOptions := options.Client().ApplyURI(mongoURI)
-	client, err := mongo.Connect(ctx, clientOptions)
+	db, err := libreMongo.Database(ctx)
 	if err != nil {
 		log.Printf("MongoDB connection error in createLibreChatSession: %v", err)
-		return "", fmt.Errorf("failed to connect to MongoDB: %w", err)
-	}
-	defer func() {
-		if err := client.Disconnect(ctx); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
-		}
-	}()
-
-	// Ping MongoDB to verify connection
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Printf("MongoDB ping failed in createLibreChatSession: %v", err)
-		return "", fmt.Errorf("failed to ping MongoDB: %w", err)
-	}
-	log.Printf("MongoDB connection verified for session creation")
-
-	// Parse database name from URI
-	dbName := "LibreChat"
-	parsedURI, err := url.Parse(mongoURI)
-	if err == nil && parsedURI.Path != "" {
-		dbPart := strings.TrimPrefix(parsedURI.Path, "/")
-		if dbPart != "" && !strings.Contains(dbPart, ":") {
-			dbName = dbPart
-		}
+		return "", err
 	}
-
-	db := client.Database(dbName)
 	sessionsCollection := db.Collection("sessions")
 
 	// Convert userID string to ObjectID
@@ -603,7 +391,7 @@ func createLibreChatSession(userID string) (string, error) {
 	// 4. Store refreshTokenHash (hashed) in session, NOT refreshToken (raw)
 	// 5. Return raw (unhashed) token for cookie
 
-	if len(libreJWTRefreshSecret) == 0 {
+	if len(cfg.Secrets.LibreJWTRefreshSecret) == 0 {
 		return "", fmt.Errorf("LIBRE_JWT_REFRESH_SECRET not set")
 	}
 
@@ -633,7 +421,7 @@ func createLibreChatSession(userID string) (string, error) {
 		"exp":       expirationTime.Unix(),
 		"iat":       time.Now().Unix(),
 	})
-	refreshTokenString, err := refreshToken.SignedString(libreJWTRefreshSecret)
+	refreshTokenString, err := refreshToken.SignedString(cfg.Secrets.LibreJWTRefreshSecret)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -670,26 +458,22 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	setCORSHeaders(w, r)
 
-	// Only allow POST method for login
-	if r.Method != "POST" {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req LoginReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("LoginHandler: Failed to decode request body: %v", err)
+		recordLogin(r, "", "failure", loginReasonInvalidRequest)
 		http.Error(w, "bad request: invalid JSON", http.StatusBadRequest)
 		return
 	}
 	if req.Email == "" {
 		log.Printf("LoginHandler: Email is required but was empty")
+		recordLogin(r, "", "failure", loginReasonEmailRequired)
 		http.Error(w, "email required", http.StatusBadRequest)
 		return
 	}
 
 	// Fetch user data from main API (use refresh token for auth if provided)
-	user, err := fetchUserFromAPI(req.Email, req.RefreshToken)
+	user, err := fetchUserFromAPI(r.Context(), req.Email, req.RefreshToken)
 	if err != nil {
 		log.Printf("Warning: Failed to fetch user from API: %v", err)
 		// Continue with login even if API fetch fails
@@ -700,125 +484,155 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create or update user in LibreChat MongoDB (sync to get user ID)
-	log.Printf("Starting MongoDB user sync for: %s (refresh_token provided: %v)", req.Email, req.RefreshToken != "")
-	mongoUserID, err := createOrUpdateLibreChatUser(user, req.RefreshToken)
+	// Create or update user in LibreChat MongoDB and start its session
+	if _, err := startLibreChatSession(w, r, user, req.RefreshToken); err != nil {
+		log.Printf("WARNING: Continuing without LibreChat session - authentication may fail")
+	}
+
+	if _, err := startProxySession(w, r, req.Email); err != nil {
+		recordLogin(r, req.Email, "failure", loginReasonSessionError)
+		http.Error(w, "token error", http.StatusInternalServerError)
+		return
+	}
+
+	recordLogin(r, req.Email, "success", "")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// startLibreChatSession syncs user to LibreChat's MongoDB, creates a LibreChat session and sets its
+// cookies. It returns the LibreChat access token, which is also sent as X-LibreChat-Token.
+func startLibreChatSession(w http.ResponseWriter, r *http.Request, user *APIUser, refreshToken string) (string, error) {
+	log.Printf("Starting MongoDB user sync for: %s (refresh_token provided: %v)", user.Email, refreshToken != "")
+	mongoUserID, err := createOrUpdateLibreChatUser(r.Context(), user, refreshToken)
 	if err != nil {
 		log.Printf("ERROR creating/updating LibreChat user: %v", err)
-		// Continue anyway - user might still work
-	} else {
-		log.Printf("SUCCESS: LibreChat user sync completed for: %s (MongoDB ID: %s)", req.Email, mongoUserID)
-
-		// Create session and generate LibreChat tokens if we have the user ID
-		if mongoUserID != "" {
-			log.Printf("Creating LibreChat session for MongoDB user ID: %s", mongoUserID)
-			// Create session in MongoDB (required for LibreChat authentication)
-			refreshTokenString, err := createLibreChatSession(mongoUserID)
-			if err != nil {
-				log.Printf("ERROR: Failed to create LibreChat session: %v", err)
-				log.Printf("WARNING: Continuing without session - authentication may fail")
-			} else {
-				log.Printf("SUCCESS: LibreChat session created, refresh token generated")
-				if len(libreJWTSecret) > 0 {
-					// Create LibreChat access token (JWT signed with JWT_SECRET)
-					accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-						"id":  mongoUserID,
-						"exp": time.Now().Add(24 * time.Hour).Unix(), // 24 hour expiry
-						"iat": time.Now().Unix(),
-					})
-					accessTokenString, err := accessToken.SignedString(libreJWTSecret)
-					if err == nil {
-						// Set LibreChat's refreshToken cookie
-						refreshTokenCookie := &http.Cookie{
-							Name:     "refreshToken",
-							Value:    refreshTokenString,
-							Path:     "/",
-							Domain:   "",
-							Expires:  time.Now().Add(7 * 24 * time.Hour), // 7 days
-							MaxAge:   7 * 24 * 3600,
-							Secure:   useHTTPS,
-							HttpOnly: true,
-							SameSite: http.SameSiteLaxMode,
-						}
-						http.SetCookie(w, refreshTokenCookie)
+		return "", err
+	}
+	log.Printf("SUCCESS: LibreChat user sync completed for: %s (MongoDB ID: %s)", user.Email, mongoUserID)
 
-						// Warn if there's a protocol/cookie mismatch
-						isHTTPS := isSecureRequest(r)
-						if isHTTPS && !useHTTPS {
-							log.Printf("⚠️  WARNING: Request is HTTPS but cookie Secure=false - cookies may not be sent!")
-							log.Printf("⚠️  Set USE_HTTPS=true to fix this issue")
-						}
-						log.Printf("Set refreshToken cookie - Secure=%v, SameSite=Lax (Request protocol: HTTPS=%v)", useHTTPS, isHTTPS)
-
-						// Set token_provider cookie
-						tokenProviderCookie := &http.Cookie{
-							Name:     "token_provider",
-							Value:    "librechat",
-							Path:     "/",
-							Domain:   "",
-							Expires:  time.Now().Add(7 * 24 * time.Hour),
-							MaxAge:   7 * 24 * 3600,
-							Secure:   useHTTPS,
-							HttpOnly: true,
-							SameSite: http.SameSiteLaxMode,
-						}
-						http.SetCookie(w, tokenProviderCookie)
-						log.Printf("Set token_provider cookie - Secure=%v, SameSite=Lax", useHTTPS)
-
-						// Store LibreChat access token in response header for frontend
-						w.Header().Set("X-LibreChat-Token", accessTokenString)
-						log.Printf("Set LibreChat authentication tokens for user: %s (MongoDB ID: %s)", req.Email, mongoUserID)
-					} else {
-						log.Printf("Failed to generate LibreChat access token: %v", err)
-					}
-				} else {
-					log.Printf("ERROR: LIBRE_JWT_SECRET not set - cannot generate access token")
-				}
-			}
-		} else {
-			log.Printf("WARNING: mongoUserID is empty - cannot create session")
-		}
+	if mongoUserID == "" {
+		log.Printf("WARNING: mongoUserID is empty - cannot create session")
+		return "", fmt.Errorf("no LibreChat user ID for %s", user.Email)
+	}
+
+	// Create session in MongoDB (required for LibreChat authentication)
+	log.Printf("Creating LibreChat session for MongoDB user ID: %s", mongoUserID)
+	refreshTokenString, err := createLibreChatSession(r.Context(), mongoUserID)
+	if err != nil {
+		log.Printf("ERROR: Failed to create LibreChat session: %v", err)
+		return "", err
+	}
+	log.Printf("SUCCESS: LibreChat session created, refresh token generated")
+
+	if len(cfg.Secrets.LibreJWTSecret) == 0 {
+		log.Printf("ERROR: LIBRE_JWT_SECRET not set - cannot generate access token")
+		return "", fmt.Errorf("LIBRE_JWT_SECRET not set")
+	}
+
+	// Create LibreChat access token (JWT signed with JWT_SECRET)
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":  mongoUserID,
+		"exp": time.Now().Add(libreAccessTokenTTL).Unix(),
+		"iat": time.Now().Unix(),
+	})
+	accessTokenString, err := accessToken.SignedString(cfg.Secrets.LibreJWTSecret)
+	if err != nil {
+		log.Printf("Failed to generate LibreChat access token: %v", err)
+		return "", err
+	}
+
+	// Set LibreChat's refreshToken cookie
+	refreshTokenCookie := &http.Cookie{
+		Name:     cfg.Cookies.LibreRefresh,
+		Value:    refreshTokenString,
+		Path:     "/",
+		Domain:   "",
+		Expires:  time.Now().Add(7 * 24 * time.Hour), // 7 days
+		MaxAge:   7 * 24 * 3600,
+		Secure:   cfg.TLS.SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, refreshTokenCookie)
+
+	// Warn if there's a protocol/cookie mismatch
+	isHTTPS := isSecureRequest(r)
+	if isHTTPS && !cfg.TLS.SecureCookies {
+		log.Printf("⚠️  WARNING: Request is HTTPS but cookie Secure=false - cookies may not be sent!")
+		log.Printf("⚠️  Set USE_HTTPS=true to fix this issue")
 	}
+	log.Printf("Set refreshToken cookie - Secure=%v, SameSite=Lax (Request protocol: HTTPS=%v)", cfg.TLS.SecureCookies, isHTTPS)
+
+	// Set token_provider cookie
+	tokenProviderCookie := &http.Cookie{
+		Name:     cfg.Cookies.LibreTokenProvider,
+		Value:    "librechat",
+		Path:     "/",
+		Domain:   "",
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+		MaxAge:   7 * 24 * 3600,
+		Secure:   cfg.TLS.SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, tokenProviderCookie)
+	log.Printf("Set token_provider cookie - Secure=%v, SameSite=Lax", cfg.TLS.SecureCookies)
+
+	// Store LibreChat access token in response header for frontend
+	w.Header().Set("X-LibreChat-Token", accessTokenString)
+	log.Printf("Set LibreChat authentication tokens for user: %s (MongoDB ID: %s)", user.Email, mongoUserID)
+	return accessTokenString, nil
+}
 
-	// create JWT
+// startProxySession sets the proxy session cookie for email and returns the session token
+func startProxySession(w http.ResponseWriter, r *http.Request, email string) (string, error) {
+	// create JWT; the jti lets this session be revoked on its own (see revocation.go)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": req.Email,
-		"exp":   time.Now().Add(6 * time.Hour).Unix(),
+		"email": email,
+		"jti":   uuid.NewString(),
+		"exp":   time.Now().Add(proxySessionTTL).Unix(),
 		"iat":   time.Now().Unix(),
 	})
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(cfg.Secrets.JWTSecret)
 	if err != nil {
-		http.Error(w, "token error", http.StatusInternalServerError)
-		return
+		return "", err
 	}
 
 	// Set secure, HttpOnly cookie. Secure flag is set based on USE_HTTPS env var.
 	cookie := &http.Cookie{
-		Name:     cookieName,
+		Name:     cfg.Cookies.Session,
 		Value:    tokenString,
 		Path:     "/",
 		Domain:   "", // leave empty for host-only cookie
-		Expires:  time.Now().Add(6 * time.Hour),
-		MaxAge:   6 * 3600,
-		Secure:   useHTTPS,             // Set based on USE_HTTPS environment variable
-		HttpOnly: true,                 // not accessible via JS
-		SameSite: http.SameSiteLaxMode, // Lax for iframe compatibility
+		Expires:  time.Now().Add(proxySessionTTL),
+		MaxAge:   int(proxySessionTTL.Seconds()),
+		Secure:   cfg.TLS.SecureCookies, // Set based on USE_HTTPS environment variable
+		HttpOnly: true,                  // not accessible via JS
+		SameSite: http.SameSiteLaxMode,  // Lax for iframe compatibility
 	}
 	http.SetCookie(w, cookie)
 
 	// Warn if there's a protocol/cookie mismatch
 	isHTTPS := isSecureRequest(r)
-	if isHTTPS && !useHTTPS {
+	if isHTTPS && !cfg.TLS.SecureCookies {
 		log.Printf("⚠️  WARNING: Request is HTTPS but cookie Secure=false - cookies may not be sent!")
 		log.Printf("⚠️  Set USE_HTTPS=true to fix this issue")
 	}
-	log.Printf("Set %s cookie - Secure=%v, SameSite=Lax (Request protocol: HTTPS=%v)", cookieName, useHTTPS, isHTTPS)
+	log.Printf("Set %s cookie - Secure=%v, SameSite=Lax (Request protocol: HTTPS=%v)", cfg.Cookies.Session, cfg.TLS.SecureCookies, isHTTPS)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	// A fresh login ends any refresh loop this browser was in
+	refreshLoops.reset(refreshClientKey(r))
+	if cfg.CSRF.Mode == csrfDoubleSubmit {
+		setCSRFCookie(w)
+	}
+	return tokenString, nil
 }
 
-// verifyToken returns email from token or error
+// verifyToken returns email from token or error. Besides the proxy's own session tokens it accepts
+// saas-api RS256 access tokens when PROXY_SAAS_JWKS_URL is set, so API clients can send their
+// saas-api token as the Bearer token. OAuth client_credentials tokens are scoped to saas-api
+// resources and are refused, as saas-api refuses them on full-session routes.
 func verifyToken(tokenString string) (string, error) {
 	tokenString = strings.TrimSpace(tokenString)
 	// allow formats: "Bearer xxx" or raw token
@@ -826,17 +640,31 @@ func verifyToken(tokenString string) (string, error) {
 		tokenString = tokenString[7:]
 	}
 	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		// Verify alg
+		// Verify alg: the proxy signs with HMAC, saas-api's public keys only verify RS256
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); ok && saasJWKS != nil {
+			return saasKeyfunc(t)
+		}
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method")
 		}
-		return jwtSecret, nil
-	})
+		return cfg.Secrets.JWTSecret, nil
+	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256"}))
 	if err != nil {
 		return "", err
 	}
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if _, rsa := token.Method.(*jwt.SigningMethodRSA); rsa {
+			if iss, _ := claims.GetIssuer(); iss != "saas-api" {
+				return "", fmt.Errorf("invalid token issuer")
+			}
+			if _, ok := claims["client_id"]; ok {
+				return "", fmt.Errorf("client credentials tokens cannot start a session")
+			}
+		}
 		if email, ok := claims["email"].(string); ok {
+			if tokenRevoked(claims, email) {
+				return "", fmt.Errorf("token revoked")
+			}
 			return email, nil
 		}
 	}
@@ -845,6 +673,12 @@ func verifyToken(tokenString string) (string, error) {
 
 // proxyWebsocket proxies a websocket connection to the target websocket endpoint.
 func proxyWebsocket(w http.ResponseWriter, r *http.Request, targetUrl *url.URL, email string) {
+	release, ok := acquireWebsocketSlot(w, r, email)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// prepare dialer to backend
 	dialer := websocket.DefaultDialer
 
@@ -867,11 +701,14 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, targetUrl *url.URL,
 		}
 	}
 
-	// inject user header
+	// inject user header; copies sent by the client are never passed on
+	requestHeader.Del("X-Authenticated-User")
+	requestHeader.Del("X-User-From-Proxy")
 	if email != "" {
 		requestHeader.Set("X-Authenticated-User", email)
 		requestHeader.Set("X-User-From-Proxy", email)
 	}
+	setUserContextHeaders(requestHeader, r)
 
 	// Build target ws url: wss:// or ws:// depending on target scheme
 	wsScheme := "ws"
@@ -884,9 +721,17 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, targetUrl *url.URL,
 		Path:     r.URL.Path, // pass through path after /proxy
 		RawQuery: r.URL.RawQuery,
 	}
+	signBackendRequest(requestHeader, http.MethodGet, targetWsUrl.RequestURI())
 
+	setAccessLogUpstream(r.Context(), "librechat-backend")
+	// One span for the whole session; its traceparent goes out with the handshake
+	_, span := startUpstreamSpan(r.Context(), targetUrl.Host, "websocket "+targetUrl.Host, requestHeader)
+	defer span.End()
 	backendConn, resp, err := dialer.Dial(targetWsUrl.String(), requestHeader)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "dial error")
+		proxyWebsocketTotal.WithLabelValues("dial_error").Inc()
 		log.Printf("websocket dial error: %v (resp: %+v)\n", err, resp)
 		http.Error(w, "Error connecting to backend websocket: "+err.Error(), http.StatusBadGateway)
 		return
@@ -894,52 +739,21 @@ func proxyWebsocket(w http.ResponseWriter, r *http.Request, targetUrl *url.URL,
 
 	// Upgrade incoming request to websocket
 	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true }, // allow from any origin for demo; adjust in prod
+		CheckOrigin: checkWebsocketOrigin,
 	}
 	clientConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		proxyWebsocketTotal.WithLabelValues("upgrade_error").Inc()
+		span.SetStatus(codes.Error, "upgrade error")
 		log.Printf("websocket upgrade error: %v\n", err)
 		backendConn.Close()
 		return
 	}
+	proxyWebsocketTotal.WithLabelValues("opened").Inc()
+	proxyWebsocketConnections.Inc()
+	defer proxyWebsocketConnections.Dec()
 
-	// Start copying messages between clientConn and backendConn
-	errc := make(chan error, 2)
-
-	go func() {
-		defer backendConn.Close()
-		defer clientConn.Close()
-		for {
-			mt, message, err := clientConn.ReadMessage()
-			if err != nil {
-				errc <- err
-				return
-			}
-			if err := backendConn.WriteMessage(mt, message); err != nil {
-				errc <- err
-				return
-			}
-		}
-	}()
-
-	go func() {
-		defer backendConn.Close()
-		defer clientConn.Close()
-		for {
-			mt, message, err := backendConn.ReadMessage()
-			if err != nil {
-				errc <- err
-				return
-			}
-			if err := clientConn.WriteMessage(mt, message); err != nil {
-				errc <- err
-				return
-			}
-		}
-	}()
-
-	// wait for error
-	<-errc
+	relayWebsocket(clientConn, backendConn)
 }
 
 // stripPrefixPath removes the proxy prefix and returns the modified request path for backend.
@@ -950,26 +764,65 @@ func stripPrefixPath(r *http.Request, prefix string) {
 	}
 }
 
-func main() {
-	// parse targets
-	backendTarget, err := url.Parse(libreBackend)
+// Serve runs the proxy (`saas-api serve proxy`) until ctx is cancelled, then shuts it down gracefully
+func Serve(ctx context.Context) {
+	// Fail fast on missing secrets or invalid settings before serving anything
+	var err error
+	cfg, err = Load()
 	if err != nil {
 		log.Fatal(err)
 	}
+	cfg.LogSummary()
 
-	frontendTarget, err := url.Parse(libreFrontend)
+	shutdownTracing, err := setupTracing(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Backend API proxy (for /api and /oauth)
-	backendProxy := httputil.NewSingleHostReverseProxy(backendTarget)
+	revocations, err = newRevocationStore(cfg.Revocation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.JWKS.URL != "" {
+		saasJWKS = newJWKSCache(cfg.JWKS)
+	}
+	assets, err = newAssetCache(cfg.AssetCache)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Backend pool and rate limiter; SIGHUP or POST /internal/reload replaces them (see reload.go)
+	state, err := newReloadState(cfg, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloadable.Store(state)
+	defer func() { current().stopHealthChecks() }()
+	watchReloadSignal(ctx)
+
+	frontendTarget, err := url.Parse(cfg.Upstream.LibreFrontend)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Backend API proxy (for /api and /oauth), balanced over the LIBRE_BACKEND instances
+	backendProxy := &httputil.ReverseProxy{
+		Director:     func(req *http.Request) { current().backends.direct(req) },
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) { current().backends.errorHandler(w, r, err) },
+		// Flush every write, so streamed chat tokens reach the browser as they arrive (see sse.go)
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			stripUpstreamCORS(resp)
+			stripUpstreamSecurityHeaders(resp)
+			return nil
+		},
+	}
 
 	// Backend proxy transport
 	backendProxy.Transport = &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
+			Timeout:   time.Duration(cfg.Circuit.ConnectTimeout) * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 		ForceAttemptHTTP2:     true,
@@ -978,11 +831,19 @@ func main() {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	backendProxy.Transport = &instrumentedTransport{upstream: "librechat-backend", base: newBreakerTransport("librechat-backend", backendProxy.Transport)}
 
 	originalBackendDirector := backendProxy.Director
 	backendProxy.Director = func(req *http.Request) {
 		originalBackendDirector(req)
-		req.Host = backendTarget.Host
+		req.Host = req.URL.Host
+
+		// Only the proxy sets the user headers (and signs them, see signing.go)
+		req.Header.Del("X-Authenticated-User")
+		req.Header.Del("X-User-From-Proxy")
+
+		// Org and role for LibreChat-side tenancy checks, before the Authorization header is touched
+		setUserContextHeaders(req.Header, req)
 
 		// Check Authorization header for tokens
 		authHeader := req.Header.Get("Authorization")
@@ -996,12 +857,12 @@ func main() {
 			}
 
 			// First, try to verify as LibreChat token (signed with LIBRE_JWT_SECRET)
-			if len(libreJWTSecret) > 0 {
+			if len(cfg.Secrets.LibreJWTSecret) > 0 {
 				if _, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 						return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 					}
-					return libreJWTSecret, nil
+					return cfg.Secrets.LibreJWTSecret, nil
 				}); err == nil {
 					// This is a LibreChat access token - forward it to LibreChat backend
 					// Don't delete it, let it pass through
@@ -1013,7 +874,7 @@ func main() {
 			// Not a LibreChat token, try to verify as proxy token
 			// Extract token from cookie or Authorization header
 			var proxyToken string
-			if c, err := req.Cookie(cookieName); err == nil {
+			if c, err := req.Cookie(cfg.Cookies.Session); err == nil {
 				proxyToken = c.Value
 			}
 			if proxyToken == "" {
@@ -1038,7 +899,7 @@ func main() {
 			}
 		} else {
 			// No Authorization header, check for proxy token in cookie
-			if c, err := req.Cookie(cookieName); err == nil {
+			if c, err := req.Cookie(cfg.Cookies.Session); err == nil {
 				if email, err := verifyToken(c.Value); err == nil {
 					req.Header.Set("X-Authenticated-User", email)
 					req.Header.Set("X-User-From-Proxy", email)
@@ -1056,8 +917,8 @@ func main() {
 		if strings.Contains(req.URL.Path, "/api/auth/refresh") {
 			// Ensure cookies are in the header that will be sent to LibreChat backend
 			// httputil.ReverseProxy should handle this automatically, but let's be explicit
-			refreshTokenCookie, _ := req.Cookie("refreshToken")
-			tokenProviderCookie, _ := req.Cookie("token_provider")
+			refreshTokenCookie, _ := req.Cookie(cfg.Cookies.LibreRefresh)
+			tokenProviderCookie, _ := req.Cookie(cfg.Cookies.LibreTokenProvider)
 			cookieHeader := req.Header.Get("Cookie")
 
 			if refreshTokenCookie != nil && !strings.Contains(cookieHeader, "refreshToken=") {
@@ -1076,7 +937,13 @@ func main() {
 				}
 			}
 		}
+
+		signBackendRequest(req.Header, req.Method, req.URL.RequestURI())
 	}
+	// LibreChat API traffic goes through the refresh loop guard (see refresh_loop.go) and event
+	// stream passthrough (see sse.go)
+	balancedBackend := balanceBackends(backendProxy)
+	libreBackend := guardRefreshLoop(streamEvents(balancedBackend))
 
 	// Frontend proxy (for Vite dev server)
 	frontendProxy := httputil.NewSingleHostReverseProxy(frontendTarget)
@@ -1085,7 +952,7 @@ func main() {
 	frontendProxy.Transport = &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
+			Timeout:   time.Duration(cfg.Circuit.ConnectTimeout) * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 		ForceAttemptHTTP2:     true,
@@ -1094,6 +961,8 @@ func main() {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	frontendProxy.ErrorHandler = upstreamErrorHandler("librechat-frontend")
+	frontendProxy.Transport = &instrumentedTransport{upstream: "librechat-frontend", base: newBreakerTransport("librechat-frontend", frontendProxy.Transport)}
 
 	originalFrontendDirector := frontendProxy.Director
 	frontendProxy.Director = func(req *http.Request) {
@@ -1106,84 +975,21 @@ func main() {
 
 	// Custom response modifier for frontend to handle CORS, preserve headers, and rewrite URLs in HTML
 	frontendProxy.ModifyResponse = func(resp *http.Response) error {
-		// Set CORS headers on the response
-		resp.Header.Set("Access-Control-Allow-Origin", "*")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-
-		// Rewrite URLs in HTML responses to use /proxy/ prefix
-		contentType := resp.Header.Get("Content-Type")
-		if strings.Contains(contentType, "text/html") {
-			// Read the response body
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return err
-			}
-			resp.Body.Close()
-
-			htmlContent := string(bodyBytes)
-
-			// DON'T rewrite URLs - LibreChat is configured with base: '/proxy/'
-			// It already generates all URLs with /proxy/ prefix automatically
-			// Only rewrite WebSocket URLs to use proxy port (7080) instead of LibreChat port (3090)
-
-			// Only rewrite WebSocket URLs to use proxy port (7080) instead of LibreChat port (3090)
-			// Keep the /proxy/ prefix since LibreChat is configured with base: '/proxy/'
-			htmlContent = strings.ReplaceAll(htmlContent, `ws://localhost:3090/`, `ws://localhost:7080/`)
-			htmlContent = strings.ReplaceAll(htmlContent, `wss://localhost:3090/`, `wss://localhost:7080/`)
-			htmlContent = strings.ReplaceAll(htmlContent, `"ws://localhost:3090/`, `"ws://localhost:7080/`)
-			htmlContent = strings.ReplaceAll(htmlContent, `"wss://localhost:3090/`, `"wss://localhost:7080/`)
-			htmlContent = strings.ReplaceAll(htmlContent, `'ws://localhost:3090/`, `'ws://localhost:7080/`)
-			htmlContent = strings.ReplaceAll(htmlContent, `'wss://localhost:3090/`, `'wss://localhost:7080/`)
-
-			// Don't modify base tag - LibreChat should already have it set correctly to /proxy/
-			// Only fix if it's incorrectly set (shouldn't happen with base config)
-
-			// No router fix script needed - LibreChat is configured with base: '/proxy/'
-			// Paths are forwarded as-is (e.g., /proxy/c/new) and LibreChat handles them correctly
-			routerFixScript := `` // Empty - no fix needed
-
-			// Insert the script as early as possible - right after <head> tag
-			if strings.Contains(htmlContent, "<head>") {
-				// Insert right after <head> tag
-				htmlContent = strings.Replace(htmlContent, "<head>", "<head>"+routerFixScript, 1)
-			} else if strings.Contains(htmlContent, "</head>") {
-				// If no opening head tag, insert before closing head
-				htmlContent = strings.Replace(htmlContent, "</head>", routerFixScript+"</head>", 1)
-			} else {
-				// Last resort: insert at the very beginning
-				htmlContent = routerFixScript + htmlContent
-			}
+		// CORS and security headers come from withCORS and withSecurityHeaders
+		stripUpstreamCORS(resp)
+		stripUpstreamSecurityHeaders(resp)
 
-			// Replace the response body
-			resp.Body = io.NopCloser(strings.NewReader(htmlContent))
-			resp.ContentLength = int64(len(htmlContent))
-			resp.Header.Set("Content-Length", fmt.Sprintf("%d", len(htmlContent)))
-		}
+		// PROXY_REWRITE_RULES (see rewrite.go); the body is streamed, not buffered
+		rewriteResponse(resp, current().rewrite.Rules)
 
 		return nil
 	}
 
-	// Helper function to extract email from request
-	extractEmailFromRequest := func(r *http.Request) string {
-		var token string
-		if c, err := r.Cookie(cookieName); err == nil {
-			token = c.Value
-		}
-		if token == "" {
-			token = r.Header.Get("Authorization")
-		}
-		if token != "" {
-			if email, err := verifyToken(token); err == nil {
-				return email
-			}
-		}
-		return ""
-	}
+	// Hashed build assets are answered from the asset cache once fetched (see assetcache.go)
+	cachedFrontend := withAssetCache(frontendProxy)
 
 	// Saas-API proxy (for /api/v1/* - forwards to port 8080)
-	saasAPITarget, err := url.Parse(mainAPIURL)
+	saasAPITarget, err := url.Parse(cfg.Upstream.MainAPIURL)
 	if err != nil {
 		log.Fatalf("Failed to parse saas-api URL: %v", err)
 	}
@@ -1191,7 +997,7 @@ func main() {
 	saasAPIProxy.Transport = &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
+			Timeout:   time.Duration(cfg.Circuit.ConnectTimeout) * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 		ForceAttemptHTTP2:     true,
@@ -1200,6 +1006,7 @@ func main() {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	saasAPIProxy.Transport = &instrumentedTransport{upstream: "saas-api", base: newBreakerTransport("saas-api", saasAPIProxy.Transport)}
 	saasAPIProxy.Director = func(req *http.Request) {
 		req.URL.Scheme = saasAPITarget.Scheme
 		req.URL.Host = saasAPITarget.Host
@@ -1208,438 +1015,258 @@ func main() {
 		// The token will be in the Authorization header from the frontend
 		// Query parameters (including token query param for static files) are automatically preserved
 		// by req.URL.RawQuery, no action needed
-		log.Printf("DEBUG: saasAPIProxy.Director - forwarding to %s://%s%s (query: %s)", req.URL.Scheme, req.URL.Host, req.URL.Path, req.URL.RawQuery)
+		// The query is not logged: /static/* carries the access token in it
+		log.Printf("DEBUG: saasAPIProxy.Director - forwarding to %s://%s%s", req.URL.Scheme, req.URL.Host, req.URL.Path)
+	}
+	saasAPIProxy.ModifyResponse = func(resp *http.Response) error {
+		stripUpstreamCORS(resp)
+		stripUpstreamSecurityHeaders(resp)
+		return nil
 	}
 	// Add error handler to catch and log proxy errors
 	saasAPIProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if handleRouteLimit(w, r) || handleCircuitOpen(w, r, err) {
+			return
+		}
 		log.Printf("ERROR: saasAPIProxy error for %s %s: %v", r.Method, r.URL.Path, err)
 		http.Error(w, fmt.Sprintf("Bad Gateway: %v", err), http.StatusBadGateway)
 	}
 
-	// Static file routes - route /static/* to saas-api (port 8080)
-	http.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
-		setCORSHeaders(w, r)
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		// Log the request for debugging
-		log.Printf("DEBUG: Proxying static file request: %s %s (query: %s)", r.Method, r.URL.Path, r.URL.RawQuery)
-		// Ensure query parameters are preserved
-		// The saasAPIProxy.Director should preserve them, but let's make sure
-		saasAPIProxy.ServeHTTP(w, r)
+	// Routes and middleware (see routes.go)
+	router := newRouter(&upstreams{
+		libreBackend:    libreBackend,
+		balancedBackend: balancedBackend,
+		frontend:        frontendProxy,
+		cachedFrontend:  cachedFrontend,
+		saasAPI:         saasAPIProxy,
+		frontendTarget:  frontendTarget,
 	})
 
-	// Backend API routes (/api and /oauth)
-	// But exclude /api/v1/* which goes to saas-api
-	http.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-		// Route /api/v1/* to saas-api (port 8080)
-		if strings.HasPrefix(r.URL.Path, "/api/v1/") {
-			setCORSHeaders(w, r)
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			saasAPIProxy.ServeHTTP(w, r)
-			return
-		}
-
-		email := extractEmailFromRequest(r)
+	port := cfg.Server.Port
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+		// Good practice: set timeouts to avoid Slowloris
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	}
 
-		// Handle websocket for backend
-		if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
-			proxyWebsocket(w, r, backendTarget, email)
-			return
+	var challengeSrv *http.Server
+	if cfg.TLS.Enabled {
+		// Cert and key presence is checked in Config.Validate
+		certFile, keyFile := cfg.TLS.CertFile, cfg.TLS.KeyFile
+		srv.TLSConfig = &tls.Config{
+			MinVersion:               tls.VersionTLS12,
+			PreferServerCipherSuites: true,
 		}
-
-		backendProxy.ServeHTTP(w, r)
-	})
-
-	http.HandleFunc("/oauth/", func(w http.ResponseWriter, r *http.Request) {
-		backendProxy.ServeHTTP(w, r)
-	})
-
-	// Frontend routes - proxy everything else to Vite dev server
-	// This includes: /@vite/client, /src/, /@react-refresh, /c/new, etc.
-	http.HandleFunc("/proxy/", func(w http.ResponseWriter, r *http.Request) {
-		// Handle preflight OPTIONS request
-		if r.Method == "OPTIONS" {
-			setCORSHeaders(w, r)
-			w.WriteHeader(http.StatusOK)
-			return
+		if cfg.TLS.ACMEEnabled() {
+			// Certificates come from the ACME manager's GetCertificate instead of files
+			manager := newACMEManager()
+			srv.TLSConfig = acmeTLSConfig(manager)
+			certFile, keyFile = "", ""
+			challengeSrv = startACMEChallengeServer(manager)
 		}
 
-		// If the path is /proxy/api/v1/*, route to saas-api (port 8080)
-		if strings.HasPrefix(r.URL.Path, "/proxy/api/v1/") {
-			setCORSHeaders(w, r)
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
+		// Graceful shutdown
+		go func() {
+			log.Printf("Starting HTTPS proxy server on https://localhost:%s\n", port)
+			log.Printf("Backend proxy: %s\n", strings.Join(cfg.Upstream.LibreBackends, ", "))
+			log.Printf("Frontend proxy: %s\n", cfg.Upstream.LibreFrontend)
+			if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("ListenAndServeTLS(): %v", err)
 			}
-			// Strip /proxy prefix and route to saas-api
-			stripPrefixPath(r, "/proxy")
-			saasAPIProxy.ServeHTTP(w, r)
-			return
-		}
-
-		// If the path is /proxy/api/ or /proxy/oauth/, route to backend instead of frontend
-		if strings.HasPrefix(r.URL.Path, "/proxy/api/") || strings.HasPrefix(r.URL.Path, "/proxy/oauth/") {
-			// Strip /proxy prefix and route to backend
-			stripPrefixPath(r, "/proxy")
-			email := extractEmailFromRequest(r)
-
-			// Handle WebSocket for backend
-			if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
-				proxyWebsocket(w, r, backendTarget, email)
-				return
+		}()
+	} else {
+		// Graceful shutdown
+		go func() {
+			log.Printf("Starting HTTP proxy server on http://localhost:%s\n", port)
+			log.Printf("Backend proxy: %s\n", strings.Join(cfg.Upstream.LibreBackends, ", "))
+			log.Printf("Frontend proxy: %s\n", cfg.Upstream.LibreFrontend)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("ListenAndServe(): %v", err)
 			}
+		}()
+	}
 
-			// Route to backend API
-			backendProxy.ServeHTTP(w, r)
-			return
-		}
-
-		// DON'T strip /proxy prefix for frontend routes - LibreChat is configured with base: '/proxy/'
-		// Keep the full path /proxy/c/new when forwarding to LibreChat
-		// stripPrefixPath(r, "/proxy")  // Keep prefix - LibreChat expects it
-
-		email := extractEmailFromRequest(r)
+	// handle shutdown
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+	// Shutdown stops accepting connections and waits for in-flight requests, while websockets and
+	// event streams, which it would not end, are drained alongside (see drain.go)
+	shutdownDone := make(chan struct{})
+	go func() {
+		srv.Shutdown(shutdownCtx)
+		close(shutdownDone)
+	}()
+	activeStreams.drain(time.Duration(cfg.Server.DrainTimeout) * time.Second)
+	<-shutdownDone
+	if challengeSrv != nil {
+		challengeSrv.Shutdown(shutdownCtx)
+	}
+	libreMongo.Close(shutdownCtx)
+	shutdownTracing(shutdownCtx)
+}
 
-		// If websocket Upgrade header present, handle websocket proxying
-		if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
-			proxyWebsocket(w, r, frontendTarget, email)
-			return
+// extractEmailFromRequest returns the signed-in user from the proxy session cookie or the
+// Authorization header, or "" when neither carries a valid token
+func extractEmailFromRequest(r *http.Request) string {
+	var token string
+	if c, err := r.Cookie(cfg.Cookies.Session); err == nil {
+		token = c.Value
+	}
+	if token == "" {
+		token = r.Header.Get("Authorization")
+	}
+	if token != "" {
+		if email, err := verifyToken(token); err == nil {
+			return email
 		}
+	}
+	return ""
+}
 
-		// Otherwise use HTTP reverse proxy
-		frontendProxy.ServeHTTP(w, r)
-	})
+// libreCredentialsHandler returns the LibreChat user for ?email= to a caller signed in as that user,
+// by proxy session cookie or saas-api Authorization token
+func libreCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, r)
 
-	// Handle Vite-specific routes that might be requested from root
-	// These should be proxied to frontend even when accessed without /proxy/ prefix
-	viteHandler := func(w http.ResponseWriter, r *http.Request) {
-		email := extractEmailFromRequest(r)
-		if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
-			proxyWebsocket(w, r, frontendTarget, email)
-			return
-		}
-		frontendProxy.ServeHTTP(w, r)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	// Register Vite routes
-	http.HandleFunc("/@vite/", viteHandler)
-	http.HandleFunc("/@react-refresh", viteHandler)
-	http.HandleFunc("/src/", viteHandler)
-	http.HandleFunc("/node_modules/", viteHandler)
-	http.HandleFunc("/@fs/", viteHandler)
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email parameter required", http.StatusBadRequest)
+		return
+	}
 
-	// Default handler - proxy everything else to frontend (for direct access without /proxy prefix)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// /login is handled by its own handler above, so we don't need to check here
+	// Get JWT from cookie OR from Authorization header (main app token)
+	var verifiedEmail string
 
-		// Skip API, OAuth, and static routes (already handled above)
-		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/oauth/") || strings.HasPrefix(r.URL.Path, "/static/") {
-			return
-		}
-
-		email := extractEmailFromRequest(r)
-
-		// Handle websocket - Vite HMR WebSocket might connect to root path
-		// Check for WebSocket upgrade or if it's a WebSocket request (has token query param)
-		if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") ||
-			strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
-			(r.URL.Query().Get("token") != "" && r.Method == "GET") {
-			// For WebSocket at root, we need to proxy to frontend
-			// But first, check if this is actually a WebSocket request
-			if strings.EqualFold(r.Header.Get("Connection"), "Upgrade") ||
-				strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
-				proxyWebsocket(w, r, frontendTarget, email)
-				return
-			}
-			// If it has a token param but isn't a WebSocket upgrade, it might be Vite trying to connect
-			// Redirect or proxy to /proxy/ path
-			if r.URL.Query().Get("token") != "" {
-				// This is likely Vite HMR WebSocket - proxy to frontend
-				proxyWebsocket(w, r, frontendTarget, email)
-				return
+	// Try cookie first
+	cookie, err := r.Cookie(cfg.Cookies.Session)
+	if err == nil && cookie != nil {
+		// Verify JWT from cookie
+		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-		}
-
-		// Proxy to frontend
-		frontendProxy.ServeHTTP(w, r)
-	})
-
-	// Endpoint to get LibreChat credentials from MongoDB
-	http.HandleFunc("/get-librechat-credentials", func(w http.ResponseWriter, r *http.Request) {
-		setCORSHeaders(w, r)
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+			return cfg.Secrets.JWTSecret, nil
+		})
 
-		email := r.URL.Query().Get("email")
-		if email == "" {
-			http.Error(w, "email parameter required", http.StatusBadRequest)
-			return
-		}
-
-		// Get JWT from cookie OR from Authorization header (main app token)
-		var verifiedEmail string
-
-		// Try cookie first
-		cookie, err := r.Cookie(cookieName)
-		if err == nil && cookie != nil {
-			// Verify JWT from cookie
-			token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return jwtSecret, nil
-			})
-
-			if err == nil && token.Valid {
-				if claims, ok := token.Claims.(jwt.MapClaims); ok {
-					if claimEmail, ok := claims["email"].(string); ok {
-						verifiedEmail = claimEmail
-					}
+		if err == nil && token.Valid {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				if claimEmail, ok := claims["email"].(string); ok {
+					verifiedEmail = claimEmail
 				}
 			}
 		}
+	}
 
-		// If cookie didn't work, try Authorization header (main app token)
-		if verifiedEmail == "" {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-				// Verify token with main API
-				client := &http.Client{Timeout: 5 * time.Second}
-				req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/auth/me", mainAPIURL), nil)
-				if err == nil {
-					req.Header.Set("Authorization", authHeader)
-					resp, err := client.Do(req)
-					if err == nil && resp.StatusCode == 200 {
-						var userData map[string]interface{}
-						if json.NewDecoder(resp.Body).Decode(&userData) == nil {
-							if userEmail, ok := userData["email"].(string); ok {
-								verifiedEmail = userEmail
-								log.Printf("Verified user via main API token: %s", verifiedEmail)
-							}
+	// If cookie didn't work, try Authorization header (main app token)
+	if verifiedEmail == "" {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
+			// Verify token with main API
+			client := &http.Client{Timeout: 5 * time.Second}
+			req, err := http.NewRequestWithContext(r.Context(), "GET", fmt.Sprintf("%s/api/v1/auth/me", cfg.Upstream.MainAPIURL), nil)
+			if err == nil {
+				req.Header.Set("Authorization", authHeader)
+				setRequestID(req)
+				resp, err := client.Do(req)
+				if err == nil && resp.StatusCode == 200 {
+					var userData map[string]interface{}
+					if json.NewDecoder(resp.Body).Decode(&userData) == nil {
+						if userEmail, ok := userData["email"].(string); ok {
+							verifiedEmail = userEmail
+							log.Printf("Verified user via main API token: %s", verifiedEmail)
 						}
-						resp.Body.Close()
-					} else if err != nil {
-						log.Printf("Error verifying main API token: %v", err)
-					} else {
-						log.Printf("Main API token verification failed with status: %d", resp.StatusCode)
 					}
+					resp.Body.Close()
+				} else if err != nil {
+					log.Printf("Error verifying main API token: %v", err)
+				} else {
+					log.Printf("Main API token verification failed with status: %d", resp.StatusCode)
 				}
 			}
 		}
+	}
 
-		// If still not verified, return unauthorized
-		if verifiedEmail == "" {
-			log.Printf("Unauthorized: No valid JWT cookie or Authorization token")
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Verify the email matches
-		if verifiedEmail != email {
-			log.Printf("Email mismatch: verified=%s, requested=%s", verifiedEmail, email)
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Fetch user from LibreChat MongoDB
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		clientOptions := options.Client().ApplyURI(mongoURI)
-		client, err := mongo.Connect(ctx, clientOptions)
-		if err != nil {
-			log.Printf("MongoDB connection error: %v", err)
-			http.Error(w, "database error", http.StatusInternalServerError)
-			return
-		}
-		defer client.Disconnect(ctx)
-
-		// Extract database name
-		dbName := "LibreChat"
-		parsedURI, err := url.Parse(mongoURI)
-		if err == nil && parsedURI.Path != "" {
-			path := strings.TrimPrefix(parsedURI.Path, "/")
-			if idx := strings.Index(path, "?"); idx > 0 {
-				path = path[:idx]
-			}
-			if path != "" {
-				dbName = path
-			}
-		}
-
-		db := client.Database(dbName)
-		collection := db.Collection("users")
-
-		filter := bson.M{"email": email}
-		// Use bson.M to avoid refreshToken decoding issues
-		var userDoc bson.M
-		err = collection.FindOne(ctx, filter).Decode(&userDoc)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				http.Error(w, "user not found in LibreChat", http.StatusNotFound)
-				return
-			}
-			log.Printf("MongoDB find error: %v", err)
-			http.Error(w, "database error", http.StatusInternalServerError)
-			return
-		}
-
-		// Extract user fields from document
-		libreUserEmail := ""
-		libreUserUsername := ""
-		libreUserName := ""
-
-		if emailVal, ok := userDoc["email"].(string); ok {
-			libreUserEmail = emailVal
-		}
-		if usernameVal, ok := userDoc["username"].(string); ok {
-			libreUserUsername = usernameVal
-		}
-		if nameVal, ok := userDoc["name"].(string); ok {
-			libreUserName = nameVal
-		}
-
-		if libreUserEmail == "" {
-			log.Printf("Email not found in user document")
-			http.Error(w, "invalid user document", http.StatusInternalServerError)
-			return
-		}
-
-		// No password needed - authentication is handled by proxy JWT cookie
-		// MongoDB is only for chat history storage
-		log.Printf("Returning user info for %s (no password needed - using JWT cookie auth)", email)
-
-		response := map[string]interface{}{
-			"email":    libreUserEmail,
-			"username": libreUserUsername,
-			"name":     libreUserName,
-		}
+	// If still not verified, return unauthorized
+	if verifiedEmail == "" {
+		log.Printf("Unauthorized: No valid JWT cookie or Authorization token")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	})
+	// Verify the email matches
+	if verifiedEmail != email {
+		log.Printf("Email mismatch: verified=%s, requested=%s", verifiedEmail, email)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	// login endpoint (also handle explicitly)
-	// Register /login handler with method check wrapper
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		// Handle preflight OPTIONS request
-		if r.Method == "OPTIONS" {
-			setCORSHeaders(w, r)
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	// Fetch user from LibreChat MongoDB
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 10*time.Second)
+	defer cancel()
 
-		// GET, HEAD, and other read-only requests should be proxied to frontend (for the login page)
-		// This includes browser reloads, favicon requests, etc.
-		if r.Method == "GET" || r.Method == "HEAD" {
-			frontendProxy.ServeHTTP(w, r)
-			return
-		}
+	db, err := libreMongo.Database(ctx)
+	if err != nil {
+		log.Printf("MongoDB connection error: %v", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	collection := db.Collection("users")
 
-		// POST requests go to loginHandler
-		if r.Method == "POST" {
-			loginHandler(w, r)
+	filter := bson.M{"email": email}
+	// Use bson.M to avoid refreshToken decoding issues
+	var userDoc bson.M
+	err = collection.FindOne(ctx, filter).Decode(&userDoc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "user not found in LibreChat", http.StatusNotFound)
 			return
 		}
-
-		// For any other method, return method not allowed
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-	})
-
-	port := "7080" // Default port
-	if p := os.Getenv("PROXY_PORT"); p != "" {
-		port = p
+		log.Printf("MongoDB find error: %v", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
 	}
 
-	// For development, use HTTP. For production, use HTTPS with certs
-	// Check USE_HTTPS environment variable
-	useHTTPSEnv := os.Getenv("USE_HTTPS")
+	// Extract user fields from document
+	libreUserEmail := ""
+	libreUserUsername := ""
+	libreUserName := ""
 
-	if useHTTPSEnv == "false" {
-		// Explicitly set to false - use HTTP mode (development)
-		useHTTPS = false
-		log.Println("🔧 USE_HTTPS=false - Development mode: Cookies will have Secure=false")
-		log.Println("   This is only suitable for HTTP (localhost) access")
-	} else if useHTTPSEnv == "true" {
-		// Explicitly set to true - use HTTPS mode (production)
-		useHTTPS = true
-		log.Println("✅ USE_HTTPS=true - Production mode: Cookies will have Secure=true")
-	} else {
-		// Not set - default to true for safety (production-ready)
-		useHTTPS = true
-		log.Println("✅ USE_HTTPS not set, defaulting to 'true' for production safety")
-		log.Println("   Cookies will have Secure=true and work with HTTPS")
-		log.Println("   Set USE_HTTPS=false explicitly for local HTTP development")
+	if emailVal, ok := userDoc["email"].(string); ok {
+		libreUserEmail = emailVal
 	}
-
-	// Server HTTPS: SERVER_HTTPS controls whether the proxy server itself runs HTTPS
-	// Set to "true" only if you want the proxy to handle TLS (needs cert.pem/key.pem)
-	// If behind a reverse proxy (nginx/Apache), leave this false
-	serverHTTPSEnv := os.Getenv("SERVER_HTTPS")
-	serverHTTPS = serverHTTPSEnv == "true"
-
-	if serverHTTPS {
-		log.Println("🔐 SERVER_HTTPS=true - Server will run with TLS (requires cert.pem and key.pem)")
-	} else {
-		log.Println("🌐 SERVER_HTTPS not set or false - Server will run on HTTP")
-		log.Println("   (Recommended when behind reverse proxy like nginx)")
+	if usernameVal, ok := userDoc["username"].(string); ok {
+		libreUserUsername = usernameVal
 	}
-
-	srv := &http.Server{
-		Addr: ":" + port,
-		// Good practice: set timeouts to avoid Slowloris
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	if nameVal, ok := userDoc["name"].(string); ok {
+		libreUserName = nameVal
 	}
 
-	if serverHTTPS {
-		certFile := "cert.pem"
-		keyFile := "key.pem"
-		srv.TLSConfig = &tls.Config{
-			MinVersion:               tls.VersionTLS12,
-			PreferServerCipherSuites: true,
-		}
+	if libreUserEmail == "" {
+		log.Printf("Email not found in user document")
+		http.Error(w, "invalid user document", http.StatusInternalServerError)
+		return
+	}
 
-		if _, err := os.Stat(certFile); os.IsNotExist(err) {
-			log.Fatalf("cert.pem not found in current directory; please create or place cert.pem and key.pem alongside the server binary")
-		}
+	// No password needed - authentication is handled by proxy JWT cookie
+	// MongoDB is only for chat history storage
+	log.Printf("Returning user info for %s (no password needed - using JWT cookie auth)", email)
 
-		// Graceful shutdown
-		go func() {
-			log.Printf("Starting HTTPS proxy server on https://localhost:%s\n", port)
-			log.Printf("Backend proxy: %s\n", libreBackend)
-			log.Printf("Frontend proxy: %s\n", libreFrontend)
-			if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("ListenAndServeTLS(): %v", err)
-			}
-		}()
-	} else {
-		// Graceful shutdown
-		go func() {
-			log.Printf("Starting HTTP proxy server on http://localhost:%s\n", port)
-			log.Printf("Backend proxy: %s\n", libreBackend)
-			log.Printf("Frontend proxy: %s\n", libreFrontend)
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("ListenAndServe(): %v", err)
-			}
-		}()
+	response := map[string]interface{}{
+		"email":    libreUserEmail,
+		"username": libreUserUsername,
+		"name":     libreUserName,
 	}
 
-	// handle shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
-	log.Println("Shutting down...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	srv.Shutdown(ctx)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
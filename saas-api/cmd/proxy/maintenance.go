@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const maintenancePath = "/internal/maintenance"
+
+const defaultMaintenanceMessage = "The chat is being upgraded and will be back shortly."
+
+var (
+	proxyMaintenanceRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "proxy_maintenance_rejected_total",
+		Help: "Requests answered with the maintenance page (or its JSON) while maintenance mode is on.",
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "proxy_maintenance_mode",
+		Help: "1 while maintenance mode is on.",
+	}, func() float64 {
+		if on, _ := maintenanceMode(); on {
+			return 1
+		}
+		return 0
+	})
+)
+
+// maintenanceOverride is the state POST /internal/maintenance switched to
+type maintenanceOverride struct {
+	Enabled bool
+	Message string // empty keeps PROXY_MAINTENANCE_MESSAGE
+}
+
+// maintenanceSwitch overrides PROXY_MAINTENANCE until the proxy restarts or a reload changes the
+// PROXY_MAINTENANCE* settings; nil follows the configuration
+var maintenanceSwitch atomic.Pointer[maintenanceOverride]
+
+// loadMaintenanceConfig reads the PROXY_MAINTENANCE* settings. PROXY_MAINTENANCE_PAGE is read here
+// too, so a reload picks up an edited page.
+func loadMaintenanceConfig() MaintenanceConfig {
+	m := MaintenanceConfig{
+		Enabled:    os.Getenv("PROXY_MAINTENANCE") == "true",
+		Message:    getEnv("PROXY_MAINTENANCE_MESSAGE", defaultMaintenanceMessage),
+		RetryAfter: getEnvInt("PROXY_MAINTENANCE_RETRY_AFTER", 300),
+		PageFile:   strings.TrimSpace(os.Getenv("PROXY_MAINTENANCE_PAGE")),
+	}
+	if m.Allow, m.err = parsePrefixes(splitList(os.Getenv("PROXY_MAINTENANCE_ALLOWLIST"))); m.err != nil {
+		return m
+	}
+	if m.PageFile != "" {
+		page, err := os.ReadFile(m.PageFile)
+		if err != nil {
+			m.err = err
+			return m
+		}
+		m.page = string(page)
+	}
+	return m
+}
+
+// maintenanceMode reports whether maintenance mode is on, and the message shown to users
+func maintenanceMode() (bool, string) {
+	s := current()
+	if s == nil {
+		return false, ""
+	}
+	on, message := s.maintenance.Enabled, s.maintenance.Message
+	if o := maintenanceSwitch.Load(); o != nil {
+		on = o.Enabled
+		if o.Message != "" {
+			message = o.Message
+		}
+	}
+	return on, message
+}
+
+// withMaintenance answers every request with 503 while maintenance mode is on, except from clients
+// in PROXY_MAINTENANCE_ALLOWLIST (admins checking the upgrade) and for the operational endpoints,
+// so health checks, metrics and switching maintenance off keep working. Admins are matched on the
+// client address clientIP vouches for, never on an X-Forwarded-For the client sent.
+func withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		on, message := maintenanceMode()
+		if !on || maintenanceExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if addr, err := clientAddr(r); err == nil && prefixesContain(current().maintenance.Allow, addr) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		proxyMaintenanceRejected.Inc()
+		respondMaintenance(w, r, message)
+	})
+}
+
+func maintenanceExempt(r *http.Request) bool {
+	switch r.URL.Path {
+	case healthzPath, metricsPath, maintenancePath, reloadPath, revokePath:
+		return true
+	}
+	return false
+}
+
+// respondMaintenance serves the maintenance page to browser navigations and a JSON 503 to API
+// calls, so the LibreChat frontend can show its own error instead of parsing HTML
+func respondMaintenance(w http.ResponseWriter, r *http.Request, message string) {
+	m := current().maintenance
+	w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if m.page != "" {
+			w.Write([]byte(m.page))
+			return
+		}
+		maintenancePage.Execute(w, map[string]string{"Message": message})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "maintenance",
+		"message":     message,
+		"retry_after": m.RetryAfter,
+	})
+}
+
+var maintenancePage = template.Must(template.New("maintenance").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Down for maintenance</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f5f6f8; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { background: #fff; padding: 2rem 2.5rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.1); max-width: 28rem; text-align: center; }
+a { display: inline-block; margin-top: 1rem; padding: .6rem 1.4rem; background: #3B82F6; color: #fff; border-radius: 6px; text-decoration: none; }
+</style>
+</head>
+<body>
+<main>
+<h1>We'll be right back</h1>
+<p>{{.Message}}</p>
+<a href="" target="_top">Try again</a>
+</main>
+</body>
+</html>
+`))
+
+// maintenanceHandler reports maintenance mode (GET) or switches it (POST {"enabled": true,
+// "message": "..."}) without editing the .env file. It requires the X-Proxy-Secret header, like
+// /internal/reload, and is disabled when PROXY_SHARED_SECRET is unset.
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.Secrets.ProxySharedSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Proxy-Secret")), []byte(cfg.Secrets.ProxySharedSecret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled *bool  `json:"enabled"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			http.Error(w, `body must be {"enabled": true|false, "message": "..."}`, http.StatusBadRequest)
+			return
+		}
+		maintenanceSwitch.Store(&maintenanceOverride{Enabled: *req.Enabled, Message: strings.TrimSpace(req.Message)})
+		logMaintenance(maintenancePath)
+	}
+
+	on, message := maintenanceMode()
+	source := "config"
+	if maintenanceSwitch.Load() != nil {
+		source = "endpoint"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"enabled": on, "message": message, "source": source})
+}
+
+// logMaintenance reports the maintenance mode now in effect after a change made through source
+func logMaintenance(source string) {
+	if on, message := maintenanceMode(); on {
+		log.Printf("🚧 Maintenance mode on (%s): %q, %d admin ranges allowed through", source, message, len(current().maintenance.Allow))
+	} else {
+		log.Printf("Maintenance mode off (%s)", source)
+	}
+}
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const metricsPath = "/metrics"
+
+var (
+	proxyRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_http_requests_total",
+		Help: "Requests handled by the proxy, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	proxyRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "proxy_http_request_duration_seconds",
+		Help:    "Time to serve a request end to end, by route pattern (websocket sessions excluded).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+
+	proxyUpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "proxy_upstream_duration_seconds",
+		Help:    "Time until upstream response headers, by upstream and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream", "outcome"})
+
+	proxyWebsocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "proxy_websocket_connections",
+		Help: "Currently open proxied websocket connections.",
+	})
+
+	proxyWebsocketTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_websocket_connections_total",
+		Help: "Websocket connection attempts, by result (opened, dial_error, upgrade_error, user_limit).",
+	}, []string{"result"})
+
+	proxyLoginsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_logins_total",
+		Help: "POST /login attempts, by result (success or failure).",
+	}, []string{"result"})
+
+	proxyMongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "proxy_mongo_command_duration_seconds",
+		Help:    "LibreChat MongoDB command durations, by command and outcome.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "outcome"})
+)
+
+// metricsHandler serves Prometheus metrics, requiring "Authorization: Bearer {METRICS_TOKEN}". They
+// reveal per-route and per-upstream traffic, so without a token they are refused unless
+// PROXY_METRICS_PUBLIC=true.
+func metricsHandler() http.Handler {
+	handler := promhttp.Handler()
+	if cfg.Secrets.MetricsToken == "" {
+		if cfg.Metrics.Public {
+			log.Printf("⚠️  /metrics is served without a token (PROXY_METRICS_PUBLIC=true)")
+		} else {
+			log.Printf("⚠️  METRICS_TOKEN not set - /metrics will refuse every request (set PROXY_METRICS_PUBLIC=true to serve it without a token)")
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := cfg.Secrets.MetricsToken; token != "" {
+			want := "Bearer " + token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if !cfg.Metrics.Public {
+			http.Error(w, "metrics require METRICS_TOKEN", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// instrumentHandler records per-route request counts and durations. Routes are labelled with the
+// router pattern that matched (e.g. "/api/*"), which keeps label cardinality bounded.
+func instrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// The pattern is only known once the router has matched the request
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		nameServerSpan(r, route)
+
+		proxyRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		if !rec.hijacked {
+			proxyRequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		}
+
+		if route == "/login" && r.Method == http.MethodPost {
+			result := "failure"
+			if rec.status < 300 {
+				result = "success"
+			}
+			proxyLoginsTotal.WithLabelValues(result).Inc()
+		}
+	})
+}
+
+// statusRecorder captures the response status while still supporting websocket hijacking and streaming
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	r.hijacked = true
+	r.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift the write deadline of event streams
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// instrumentedTransport measures upstream latency (time to response headers) for a reverse proxy,
+// records the upstream name for the access log and traces the call (see tracing.go)
+type instrumentedTransport struct {
+	upstream string
+	base     http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	setAccessLogUpstream(req.Context(), t.upstream)
+	// Cloned so the traceparent of this span is not written into the caller's request
+	req = req.Clone(req.Context())
+	ctx, span := startUpstreamSpan(req.Context(), t.upstream, req.Method+" "+t.upstream, req.Header)
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	endUpstreamSpan(span, resp, err)
+	outcome := "error"
+	if err == nil {
+		outcome = strconv.Itoa(resp.StatusCode/100) + "xx"
+	}
+	proxyUpstreamDuration.WithLabelValues(t.upstream, outcome).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// mongoCommandMonitor feeds LibreChat MongoDB command durations into proxyMongoDuration and traces
+// each command as a child of the request that issued it
+func mongoCommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: startMongoSpan,
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			proxyMongoDuration.WithLabelValues(e.CommandName, "success").Observe(e.Duration.Seconds())
+			endMongoSpan(e.RequestID, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			proxyMongoDuration.WithLabelValues(e.CommandName, "failure").Observe(e.Duration.Seconds())
+			endMongoSpan(e.RequestID, e.Failure)
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How often a cached client is re-pinged before being handed out
+const mongoHealthCheckInterval = 30 * time.Second
+
+// How long connecting (or re-pinging) may take; it is not tied to the request that triggered it,
+// since other requests wait on the same attempt
+const mongoDialTimeout = 10 * time.Second
+
+// mongoPool holds a single shared, lazily-initialized Mongo client. The driver pools connections
+// internally, so logins and credential lookups reuse connections instead of dialing per request.
+// Connecting and health checks run outside mu, one at a time: while a health check is in flight the
+// current client is still handed out, and while there is no client callers wait for the one dial.
+type mongoPool struct {
+	mu          sync.Mutex
+	client      *mongo.Client
+	lastHealthy time.Time
+	dialing     chan struct{} // closed when the in-flight connect or health check finishes
+	dialErr     error         // outcome of the last connect, for the callers that waited on it
+	closed      bool
+}
+
+var libreMongo = &mongoPool{}
+
+// Client returns a healthy client, connecting on first use and reconnecting if the last ping failed
+func (p *mongoPool) Client(ctx context.Context) (*mongo.Client, error) {
+	p.mu.Lock()
+	if p.client != nil && (time.Since(p.lastHealthy) < mongoHealthCheckInterval || p.dialing != nil) {
+		client := p.client
+		p.mu.Unlock()
+		return client, nil
+	}
+	if p.dialing != nil {
+		done := p.dialing
+		p.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.client != nil {
+			return p.client, nil
+		}
+		return nil, p.dialErr
+	}
+
+	stale := p.client
+	done := make(chan struct{})
+	p.dialing = done
+	p.mu.Unlock()
+
+	client, err := p.refresh(stale)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing = nil
+	close(done)
+	if p.closed {
+		if client != nil {
+			_ = client.Disconnect(context.Background())
+		}
+		return nil, fmt.Errorf("MongoDB pool is closed")
+	}
+	p.client, p.dialErr = client, err
+	if err != nil {
+		return nil, err
+	}
+	p.lastHealthy = time.Now()
+	return client, nil
+}
+
+// refresh re-pings the current client and returns it while it is healthy; otherwise it drops it and
+// connects a new one. It runs without p.mu held.
+func (p *mongoPool) refresh(current *mongo.Client) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoDialTimeout)
+	defer cancel()
+
+	if current != nil {
+		err := current.Ping(ctx, nil)
+		if err == nil {
+			return current, nil
+		}
+		log.Printf("MongoDB health check failed, reconnecting: %v", err)
+
+		disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = current.Disconnect(disconnectCtx)
+		cancelDisconnect()
+	}
+
+	log.Printf("Connecting to MongoDB: %s", cfg.Mongo.URI)
+	clientOptions := options.Client().
+		ApplyURI(cfg.Mongo.URI).
+		SetMaxPoolSize(uint64(cfg.Mongo.MaxPoolSize)).
+		SetMinPoolSize(uint64(cfg.Mongo.MinPoolSize)).
+		SetMaxConnIdleTime(5 * time.Minute).
+		SetServerSelectionTimeout(5 * time.Second).
+		SetMonitor(mongoCommandMonitor())
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	log.Printf("MongoDB connection pool ready")
+	return client, nil
+}
+
+// Database returns the LibreChat database on the shared client
+func (p *mongoPool) Database(ctx context.Context) (*mongo.Database, error) {
+	client, err := p.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(mongoDatabaseName()), nil
+}
+
+// Close disconnects the shared client (on shutdown)
+func (p *mongoPool) Close(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.client == nil {
+		return
+	}
+	if err := p.client.Disconnect(ctx); err != nil {
+		log.Printf("Error disconnecting from MongoDB: %v", err)
+	}
+	p.client = nil
+}
+
+// mongoDatabaseName extracts the database name from MONGO_URI (mongodb://host:port/database), defaulting to LibreChat
+func mongoDatabaseName() string {
+	dbName := "LibreChat"
+
+	parsedURI, err := url.Parse(cfg.Mongo.URI)
+	if err == nil && parsedURI.Path != "" {
+		path := strings.TrimPrefix(parsedURI.Path, "/")
+		if idx := strings.Index(path, "?"); idx > 0 {
+			path = path[:idx]
+		}
+		if path != "" && !strings.Contains(path, ":") {
+			dbName = path
+		}
+	}
+
+	return dbName
+}
@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+var proxyRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_rate_limited_total",
+	Help: "Requests rejected with 429, by limit (login or api).",
+}, []string{"limit"})
+
+// rateLimiter is a token bucket store: Allow takes one token from key's bucket, refilled at
+// perMinute/60 tokens per second up to burst. When denied it reports how long until a token is free.
+type rateLimiter interface {
+	Allow(ctx context.Context, key string, perMinute, burst int) (bool, time.Duration, error)
+}
+
+func newRateLimiter(c RateLimitConfig) (rateLimiter, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if c.RedisURL == "" {
+		log.Printf("Rate limiting: in-memory buckets (login %d/min per IP, api %d/min per user or IP)", c.LoginPerMinute, c.APIPerMinute)
+		return newMemoryLimiter(), nil
+	}
+
+	opts, err := redis.ParseURL(c.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY_RATE_LIMIT_REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		// Still usable once Redis comes back; until then requests fail open
+		log.Printf("⚠️  Rate limiting: Redis not reachable yet (%v)", err)
+	}
+	log.Printf("Rate limiting: Redis buckets (login %d/min per IP, api %d/min per user or IP)", c.LoginPerMinute, c.APIPerMinute)
+	return &redisLimiter{client: client}, nil
+}
+
+// withRateLimit throttles POST /login per client IP and LibreChat / saas-api routes per user
+// (falling back to the client IP for anonymous requests). Frontend assets are never limited.
+// Limiter errors fail open so a Redis outage does not take the gateway down.
+func withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := current()
+		if s.limiter == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var name, key string
+		var perMinute, burst int
+		switch {
+		case r.URL.Path == "/login" && r.Method == http.MethodPost:
+			name, key = "login", "ip:"+clientIP(r)
+			perMinute, burst = s.rateLimit.LoginPerMinute, s.rateLimit.LoginBurst
+		case isRateLimitedAPIPath(r.URL.Path):
+			name, key = "api", "ip:"+clientIP(r)
+			if email := requestUserEmail(r); email != "" {
+				key = "user:" + strings.ToLower(email)
+			}
+			perMinute, burst = s.rateLimit.APIPerMinute, s.rateLimit.APIBurst
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, retryAfter, err := s.limiter.Allow(r.Context(), "proxy:ratelimit:"+name+":"+key, perMinute, burst)
+		if err != nil {
+			log.Printf("WARNING: rate limiter unavailable, allowing request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed {
+			proxyRateLimitedTotal.WithLabelValues(name).Inc()
+			log.Printf("Rate limit exceeded: limit=%s key=%s path=%s", name, key, r.URL.Path)
+			if name == "login" {
+				recordLogin(r, "", "failure", loginReasonRateLimited)
+			}
+			setCORSHeaders(w, r)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isRateLimitedAPIPath(path string) bool {
+	for _, prefix := range []string{"/api/", "/oauth/", "/get-librechat-credentials", documentBridgePrefix, tokenExchangePath} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryLimiter keeps buckets in process; fine for a single proxy instance
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	sweep   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{buckets: make(map[string]*tokenBucket), sweep: time.Now()}
+}
+
+func (m *memoryLimiter) Allow(_ context.Context, key string, perMinute, burst int) (bool, time.Duration, error) {
+	ratePerSecond := float64(perMinute) / 60
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Drop buckets that have been full for a while so idle clients do not accumulate
+	if now.Sub(m.sweep) > time.Minute {
+		for k, b := range m.buckets {
+			if now.Sub(b.last) > 10*time.Minute {
+				delete(m.buckets, k)
+			}
+		}
+		m.sweep = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*ratePerSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / ratePerSecond * float64(time.Second)), nil
+}
+
+// redisLimiter shares buckets between proxy instances. The refill-and-take runs as one Lua script
+// so concurrent requests cannot overspend a bucket.
+type redisLimiter struct {
+	client *redis.Client
+}
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, perMinute, burst int) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	defer cancel()
+
+	ratePerSecond := float64(perMinute) / 60
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{key}, ratePerSecond, burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	libreRefreshPath   = "/api/auth/refresh"
+	sessionExpiredPath = "/session-expired"
+)
+
+var proxyRefreshLoopsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "proxy_refresh_loops_total",
+	Help: "Clients cut off after repeated LibreChat /api/auth/refresh failures.",
+})
+
+// refreshLoops tracks LibreChat refresh failures per client. A client whose refreshes keep failing
+// (broken or revoked session) is "tripped": its refresh calls are answered by the proxy without
+// reaching LibreChat, its cookies are cleared and page loads are sent to the session expired page,
+// until it logs in again or the cooldown passes.
+var refreshLoops = &refreshLoopDetector{clients: make(map[string]*refreshFailures)}
+
+type refreshLoopDetector struct {
+	mu      sync.Mutex
+	clients map[string]*refreshFailures
+	sweep   time.Time
+}
+
+type refreshFailures struct {
+	failures     []time.Time
+	trippedUntil time.Time
+}
+
+// refreshClientKey identifies the session a refresh belongs to: the proxy session cookie, else the
+// LibreChat refresh cookie. Users behind one NAT with the same browser are then counted apart, and a
+// broken session only trips itself. Requests with neither cookie fall back to IP and User-Agent.
+func refreshClientKey(r *http.Request) string {
+	key := "ip:" + clientIP(r) + "|" + r.UserAgent()
+	for _, name := range []string{cfg.Cookies.Session, cfg.Cookies.LibreRefresh} {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			key = "cookie:" + name + "=" + c.Value
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:12])
+}
+
+// recordFailure counts a failed refresh and reports whether the client just tripped
+func (d *refreshLoopDetector) recordFailure(key string) bool {
+	now := time.Now()
+	window := time.Duration(cfg.RefreshLoop.Window) * time.Second
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweepLocked(now, window)
+
+	f, ok := d.clients[key]
+	if !ok {
+		f = &refreshFailures{}
+		d.clients[key] = f
+	}
+
+	kept := f.failures[:0]
+	for _, t := range f.failures {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	f.failures = append(kept, now)
+
+	if len(f.failures) >= cfg.RefreshLoop.MaxFailures && now.After(f.trippedUntil) {
+		f.trippedUntil = now.Add(time.Duration(cfg.RefreshLoop.Cooldown) * time.Second)
+		f.failures = nil
+		return true
+	}
+	return false
+}
+
+func (d *refreshLoopDetector) tripped(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, ok := d.clients[key]
+	return ok && time.Now().Before(f.trippedUntil)
+}
+
+// reset forgets the client, e.g. after a successful refresh or login
+func (d *refreshLoopDetector) reset(key string) {
+	d.mu.Lock()
+	delete(d.clients, key)
+	d.mu.Unlock()
+}
+
+func (d *refreshLoopDetector) sweepLocked(now time.Time, window time.Duration) {
+	if now.Sub(d.sweep) < time.Minute {
+		return
+	}
+	for k, f := range d.clients {
+		idle := len(f.failures) == 0 || now.Sub(f.failures[len(f.failures)-1]) > window
+		if idle && now.After(f.trippedUntil) {
+			delete(d.clients, k)
+		}
+	}
+	d.sweep = now
+}
+
+// guardRefreshLoop wraps the LibreChat backend proxy. It answers refresh calls from tripped clients
+// itself and feeds refresh results from LibreChat into the detector.
+func guardRefreshLoop(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.RefreshLoop.Enabled || r.URL.Path != libreRefreshPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := refreshClientKey(r)
+		if refreshLoops.tripped(key) {
+			respondSessionExpired(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		switch {
+		case rec.status < 300:
+			refreshLoops.reset(key)
+		case rec.status == http.StatusUnauthorized || rec.status == http.StatusForbidden:
+			if refreshLoops.recordFailure(key) {
+				proxyRefreshLoopsTotal.Inc()
+				log.Printf("Refresh loop detected for %s (%s): %d failed refreshes within %ds, forcing re-login",
+					clientIP(r), requestUserEmail(r), cfg.RefreshLoop.MaxFailures, cfg.RefreshLoop.Window)
+			}
+		}
+	})
+}
+
+// redirectIfSessionExpired sends page loads from tripped clients to the session expired page.
+// It returns true when it handled the request.
+func redirectIfSessionExpired(w http.ResponseWriter, r *http.Request) bool {
+	if !cfg.RefreshLoop.Enabled || r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	if !refreshLoops.tripped(refreshClientKey(r)) {
+		return false
+	}
+	clearSessionCookies(w)
+	http.Redirect(w, r, sessionExpiredPath, http.StatusFound)
+	return true
+}
+
+// respondSessionExpired clears the session cookies and tells the caller to log in again: a redirect
+// for browser navigations, a JSON 401 (with the page in X-Relogin-URL) for the LibreChat frontend.
+func respondSessionExpired(w http.ResponseWriter, r *http.Request) {
+	clearSessionCookies(w)
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, sessionExpiredPath, http.StatusFound)
+		return
+	}
+
+	setCORSHeaders(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Relogin-URL", sessionExpiredPath)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":    "session_expired",
+		"message":  "Your chat session could not be refreshed. Please sign in again.",
+		"redirect": sessionExpiredPath,
+	})
+}
+
+// clearSessionCookies expires the proxy session and the LibreChat refresh cookies
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{cfg.Cookies.Session, cfg.Cookies.LibreRefresh, cfg.Cookies.LibreTokenProvider} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   cfg.TLS.SecureCookies,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+var sessionExpiredPage = template.Must(template.New("session-expired").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Session expired</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f5f6f8; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { background: #fff; padding: 2rem 2.5rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.1); max-width: 28rem; text-align: center; }
+a { display: inline-block; margin-top: 1rem; padding: .6rem 1.4rem; background: #3B82F6; color: #fff; border-radius: 6px; text-decoration: none; }
+</style>
+</head>
+<body>
+<main>
+<h1>Your session has expired</h1>
+<p>We could not refresh your chat session, so you have been signed out to keep your account safe.</p>
+<a href="{{.ReloginURL}}" target="_top">Sign in again</a>
+</main>
+</body>
+</html>
+`))
+
+// sessionExpiredHandler serves the re-login page and forgets the client so a fresh login starts clean
+func sessionExpiredHandler(w http.ResponseWriter, r *http.Request) {
+	refreshLoops.reset(refreshClientKey(r))
+	clearSessionCookies(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	sessionExpiredPage.Execute(w, map[string]string{"ReloginURL": cfg.RefreshLoop.ReloginURL})
+}
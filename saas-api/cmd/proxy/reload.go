@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"saas-api/cmd/bootstrap"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const reloadPath = "/internal/reload"
+
+var proxyConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_config_reloads_total",
+	Help: "Configuration reloads (SIGHUP or POST /internal/reload), by result (applied, unchanged or rejected).",
+}, []string{"result"})
+
+// reloadState is what a reload can replace while the proxy runs: the LibreChat backend instances and
+// balancer settings, the CORS allowlist, the rewrite rules, the rate limits and maintenance mode, with
+// the backend pool and rate limiter built from them. Everything else in cfg is read once at startup.
+type reloadState struct {
+	upstreamBackends []string
+	balancer         BalancerConfig
+	cors             CORSConfig
+	rewrite          RewriteConfig
+	rateLimit        RateLimitConfig
+	maintenance      MaintenanceConfig
+
+	backends         *backendPool
+	limiter          rateLimiter // nil disables rate limiting
+	stopHealthChecks context.CancelFunc
+}
+
+// reloadable is published once by Serve and swapped whole by each reload; requests already running
+// keep the state (and backend instance) they started with
+var reloadable atomic.Pointer[reloadState]
+
+// current returns the reloadable settings in effect
+func current() *reloadState {
+	return reloadable.Load()
+}
+
+// reloadMu serializes reloads triggered by SIGHUP and the admin endpoint
+var reloadMu sync.Mutex
+
+// newReloadState builds the backend pool and rate limiter for c. Backend instances and the limiter
+// are taken over from prev when their settings did not change, so health state, active connection
+// counts and token buckets survive a reload.
+func newReloadState(c *Config, prev *reloadState) (*reloadState, error) {
+	s := &reloadState{
+		upstreamBackends: c.Upstream.LibreBackends,
+		balancer:         c.Balancer,
+		cors:             c.CORS,
+		rewrite:          c.Rewrite,
+		rateLimit:        c.RateLimit,
+		maintenance:      c.Maintenance,
+	}
+
+	if prev != nil && prev.rateLimit.Enabled == s.rateLimit.Enabled && prev.rateLimit.RedisURL == s.rateLimit.RedisURL {
+		s.limiter = prev.limiter
+	} else {
+		limiter, err := newRateLimiter(s.rateLimit)
+		if err != nil {
+			return nil, err
+		}
+		s.limiter = limiter
+	}
+
+	if prev != nil && reflect.DeepEqual(prev.upstreamBackends, s.upstreamBackends) && prev.balancer == s.balancer {
+		s.backends, s.stopHealthChecks = prev.backends, prev.stopHealthChecks
+		return s, nil
+	}
+	pool, err := newBackendPool(s.upstreamBackends, s.balancer)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		pool.adopt(prev.backends)
+	}
+	healthCtx, stop := context.WithCancel(context.Background())
+	go pool.healthCheck(healthCtx, s.balancer)
+	s.backends, s.stopHealthChecks = pool, stop
+	return s, nil
+}
+
+// reloadConfig re-reads the .env file, environment and secrets and applies the reloadable settings.
+// An invalid configuration is rejected as a whole and the running one stays in effect. It returns
+// the names of the settings that changed.
+func reloadConfig() ([]string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := bootstrap.ReloadEnv(); err != nil {
+		proxyConfigReloads.WithLabelValues("rejected").Inc()
+		return nil, err
+	}
+	next, err := Load()
+	if err != nil {
+		proxyConfigReloads.WithLabelValues("rejected").Inc()
+		return nil, err
+	}
+
+	prev := current()
+	state, err := newReloadState(next, prev)
+	if err != nil {
+		proxyConfigReloads.WithLabelValues("rejected").Inc()
+		return nil, err
+	}
+	changed := prev.diff(state)
+	if len(changed) == 0 {
+		proxyConfigReloads.WithLabelValues("unchanged").Inc()
+		return nil, nil
+	}
+
+	reloadable.Store(state)
+	if state.backends != prev.backends {
+		prev.stopHealthChecks()
+		prev.backends.forgetRemoved(state.backends)
+	}
+	if !reflect.DeepEqual(prev.maintenance, state.maintenance) {
+		// An edited PROXY_MAINTENANCE* wins over the last POST /internal/maintenance
+		maintenanceSwitch.Store(nil)
+	}
+	if assets != nil && !reflect.DeepEqual(prev.rewrite.Rules, state.rewrite.Rules) {
+		// Cached bodies were rewritten with the old rules
+		assets.purge()
+	}
+	proxyConfigReloads.WithLabelValues("applied").Inc()
+	return changed, nil
+}
+
+// diff names the settings that differ between s and next
+func (s *reloadState) diff(next *reloadState) []string {
+	var changed []string
+	if !reflect.DeepEqual(s.upstreamBackends, next.upstreamBackends) {
+		changed = append(changed, "LIBRE_BACKEND")
+	}
+	if s.balancer != next.balancer {
+		changed = append(changed, "balancer")
+	}
+	if !reflect.DeepEqual(s.cors.AllowedOrigins, next.cors.AllowedOrigins) {
+		changed = append(changed, "CORS_ALLOWED_ORIGINS")
+	}
+	if !reflect.DeepEqual(s.rewrite.Rules, next.rewrite.Rules) {
+		changed = append(changed, "PROXY_REWRITE_RULES")
+	}
+	if s.rateLimit != next.rateLimit {
+		changed = append(changed, "rate limits")
+	}
+	if !reflect.DeepEqual(s.maintenance, next.maintenance) {
+		changed = append(changed, "maintenance")
+	}
+	return changed
+}
+
+// logReload reports the outcome of a reload triggered by source
+func logReload(source string, changed []string, err error) {
+	switch {
+	case err != nil:
+		log.Printf("⚠️  Config reload (%s) rejected, keeping the running configuration: %v", source, err)
+	case len(changed) == 0:
+		log.Printf("Config reload (%s): nothing changed", source)
+	default:
+		s := current()
+		log.Printf("✅ Config reload (%s) applied: %s", source, strings.Join(changed, ", "))
+		log.Printf("LibreChat backend: %s", strings.Join(s.upstreamBackends, ", "))
+		if slices.Contains(changed, "maintenance") {
+			logMaintenance(source)
+		}
+	}
+}
+
+// watchReloadSignal reloads the configuration on every SIGHUP until ctx is done
+func watchReloadSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				changed, err := reloadConfig()
+				logReload("SIGHUP", changed, err)
+			}
+		}
+	}()
+}
+
+// reloadHandler triggers a reload like SIGHUP, for deployments where signalling the process is
+// awkward. It requires the X-Proxy-Secret header, like /internal/revoke, and is disabled when
+// PROXY_SHARED_SECRET is unset.
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.Secrets.ProxySharedSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Proxy-Secret")), []byte(cfg.Secrets.ProxySharedSecret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	changed, err := reloadConfig()
+	logReload(reloadPath, changed, err)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "rejected", "error": err.Error()})
+		return
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "changed": changed})
+}
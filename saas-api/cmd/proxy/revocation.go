@@ -0,0 +1,429 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// proxySessionTTL is how long a proxy JWT (and its cookie) is valid, and so how long a revocation must be kept
+	proxySessionTTL = 6 * time.Hour
+
+	revokePath = "/internal/revoke"
+
+	// revocationCacheTTL bounds how long a lookup result is reused, so a token revoked on another
+	// proxy instance is refused within this long
+	revocationCacheTTL = 5 * time.Second
+)
+
+var proxyTokensRevokedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_tokens_revoked_total",
+	Help: "Proxy session revocations recorded, by scope (token or user).",
+}, []string{"scope"})
+
+// revocationStore is the denylist consulted by verifyToken. A single token is revoked by its jti until
+// it expires; a user is revoked by rejecting every token issued to them before a point in time.
+type revocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, email string, before time.Time) error
+	Revoked(ctx context.Context, jti, email string, issuedAt time.Time) (bool, error)
+}
+
+// revocations is built in main from cfg.Revocation; nil disables revocation checks
+var revocations revocationStore
+
+// defaultRevocationStore shares the denylist through Redis when rate limits already are, since that
+// means several proxy instances; otherwise it keeps it in memory
+func defaultRevocationStore() string {
+	if os.Getenv("PROXY_RATE_LIMIT_REDIS_URL") != "" {
+		return revocationStoreRedis
+	}
+	return revocationStoreMemory
+}
+
+func newRevocationStore(c RevocationConfig) (revocationStore, error) {
+	switch c.Store {
+	case revocationStoreOff:
+		log.Println("Session revocation: disabled (proxy JWTs stay valid until they expire)")
+		return nil, nil
+	case revocationStoreRedis:
+		opts, err := redis.ParseURL(c.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROXY_REVOCATION_REDIS_URL: %w", err)
+		}
+		client := redis.NewClient(opts)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			log.Printf("⚠️  Session revocation: Redis not reachable yet (%v)", err)
+		}
+		log.Println("Session revocation: Redis denylist")
+		return newCachedRevocations(&redisRevocations{client: client}), nil
+	case revocationStoreMongo:
+		log.Println("Session revocation: MongoDB denylist (collection " + revocationCollection + ")")
+		return newCachedRevocations(&mongoRevocations{}), nil
+	default:
+		log.Println("Session revocation: in-memory denylist (not shared between proxy instances)")
+		if cfg.RateLimit.RedisURL != "" {
+			// Rate limits are shared, so other instances serve these users too and never see revocations made here
+			log.Println("⚠️  Session revocation: PROXY_REVOCATION_STORE=memory with several proxy instances; a session revoked on one stays valid on the others. Use redis or mongo")
+		}
+		return newMemoryRevocations(), nil
+	}
+}
+
+// tokenRevoked checks a verified proxy token against the denylist. Store errors fail open, like the
+// rate limiter, so an outage does not sign everyone out.
+func tokenRevoked(claims jwt.MapClaims, email string) bool {
+	if revocations == nil {
+		return false
+	}
+	jti, _ := claims["jti"].(string)
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	revoked, err := revocations.Revoked(ctx, jti, email, issuedAt)
+	if err != nil {
+		log.Printf("WARNING: revocation store unavailable, accepting token: %v", err)
+		return false
+	}
+	return revoked
+}
+
+// revokeProxyToken denylists a proxy JWT until its expiry. Tokens that no longer verify are ignored:
+// they are already refused.
+func revokeProxyToken(ctx context.Context, tokenString string) error {
+	if revocations == nil {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(tokenString, "Bearer "), claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return cfg.Secrets.JWTSecret, nil
+	})
+	if err != nil {
+		return nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	email, _ := claims["email"].(string)
+	if jti == "" {
+		// Issued before tokens carried an ID: the only way to end it is to revoke the user
+		if email == "" {
+			return nil
+		}
+		return revokeUser(ctx, email)
+	}
+
+	expiresAt := time.Now().Add(proxySessionTTL)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+	if err := revocations.RevokeToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	proxyTokensRevokedTotal.WithLabelValues("token").Inc()
+	return nil
+}
+
+// revokeUser refuses every proxy JWT issued to email until now (password change, admin action)
+func revokeUser(ctx context.Context, email string) error {
+	if revocations == nil {
+		return nil
+	}
+	if err := revocations.RevokeUser(ctx, strings.ToLower(email), time.Now()); err != nil {
+		return err
+	}
+	proxyTokensRevokedTotal.WithLabelValues("user").Inc()
+	return nil
+}
+
+type revokeRequest struct {
+	Token string `json:"token,omitempty"` // revoke this proxy JWT
+	Email string `json:"email,omitempty"` // revoke every proxy JWT issued to this user so far
+}
+
+// revokeHandler lets saas-api (or an operator) revoke proxy sessions. It requires the X-Proxy-Secret
+// header, like the document bridge exchange, and is disabled when PROXY_SHARED_SECRET is unset.
+func revokeHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.Secrets.ProxySharedSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Proxy-Secret")), []byte(cfg.Secrets.ProxySharedSecret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req revokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Token == "") == (req.Email == "") {
+		http.Error(w, `bad request: send either "token" or "email"`, http.StatusBadRequest)
+		return
+	}
+	if revocations == nil {
+		http.Error(w, "session revocation is disabled (PROXY_REVOCATION_STORE=off)", http.StatusServiceUnavailable)
+		return
+	}
+
+	var err error
+	if req.Token != "" {
+		err = revokeProxyToken(r.Context(), req.Token)
+	} else {
+		err = revokeUser(r.Context(), req.Email)
+		log.Printf("Revocation: revoked all proxy sessions of %s", req.Email)
+	}
+	if err != nil {
+		log.Printf("Revocation: failed: %v", err)
+		http.Error(w, "revocation store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// memoryRevocations keeps the denylist in process; fine for a single proxy instance
+type memoryRevocations struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // jti -> token expiry
+	users  map[string]time.Time // email -> tokens issued before this are revoked
+	sweep  time.Time
+}
+
+func newMemoryRevocations() *memoryRevocations {
+	return &memoryRevocations{tokens: make(map[string]time.Time), users: make(map[string]time.Time), sweep: time.Now()}
+}
+
+func (m *memoryRevocations) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *memoryRevocations) RevokeUser(_ context.Context, email string, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	m.users[email] = before
+	return nil
+}
+
+func (m *memoryRevocations) Revoked(_ context.Context, jti, email string, issuedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	before, ok := m.users[strings.ToLower(email)]
+	return ok && !issuedAt.After(before), nil
+}
+
+// prune drops entries whose tokens have expired anyway; callers hold mu
+func (m *memoryRevocations) prune() {
+	now := time.Now()
+	if now.Sub(m.sweep) < time.Minute {
+		return
+	}
+	for jti, expiresAt := range m.tokens {
+		if now.After(expiresAt) {
+			delete(m.tokens, jti)
+		}
+	}
+	for email, before := range m.users {
+		if now.Sub(before) > proxySessionTTL {
+			delete(m.users, email)
+		}
+	}
+	m.sweep = now
+}
+
+// redisRevocations shares the denylist between proxy instances. Keys expire with the tokens they cover.
+type redisRevocations struct {
+	client *redis.Client
+}
+
+func (s *redisRevocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, "proxy:revoked:token:"+jti, 1, ttl).Err()
+}
+
+func (s *redisRevocations) RevokeUser(ctx context.Context, email string, before time.Time) error {
+	return s.client.Set(ctx, "proxy:revoked:user:"+email, before.Unix(), proxySessionTTL).Err()
+}
+
+func (s *redisRevocations) Revoked(ctx context.Context, jti, email string, issuedAt time.Time) (bool, error) {
+	values, err := s.client.MGet(ctx, "proxy:revoked:token:"+jti, "proxy:revoked:user:"+strings.ToLower(email)).Result()
+	if err != nil {
+		return false, err
+	}
+	if values[0] != nil && jti != "" {
+		return true, nil
+	}
+	if before, ok := values[1].(string); ok {
+		unix, err := strconv.ParseInt(before, 10, 64)
+		return err == nil && issuedAt.Unix() <= unix, nil
+	}
+	return false, nil
+}
+
+const revocationCollection = "proxy_revocations"
+
+// mongoRevocations keeps the denylist in the LibreChat database, for deployments without Redis.
+// A TTL index on expiresAt lets Mongo delete entries once the tokens they cover have expired.
+type mongoRevocations struct {
+	indexOnce sync.Once
+}
+
+type revocationDoc struct {
+	ID        string    `bson:"_id"` // "token:<jti>" or "user:<email>"
+	Before    time.Time `bson:"before,omitempty"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+func (s *mongoRevocations) collection(ctx context.Context) (*mongo.Collection, error) {
+	db, err := libreMongo.Database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collection := db.Collection(revocationCollection)
+	s.indexOnce.Do(func() {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.M{"expiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			log.Printf("Session revocation: failed to create TTL index on %s: %v", revocationCollection, err)
+		}
+	})
+	return collection, nil
+}
+
+func (s *mongoRevocations) upsert(ctx context.Context, doc revocationDoc) error {
+	collection, err := s.collection(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": doc.ID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *mongoRevocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return s.upsert(ctx, revocationDoc{ID: "token:" + jti, ExpiresAt: expiresAt})
+}
+
+func (s *mongoRevocations) RevokeUser(ctx context.Context, email string, before time.Time) error {
+	return s.upsert(ctx, revocationDoc{ID: "user:" + email, Before: before, ExpiresAt: before.Add(proxySessionTTL)})
+}
+
+func (s *mongoRevocations) Revoked(ctx context.Context, jti, email string, issuedAt time.Time) (bool, error) {
+	collection, err := s.collection(ctx)
+	if err != nil {
+		return false, err
+	}
+	ids := bson.A{"user:" + strings.ToLower(email)}
+	if jti != "" {
+		ids = append(ids, "token:"+jti)
+	}
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return false, err
+	}
+	var docs []revocationDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return false, err
+	}
+	for _, doc := range docs {
+		if strings.HasPrefix(doc.ID, "token:") || !issuedAt.After(doc.Before) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cachedRevocations remembers lookups for revocationCacheTTL. verifyToken runs several times per
+// request (rate limiting, access log, the handler), and this keeps that to one store round trip.
+type cachedRevocations struct {
+	store revocationStore
+
+	mu      sync.Mutex
+	entries map[string]cachedRevocation
+}
+
+type cachedRevocation struct {
+	revoked bool
+	at      time.Time
+}
+
+func newCachedRevocations(store revocationStore) *cachedRevocations {
+	return &cachedRevocations{store: store, entries: make(map[string]cachedRevocation)}
+}
+
+func (c *cachedRevocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	defer c.flush()
+	return c.store.RevokeToken(ctx, jti, expiresAt)
+}
+
+func (c *cachedRevocations) RevokeUser(ctx context.Context, email string, before time.Time) error {
+	defer c.flush()
+	return c.store.RevokeUser(ctx, email, before)
+}
+
+func (c *cachedRevocations) Revoked(ctx context.Context, jti, email string, issuedAt time.Time) (bool, error) {
+	key := jti + "|" + strings.ToLower(email) + "|" + strconv.FormatInt(issuedAt.Unix(), 10)
+	now := time.Now()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Sub(entry.at) < revocationCacheTTL {
+		c.mu.Unlock()
+		return entry.revoked, nil
+	}
+	c.mu.Unlock()
+
+	revoked, err := c.store.Revoked(ctx, jti, email, issuedAt)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) > 10000 {
+		c.entries = make(map[string]cachedRevocation)
+	}
+	c.entries[key] = cachedRevocation{revoked: revoked, at: now}
+	c.mu.Unlock()
+	return revoked, nil
+}
+
+// flush forgets cached lookups so revocations made through this instance apply immediately
+func (c *cachedRevocations) flush() {
+	c.mu.Lock()
+	c.entries = make(map[string]cachedRevocation)
+	c.mu.Unlock()
+}
@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// rewriteChunk is how much of a response body is read from the frontend at a time
+const rewriteChunk = 32 << 10
+
+// replacement is one literal substitution applied to a response body
+type replacement struct {
+	old, new []byte
+}
+
+// loadRewriteConfig reads PROXY_REWRITE_RULES. Rules are separated by ";" or newlines, and each one
+// is "content-types|pattern|replacement", e.g.
+//
+//	text/html,application/javascript|ws://localhost:3090/|wss://chat.example.com/
+//
+// An empty content-types field means text/html. Unset, the LibreChat frontend's websocket URLs
+// (the Vite dev server's HMR socket) are pointed at the proxy; set to "" to rewrite nothing.
+// LibreChat is built with base '/proxy/', so asset and router URLs never need rewriting.
+func loadRewriteConfig(c *Config) RewriteConfig {
+	spec, ok := os.LookupEnv("PROXY_REWRITE_RULES")
+	if !ok {
+		return RewriteConfig{Rules: defaultRewriteRules(c)}
+	}
+	rules, err := parseRewriteRules(spec)
+	return RewriteConfig{Rules: rules, err: err}
+}
+
+func defaultRewriteRules(c *Config) []RewriteRule {
+	frontend, err := url.Parse(c.Upstream.LibreFrontend)
+	proxyHost := "localhost:" + c.Server.Port
+	if err != nil || frontend.Host == "" || frontend.Host == proxyHost {
+		return nil
+	}
+	var rules []RewriteRule
+	for _, scheme := range []string{"ws://", "wss://"} {
+		rules = append(rules, RewriteRule{
+			ContentTypes: []string{"text/html"},
+			Pattern:      scheme + frontend.Host + "/",
+			Replacement:  scheme + proxyHost + "/",
+		})
+	}
+	return rules
+}
+
+func parseRewriteRules(spec string) ([]RewriteRule, error) {
+	var rules []RewriteRule
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("rule %q must be content-types|pattern|replacement", line)
+		}
+		rule := RewriteRule{
+			ContentTypes: splitList(strings.ToLower(parts[0])),
+			Pattern:      strings.TrimSpace(parts[1]),
+			Replacement:  strings.TrimSpace(parts[2]),
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %q has an empty pattern", line)
+		}
+		if len(rule.ContentTypes) == 0 {
+			rule.ContentTypes = []string{"text/html"}
+		}
+		for _, contentType := range rule.ContentTypes {
+			if contentType != "*" && !strings.Contains(contentType, "/") {
+				return nil, fmt.Errorf("rule %q: content type %q must be type/subtype, type/* or *", line, contentType)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchesContentType reports whether mediaType (lowercase, without parameters) is one the rule applies to
+func (r RewriteRule) matchesContentType(mediaType string) bool {
+	for _, contentType := range r.ContentTypes {
+		if contentType == "*" || contentType == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(contentType, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseReplacements returns the replacements of every rule matching the response's content type
+func responseReplacements(resp *http.Response, rules []RewriteRule) []replacement {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	var replacements []replacement
+	for _, rule := range rules {
+		if rule.matchesContentType(mediaType) {
+			replacements = append(replacements, replacement{old: []byte(rule.Pattern), new: []byte(rule.Replacement)})
+		}
+	}
+	return replacements
+}
+
+// rewriteResponse applies the matching rules to a response body while it streams to the client, so
+// memory use does not grow with the body size. gzip bodies are decoded, rewritten and compressed
+// again; other encodings cannot be rewritten and pass through untouched. The rewritten length is
+// unknown up front, so Content-Length is dropped and the body is sent chunked.
+func rewriteResponse(resp *http.Response, rules []RewriteRule) {
+	if len(rules) == 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified || resp.Request.Method == http.MethodHead {
+		return
+	}
+	replacements := responseReplacements(resp, rules)
+	if len(replacements) == 0 {
+		return
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		resp.Body = &streamRewriter{src: resp.Body, closer: resp.Body, replacements: replacements, keep: longestReplacement(replacements) - 1}
+	case "gzip":
+		resp.Body = gzipRewriter(resp.Body, replacements)
+	default:
+		log.Printf("Rewrite: skipping %s with Content-Encoding %q", resp.Request.URL.Path, encoding)
+		return
+	}
+
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+}
+
+// gzipRewriter decompresses body, rewrites it and compresses it again, all while streaming
+func gzipRewriter(body io.ReadCloser, replacements []replacement) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		rewriter := &streamRewriter{src: zr, replacements: replacements, keep: longestReplacement(replacements) - 1}
+		zw := gzip.NewWriter(pw)
+		_, err = io.Copy(zw, rewriter)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		// A client that went away closes the pipe, which ends the copy with an error here
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func longestReplacement(replacements []replacement) int {
+	longest := 0
+	for _, r := range replacements {
+		longest = max(longest, len(r.old))
+	}
+	return longest
+}
+
+// streamRewriter applies literal replacements to a stream. Up to keep bytes (the longest pattern
+// minus one) are held back between reads, so a match split across two reads is still found.
+type streamRewriter struct {
+	src          io.Reader
+	closer       io.Closer
+	replacements []replacement
+	keep         int
+
+	buf     []byte // Read from src, not yet rewritten
+	out     bytes.Buffer
+	srcDone bool
+	err     error
+}
+
+func (s *streamRewriter) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if s.srcDone {
+			if s.err != nil {
+				return 0, s.err
+			}
+			return 0, io.EOF
+		}
+		s.fill()
+	}
+	return s.out.Read(p)
+}
+
+func (s *streamRewriter) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// fill reads one chunk from src and moves everything that can no longer be part of a match to out
+func (s *streamRewriter) fill() {
+	chunk := make([]byte, rewriteChunk)
+	n, err := s.src.Read(chunk)
+	s.buf = append(s.buf, chunk[:n]...)
+	if err != nil {
+		s.srcDone = true
+		if err != io.EOF {
+			s.err = err
+		}
+	}
+
+	// A match starting before limit is complete; one starting after it may continue in the next read
+	limit := len(s.buf) - s.keep
+	if s.srcDone {
+		limit = len(s.buf)
+	}
+
+	i := 0
+	for i < limit {
+		at, r := s.nextMatch(s.buf[i:])
+		if r == nil || i+at >= limit {
+			s.out.Write(s.buf[i:limit])
+			i = limit
+			break
+		}
+		s.out.Write(s.buf[i : i+at])
+		s.out.Write(r.new)
+		i += at + len(r.old)
+	}
+	s.buf = append(s.buf[:0], s.buf[i:]...)
+}
+
+// nextMatch finds the earliest replacement in b, preferring the longest pattern at the same offset
+func (s *streamRewriter) nextMatch(b []byte) (int, *replacement) {
+	best, bestAt := (*replacement)(nil), -1
+	for i := range s.replacements {
+		r := &s.replacements[i]
+		at := bytes.Index(b, r.old)
+		if at < 0 {
+			continue
+		}
+		if bestAt < 0 || at < bestAt || (at == bestAt && len(r.old) > len(best.old)) {
+			best, bestAt = r, at
+		}
+	}
+	return bestAt, best
+}
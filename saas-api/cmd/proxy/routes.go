@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// upstreams are the reverse proxies Serve builds for the routes to hand requests to
+type upstreams struct {
+	libreBackend    http.Handler // LibreChat API, balanced, with refresh loop guard and event streams
+	balancedBackend http.Handler // LibreChat API, balanced only (OAuth redirects)
+	frontend        http.Handler // LibreChat frontend
+	cachedFrontend  http.Handler // frontend behind the asset cache (see assetcache.go)
+	saasAPI         http.Handler
+	frontendTarget  *url.URL // websockets to the frontend are dialled here
+}
+
+// newRouter registers the proxy's routes behind its middleware. Middleware runs for every request,
+// matched or not, in the order listed. Routes match most specific first (/api/v1/* before /api/*
+// before /*), whatever the order they are registered in.
+func newRouter(u *upstreams) http.Handler {
+	r := chi.NewRouter()
+	r.Use(withTracing, withAccessLog, withIPFilter, withSecurityHeaders, withCORS, withMaintenance, withCSRF,
+		withRateLimit, withRequestLimits, instrumentHandler, withCleanPath)
+
+	// Sessions: sign in (GET serves the login page), token exchange, sign out and the refresh loop's
+	// re-login page
+	r.Handle("/login", methods{
+		http.MethodGet:     u.frontend.ServeHTTP,
+		http.MethodHead:    u.frontend.ServeHTTP,
+		http.MethodPost:    loginHandler,
+		http.MethodOptions: loginHandler,
+	})
+	r.Handle(tokenExchangePath, methods{http.MethodPost: tokenExchangeHandler, http.MethodOptions: tokenExchangeHandler})
+	r.Handle(logoutPath, methods{http.MethodPost: logoutHandler, http.MethodOptions: logoutHandler})
+	r.Handle(sessionExpiredPath, methods{http.MethodGet: sessionExpiredHandler})
+	r.Handle("/get-librechat-credentials", methods{http.MethodGet: libreCredentialsHandler, http.MethodOptions: libreCredentialsHandler})
+
+	// Operations: the secret-protected admin endpoints, health checks and metrics
+	r.Handle(revokePath, methods{http.MethodPost: revokeHandler})
+	r.Handle(reloadPath, methods{http.MethodPost: reloadHandler})
+	r.Handle(maintenancePath, methods{http.MethodGet: maintenanceHandler, http.MethodPost: maintenanceHandler})
+	r.Handle(healthzPath, methods{http.MethodGet: healthzHandler, http.MethodHead: healthzHandler})
+	if cfg.Metrics.Enabled {
+		r.Handle(metricsPath, methods{http.MethodGet: metricsHandler().ServeHTTP})
+	}
+
+	// saas-api: its REST API, static files and the cookie-authenticated document bridge
+	r.Handle("/api/v1/*", saasAPIHandler(u.saasAPI))
+	r.Handle("/proxy/api/v1/*", stripProxyPrefix(saasAPIHandler(u.saasAPI)))
+	static := staticHandler(u.saasAPI)
+	r.Handle("/static/*", methods{http.MethodGet: static, http.MethodHead: static, http.MethodOptions: static})
+	r.Handle(documentBridgePrefix+"*", methods{
+		http.MethodGet:     documentBridgeHandler,
+		http.MethodHead:    documentBridgeHandler,
+		http.MethodOptions: documentBridgeHandler,
+	})
+
+	// LibreChat backend: its API and websockets, and OAuth
+	r.Handle("/api/*", backendHandler(u))
+	r.Handle("/proxy/api/*", stripProxyPrefix(backendHandler(u)))
+	r.Handle("/oauth/*", u.balancedBackend)
+	r.Handle("/proxy/oauth/*", stripProxyPrefix(backendHandler(u)))
+
+	// LibreChat frontend: the chat app under /proxy/, the Vite dev server's own paths, and every other
+	// page for direct access without the /proxy prefix
+	r.Handle("/proxy", methods{http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/proxy/", http.StatusMovedPermanently)
+	}})
+	r.Handle("/proxy/*", chatAppHandler(u))
+	for _, pattern := range []string{"/@vite/*", "/@react-refresh", "/src/*", "/node_modules/*", "/@fs/*"} {
+		r.Handle(pattern, viteHandler(u))
+	}
+	root := rootFrontendHandler(u)
+	r.Handle("/*", methods{http.MethodGet: root, http.MethodHead: root})
+
+	return r
+}
+
+// methods maps the HTTP methods a route serves to their handlers; any other method gets 405 with an
+// Allow header. A route registered for one method only (r.Get) would instead hand the others to
+// whatever wildcard route serves them, such as GET on the /* catch-all.
+type methods map[string]http.HandlerFunc
+
+func (m methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := m[r.Method]; ok {
+		h(w, r)
+		return
+	}
+	allow := make([]string, 0, len(m))
+	for method := range m {
+		allow = append(allow, method)
+	}
+	sort.Strings(allow)
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// withCleanPath redirects paths with "//", "." or ".." segments to their clean form, as
+// http.ServeMux does, so a path cannot reach a route its clean form would not
+func withCleanPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			if clean := cleanPath(r.URL.Path); clean != r.URL.Path {
+				target := *r.URL
+				target.Path, target.RawPath = clean, ""
+				http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+				return
+			}
+		}
+		// Match on the decoded path, like ServeMux, rather than the escaped one
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			rctx.RoutePath = r.URL.Path
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cleanPath is path.Clean rooted at "/", keeping a trailing slash
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Connection"), "Upgrade") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// stripProxyPrefix serves /proxy/api/... and /proxy/oauth/... as /api/... and /oauth/...
+func stripProxyPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stripPrefixPath(r, "/proxy")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// saasAPIHandler forwards to saas-api; preflights are answered by the proxy
+func saasAPIHandler(saasAPI http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w, r)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		saasAPI.ServeHTTP(w, r)
+	}
+}
+
+// staticHandler forwards /static/* to saas-api, token query parameter included. The query carries
+// the access token, so it is never logged; withAccessLog records the method and path.
+func staticHandler(saasAPI http.Handler) http.HandlerFunc {
+	return saasAPIHandler(saasAPI)
+}
+
+// backendHandler forwards LibreChat API requests and websockets to a backend instance
+func backendHandler(u *upstreams) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocketUpgrade(r) {
+			proxyBackendWebsocket(w, r, extractEmailFromRequest(r))
+			return
+		}
+		u.libreBackend.ServeHTTP(w, r)
+	}
+}
+
+// chatAppHandler serves the LibreChat app under /proxy/. The prefix is kept: LibreChat is built with
+// base '/proxy/' and expects paths like /proxy/c/new.
+func chatAppHandler(u *upstreams) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			setCORSHeaders(w, r)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if isWebsocketUpgrade(r) {
+			proxyWebsocket(w, r, u.frontendTarget, extractEmailFromRequest(r))
+			return
+		}
+		// Stop a client stuck in a refresh loop from reloading the chat app over and over
+		if redirectIfSessionExpired(w, r) {
+			return
+		}
+		u.cachedFrontend.ServeHTTP(w, r)
+	}
+}
+
+// viteHandler proxies the Vite dev server's paths (/@vite/client, /src/, ...) that the app requests
+// from the root, without the /proxy/ prefix
+func viteHandler(u *upstreams) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocketUpgrade(r) {
+			proxyWebsocket(w, r, u.frontendTarget, extractEmailFromRequest(r))
+			return
+		}
+		u.frontend.ServeHTTP(w, r)
+	}
+}
+
+// rootFrontendHandler proxies every path no other route claims to the frontend. Vite's HMR socket
+// may connect to the root, with an upgrade or with just its token query parameter.
+func rootFrontendHandler(u *upstreams) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocketUpgrade(r) || (r.Method == http.MethodGet && r.URL.Query().Get("token") != "") {
+			proxyWebsocket(w, r, u.frontendTarget, extractEmailFromRequest(r))
+			return
+		}
+		u.cachedFrontend.ServeHTTP(w, r)
+	}
+}
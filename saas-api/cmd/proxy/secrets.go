@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// errSecretNotFound is returned by providers when a secret does not exist (as opposed to a lookup failure)
+var errSecretNotFound = errors.New("secret not found")
+
+// SecretsProvider resolves secrets by name (e.g. "JWT_SECRET")
+type SecretsProvider interface {
+	Name() string
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// envSecrets reads secrets from environment variables (including values loaded from .env)
+type envSecrets struct{}
+
+func (envSecrets) Name() string { return "env" }
+
+func (envSecrets) GetSecret(_ context.Context, name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	return "", errSecretNotFound
+}
+
+// fileSecrets reads one file per secret from a directory (Docker/Kubernetes mounted secrets)
+type fileSecrets struct {
+	dir string
+}
+
+func (p fileSecrets) Name() string { return "file:" + p.dir }
+
+func (p fileSecrets) GetSecret(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if os.IsNotExist(err) {
+		return "", errSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ssmSecrets reads SecureString parameters from AWS SSM Parameter Store ({prefix}{name})
+type ssmSecrets struct {
+	client *ssm.Client
+	prefix string
+}
+
+func (p ssmSecrets) Name() string { return "aws-ssm:" + p.prefix }
+
+func (p ssmSecrets) GetSecret(ctx context.Context, name string) (string, error) {
+	paramName := p.prefix + name
+	withDecryption := true
+	out, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           &paramName,
+		WithDecryption: &withDecryption,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "ParameterNotFound") {
+			return "", errSecretNotFound
+		}
+		return "", err
+	}
+	if out.Parameter == nil || out.Parameter.Value == nil {
+		return "", errSecretNotFound
+	}
+	return *out.Parameter.Value, nil
+}
+
+// secretsManagerSecrets reads secret strings from AWS Secrets Manager ({prefix}{name})
+type secretsManagerSecrets struct {
+	client *secretsmanager.Client
+	prefix string
+}
+
+func (p secretsManagerSecrets) Name() string { return "aws-secretsmanager:" + p.prefix }
+
+func (p secretsManagerSecrets) GetSecret(ctx context.Context, name string) (string, error) {
+	secretID := p.prefix + name
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &secretID})
+	if err != nil {
+		if strings.Contains(err.Error(), "ResourceNotFoundException") {
+			return "", errSecretNotFound
+		}
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", errSecretNotFound
+	}
+	return *out.SecretString, nil
+}
+
+// newSecretsProvider builds the provider selected by SECRETS_PROVIDER (env | file | aws-ssm | aws-secretsmanager)
+func newSecretsProvider(ctx context.Context) (SecretsProvider, error) {
+	kind := os.Getenv("SECRETS_PROVIDER")
+	prefix := os.Getenv("SECRETS_PREFIX")
+
+	switch kind {
+	case "", "env":
+		return envSecrets{}, nil
+	case "file":
+		dir := os.Getenv("SECRETS_DIR")
+		if dir == "" {
+			dir = "/run/secrets"
+		}
+		return fileSecrets{dir: dir}, nil
+	case "aws-ssm", "aws-secretsmanager":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		if kind == "aws-ssm" {
+			return ssmSecrets{client: ssm.NewFromConfig(awsCfg), prefix: prefix}, nil
+		}
+		return secretsManagerSecrets{client: secretsmanager.NewFromConfig(awsCfg), prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q (expected env, file, aws-ssm or aws-secretsmanager)", kind)
+	}
+}
+
+// resolveSecret returns the first of names found in the provider, falling back to the environment
+// so individual values can still be overridden locally
+func resolveSecret(ctx context.Context, provider SecretsProvider, names ...string) (string, error) {
+	for _, name := range names {
+		value, err := provider.GetSecret(ctx, name)
+		if err == nil && value != "" {
+			return value, nil
+		}
+		if err != nil && !errors.Is(err, errSecretNotFound) {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if provider.Name() != "env" {
+		for _, name := range names {
+			if value := os.Getenv(name); value != "" {
+				return value, nil
+			}
+		}
+	}
+	return "", errSecretNotFound
+}
+
+// loadSecrets resolves all proxy secrets and fails if a required one is missing or any lookup fails.
+// There are deliberately no built-in defaults: a proxy signing cookies with a known key is worse than one that does not start.
+func loadSecrets(ctx context.Context) (SecretsConfig, error) {
+	var secrets SecretsConfig
+
+	provider, err := newSecretsProvider(ctx)
+	if err != nil {
+		return secrets, fmt.Errorf("secrets: %w", err)
+	}
+	log.Printf("Secrets: using provider %s", provider.Name())
+
+	required := []struct {
+		target *[]byte
+		names  []string // first match wins; later names are LibreChat's own variable names
+	}{
+		{&secrets.JWTSecret, []string{"JWT_SECRET"}},
+		{&secrets.LibreJWTSecret, []string{"LIBRE_JWT_SECRET", "JWT_SECRET"}},
+		{&secrets.LibreJWTRefreshSecret, []string{"LIBRE_JWT_REFRESH_SECRET", "JWT_REFRESH_SECRET"}},
+	}
+
+	var missing []string
+	for _, secret := range required {
+		value, err := resolveSecret(ctx, provider, secret.names...)
+		if err != nil {
+			if !errors.Is(err, errSecretNotFound) {
+				return secrets, fmt.Errorf("secrets: failed to read %v: %w", secret.names, err)
+			}
+			missing = append(missing, secret.names[0])
+			continue
+		}
+		*secret.target = []byte(value)
+		log.Printf("%s: ✅ Set (length: %d)", secret.names[0], len(value))
+	}
+
+	if len(missing) > 0 {
+		return secrets, fmt.Errorf("secrets: required secrets missing: %s", strings.Join(missing, ", "))
+	}
+
+	// Optional: a secret that does not exist leaves its feature off, but a provider that cannot be
+	// read fails the load, so an outage does not quietly turn off the document bridge or revocation
+	optional := []struct {
+		name string
+		set  func(string)
+	}{
+		{"PROXY_SHARED_SECRET", func(v string) { secrets.ProxySharedSecret = v }},
+		{"SAAS_JWT_SECRET", func(v string) { secrets.SaaSJWTSecret = []byte(v) }},
+		{"METRICS_TOKEN", func(v string) { secrets.MetricsToken = v }},
+		{"PROXY_SIGNING_SECRET", func(v string) { secrets.SigningSecret = []byte(v) }},
+	}
+	for _, secret := range optional {
+		value, err := resolveSecret(ctx, provider, secret.name)
+		if err != nil {
+			if !errors.Is(err, errSecretNotFound) {
+				return secrets, fmt.Errorf("secrets: failed to read %s: %w", secret.name, err)
+			}
+			if secret.name == "PROXY_SHARED_SECRET" {
+				log.Printf("PROXY_SHARED_SECRET not set - /proxy/files/* will be unavailable")
+			}
+			continue
+		}
+		secret.set(value)
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Security headers owned by the proxy. LibreChat's and saas-api's own values are dropped so each
+// header is sent once, with the proxy's value.
+var managedSecurityHeaders = []string{
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Strict-Transport-Security",
+}
+
+// loadSecurityHeadersConfig reads the PROXY_CSP*, PROXY_FRAME_ANCESTORS, PROXY_REFERRER_POLICY,
+// PROXY_HSTS_* and PROXY_SECURITY_HEADER_OVERRIDES settings. Frame ancestors default to the proxy's
+// own origin plus CORS_ALLOWED_ORIGINS, the apps allowed to embed the chat in an iframe.
+func loadSecurityHeadersConfig(c *Config) SecurityHeadersConfig {
+	h := SecurityHeadersConfig{
+		Enabled:               os.Getenv("PROXY_SECURITY_HEADERS") != "false",
+		CSP:                   strings.TrimSpace(os.Getenv("PROXY_CSP")),
+		CSPReportOnly:         os.Getenv("PROXY_CSP_REPORT_ONLY") == "true",
+		FrameAncestors:        strings.Fields(os.Getenv("PROXY_FRAME_ANCESTORS")),
+		ReferrerPolicy:        getEnv("PROXY_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		HSTSMaxAge:            getEnvCount("PROXY_HSTS_MAX_AGE", 31536000),
+		HSTSIncludeSubdomains: os.Getenv("PROXY_HSTS_INCLUDE_SUBDOMAINS") == "true",
+	}
+	if len(h.FrameAncestors) == 0 {
+		h.FrameAncestors = append([]string{"'self'"}, c.CORS.AllowedOrigins...)
+	}
+	h.Overrides, h.err = parseHeaderOverrides(os.Getenv("PROXY_SECURITY_HEADER_OVERRIDES"))
+	return h
+}
+
+// parseHeaderOverrides reads rules separated by ";" or newlines, each "path-prefix|Header-Name|value".
+// An empty value removes the header on that route.
+func parseHeaderOverrides(spec string) ([]HeaderOverride, error) {
+	var overrides []HeaderOverride
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("override %q must be path-prefix|Header-Name|value", line)
+		}
+		override := HeaderOverride{
+			PathPrefix: strings.TrimSpace(parts[0]),
+			Header:     http.CanonicalHeaderKey(strings.TrimSpace(parts[1])),
+			Value:      strings.TrimSpace(parts[2]),
+		}
+		if !strings.HasPrefix(override.PathPrefix, "/") {
+			return nil, fmt.Errorf("override %q: path prefix must start with /", line)
+		}
+		if override.Header == "" || strings.ContainsAny(override.Header, " :,;\t") {
+			return nil, fmt.Errorf("override %q: %q is not a valid header name", line, override.Header)
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
+
+// withSecurityHeaders adds the proxy's security headers to every response. frame-ancestors is always
+// enforced, even in report-only mode, because browsers ignore it in a report-only policy.
+func withSecurityHeaders(next http.Handler) http.Handler {
+	if !cfg.SecurityHeaders.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range securityHeaders(r) {
+			w.Header().Set(name, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// securityHeaders returns the headers for r after applying the longest matching per-route overrides
+func securityHeaders(r *http.Request) map[string]string {
+	h := cfg.SecurityHeaders
+	headers := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy":        h.ReferrerPolicy,
+	}
+
+	frameAncestors := "frame-ancestors " + strings.Join(h.FrameAncestors, " ")
+	switch {
+	case h.CSP == "":
+		headers["Content-Security-Policy"] = frameAncestors
+	case h.CSPReportOnly:
+		headers["Content-Security-Policy"] = frameAncestors
+		headers["Content-Security-Policy-Report-Only"] = h.CSP
+	case strings.Contains(h.CSP, "frame-ancestors"):
+		headers["Content-Security-Policy"] = h.CSP
+	default:
+		headers["Content-Security-Policy"] = strings.TrimSuffix(h.CSP, ";") + "; " + frameAncestors
+	}
+
+	// X-Frame-Options cannot name other origins; older browsers only get it when framing is same-origin
+	if len(h.FrameAncestors) == 1 && h.FrameAncestors[0] == "'self'" {
+		headers["X-Frame-Options"] = "SAMEORIGIN"
+	} else if len(h.FrameAncestors) == 1 && h.FrameAncestors[0] == "'none'" {
+		headers["X-Frame-Options"] = "DENY"
+	}
+
+	if h.HSTSMaxAge > 0 && isSecureRequest(r) {
+		hsts := fmt.Sprintf("max-age=%d", h.HSTSMaxAge)
+		if h.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+
+	matched := make(map[string]int) // Header -> length of the prefix that set it
+	for _, override := range h.Overrides {
+		if !strings.HasPrefix(r.URL.Path, override.PathPrefix) || len(override.PathPrefix) < matched[override.Header] {
+			continue
+		}
+		matched[override.Header] = len(override.PathPrefix)
+		headers[override.Header] = override.Value
+	}
+
+	for name, value := range headers {
+		if value == "" {
+			delete(headers, name)
+		}
+	}
+	return headers
+}
+
+// stripUpstreamSecurityHeaders is a ReverseProxy ModifyResponse step: without it the upstream's
+// values would be appended to the proxy's, and browsers enforce every CSP they receive
+func stripUpstreamSecurityHeaders(resp *http.Response) {
+	if !cfg.SecurityHeaders.Enabled {
+		return
+	}
+	for _, name := range managedSecurityHeaders {
+		resp.Header.Del(name)
+	}
+	for _, override := range cfg.SecurityHeaders.Overrides {
+		resp.Header.Del(override.Header)
+	}
+}
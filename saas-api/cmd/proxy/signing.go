@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With PROXY_SIGNING_SECRET set, every request and websocket handshake the proxy sends to the
+// LibreChat backend is signed:
+//
+//	X-Proxy-Timestamp: <unix seconds>
+//	X-Proxy-Signature: v1=<hex HMAC-SHA256 of the lines below, joined with "\n">
+//
+//	<timestamp>
+//	<method>
+//	<request URI, path and query as sent>
+//	<X-Authenticated-User>
+//	<X-User-Org>
+//	<X-User-Role>
+//	<X-User-Super-Admin>
+//
+// An absent header signs as an empty line. LibreChat's verifyProxySignature middleware recomputes
+// the signature, so a client that reaches the backend directly cannot pose as a user by sending
+// X-Authenticated-User itself.
+const (
+	signatureHeader          = "X-Proxy-Signature"
+	signatureTimestampHeader = "X-Proxy-Timestamp"
+)
+
+// identityHeaders are the headers the proxy vouches for, in signing order
+var identityHeaders = []string{"X-Authenticated-User", userOrgHeader, userRoleHeader, userSuperAdminHeader}
+
+// signBackendRequest signs a method request for requestURI once header holds its final identity
+// headers. Signature headers sent by the client are always dropped.
+func signBackendRequest(header http.Header, method, requestURI string) {
+	header.Del(signatureHeader)
+	header.Del(signatureTimestampHeader)
+	if len(cfg.Secrets.SigningSecret) == 0 {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header.Set(signatureTimestampHeader, timestamp)
+	header.Set(signatureHeader, "v1="+requestSignature(cfg.Secrets.SigningSecret, timestamp, method, requestURI, header))
+}
+
+func requestSignature(secret []byte, timestamp, method, requestURI string, header http.Header) string {
+	lines := []string{timestamp, strings.ToUpper(method), requestURI}
+	for _, name := range identityHeaders {
+		lines = append(lines, header.Get(name))
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
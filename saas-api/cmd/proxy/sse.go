@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const eventStreamType = "text/event-stream"
+
+var proxyEventStreams = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "proxy_event_streams",
+	Help: "Currently open Server-Sent Events responses from LibreChat.",
+})
+
+// streamEvents lets LibreChat's Server-Sent Events (streamed chat tokens) through as they are produced.
+// The backend proxy flushes every write (FlushInterval -1); on top of that, event streams:
+//   - are requested without Accept-Encoding, because a compressing upstream holds back small events
+//   - carry X-Accel-Buffering: no, so nginx or an ingress in front of the proxy does not buffer them
+//   - get PROXY_SSE_MAX_DURATION instead of PROXY_WRITE_TIMEOUT, which would cut off long generations
+//   - are cut on shutdown, so the client reconnects to another instance (see drain.go)
+func streamEvents(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptsEventStream(r) {
+			r.Header.Del("Accept-Encoding")
+		}
+
+		// Cancelling the request aborts the upstream read, which ends the response
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// Deferred, because the reverse proxy ends an aborted stream with panic(http.ErrAbortHandler)
+		sw := &eventStreamWriter{ResponseWriter: w, cancel: cancel}
+		defer sw.endStream()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+	})
+}
+
+// acceptsEventStream reports whether the client asked for an event stream (EventSource sends
+// "Accept: text/event-stream"). Streams requested with fetch are still detected from the response.
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == eventStreamType {
+			return true
+		}
+	}
+	return false
+}
+
+// eventStreamWriter prepares the response once its headers show an event stream
+type eventStreamWriter struct {
+	http.ResponseWriter
+	cancel      context.CancelFunc
+	untrack     func()
+	wroteHeader bool
+	streaming   bool
+}
+
+func (w *eventStreamWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType == eventStreamType {
+			w.startStream()
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *eventStreamWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *eventStreamWriter) startStream() {
+	h := w.Header()
+	h.Set("X-Accel-Buffering", "no")
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	// A zero deadline means none
+	var deadline time.Time
+	if cfg.Stream.MaxDuration > 0 {
+		deadline = time.Now().Add(time.Duration(cfg.Stream.MaxDuration) * time.Second)
+	}
+	if err := http.NewResponseController(w.ResponseWriter).SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("⚠️  Could not extend the write deadline of an event stream: %v", err)
+	}
+
+	w.streaming = true
+	w.untrack = activeStreams.add(w.cancel)
+	proxyEventStreams.Inc()
+}
+
+func (w *eventStreamWriter) endStream() {
+	if w.streaming {
+		w.untrack()
+		proxyEventStreams.Dec()
+	}
+}
+
+// Flush errors mean the client went away, which the proxy notices on its next write
+func (w *eventStreamWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *eventStreamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *eventStreamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
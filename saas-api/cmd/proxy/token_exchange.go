@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// POST /token/exchange trades a saas-api access token (Authorization: Bearer) for a LibreChat session,
+// so apps that already signed the user in to saas-api skip the email-only /login flow. The token is
+// validated locally (see verifySaaSToken); saas-api is only asked for the user's profile.
+const tokenExchangePath = "/token/exchange"
+
+// LibreChat access tokens minted by /login and /token/exchange
+const libreAccessTokenTTL = 24 * time.Hour
+
+var proxyTokenExchanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_token_exchanges_total",
+	Help: "POST /token/exchange calls, by result (success, invalid_token, unavailable or error).",
+}, []string{"result"})
+
+// saasClaims are the claims of a saas-api access token (see internal/auth.Claims)
+type saasClaims struct {
+	UserID         string  `json:"user_id"`
+	Email          string  `json:"email"`
+	OrgID          *string `json:"org_id,omitempty"`
+	IsSuperAdmin   bool    `json:"is_super_admin"`
+	ClientID       *string `json:"client_id,omitempty"`       // Set on client_credentials tokens of OAuth clients
+	ImpersonatorID *string `json:"impersonator_id,omitempty"` // Set on impersonation tokens: the super admin acting as the user
+	jwt.RegisteredClaims
+}
+
+var errSaaSTokenUnavailable = errors.New("neither PROXY_SAAS_JWKS_URL nor SAAS_JWT_SECRET is set")
+
+// verifySaaSToken validates a saas-api access token: RS256 against saas-api's JWKS, or HS256 against
+// SAAS_JWT_SECRET. Refresh tokens carry no email and are rejected, and so are client_credentials
+// tokens: their scopes do not reach full sessions in saas-api (RequireUserToken), so they must not
+// buy a LibreChat session either. Impersonation tokens are rejected too: the sessions would outlive
+// the short impersonation and no longer carry the impersonator.
+func verifySaaSToken(tokenString string) (*saasClaims, error) {
+	if saasJWKS == nil && len(cfg.Secrets.SaaSJWTSecret) == 0 {
+		return nil, errSaaSTokenUnavailable
+	}
+
+	claims := &saasClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, saasKeyfunc,
+		jwt.WithValidMethods([]string{"RS256", "HS256"}), jwt.WithIssuer("saas-api"), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Email == "" || claims.UserID == "" {
+		return nil, fmt.Errorf("not an access token")
+	}
+	if claims.ClientID != nil {
+		return nil, fmt.Errorf("client credentials tokens cannot start a session")
+	}
+	if claims.ImpersonatorID != nil {
+		return nil, fmt.Errorf("impersonation tokens cannot start a session")
+	}
+	return claims, nil
+}
+
+// fetchSaaSProfile reads the token holder's own profile from saas-api. On failure the LibreChat user
+// is synced from the token's email alone, as /login does.
+func fetchSaaSProfile(ctx context.Context, claims *saasClaims, accessToken string) *APIUser {
+	fallback := &APIUser{ID: claims.UserID, Email: claims.Email, EmailVerified: true, FullName: claims.Email}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/users/%s", cfg.Upstream.MainAPIURL, claims.UserID), nil)
+	if err != nil {
+		return fallback
+	}
+	setRequestID(req)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Warning: Could not fetch profile of %s: %v. Using email only.", claims.Email, err)
+		return fallback
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Warning: API returned status %d for profile of %s. Using email only.", resp.StatusCode, claims.Email)
+		return fallback
+	}
+
+	var user APIUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil || !strings.EqualFold(user.Email, claims.Email) {
+		log.Printf("Warning: Could not decode profile of %s. Using email only.", claims.Email)
+		return fallback
+	}
+	return &user
+}
+
+// tokenExchangeHandler validates the saas-api Bearer token, syncs the user to LibreChat and starts
+// both the LibreChat and proxy sessions. Besides the cookies /login sets, the response carries the
+// LibreChat access token and the proxy session token for clients that do not keep cookies.
+func tokenExchangeHandler(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, r)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	accessToken := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if accessToken == "" {
+		proxyTokenExchanges.WithLabelValues("invalid_token").Inc()
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := verifySaaSToken(accessToken)
+	if errors.Is(err, errSaaSTokenUnavailable) {
+		proxyTokenExchanges.WithLabelValues("unavailable").Inc()
+		http.Error(w, "token exchange not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		proxyTokenExchanges.WithLabelValues("invalid_token").Inc()
+		log.Printf("Token exchange: rejected saas-api token: %v", err)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	user := fetchSaaSProfile(r.Context(), claims, accessToken)
+	libreToken, err := startLibreChatSession(w, r, user, "")
+	if err != nil {
+		proxyTokenExchanges.WithLabelValues("error").Inc()
+		http.Error(w, "failed to create LibreChat session", http.StatusBadGateway)
+		return
+	}
+
+	sessionToken, err := startProxySession(w, r, claims.Email)
+	if err != nil {
+		proxyTokenExchanges.WithLabelValues("error").Inc()
+		http.Error(w, "token error", http.StatusInternalServerError)
+		return
+	}
+
+	proxyTokenExchanges.WithLabelValues("success").Inc()
+	log.Printf("Token exchange: started LibreChat session for %s", claims.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":             "ok",
+		"librechat_token":    libreToken,
+		"expires_in":         int(libreAccessTokenTTL.Seconds()),
+		"session_token":      sessionToken,
+		"session_expires_in": int(proxySessionTTL.Seconds()),
+	})
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is the proxy's OpenTelemetry tracer. Until setupTracing installs a provider (and when
+// tracing is off) its spans are no-ops that still carry an inbound traceparent to the upstreams.
+var tracer = otel.Tracer("saas-api/cmd/proxy")
+
+// setupTracing exports spans over OTLP/HTTP when cfg.Tracing is enabled; the exporter reads the
+// standard OTEL_EXPORTER_OTLP_* variables. W3C trace context and baggage are propagated either way.
+// The returned func flushes pending spans on shutdown.
+func setupTracing(ctx context.Context) (func(context.Context), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Tracing.Enabled {
+		return func(context.Context) {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.Tracing.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(cfg.Tracing.SamplePercent)/100))),
+	)
+	otel.SetTracerProvider(provider)
+	tracer = provider.Tracer("saas-api/cmd/proxy")
+
+	return func(ctx context.Context) {
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("Tracing: could not flush spans: %v", err)
+		}
+	}, nil
+}
+
+// withTracing starts a server span per request, continuing the caller's trace when it sends a
+// traceparent. instrumentHandler names the span after the matched route.
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath || r.URL.Path == healthzPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.ClientAddress(clientIP(r)),
+			semconv.UserAgentOriginal(r.UserAgent()),
+		))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// nameServerSpan names the request's span "METHOD route", with the router pattern that matched
+func nameServerSpan(r *http.Request, route string) {
+	span := trace.SpanFromContext(r.Context())
+	span.SetName(r.Method + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// startUpstreamSpan starts a client span for a call to upstream and injects its traceparent into
+// header, so LibreChat and saas-api continue the trace
+func startUpstreamSpan(ctx context.Context, upstream, name string, header http.Header) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("proxy.upstream", upstream),
+	))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	return ctx, span
+}
+
+// endUpstreamSpan records the upstream's answer (or transport error) on span and ends it
+func endUpstreamSpan(span trace.Span, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp != nil:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	span.End()
+}
+
+// mongoSpans holds the spans of MongoDB commands in flight, by driver request ID
+var mongoSpans sync.Map
+
+func startMongoSpan(ctx context.Context, e *event.CommandStartedEvent) {
+	if !cfg.Tracing.Enabled {
+		return
+	}
+	_, span := tracer.Start(ctx, "mongodb "+e.CommandName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNameMongoDB,
+		semconv.DBNamespace(e.DatabaseName),
+		semconv.DBOperationName(e.CommandName),
+	))
+	mongoSpans.Store(e.RequestID, span)
+}
+
+// endMongoSpan ends a command's span; failure is the driver's error message, empty on success
+func endMongoSpan(requestID int64, failure string) {
+	value, ok := mongoSpans.LoadAndDelete(requestID)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	if failure != "" {
+		span.SetStatus(codes.Error, failure)
+	}
+	span.End()
+}
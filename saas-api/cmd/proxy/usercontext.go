@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Tenancy headers for LibreChat-side customizations. The proxy always removes client-sent copies, so
+// LibreChat can trust them.
+const (
+	userOrgHeader        = "X-User-Org"
+	userRoleHeader       = "X-User-Role"
+	userSuperAdminHeader = "X-User-Super-Admin"
+)
+
+// A failed lookup is retried after this long (or the cache TTL, if shorter) rather than on every request
+const userContextFailureTTL = 30 * time.Second
+
+var proxyUserContextLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "proxy_user_context_lookups_total",
+	Help: "saas-api lookups of a user's org and role, by result (success or failure).",
+}, []string{"result"})
+
+// saasUser is the part of the saas-api user returned by the token exchange that LibreChat needs
+type saasUser struct {
+	OrgID        string `json:"org_id"`
+	OrgRole      string `json:"org_role"`
+	IsSuperAdmin bool   `json:"is_super_admin"`
+}
+
+type userContext struct {
+	user      saasUser
+	found     bool
+	expiresAt time.Time
+}
+
+// userContextCache keeps each user's org and role for PROXY_USER_CONTEXT_TTL, so LibreChat traffic
+// does not cost a saas-api call per request
+type userContextCache struct {
+	mu      sync.Mutex
+	entries map[string]userContext
+}
+
+var userContexts = &userContextCache{entries: make(map[string]userContext)}
+
+func (c *userContextCache) get(email, proxyToken string) (saasUser, bool) {
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.entries[email]; ok && now.Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.user, entry.found
+	}
+	c.mu.Unlock()
+
+	// The exchange also yields a saas-api token, which the document bridge can reuse
+	entry := userContext{expiresAt: now.Add(time.Duration(cfg.UserContext.CacheTTL) * time.Second)}
+	t, err := exchangeProxyToken(proxyToken)
+	if err != nil {
+		proxyUserContextLookups.WithLabelValues("failure").Inc()
+		log.Printf("User context lookup failed for %s: %v", email, err)
+		if failureExpiry := now.Add(userContextFailureTTL); failureExpiry.Before(entry.expiresAt) {
+			entry.expiresAt = failureExpiry
+		}
+	} else {
+		proxyUserContextLookups.WithLabelValues("success").Inc()
+		entry.user, entry.found = t.user, true
+		saasTokens.put(email, t)
+	}
+
+	c.mu.Lock()
+	for key, old := range c.entries {
+		if !now.Before(old.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[email] = entry
+	c.mu.Unlock()
+	return entry.user, entry.found
+}
+
+// setUserContextHeaders replaces any client-sent tenancy headers in dst with the org, org role and
+// super-admin flag of the proxy session r carries. Without a valid session, or when saas-api cannot
+// be reached, none are sent.
+func setUserContextHeaders(dst http.Header, r *http.Request) {
+	dst.Del(userOrgHeader)
+	dst.Del(userRoleHeader)
+	dst.Del(userSuperAdminHeader)
+	if !cfg.UserContext.Enabled {
+		return
+	}
+
+	var proxyToken string
+	if c, err := r.Cookie(cfg.Cookies.Session); err == nil {
+		proxyToken = c.Value
+	}
+	if proxyToken == "" {
+		proxyToken = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	if proxyToken == "" {
+		return
+	}
+	email, err := verifyToken(proxyToken)
+	if err != nil {
+		return
+	}
+
+	user, ok := userContexts.get(email, proxyToken)
+	if !ok {
+		return
+	}
+	if user.OrgID != "" {
+		dst.Set(userOrgHeader, user.OrgID)
+	}
+	if user.OrgRole != "" {
+		dst.Set(userRoleHeader, user.OrgRole)
+	}
+	dst.Set(userSuperAdminHeader, strconv.FormatBool(user.IsSuperAdmin))
+}
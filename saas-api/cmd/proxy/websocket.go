@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var proxyWebsocketUsers = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "proxy_websocket_users",
+	Help: "Users (or anonymous client IPs) with at least one open proxied websocket.",
+})
+
+// wsUserConns counts the open websocket relays of each user, for PROXY_WS_MAX_PER_USER
+var wsUserConns = &websocketCounter{open: make(map[string]int)}
+
+type websocketCounter struct {
+	mu   sync.Mutex
+	open map[string]int
+}
+
+// acquire reserves a connection for key, unless key already has max open (0 means no limit)
+func (c *websocketCounter) acquire(key string, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if max > 0 && c.open[key] >= max {
+		return false
+	}
+	c.open[key]++
+	proxyWebsocketUsers.Set(float64(len(c.open)))
+	return true
+}
+
+func (c *websocketCounter) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.open[key]--; c.open[key] <= 0 {
+		delete(c.open, key)
+	}
+	proxyWebsocketUsers.Set(float64(len(c.open)))
+}
+
+// websocketUserKey is who a websocket counts against: the signed-in user, or the client IP when
+// anonymous
+func websocketUserKey(r *http.Request, email string) string {
+	if email != "" {
+		return "user:" + email
+	}
+	return "ip:" + clientIP(r)
+}
+
+// acquireWebsocketSlot holds one of the caller's PROXY_WS_MAX_PER_USER websockets until the returned
+// release is called. Past the cap the handshake is refused with 429 and ok is false, so one user or
+// bot cannot exhaust the gateway's file descriptors.
+func acquireWebsocketSlot(w http.ResponseWriter, r *http.Request, email string) (release func(), ok bool) {
+	key := websocketUserKey(r, email)
+	if !wsUserConns.acquire(key, cfg.Websocket.MaxPerUser) {
+		proxyWebsocketTotal.WithLabelValues("user_limit").Inc()
+		log.Printf("websocket refused: %s already has %d open (PROXY_WS_MAX_PER_USER)", key, cfg.Websocket.MaxPerUser)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(cfg.Websocket.PongTimeout))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": "too_many_websockets"})
+		return nil, false
+	}
+	return func() { wsUserConns.release(key) }, true
+}
+
+// relayWebsocket copies messages between the browser and LibreChat until either side goes away.
+// Each hop is kept alive independently: the proxy pings both peers every PingInterval and drops a
+// peer that sends nothing (not even a pong) within PongTimeout. A close frame received from one side
+// is forwarded to the other with the same code and reason. On shutdown both peers get a 1001 close
+// frame, and their replies end the relay (see drain.go).
+func relayWebsocket(clientConn, backendConn *websocket.Conn) {
+	ws := cfg.Websocket
+	for _, conn := range []*websocket.Conn{clientConn, backendConn} {
+		prepareWebsocketConn(conn, ws)
+	}
+
+	untrack := activeStreams.add(func() {
+		goingAway := websocket.FormatCloseMessage(websocket.CloseGoingAway, "proxy shutting down")
+		deadline := time.Now().Add(time.Duration(ws.WriteTimeout) * time.Second)
+		clientConn.WriteControl(websocket.CloseMessage, goingAway, deadline)
+		backendConn.WriteControl(websocket.CloseMessage, goingAway, deadline)
+	})
+	defer untrack()
+
+	done := make(chan struct{})
+	defer close(done)
+	go pingWebsocket(clientConn, ws, done)
+	go pingWebsocket(backendConn, ws, done)
+
+	errc := make(chan error, 2)
+	go func() { errc <- copyWebsocket(backendConn, clientConn, ws) }()
+	go func() { errc <- copyWebsocket(clientConn, backendConn, ws) }()
+
+	// The first side to finish decides the outcome; closing both unblocks the other copier
+	err := <-errc
+	clientConn.Close()
+	backendConn.Close()
+	<-errc
+
+	if err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+		log.Printf("websocket relay ended: %v", err)
+	}
+}
+
+func prepareWebsocketConn(conn *websocket.Conn, ws WebsocketConfig) {
+	pongWait := time.Duration(ws.PongTimeout) * time.Second
+	conn.SetReadLimit(ws.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+}
+
+// copyWebsocket relays messages from src to dst. It returns src's read error after forwarding the
+// matching close frame to dst (or a going-away close when src vanished without one).
+func copyWebsocket(dst, src *websocket.Conn, ws WebsocketConfig) error {
+	pongWait := time.Duration(ws.PongTimeout) * time.Second
+	writeWait := time.Duration(ws.WriteTimeout) * time.Second
+
+	for {
+		mt, message, err := src.ReadMessage()
+		if err != nil {
+			forwardClose(dst, err, writeWait)
+			return err
+		}
+		// Any traffic proves the peer is alive, not only pongs
+		src.SetReadDeadline(time.Now().Add(pongWait))
+
+		dst.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := dst.WriteMessage(mt, message); err != nil {
+			src.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "upstream write failed"), time.Now().Add(writeWait))
+			return err
+		}
+	}
+}
+
+// forwardClose mirrors why src ended onto dst
+func forwardClose(dst *websocket.Conn, readErr error, writeWait time.Duration) {
+	code, text := websocket.CloseGoingAway, ""
+
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(readErr, &closeErr):
+		code, text = closeErr.Code, closeErr.Text
+		// 1005 and 1006 are reserved for reporting and must not be sent on the wire
+		if code == websocket.CloseNoStatusReceived {
+			code = websocket.CloseNormalClosure
+		} else if code == websocket.CloseAbnormalClosure {
+			code = websocket.CloseGoingAway
+		}
+	case errors.Is(readErr, websocket.ErrReadLimit):
+		code, text = websocket.CloseMessageTooBig, "message too big"
+	case errors.As(readErr, &netErr) && netErr.Timeout():
+		text = "peer timed out"
+	}
+
+	dst.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
+
+// pingWebsocket sends pings until done; WriteControl is safe to call alongside the copier's writes
+func pingWebsocket(conn *websocket.Conn, ws WebsocketConfig, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(ws.PingInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(time.Duration(ws.WriteTimeout) * time.Second)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
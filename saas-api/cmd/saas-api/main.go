@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"saas-api/cmd/api"
+	"saas-api/cmd/bootstrap"
+	"saas-api/cmd/mcpserver"
+	"saas-api/cmd/proxy"
+)
+
+const usage = `Usage: saas-api <command>
+
+Commands:
+  serve api      Run the REST API
+  serve proxy    Run the LibreChat proxy
+  serve mcp      Run the MCP document search server (MCP_ADDR, default :8081)
+  seed [flags]   Load the demo dataset (see: saas-api seed -h)
+  smoke [flags]  Check a running deployment (see: saas-api smoke -h)
+`
+
+// services are the long-running servers `saas-api serve` can start
+var services = map[string]func(context.Context){
+	"api":   api.Serve,
+	"proxy": proxy.Serve,
+	"mcp":   mcpserver.Serve,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch command := os.Args[1]; command {
+	case "serve":
+		if len(os.Args) < 3 || services[os.Args[2]] == nil {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		name := os.Args[2]
+		bootstrap.Logging(name)
+		bootstrap.LoadEnv()
+
+		ctx, stop := bootstrap.SignalContext()
+		defer stop()
+		services[name](ctx)
+
+	case "seed", "smoke":
+		bootstrap.Logging(command)
+		bootstrap.LoadEnv()
+
+		run := api.Seed
+		if command == "smoke" {
+			run = api.Smoke
+		}
+		if err := run(context.Background(), os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %v", command, err)
+		}
+
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
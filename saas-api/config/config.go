@@ -3,13 +3,40 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	App      AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	App       AppConfig
+	Proxy     ProxyConfig
+	Pending   PendingUserConfig
+	Roles     RoleExpiryConfig
+	LibreChat LibreChatConfig
+	Secrets   SecretsConfig
+	Artifacts ArtifactConfig
+	Canary    PipelineCanaryConfig
+	Import    FolderImportConfig
+	OIDC      OIDCConfig
+	SAML      SAMLConfig
+	TwoFactor TwoFactorConfig
+	WebAuthn  WebAuthnConfig
+	Reset     PasswordResetConfig
+	Email     EmailChangeConfig
+	LoginLink LoginLinkConfig
+	Lockout   LockoutConfig
+	OTP       OTPConfig
+	Mail      MailConfig
+	Storage   StorageConfig
+	Uploads   UploadSessionConfig
+	RateLimit AuthRateLimitConfig
+	Invite    InvitationConfig
+	StepUp    StepUpConfig
+	Captcha   CaptchaConfig
+	OAuth     OAuthConfig
+	PermCache PermissionCacheConfig
 }
 
 type ServerConfig struct {
@@ -18,6 +45,8 @@ type ServerConfig struct {
 	ReadTimeout  int
 	WriteTimeout int
 	IdleTimeout  int
+	// Proxies (addresses or CIDR ranges) whose X-Forwarded-For is believed when taking the client IP
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -30,9 +59,213 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey       string
-	AccessTokenTTL  int // minutes
-	RefreshTokenTTL int // days
+	SecretKey        string
+	AccessTokenTTL   int    // minutes
+	RefreshTokenTTL  int    // days
+	ImpersonationTTL int    // minutes a super admin's impersonation token is valid
+	SigningKeys      string // JSON list of rotating signing keys (see auth.ParseSigningKeys); empty: SecretKey signs
+}
+
+// ProxyConfig configures the server-to-server token exchange used by the LibreChat proxy
+type ProxyConfig struct {
+	SharedSecret     string // Empty disables the exchange endpoint
+	ExchangeTokenTTL int    // seconds
+	URL              string // Base URL of the proxy, for revoking its sessions on sign-out everywhere; empty: they expire on their own
+}
+
+// PendingUserConfig controls the job that expires users stuck in "pending"
+type PendingUserConfig struct {
+	ExpiryDays    int    // 0 disables the job
+	Action        string // "flag" (mark as expired) or "delete" (frees the email for reuse)
+	CheckInterval int    // minutes
+}
+
+// RoleExpiryConfig controls the job that revokes expired role assignments and warns org admins of
+// assignments about to expire
+type RoleExpiryConfig struct {
+	CheckInterval int // minutes; 0 disables the job
+	NoticeDays    int // days ahead admins are told of expiring assignments; 0 sends no notices
+}
+
+// LibreChatConfig controls pushing profile changes to the LibreChat MongoDB users collection
+type LibreChatConfig struct {
+	MongoURI    string
+	ProfileSync bool // Update LibreChat name/username/avatar when a user's profile changes
+}
+
+// SecretsConfig holds the key-encryption keys for the org secrets store
+type SecretsConfig struct {
+	EncryptionKeys string // "id:base64key[,id:base64key...]", current key first; empty disables org secrets
+}
+
+// ArtifactConfig controls the lifecycle of document processing artifacts (*_chunks.json in JSON_BASE_PATH)
+type ArtifactConfig struct {
+	CompressAfterDays int // gzip artifacts older than this; 0 disables compression
+	RetentionDays     int // delete artifacts older than this; 0 keeps them forever
+	OrgQuotaMB        int // per-org artifact storage; the oldest are deleted beyond it, 0 is unlimited
+	SweepInterval     int // minutes
+}
+
+// PipelineCanaryConfig describes a candidate document pipeline that is run in shadow mode on a sample
+// of documents, next to the live one, before a full reindex
+type PipelineCanaryConfig struct {
+	Version string   // Suffix of the canary's Weaviate classes (letters, digits, _); empty disables canary runs
+	Script  string   // Python entry point of the candidate pipeline
+	Queries []string // Benchmark queries compared between the live and canary classes
+}
+
+// FolderImportConfig limits ZIP folder imports (POST /api/v1/folders/import-zip)
+type FolderImportConfig struct {
+	MaxArchiveMB int      // size of the uploaded archive
+	MaxFiles     int      // documents created by one import
+	MaxTotalMB   int      // uncompressed size of the imported files
+	Extensions   []string // lowercase, with the dot; other files are skipped
+}
+
+// OIDCConfig controls OIDC single sign-on; each org's identity provider is set through the API
+type OIDCConfig struct {
+	RedirectURL string // This API's callback as registered with the IdPs (.../api/v1/auth/oidc/callback); empty disables OIDC login
+	FrontendURL string // Where the browser goes after signing in, with the return_to path appended; empty stays on this host
+}
+
+// SAMLConfig controls SAML single sign-on; each org's identity provider is set through the API. The
+// optional key pair signs authentication requests and decrypts encrypted assertions.
+type SAMLConfig struct {
+	BaseURL     string // Public URL of this API, for the per-org entity ID and ACS URL; empty disables SAML login
+	FrontendURL string // As OIDCConfig.FrontendURL (default: OIDC_FRONTEND_URL)
+	CertFile    string // PEM certificate published in the SP metadata
+	KeyFile     string // PEM private key (RSA) of CertFile
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication, which is available when
+// SECRETS_ENCRYPTION_KEYS is set (the TOTP secrets are sealed with those keys)
+type TwoFactorConfig struct {
+	Issuer string // Account issuer shown in authenticator apps
+}
+
+// WebAuthnConfig controls passkey sign-in. Passkeys are bound to the relying party ID, so changing it
+// orphans every registered passkey.
+type WebAuthnConfig struct {
+	RPID          string   // Relying party ID: the domain of the frontend (e.g. app.example.com); empty disables passkeys
+	RPDisplayName string   // Name shown by the browser when creating a passkey
+	RPOrigins     []string // Origins the ceremonies may come from (default: https://<RPID>)
+}
+
+// PasswordResetConfig controls the forgot-password flow
+type PasswordResetConfig struct {
+	URL        string // Frontend page the emailed link opens, with ?token= appended; empty disables password reset
+	TTL        int    // minutes a reset link stays valid
+	MaxPerUser int    // reset emails per account per hour
+	MaxPerIP   int    // reset emails per client IP per hour
+}
+
+// EmailChangeConfig controls changing a user's email, which takes effect once a link sent to the
+// new address is followed
+type EmailChangeConfig struct {
+	URL string // Frontend page the emailed link opens, with ?token= appended; empty disables email changes
+	TTL int    // minutes a confirmation link stays valid
+}
+
+// LoginLinkConfig controls passwordless sign-in with emailed one-time links, next to OTP codes
+type LoginLinkConfig struct {
+	URL        string // Frontend page the emailed link opens, with ?token= appended; empty disables sign-in links
+	TTL        int    // minutes a link stays valid
+	MaxPerHour int    // links sent to one account per hour
+}
+
+// InvitationConfig controls inviting people to an organization by email; the account is created
+// when the emailed link is accepted
+type InvitationConfig struct {
+	URL    string // Frontend page the emailed link opens, with ?token= appended; empty disables invitations
+	TTL    int    // days an invitation stays valid unless it sets its own expires_at
+	MaxTTL int    // longest expires_at an invitation may ask for, in days
+}
+
+// StepUpConfig controls re-authentication before sensitive operations such as deleting an org,
+// issuing API keys or changing roles
+type StepUpConfig struct {
+	MaxAge int // minutes a sign-in or step-up counts as recent; 0 disables the check
+}
+
+// PermissionCacheConfig controls caching each user's permissions in Redis; it is off when
+// REDIS_URL is not set
+type PermissionCacheConfig struct {
+	TTL int // seconds an entry is kept; 0 disables the cache
+}
+
+// OAuthConfig controls the OAuth2 client_credentials grant for machine integrations
+type OAuthConfig struct {
+	TokenTTL int // minutes a client_credentials access token stays valid
+}
+
+// LockoutConfig is the default account lockout policy; orgs override it with their
+// lockout_max_attempts and lockout_duration_minutes settings
+type LockoutConfig struct {
+	MaxAttempts int // failed sign-ins in a row that lock an account; 0 disables lockout
+	Duration    int // minutes an account stays locked
+}
+
+// AuthRateLimitConfig limits attempts on the sign-in and OTP endpoints per client IP and per email,
+// counted in Redis; it is off when REDIS_URL is not set
+type AuthRateLimitConfig struct {
+	PerIP    int // attempts from one IP per window and endpoint; 0 disables the IP limit
+	PerEmail int // attempts for one email per window and endpoint; 0 disables the email limit
+	Window   int // minutes
+}
+
+// CaptchaConfig protects login and the OTP endpoints with reCAPTCHA or hCaptcha; it is off when no
+// provider is set
+type CaptchaConfig struct {
+	Provider  string  // recaptcha or hcaptcha; empty disables CAPTCHA checks
+	SecretKey string  // the site's secret key at the provider
+	MinScore  float64 // lowest reCAPTCHA v3 score accepted (0-1); 0 ignores scores
+	Hostname  string  // when set, tokens must have been solved on this hostname
+}
+
+// OTPConfig controls the channels sign-in OTP codes are delivered on. Email is always available;
+// SMS needs a provider.
+type OTPConfig struct {
+	SMSProvider      string // "twilio" or "sns"; empty sends codes by email only
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string // sending number, or a messaging service SID (MG...)
+	SNSRegion        string // empty: the AWS SDK's default region
+	SNSSenderID      string // alphanumeric sender ID, where the destination country supports it
+	EmailMaxPerHour  int    // codes emailed to one account per hour; 0 disables the limit
+	SMSMaxPerHour    int    // codes texted to one account per hour; 0 disables the limit
+}
+
+// MailConfig selects the email provider for OTP codes, links and notifications
+type MailConfig struct {
+	Driver         string // "smtp" (default), "ses", "sendgrid", or "log" to only log emails (development)
+	FromEmail      string
+	FromName       string
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SESRegion      string // empty: the AWS SDK's default region
+	SendGridAPIKey string
+	Retries        int // attempts after a failed send; rejected messages are not retried
+	RetryDelay     int // milliseconds before the first retry, doubling for each next one
+}
+
+type StorageConfig struct {
+	Backend          string // "local" (default) or "s3"
+	LocalRoot        string // directory of the local backend; the documents API's RESOURCES_BASE_PATH
+	S3Bucket         string
+	S3Region         string // empty: the AWS SDK's default region
+	S3Endpoint       string // empty: Amazon S3; set for compatible stores such as MinIO
+	S3Prefix         string // prepended to every key
+	S3ForcePathStyle bool   // address the bucket in the path, as MinIO needs
+	SignedURLTTL     int    // seconds a download redirect to the bucket stays valid
+}
+
+// UploadSessionConfig controls resumable chunked uploads (POST /api/v1/documents/uploads)
+type UploadSessionConfig struct {
+	TTL           int // hours an upload session stays open after its last chunk
+	MaxChunkMB    int // largest chunk a single PATCH may send
+	SweepInterval int // minutes between removals of expired sessions; 0 disables the sweep
 }
 
 type AppConfig struct {
@@ -49,6 +282,8 @@ func Load() *Config {
 			ReadTimeout:  getEnvAsInt("SERVER_READ_TIMEOUT", 15),
 			WriteTimeout: getEnvAsInt("SERVER_WRITE_TIMEOUT", 15),
 			IdleTimeout:  getEnvAsInt("SERVER_IDLE_TIMEOUT", 60),
+			// Default: nginx on the same host. Set it empty when clients connect directly
+			TrustedProxies: getEnvAsListDefault("SERVER_TRUSTED_PROXIES", ",", "127.0.0.1,::1"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("ALCHEMY_DB_HOST", "127.0.0.1"), // Use 127.0.0.1 instead of localhost to avoid IPv6 issues
@@ -59,16 +294,179 @@ func Load() *Config {
 			SSLMode:  getEnv("ALCHEMY_DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			SecretKey:       getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
-			AccessTokenTTL:  getEnvAsInt("JWT_ACCESS_TTL", 1440), // 1 day (1440 minutes)
-			RefreshTokenTTL: getEnvAsInt("JWT_REFRESH_TTL", 7),   // 7 days
+			SecretKey:        getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
+			AccessTokenTTL:   getEnvAsInt("JWT_ACCESS_TTL", 1440), // 1 day (1440 minutes)
+			RefreshTokenTTL:  getEnvAsInt("JWT_REFRESH_TTL", 7),   // 7 days
+			ImpersonationTTL: getEnvAsInt("JWT_IMPERSONATION_TTL", 15),
+			SigningKeys:      getEnv("JWT_SIGNING_KEYS", ""),
 		},
 		App: AppConfig{
 			Environment: getEnv("APP_ENV", "development"),
 			LogLevel:    getEnv("LOG_LEVEL", "info"),
 			StoragePath: getEnv("STORAGE_PATH", "uploads"), // Default: "uploads" directory
 		},
+		Proxy: ProxyConfig{
+			SharedSecret:     getEnv("PROXY_SHARED_SECRET", ""),
+			ExchangeTokenTTL: getEnvAsInt("PROXY_EXCHANGE_TTL", 300), // 5 minutes
+			URL:              getEnv("PROXY_URL", ""),
+		},
+		Pending: PendingUserConfig{
+			ExpiryDays:    getEnvAsInt("PENDING_USER_EXPIRY_DAYS", 30),
+			Action:        getEnv("PENDING_USER_EXPIRY_ACTION", "flag"),
+			CheckInterval: getEnvAsInt("PENDING_USER_EXPIRY_INTERVAL", 60), // 1 hour
+		},
+		Roles: RoleExpiryConfig{
+			CheckInterval: getEnvAsInt("ROLE_EXPIRY_INTERVAL", 60), // 1 hour
+			NoticeDays:    getEnvAsInt("ROLE_EXPIRY_NOTICE_DAYS", 7),
+		},
+		LibreChat: LibreChatConfig{
+			MongoURI:    getEnv("MONGO_URI", "mongodb://127.0.0.1:27017/LibreChat"),
+			ProfileSync: getEnv("LIBRECHAT_PROFILE_SYNC", "true") == "true",
+		},
+		Artifacts: ArtifactConfig{
+			CompressAfterDays: getEnvAsInt("ARTIFACT_COMPRESS_AFTER_DAYS", 7),
+			RetentionDays:     getEnvAsInt("ARTIFACT_RETENTION_DAYS", 0),
+			OrgQuotaMB:        getEnvAsInt("ARTIFACT_ORG_QUOTA_MB", 0),
+			SweepInterval:     getEnvAsInt("ARTIFACT_SWEEP_INTERVAL", 360), // 6 hours
+		},
+		Canary: PipelineCanaryConfig{
+			Version: getEnv("PIPELINE_CANARY_VERSION", ""),
+			Script:  getEnv("PIPELINE_CANARY_SCRIPT", "../docling/document_process.py"),
+			Queries: getEnvAsList("PIPELINE_CANARY_QUERIES", ";"),
+		},
+		Secrets: SecretsConfig{
+			EncryptionKeys: getEnv("SECRETS_ENCRYPTION_KEYS", ""),
+		},
+		Import: FolderImportConfig{
+			MaxArchiveMB: getEnvAsInt("FOLDER_IMPORT_MAX_ARCHIVE_MB", 512),
+			MaxFiles:     getEnvAsInt("FOLDER_IMPORT_MAX_FILES", 500),
+			MaxTotalMB:   getEnvAsInt("FOLDER_IMPORT_MAX_TOTAL_MB", 2048),
+			Extensions:   folderImportExtensions(),
+		},
+		OIDC: OIDCConfig{
+			RedirectURL: getEnv("OIDC_REDIRECT_URL", ""),
+			FrontendURL: strings.TrimSuffix(getEnv("OIDC_FRONTEND_URL", ""), "/"),
+		},
+		SAML: SAMLConfig{
+			BaseURL:     strings.TrimSuffix(getEnv("SAML_BASE_URL", ""), "/"),
+			FrontendURL: strings.TrimSuffix(getEnv("SAML_FRONTEND_URL", getEnv("OIDC_FRONTEND_URL", "")), "/"),
+			CertFile:    getEnv("SAML_SP_CERT_FILE", ""),
+			KeyFile:     getEnv("SAML_SP_KEY_FILE", ""),
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer: getEnv("TWO_FACTOR_ISSUER", "SaaS API"),
+		},
+		WebAuthn: webAuthnConfig(),
+		Reset: PasswordResetConfig{
+			URL:        getEnv("PASSWORD_RESET_URL", ""),
+			TTL:        getEnvAsInt("PASSWORD_RESET_TTL", 30),
+			MaxPerUser: getEnvAsInt("PASSWORD_RESET_MAX_PER_USER", 3),
+			MaxPerIP:   getEnvAsInt("PASSWORD_RESET_MAX_PER_IP", 10),
+		},
+		Email: EmailChangeConfig{
+			URL: getEnv("EMAIL_CHANGE_URL", ""),
+			TTL: getEnvAsInt("EMAIL_CHANGE_TTL", 60),
+		},
+		LoginLink: LoginLinkConfig{
+			URL:        getEnv("LOGIN_LINK_URL", ""),
+			TTL:        getEnvAsInt("LOGIN_LINK_TTL", 15),
+			MaxPerHour: getEnvAsInt("LOGIN_LINK_MAX_PER_HOUR", 5),
+		},
+		Invite: InvitationConfig{
+			URL:    getEnv("INVITATION_URL", ""),
+			TTL:    getEnvAsInt("INVITATION_TTL", 7),
+			MaxTTL: getEnvAsInt("INVITATION_MAX_TTL", 30),
+		},
+		StepUp: StepUpConfig{
+			MaxAge: getEnvAsInt("STEP_UP_MAX_AGE", 10),
+		},
+		Captcha: CaptchaConfig{
+			Provider:  getEnv("CAPTCHA_PROVIDER", ""),
+			SecretKey: getEnv("CAPTCHA_SECRET_KEY", ""),
+			MinScore:  getEnvAsFloat("CAPTCHA_MIN_SCORE", 0.5),
+			Hostname:  getEnv("CAPTCHA_HOSTNAME", ""),
+		},
+		OAuth: OAuthConfig{
+			TokenTTL: getEnvAsInt("OAUTH_TOKEN_TTL", 60),
+		},
+		PermCache: PermissionCacheConfig{
+			TTL: getEnvAsInt("PERMISSION_CACHE_TTL", 300),
+		},
+		Lockout: LockoutConfig{
+			MaxAttempts: getEnvAsInt("LOCKOUT_MAX_ATTEMPTS", 5),
+			Duration:    getEnvAsInt("LOCKOUT_DURATION", 30),
+		},
+		RateLimit: AuthRateLimitConfig{
+			PerIP:    getEnvAsInt("AUTH_RATE_LIMIT_PER_IP", 30),
+			PerEmail: getEnvAsInt("AUTH_RATE_LIMIT_PER_EMAIL", 10),
+			Window:   getEnvAsInt("AUTH_RATE_LIMIT_WINDOW", 15),
+		},
+		OTP: OTPConfig{
+			SMSProvider:      getEnv("OTP_SMS_PROVIDER", ""),
+			TwilioAccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
+			TwilioAuthToken:  getEnv("TWILIO_AUTH_TOKEN", ""),
+			TwilioFrom:       getEnv("TWILIO_FROM", ""),
+			SNSRegion:        getEnv("SNS_REGION", ""),
+			SNSSenderID:      getEnv("SNS_SENDER_ID", ""),
+			EmailMaxPerHour:  getEnvAsInt("OTP_EMAIL_MAX_PER_HOUR", 10),
+			SMSMaxPerHour:    getEnvAsInt("OTP_SMS_MAX_PER_HOUR", 5),
+		},
+		Mail: MailConfig{
+			Driver:         getEnv("MAIL_DRIVER", "smtp"),
+			FromEmail:      getEnv("MAIL_FROM_EMAIL", getEnv("SMTP_FROM_EMAIL", "abhinavkangale123@gmail.com")),
+			FromName:       getEnv("MAIL_FROM_NAME", getEnv("SMTP_FROM_NAME", "FIA - FYERS Intelligent Assistant")),
+			SMTPHost:       getEnv("SMTP_HOST", "smtp.gmail.com"),
+			SMTPPort:       getEnv("SMTP_PORT", "587"),
+			SMTPUsername:   getEnv("SMTP_USERNAME", "abhinavkangale123@gmail.com"),
+			SMTPPassword:   getEnv("SMTP_PASSWORD", "tmnpmoiwcxiaajjx"),
+			SESRegion:      getEnv("SES_REGION", ""),
+			SendGridAPIKey: getEnv("SENDGRID_API_KEY", ""),
+			Retries:        getEnvAsInt("MAIL_RETRIES", 2),
+			RetryDelay:     getEnvAsInt("MAIL_RETRY_DELAY_MS", 500),
+		},
+		Storage: StorageConfig{
+			Backend:          getEnv("STORAGE_BACKEND", "local"),
+			LocalRoot:        getEnv("RESOURCES_BASE_PATH", "uploads"),
+			S3Bucket:         getEnv("S3_BUCKET", ""),
+			S3Region:         getEnv("S3_REGION", ""),
+			S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+			S3Prefix:         getEnv("S3_PREFIX", ""),
+			S3ForcePathStyle: getEnv("S3_FORCE_PATH_STYLE", "false") == "true",
+			SignedURLTTL:     getEnvAsInt("STORAGE_SIGNED_URL_TTL", 300),
+		},
+		Uploads: UploadSessionConfig{
+			TTL:           getEnvAsInt("UPLOAD_SESSION_TTL", 24),
+			MaxChunkMB:    getEnvAsInt("UPLOAD_MAX_CHUNK_MB", 64),
+			SweepInterval: getEnvAsInt("UPLOAD_SESSION_SWEEP_INTERVAL", 60), // 1 hour
+		},
+	}
+}
+
+// webAuthnConfig reads the WEBAUTHN_* settings; the origins default to the relying party over HTTPS
+func webAuthnConfig() WebAuthnConfig {
+	cfg := WebAuthnConfig{
+		RPID:          getEnv("WEBAUTHN_RP_ID", ""),
+		RPDisplayName: getEnv("WEBAUTHN_RP_NAME", "SaaS API"),
+		RPOrigins:     getEnvAsList("WEBAUTHN_RP_ORIGINS", ","),
+	}
+	if len(cfg.RPOrigins) == 0 && cfg.RPID != "" {
+		cfg.RPOrigins = []string{"https://" + cfg.RPID}
 	}
+	return cfg
+}
+
+// folderImportExtensions returns FOLDER_IMPORT_EXTENSIONS, or the formats the document pipeline reads
+// (SUPPORTED_FORMATS in docling/document_process.py)
+func folderImportExtensions() []string {
+	extensions := getEnvAsList("FOLDER_IMPORT_EXTENSIONS", ",")
+	if len(extensions) == 0 {
+		return []string{".pdf", ".docx", ".dotx", ".docm", ".dotm", ".pptx", ".xlsx", ".xlsm", ".csv", ".md", ".txt",
+			".html", ".htm", ".xhtml", ".json", ".jpg", ".jpeg", ".png", ".tiff", ".bmp", ".webp"}
+	}
+	for i, ext := range extensions {
+		extensions[i] = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+	}
+	return extensions
 }
 
 func getEnv(key, defaultValue string) string {
@@ -85,3 +483,36 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsListDefault is getEnvAsList with defaultValue used when the variable is not set at all;
+// set to an empty value it gives an empty list
+func getEnvAsListDefault(key, sep, defaultValue string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		value = defaultValue
+	}
+	return splitList(value, sep)
+}
+
+// getEnvAsList splits a variable on sep, dropping empty entries
+func getEnvAsList(key, sep string) []string {
+	return splitList(os.Getenv(key), sep)
+}
+
+func splitList(list, sep string) []string {
+	var values []string
+	for _, value := range strings.Split(list, sep) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
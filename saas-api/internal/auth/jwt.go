@@ -2,7 +2,7 @@ package auth
 
 import (
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
 	"saas-api/config"
@@ -13,29 +13,88 @@ import (
 )
 
 type Claims struct {
-	UserID       uuid.UUID  `json:"user_id"`
-	Email        string     `json:"email"`
-	OrgID        *uuid.UUID `json:"org_id,omitempty"`
-	IsSuperAdmin bool       `json:"is_super_admin"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Email          string     `json:"email"`
+	OrgID          *uuid.UUID `json:"org_id,omitempty"`
+	IsSuperAdmin   bool       `json:"is_super_admin"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"` // the super admin acting as the user, on impersonation tokens only
+	// AuthTime is when the user last proved a credential (OIDC auth_time): at sign-in, or at a step-up
+	// re-authentication. Refreshed access tokens keep it; tokens not issued to a signing-in user have none.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// ClientID is set on client_credentials tokens: the OAuth client the token was issued to, acting as
+	// UserID within the space-separated Scope
+	ClientID *uuid.UUID `json:"client_id,omitempty"`
+	Scope    string     `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type TokenService struct {
 	config *config.Config
+	keys   []SigningKey // rotating signing keys, by NotBefore; none: JWT_SECRET signs
 }
 
 func NewTokenService(cfg *config.Config) *TokenService {
 	return &TokenService{config: cfg}
 }
 
+// GenerateAccessToken issues an access token to a user who just authenticated, so its auth_time is now
 func (ts *TokenService) GenerateAccessToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(ts.config.JWT.AccessTokenTTL) * time.Minute)
+	return ts.generateAccessToken(user, ts.accessTokenTTL(), nil, jwt.NewNumericDate(time.Now()))
+}
+
+// GenerateRefreshedAccessToken issues an access token from a refresh token, keeping the auth_time of
+// the sign-in that issued it
+func (ts *TokenService) GenerateRefreshedAccessToken(user *models.User, refreshClaims *Claims) (string, error) {
+	return ts.generateAccessToken(user, ts.accessTokenTTL(), nil, refreshClaims.AuthTime)
+}
 
+// GenerateAccessTokenWithTTL issues an access token with a custom lifetime (e.g. short-lived proxy tokens)
+func (ts *TokenService) GenerateAccessTokenWithTTL(user *models.User, ttl time.Duration) (string, error) {
+	return ts.generateAccessToken(user, ttl, nil, nil)
+}
+
+// GenerateImpersonationToken issues an access token acting as user on behalf of impersonatorID.
+// There is no refresh token for it; it is only good for ttl.
+func (ts *TokenService) GenerateImpersonationToken(user *models.User, impersonatorID uuid.UUID, ttl time.Duration) (string, error) {
+	return ts.generateAccessToken(user, ttl, &impersonatorID, nil)
+}
+
+// GenerateClientToken issues a client_credentials access token to an OAuth client acting as user.
+// Its subject is the client, and it has no auth_time, so it never passes a step-up check.
+func (ts *TokenService) GenerateClientToken(user *models.User, clientID uuid.UUID, scopes []string, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID:       user.ID,
-		Email:        user.Email,
-		OrgID:        user.OrgID,
-		IsSuperAdmin: user.IsSuperAdmin,
+		UserID:   user.ID,
+		Email:    user.Email,
+		OrgID:    user.OrgID,
+		ClientID: &clientID,
+		Scope:    strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "saas-api",
+			Subject:   clientID.String(),
+		},
+	}
+
+	return ts.sign(claims)
+}
+
+func (ts *TokenService) accessTokenTTL() time.Duration {
+	return time.Duration(ts.config.JWT.AccessTokenTTL) * time.Minute
+}
+
+func (ts *TokenService) generateAccessToken(user *models.User, ttl time.Duration, impersonatorID *uuid.UUID, authTime *jwt.NumericDate) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+
+	claims := &Claims{
+		UserID:         user.ID,
+		Email:          user.Email,
+		OrgID:          user.OrgID,
+		IsSuperAdmin:   user.IsSuperAdmin,
+		ImpersonatorID: impersonatorID,
+		AuthTime:       authTime,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -45,15 +104,15 @@ func (ts *TokenService) GenerateAccessToken(user *models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(ts.config.JWT.SecretKey))
+	return ts.sign(claims)
 }
 
 func (ts *TokenService) GenerateRefreshToken(userID uuid.UUID) (string, error) {
 	expirationTime := time.Now().Add(time.Duration(ts.config.JWT.RefreshTokenTTL) * 24 * time.Hour)
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:   userID,
+		AuthTime: jwt.NewNumericDate(time.Now()),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -63,19 +122,28 @@ func (ts *TokenService) GenerateRefreshToken(userID uuid.UUID) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(ts.config.JWT.SecretKey))
+	return ts.sign(claims)
+}
+
+// sign signs access and refresh tokens with the current signing key, named in the kid header
+func (ts *TokenService) sign(claims *Claims) (string, error) {
+	kid, method, key := ts.signingKey(time.Now())
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
 }
 
+// ValidateToken checks an access or refresh token against the key its kid names (JWT_SECRET when it
+// has none)
 func (ts *TokenService) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(ts.config.JWT.SecretKey), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		return ts.verificationKey(kid, token.Method.Alg(), time.Now())
+	}, jwt.WithValidMethods([]string{AlgHS256, AlgRS256}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms of JWT_SIGNING_KEYS entries
+const (
+	AlgHS256 = "HS256" // shared secret; only holders of the secret can validate tokens
+	AlgRS256 = "RS256" // RSA key pair; anyone can validate tokens with the public key from the JWKS
+)
+
+// Shortest secret accepted for an HS256 key, and smallest RS256 key
+const (
+	minSigningKeyBytes = 32
+	minRSAKeyBits      = 2048
+)
+
+// SigningKey is one entry of JWT_SIGNING_KEYS. Access and refresh tokens are signed with the newest
+// key whose NotBefore has passed and name it in their kid header; any key that has not expired
+// validates the tokens that name it, so secrets rotate without signing everyone out.
+type SigningKey struct {
+	ID             string    `json:"kid"`
+	Alg            string    `json:"alg,omitempty"`              // HS256 (default) or RS256
+	Secret         string    `json:"secret,omitempty"`           // HS256
+	PrivateKey     string    `json:"private_key,omitempty"`      // RS256: PEM, PKCS#1 or PKCS#8
+	PrivateKeyFile string    `json:"private_key_file,omitempty"` // RS256: path of the PEM, instead of PrivateKey
+	NotBefore      time.Time `json:"not_before,omitempty"`       // signs from then on; zero: right away
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`       // stops validating; zero: JWT_REFRESH_TTL days after the next key takes over
+
+	rsaKey *rsa.PrivateKey
+}
+
+// JWK is the public half of an RS256 signing key, as served at /.well-known/jwks.json (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// ParseSigningKeys reads JWT_SIGNING_KEYS, a JSON list of keys, sorted by NotBefore. An empty value
+// means no keys: tokens are signed with JWT_SECRET and carry no kid.
+func ParseSigningKeys(raw string) ([]SigningKey, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var keys []SigningKey
+	if err := json.Unmarshal([]byte(raw), &keys); err != nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KEYS must be a JSON list of keys: %w", err)
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for i := range keys {
+		key := &keys[i]
+		switch {
+		case key.ID == "":
+			return nil, fmt.Errorf("every signing key needs a kid")
+		case seen[key.ID]:
+			return nil, fmt.Errorf("signing key %q is listed twice", key.ID)
+		case !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(key.NotBefore):
+			return nil, fmt.Errorf("signing key %q expires before it takes over", key.ID)
+		}
+		seen[key.ID] = true
+
+		if key.Alg == "" {
+			key.Alg = AlgHS256
+		}
+		switch key.Alg {
+		case AlgHS256:
+			if len(key.Secret) < minSigningKeyBytes {
+				return nil, fmt.Errorf("signing key %q: the secret must be at least %d bytes", key.ID, minSigningKeyBytes)
+			}
+		case AlgRS256:
+			if err := key.loadRSAKey(); err != nil {
+				return nil, fmt.Errorf("signing key %q: %w", key.ID, err)
+			}
+		default:
+			return nil, fmt.Errorf("signing key %q: alg must be %s or %s", key.ID, AlgHS256, AlgRS256)
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].NotBefore.Before(keys[j].NotBefore) })
+	return keys, nil
+}
+
+func (k *SigningKey) loadRSAKey() error {
+	pemData := []byte(k.PrivateKey)
+	if k.PrivateKeyFile != "" {
+		data, err := os.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the private key: %w", err)
+		}
+		pemData = data
+	}
+	if len(pemData) == 0 {
+		return fmt.Errorf("RS256 keys need a private_key or private_key_file")
+	}
+
+	rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	if rsaKey.N.BitLen() < minRSAKeyBits {
+		return fmt.Errorf("the RSA key must be at least %d bits", minRSAKeyBits)
+	}
+	k.rsaKey = rsaKey
+	return nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Alg == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodHS256
+}
+
+// SetSigningKeys makes tokens use keys (from ParseSigningKeys) instead of JWT_SECRET alone. Tokens
+// without a kid, such as those issued before the first rotation and the proxy's, are still checked
+// against JWT_SECRET, which also keeps deriving the keys of short-lived sign-in state.
+func (ts *TokenService) SetSigningKeys(keys []SigningKey) {
+	ts.keys = keys
+}
+
+// SigningKeyID is the kid new tokens get at now; empty when they are signed with JWT_SECRET
+func (ts *TokenService) SigningKeyID(now time.Time) string {
+	kid, _, _ := ts.signingKey(now)
+	return kid
+}
+
+// PublicKeys returns the RS256 keys that have not expired, scheduled ones included so validators
+// know a key before the first token signed with it
+func (ts *TokenService) PublicKeys(now time.Time) []JWK {
+	jwks := []JWK{}
+	for i, key := range ts.keys {
+		if key.rsaKey == nil || ts.keyExpired(i, now) {
+			continue
+		}
+		jwks = append(jwks, JWK{
+			Kty: "RSA",
+			Kid: key.ID,
+			Use: "sig",
+			Alg: AlgRS256,
+			N:   base64.RawURLEncoding.EncodeToString(key.rsaKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.rsaKey.E)).Bytes()),
+		})
+	}
+	return jwks
+}
+
+// signingKey returns the newest key in effect at now, falling back to JWT_SECRET
+func (ts *TokenService) signingKey(now time.Time) (string, jwt.SigningMethod, interface{}) {
+	for i := len(ts.keys) - 1; i >= 0; i-- {
+		key := &ts.keys[i]
+		if key.NotBefore.After(now) || ts.keyExpired(i, now) {
+			continue
+		}
+		if key.rsaKey != nil {
+			return key.ID, key.method(), key.rsaKey
+		}
+		return key.ID, key.method(), []byte(key.Secret)
+	}
+	return "", jwt.SigningMethodHS256, []byte(ts.config.JWT.SecretKey)
+}
+
+// verificationKey returns the key that checks a token naming kid and signed with alg, if that key is
+// still valid. A token must use its key's algorithm, so an RS256 public key never checks an HMAC.
+func (ts *TokenService) verificationKey(kid, alg string, now time.Time) (interface{}, error) {
+	if kid == "" {
+		if alg != AlgHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", alg)
+		}
+		return []byte(ts.config.JWT.SecretKey), nil
+	}
+	for i := range ts.keys {
+		key := &ts.keys[i]
+		if key.ID != kid {
+			continue
+		}
+		if alg != key.Alg {
+			return nil, fmt.Errorf("unexpected signing method %v for key %q", alg, kid)
+		}
+		if ts.keyExpired(i, now) {
+			return nil, fmt.Errorf("signing key %q has expired", kid)
+		}
+		if key.rsaKey != nil {
+			return &key.rsaKey.PublicKey, nil
+		}
+		return []byte(key.Secret), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// keyExpired applies the key's ExpiresAt or, without one, retires it once the refresh tokens it
+// signed before the next key took over have run out
+func (ts *TokenService) keyExpired(i int, now time.Time) bool {
+	expiresAt := ts.keys[i].ExpiresAt
+	if expiresAt.IsZero() && i+1 < len(ts.keys) {
+		refreshTTL := time.Duration(ts.config.JWT.RefreshTokenTTL) * 24 * time.Hour
+		expiresAt = ts.keys[i+1].NotBefore.Add(refreshTTL)
+	}
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
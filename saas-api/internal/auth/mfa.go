@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFA token purposes
+const (
+	MFAPurposeVerify = "verify" // the user has 2FA and must enter a code
+	MFAPurposeEnroll = "enroll" // the user's org requires 2FA and the user has not enrolled yet
+)
+
+// MFATokenTTL is how long a user has to finish signing in after the password or OTP step
+const MFATokenTTL = 5 * time.Minute
+
+// MFAClaims is a sign-in that passed its first factor and waits for the second. It is returned in
+// place of the tokens and only accepted by the /auth/2fa endpoints.
+type MFAClaims struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Purpose string    `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAToken signs a pending sign-in, under a key of its own (see GenerateOIDCState)
+func (ts *TokenService) GenerateMFAToken(userID uuid.UUID, purpose string) (string, error) {
+	claims := &MFAClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFATokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "saas-api",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ts.stateKey("mfa"))
+}
+
+func (ts *TokenService) ValidateMFAToken(tokenString string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return ts.stateKey("mfa"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
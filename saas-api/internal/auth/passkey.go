@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Passkey ceremony purposes
+const (
+	PasskeyPurposeRegister = "register"
+	PasskeyPurposeLogin    = "login"
+)
+
+// PasskeyState is a WebAuthn ceremony in progress: the challenge and options the browser's response
+// is checked against. It travels to the browser and back as a signed token, so the API keeps no
+// state between beginning and finishing a registration or sign-in.
+type PasskeyState struct {
+	Purpose string               `json:"purpose"`
+	Session webauthn.SessionData `json:"session"`
+	jwt.RegisteredClaims
+}
+
+// GeneratePasskeyState signs a ceremony for ttl, under a key of its own (see GenerateOIDCState)
+func (ts *TokenService) GeneratePasskeyState(purpose string, session *webauthn.SessionData, ttl time.Duration) (string, error) {
+	st := &PasskeyState{
+		Purpose: purpose,
+		Session: *session,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "saas-api",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, st).SignedString(ts.stateKey("webauthn"))
+}
+
+func (ts *TokenService) ValidatePasskeyState(tokenString string) (*PasskeyState, error) {
+	st := &PasskeyState{}
+	_, err := jwt.ParseWithClaims(tokenString, st, func(token *jwt.Token) (interface{}, error) {
+		return ts.stateKey("webauthn"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
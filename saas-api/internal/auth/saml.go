@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"saas-api/config"
+
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	xrv "github.com/mattermost/xml-roundtrip-validator"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// maxSAMLMetadataBytes bounds IdP metadata, uploaded or fetched
+const maxSAMLMetadataBytes = 1 << 20
+
+// SAMLClient acts as the SAML service provider of every org. Each org gets its own entity ID and
+// ACS URL under SAML_BASE_URL, so the IdPs tell the orgs apart; the optional key pair is shared.
+type SAMLClient struct {
+	baseURL    string
+	key        crypto.Signer
+	cert       *x509.Certificate
+	httpClient *http.Client
+}
+
+// NewSAMLClient loads the service provider key pair when cfg names one
+func NewSAMLClient(cfg config.SAMLConfig) (*SAMLClient, error) {
+	c := &SAMLClient{baseURL: cfg.BaseURL, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return c, nil
+	}
+
+	pair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SAML SP key pair: %w", err)
+	}
+	c.cert, err = x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML SP certificate: %w", err)
+	}
+	switch key := pair.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		c.key = key
+	case *ecdsa.PrivateKey:
+		c.key = key
+	default:
+		return nil, errors.New("SAML SP key must be RSA or ECDSA")
+	}
+	return c, nil
+}
+
+// EntityID is the org's service provider entity ID, which is also where its SP metadata is served
+func (c *SAMLClient) EntityID(orgSlug string) string {
+	return c.baseURL + "/api/v1/auth/saml/" + url.PathEscape(orgSlug) + "/metadata"
+}
+
+// ACSURL is where the org's IdP posts its responses
+func (c *SAMLClient) ACSURL(orgSlug string) string {
+	return c.baseURL + "/api/v1/auth/saml/" + url.PathEscape(orgSlug) + "/acs"
+}
+
+// ServiceProvider is the SP this API acts as for the org with orgSlug, trusting the IdP idp describes
+func (c *SAMLClient) ServiceProvider(orgSlug string, idp *saml.EntityDescriptor) *saml.ServiceProvider {
+	metadataURL, _ := url.Parse(c.EntityID(orgSlug))
+	acsURL, _ := url.Parse(c.ACSURL(orgSlug))
+	sp := &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idp,
+		HTTPClient:        c.httpClient,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+	if c.key != nil {
+		sp.Key, sp.Certificate = c.key, c.cert
+		sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+		if _, ok := c.key.(*ecdsa.PrivateKey); ok {
+			sp.SignatureMethod = dsig.ECDSASHA256SignatureMethod
+		}
+	}
+	return sp
+}
+
+// SPMetadata is the org's service provider metadata. Only the HTTP-POST ACS binding is advertised,
+// as it is the only one the ACS handles.
+func (c *SAMLClient) SPMetadata(orgSlug string) *saml.EntityDescriptor {
+	md := c.ServiceProvider(orgSlug, nil).Metadata()
+	for i := range md.SPSSODescriptors {
+		d := &md.SPSSODescriptors[i]
+		acs := d.AssertionConsumerServices[:0]
+		for _, e := range d.AssertionConsumerServices {
+			if e.Binding == saml.HTTPPostBinding {
+				acs = append(acs, e)
+			}
+		}
+		d.AssertionConsumerServices = acs
+	}
+	return md
+}
+
+// FetchSAMLMetadata downloads IdP metadata from metadataURL
+func (c *SAMLClient) FetchSAMLMetadata(ctx context.Context, metadataURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch IdP metadata: %s returned %d", metadataURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSAMLMetadataBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+	}
+	if len(data) > maxSAMLMetadataBytes {
+		return nil, errors.New("IdP metadata is larger than 1 MB")
+	}
+	return data, nil
+}
+
+// ParseSAMLMetadata reads IdP metadata, either an EntityDescriptor or an EntitiesDescriptor holding
+// one, and checks it has what sign-in needs: an HTTP-Redirect SSO endpoint and a signing certificate
+func ParseSAMLMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	if len(data) > maxSAMLMetadataBytes {
+		return nil, errors.New("IdP metadata is larger than 1 MB")
+	}
+	if err := xrv.Validate(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid IdP metadata: %w", err)
+	}
+
+	idp := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, idp); err != nil {
+		entities := &saml.EntitiesDescriptor{}
+		if xml.Unmarshal(data, entities) != nil {
+			return nil, fmt.Errorf("invalid IdP metadata: %w", err)
+		}
+		idp = nil
+		for i, e := range entities.EntityDescriptors {
+			if len(e.IDPSSODescriptors) > 0 {
+				idp = &entities.EntityDescriptors[i]
+				break
+			}
+		}
+		if idp == nil {
+			return nil, errors.New("IdP metadata has no identity provider")
+		}
+	}
+
+	if idp.EntityID == "" || len(idp.IDPSSODescriptors) == 0 {
+		return nil, errors.New("IdP metadata has no identity provider")
+	}
+	if SAMLSSOURL(idp) == "" {
+		return nil, errors.New("IdP metadata has no HTTP-Redirect SingleSignOnService")
+	}
+	hasCert := false
+	for _, d := range idp.IDPSSODescriptors {
+		for _, kd := range d.KeyDescriptors {
+			if (kd.Use == "" || kd.Use == "signing") && len(kd.KeyInfo.X509Data.X509Certificates) > 0 {
+				hasCert = true
+			}
+		}
+	}
+	if !hasCert {
+		return nil, errors.New("IdP metadata has no signing certificate")
+	}
+	return idp, nil
+}
+
+// SAMLSSOURL is the IdP's HTTP-Redirect sign-in endpoint, empty when it has none
+func SAMLSSOURL(idp *saml.EntityDescriptor) string {
+	for _, d := range idp.IDPSSODescriptors {
+		for _, sso := range d.SingleSignOnServices {
+			if sso.Binding == saml.HTTPRedirectBinding {
+				return sso.Location
+			}
+		}
+	}
+	return ""
+}
+
+// AuthnRequestURL is the IdP's sign-in page for a new authentication request, and the request's ID,
+// which the response must answer
+func (c *SAMLClient) AuthnRequestURL(sp *saml.ServiceProvider, relayState string) (string, string, error) {
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", "", err
+	}
+	return redirectURL.String(), req.ID, nil
+}
+
+// SAMLIdentity is the subject and attributes of a verified assertion
+type SAMLIdentity struct {
+	NameID       string
+	NameIDFormat string
+	Attributes   map[string][]string // By Name and by FriendlyName
+}
+
+// Attribute returns the first value of the first of names the assertion carries
+func (id *SAMLIdentity) Attribute(names ...string) string {
+	for _, name := range names {
+		if values := id.Attributes[name]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// ParseResponse verifies the base64 SAMLResponse the IdP posted to the ACS URL: signature,
+// issuer, destination, audience, validity window and that it answers requestID
+func (c *SAMLClient) ParseResponse(sp *saml.ServiceProvider, samlResponse, requestID string) (*SAMLIdentity, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlResponse))
+	if err != nil {
+		return nil, fmt.Errorf("SAMLResponse is not base64: %w", err)
+	}
+	assertion, err := sp.ParseXMLResponse(raw, []string{requestID}, sp.AcsURL)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			return nil, fmt.Errorf("invalid SAML response: %w", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("invalid SAML response: %w", err)
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil {
+		return nil, errors.New("SAML assertion has no NameID")
+	}
+
+	identity := &SAMLIdentity{
+		NameID:       assertion.Subject.NameID.Value,
+		NameIDFormat: assertion.Subject.NameID.Format,
+		Attributes:   make(map[string][]string),
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			values := make([]string, 0, len(attr.Values))
+			for _, v := range attr.Values {
+				values = append(values, strings.TrimSpace(v.Value))
+			}
+			identity.Attributes[attr.Name] = append(identity.Attributes[attr.Name], values...)
+			if attr.FriendlyName != "" && attr.FriendlyName != attr.Name {
+				identity.Attributes[attr.FriendlyName] = append(identity.Attributes[attr.FriendlyName], values...)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// SAMLState is a sign-in in progress, kept in a signed cookie from the login redirect to the ACS.
+// RelayState goes to the IdP and must come back with the response; RequestID is the AuthnRequest
+// the response must answer.
+type SAMLState struct {
+	OrgID      uuid.UUID `json:"org_id"`
+	RelayState string    `json:"relay_state"`
+	RequestID  string    `json:"request_id"`
+	ReturnTo   string    `json:"return_to,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewSAMLState starts a sign-in for orgID with a fresh relay state
+func NewSAMLState(orgID uuid.UUID, returnTo string) *SAMLState {
+	return &SAMLState{OrgID: orgID, RelayState: rand.Text(), ReturnTo: returnTo}
+}
+
+// GenerateSAMLState signs st for ttl, under a key of its own (see GenerateOIDCState)
+func (ts *TokenService) GenerateSAMLState(st *SAMLState, ttl time.Duration) (string, error) {
+	st.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    "saas-api",
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, st).SignedString(ts.stateKey("saml-state"))
+}
+
+func (ts *TokenService) ValidateSAMLState(tokenString string) (*SAMLState, error) {
+	st := &SAMLState{}
+	_, err := jwt.ParseWithClaims(tokenString, st, func(token *jwt.Token) (interface{}, error) {
+		return ts.stateKey("saml-state"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
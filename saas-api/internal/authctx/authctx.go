@@ -0,0 +1,154 @@
+// Package authctx reads the caller identity AuthMiddleware puts on the Gin context. Handlers use
+// these getters instead of repeating the type assertions and UUID parsing, and get AppErrors they
+// can pass straight to respondError.
+package authctx
+
+import (
+	"net/http"
+	"time"
+
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Context keys set by AuthMiddleware
+const (
+	keyUserID         = "user_id"
+	keyEmail          = "email"
+	keyOrgID          = "org_id"
+	keyIsSuperAdmin   = "is_super_admin"
+	keyAPIKeyScopes   = "api_key_scopes"
+	keyImpersonatorID = "impersonator_id"
+	keyAuthTime       = "auth_time"
+	keyOAuthClientID  = "oauth_client_id"
+	keyPersonalToken  = "personal_token_id"
+)
+
+var (
+	ErrNotAuthenticated = errors.NewError("UNAUTHORIZED", "User not authenticated", http.StatusUnauthorized)
+	ErrInvalidUserID    = errors.NewError("UNAUTHORIZED", "Invalid user ID", http.StatusUnauthorized)
+	ErrNoOrganization   = errors.NewError("VALIDATION_ERROR", "Organization ID is required. Provide 'org_id' or ensure you are associated with an organization", http.StatusBadRequest)
+)
+
+// User is the authenticated caller
+type User struct {
+	ID           uuid.UUID
+	Email        string
+	OrgID        *uuid.UUID // nil for users without an org (e.g. super admins)
+	IsSuperAdmin bool
+}
+
+// CurrentUser returns the caller, or ErrNotAuthenticated / ErrInvalidUserID when the request did
+// not pass through AuthMiddleware or carries a malformed user ID
+func CurrentUser(c *gin.Context) (User, error) {
+	value, exists := c.Get(keyUserID)
+	if !exists || value == nil {
+		return User{}, ErrNotAuthenticated
+	}
+	userIDStr, ok := value.(string)
+	if !ok || userIDStr == "" {
+		return User{}, ErrInvalidUserID
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return User{}, ErrInvalidUserID
+	}
+
+	return User{
+		ID:           userID,
+		Email:        c.GetString(keyEmail),
+		OrgID:        CurrentOrg(c),
+		IsSuperAdmin: IsSuperAdmin(c),
+	}, nil
+}
+
+// UserID returns the caller's ID for audit columns (created_by, updated_by...); nil when unknown
+func UserID(c *gin.Context) *uuid.UUID {
+	user, err := CurrentUser(c)
+	if err != nil {
+		return nil
+	}
+	return &user.ID
+}
+
+// CurrentOrg returns the caller's own org, or nil when the caller has none
+func CurrentOrg(c *gin.Context) *uuid.UUID {
+	orgIDStr := c.GetString(keyOrgID)
+	if orgIDStr == "" {
+		return nil
+	}
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return nil
+	}
+	return &orgID
+}
+
+// MustOrg returns the caller's own org, or ErrNoOrganization when the caller has none
+func MustOrg(c *gin.Context) (uuid.UUID, error) {
+	orgID := CurrentOrg(c)
+	if orgID == nil {
+		return uuid.Nil, ErrNoOrganization
+	}
+	return *orgID, nil
+}
+
+// IsSuperAdmin reports whether the caller is a super admin
+func IsSuperAdmin(c *gin.Context) bool {
+	return c.GetBool(keyIsSuperAdmin)
+}
+
+// APIKeyScopes returns the scopes of the API key, OAuth client token or personal access token the
+// caller authenticated with; ok is false when the caller used a user's bearer token, whose access is
+// its user's permissions alone
+func APIKeyScopes(c *gin.Context) (scopes []string, ok bool) {
+	value, exists := c.Get(keyAPIKeyScopes)
+	if !exists {
+		return nil, false
+	}
+	scopes, ok = value.([]string)
+	return scopes, ok
+}
+
+// PersonalTokenID returns the personal access token the caller authenticated with, or nil when the
+// caller did not use one
+func PersonalTokenID(c *gin.Context) *uuid.UUID {
+	tokenID, err := uuid.Parse(c.GetString(keyPersonalToken))
+	if err != nil {
+		return nil
+	}
+	return &tokenID
+}
+
+// OAuthClientID returns the OAuth client a client_credentials token was issued to, or nil when the
+// caller did not use one
+func OAuthClientID(c *gin.Context) *uuid.UUID {
+	clientID, err := uuid.Parse(c.GetString(keyOAuthClientID))
+	if err != nil {
+		return nil
+	}
+	return &clientID
+}
+
+// ImpersonatorID returns the super admin acting as the caller, or nil when the caller is not being
+// impersonated
+func ImpersonatorID(c *gin.Context) *uuid.UUID {
+	impersonatorID, err := uuid.Parse(c.GetString(keyImpersonatorID))
+	if err != nil {
+		return nil
+	}
+	return &impersonatorID
+}
+
+// AuthTime returns when the caller last authenticated (the token's auth_time); ok is false for API
+// keys and tokens without one
+func AuthTime(c *gin.Context) (authTime time.Time, ok bool) {
+	value, exists := c.Get(keyAuthTime)
+	if !exists {
+		return time.Time{}, false
+	}
+	authTime, ok = value.(time.Time)
+	return authTime, ok
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccessReviewHandler struct {
+	reviews *services.AccessReviewService
+}
+
+func NewAccessReviewHandler(reviews *services.AccessReviewService) *AccessReviewHandler {
+	return &AccessReviewHandler{reviews: reviews}
+}
+
+// Export returns every user's roles, effective permissions, folder grants and super-admin flag
+// GET /api/v1/admin/access-review?org_id=&format=json|csv&async=true
+// With async=true the review runs in the background and 202 returns the job to poll.
+func (h *AccessReviewHandler) Export(c *gin.Context) {
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if !services.ValidReviewFormat(format) {
+		respondError(c, services.ErrInvalidReviewFormat, "Invalid format")
+		return
+	}
+
+	var orgID *uuid.UUID
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
+		parsed, err := uuid.Parse(orgIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid organization ID",
+			})
+			return
+		}
+		orgID = &parsed
+	}
+
+	if async, _ := strconv.ParseBool(c.Query("async")); async {
+		job, err := h.reviews.StartJob(orgID, caller.ID, format)
+		if err != nil {
+			respondError(c, err, "Failed to start access review")
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	review, err := h.reviews.Generate(c.Request.Context(), orgID, caller.ID, format)
+	if err != nil {
+		respondError(c, err, "Failed to generate access review")
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, review)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.reviews.Write(&buf, review, format); err != nil {
+		respondError(c, err, "Failed to write access review")
+		return
+	}
+	sendAccessReviewFile(c, format, review.GeneratedAt, buf.Bytes())
+}
+
+// Job reports an asynchronous review's status
+// GET /api/v1/admin/access-review/jobs/:id
+func (h *AccessReviewHandler) Job(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid job ID",
+		})
+		return
+	}
+
+	job, err := h.reviews.Job(id)
+	if err != nil {
+		respondError(c, err, "Failed to get access review")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Download returns a completed asynchronous review
+// GET /api/v1/admin/access-review/jobs/:id/download
+func (h *AccessReviewHandler) Download(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid job ID",
+		})
+		return
+	}
+
+	job, output, err := h.reviews.JobOutput(id)
+	if err != nil {
+		respondError(c, err, "Failed to get access review")
+		return
+	}
+	sendAccessReviewFile(c, job.Format, *job.CompletedAt, output)
+}
+
+func sendAccessReviewFile(c *gin.Context, format string, generatedAt time.Time, data []byte) {
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"access-review-%s.%s\"", generatedAt.UTC().Format("20060102-150405"), format))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, data)
+}
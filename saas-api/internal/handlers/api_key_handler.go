@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// APIKeyHandler serves the org's API keys. The key itself is only in the create response; listings
+// show its prefix. Super admins pick the org with org_id.
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// List returns the org's API keys, including revoked ones
+// GET /api/v1/api-keys?org_id=
+func (h *APIKeyHandler) List(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.List(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, err, "Failed to list API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// Create issues a key; the response is the only time the key is returned
+// POST /api/v1/api-keys
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	subject := policy.FromContext(c)
+	orgID, err := subject.TargetOrg(req.OrgID)
+	if err != nil {
+		respondError(c, err, "Access denied")
+		return
+	}
+
+	key, err := h.apiKeyService.Create(c.Request.Context(), orgID, req, subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": key})
+}
+
+// GetByID returns one of the org's API keys
+// GET /api/v1/api-keys/:id?org_id=
+func (h *APIKeyHandler) GetByID(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	keyID, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.Get(c.Request.Context(), orgID, keyID)
+	if err != nil {
+		respondError(c, err, "Failed to get API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": key})
+}
+
+// Revoke stops a key from authenticating
+// DELETE /api/v1/api-keys/:id?org_id=
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	keyID, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	key, err := h.apiKeyService.Revoke(c.Request.Context(), orgID, keyID, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    key,
+		"message": "API key revoked successfully",
+	})
+}
+
+// apiKeyOrgQuery resolves the org whose keys are managed: the caller's own, or ?org_id= for super admins
+func apiKeyOrgQuery(c *gin.Context) (uuid.UUID, bool) {
+	var requested *uuid.UUID
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
+		orgID, err := uuid.Parse(orgIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid organization ID",
+			})
+			return uuid.Nil, false
+		}
+		requested = &orgID
+	}
+
+	orgID, err := policy.FromContext(c).TargetOrg(requested)
+	if err != nil {
+		respondError(c, err, "Access denied")
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+func apiKeyIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid API key ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/orgtime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type APIUsageHandler struct {
+	usageRepo *repositories.APIUsageRepository
+	timezones *orgtime.Resolver
+}
+
+func NewAPIUsageHandler(usageRepo *repositories.APIUsageRepository, timezones *orgtime.Resolver) *APIUsageHandler {
+	return &APIUsageHandler{
+		usageRepo: usageRepo,
+		timezones: timezones,
+	}
+}
+
+// Report returns per-endpoint usage by org for the requested window (default: last 7 days)
+// Query params: from, to (RFC3339 or YYYY-MM-DD), org_id, route, sort (requests|errors|latency), limit,
+// interval (day|month, adds period_start), timezone (IANA name; defaults to the org's, else UTC).
+// Dates and periods are local to the effective timezone.
+func (h *APIUsageHandler) Report(c *gin.Context) {
+	now := time.Now().UTC()
+	filter := models.APIUsageReportFilter{
+		SortBy:   c.DefaultQuery("sort", "requests"),
+		Interval: c.Query("interval"),
+	}
+
+	if filter.Interval != "" && filter.Interval != "day" && filter.Interval != "month" {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid 'interval' value, expected day or month",
+		})
+		return
+	}
+
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
+		orgID, err := uuid.Parse(orgIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid organization ID",
+			})
+			return
+		}
+		filter.OrgID = &orgID
+	}
+
+	var loc *time.Location
+	if timezone := c.Query("timezone"); timezone != "" {
+		if !orgtime.Valid(timezone) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid 'timezone' value, expected an IANA name such as Asia/Kolkata",
+			})
+			return
+		}
+		filter.Timezone, loc = timezone, orgtime.Location(timezone)
+	} else {
+		filter.Timezone, loc = h.timezones.Zone(c.Request.Context(), filter.OrgID)
+	}
+
+	filter.From, filter.To = now.Add(-7*24*time.Hour), now
+	if filter.Interval != "" {
+		// Start on a period boundary so the first day is not partial
+		filter.From = orgtime.DaysBefore(now, loc, 7).UTC()
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := parseUsageTime(fromStr, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid 'from' value, expected RFC3339 or YYYY-MM-DD",
+			})
+			return
+		}
+		filter.From = from
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := parseUsageTime(toStr, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid 'to' value, expected RFC3339 or YYYY-MM-DD",
+			})
+			return
+		}
+		filter.To = to
+	}
+
+	if !filter.From.Before(filter.To) {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "'from' must be before 'to'",
+		})
+		return
+	}
+
+	if route := c.Query("route"); route != "" {
+		filter.Route = &route
+	}
+
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if filter.Limit < 1 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+
+	report, err := h.usageRepo.Report(c.Request.Context(), filter)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.Status, errors.ErrorResponse{
+				Error:   appErr.Code,
+				Message: appErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to retrieve API usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     report,
+		"from":     filter.From,
+		"to":       filter.To,
+		"timezone": filter.Timezone,
+	})
+}
+
+// parseUsageTime returns a UTC time; dates are local midnight in loc, because buckets are stored in UTC
+func parseUsageTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := orgtime.ParseDate(value, loc)
+	return t.UTC(), err
+}
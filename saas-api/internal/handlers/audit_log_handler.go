@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"net"
 	"net/http"
+	"saas-api/config"
+	"saas-api/internal/authctx"
+	"saas-api/internal/models"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,11 +18,15 @@ import (
 
 type AuditLogHandler struct {
 	auditLogRepo *repositories.AuditLogRepository
+	userRepo     *repositories.UserRepository
+	proxyConfig  config.ProxyConfig
 }
 
-func NewAuditLogHandler(auditLogRepo *repositories.AuditLogRepository) *AuditLogHandler {
+func NewAuditLogHandler(auditLogRepo *repositories.AuditLogRepository, userRepo *repositories.UserRepository, proxyConfig config.ProxyConfig) *AuditLogHandler {
 	return &AuditLogHandler{
 		auditLogRepo: auditLogRepo,
+		userRepo:     userRepo,
+		proxyConfig:  proxyConfig,
 	}
 }
 
@@ -39,20 +49,14 @@ func (h *AuditLogHandler) List(c *gin.Context) {
 	var action *string
 	var resourceType *string
 
-	// Get org_id from query or context
-	orgIDStr := c.Query("org_id")
-	if orgIDStr == "" {
-		// Try to get from context (for regular users)
-		orgIDFromCtx, exists := c.Get("org_id")
-		if exists && orgIDFromCtx != nil {
-			orgIDStr = orgIDFromCtx.(string)
-		}
-	}
-	if orgIDStr != "" {
+	// Get org_id from query, falling back to the caller's org (for regular users)
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
 		parsed, err := uuid.Parse(orgIDStr)
 		if err == nil {
 			orgID = &parsed
 		}
+	} else {
+		orgID = authctx.CurrentOrg(c)
 	}
 
 	// Get user_id from query
@@ -138,3 +142,70 @@ func (h *AuditLogHandler) GetByID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, log)
 }
+
+// RecordProxyLogin stores a login attempt on the LibreChat proxy as a "proxy.login" audit log, so
+// chat gateway sign-ins show up next to the rest of the audit trail. The entry is attributed to the
+// user and org with that email, when there is one. Requires the X-Proxy-Secret header, like secret
+// resolution; disabled when PROXY_SHARED_SECRET is unset.
+// POST /api/v1/internal/audit/proxy-login
+func (h *AuditLogHandler) RecordProxyLogin(c *gin.Context) {
+	if h.proxyConfig.SharedSecret == "" {
+		respondError(c, errors.NewError("NOT_FOUND", "Proxy audit events are not enabled", http.StatusNotFound), "")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Proxy-Secret")), []byte(h.proxyConfig.SharedSecret)) != 1 {
+		respondError(c, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.ProxyLoginEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	entry := &models.AuditLog{
+		Action: "proxy.login",
+		Status: req.Outcome,
+		Metadata: map[string]interface{}{
+			"email":  email,
+			"source": "librechat_proxy",
+		},
+	}
+	// ip_address is INET, so anything else would fail the insert
+	if ip := net.ParseIP(req.IPAddress); ip != nil {
+		ipAddress := ip.String()
+		entry.IPAddress = &ipAddress
+	}
+	if req.UserAgent != "" {
+		entry.UserAgent = &req.UserAgent
+	}
+	if req.Reason != "" {
+		entry.Metadata["reason"] = req.Reason
+		if req.Outcome == "failure" {
+			entry.ErrorMessage = &req.Reason
+		}
+	}
+
+	// Unknown emails are still recorded, without a user or org
+	if email != "" {
+		if user, err := h.userRepo.GetByEmail(c.Request.Context(), email); err == nil {
+			resourceType := "user"
+			entry.UserID = &user.ID
+			entry.OrgID = user.OrgID
+			entry.ResourceType = &resourceType
+			entry.ResourceID = &user.ID
+		}
+	}
+
+	if err := h.auditLogRepo.Create(c.Request.Context(), entry); err != nil {
+		respondError(c, err, "Failed to record login event")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": entry.ID})
+}
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
+	"saas-api/internal/authctx"
 	"saas-api/internal/middleware"
 	"saas-api/internal/models"
 	"saas-api/internal/repositories"
@@ -10,13 +13,14 @@ import (
 	"saas-api/pkg/errors"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
 	authService *services.AuthService
 	authMW      *middleware.AuthMiddleware
 	orgRepo     *repositories.OrganizationRepository
+	oidcService *services.OIDCService // nil: OIDC sign-in routes answer 503
+	samlService *services.SAMLService // nil: SAML sign-in routes answer 503
 }
 
 func NewAuthHandler(authService *services.AuthService, authMW *middleware.AuthMiddleware, orgRepo *repositories.OrganizationRepository) *AuthHandler {
@@ -27,6 +31,16 @@ func NewAuthHandler(authService *services.AuthService, authMW *middleware.AuthMi
 	}
 }
 
+// SetOIDC enables single sign-on through the organizations' OIDC identity providers
+func (h *AuthHandler) SetOIDC(oidcService *services.OIDCService) {
+	h.oidcService = oidcService
+}
+
+// SetSAML enables single sign-on through the organizations' SAML identity providers
+func (h *AuthHandler) SetSAML(samlService *services.SAMLService) {
+	h.samlService = samlService
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -120,25 +134,74 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *AuthHandler) Logout(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
+// ProxyTokenExchange issues a short-lived access token for the LibreChat proxy so it can fetch
+// documents on behalf of a user that only holds the proxy cookie. Requires the X-Proxy-Secret header.
+func (h *AuthHandler) ProxyTokenExchange(c *gin.Context) {
+	var req models.ProxyTokenExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.authService.ExchangeProxyToken(c.Request.Context(), c.GetHeader("X-Proxy-Secret"), req.Token)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.Status, errors.ErrorResponse{
+				Error:   appErr.Code,
+				Message: appErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Token exchange failed",
 		})
 		return
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect tells a sibling service whether an access token is active and whose it is (RFC 7662).
+// Requires the X-Proxy-Secret header.
+// POST /api/v1/auth/introspect
+func (h *AuthHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.authService.Introspect(c.Request.Context(), c.GetHeader("X-Proxy-Secret"), req)
+	if err != nil {
+		respondError(c, err, "Token introspection failed")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
 	var req models.RefreshTokenRequest
 	refreshToken := ""
 	if c.ShouldBindJSON(&req) == nil {
 		refreshToken = req.RefreshToken
 	}
 
-	uid, _ := uuid.Parse(userID.(string))
-	err := h.authService.Logout(c.Request.Context(), refreshToken, uid)
-	if err != nil {
+	if err := h.authService.Logout(c.Request.Context(), refreshToken, user.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
 			Message: "Logout failed",
@@ -150,37 +213,29 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 }
 
 func (h *AuthHandler) Me(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
-		})
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
 		return
 	}
 
-	// Get user details from service
-	// This would require a user service method
-	orgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
 	response := gin.H{
-		"user_id":        userID,
-		"email":          c.GetString("email"),
-		"org_id":         orgID,
-		"is_super_admin": isSuperAdmin,
+		"user_id":        user.ID,
+		"email":          user.Email,
+		"org_id":         user.OrgID,
+		"is_super_admin": user.IsSuperAdmin,
+	}
+	if impersonatorID := authctx.ImpersonatorID(c); impersonatorID != nil {
+		response["impersonator_id"] = impersonatorID
 	}
 
 	// If user has an organization, fetch organization details including logo
-	if orgID != nil && orgID != "" {
-		orgUUID, err := uuid.Parse(orgID.(string))
-		if err == nil {
-			org, err := h.orgRepo.GetByID(c.Request.Context(), orgUUID)
-			if err == nil && org != nil {
-				response["org_name"] = org.Name
-				if org.LogoURL != nil {
-					response["org_logo_url"] = *org.LogoURL
-				}
+	if user.OrgID != nil {
+		org, err := h.orgRepo.GetByID(c.Request.Context(), *user.OrgID)
+		if err == nil && org != nil {
+			response["org_name"] = org.Name
+			if org.LogoURL != nil {
+				response["org_logo_url"] = *org.LogoURL
 			}
 		}
 	}
@@ -251,6 +306,32 @@ func (h *AuthHandler) VerifyOTP(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SetOTPChannel chooses whether the current user gets sign-in codes by email or SMS
+// PUT /api/v1/auth/otp-channel
+func (h *AuthHandler) SetOTPChannel(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	var req models.SetOTPChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.SetOTPChannel(c.Request.Context(), user.ID, req.Channel); err != nil {
+		respondError(c, err, "Failed to set OTP channel")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OTP channel updated successfully"})
+}
+
 // ResendOTP resends OTP to user's email
 func (h *AuthHandler) ResendOTP(c *gin.Context) {
 	var req models.ResendOTPRequest
@@ -280,3 +361,186 @@ func (h *AuthHandler) ResendOTP(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// oidcStateCookie carries the sign-in in progress from OIDCLogin to OIDCCallback
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/v1/auth/oidc"
+)
+
+// OIDCLogin sends the browser to the organization's identity provider (authorization code flow
+// with PKCE). return_to is the path on the frontend to land on once signed in.
+// GET /api/v1/auth/oidc/:org_slug/login?return_to=/path
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	if h.oidcService == nil {
+		respondError(c, services.ErrOIDCDisabled, "")
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if !isReturnPath(returnTo) {
+		returnTo = "/"
+	}
+
+	authURL, state, err := h.oidcService.Start(c.Request.Context(), c.Param("org_slug"), returnTo)
+	if err != nil {
+		respondError(c, err, "Failed to start single sign-on")
+		return
+	}
+
+	// Lax, so the cookie comes back with the IdP's top-level redirect to the callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(services.OIDCStateTTL.Seconds()), oidcStateCookiePath, "", isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes the sign-in the identity provider redirected back. The tokens are set as
+// the access_token and refreshToken cookies, like a token refresh, and the browser is sent to the
+// return_to path; the frontend reads the tokens with POST /api/v1/auth/refresh.
+// GET /api/v1/auth/oidc/callback?code=...&state=...
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if h.oidcService == nil {
+		respondError(c, services.ErrOIDCDisabled, "")
+		return
+	}
+
+	// The state cookie is good for one attempt
+	stateCookie, _ := c.Cookie(oidcStateCookie)
+	secure := isSecureRequest(c)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", secure, true)
+
+	if idpErr := c.Query("error"); idpErr != "" {
+		message := c.Query("error_description")
+		if message == "" {
+			message = idpErr
+		}
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error:   "OIDC_LOGIN_FAILED",
+			Message: "The identity provider refused the sign-in: " + message,
+		})
+		return
+	}
+
+	ipAddress := h.authMW.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	response, returnTo, err := h.oidcService.Callback(c.Request.Context(), stateCookie, c.Query("state"), c.Query("code"), ipAddress, userAgent)
+	if err != nil {
+		respondError(c, err, "Single sign-on failed")
+		return
+	}
+
+	setSSOSessionCookies(c, response, secure)
+	c.Redirect(http.StatusFound, h.oidcService.FrontendURL()+returnTo)
+}
+
+// samlStateCookie carries the sign-in in progress from SAMLLogin to SAMLACS
+const (
+	samlStateCookie     = "saml_state"
+	samlStateCookiePath = "/api/v1/auth/saml"
+)
+
+// SAMLMetadata serves the organization's service provider metadata, to be registered at its IdP
+// GET /api/v1/auth/saml/:org_slug/metadata
+func (h *AuthHandler) SAMLMetadata(c *gin.Context) {
+	if h.samlService == nil {
+		respondError(c, services.ErrSAMLDisabled, "")
+		return
+	}
+
+	metadata, err := h.samlService.Metadata(c.Request.Context(), c.Param("org_slug"))
+	if err != nil {
+		respondError(c, err, "Failed to get SAML metadata")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLLogin sends the browser to the organization's SAML identity provider (HTTP-Redirect binding).
+// return_to is the path on the frontend to land on once signed in.
+// GET /api/v1/auth/saml/:org_slug/login?return_to=/path
+func (h *AuthHandler) SAMLLogin(c *gin.Context) {
+	if h.samlService == nil {
+		respondError(c, services.ErrSAMLDisabled, "")
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if !isReturnPath(returnTo) {
+		returnTo = "/"
+	}
+
+	redirectURL, state, err := h.samlService.Start(c.Request.Context(), c.Param("org_slug"), returnTo)
+	if err != nil {
+		respondError(c, err, "Failed to start single sign-on")
+		return
+	}
+
+	secure := isSecureRequest(c)
+	setSAMLStateCookie(c, state, int(services.SAMLStateTTL.Seconds()), secure)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// SAMLACS is the assertion consumer service: it completes the sign-in the identity provider posted
+// back (HTTP-POST binding). Tokens and the redirect work as in OIDCCallback.
+// POST /api/v1/auth/saml/:org_slug/acs (form: SAMLResponse, RelayState)
+func (h *AuthHandler) SAMLACS(c *gin.Context) {
+	if h.samlService == nil {
+		respondError(c, services.ErrSAMLDisabled, "")
+		return
+	}
+
+	// The state cookie is good for one attempt
+	stateCookie, _ := c.Cookie(samlStateCookie)
+	secure := isSecureRequest(c)
+	setSAMLStateCookie(c, "", -1, secure)
+
+	ipAddress := h.authMW.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	response, returnTo, err := h.samlService.ACS(c.Request.Context(), c.Param("org_slug"), stateCookie,
+		c.PostForm("RelayState"), c.PostForm("SAMLResponse"), ipAddress, userAgent)
+	if err != nil {
+		respondError(c, err, "Single sign-on failed")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	setSSOSessionCookies(c, response, secure)
+	c.Redirect(http.StatusFound, h.samlService.FrontendURL()+returnTo)
+}
+
+// setSAMLStateCookie sets (or, with maxAge -1, clears) the SAML state cookie. The IdP posts to the
+// ACS from its own site, and browsers only send cookies with such a POST when they are
+// SameSite=None, which requires Secure; over plain HTTP the cookie falls back to Lax.
+func setSAMLStateCookie(c *gin.Context, value string, maxAge int, secure bool) {
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(samlStateCookie, value, maxAge, samlStateCookiePath, "", secure, true)
+}
+
+// setSSOSessionCookies sets the access_token and refreshToken cookies of a single sign-on, which the
+// frontend exchanges with POST /api/v1/auth/refresh
+func setSSOSessionCookies(c *gin.Context, response *models.LoginResponse, secure bool) {
+	c.SetCookie("access_token", response.AccessToken, int(response.ExpiresIn), "/", "", secure, true)
+	c.SetCookie("refreshToken", response.RefreshToken, 30*24*60*60, "/", "", secure, true) // 30 days
+}
+
+// isReturnPath accepts a local path only, so return_to cannot send the browser to another site
+func isReturnPath(p string) bool {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return false
+	}
+	u, err := url.Parse(p)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// isSecureRequest reports whether the client connected over HTTPS, directly or through a proxy
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -2,8 +2,21 @@ package handlers
 
 import (
 	"context"
+	"mime"
+	"net/http"
+	"path"
+	"saas-api/config"
 	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // BaseHandler provides common functionality and dependencies for all handlers
@@ -23,6 +36,116 @@ func (h *BaseHandler) GetServices() *services.Services {
 	return h.services
 }
 
+// respondError writes err as an ErrorResponse when it is an AppError,
+// otherwise a 500 with fallbackMessage
+func respondError(c *gin.Context, err error, fallbackMessage string) {
+	if appErr, ok := err.(*errors.AppError); ok {
+		c.JSON(appErr.Status, errors.ErrorResponse{
+			Error:   appErr.Code,
+			Message: appErr.Message,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+		Error:   errors.ErrInternalServer.Code,
+		Message: fallbackMessage,
+	})
+}
+
+// respondForbidden writes a 403 with a handler-specific message
+func respondForbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, errors.ErrorResponse{
+		Error:   errors.ErrForbidden.Code,
+		Message: message,
+	})
+}
+
+// serveStoredFile sends the file at key in store as filename, with disposition "inline" or
+// "attachment". Local files are sent from disk; attachments on backends that sign URLs redirect the
+// client to a URL valid for ttl, so they do not stream through the API.
+func serveStoredFile(c *gin.Context, store storage.Storage, key, filename, disposition string, ttl time.Duration) {
+	ctx := c.Request.Context()
+	exists, err := store.Exists(ctx, key)
+	if err != nil {
+		respondError(c, err, "Failed to access file")
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error:   errors.ErrNotFound.Code,
+			Message: "File not found",
+		})
+		return
+	}
+	contentDisposition := mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+
+	if local, ok := store.(*storage.Local); ok {
+		diskPath, err := local.Path(key)
+		if err != nil {
+			respondError(c, err, "Failed to access file")
+			return
+		}
+		c.Header("Content-Disposition", contentDisposition)
+		c.File(diskPath)
+		return
+	}
+
+	if disposition == "attachment" {
+		signedURL, err := store.SignedURL(ctx, key, filename, ttl)
+		if err == nil {
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+		if err != storage.ErrSignedURLNotSupported {
+			respondError(c, err, "Failed to sign file URL")
+			return
+		}
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		respondError(c, err, "Failed to read file")
+		return
+	}
+	defer body.Close()
+	mimeType := getMimeType(strings.ToLower(strings.TrimPrefix(path.Ext(key), ".")))
+	c.DataFromReader(http.StatusOK, -1, mimeType, body, map[string]string{"Content-Disposition": contentDisposition})
+}
+
+// orgIDParam resolves :id and checks the caller belongs to it (super admins may manage any org)
+func orgIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid organization ID",
+		})
+		return uuid.Nil, false
+	}
+
+	if err := policy.FromContext(c).CanAccess(&id); err != nil {
+		respondError(c, err, "Access denied")
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// canManageUser checks the caller may act on user: one of their org other than a super admin (super
+// admins may manage anyone). It answers 403 with outsideOrgMessage, or for super admins, otherwise.
+func canManageUser(c *gin.Context, user *models.User, outsideOrgMessage string) bool {
+	subject := policy.FromContext(c)
+	if err := subject.CanAccess(user.OrgID); err != nil {
+		respondForbidden(c, outsideOrgMessage)
+		return false
+	}
+	if user.IsSuperAdmin && !subject.IsSuperAdmin {
+		respondForbidden(c, "Only super admins can manage super admins")
+		return false
+	}
+	return true
+}
+
 // Handlers holds all handler instances
 type Handlers struct {
 	Auth         *AuthHandler
@@ -46,24 +169,26 @@ type Handlers struct {
 func NewHandlers(services *services.Services, storagePath string, authService *services.AuthService, authMW *middleware.AuthMiddleware) *Handlers {
 	repos := services.GetRepositories()
 
-	// Get document service for file handler (if available)
+	// Get document service for file handler (if available), whose storage the file handler shares
 	var docService interface {
 		DeleteDocument(ctx context.Context, documentID int64) error
 	}
+	var fileStorage storage.Storage = storage.NewLocal(storagePath)
 	if services.Document != nil {
 		docService = services.Document
+		fileStorage = services.Document.Storage
 	}
 
 	return &Handlers{
 		Auth:         NewAuthHandler(authService, authMW, repos.Organization),
-		User:         NewUserHandler(repos.User, repos.Role, repos.Organization),
+		User:         NewUserHandler(repos.User, repos.Role, repos.Organization, nil), // LibreChat profile sync is wired in cmd/api
 		Document:     NewDocumentHandler(services),
 		Folder:       NewFolderHandler(repos.Folder, repos.Document), // Update folder handler if needed
-		File:         NewFileHandler(repos.Folder, repos.Document, docService, storagePath),
-		Permission:   NewPermissionHandler(repos.Permission),
+		File:         NewFileHandler(repos.Folder, repos.Document, docService, fileStorage),
+		Permission:   NewPermissionHandler(services.Permission),
 		Role:         NewRoleHandler(repos.Role),
 		Organization: NewOrganizationHandler(repos.Organization, repos.Role, repos.Permission),
-		AuditLog:     NewAuditLogHandler(repos.AuditLog),
+		AuditLog:     NewAuditLogHandler(repos.AuditLog, repos.User, config.ProxyConfig{}), // proxy login events are wired in cmd/api
 		Persona:      NewPersonaHandler(repos.Persona),
 		Template:     NewTemplateHandler(repos.Template),
 		LibreChat:    NewLibreChatHandler(),
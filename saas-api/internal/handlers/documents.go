@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"path"
 	"path/filepath"
+	"saas-api/internal/authctx"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/repositories"
 	"saas-api/internal/services"
 	"strconv"
 	"strings"
@@ -26,19 +29,11 @@ func NewDocumentHandler(services *services.Services) *DocumentHandler {
 // UploadDocument handles the POST /api/v1/documents/upload endpoint
 func (h *DocumentHandler) UploadDocument() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get user_id from context (should be set by auth middleware)
-		userID, exists := c.Get("user_id")
-		if !exists || userID == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "User not authenticated",
-			})
-			return
-		}
-
-		userIDStr, ok := userID.(string)
-		if !ok || userIDStr == "" {
+		// Get the caller (set by auth middleware)
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid user ID",
+				"error": err.Error(),
 			})
 			return
 		}
@@ -52,34 +47,16 @@ func (h *DocumentHandler) UploadDocument() gin.HandlerFunc {
 			return
 		}
 
-		// Get org_id from context or form data (nullable for superadmins)
-		var orgID *uuid.UUID
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		isSuperAdminBool := false
-		if isSuperAdmin != nil {
-			if val, ok := isSuperAdmin.(bool); ok {
-				isSuperAdminBool = val
-			}
-		}
-
-		// Try to get org_id from form data first
+		// Resolve owning org: super admins may pass org_id (or upload without an org),
+		// everyone else always uploads into their own org
+		var requestedOrgID *uuid.UUID
 		if orgIDStr := c.PostForm("org_id"); orgIDStr != "" {
 			if parsedOrgID, err := uuid.Parse(orgIDStr); err == nil {
-				orgID = &parsedOrgID
-			}
-		} else if !isSuperAdminBool {
-			// Non-superadmin: fallback to context org_id
-			if orgIDVal, exists := c.Get("org_id"); exists && orgIDVal != nil {
-				if orgIDStr, ok := orgIDVal.(string); ok && orgIDStr != "" {
-					if parsedOrgID, err := uuid.Parse(orgIDStr); err == nil {
-						orgID = &parsedOrgID
-					}
-				}
+				requestedOrgID = &parsedOrgID
 			}
 		}
-
-		// org_id is required for non-superadmin users only
-		if !isSuperAdminBool && orgID == nil {
+		orgID, err := policy.FromContext(c).OwnerOrg(requestedOrgID)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "org_id is required for non-superadmin users",
 			})
@@ -105,67 +82,31 @@ func (h *DocumentHandler) UploadDocument() gin.HandlerFunc {
 		filename = strings.ReplaceAll(filename, " ", "_")
 		filename = strings.ReplaceAll(filename, "'", "")
 
-		// Construct file path (relative to ResourcesBasePath for database storage)
-		// Full disk path for saving, relative path for database
-		var diskPath string   // Full path for saving file to disk
-		var dbFilePath string // Relative path for database storage
-
-		if orgID != nil {
-			// Include org_id and folder path for organization files
-			// Structure: uploads/{org_id}/{folder_path}/filename
-			var pathComponents []string
-			pathComponents = append(pathComponents, h.Services.Document.ResourcesBasePath, orgID.String())
-			if folderPath != "" {
-				pathComponents = append(pathComponents, folderPath)
-			}
-			pathComponents = append(pathComponents, filename)
-
-			// Create directory structure if it doesn't exist
-			fileDir := path.Join(pathComponents[:len(pathComponents)-1]...)
-			if err := os.MkdirAll(fileDir, 0755); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "failed to create directory structure",
-				})
-				return
-			}
-			diskPath = path.Join(pathComponents...)
-
-			// Store relative path in database: {org_id}/{folder_path}/filename
-			var dbPathComponents []string
-			dbPathComponents = append(dbPathComponents, orgID.String())
-			if folderPath != "" {
-				dbPathComponents = append(dbPathComponents, folderPath)
-			}
-			dbPathComponents = append(dbPathComponents, filename)
-			dbFilePath = path.Join(dbPathComponents...)
-		} else {
-			// Superadmin files: uploads/{folder_path}/filename or uploads/filename
-			var pathComponents []string
-			pathComponents = append(pathComponents, h.Services.Document.ResourcesBasePath)
-			if folderPath != "" {
-				pathComponents = append(pathComponents, folderPath)
-			}
-			pathComponents = append(pathComponents, filename)
+		// Construct the storage key, as stored in the database
+		dbFilePath := uploadKey(orgID, folderPath, filename)
 
-			// Create directory structure if it doesn't exist
-			fileDir := path.Join(pathComponents[:len(pathComponents)-1]...)
-			if err := os.MkdirAll(fileDir, 0755); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "failed to create directory structure",
+		// Parse optional metadata (JSON string)
+		var metadata map[string]interface{}
+		if metadataStr := c.PostForm("metadata"); metadataStr != "" {
+			if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "invalid metadata format, expected JSON",
 				})
 				return
 			}
-			diskPath = path.Join(pathComponents...)
+		}
 
-			// Store relative path in database: {folder_path}/filename or just filename
-			if folderPath != "" {
-				dbFilePath = path.Join(folderPath, filename)
-			} else {
-				dbFilePath = filename
-			}
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "failed to read file",
+			})
+			return
 		}
+		defer src.Close()
 
-		err = c.SaveUploadedFile(file, diskPath)
+		// Identical bytes already uploaded in this org are shared rather than written again
+		stored, err := h.Services.Document.StoreUpload(c.Request.Context(), orgID, src, dbFilePath)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "failed to save file",
@@ -173,23 +114,16 @@ func (h *DocumentHandler) UploadDocument() gin.HandlerFunc {
 			return
 		}
 
-		// Parse optional metadata (JSON string)
-		var metadata map[string]interface{}
-		if metadataStr := c.PostForm("metadata"); metadataStr != "" {
-			if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "invalid metadata format, expected JSON",
-				})
-				return
-			}
-		}
 		// Prepare request
 		req := &services.UploadDocumentRequest{
-			UserID:   userIDStr,
-			OrgID:    orgID,
-			FilePath: dbFilePath, // Use relative path for database storage
-			FolderID: folderID,
-			Metadata: metadata,
+			UserID:    caller.ID.String(),
+			OrgID:     orgID,
+			FilePath:  stored.FilePath, // Relative path; a duplicate points at the existing copy
+			Filename:  filename,
+			Checksum:  stored.Checksum,
+			SizeBytes: stored.SizeBytes,
+			FolderID:  folderID,
+			Metadata:  metadata,
 		}
 
 		// Call service to create document entry
@@ -247,6 +181,308 @@ func (h *DocumentHandler) UploadDocument() gin.HandlerFunc {
 	}
 }
 
+// PresignUpload handles the POST /api/v1/documents/presign endpoint: it reserves a document and
+// returns a URL the client uploads the file to directly, without sending it through the API
+func (h *DocumentHandler) PresignUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		var body models.PresignUploadRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Same owning org rules as uploads
+		orgID, err := policy.FromContext(c).OwnerOrg(body.OrgID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "org_id is required for non-superadmin users",
+			})
+			return
+		}
+
+		folderID, folderPath := h.uploadFolder(c, body.FolderID)
+		filename := uploadFilename(body.Filename)
+
+		contentType := body.ContentType
+		if contentType == "" {
+			contentType = getMimeType(strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")))
+		}
+
+		upload, err := h.Services.Document.PresignUpload(c.Request.Context(), &services.UploadDocumentRequest{
+			UserID:    caller.ID.String(),
+			OrgID:     orgID,
+			FilePath:  uploadKey(orgID, folderPath, filename),
+			Filename:  filename,
+			SizeBytes: body.SizeBytes,
+			FolderID:  folderID,
+			Metadata:  body.Metadata,
+		}, contentType)
+		if err != nil {
+			respondError(c, err, "Failed to presign upload")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"data":    upload,
+			"code":    http.StatusCreated,
+			"s":       "ok",
+			"message": "Upload the file to upload_url, then finalize the upload",
+		})
+	}
+}
+
+// FinalizeUpload handles the POST /api/v1/documents/:document_id/finalize endpoint, the callback a
+// client makes once its direct upload has finished: the document is then processed like an upload
+func (h *DocumentHandler) FinalizeUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid document_id format, expected integer",
+			})
+			return
+		}
+
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		response, err := h.Services.Document.FinalizeUpload(c.Request.Context(), documentID, caller.ID)
+		if err != nil {
+			respondError(c, err, "Failed to finalize upload")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    response,
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": "Document uploaded successfully",
+		})
+	}
+}
+
+// CreateUploadSession handles the POST /api/v1/documents/uploads endpoint: it opens a resumable
+// upload, which the client sends in chunks with PATCH /uploads/:session_id and then completes
+func (h *DocumentHandler) CreateUploadSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		var body models.CreateUploadSessionRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Same owning org rules as uploads
+		orgID, err := policy.FromContext(c).OwnerOrg(body.OrgID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "org_id is required for non-superadmin users",
+			})
+			return
+		}
+
+		folderID, folderPath := h.uploadFolder(c, body.FolderID)
+		filename := uploadFilename(body.Filename)
+
+		session, err := h.Services.Document.CreateUploadSession(c.Request.Context(), &services.UploadDocumentRequest{
+			UserID:    caller.ID.String(),
+			OrgID:     orgID,
+			FilePath:  uploadKey(orgID, folderPath, filename),
+			Filename:  filename,
+			SizeBytes: body.SizeBytes,
+			FolderID:  folderID,
+			Metadata:  body.Metadata,
+		})
+		if err != nil {
+			respondError(c, err, "Failed to create upload session")
+			return
+		}
+
+		c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID.String())
+		h.uploadSessionHeaders(c, session)
+		c.JSON(http.StatusCreated, gin.H{
+			"data":    h.uploadSessionResponse(session),
+			"code":    http.StatusCreated,
+			"s":       "ok",
+			"message": "Send the file in chunks, then complete the upload",
+		})
+	}
+}
+
+// GetUploadSession handles the GET and HEAD /api/v1/documents/uploads/:session_id endpoints; a
+// client resuming an interrupted upload reads Upload-Offset to know where to continue
+func (h *DocumentHandler) GetUploadSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := h.uploadSessionParams(c)
+		if !ok {
+			return
+		}
+
+		session, err := h.Services.Document.GetUploadSession(c.Request.Context(), sessionID, userID)
+		if err != nil {
+			respondError(c, err, "Failed to get upload session")
+			return
+		}
+
+		h.uploadSessionHeaders(c, session)
+		c.Header("Cache-Control", "no-store")
+		if c.Request.Method == http.MethodHead {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data":    h.uploadSessionResponse(session),
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": "Upload session retrieved successfully",
+		})
+	}
+}
+
+// AppendUploadChunk handles the PATCH /api/v1/documents/uploads/:session_id endpoint. The body is
+// the chunk (Content-Type: application/offset+octet-stream), written at the Upload-Offset header and
+// checked against the Upload-Checksum header. Responds 204 with the new Upload-Offset.
+func (h *DocumentHandler) AppendUploadChunk() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := h.uploadSessionParams(c)
+		if !ok {
+			return
+		}
+
+		if c.ContentType() != "application/offset+octet-stream" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Content-Type must be application/offset+octet-stream",
+			})
+			return
+		}
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Upload-Offset header is required",
+			})
+			return
+		}
+
+		session, err := h.Services.Document.AppendUploadChunk(c.Request.Context(), sessionID, userID, offset, c.GetHeader("Upload-Checksum"), c.Request.Body)
+		if err != nil {
+			respondError(c, err, "Failed to save chunk")
+			return
+		}
+
+		h.uploadSessionHeaders(c, session)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// CompleteUploadSession handles the POST /api/v1/documents/uploads/:session_id/complete endpoint:
+// once every byte is received the chunks become the document's file, processed like an upload
+func (h *DocumentHandler) CompleteUploadSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := h.uploadSessionParams(c)
+		if !ok {
+			return
+		}
+
+		response, err := h.Services.Document.CompleteUploadSession(c.Request.Context(), sessionID, userID)
+		if err != nil {
+			respondError(c, err, "Failed to complete upload")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    response,
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": "Document uploaded successfully",
+		})
+	}
+}
+
+// CancelUploadSession handles the DELETE /api/v1/documents/uploads/:session_id endpoint
+func (h *DocumentHandler) CancelUploadSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := h.uploadSessionParams(c)
+		if !ok {
+			return
+		}
+
+		if err := h.Services.Document.CancelUploadSession(c.Request.Context(), sessionID, userID); err != nil {
+			respondError(c, err, "Failed to cancel upload")
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// uploadSessionParams resolves :session_id and the caller, whose sessions are the only ones they see
+func (h *DocumentHandler) uploadSessionParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid session_id format, expected UUID",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return sessionID, caller.ID, true
+}
+
+// uploadSessionHeaders sets the tus-style headers describing a session's progress
+func (h *DocumentHandler) uploadSessionHeaders(c *gin.Context, session *repositories.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.OffsetBytes, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.SizeBytes, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+func (h *DocumentHandler) uploadSessionResponse(session *repositories.UploadSession) gin.H {
+	return gin.H{
+		"id":              session.ID,
+		"filename":        session.Filename,
+		"folder_id":       session.FolderID,
+		"size_bytes":      session.SizeBytes,
+		"offset_bytes":    session.OffsetBytes,
+		"status":          session.Status,
+		"max_chunk_bytes": h.Services.Document.MaxUploadChunkBytes(),
+		"expires_at":      session.ExpiresAt,
+		"created_at":      session.CreatedAt,
+	}
+}
+
 // GetDocuments handles the GET /api/v1/documents endpoint
 func (h *DocumentHandler) GetDocuments() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -353,35 +589,17 @@ func (h *DocumentHandler) GetDocumentsWithFilter() gin.HandlerFunc {
 			folderID = &fid
 		}
 
-		// Get org_id from query or context (nullable for superadmins)
-		var orgID *uuid.UUID
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		isSuperAdminBool := false
-		if isSuperAdmin != nil {
-			if val, ok := isSuperAdmin.(bool); ok {
-				isSuperAdminBool = val
-			}
-		}
-
-		// Try to get org_id from query first
+		// Resolve org scope: super admins may filter by org_id (or see all orgs),
+		// everyone else only sees their own org
+		subject := policy.FromContext(c)
+		requestedOrgID := subject.OrgID
 		if orgIDStr := c.Query("org_id"); orgIDStr != "" {
 			if parsedOrgID, err := uuid.Parse(orgIDStr); err == nil {
-				orgID = &parsedOrgID
-			}
-		} else {
-			// Fallback to context
-			if orgIDVal, exists := c.Get("org_id"); exists && orgIDVal != nil {
-				if orgIDStr, ok := orgIDVal.(string); ok && orgIDStr != "" {
-					if parsedOrgID, err := uuid.Parse(orgIDStr); err == nil {
-						orgID = &parsedOrgID
-					}
-				}
+				requestedOrgID = &parsedOrgID
 			}
 		}
-
-		// For superadmins, org_id can be null - allow the request to proceed
-		// For non-superadmin users, org_id is required
-		if !isSuperAdminBool && orgID == nil {
+		orgID, err := subject.ListScope(requestedOrgID)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "org_id is required for non-superadmin users",
 				"message": "Please provide org_id as a query parameter or ensure your JWT token includes is_super_admin claim",
@@ -412,12 +630,22 @@ func (h *DocumentHandler) GetDocumentsWithFilter() gin.HandlerFunc {
 			limit = 50
 		}
 
+		// Optional extras, e.g. include=snippet
+		includeSnippet := false
+		for _, include := range strings.Split(c.Query("include"), ",") {
+			if strings.TrimSpace(include) == "snippet" {
+				includeSnippet = true
+			}
+		}
+
 		// Prepare request
 		req := &services.GetDocumentsRequest{
-			FolderID: folderID,
-			OrgID:    orgID,
-			Page:     page,
-			Limit:    limit,
+			FolderID:       folderID,
+			OrgID:          orgID,
+			ViewerID:       subject.DocumentViewer(),
+			Page:           page,
+			Limit:          limit,
+			IncludeSnippet: includeSnippet,
 		}
 
 		// Call service
@@ -450,6 +678,10 @@ func (h *DocumentHandler) GetJobStatus() gin.HandlerFunc {
 			return
 		}
 
+		if documentID, err := strconv.ParseInt(jobID, 10, 64); err == nil && !h.authorizeDocument(c, documentID) {
+			return
+		}
+
 		docInfo, err := h.Services.Document.GetJobStatus(c.Request.Context(), jobID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -514,6 +746,10 @@ func (h *DocumentHandler) DeleteDocument() gin.HandlerFunc {
 			return
 		}
 
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
 		// Call service to delete document
 		err = h.Services.Document.DeleteDocument(c.Request.Context(), documentID)
 		if err != nil {
@@ -539,6 +775,474 @@ func (h *DocumentHandler) DeleteDocument() gin.HandlerFunc {
 	}
 }
 
+// ReplaceContent handles PUT /api/v1/documents/:document_id/content: stores a new file for the
+// document as its next version. The current content stays live until the new one is processed and
+// embedded; the document ID, and so every citation and link to it, stays the same.
+func (h *DocumentHandler) ReplaceContent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid document_id format, expected integer",
+			})
+			return
+		}
+
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "file is required",
+			})
+			return
+		}
+
+		// Same naming as uploads; the file is stored next to the current one
+		filename := filepath.Base(filepath.Clean(file.Filename))
+		filename = strings.ReplaceAll(filename, " ", "_")
+		filename = strings.ReplaceAll(filename, "'", "")
+
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "failed to read file",
+			})
+			return
+		}
+		defer src.Close()
+
+		version, err := h.Services.Document.ReplaceContent(c.Request.Context(), &services.ReplaceContentRequest{
+			DocumentID: documentID,
+			UserID:     caller.ID,
+			Filename:   filename,
+			File:       src,
+		})
+		if err != nil {
+			respondError(c, err, "Failed to replace document content")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"data":    version,
+			"code":    http.StatusAccepted,
+			"s":       "ok",
+			"message": fmt.Sprintf("Version %d of document %d submitted for processing", version.Version, documentID),
+		})
+	}
+}
+
+// ListVersions handles the GET /api/v1/documents/:document_id/versions endpoint: the document's
+// content history, newest first
+func (h *DocumentHandler) ListVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid document_id format, expected integer",
+			})
+			return
+		}
+
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
+		versions, err := h.Services.Document.DocumentVersions(c.Request.Context(), documentID)
+		if err != nil {
+			respondError(c, err, "Failed to list document versions")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    versions,
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": "Document versions retrieved successfully",
+		})
+	}
+}
+
+// DownloadVersion handles the GET /api/v1/documents/:document_id/versions/:version/download endpoint
+func (h *DocumentHandler) DownloadVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, version, ok := h.versionParams(c)
+		if !ok {
+			return
+		}
+
+		v, err := h.Services.Document.DocumentVersion(c.Request.Context(), documentID, version)
+		if err != nil {
+			respondError(c, err, "Failed to get document version")
+			return
+		}
+		if v.FilePath == nil || *v.FilePath == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Document version file not found",
+			})
+			return
+		}
+
+		// Absolute paths predate storage keys and are on the local disk
+		if filepath.IsAbs(*v.FilePath) {
+			c.FileAttachment(*v.FilePath, v.Filename)
+			return
+		}
+		serveStoredFile(c, h.Services.Document.Storage, *v.FilePath, v.Filename, "attachment", h.Services.Document.SignedURLTTL)
+	}
+}
+
+// RestoreVersion handles the POST /api/v1/documents/:document_id/versions/:version/restore endpoint:
+// an earlier version becomes the document's content again, keeping the document ID
+func (h *DocumentHandler) RestoreVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, version, ok := h.versionParams(c)
+		if !ok {
+			return
+		}
+
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		restored, err := h.Services.Document.RestoreVersion(c.Request.Context(), documentID, version, caller.ID)
+		if err != nil {
+			respondError(c, err, "Failed to restore document version")
+			return
+		}
+
+		// Versions still embedded go live at once; the others are embedded again first
+		status := http.StatusOK
+		message := fmt.Sprintf("Version %d of document %d restored", version, documentID)
+		if restored.Status != repositories.DocumentVersionActive {
+			status = http.StatusAccepted
+			message = fmt.Sprintf("Version %d of document %d submitted for processing", version, documentID)
+		}
+		c.JSON(status, gin.H{
+			"data":    restored,
+			"code":    status,
+			"s":       "ok",
+			"message": message,
+		})
+	}
+}
+
+// versionParams parses :document_id and :version and checks the caller may see the document
+func (h *DocumentHandler) versionParams(c *gin.Context) (int64, int, bool) {
+	documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid document_id format, expected integer",
+		})
+		return 0, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid version, expected a positive integer",
+		})
+		return 0, 0, false
+	}
+	if !h.authorizeDocument(c, documentID) {
+		return 0, 0, false
+	}
+	return documentID, version, true
+}
+
+// ImportFolderZip handles POST /api/v1/folders/import-zip: unpacks a ZIP archive (multipart file)
+// into folders and documents under the optional parent_id folder
+func (h *DocumentHandler) ImportFolderZip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, err := authctx.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "file is required",
+			})
+			return
+		}
+
+		// Folders always belong to an org; super admins must pass org_id
+		var requestedOrgID *uuid.UUID
+		if orgIDStr := c.PostForm("org_id"); orgIDStr != "" {
+			if parsedOrgID, err := uuid.Parse(orgIDStr); err == nil {
+				requestedOrgID = &parsedOrgID
+			}
+		}
+		orgID, err := policy.FromContext(c).TargetOrg(requestedOrgID)
+		if err != nil {
+			respondError(c, err, "Failed to resolve organization")
+			return
+		}
+
+		var parentID *uuid.UUID
+		if pid := c.PostForm("parent_id"); pid != "" {
+			parsed, err := uuid.Parse(pid)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "invalid parent_id format, expected UUID",
+				})
+				return
+			}
+			parentID = &parsed
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "failed to read file",
+			})
+			return
+		}
+		defer src.Close()
+
+		result, err := h.Services.Document.ImportFolderZip(c.Request.Context(), &services.FolderImportRequest{
+			OrgID:    orgID,
+			ParentID: parentID,
+			UserID:   caller.ID,
+			Archive:  src,
+			Size:     file.Size,
+		})
+		if err != nil {
+			respondError(c, err, "Failed to import archive")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    result,
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": fmt.Sprintf("Imported %d documents and %d new folders", result.DocumentsCreated, result.FoldersCreated),
+		})
+	}
+}
+
+// PurgeArtifacts handles DELETE /api/v1/documents/:document_id/artifacts: removes the document's
+// processing artifacts (chunks JSON) but keeps the source file and its embeddings
+func (h *DocumentHandler) PurgeArtifacts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid document_id format, expected integer",
+			})
+			return
+		}
+
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
+		freed, err := h.Services.Document.PurgeArtifacts(c.Request.Context(), documentID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":        http.StatusOK,
+			"s":           "ok",
+			"message":     fmt.Sprintf("Artifacts for document %d purged", documentID),
+			"freed_bytes": freed,
+		})
+	}
+}
+
+// StartPipelineCanary handles POST /api/v1/admin/pipeline-canary: reprocesses a random sample of
+// processed documents with the canary pipeline in shadow mode
+func (h *DocumentHandler) StartPipelineCanary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			SampleSize int        `json:"sample_size"`
+			OrgID      *uuid.UUID `json:"org_id"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		if req.SampleSize == 0 {
+			req.SampleSize = 20
+		}
+		if req.SampleSize < 0 || req.SampleSize > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "sample_size must be between 1 and 500",
+			})
+			return
+		}
+
+		run, err := h.Services.Document.StartCanary(c.Request.Context(), req.OrgID, req.SampleSize)
+		if err != nil {
+			respondError(c, err, "Failed to start canary run")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"data":    run,
+			"code":    http.StatusAccepted,
+			"s":       "ok",
+			"message": fmt.Sprintf("Canary run for pipeline %s started on %d document(s)", run.Version, run.Total),
+		})
+	}
+}
+
+// ComparePipelineCanary handles GET /api/v1/admin/pipeline-canary?version=&query=: compares a canary
+// version's output with the live pipeline. Repeated query params replace the configured benchmark queries.
+func (h *DocumentHandler) ComparePipelineCanary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := c.DefaultQuery("version", h.Services.Document.Canary.Version)
+		if version == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "version is required",
+			})
+			return
+		}
+
+		comparison, err := h.Services.Document.CompareCanary(c.Request.Context(), version, c.QueryArray("query"))
+		if err != nil {
+			respondError(c, err, "Failed to compare canary run")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    comparison,
+			"code":    http.StatusOK,
+			"s":       "ok",
+			"message": "Canary comparison generated",
+		})
+	}
+}
+
+// DeletePipelineCanary handles DELETE /api/v1/admin/pipeline-canary?version=: drops a canary version's
+// Weaviate classes, chunks files and results
+func (h *DocumentHandler) DeletePipelineCanary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := c.Query("version")
+		if version == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "version is required",
+			})
+			return
+		}
+
+		deleted, err := h.Services.Document.DeleteCanary(c.Request.Context(), version)
+		if err != nil {
+			respondError(c, err, "Failed to delete canary run")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":      http.StatusOK,
+			"s":         "ok",
+			"message":   fmt.Sprintf("Canary %s deleted", version),
+			"documents": deleted,
+		})
+	}
+}
+
+// authorizeDocument checks the document belongs to the caller's org and is in a folder the caller's
+// roles may see, and writes the error response if not
+func (h *DocumentHandler) authorizeDocument(c *gin.Context, documentID int64) bool {
+	documentRepo := h.Services.GetRepositories().Document
+	doc, err := documentRepo.GetByID(c.Request.Context(), documentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Document not found",
+		})
+		return false
+	}
+
+	subject := policy.FromContext(c)
+	if err := subject.CanAccess(doc.OrgID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied to this document",
+		})
+		return false
+	}
+
+	accessible, err := documentRepo.FolderAccessible(c.Request.Context(), doc.FolderID, subject.DocumentViewer())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if !accessible {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied to this document's folder",
+		})
+		return false
+	}
+	return true
+}
+
+// uploadFolder resolves the folder an upload goes into: its ID as the service takes it and its path
+// for the storage key. An unknown folder gives an empty path.
+func (h *DocumentHandler) uploadFolder(c *gin.Context, folderUUID *uuid.UUID) (*string, string) {
+	if folderUUID == nil {
+		return nil, ""
+	}
+	folderID := folderUUID.String()
+	folder, err := h.Services.GetRepositories().Folder.GetByID(c.Request.Context(), *folderUUID)
+	if err != nil {
+		return &folderID, ""
+	}
+	return &folderID, strings.TrimPrefix(folder.Path, "/")
+}
+
+// uploadFilename names an upload as UploadDocument does, without any directories of the client's name
+func uploadFilename(name string) string {
+	filename := filepath.Base(filepath.Clean(name))
+	filename = strings.ReplaceAll(filename, " ", "_")
+	return strings.ReplaceAll(filename, "'", "")
+}
+
+// uploadKey is the storage key of an upload, as stored in the database: {org_id}/{folder_path}/filename
+// for organization files, {folder_path}/filename or just filename for super admin files
+func uploadKey(orgID *uuid.UUID, folderPath, filename string) string {
+	var components []string
+	if orgID != nil {
+		components = append(components, orgID.String())
+	}
+	if folderPath != "" {
+		components = append(components, folderPath)
+	}
+	return path.Join(append(components, filename)...)
+}
+
 // parseIntWithDefault parses an integer string and returns default value on error
 func parseIntWithDefault(s string, defaultVal int) (int, error) {
 	var result int
@@ -562,7 +1266,7 @@ func (h *DocumentHandler) DownloadDocument() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get document ID from URL parameter
 		documentIDStr := c.Param("document_id")
-		_, err := strconv.ParseInt(documentIDStr, 10, 64)
+		documentID, err := strconv.ParseInt(documentIDStr, 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "Invalid document ID",
@@ -570,6 +1274,10 @@ func (h *DocumentHandler) DownloadDocument() gin.HandlerFunc {
 			return
 		}
 
+		if !h.authorizeDocument(c, documentID) {
+			return
+		}
+
 		// Get document from database
 		doc, err := h.Services.Document.GetJobStatus(c.Request.Context(), documentIDStr)
 		if err != nil {
@@ -587,13 +1295,13 @@ func (h *DocumentHandler) DownloadDocument() gin.HandlerFunc {
 			return
 		}
 
-		// Construct full file path
-		filePath := doc.FilePath
-		if !filepath.IsAbs(filePath) {
-			filePath = filepath.Join(h.Services.Document.ResourcesBasePath, filePath)
+		// Absolute paths predate storage keys and are on the local disk
+		if filepath.IsAbs(doc.FilePath) {
+			c.FileAttachment(doc.FilePath, doc.Name)
+			return
 		}
 
-		// Serve the file
-		c.FileAttachment(filePath, doc.Name)
+		// Serve the file, or redirect to it on storage that signs URLs
+		serveStoredFile(c, h.Services.Document.Storage, doc.FilePath, doc.Name, "attachment", h.Services.Document.SignedURLTTL)
 	}
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailChangeHandler serves changing the signed-in user's email. The confirmation is public, because
+// the link may be opened on another device than the one that asked for the change.
+type EmailChangeHandler struct {
+	emailChangeService *services.EmailChangeService
+	authMW             *middleware.AuthMiddleware
+}
+
+func NewEmailChangeHandler(emailChangeService *services.EmailChangeService, authMW *middleware.AuthMiddleware) *EmailChangeHandler {
+	return &EmailChangeHandler{
+		emailChangeService: emailChangeService,
+		authMW:             authMW,
+	}
+}
+
+// RequestChange sends a confirmation link to the new address
+// POST /api/v1/auth/email-change
+func (h *EmailChangeHandler) RequestChange(c *gin.Context) {
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	if err := h.emailChangeService.RequestChange(c.Request.Context(), user.ID, req.NewEmail, h.authMW.GetClientIP(c)); err != nil {
+		respondError(c, err, "Failed to request email change")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "A confirmation link has been sent to the new email address"})
+}
+
+// ConfirmChange applies the change with the token from the confirmation link
+// POST /api/v1/auth/email-change/confirm
+func (h *EmailChangeHandler) ConfirmChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.emailChangeService.ConfirmChange(c.Request.Context(), req.Token, h.authMW.GetClientIP(c)); err != nil {
+		respondError(c, err, "Failed to change email")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed. Please sign in with your new email address"})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FeatureHandler reports the features of the caller's org, for the frontend to hide what the org
+// cannot use, and lets super admins set feature flags
+type FeatureHandler struct {
+	featureService *services.FeatureService
+}
+
+func NewFeatureHandler(featureService *services.FeatureService) *FeatureHandler {
+	return &FeatureHandler{featureService: featureService}
+}
+
+// List returns every feature with whether the caller's org has it and why. Super admins get every
+// feature enabled, or pass ?org_id= to see an org's.
+// GET /api/v1/features
+func (h *FeatureHandler) List(c *gin.Context) {
+	subject := policy.FromContext(c)
+
+	orgID := subject.OrgID
+	if subject.IsSuperAdmin {
+		orgID = nil
+		if orgParam := c.Query("org_id"); orgParam != "" {
+			uid, err := uuid.Parse(orgParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+					Error:   errors.ErrValidation.Code,
+					Message: "Invalid org_id parameter",
+				})
+				return
+			}
+			orgID = &uid
+		} else {
+			c.JSON(http.StatusOK, gin.H{"data": h.featureService.SuperAdminFeatures()})
+			return
+		}
+	}
+	if orgID == nil {
+		respondForbidden(c, "Organization context required")
+		return
+	}
+
+	features, err := h.featureService.Features(c.Request.Context(), *orgID)
+	if err != nil {
+		respondError(c, err, "Failed to list features")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": features})
+}
+
+// Set grants or withdraws a feature for an org whatever its plan (super admins only)
+// PUT /api/v1/organizations/:id/features/:feature
+func (h *FeatureHandler) Set(c *gin.Context) {
+	orgID, ok := h.flagOrgParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	state, err := h.featureService.SetFlag(c.Request.Context(), orgID, c.Param("feature"), *req.Enabled, policy.FromContext(c).UserID)
+	if err != nil {
+		respondError(c, err, "Failed to set feature flag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": state})
+}
+
+// Clear removes an org's feature flag, so the org has the feature only if its plan does (super
+// admins only)
+// DELETE /api/v1/organizations/:id/features/:feature
+func (h *FeatureHandler) Clear(c *gin.Context) {
+	orgID, ok := h.flagOrgParam(c)
+	if !ok {
+		return
+	}
+
+	state, err := h.featureService.ClearFlag(c.Request.Context(), orgID, c.Param("feature"), policy.FromContext(c).UserID)
+	if err != nil {
+		respondError(c, err, "Failed to clear feature flag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": state})
+}
+
+// flagOrgParam resolves :id for the flag endpoints; flags override the plan, so only super admins
+// may change them
+func (h *FeatureHandler) flagOrgParam(c *gin.Context) (uuid.UUID, bool) {
+	if !policy.FromContext(c).IsSuperAdmin {
+		respondForbidden(c, "Only super admins can change feature flags")
+		return uuid.Nil, false
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid organization ID",
+		})
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
@@ -7,9 +7,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
 	"strconv"
 	"strings"
 
@@ -23,17 +26,17 @@ type FileHandler struct {
 	documentService interface {
 		DeleteDocument(ctx context.Context, documentID int64) error
 	}
-	storagePath string // Base path for file storage: {storagePath}/{org_id}/folder/files
+	storage storage.Storage // Files are stored at {org_id}/folder/files
 }
 
 func NewFileHandler(folderRepo *repositories.FolderRepository, documentRepo *repositories.DocumentRepository, documentService interface {
 	DeleteDocument(ctx context.Context, documentID int64) error
-}, storagePath string) *FileHandler {
+}, store storage.Storage) *FileHandler {
 	return &FileHandler{
 		folderRepo:      folderRepo,
 		documentRepo:    documentRepo,
 		documentService: documentService,
-		storagePath:     storagePath,
+		storage:         store,
 	}
 }
 
@@ -103,63 +106,21 @@ func (h *FileHandler) Create(c *gin.Context) {
 	}
 
 	// Get user and org from context
-	userID, exists := c.Get("user_id")
-	if !exists || userID == nil {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User ID is required",
-		})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID format",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userIDStr)
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User ID is required")
 		return
 	}
+	uid := caller.ID
 
 	// Get org_id from request body or context
 	var orgUUID uuid.UUID
 	if req.OrgID != nil {
 		orgUUID = *req.OrgID
 	} else {
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in request body or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		var err error
-		orgUUID, err = uuid.Parse(orgIDStr)
+		orgUUID, err = authctx.MustOrg(c)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
+			respondError(c, err, "")
 			return
 		}
 	}
@@ -249,30 +210,9 @@ func (h *FileHandler) List(c *gin.Context) {
 		}
 	} else {
 		// Get org_id from context (for regular users)
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in query parameter or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		orgUUID, err = uuid.Parse(orgIDStr)
+		orgUUID, err = authctx.MustOrg(c)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
+			respondError(c, err, "")
 			return
 		}
 	}
@@ -305,7 +245,7 @@ func (h *FileHandler) List(c *gin.Context) {
 		docFolderID = folderID
 	}
 
-	documents, total, err := h.documentRepo.ListByFolder(c.Request.Context(), docFolderID, orgUUID, page, limit)
+	documents, total, err := h.documentRepo.ListByFolder(c.Request.Context(), docFolderID, orgUUID, policy.FromContext(c).DocumentViewer(), page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
@@ -384,39 +324,9 @@ func (h *FileHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	// Check organization access - users can only access files from their own organization
-	// Super admins can access files from any organization
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		// Regular user - check if file belongs to their organization
-		userOrgID, exists := c.Get("org_id")
-		if !exists || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this file",
-			})
-			return
-		}
-
-		userOrgIDStr, ok := userOrgID.(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this file",
-			})
-			return
-		}
-
-		userOrgUUID, err := uuid.Parse(userOrgIDStr)
-		if err != nil || (doc.OrgID != nil && userOrgUUID != *doc.OrgID) {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this file. Files can only be accessed by users from the same organization.",
-			})
-			return
-		}
+	if !h.authorizeFile(c, doc) {
+		return
 	}
-	// Super admin - allow access to any file
 
 	// Convert to File model
 	file := documentToFile(doc)
@@ -440,55 +350,64 @@ func (h *FileHandler) GetByID(c *gin.Context) {
 			return
 		}
 
-		// Check if the path already includes storage path prefix
-		if strings.HasPrefix(filePath, h.storagePath+"/") || strings.HasPrefix(filePath, h.storagePath+"\\") {
-			// Path already includes storage path prefix (legacy format)
+		// Absolute paths predate storage keys and are on the local disk
+		if filepath.IsAbs(filePath) {
 			filePath = filepath.Clean(filePath)
-		} else if filepath.IsAbs(filePath) {
-			// It's an absolute path - use as-is
-			filePath = filepath.Clean(filePath)
-		} else {
-			// Relative path - join with storagePath
-			filePath = filepath.Join(h.storagePath, filePath)
-		}
-
-		// Clean the path
-		filePath = filepath.Clean(filePath)
-
-		// Serve file for download
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, errors.ErrorResponse{
-				Error:   errors.ErrNotFound.Code,
-				Message: fmt.Sprintf("File not found on disk: %s", filePath),
-			})
+			if _, err := os.Stat(filePath); os.IsNotExist(err) {
+				c.JSON(http.StatusNotFound, errors.ErrorResponse{
+					Error:   errors.ErrNotFound.Code,
+					Message: fmt.Sprintf("File not found on disk: %s", filePath),
+				})
+				return
+			}
+			c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", doc.Name))
+			c.File(filePath)
 			return
 		}
 
-		// Set appropriate content type based on file extension
-		// Prioritize extension-based detection to ensure correct MIME type
-		mimeType := "application/octet-stream"
-		if doc.Content.MimeType != nil && *doc.Content.MimeType != "" {
-			mimeType = *doc.Content.MimeType
-		} else if file.Extension != nil && *file.Extension != "" {
-			mimeType = getMimeType(*file.Extension)
-		}
-
-		// Set headers for file download
-		c.Header("Content-Type", mimeType)
-		// For HTML and PDF files, use inline to allow preview; for others, use attachment to force download
-		if strings.HasPrefix(mimeType, "text/html") || mimeType == "application/pdf" {
-			c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", doc.Name))
-		} else {
-			c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", doc.Name))
-		}
-		// Serve the file
-		c.File(filePath)
+		// Serve inline to allow preview
+		serveStoredFile(c, h.storage, filePath, doc.Name, "inline", 0)
 		return
 	}
 
 	c.JSON(http.StatusOK, file)
 }
 
+// authorizeFile answers 403 and returns false unless the caller may use the file: users only reach
+// files of their own organization (super admins any file), and within it, folder permissions limit
+// files to the roles they are granted to
+func (h *FileHandler) authorizeFile(c *gin.Context, doc *repositories.Document) bool {
+	subject := policy.FromContext(c)
+	if err := subject.CanAccess(doc.OrgID); err != nil {
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error:   errors.ErrForbidden.Code,
+			Message: "You do not have access to this file. Files can only be accessed by users from the same organization.",
+		})
+		return false
+	}
+	return h.authorizeFolder(c, doc.FolderID)
+}
+
+// authorizeFolder answers 403 and returns false when folder permissions keep the caller out of the folder
+func (h *FileHandler) authorizeFolder(c *gin.Context, folderID *uuid.UUID) bool {
+	accessible, err := h.documentRepo.FolderAccessible(c.Request.Context(), folderID, policy.FromContext(c).DocumentViewer())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to check folder permissions",
+		})
+		return false
+	}
+	if !accessible {
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error:   errors.ErrForbidden.Code,
+			Message: "You do not have access to files in this folder",
+		})
+		return false
+	}
+	return true
+}
+
 func (h *FileHandler) Update(c *gin.Context) {
 	idStr := c.Param("id")
 
@@ -547,6 +466,9 @@ func (h *FileHandler) Update(c *gin.Context) {
 		})
 		return
 	}
+	if !h.authorizeFile(c, doc) {
+		return
+	}
 
 	// Update fields
 	if req.Name != nil {
@@ -559,17 +481,22 @@ func (h *FileHandler) Update(c *gin.Context) {
 		}
 	}
 	if req.FolderID != nil {
+		// The destination must be a folder of the file's org that the caller may see too
+		folder, err := h.folderRepo.GetByID(c.Request.Context(), *req.FolderID)
+		if err != nil || (doc.OrgID != nil && folder.OrgID != *doc.OrgID) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Folder not found in the file's organization",
+			})
+			return
+		}
+		if !h.authorizeFolder(c, req.FolderID) {
+			return
+		}
 		doc.FolderID = req.FolderID
 	}
 
-	userID, exists := c.Get("user_id")
-	if exists && userID != nil {
-		if userIDStr, ok := userID.(string); ok {
-			if uid, err := uuid.Parse(userIDStr); err == nil {
-				doc.UpdatedBy = &uid
-			}
-		}
-	}
+	doc.UpdatedBy = authctx.UserID(c)
 
 	if err := h.documentRepo.Update(c.Request.Context(), doc); err != nil {
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
@@ -633,6 +560,9 @@ func (h *FileHandler) Delete(c *gin.Context) {
 		})
 		return
 	}
+	if !h.authorizeFile(c, doc) {
+		return
+	}
 
 	// Get file_path for deletion
 	var filePath string
@@ -642,26 +572,23 @@ func (h *FileHandler) Delete(c *gin.Context) {
 		filePath = *doc.Content.Path
 	}
 
-	// Delete physical file from disk first
-	if filePath != "" {
-		// Determine the actual file path
-		diskPath := filePath
-		if !filepath.IsAbs(diskPath) {
-			// Relative path - join with storagePath
-			diskPath = filepath.Join(h.storagePath, diskPath)
-		}
-		diskPath = filepath.Clean(diskPath)
-
-		// Check if file exists and delete it
-		if _, err := os.Stat(diskPath); err == nil {
-			if err := os.Remove(diskPath); err != nil {
-				// Log error but don't fail the deletion - file might have been manually deleted
-				fmt.Printf("Warning: Failed to delete physical file %s: %v\n", diskPath, err)
-			} else {
-				fmt.Printf("Successfully deleted physical file: %s\n", diskPath)
+	// Delete the stored file first. Deduplicated uploads may share it with other documents;
+	// DeleteDocument releases those and removes the file with its last reference.
+	if filePath != "" && doc.Content.Checksum == nil {
+		var err error
+		if filepath.IsAbs(filePath) {
+			// Absolute paths predate storage keys and are on the local disk
+			if err = os.Remove(filepath.Clean(filePath)); os.IsNotExist(err) {
+				err = nil
 			}
 		} else {
-			fmt.Printf("Physical file not found (may have been deleted already): %s\n", diskPath)
+			err = h.storage.Delete(c.Request.Context(), filePath)
+		}
+		if err != nil {
+			// Log error but don't fail the deletion - file might have been manually deleted
+			fmt.Printf("Warning: Failed to delete file %s: %v\n", filePath, err)
+		} else {
+			fmt.Printf("Successfully deleted file: %s\n", filePath)
 		}
 	}
 
@@ -698,7 +625,7 @@ func (h *FileHandler) Delete(c *gin.Context) {
 func (h *FileHandler) Upload(c *gin.Context) {
 	// Handle file upload via multipart/form-data
 	// For now, we'll create a file record and return it
-	// Actual file storage would be handled separately (S3, local storage, etc.)
+	// The file goes to the configured storage backend (local disk or S3)
 
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
@@ -732,61 +659,20 @@ func (h *FileHandler) Upload(c *gin.Context) {
 			return
 		}
 	} else {
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in form data or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		var parseErr error
-		orgUUID, parseErr = uuid.Parse(orgIDStr)
-		if parseErr != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
+		var err error
+		orgUUID, err = authctx.MustOrg(c)
+		if err != nil {
+			respondError(c, err, "")
 			return
 		}
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists || userID == nil {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User ID is required",
-		})
-		return
-	}
-
-	userIDStr, ok := userID.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID format",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userIDStr)
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User ID is required")
 		return
 	}
+	uid := caller.ID
 
 	// Extract extension
 	ext := filepath.Ext(fileHeader.Filename)
@@ -848,35 +734,10 @@ func (h *FileHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// Save actual file to disk at the storage key path
-	// storageKey is relative to storagePath, so we need to join them
-	fullStoragePath := filepath.Join(h.storagePath, storageKey)
-
-	// Create directory structure if it doesn't exist
-	storageDir := filepath.Dir(fullStoragePath)
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		// If directory creation fails, still return error but don't create DB record
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("Failed to create storage directory: %v", err),
-		})
-		return
-	}
-
-	// Open destination file
-	dst, err := os.Create(fullStoragePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("Failed to create file: %v", err),
-		})
-		return
-	}
-	defer dst.Close()
-
 	// Open uploaded file
 	src, err := fileHeader.Open()
 	if err != nil {
+		h.documentRepo.Delete(c.Request.Context(), doc.ID)
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
 			Message: fmt.Sprintf("Failed to open uploaded file: %v", err),
@@ -885,82 +746,39 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Copy file content
-	bytesWritten, err := io.Copy(dst, src)
-	if err != nil {
-		// Clean up: remove file if copy fails
-		os.Remove(fullStoragePath)
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("Failed to save file: %v", err),
-		})
-		return
-	}
-
-	// Ensure data is written to disk
-	if err := dst.Sync(); err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("Failed to sync file to disk: %v", err),
-		})
-		return
-	}
-
-	// Verify file was written correctly
-	if bytesWritten != fileHeader.Size {
-		os.Remove(fullStoragePath)
-		// Delete the document record we created
-		h.documentRepo.Delete(c.Request.Context(), doc.ID)
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("File size mismatch: expected %d bytes, wrote %d bytes", fileHeader.Size, bytesWritten),
-		})
-		return
+	// For PDF files, verify the file starts with PDF header
+	if ext == "pdf" {
+		header := make([]byte, 4)
+		if n, err := io.ReadFull(src, header); err == nil && n == 4 && string(header) != "%PDF" {
+			// Delete the document record we created
+			h.documentRepo.Delete(c.Request.Context(), doc.ID)
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid PDF file: file does not start with PDF header",
+			})
+			return
+		}
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			h.documentRepo.Delete(c.Request.Context(), doc.ID)
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: fmt.Sprintf("Failed to read uploaded file: %v", err),
+			})
+			return
+		}
 	}
 
-	// Verify file exists and has correct size
-	if fileInfo, err := os.Stat(fullStoragePath); err != nil {
+	// Save the file at the storage key
+	if err := h.storage.Put(c.Request.Context(), storageKey, src); err != nil {
 		// Delete the document record we created
 		h.documentRepo.Delete(c.Request.Context(), doc.ID)
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("File verification failed: %v", err),
-		})
-		return
-	} else if fileInfo.Size() != fileHeader.Size {
-		os.Remove(fullStoragePath)
-		// Delete the document record we created
-		h.documentRepo.Delete(c.Request.Context(), doc.ID)
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Error:   errors.ErrInternalServer.Code,
-			Message: fmt.Sprintf("File size verification failed: expected %d bytes, got %d bytes", fileHeader.Size, fileInfo.Size()),
+			Message: fmt.Sprintf("Failed to save file: %v", err),
 		})
 		return
 	}
 
-	// For PDF files, verify the file starts with PDF header
-	if ext == "pdf" {
-		// Read first few bytes to verify it's a valid PDF
-		verifyFile, err := os.Open(fullStoragePath)
-		if err == nil {
-			header := make([]byte, 4)
-			if n, err := verifyFile.Read(header); err == nil && n == 4 {
-				if string(header) != "%PDF" {
-					verifyFile.Close()
-					os.Remove(fullStoragePath)
-					// Delete the document record we created
-					h.documentRepo.Delete(c.Request.Context(), doc.ID)
-					c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-						Error:   errors.ErrValidation.Code,
-						Message: "Invalid PDF file: file does not start with PDF header",
-					})
-					return
-				}
-			}
-			verifyFile.Close()
-		}
-	}
-
 	// Update document with actual file size
 	*doc.Content.SizeBytes = fileHeader.Size
 	if err := h.documentRepo.Update(c.Request.Context(), doc); err != nil {
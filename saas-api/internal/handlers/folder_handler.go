@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"net/http"
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
 
@@ -32,68 +34,23 @@ func (h *FolderHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Get user and org from context
-	userID, exists := c.Get("user_id")
-	if !exists || userID == nil {
+	// Org users always create folders in their own org; super admins must specify one
+	subject := policy.FromContext(c)
+	if subject.UserID == uuid.Nil {
 		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
 			Error:   errors.ErrUnauthorized.Code,
 			Message: "User ID is required",
 		})
 		return
 	}
+	uid := subject.UserID
 
-	userIDStr, ok := userID.(string)
-	if !ok {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID format",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userIDStr)
+	orgUUID, err := subject.TargetOrg(req.OrgID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "Failed to resolve organization")
 		return
 	}
 
-	// Get org_id from request body or context
-	var orgUUID uuid.UUID
-	if req.OrgID != nil {
-		orgUUID = *req.OrgID
-	} else {
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in request body or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		var err error
-		orgUUID, err = uuid.Parse(orgIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
-			return
-		}
-	}
-
 	folder := &models.Folder{
 		ID:        uuid.New(),
 		OrgID:     orgUUID,
@@ -121,48 +78,9 @@ func (h *FolderHandler) Create(c *gin.Context) {
 }
 
 func (h *FolderHandler) List(c *gin.Context) {
-	var orgUUID uuid.UUID
-	var err error
-
-	// Check if org_id is in query params (for super admins)
-	orgIDStr := c.Query("org_id")
-	if orgIDStr != "" {
-		orgUUID, err = uuid.Parse(orgIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID in query parameter",
-			})
-			return
-		}
-	} else {
-		// Get org_id from context (for regular users)
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in query parameter or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		orgUUID, err = uuid.Parse(orgIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
-			return
-		}
+	orgUUID, ok := h.resolveFolderOrg(c)
+	if !ok {
+		return
 	}
 
 	parentIDStr := c.Query("parent_id")
@@ -187,48 +105,9 @@ func (h *FolderHandler) List(c *gin.Context) {
 }
 
 func (h *FolderHandler) GetTree(c *gin.Context) {
-	var orgUUID uuid.UUID
-	var err error
-
-	// Check if org_id is in query params (for super admins)
-	orgIDStr := c.Query("org_id")
-	if orgIDStr != "" {
-		orgUUID, err = uuid.Parse(orgIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID in query parameter",
-			})
-			return
-		}
-	} else {
-		// Get org_id from context (for regular users)
-		orgID, exists := c.Get("org_id")
-		if !exists || orgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization ID is required. Provide 'org_id' in query parameter or ensure you are associated with an organization",
-			})
-			return
-		}
-
-		orgIDStr, ok := orgID.(string)
-		if !ok {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID format",
-			})
-			return
-		}
-
-		orgUUID, err = uuid.Parse(orgIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Invalid organization ID",
-			})
-			return
-		}
+	orgUUID, ok := h.resolveFolderOrg(c)
+	if !ok {
+		return
 	}
 
 	folders, err := h.folderRepo.GetTree(c.Request.Context(), orgUUID)
@@ -299,37 +178,10 @@ func (h *FolderHandler) GetByID(c *gin.Context) {
 	// Check organization access - users can only access folders from their own organization
 	// Super admins can access folders from any organization
 	// Users from the same org can access each other's folders
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		// Regular user - check if folder belongs to their organization
-		userOrgID, exists := c.Get("org_id")
-		if !exists || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this folder",
-			})
-			return
-		}
-
-		userOrgIDStr, ok := userOrgID.(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this folder",
-			})
-			return
-		}
-
-		userOrgUUID, err := uuid.Parse(userOrgIDStr)
-		if err != nil || userOrgUUID != folder.OrgID {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this folder. Folders can only be accessed by users from the same organization.",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(&folder.OrgID); err != nil {
+		respondForbidden(c, "You do not have access to this folder. Folders can only be accessed by users from the same organization.")
+		return
 	}
-	// Super admin - allow access to any folder
 
 	// Get files in this folder
 	documents, _ := h.documentRepo.GetByFolder(c.Request.Context(), id)
@@ -392,14 +244,7 @@ func (h *FolderHandler) Update(c *gin.Context) {
 		folder.ParentID = req.ParentID
 	}
 
-	userID, exists := c.Get("user_id")
-	if exists && userID != nil {
-		if userIDStr, ok := userID.(string); ok {
-			if uid, err := uuid.Parse(userIDStr); err == nil {
-				folder.UpdatedBy = &uid
-			}
-		}
-	}
+	folder.UpdatedBy = authctx.UserID(c)
 
 	if err := h.folderRepo.Update(c.Request.Context(), folder); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
@@ -547,3 +392,252 @@ func (h *FolderHandler) RemovePermission(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Permission removed successfully"})
 }
+
+func (h *FolderHandler) GetMetadataRule(c *gin.Context) {
+	folder, ok := h.getAccessibleFolder(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.folderRepo.GetMetadataRule(c.Request.Context(), folder.ID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr == errors.ErrNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error:   errors.ErrNotFound.Code,
+				Message: "No metadata rule defined for this folder",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to get folder metadata rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *FolderHandler) SetMetadataRule(c *gin.Context) {
+	folder, ok := h.getAccessibleFolder(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetFolderMetadataRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if len(req.Tags) == 0 && len(req.DefaultMetadata) == 0 {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "At least one tag or default metadata key is required",
+		})
+		return
+	}
+	if _, exists := req.DefaultMetadata["tags"]; exists {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Use 'tags' instead of default_metadata.tags",
+		})
+		return
+	}
+
+	rule := &models.FolderMetadataRule{
+		FolderID:          folder.ID,
+		Tags:              req.Tags,
+		DefaultMetadata:   req.DefaultMetadata,
+		ApplyToSubfolders: true,
+	}
+	if req.ApplyToSubfolders != nil {
+		rule.ApplyToSubfolders = *req.ApplyToSubfolders
+	}
+
+	rule.UpdatedBy = authctx.UserID(c)
+
+	if err := h.folderRepo.UpsertMetadataRule(c.Request.Context(), rule); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.Status, errors.ErrorResponse{
+				Error:   appErr.Code,
+				Message: appErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to save folder metadata rule",
+		})
+		return
+	}
+
+	rule.FolderPath = &folder.Path
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *FolderHandler) DeleteMetadataRule(c *gin.Context) {
+	folder, ok := h.getAccessibleFolder(c)
+	if !ok {
+		return
+	}
+
+	if err := h.folderRepo.DeleteMetadataRule(c.Request.Context(), folder.ID); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			c.JSON(appErr.Status, errors.ErrorResponse{
+				Error:   appErr.Code,
+				Message: appErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to delete folder metadata rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder metadata rule deleted successfully"})
+}
+
+// ApplyMetadataRules re-applies effective folder rules to documents already in the folder
+// (and its subfolders when the folder's rule is inherited)
+func (h *FolderHandler) ApplyMetadataRules(c *gin.Context) {
+	folder, ok := h.getAccessibleFolder(c)
+	if !ok {
+		return
+	}
+
+	var req models.ApplyFolderMetadataRulesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	folderIDs := []uuid.UUID{folder.ID}
+	if rule, err := h.folderRepo.GetMetadataRule(ctx, folder.ID); err == nil && rule.ApplyToSubfolders {
+		descendantIDs, err := h.folderRepo.GetDescendantIDs(ctx, folder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: "Failed to list subfolders",
+			})
+			return
+		}
+		folderIDs = append(folderIDs, descendantIDs...)
+	}
+
+	updatedBy := authctx.UserID(c)
+
+	updated, failed := 0, 0
+	for _, folderID := range folderIDs {
+		rules, err := h.folderRepo.GetEffectiveMetadataRules(ctx, folderID)
+		if err != nil || len(rules) == 0 {
+			continue
+		}
+
+		documents, err := h.documentRepo.GetByFolder(ctx, folderID)
+		if err != nil {
+			failed++
+			continue
+		}
+
+		for _, doc := range documents {
+			metadata := repositories.ApplyMetadataRules(doc.Metadata, rules, req.Overwrite)
+			if err := h.documentRepo.UpdateMetadata(ctx, doc.ID, metadata, updatedBy); err != nil {
+				failed++
+				continue
+			}
+			updated++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Folder metadata rules applied",
+		"folders_processed": len(folderIDs),
+		"documents_updated": updated,
+		"documents_failed":  failed,
+	})
+}
+
+// getAccessibleFolder loads the folder from the :id param and enforces same-org access for non super admins
+func (h *FolderHandler) getAccessibleFolder(c *gin.Context) (*models.Folder, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid folder ID",
+		})
+		return nil, false
+	}
+
+	folder, err := h.folderRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr == errors.ErrNotFound {
+			c.JSON(http.StatusNotFound, errors.ErrorResponse{
+				Error:   errors.ErrNotFound.Code,
+				Message: "Folder not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+			Error:   errors.ErrInternalServer.Code,
+			Message: "Failed to get folder",
+		})
+		return nil, false
+	}
+
+	if err := policy.FromContext(c).CanAccess(&folder.OrgID); err != nil {
+		respondForbidden(c, "You do not have access to this folder")
+		return nil, false
+	}
+
+	return folder, true
+}
+
+// resolveFolderOrg picks the org whose folders are listed: super admins may pass ?org_id,
+// everyone else is pinned to their own org
+func (h *FolderHandler) resolveFolderOrg(c *gin.Context) (uuid.UUID, bool) {
+	var requestedOrgID *uuid.UUID
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
+		parsed, err := uuid.Parse(orgIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid organization ID in query parameter",
+			})
+			return uuid.Nil, false
+		}
+		requestedOrgID = &parsed
+	}
+
+	subject := policy.FromContext(c)
+	if requestedOrgID == nil {
+		requestedOrgID = subject.OrgID
+	}
+
+	orgID, err := subject.ListScope(requestedOrgID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve organization")
+		return uuid.Nil, false
+	}
+	if orgID == nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Organization ID is required. Provide 'org_id' in query parameter or ensure you are associated with an organization",
+		})
+		return uuid.Nil, false
+	}
+
+	return *orgID, true
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/internal/services"
+	"saas-api/pkg/weaviate"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	db             *database.DB
+	weaviateClient *weaviate.WeaviateClient // nil when the document service is unavailable
+}
+
+func NewHealthHandler(db *database.DB, weaviateClient *weaviate.WeaviateClient) *HealthHandler {
+	return &HealthHandler{
+		db:             db,
+		weaviateClient: weaviateClient,
+	}
+}
+
+// Readyz reports whether the API can serve traffic: the database is reachable and, when Weaviate
+// is configured, its document classes match the schema the search code expects
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	ready := true
+	checks := gin.H{}
+
+	if err := h.db.Ping(ctx); err != nil {
+		ready = false
+		checks["database"] = services.HealthStatus{Status: "error", Timestamp: time.Now(), Details: err.Error()}
+	} else {
+		checks["database"] = services.HealthStatus{Status: "ok", Timestamp: time.Now()}
+	}
+
+	if h.weaviateClient != nil {
+		report, err := h.weaviateClient.VerifySchema(ctx, false)
+		switch {
+		case err != nil:
+			ready = false
+			checks["weaviate_schema"] = services.HealthStatus{Status: "error", Timestamp: time.Now(), Details: err.Error()}
+		case !report.OK():
+			ready = false
+			checks["weaviate_schema"] = gin.H{"status": "drift", "report": report}
+		default:
+			checks["weaviate_schema"] = gin.H{"status": "ok", "report": report}
+		}
+	}
+
+	status := http.StatusOK
+	state := "ready"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		state = "not_ready"
+	}
+
+	c.JSON(status, gin.H{
+		"status": state,
+		"checks": checks,
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImpersonationHandler lets super admins sign in as a user for support
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+	authMW               *middleware.AuthMiddleware
+}
+
+func NewImpersonationHandler(impersonationService *services.ImpersonationService, authMW *middleware.AuthMiddleware) *ImpersonationHandler {
+	return &ImpersonationHandler{impersonationService: impersonationService, authMW: authMW}
+}
+
+// Impersonate issues a short-lived access token acting as the user; the body's reason is optional
+// POST /api/v1/admin/impersonate/:user_id
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.impersonationService.Impersonate(c.Request.Context(), policy.FromContext(c).UserID, userID, req.Reason, h.authMW.GetClientIP(c), c.Request.UserAgent())
+	if err != nil {
+		respondError(c, err, "Failed to impersonate user")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvitationHandler serves org invitations. Accepting is public: the invited person has no account
+// until the link is followed.
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+	authMW            *middleware.AuthMiddleware
+}
+
+func NewInvitationHandler(invitationService *services.InvitationService, authMW *middleware.AuthMiddleware) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		authMW:            authMW,
+	}
+}
+
+// Create invites an email into the org and sends the link
+// POST /api/v1/invitations
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	subject := policy.FromContext(c)
+	orgID, err := subject.TargetOrg(req.OrgID)
+	if err != nil {
+		respondError(c, err, "Access denied")
+		return
+	}
+
+	invitation, err := h.invitationService.Create(c.Request.Context(), orgID, req, subject.UserID, h.authMW.GetClientIP(c))
+	if err != nil {
+		respondError(c, err, "Failed to create invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": invitation})
+}
+
+// List returns the org's invitations, optionally filtered by ?status=
+// GET /api/v1/invitations?org_id=&status=
+func (h *InvitationHandler) List(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.List(c.Request.Context(), orgID, c.Query("status"))
+	if err != nil {
+		respondError(c, err, "Failed to list invitations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+// Cancel stops an invitation's link from working
+// DELETE /api/v1/invitations/:id?org_id=
+func (h *InvitationHandler) Cancel(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := invitationIDParam(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationService.Cancel(c.Request.Context(), orgID, invitationID, policy.FromContext(c).UserID, h.authMW.GetClientIP(c))
+	if err != nil {
+		respondError(c, err, "Failed to cancel invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    invitation,
+		"message": "Invitation cancelled successfully",
+	})
+}
+
+// Resend emails a new link, which also renews an expired invitation
+// POST /api/v1/invitations/:id/resend?org_id=
+func (h *InvitationHandler) Resend(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	invitationID, ok := invitationIDParam(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationService.Resend(c.Request.Context(), orgID, invitationID, policy.FromContext(c).UserID, h.authMW.GetClientIP(c))
+	if err != nil {
+		respondError(c, err, "Failed to resend invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    invitation,
+		"message": "Invitation sent",
+	})
+}
+
+// Accept creates the invited account with the token from the link
+// POST /api/v1/invitations/accept
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.invitationService.Accept(c.Request.Context(), req, h.authMW.GetClientIP(c))
+	if err != nil {
+		respondError(c, err, "Failed to accept invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    user,
+		"message": "Invitation accepted. Please sign in with your email and password",
+	})
+}
+
+func invitationIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid invitation ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"saas-api/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys of RS256 signing keys, so the proxy and other services
+// validate access tokens without the HMAC secret
+type JWKSHandler struct {
+	tokenService *auth.TokenService
+}
+
+func NewJWKSHandler(tokenService *auth.TokenService) *JWKSHandler {
+	return &JWKSHandler{tokenService: tokenService}
+}
+
+// Keys returns the key set; it is empty while tokens are signed with HS256 only
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) Keys(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": h.tokenService.PublicKeys(time.Now())})
+}
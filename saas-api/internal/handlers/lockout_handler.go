@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// LockoutHandler lets admins lift the lockout of an account of their org
+type LockoutHandler struct {
+	lockoutService *services.LockoutService
+}
+
+func NewLockoutHandler(lockoutService *services.LockoutService) *LockoutHandler {
+	return &LockoutHandler{lockoutService: lockoutService}
+}
+
+// Unlock clears a user's lockout and failed sign-in count
+// POST /api/v1/users/:id/unlock
+func (h *LockoutHandler) Unlock(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	user, err := h.lockoutService.User(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return
+	}
+	if !canManageUser(c, user, "Cannot unlock users outside your organization") {
+		return
+	}
+
+	if err := h.lockoutService.Unlock(c.Request.Context(), user, policy.FromContext(c).UserID); err != nil {
+		respondError(c, err, "Failed to unlock user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// LoginHistoryHandler serves sign-in attempts: the signed-in user's own under /auth/me/logins, and
+// any user's in the caller's org under /users/:id/login-history for admins
+type LoginHistoryHandler struct {
+	loginHistoryService *services.LoginHistoryService
+}
+
+func NewLoginHistoryHandler(loginHistoryService *services.LoginHistoryService) *LoginHistoryHandler {
+	return &LoginHistoryHandler{loginHistoryService: loginHistoryService}
+}
+
+// List returns the current user's sign-in attempts, newest first
+// GET /api/v1/auth/me/logins?page=&limit=
+func (h *LoginHistoryHandler) List(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.list(c, user.ID)
+}
+
+// ListForUser returns a user's sign-in attempts, newest first
+// GET /api/v1/users/:id/login-history?page=&limit=
+func (h *LoginHistoryHandler) ListForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	user, err := h.loginHistoryService.User(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return
+	}
+	if !canManageUser(c, user, "Cannot view login history of users outside your organization") {
+		return
+	}
+	h.list(c, userID)
+}
+
+func (h *LoginHistoryHandler) list(c *gin.Context, userID uuid.UUID) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	events, total, err := h.loginHistoryService.List(c.Request.Context(), userID, page, limit)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve login history")
+		return
+	}
+
+	totalPages := (total + limit - 1) / limit
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        events,
+		"page":        page,
+		"limit":       limit,
+		"total":       total,
+		"total_pages": totalPages,
+	})
+}
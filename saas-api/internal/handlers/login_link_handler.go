@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginLinkHandler serves passwordless sign-in by emailed link. Both endpoints are public.
+type LoginLinkHandler struct {
+	loginLinkService *services.LoginLinkService
+	authMW           *middleware.AuthMiddleware
+}
+
+func NewLoginLinkHandler(loginLinkService *services.LoginLinkService, authMW *middleware.AuthMiddleware) *LoginLinkHandler {
+	return &LoginLinkHandler{
+		loginLinkService: loginLinkService,
+		authMW:           authMW,
+	}
+}
+
+// SendLink emails a sign-in link; the answer is the same whether or not the email has an account
+// POST /api/v1/auth/send-link
+func (h *LoginLinkHandler) SendLink(c *gin.Context) {
+	var req models.SendLoginLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.loginLinkService.SendLink(c.Request.Context(), req.Email, h.authMW.GetClientIP(c)); err != nil {
+		respondError(c, err, "Failed to send sign-in link")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a sign-in link has been sent"})
+}
+
+// VerifyLink exchanges the token from a sign-in link for login tokens, or for an MFA token when the
+// user has two-factor authentication
+// POST /api/v1/auth/verify-link
+func (h *LoginLinkHandler) VerifyLink(c *gin.Context) {
+	var req models.VerifyLoginLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.loginLinkService.VerifyLink(c.Request.Context(), req.Token, h.authMW.GetClientIP(c), c.GetHeader("User-Agent"))
+	if err != nil {
+		respondError(c, err, "Sign-in link verification failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
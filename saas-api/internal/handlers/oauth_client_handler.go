@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// OAuthClientHandler serves the org's OAuth2 clients and the token endpoint they call. The client
+// secret is only in the create response; listings show its prefix. Super admins pick the org with
+// org_id.
+type OAuthClientHandler struct {
+	oauthClientService *services.OAuthClientService
+	authMW             *middleware.AuthMiddleware
+}
+
+func NewOAuthClientHandler(oauthClientService *services.OAuthClientService, authMW *middleware.AuthMiddleware) *OAuthClientHandler {
+	return &OAuthClientHandler{
+		oauthClientService: oauthClientService,
+		authMW:             authMW,
+	}
+}
+
+// List returns the org's OAuth clients, including revoked ones
+// GET /api/v1/oauth-clients?org_id=
+func (h *OAuthClientHandler) List(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.oauthClientService.List(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, err, "Failed to list OAuth clients")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": clients})
+}
+
+// Create registers a client; the response is the only time the secret is returned
+// POST /api/v1/oauth-clients
+func (h *OAuthClientHandler) Create(c *gin.Context) {
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	subject := policy.FromContext(c)
+	orgID, err := subject.TargetOrg(req.OrgID)
+	if err != nil {
+		respondError(c, err, "Access denied")
+		return
+	}
+
+	client, err := h.oauthClientService.Create(c.Request.Context(), orgID, req, subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to create OAuth client")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": client})
+}
+
+// GetByID returns one of the org's OAuth clients
+// GET /api/v1/oauth-clients/:id?org_id=
+func (h *OAuthClientHandler) GetByID(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	clientID, ok := oauthClientIDParam(c)
+	if !ok {
+		return
+	}
+
+	client, err := h.oauthClientService.Get(c.Request.Context(), orgID, clientID)
+	if err != nil {
+		respondError(c, err, "Failed to get OAuth client")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": client})
+}
+
+// Revoke stops a client from getting tokens
+// DELETE /api/v1/oauth-clients/:id?org_id=
+func (h *OAuthClientHandler) Revoke(c *gin.Context) {
+	orgID, ok := apiKeyOrgQuery(c)
+	if !ok {
+		return
+	}
+	clientID, ok := oauthClientIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	client, err := h.oauthClientService.Revoke(c.Request.Context(), orgID, clientID, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to revoke OAuth client")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    client,
+		"message": "OAuth client revoked successfully",
+	})
+}
+
+// Token is the OAuth2 token endpoint for the client_credentials grant. Errors are RFC 6749 error
+// responses rather than ErrorResponse.
+// POST /api/v1/oauth/token
+func (h *OAuthClientHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req models.OAuthTokenRequest
+	if err := c.ShouldBindWith(&req, binding.FormPost); err != nil {
+		oauthError(c, services.ErrOAuthInvalidRequest, false)
+		return
+	}
+
+	// HTTP Basic credentials are form-encoded (RFC 6749 section 2.3.1)
+	clientID, clientSecret, basicAuth := c.Request.BasicAuth()
+	if basicAuth {
+		var idErr, secretErr error
+		clientID, idErr = url.QueryUnescape(clientID)
+		clientSecret, secretErr = url.QueryUnescape(clientSecret)
+		if idErr != nil || secretErr != nil {
+			oauthError(c, services.ErrOAuthInvalidClient, true)
+			return
+		}
+	}
+
+	response, err := h.oauthClientService.IssueToken(c.Request.Context(), req, basicAuth, clientID, clientSecret, h.authMW.GetClientIP(c))
+	if err != nil {
+		oauthError(c, err, basicAuth)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// oauthError writes err as an RFC 6749 error response; internal errors become server_error
+func oauthError(c *gin.Context, err error, basicAuth bool) {
+	appErr, ok := err.(*errors.AppError)
+	if !ok || appErr.Status >= http.StatusInternalServerError {
+		c.JSON(http.StatusInternalServerError, models.OAuthErrorResponse{
+			Error:            "server_error",
+			ErrorDescription: "Failed to issue token",
+		})
+		return
+	}
+	if appErr.Status == http.StatusUnauthorized && basicAuth {
+		c.Header("WWW-Authenticate", `Basic realm="saas-api"`)
+	}
+	c.JSON(appErr.Status, models.OAuthErrorResponse{
+		Error:            appErr.Code,
+		ErrorDescription: appErr.Message,
+	})
+}
+
+func oauthClientIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid OAuth client ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCProviderHandler serves the organization's single sign-on settings. The client secret is not
+// part of them: it is an org secret, referenced by name.
+type OIDCProviderHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCProviderHandler(oidcService *services.OIDCService) *OIDCProviderHandler {
+	return &OIDCProviderHandler{oidcService: oidcService}
+}
+
+// Get returns the organization's identity provider
+// GET /api/v1/organizations/:id/oidc
+func (h *OIDCProviderHandler) Get(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	provider, err := h.oidcService.GetProvider(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, err, "Failed to get OIDC provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": provider})
+}
+
+// Set creates or replaces the organization's identity provider
+// PUT /api/v1/organizations/:id/oidc
+func (h *OIDCProviderHandler) Set(c *gin.Context) {
+	var req models.SetOrgOIDCProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	provider, err := h.oidcService.SetProvider(c.Request.Context(), orgID, req, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to save OIDC provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": provider})
+}
+
+// Delete turns single sign-on off for the organization
+// DELETE /api/v1/organizations/:id/oidc
+func (h *OIDCProviderHandler) Delete(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	if err := h.oidcService.DeleteProvider(c.Request.Context(), orgID, &subject.UserID); err != nil {
+		respondError(c, err, "Failed to delete OIDC provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OIDC provider deleted successfully"})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrgSecretHandler serves the org secrets API. Responses only ever carry metadata: a value cannot
+// be read back once written, only rotated or deleted.
+type OrgSecretHandler struct {
+	secretService *services.OrgSecretService
+	proxyConfig   config.ProxyConfig
+}
+
+func NewOrgSecretHandler(secretService *services.OrgSecretService, proxyConfig config.ProxyConfig) *OrgSecretHandler {
+	return &OrgSecretHandler{
+		secretService: secretService,
+		proxyConfig:   proxyConfig,
+	}
+}
+
+// List returns the organization's secrets (names and metadata, no values)
+// GET /api/v1/organizations/:id/secrets
+func (h *OrgSecretHandler) List(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	secrets, err := h.secretService.List(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, err, "Failed to list secrets")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": secrets})
+}
+
+// Create stores a new secret
+// POST /api/v1/organizations/:id/secrets
+func (h *OrgSecretHandler) Create(c *gin.Context) {
+	var req models.CreateOrgSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	secret, err := h.secretService.Create(c.Request.Context(), orgID, req, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to create secret")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": secret})
+}
+
+// Rotate replaces a secret's value
+// PUT /api/v1/organizations/:id/secrets/:name
+func (h *OrgSecretHandler) Rotate(c *gin.Context) {
+	var req models.RotateOrgSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	secret, err := h.secretService.Rotate(c.Request.Context(), orgID, c.Param("name"), req.Value, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to rotate secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": secret})
+}
+
+// Delete removes a secret
+// DELETE /api/v1/organizations/:id/secrets/:name
+func (h *OrgSecretHandler) Delete(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	if err := h.secretService.Delete(c.Request.Context(), orgID, c.Param("name"), &subject.UserID); err != nil {
+		respondError(c, err, "Failed to delete secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Secret deleted successfully"})
+}
+
+// Resolve returns a secret's value to a trusted service. Requires the X-Proxy-Secret header, like
+// the proxy token exchange; disabled when PROXY_SHARED_SECRET is unset.
+// POST /api/v1/internal/secrets/resolve
+func (h *OrgSecretHandler) Resolve(c *gin.Context) {
+	if h.proxyConfig.SharedSecret == "" {
+		respondError(c, errors.NewError("NOT_FOUND", "Secret resolution is not enabled", http.StatusNotFound), "")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Proxy-Secret")), []byte(h.proxyConfig.SharedSecret)) != 1 {
+		respondError(c, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.ResolveOrgSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	value, err := h.secretService.Resolve(c.Request.Context(), req.OrgID, req.Name)
+	if err != nil {
+		respondError(c, err, "Failed to resolve secret")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"name": req.Name, "value": value})
+}
@@ -7,10 +7,14 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
+	"saas-api/pkg/orgtime"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,6 +24,14 @@ type OrganizationHandler struct {
 	orgRepo  *repositories.OrganizationRepository
 	roleRepo *repositories.RoleRepository
 	permRepo *repositories.PermissionRepository
+
+	timezones     *orgtime.Resolver // Optional: invalidated when an org's timezone changes
+	scheduledJobs []OrgScheduledJob // Reported by Schedule
+}
+
+// OrgScheduledJob is a background job that can describe its schedule in an organization's timezone
+type OrgScheduledJob interface {
+	Schedule(now time.Time, loc *time.Location) []models.ScheduledJob
 }
 
 func NewOrganizationHandler(orgRepo *repositories.OrganizationRepository, roleRepo *repositories.RoleRepository, permRepo *repositories.PermissionRepository) *OrganizationHandler {
@@ -30,6 +42,12 @@ func NewOrganizationHandler(orgRepo *repositories.OrganizationRepository, roleRe
 	}
 }
 
+// SetSchedule wires the org timezone cache and the background jobs reported by Schedule
+func (h *OrganizationHandler) SetSchedule(timezones *orgtime.Resolver, jobs ...OrgScheduledJob) {
+	h.timezones = timezones
+	h.scheduledJobs = jobs
+}
+
 func (h *OrganizationHandler) Create(c *gin.Context) {
 	var req models.CreateOrganizationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -40,8 +58,12 @@ func (h *OrganizationHandler) Create(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	uid, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	uid := caller.ID
 
 	// Auto-generate slug from name (internal use only, not exposed in API)
 	// Generate slug from name: lowercase, replace spaces with hyphens, remove special chars
@@ -98,6 +120,18 @@ func (h *OrganizationHandler) Create(c *gin.Context) {
 	// Set default values
 	maxUsers := 100
 	maxStorageGB := 1
+	timezone := orgtime.DefaultTimezone
+
+	if req.Timezone != nil && *req.Timezone != "" {
+		if !orgtime.Valid(*req.Timezone) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Invalid timezone %q, expected an IANA name such as Asia/Kolkata", *req.Timezone),
+			})
+			return
+		}
+		timezone = *req.Timezone
+	}
 
 	// Override with request values if provided
 	if req.MaxUsers != nil {
@@ -127,7 +161,7 @@ func (h *OrganizationHandler) Create(c *gin.Context) {
 		SubscriptionStatus:  "active",
 		MaxUsers:            maxUsers,
 		MaxStorageGB:        maxStorageGB,
-		Timezone:            "UTC",
+		Timezone:            timezone,
 		DateFormat:          "YYYY-MM-DD",
 		Locale:              "en-US",
 		Status:              "active",
@@ -302,8 +336,13 @@ func (h *OrganizationHandler) Update(c *gin.Context) {
 	if req.BillingEmail != nil {
 		org.BillingEmail = req.BillingEmail
 	}
-	if req.SubscriptionPlan != nil {
-		org.SubscriptionPlan = *req.SubscriptionPlan
+	if req.SubscriptionPlan != nil && *req.SubscriptionPlan != org.SubscriptionPlan {
+		// Plan changes need limit checks and billing events, see SubscriptionHandler
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Use POST /organizations/:id/subscription/upgrade or /downgrade to change the subscription plan",
+		})
+		return
 	}
 	if req.Status != nil {
 		// Validate status value - must match database enum: active, suspended, pending, deleted
@@ -317,9 +356,23 @@ func (h *OrganizationHandler) Update(c *gin.Context) {
 	if req.Settings != nil {
 		org.Settings = req.Settings
 	}
+	if req.Timezone != nil {
+		if !orgtime.Valid(*req.Timezone) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Invalid timezone %q, expected an IANA name such as Asia/Kolkata", *req.Timezone),
+			})
+			return
+		}
+		org.Timezone = *req.Timezone
+	}
 
-	userID, _ := c.Get("user_id")
-	uid, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	uid := caller.ID
 	org.UpdatedBy = &uid
 
 	if err := h.orgRepo.Update(c.Request.Context(), org); err != nil {
@@ -340,9 +393,59 @@ func (h *OrganizationHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// Schedules and usage buckets pick up a new timezone immediately
+	h.timezones.Invalidate(org.ID)
+
 	c.JSON(http.StatusOK, org)
 }
 
+// Schedule reports the organization's effective timezone, the current local day and month
+// boundaries, and how each background job applies to the organization
+// GET /api/v1/organizations/:id/schedule
+func (h *OrganizationHandler) Schedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid organization ID",
+		})
+		return
+	}
+
+	if err := policy.FromContext(c).CanAccess(&id); err != nil {
+		respondForbidden(c, "Cannot view the schedule of another organization")
+		return
+	}
+	if _, err := h.orgRepo.GetTimezone(c.Request.Context(), id); err != nil {
+		respondError(c, err, "Failed to get organization")
+		return
+	}
+
+	// Always read fresh: this is where admins check a timezone change took effect
+	h.timezones.Invalidate(id)
+	name, loc := h.timezones.Zone(c.Request.Context(), &id)
+	now := time.Now()
+	dayStart := orgtime.StartOfDay(now, loc)
+	monthStart := orgtime.StartOfMonth(now, loc)
+
+	schedule := models.OrganizationSchedule{
+		OrgID:          id,
+		Timezone:       name,
+		UTCOffset:      orgtime.Offset(now, loc),
+		LocalTime:      now.In(loc),
+		DayStart:       dayStart,
+		NextDayStart:   time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day()+1, 0, 0, 0, 0, loc),
+		MonthStart:     monthStart,
+		NextMonthStart: time.Date(monthStart.Year(), monthStart.Month()+1, 1, 0, 0, 0, 0, loc),
+		Jobs:           make([]models.ScheduledJob, 0, len(h.scheduledJobs)),
+	}
+	for _, job := range h.scheduledJobs {
+		schedule.Jobs = append(schedule.Jobs, job.Schedule(now, loc)...)
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
 func (h *OrganizationHandler) Delete(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -353,8 +456,12 @@ func (h *OrganizationHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	uid, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	uid := caller.ID
 
 	reason := "Deleted by user"
 	if err := h.orgRepo.Delete(c.Request.Context(), id, uid, reason); err != nil {
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PasskeyHandler serves passkey (WebAuthn) sign-in and the signed-in user's passkeys. Each ceremony
+// is a begin call, whose options go to the browser's WebAuthn API, and a finish call with its result.
+type PasskeyHandler struct {
+	passkeyService *services.PasskeyService
+	authMW         *middleware.AuthMiddleware
+}
+
+func NewPasskeyHandler(passkeyService *services.PasskeyService, authMW *middleware.AuthMiddleware) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeyService: passkeyService,
+		authMW:         authMW,
+	}
+}
+
+// List returns the current user's passkeys
+// GET /api/v1/auth/passkeys
+func (h *PasskeyHandler) List(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	passkeys, err := h.passkeyService.List(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to list passkeys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": passkeys})
+}
+
+// BeginRegistration returns the options for navigator.credentials.create()
+// POST /api/v1/auth/passkeys/register/begin
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	ceremony, err := h.passkeyService.BeginRegistration(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to start passkey registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ceremony})
+}
+
+// FinishRegistration stores the passkey the browser created
+// POST /api/v1/auth/passkeys/register/finish
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	var req models.FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	passkey, err := h.passkeyService.FinishRegistration(c.Request.Context(), user.ID, req, c.GetHeader("User-Agent"))
+	if err != nil {
+		respondError(c, err, "Failed to register passkey")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": passkey})
+}
+
+// Rename changes the name a passkey is listed under
+// PATCH /api/v1/auth/passkeys/:id
+func (h *PasskeyHandler) Rename(c *gin.Context) {
+	var req models.RenamePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	passkeyID, ok := passkeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	passkey, err := h.passkeyService.Rename(c.Request.Context(), user.ID, passkeyID, req.Name)
+	if err != nil {
+		respondError(c, err, "Failed to rename passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": passkey})
+}
+
+// Delete removes one of the current user's passkeys
+// DELETE /api/v1/auth/passkeys/:id
+func (h *PasskeyHandler) Delete(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	passkeyID, ok := passkeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.passkeyService.Delete(c.Request.Context(), user.ID, passkeyID); err != nil {
+		respondError(c, err, "Failed to delete passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+// BeginLogin returns the options for navigator.credentials.get()
+// POST /api/v1/auth/passkeys/login/begin
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	ceremony, err := h.passkeyService.BeginLogin()
+	if err != nil {
+		respondError(c, err, "Failed to start passkey sign-in")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ceremony})
+}
+
+// FinishLogin signs in with the passkey the browser used; the response is the login response
+// POST /api/v1/auth/passkeys/login/finish
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req models.FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ipAddress := h.authMW.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	response, err := h.passkeyService.FinishLogin(c.Request.Context(), req, ipAddress, userAgent)
+	if err != nil {
+		respondError(c, err, "Passkey sign-in failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func passkeyIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid passkey ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
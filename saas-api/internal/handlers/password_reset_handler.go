@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordResetHandler serves the forgot-password flow. Both endpoints are public.
+type PasswordResetHandler struct {
+	resetService *services.PasswordResetService
+	authMW       *middleware.AuthMiddleware
+}
+
+func NewPasswordResetHandler(resetService *services.PasswordResetService, authMW *middleware.AuthMiddleware) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		resetService: resetService,
+		authMW:       authMW,
+	}
+}
+
+// ForgotPassword emails a reset link; the answer is the same whether or not the email has an account
+// POST /api/v1/auth/forgot-password
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.resetService.ForgotPassword(c.Request.Context(), req.Email, h.authMW.GetClientIP(c)); err != nil {
+		respondError(c, err, "Failed to request password reset")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password with the token from the reset link
+// POST /api/v1/auth/reset-password
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.resetService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword, h.authMW.GetClientIP(c)); err != nil {
+		respondError(c, err, "Failed to reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please sign in with your new password"})
+}
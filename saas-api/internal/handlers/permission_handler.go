@@ -3,22 +3,25 @@ package handlers
 import (
 	"net/http"
 
-	"saas-api/internal/repositories"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
 	"saas-api/pkg/errors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type PermissionHandler struct {
-	permRepo *repositories.PermissionRepository
+	permissionService *services.PermissionService
 }
 
-func NewPermissionHandler(permRepo *repositories.PermissionRepository) *PermissionHandler {
-	return &PermissionHandler{permRepo: permRepo}
+func NewPermissionHandler(permissionService *services.PermissionService) *PermissionHandler {
+	return &PermissionHandler{permissionService: permissionService}
 }
 
 func (h *PermissionHandler) List(c *gin.Context) {
-	permissions, err := h.permRepo.List(c.Request.Context())
+	permissions, err := h.permissionService.List(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
@@ -29,3 +32,78 @@ func (h *PermissionHandler) List(c *gin.Context) {
 
 	c.JSON(http.StatusOK, permissions)
 }
+
+// Create registers a permission, which can then be granted to roles
+// POST /api/v1/permissions
+func (h *PermissionHandler) Create(c *gin.Context) {
+	var req models.CreatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	perm, err := h.permissionService.Create(c.Request.Context(), req, policy.FromContext(c).UserID)
+	if err != nil {
+		respondError(c, err, "Failed to create permission")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": perm})
+}
+
+// Update changes a permission's description; system permissions cannot be changed
+// PUT /api/v1/permissions/:id
+func (h *PermissionHandler) Update(c *gin.Context) {
+	id, ok := permissionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	perm, err := h.permissionService.Update(c.Request.Context(), id, req, policy.FromContext(c).UserID)
+	if err != nil {
+		respondError(c, err, "Failed to update permission")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": perm})
+}
+
+// Delete removes a permission from every role and deletes it; system permissions cannot be deleted
+// DELETE /api/v1/permissions/:id
+func (h *PermissionHandler) Delete(c *gin.Context) {
+	id, ok := permissionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.permissionService.Delete(c.Request.Context(), id, policy.FromContext(c).UserID); err != nil {
+		respondError(c, err, "Failed to delete permission")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission deleted successfully"})
+}
+
+func permissionIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid permission ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
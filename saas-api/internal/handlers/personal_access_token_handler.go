@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PersonalAccessTokenHandler serves personal access tokens: the signed-in user's own under
+// /auth/personal-tokens, and any user's in the caller's org under /users/:id/personal-tokens for
+// admins. The token itself is only in the create response; listings show its prefix.
+type PersonalAccessTokenHandler struct {
+	tokenService *services.PersonalAccessTokenService
+}
+
+func NewPersonalAccessTokenHandler(tokenService *services.PersonalAccessTokenService) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{tokenService: tokenService}
+}
+
+// List returns the current user's tokens, including revoked ones
+// GET /api/v1/auth/personal-tokens
+func (h *PersonalAccessTokenHandler) List(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.list(c, user.ID)
+}
+
+// Create mints a token for the current user; the response is the only time the token is returned
+// POST /api/v1/auth/personal-tokens
+func (h *PersonalAccessTokenHandler) Create(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	token, err := h.tokenService.Create(c.Request.Context(), user.ID, req)
+	if err != nil {
+		respondError(c, err, "Failed to create personal access token")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": token})
+}
+
+// Revoke stops one of the current user's tokens from authenticating
+// DELETE /api/v1/auth/personal-tokens/:id
+func (h *PersonalAccessTokenHandler) Revoke(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.revoke(c, user.ID, user.ID, "id")
+}
+
+// ListForUser returns a user's tokens
+// GET /api/v1/users/:id/personal-tokens
+func (h *PersonalAccessTokenHandler) ListForUser(c *gin.Context) {
+	userID, ok := h.tokenUserParam(c)
+	if !ok {
+		return
+	}
+	h.list(c, userID)
+}
+
+// RevokeForUser stops one of a user's tokens from authenticating
+// DELETE /api/v1/users/:id/personal-tokens/:token_id
+func (h *PersonalAccessTokenHandler) RevokeForUser(c *gin.Context) {
+	userID, ok := h.tokenUserParam(c)
+	if !ok {
+		return
+	}
+	h.revoke(c, userID, policy.FromContext(c).UserID, "token_id")
+}
+
+func (h *PersonalAccessTokenHandler) list(c *gin.Context, userID uuid.UUID) {
+	tokens, err := h.tokenService.List(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to list personal access tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+func (h *PersonalAccessTokenHandler) revoke(c *gin.Context, userID, actorID uuid.UUID, tokenParam string) {
+	tokenID, err := uuid.Parse(c.Param(tokenParam))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid token ID",
+		})
+		return
+	}
+
+	token, err := h.tokenService.Revoke(c.Request.Context(), userID, tokenID, actorID)
+	if err != nil {
+		respondError(c, err, "Failed to revoke personal access token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    token,
+		"message": "Personal access token revoked successfully",
+	})
+}
+
+// tokenUserParam resolves :id to a user whose tokens the caller may manage (see canManageUser)
+func (h *PersonalAccessTokenHandler) tokenUserParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return uuid.Nil, false
+	}
+
+	user, err := h.tokenService.User(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return uuid.Nil, false
+	}
+	if !canManageUser(c, user, "Cannot manage personal access tokens of users outside your organization") {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
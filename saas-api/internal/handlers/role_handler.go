@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
 
@@ -31,45 +35,25 @@ func (h *RoleHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Get org_id from context or request
-	orgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
-	var orgIDPtr *uuid.UUID
-	var roleType string
-
 	// Super admin can specify org_id in request, or create system role (org_id = NULL)
-	if isSuperAdmin != nil && isSuperAdmin.(bool) {
-		if req.OrgID != nil {
-			// Super admin specified org_id in request
-			orgIDPtr = req.OrgID
-			roleType = "org_defined"
-		} else if orgID != nil && orgID != "" {
-			// Super admin has org context, use it
-			uid, _ := uuid.Parse(orgID.(string))
-			orgIDPtr = &uid
-			roleType = "org_defined"
-		} else {
-			// Super admin creating system role (no org_id)
-			orgIDPtr = nil
-			roleType = "system"
-		}
-	} else {
-		// Org admin or regular user - must use their own org
-		if orgID == nil || orgID == "" {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Organization context required",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(orgID.(string))
-		orgIDPtr = &uid
-		roleType = "org_defined"
+	// Org admins and regular users always create roles in their own org
+	orgIDPtr, err := policy.FromContext(c).OwnerOrg(req.OrgID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve organization")
+		return
 	}
 
-	userID, _ := c.Get("user_id")
-	createdBy, _ := uuid.Parse(userID.(string))
+	roleType := "org_defined"
+	if orgIDPtr == nil {
+		roleType = "system"
+	}
+
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	createdBy := caller.ID
 
 	role := &models.Role{
 		ID:          uuid.New(),
@@ -85,6 +69,10 @@ func (h *RoleHandler) Create(c *gin.Context) {
 		role.IsDefault = *req.IsDefault
 	}
 
+	if len(req.ParentIDs) > 0 && !h.checkParents(c, role, req.ParentIDs) {
+		return
+	}
+
 	if err := h.roleRepo.Create(c.Request.Context(), role); err != nil {
 		log.Printf("Failed to create role: %v", err)
 		if appErr, ok := err.(*errors.AppError); ok {
@@ -112,21 +100,27 @@ func (h *RoleHandler) Create(c *gin.Context) {
 		}
 	}
 
+	if len(req.ParentIDs) > 0 {
+		if err := h.roleRepo.SetParents(c.Request.Context(), role.ID, req.ParentIDs, createdBy); err != nil {
+			respondError(c, err, "Failed to set parent roles")
+			return
+		}
+		role.ParentIDs = req.ParentIDs
+	}
+
 	c.JSON(http.StatusCreated, role)
 }
 
 func (h *RoleHandler) List(c *gin.Context) {
-	orgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
-	// Check for org_id query parameter (for filtering)
-	orgFilterParam := c.Query("org_id")
-
-	var orgIDPtr *uuid.UUID
-
-	// If super admin and org_id query parameter is provided, use it for filtering
-	if isSuperAdmin != nil && isSuperAdmin.(bool) && orgFilterParam != "" {
-		if orgFilterParam != "all" {
+	subject := policy.FromContext(c)
+
+	// Super admins may filter with ?org_id=<uuid>, or pass "all" to include every org and system roles.
+	// Without a filter they see their own org context (or everything when they have none).
+	requestedOrgID := subject.OrgID
+	if orgFilterParam := c.Query("org_id"); orgFilterParam != "" {
+		if orgFilterParam == "all" {
+			requestedOrgID = nil
+		} else {
 			uid, err := uuid.Parse(orgFilterParam)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, errors.ErrorResponse{
@@ -135,15 +129,14 @@ func (h *RoleHandler) List(c *gin.Context) {
 				})
 				return
 			}
-			orgIDPtr = &uid
+			requestedOrgID = &uid
 		}
-		// If orgFilterParam is "all" or empty, orgIDPtr remains nil (show all roles)
-	} else if orgID != nil && orgID != "" {
-		// Non-super admin or super admin without filter - use their org context
-		uid, _ := uuid.Parse(orgID.(string))
-		orgIDPtr = &uid
-	} else if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		orgIDPtr = nil // Only super admins can see system roles
+	}
+
+	orgIDPtr, err := subject.ListScope(requestedOrgID)
+	if err != nil {
+		respondError(c, err, "Failed to list roles")
+		return
 	}
 
 	roles, err := h.roleRepo.List(c.Request.Context(), orgIDPtr)
@@ -158,6 +151,71 @@ func (h *RoleHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, roles)
 }
 
+// ListExpirations lists role assignments of the org's users expiring within ?days= (default 30, at
+// most 365), soonest first. Super admins may pass ?org_id=, or "all" for every org.
+// GET /api/v1/roles/expirations
+func (h *RoleHandler) ListExpirations(c *gin.Context) {
+	subject := policy.FromContext(c)
+
+	requestedOrgID := subject.OrgID
+	if orgFilterParam := c.Query("org_id"); orgFilterParam != "" {
+		if orgFilterParam == "all" {
+			requestedOrgID = nil
+		} else {
+			uid, err := uuid.Parse(orgFilterParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+					Error:   errors.ErrValidation.Code,
+					Message: "Invalid org_id parameter",
+				})
+				return
+			}
+			requestedOrgID = &uid
+		}
+	}
+	orgIDPtr, err := subject.ListScope(requestedOrgID)
+	if err != nil {
+		respondError(c, err, "Failed to list role expirations")
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "days must be between 1 and 365",
+		})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	assignments, total, err := h.roleRepo.ListExpiringAssignments(c.Request.Context(), orgIDPtr, time.Now().AddDate(0, 0, days), page, limit)
+	if err != nil {
+		respondError(c, err, "Failed to list role expirations")
+		return
+	}
+
+	totalPages := (total + limit - 1) / limit
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        assignments,
+		"page":        page,
+		"limit":       limit,
+		"total":       total,
+		"total_pages": totalPages,
+	})
+}
+
 func (h *RoleHandler) GetByID(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -223,24 +281,9 @@ func (h *RoleHandler) Update(c *gin.Context) {
 	}
 
 	// Check if role is in same org (unless super admin)
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userOrgID, _ := c.Get("org_id")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if role.OrgID == nil || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update roles outside your organization",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(userOrgID.(string))
-		if role.OrgID.String() != uid.String() {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update roles outside your organization",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(role.OrgID); err != nil {
+		respondForbidden(c, "Cannot update roles outside your organization")
+		return
 	}
 
 	// Update fields
@@ -285,24 +328,9 @@ func (h *RoleHandler) Delete(c *gin.Context) {
 	// Check if role is in same org (unless super admin)
 	role, err := h.roleRepo.GetByID(c.Request.Context(), id)
 	if err == nil {
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		userOrgID, _ := c.Get("org_id")
-		if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-			if role.OrgID == nil || userOrgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot delete roles outside your organization",
-				})
-				return
-			}
-			uid, _ := uuid.Parse(userOrgID.(string))
-			if role.OrgID.String() != uid.String() {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot delete roles outside your organization",
-				})
-				return
-			}
+		if err := policy.FromContext(c).CanAccess(role.OrgID); err != nil {
+			respondForbidden(c, "Cannot delete roles outside your organization")
+			return
 		}
 	}
 
@@ -334,7 +362,12 @@ func (h *RoleHandler) GetPermissions(c *gin.Context) {
 		return
 	}
 
-	permissions, err := h.roleRepo.GetPermissions(c.Request.Context(), id)
+	// ?inherited=true adds the permissions the role inherits from its parents
+	getPermissions := h.roleRepo.GetPermissions
+	if c.Query("inherited") == "true" {
+		getPermissions = h.roleRepo.GetEffectivePermissions
+	}
+	permissions, err := getPermissions(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 			Error:   errors.ErrInternalServer.Code,
@@ -375,29 +408,18 @@ func (h *RoleHandler) AssignPermissions(c *gin.Context) {
 	// Check if role is in same org (unless super admin)
 	role, err := h.roleRepo.GetByID(c.Request.Context(), id)
 	if err == nil {
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		userOrgID, _ := c.Get("org_id")
-		if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-			if role.OrgID == nil || userOrgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot assign permissions to roles outside your organization",
-				})
-				return
-			}
-			uid, _ := uuid.Parse(userOrgID.(string))
-			if role.OrgID.String() != uid.String() {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot assign permissions to roles outside your organization",
-				})
-				return
-			}
+		if err := policy.FromContext(c).CanAccess(role.OrgID); err != nil {
+			respondForbidden(c, "Cannot assign permissions to roles outside your organization")
+			return
 		}
 	}
 
-	userID, _ := c.Get("user_id")
-	grantedBy, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	grantedBy := caller.ID
 
 	if err := h.roleRepo.AssignPermissions(c.Request.Context(), id, req.PermissionIDs, grantedBy); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
@@ -416,3 +438,77 @@ func (h *RoleHandler) AssignPermissions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Permissions assigned successfully"})
 }
+
+// SetParents replaces the roles a role inherits permissions from
+// PUT /api/v1/roles/:id/parents
+func (h *RoleHandler) SetParents(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid role ID",
+		})
+		return
+	}
+
+	var req models.SetRoleParentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	role, err := h.roleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get role")
+		return
+	}
+	if err := policy.FromContext(c).CanAccess(role.OrgID); err != nil {
+		respondForbidden(c, "Cannot change roles outside your organization")
+		return
+	}
+	if !h.checkParents(c, role, req.ParentIDs) {
+		return
+	}
+
+	if err := h.roleRepo.SetParents(c.Request.Context(), id, req.ParentIDs, policy.FromContext(c).UserID); err != nil {
+		respondError(c, err, "Failed to set parent roles")
+		return
+	}
+
+	role, err = h.roleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get role")
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+// checkParents makes sure a role may inherit from each of parentIDs: system roles inherit only from
+// system roles, org roles from system roles and roles of their own org
+func (h *RoleHandler) checkParents(c *gin.Context, role *models.Role, parentIDs []uuid.UUID) bool {
+	for _, parentID := range parentIDs {
+		parent, err := h.roleRepo.GetByID(c.Request.Context(), parentID)
+		if err == errors.ErrNotFound {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Parent role %s not found", parentID),
+			})
+			return false
+		}
+		if err != nil {
+			respondError(c, err, "Failed to get parent role")
+			return false
+		}
+		if parent.OrgID != nil && (role.OrgID == nil || *parent.OrgID != *role.OrgID) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Role %q belongs to another organization and cannot be inherited", parent.Name),
+			})
+			return false
+		}
+	}
+	return true
+}
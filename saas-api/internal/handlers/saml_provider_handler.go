@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SAMLProviderHandler serves the organization's SAML single sign-on settings, with the service
+// provider entity ID and ACS URL to register at the IdP
+type SAMLProviderHandler struct {
+	samlService *services.SAMLService
+}
+
+func NewSAMLProviderHandler(samlService *services.SAMLService) *SAMLProviderHandler {
+	return &SAMLProviderHandler{samlService: samlService}
+}
+
+// Get returns the organization's identity provider
+// GET /api/v1/organizations/:id/saml
+func (h *SAMLProviderHandler) Get(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	provider, err := h.samlService.GetProvider(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, err, "Failed to get SAML provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": provider})
+}
+
+// Set creates or replaces the organization's identity provider from its metadata
+// PUT /api/v1/organizations/:id/saml
+func (h *SAMLProviderHandler) Set(c *gin.Context) {
+	var req models.SetOrgSAMLProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	provider, err := h.samlService.SetProvider(c.Request.Context(), orgID, req, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to save SAML provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": provider})
+}
+
+// Delete turns SAML single sign-on off for the organization
+// DELETE /api/v1/organizations/:id/saml
+func (h *SAMLProviderHandler) Delete(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject := policy.FromContext(c)
+	if err := h.samlService.DeleteProvider(c.Request.Context(), orgID, &subject.UserID); err != nil {
+		respondError(c, err, "Failed to delete SAML provider")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SAML provider deleted successfully"})
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// scimTypes maps service error codes to SCIM error types (RFC 7644 section 3.12)
+var scimTypes = map[string]string{
+	"CONFLICT":         "uniqueness",
+	"INVALID_FILTER":   "invalidFilter",
+	"INVALID_PATH":     "invalidPath",
+	"VALIDATION_ERROR": "invalidValue",
+	"MUTABILITY":       "mutability",
+}
+
+// SCIMHandler serves SCIM 2.0 provisioning for the org of the calling API key. Responses and
+// errors use SCIM's own format (application/scim+json), not the API's.
+type SCIMHandler struct {
+	scimService *services.SCIMService
+}
+
+func NewSCIMHandler(scimService *services.SCIMService) *SCIMHandler {
+	return &SCIMHandler{scimService: scimService}
+}
+
+// ServiceProviderConfig describes what this SCIM server supports
+// GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{models.SCIMSchemaServiceProviderConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": 200},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "API key",
+			"description": "An API key of the organization, sent as Authorization: Bearer <key>",
+			"primary":     true,
+		}},
+	})
+}
+
+// ListUsers returns the org's users, optionally filtered (userName eq "...")
+// GET /scim/v2/Users?filter=&startIndex=&count=
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	startIndex, count := scimPageQuery(c)
+
+	list, err := h.scimService.ListUsers(c.Request.Context(), orgID, c.Query("filter"), startIndex, count)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, list)
+}
+
+// GET /scim/v2/Users/:id
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMUserNotFound)
+	if !ok {
+		return
+	}
+
+	user, err := h.scimService.GetUser(c.Request.Context(), orgID, id)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, user)
+}
+
+// CreateUser provisions a user into the org
+// POST /scim/v2/Users
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	user, err := h.scimService.CreateUser(c.Request.Context(), orgID, policy.FromContext(c).UserID, &req)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusCreated, user)
+}
+
+// ReplaceUser replaces a user's attributes
+// PUT /scim/v2/Users/:id
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMUserNotFound)
+	if !ok {
+		return
+	}
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(c.Request.Context(), orgID, policy.FromContext(c).UserID, id, &req)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, user)
+}
+
+// PatchUser updates a user; {"op": "replace", "path": "active", "value": false} deactivates them
+// PATCH /scim/v2/Users/:id
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMUserNotFound)
+	if !ok {
+		return
+	}
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	user, err := h.scimService.PatchUser(c.Request.Context(), orgID, policy.FromContext(c).UserID, id, req.Operations)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, user)
+}
+
+// DELETE /scim/v2/Users/:id
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMUserNotFound)
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteUser(c.Request.Context(), orgID, policy.FromContext(c).UserID, id); err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups returns the org's roles as groups, optionally filtered (displayName eq "...")
+// GET /scim/v2/Groups?filter=&startIndex=&count=&excludedAttributes=members
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	startIndex, count := scimPageQuery(c)
+
+	list, err := h.scimService.ListGroups(c.Request.Context(), orgID, c.Query("filter"), startIndex, count, scimWithMembers(c))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, list)
+}
+
+// GET /scim/v2/Groups/:id?excludedAttributes=members
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMGroupNotFound)
+	if !ok {
+		return
+	}
+
+	group, err := h.scimService.GetGroup(c.Request.Context(), orgID, id, scimWithMembers(c))
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, group)
+}
+
+// CreateGroup creates an org role with the given members
+// POST /scim/v2/Groups
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	var req models.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(c.Request.Context(), orgID, policy.FromContext(c).UserID, &req)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusCreated, group)
+}
+
+// ReplaceGroup replaces a group's name and members
+// PUT /scim/v2/Groups/:id
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMGroupNotFound)
+	if !ok {
+		return
+	}
+	var req models.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(c.Request.Context(), orgID, policy.FromContext(c).UserID, id, &req)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, group)
+}
+
+// PatchGroup renames a group or adds and removes members
+// PATCH /scim/v2/Groups/:id
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMGroupNotFound)
+	if !ok {
+		return
+	}
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondSCIMError(c, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	group, err := h.scimService.PatchGroup(c.Request.Context(), orgID, policy.FromContext(c).UserID, id, req.Operations)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	scimJSON(c, http.StatusOK, group)
+}
+
+// DELETE /scim/v2/Groups/:id
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	orgID, ok := scimOrg(c)
+	if !ok {
+		return
+	}
+	id, ok := scimIDParam(c, services.ErrSCIMGroupNotFound)
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteGroup(c.Request.Context(), orgID, policy.FromContext(c).UserID, id); err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// scimOrg is the org of the calling API key
+func scimOrg(c *gin.Context) (uuid.UUID, bool) {
+	orgID, err := policy.FromContext(c).TargetOrg(nil)
+	if err != nil {
+		respondSCIMError(c, err)
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+// scimIDParam parses :id; an ID that is not a UUID answers notFound, like an unknown one
+func scimIDParam(c *gin.Context, notFound error) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondSCIMError(c, notFound)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func scimPageQuery(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil {
+		count = services.SCIMDefaultCount
+	}
+	return startIndex, count
+}
+
+func scimWithMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			return false
+		}
+	}
+	return true
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+// respondSCIMError writes err as a SCIM error; errors that are not AppErrors are a 500
+func respondSCIMError(c *gin.Context, err error) {
+	status, detail, scimType := http.StatusInternalServerError, "Internal server error", ""
+	if appErr, ok := err.(*errors.AppError); ok {
+		status, detail, scimType = appErr.Status, appErr.Message, scimTypes[appErr.Code]
+	}
+	scimJSON(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
@@ -39,24 +40,12 @@ func (h *ScreenerHandler) SaveScreener(c *gin.Context) {
 		return
 	}
 
-	// Get user_id from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User not authenticated")
 		return
 	}
+	uid := caller.ID
 
 	// Get org_id from user
 	user, err := h.userRepo.GetByID(c.Request.Context(), uid)
@@ -163,24 +152,12 @@ func (h *ScreenerHandler) SaveScreener(c *gin.Context) {
 // GetSavedScreeners gets all saved screeners for the authenticated user
 // GET /api/v1/screeners/saved
 func (h *ScreenerHandler) GetSavedScreeners(c *gin.Context) {
-	// Get user_id from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User not authenticated")
 		return
 	}
+	uid := caller.ID
 
 	screeners, err := h.screenerRepo.ListByUser(c.Request.Context(), uid)
 	if err != nil {
@@ -217,24 +194,12 @@ func (h *ScreenerHandler) DeleteScreener(c *gin.Context) {
 		return
 	}
 
-	// Get user_id from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User not authenticated")
 		return
 	}
+	uid := caller.ID
 
 	if err := h.screenerRepo.Delete(c.Request.Context(), id, uid); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
@@ -270,24 +235,12 @@ func (h *ScreenerHandler) RunSavedScreener(c *gin.Context) {
 		return
 	}
 
-	// Get user_id from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
-			Error:   errors.ErrUnauthorized.Code,
-			Message: "User not authenticated",
-		})
-		return
-	}
-
-	uid, err := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-			Error:   errors.ErrValidation.Code,
-			Message: "Invalid user ID",
-		})
+		respondError(c, err, "User not authenticated")
 		return
 	}
+	uid := caller.ID
 
 	// Fetch screener from database
 	screener, err := h.screenerRepo.GetByID(c.Request.Context(), id)
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxFeedbackQueryLength = 4000
+
+type SearchFeedbackHandler struct {
+	feedbackRepo *repositories.SearchFeedbackRepository
+	docRepo      *repositories.DocumentRepository
+}
+
+func NewSearchFeedbackHandler(feedbackRepo *repositories.SearchFeedbackRepository, docRepo *repositories.DocumentRepository) *SearchFeedbackHandler {
+	return &SearchFeedbackHandler{
+		feedbackRepo: feedbackRepo,
+		docRepo:      docRepo,
+	}
+}
+
+// Create records whether a returned chunk was helpful for a query
+// POST /api/v1/search/feedback
+func (h *SearchFeedbackHandler) Create(c *gin.Context) {
+	var req models.CreateSearchFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" || len(req.Query) > maxFeedbackQueryLength {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "query must be between 1 and 4000 characters",
+		})
+		return
+	}
+
+	subject := policy.FromContext(c)
+	if subject.UserID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error:   errors.ErrUnauthorized.Code,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	// Feedback is stored under the document's org, so it can only be given on documents the caller can see
+	doc, ok := h.accessibleDocument(c, subject, req.DocumentID)
+	if !ok {
+		return
+	}
+
+	feedback := &models.SearchFeedback{
+		OrgID:      doc.OrgID,
+		UserID:     subject.UserID,
+		DocumentID: doc.ID,
+		ChunkID:    req.ChunkID,
+		Query:      req.Query,
+		QueryID:    req.QueryID,
+		Helpful:    *req.Helpful,
+		Comment:    req.Comment,
+	}
+	if err := h.feedbackRepo.Upsert(c.Request.Context(), feedback); err != nil {
+		respondError(c, err, "Failed to save search feedback")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    feedback,
+		"message": "Feedback recorded",
+	})
+}
+
+// Stats returns aggregated feedback per document in the caller's org
+// GET /api/v1/search/feedback/stats?org_id=&limit=
+func (h *SearchFeedbackHandler) Stats(c *gin.Context) {
+	var requested *uuid.UUID
+	if orgIDStr := c.Query("org_id"); orgIDStr != "" {
+		orgID, err := uuid.Parse(orgIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: "Invalid organization ID",
+			})
+			return
+		}
+		requested = &orgID
+	}
+
+	orgID, err := policy.FromContext(c).ListScope(requested)
+	if err != nil {
+		respondError(c, err, "Access denied")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	stats, err := h.feedbackRepo.DocumentStats(c.Request.Context(), orgID, limit)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve search feedback")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stats,
+	})
+}
+
+// DocumentStats returns aggregated feedback for one document, broken down per chunk
+// GET /api/v1/search/feedback/documents/:document_id
+func (h *SearchFeedbackHandler) DocumentStats(c *gin.Context) {
+	documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid document ID",
+		})
+		return
+	}
+
+	doc, ok := h.accessibleDocument(c, policy.FromContext(c), documentID)
+	if !ok {
+		return
+	}
+
+	chunks, err := h.feedbackRepo.ChunkStats(c.Request.Context(), documentID)
+	if err != nil {
+		respondError(c, err, "Failed to retrieve search feedback")
+		return
+	}
+
+	stats := &models.SearchFeedbackDocumentStats{
+		DocumentID:   doc.ID,
+		DocumentName: doc.Name,
+		Chunks:       chunks,
+	}
+	for _, chunk := range chunks {
+		stats.HelpfulCount += chunk.HelpfulCount
+		stats.UnhelpfulCount += chunk.UnhelpfulCount
+	}
+	stats.Score = models.SearchFeedbackScore(stats.HelpfulCount, stats.UnhelpfulCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stats,
+	})
+}
+
+// accessibleDocument loads a document and checks the caller may see it, writing the error response if not
+func (h *SearchFeedbackHandler) accessibleDocument(c *gin.Context, subject policy.Subject, documentID int64) (*repositories.Document, bool) {
+	doc, err := h.docRepo.GetByID(c.Request.Context(), documentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, errors.ErrorResponse{
+			Error:   errors.ErrNotFound.Code,
+			Message: "Document not found",
+		})
+		return nil, false
+	}
+	if err := subject.CanAccess(doc.OrgID); err != nil {
+		respondError(c, err, "Access denied")
+		return nil, false
+	}
+	return doc, true
+}
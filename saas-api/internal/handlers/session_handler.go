@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/policy"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionHandler serves sessions (refresh tokens): the signed-in user's own under /auth/sessions,
+// and any user's in the caller's org under /users/:id/sessions for admins
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+// List returns the current user's active sessions
+// GET /api/v1/auth/sessions
+func (h *SessionHandler) List(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.list(c, user.ID)
+}
+
+// Revoke ends one of the current user's sessions
+// DELETE /api/v1/auth/sessions/:id
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.revoke(c, user.ID, user.ID, "id")
+}
+
+// RevokeAll ends every session of the current user, this one included
+// DELETE /api/v1/auth/sessions
+func (h *SessionHandler) RevokeAll(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	h.revokeAll(c, user.ID, user.ID)
+}
+
+// LogoutAll signs the current user out of every device, LibreChat included
+// POST /api/v1/auth/logout-all
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	response, err := h.sessionService.LogoutAll(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to log out")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    response,
+		"message": "Logged out of all devices",
+	})
+}
+
+// ListForUser returns a user's active sessions
+// GET /api/v1/users/:id/sessions
+func (h *SessionHandler) ListForUser(c *gin.Context) {
+	userID, ok := h.sessionUserParam(c)
+	if !ok {
+		return
+	}
+	h.list(c, userID)
+}
+
+// RevokeForUser ends one of a user's sessions
+// DELETE /api/v1/users/:id/sessions/:session_id
+func (h *SessionHandler) RevokeForUser(c *gin.Context) {
+	userID, ok := h.sessionUserParam(c)
+	if !ok {
+		return
+	}
+	h.revoke(c, userID, policy.FromContext(c).UserID, "session_id")
+}
+
+// RevokeAllForUser ends every session of a user
+// DELETE /api/v1/users/:id/sessions
+func (h *SessionHandler) RevokeAllForUser(c *gin.Context) {
+	userID, ok := h.sessionUserParam(c)
+	if !ok {
+		return
+	}
+	h.revokeAll(c, userID, policy.FromContext(c).UserID)
+}
+
+func (h *SessionHandler) list(c *gin.Context, userID uuid.UUID) {
+	sessions, err := h.sessionService.List(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to list sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+func (h *SessionHandler) revoke(c *gin.Context, userID, actorID uuid.UUID, sessionParam string) {
+	sessionID, err := uuid.Parse(c.Param(sessionParam))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid session ID",
+		})
+		return
+	}
+
+	if err := h.sessionService.Revoke(c.Request.Context(), userID, sessionID, actorID); err != nil {
+		respondError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func (h *SessionHandler) revokeAll(c *gin.Context, userID, actorID uuid.UUID) {
+	revoked, err := h.sessionService.RevokeAll(c.Request.Context(), userID, actorID)
+	if err != nil {
+		respondError(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    gin.H{"revoked": revoked},
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// sessionUserParam resolves :id to a user whose sessions the caller may manage (see canManageUser)
+func (h *SessionHandler) sessionUserParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return uuid.Nil, false
+	}
+
+	user, err := h.sessionService.User(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return uuid.Nil, false
+	}
+	if !canManageUser(c, user, "Cannot manage sessions of users outside your organization") {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
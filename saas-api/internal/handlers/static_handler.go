@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +20,7 @@ import (
 type StaticHandler struct {
 	storagePath  string
 	documentRepo *repositories.DocumentRepository
+	storage      storage.Storage // Optional: remote storage to serve from instead of storagePath
 }
 
 func NewStaticHandler(storagePath string, documentRepo *repositories.DocumentRepository) *StaticHandler {
@@ -26,6 +30,15 @@ func NewStaticHandler(storagePath string, documentRepo *repositories.DocumentRep
 	}
 }
 
+// SetStorage serves files from the configured storage backend. Local storage keeps serving from
+// storagePath on disk, with its fallbacks for legacy paths.
+func (h *StaticHandler) SetStorage(store storage.Storage) {
+	if _, local := store.(*storage.Local); local {
+		return
+	}
+	h.storage = store
+}
+
 // ServeFile serves files directly from the storage path
 // Route: /static/resources/folder/file/*
 // Example: /static/resources/folder/file/org_id/folder_path/file_name.pdf
@@ -56,10 +69,10 @@ func (h *StaticHandler) ServeFile(c *gin.Context) {
 		return
 	}
 
-	// Get user's org_id and super admin status for access validation
-	userOrgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	isSuperAdminBool := isSuperAdmin != nil && isSuperAdmin.(bool)
+	if h.storage != nil {
+		h.serveFromStorage(c, requestPath)
+		return
+	}
 
 	// Construct file path on disk
 	// Path format: {storagePath}/{requestPath}
@@ -234,46 +247,15 @@ func (h *StaticHandler) ServeFile(c *gin.Context) {
 		}
 	}
 
-	// Validate access - Super admins can access any file regardless of org_id
-	if !isSuperAdminBool {
-		if pathOrgUUID != nil {
-			// Path contains org_id - validate user has access to this org
-			if userOrgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "You do not have access to this file",
-				})
-				return
-			}
-
-			userOrgIDStr, ok := userOrgID.(string)
-			if !ok {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "You do not have access to this file",
-				})
-				return
-			}
-
-			userOrgUUID, err := uuid.Parse(userOrgIDStr)
-			if err != nil || userOrgUUID != *pathOrgUUID {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "You do not have access to this file. Files can only be accessed by users from the same organization.",
-				})
-				return
-			}
-		} else {
-			// Path doesn't contain org_id (legacy format like "LLAMA/Whatsapp/login2.png")
-			// Only allow super admin access
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "You do not have access to this file",
-			})
-			return
-		}
+	// Validate access - Super admins can access any file regardless of org_id.
+	// Paths without an org_id (legacy format like "LLAMA/Whatsapp/login2.png") are super admin only.
+	if err := policy.FromContext(c).CanAccess(pathOrgUUID); err != nil {
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error:   errors.ErrForbidden.Code,
+			Message: "You do not have access to this file. Files can only be accessed by users from the same organization.",
+		})
+		return
 	}
-	// Super admin can access any file - no validation needed
 
 	// Access validated, determine MIME type and serve the file
 	ext := filepath.Ext(fullPath)
@@ -287,3 +269,27 @@ func (h *StaticHandler) ServeFile(c *gin.Context) {
 	// Serve the file
 	c.File(fullPath)
 }
+
+// serveFromStorage serves requestPath, a storage key, from remote storage. As on disk, access is
+// decided by the org_id in the key; keys without one are super admin only.
+func (h *StaticHandler) serveFromStorage(c *gin.Context, requestPath string) {
+	key := filepath.ToSlash(requestPath)
+	key = strings.TrimPrefix(key, filepath.ToSlash(h.storagePath)+"/")
+
+	var pathOrgUUID *uuid.UUID
+	for _, segment := range strings.Split(key, "/") {
+		if parsedUUID, err := uuid.Parse(segment); err == nil {
+			pathOrgUUID = &parsedUUID
+			break
+		}
+	}
+	if err := policy.FromContext(c).CanAccess(pathOrgUUID); err != nil {
+		c.JSON(http.StatusForbidden, errors.ErrorResponse{
+			Error:   errors.ErrForbidden.Code,
+			Message: "You do not have access to this file. Files can only be accessed by users from the same organization.",
+		})
+		return
+	}
+
+	serveStoredFile(c, h.storage, key, path.Base(key), "inline", 0)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StepUpHandler re-authenticates the signed-in user for operations behind StepUpMiddleware
+type StepUpHandler struct {
+	stepUpService *services.StepUpService
+	authMW        *middleware.AuthMiddleware
+}
+
+func NewStepUpHandler(stepUpService *services.StepUpService, authMW *middleware.AuthMiddleware) *StepUpHandler {
+	return &StepUpHandler{stepUpService: stepUpService, authMW: authMW}
+}
+
+// SendOTP sends the signed-in user a one-time code to step up with
+// POST /api/v1/auth/step-up/send-otp
+func (h *StepUpHandler) SendOTP(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	response, err := h.stepUpService.SendOTP(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to send OTP")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StepUp checks a password, OTP or 2FA code and returns an access token with a fresh auth_time
+// POST /api/v1/auth/step-up
+func (h *StepUpHandler) StepUp(c *gin.Context) {
+	var req models.StepUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	response, err := h.stepUpService.StepUp(c.Request.Context(), user.ID, req, h.authMW.GetClientIP(c), c.Request.UserAgent())
+	if err != nil {
+		respondError(c, err, "Failed to re-authenticate")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/repositories"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SubscriptionHandler struct {
+	orgRepo             *repositories.OrganizationRepository
+	subscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionHandler(orgRepo *repositories.OrganizationRepository, subscriptionService *services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		orgRepo:             orgRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// Upgrade moves the organization to a higher plan (or converts a trial to paid)
+// POST /api/v1/organizations/:id/subscription/upgrade
+func (h *SubscriptionHandler) Upgrade(c *gin.Context) {
+	h.changePlan(c, h.subscriptionService.Upgrade)
+}
+
+// Downgrade moves the organization to a lower plan if current usage fits the target plan
+// POST /api/v1/organizations/:id/subscription/downgrade
+func (h *SubscriptionHandler) Downgrade(c *gin.Context) {
+	h.changePlan(c, h.subscriptionService.Downgrade)
+}
+
+// Cancel cancels the organization's subscription
+// POST /api/v1/organizations/:id/subscription/cancel
+func (h *SubscriptionHandler) Cancel(c *gin.Context) {
+	var req models.CancelSubscriptionRequest
+	// The body is optional: an empty request cancels at the end of the current period
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	org, subject, ok := h.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	event, err := h.subscriptionService.Cancel(c.Request.Context(), org, req, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to cancel subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  org,
+		"event": event,
+	})
+}
+
+func (h *SubscriptionHandler) changePlan(c *gin.Context, change func(ctx context.Context, org *models.Organization, plan string, actorID *uuid.UUID) (*models.BillingEvent, error)) {
+	var req models.ChangeSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	org, subject, ok := h.loadOrganization(c)
+	if !ok {
+		return
+	}
+
+	event, err := change(c.Request.Context(), org, req.Plan, &subject.UserID)
+	if err != nil {
+		respondError(c, err, "Failed to change subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  org,
+		"event": event,
+	})
+}
+
+// loadOrganization resolves :id and checks the caller belongs to it (super admins may manage any org)
+func (h *SubscriptionHandler) loadOrganization(c *gin.Context) (*models.Organization, policy.Subject, bool) {
+	subject := policy.FromContext(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid organization ID",
+		})
+		return nil, subject, false
+	}
+
+	if err := subject.CanAccess(&id); err != nil {
+		respondError(c, err, "Access denied")
+		return nil, subject, false
+	}
+
+	org, err := h.orgRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get organization")
+		return nil, subject, false
+	}
+
+	return org, subject, true
+}
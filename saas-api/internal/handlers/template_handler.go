@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
 
@@ -33,23 +35,14 @@ func (h *TemplateHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Get org_id from context
-	orgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userID, _ := c.Get("user_id")
-	uid, _ := uuid.Parse(userID.(string))
-
-	var orgIDPtr *uuid.UUID
-	if orgID != nil && orgID != "" {
-		orgUUID, _ := uuid.Parse(orgID.(string))
-		orgIDPtr = &orgUUID
-	} else if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		c.JSON(http.StatusForbidden, errors.ErrorResponse{
-			Error:   errors.ErrForbidden.Code,
-			Message: "Organization context required",
-		})
+	// Super admins without an org create global entries (org_id = NULL)
+	subject := policy.FromContext(c)
+	orgIDPtr, err := subject.OwnerOrg(nil)
+	if err != nil {
+		respondError(c, err, "Failed to resolve organization")
 		return
 	}
+	uid := subject.UserID
 
 	isCustom := false
 	if req.IsCustom != nil {
@@ -88,7 +81,7 @@ func (h *TemplateHandler) Create(c *gin.Context) {
 	}
 
 	// Fetch with joined data
-	template, err := h.templateRepo.GetByID(c.Request.Context(), template.ID)
+	template, err = h.templateRepo.GetByID(c.Request.Context(), template.ID)
 	if err != nil {
 		c.JSON(http.StatusOK, template) // Return without joined data if fetch fails
 		return
@@ -101,16 +94,12 @@ func (h *TemplateHandler) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	var orgID *uuid.UUID
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
 	// Super admin sees all templates (orgID = nil)
 	// Org admin sees only their org's templates
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if orgIDStr, exists := c.Get("org_id"); exists && orgIDStr != nil && orgIDStr != "" {
-			uid, _ := uuid.Parse(orgIDStr.(string))
-			orgID = &uid
-		}
+	orgID, err := policy.FromContext(c).ListScope(nil)
+	if err != nil {
+		respondError(c, err, "Failed to list templates")
+		return
 	}
 
 	templates, total, err := h.templateRepo.List(c.Request.Context(), orgID, page, limit)
@@ -202,24 +191,9 @@ func (h *TemplateHandler) Update(c *gin.Context) {
 	}
 
 	// Check if user is in same org (unless super admin)
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userOrgID, _ := c.Get("org_id")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if template.OrgID == nil || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update templates outside your organization",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(userOrgID.(string))
-		if template.OrgID.String() != uid.String() {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update templates outside your organization",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(template.OrgID); err != nil {
+		respondForbidden(c, "Cannot update templates outside your organization")
+		return
 	}
 
 	// Update fields
@@ -239,8 +213,12 @@ func (h *TemplateHandler) Update(c *gin.Context) {
 		template.Content = req.Content
 	}
 
-	userID, _ := c.Get("user_id")
-	updatedBy, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	updatedBy := caller.ID
 	template.UpdatedBy = &updatedBy
 
 	if err := h.templateRepo.Update(c.Request.Context(), template); err != nil {
@@ -296,28 +274,17 @@ func (h *TemplateHandler) Delete(c *gin.Context) {
 	}
 
 	// Check if user is in same org (unless super admin)
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userOrgID, _ := c.Get("org_id")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if template.OrgID == nil || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot delete templates outside your organization",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(userOrgID.(string))
-		if template.OrgID.String() != uid.String() {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot delete templates outside your organization",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(template.OrgID); err != nil {
+		respondForbidden(c, "Cannot delete templates outside your organization")
+		return
 	}
 
-	userID, _ := c.Get("user_id")
-	deletedBy, _ := uuid.Parse(userID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	deletedBy := caller.ID
 
 	if err := h.templateRepo.Delete(c.Request.Context(), id, deletedBy); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
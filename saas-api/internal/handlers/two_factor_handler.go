@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/internal/middleware"
+	"saas-api/internal/models"
+	"saas-api/internal/services"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler serves TOTP two-factor authentication: the signed-in user's own settings, and
+// the second step of sign-ins that returned an MFA token
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	authMW           *middleware.AuthMiddleware
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, authMW *middleware.AuthMiddleware) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		authMW:           authMW,
+	}
+}
+
+// Status returns whether the current user has 2FA, and whether their organization requires it
+// GET /api/v1/auth/2fa
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	status, err := h.twoFactorService.Status(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to get two-factor status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// Setup starts enrollment: it returns a new secret and its QR code for the authenticator app
+// POST /api/v1/auth/2fa/setup
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	setup, err := h.twoFactorService.Setup(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, err, "Failed to start two-factor setup")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": setup})
+}
+
+// Confirm turns 2FA on with a code from the authenticator app and returns the backup codes
+// POST /api/v1/auth/2fa/confirm
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(c.Request.Context(), user.ID, req.Code)
+	if err != nil {
+		respondError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": models.BackupCodesResponse{BackupCodes: codes}})
+}
+
+// RegenerateBackupCodes replaces the backup codes; it takes a current code
+// POST /api/v1/auth/2fa/backup-codes
+func (h *TwoFactorHandler) RegenerateBackupCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateBackupCodes(c.Request.Context(), user.ID, req.Code)
+	if err != nil {
+		respondError(c, err, "Failed to regenerate backup codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": models.BackupCodesResponse{BackupCodes: codes}})
+}
+
+// Disable turns 2FA off; it takes a current code
+// DELETE /api/v1/auth/2fa
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+	user, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.Request.Context(), user.ID, req.Code); err != nil {
+		respondError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled successfully"})
+}
+
+// Verify completes a sign-in that returned mfa_required
+// POST /api/v1/auth/2fa/verify
+func (h *TwoFactorHandler) Verify(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	ipAddress := h.authMW.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	response, err := h.twoFactorService.Verify(c.Request.Context(), req.MFAToken, req.Code, ipAddress, userAgent)
+	if err != nil {
+		respondError(c, err, "Two-factor verification failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Enroll starts the enrollment of a sign-in that returned mfa_enrollment_required
+// POST /api/v1/auth/2fa/enroll
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	var req models.TwoFactorEnrollRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	setup, err := h.twoFactorService.Enroll(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		respondError(c, err, "Failed to start two-factor setup")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": setup})
+}
+
+// EnrollConfirm turns 2FA on and completes the sign-in; the response carries the tokens and the
+// backup codes
+// POST /api/v1/auth/2fa/enroll/confirm
+func (h *TwoFactorHandler) EnrollConfirm(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	ipAddress := h.authMW.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	response, err := h.twoFactorService.EnrollConfirm(c.Request.Context(), req.MFAToken, req.Code, ipAddress, userAgent)
+	if err != nil {
+		respondError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func bindTwoFactorRequest(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return false
+	}
+	return true
+}
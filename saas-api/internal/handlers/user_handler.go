@@ -7,8 +7,11 @@ import (
 	"strconv"
 	"time"
 
+	"saas-api/internal/authctx"
 	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
+	"saas-api/internal/services"
 	"saas-api/pkg/errors"
 	"saas-api/pkg/utils"
 
@@ -17,16 +20,18 @@ import (
 )
 
 type UserHandler struct {
-	userRepo *repositories.UserRepository
-	roleRepo *repositories.RoleRepository
-	orgRepo  *repositories.OrganizationRepository
+	userRepo  *repositories.UserRepository
+	roleRepo  *repositories.RoleRepository
+	orgRepo   *repositories.OrganizationRepository
+	libreSync *services.LibreChatSync
 }
 
-func NewUserHandler(userRepo *repositories.UserRepository, roleRepo *repositories.RoleRepository, orgRepo *repositories.OrganizationRepository) *UserHandler {
+func NewUserHandler(userRepo *repositories.UserRepository, roleRepo *repositories.RoleRepository, orgRepo *repositories.OrganizationRepository, libreSync *services.LibreChatSync) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepo,
-		roleRepo: roleRepo,
-		orgRepo:  orgRepo,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		orgRepo:   orgRepo,
+		libreSync: libreSync,
 	}
 }
 
@@ -40,42 +45,26 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Get org_id from context or request
-	orgID, _ := c.Get("org_id")
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
-	// If not super admin, must use their own org
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if orgID == nil || orgID == "" {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Organization context required",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(orgID.(string))
-		req.OrgID = &uid
-	} else {
-		// Super admin must specify org by id or name (they may not have their own org)
-		if req.OrgID == nil {
-			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
-				Error:   errors.ErrValidation.Code,
-				Message: "Organization must be specified. Provide 'org_id' in the request",
-			})
-			return
-		}
+	// Resolve the target org: org users are pinned to their own org, super admins must specify one
+	subject := policy.FromContext(c)
+	targetOrgID, err := subject.TargetOrg(req.OrgID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve organization")
+		return
+	}
+	if subject.IsSuperAdmin {
 		// Verify the organization exists
-		org, err := h.orgRepo.GetByID(c.Request.Context(), *req.OrgID)
+		org, err := h.orgRepo.GetByID(c.Request.Context(), targetOrgID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
 				Error:   errors.ErrValidation.Code,
-				Message: fmt.Sprintf("Organization with id '%s' not found", *req.OrgID),
+				Message: fmt.Sprintf("Organization with id '%s' not found", targetOrgID),
 			})
 			return
 		}
-		// Use the verified org ID
-		req.OrgID = &org.ID
+		targetOrgID = org.ID
 	}
+	req.OrgID = &targetOrgID
 
 	// Hash password
 	passwordHash, err := utils.HashPassword(req.Password)
@@ -118,6 +107,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 		Timezone:      "UTC",
 		Locale:        "en-US",
 		Metadata:      make(map[string]interface{}),
+		InvitedBy:     &subject.UserID,
 	}
 
 	if err := h.userRepo.Create(c.Request.Context(), user); err != nil {
@@ -138,8 +128,12 @@ func (h *UserHandler) Create(c *gin.Context) {
 
 	// Assign role if provided (by ID or name)
 	var roleToAssign *uuid.UUID
-	currentUserID, _ := c.Get("user_id")
-	assignedBy, _ := uuid.Parse(currentUserID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	assignedBy := caller.ID
 
 	if req.RoleID != nil {
 		// Role specified by UUID
@@ -149,9 +143,8 @@ func (h *UserHandler) Create(c *gin.Context) {
 		var orgIDForLookup *uuid.UUID
 		if user.OrgID != nil {
 			orgIDForLookup = user.OrgID
-		} else if orgID != nil && orgID != "" {
-			uid, _ := uuid.Parse(orgID.(string))
-			orgIDForLookup = &uid
+		} else if subject.OrgID != nil {
+			orgIDForLookup = subject.OrgID
 		}
 
 		role, err := h.roleRepo.GetByName(c.Request.Context(), *req.RoleName, orgIDForLookup)
@@ -178,43 +171,23 @@ func (h *UserHandler) Create(c *gin.Context) {
 			return
 		}
 
-		if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-			// Non-super admin: verify role belongs to same org
-			if role.OrgID == nil || orgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot assign system roles",
-				})
-				return
+		// Non-super admins may only assign roles that belong to their own org
+		if err := subject.CanAccess(role.OrgID); err != nil {
+			if role.OrgID == nil {
+				respondForbidden(c, "Cannot assign system roles")
 			} else {
-				uid, _ := uuid.Parse(orgID.(string))
-				if role.OrgID.String() == uid.String() {
-					if err := h.roleRepo.AssignRoleToUser(c.Request.Context(), user.ID, *roleToAssign, assignedBy, nil); err != nil {
-						log.Printf("Failed to assign role to user: %v", err)
-						c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-							Error:   errors.ErrInternalServer.Code,
-							Message: fmt.Sprintf("Failed to assign role: %v", err),
-						})
-						return
-					}
-				} else {
-					c.JSON(http.StatusForbidden, errors.ErrorResponse{
-						Error:   errors.ErrForbidden.Code,
-						Message: "Cannot assign roles from other organizations",
-					})
-					return
-				}
-			}
-		} else {
-			// Super admin can assign any role
-			if err := h.roleRepo.AssignRoleToUser(c.Request.Context(), user.ID, *roleToAssign, assignedBy, nil); err != nil {
-				log.Printf("Failed to assign role to user: %v", err)
-				c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-					Error:   errors.ErrInternalServer.Code,
-					Message: fmt.Sprintf("Failed to assign role: %v", err),
-				})
-				return
+				respondForbidden(c, "Cannot assign roles from other organizations")
 			}
+			return
+		}
+
+		if err := h.roleRepo.AssignRoleToUser(c.Request.Context(), user.ID, *roleToAssign, assignedBy, nil); err != nil {
+			log.Printf("Failed to assign role to user: %v", err)
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: fmt.Sprintf("Failed to assign role: %v", err),
+			})
+			return
 		}
 	} else if user.OrgID != nil {
 		// If no role specified, assign default role for the org
@@ -222,8 +195,6 @@ func (h *UserHandler) Create(c *gin.Context) {
 		if err == nil {
 			for _, role := range roles {
 				if role.IsDefault {
-					currentUserID, _ := c.Get("user_id")
-					assignedBy, _ := uuid.Parse(currentUserID.(string))
 					if err := h.roleRepo.AssignRoleToUser(c.Request.Context(), user.ID, role.ID, assignedBy, nil); err != nil {
 						log.Printf("Failed to assign default role to user: %v", err)
 						// Don't fail user creation if default role assignment fails, just log it
@@ -281,33 +252,24 @@ func (h *UserHandler) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	var orgID *uuid.UUID
-	isSuperAdmin, _ := c.Get("is_super_admin")
-
 	// Super admin can filter by org_id via query parameter, or see all users (orgID = nil)
 	// Org admin sees only their org's users
-	if isSuperAdmin != nil && isSuperAdmin.(bool) {
-		// Super admin: check for org_id query parameter for filtering
-		if orgIDParam := c.Query("org_id"); orgIDParam != "" {
-			uid, err := uuid.Parse(orgIDParam)
-			if err == nil {
-				orgID = &uid
-				log.Printf("Filtering users by org_id: %s", orgID.String())
-			} else {
-				log.Printf("Failed to parse org_id query parameter: %s, error: %v", orgIDParam, err)
-			}
+	var requestedOrgID *uuid.UUID
+	if orgIDParam := c.Query("org_id"); orgIDParam != "" {
+		uid, err := uuid.Parse(orgIDParam)
+		if err == nil {
+			requestedOrgID = &uid
 		} else {
-			log.Printf("No org_id query parameter provided, returning all users")
-		}
-		// If no org_id param, orgID remains nil to get all users
-	} else {
-		// Org admin: use their own org
-		if orgIDStr, exists := c.Get("org_id"); exists && orgIDStr != nil && orgIDStr != "" {
-			uid, _ := uuid.Parse(orgIDStr.(string))
-			orgID = &uid
+			log.Printf("Failed to parse org_id query parameter: %s, error: %v", orgIDParam, err)
 		}
 	}
 
+	orgID, err := policy.FromContext(c).ListScope(requestedOrgID)
+	if err != nil {
+		respondError(c, err, "Failed to list users")
+		return
+	}
+
 	log.Printf("Calling userRepo.List with orgID: %v, page: %d, limit: %d", orgID, page, limit)
 	users, total, err := h.userRepo.List(c.Request.Context(), orgID, page, limit)
 	if err != nil {
@@ -370,24 +332,9 @@ func (h *UserHandler) Update(c *gin.Context) {
 	}
 
 	// Check if user is in same org (unless super admin)
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userOrgID, _ := c.Get("org_id")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if user.OrgID == nil || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update users outside your organization",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(userOrgID.(string))
-		if user.OrgID.String() != uid.String() {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot update users outside your organization",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(user.OrgID); err != nil {
+		respondForbidden(c, "Cannot update users outside your organization")
+		return
 	}
 
 	// Update fields
@@ -465,6 +412,11 @@ func (h *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// Keep the LibreChat profile in step instead of waiting for the next proxy login
+	if req.FirstName != nil || req.LastName != nil || req.AvatarURL != nil {
+		h.libreSync.SyncProfileAsync(user)
+	}
+
 	user.PasswordHash = ""
 	c.JSON(http.StatusOK, user)
 }
@@ -482,24 +434,9 @@ func (h *UserHandler) Delete(c *gin.Context) {
 	// Check if user is in same org (unless super admin)
 	user, err := h.userRepo.GetByID(c.Request.Context(), id)
 	if err == nil {
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		userOrgID, _ := c.Get("org_id")
-		if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-			if user.OrgID == nil || userOrgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot delete users outside your organization",
-				})
-				return
-			}
-			uid, _ := uuid.Parse(userOrgID.(string))
-			if user.OrgID.String() != uid.String() {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot delete users outside your organization",
-				})
-				return
-			}
+		if err := policy.FromContext(c).CanAccess(user.OrgID); err != nil {
+			respondForbidden(c, "Cannot delete users outside your organization")
+			return
 		}
 	}
 
@@ -548,6 +485,110 @@ func (h *UserHandler) GetPermissions(c *gin.Context) {
 	c.JSON(http.StatusOK, permissions)
 }
 
+// CheckPermissions answers several permission checks for a user in one call, with the rules
+// RequirePermission applies: super admins are allowed everything. Users check their own
+// permissions; others' need the user to be in the caller's org.
+// POST /api/v1/users/:id/permissions/check
+func (h *UserHandler) CheckPermissions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	var req models.CheckPermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return
+	}
+	subject := policy.FromContext(c)
+	if user.ID != subject.UserID && subject.CanAccess(user.OrgID) != nil {
+		respondForbidden(c, "Cannot check permissions of users outside your organization")
+		return
+	}
+
+	allowed := make([]bool, len(req.Permissions))
+	if user.IsSuperAdmin {
+		for i := range allowed {
+			allowed[i] = true
+		}
+	} else if allowed, err = h.userRepo.HasPermissions(c.Request.Context(), id, req.Permissions); err != nil {
+		respondError(c, err, "Failed to check permissions")
+		return
+	}
+
+	results := make([]models.PermissionCheckResult, len(req.Permissions))
+	for i, check := range req.Permissions {
+		results[i] = models.PermissionCheckResult{
+			Resource: check.Resource,
+			Action:   check.Action,
+			Allowed:  allowed[i],
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// ExplainPermission shows which of a user's roles grant or fail to grant resource:action, as
+// RequirePermission decides it. It is open to the same callers as CheckPermissions.
+// GET /api/v1/users/:id/permissions/explain?resource=&action=
+func (h *UserHandler) ExplainPermission(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	resource, action := c.Query("resource"), c.Query("action")
+	if resource == "" || action == "" {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "resource and action are required",
+		})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return
+	}
+	subject := policy.FromContext(c)
+	if user.ID != subject.UserID && subject.CanAccess(user.OrgID) != nil {
+		respondForbidden(c, "Cannot check permissions of users outside your organization")
+		return
+	}
+
+	explanation, err := h.userRepo.ExplainPermission(c.Request.Context(), id, resource, action)
+	if err != nil {
+		respondError(c, err, "Failed to explain permission")
+		return
+	}
+	// Super admins bypass permission checks whatever their roles grant
+	if user.IsSuperAdmin {
+		explanation.Allowed = true
+		explanation.Reason = "super_admin"
+		explanation.Missing = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": explanation})
+}
+
 // AssignRole assigns a role to a user
 func (h *UserHandler) AssignRole(c *gin.Context) {
 	userID, err := uuid.Parse(c.Param("id"))
@@ -585,36 +626,34 @@ func (h *UserHandler) AssignRole(c *gin.Context) {
 		return
 	}
 
-	isSuperAdmin, _ := c.Get("is_super_admin")
-	userOrgID, _ := c.Get("org_id")
-	if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-		if user.OrgID == nil || userOrgID == nil {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot assign roles to users outside your organization",
-			})
-			return
-		}
-		uid, _ := uuid.Parse(userOrgID.(string))
-		if user.OrgID.String() != uid.String() {
-			c.JSON(http.StatusForbidden, errors.ErrorResponse{
-				Error:   errors.ErrForbidden.Code,
-				Message: "Cannot assign roles to users outside your organization",
-			})
-			return
-		}
+	if err := policy.FromContext(c).CanAccess(user.OrgID); err != nil {
+		respondForbidden(c, "Cannot assign roles to users outside your organization")
+		return
 	}
 
 	// Get current user ID
-	currentUserID, _ := c.Get("user_id")
-	assignedBy, _ := uuid.Parse(currentUserID.(string))
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+	assignedBy := caller.ID
 
-	// Remove all existing roles for this user first (replace, not add)
-	existingRoles, err := h.userRepo.GetUserRoles(c.Request.Context(), userID)
-	if err == nil {
-		for _, role := range existingRoles {
-			if err := h.roleRepo.RemoveRoleFromUser(c.Request.Context(), userID, role.ID); err != nil {
-				log.Printf("Warning: Failed to remove existing role %s from user: %v", role.ID, err)
+	if !h.checkAssignableRoles(c, user, []uuid.UUID{req.RoleID}) {
+		return
+	}
+
+	// Unless adding, remove all existing roles for this user first (replace, not add)
+	if req.Mode != "add" {
+		existingRoles, err := h.userRepo.GetUserRoles(c.Request.Context(), userID)
+		if err == nil {
+			for _, role := range existingRoles {
+				if role.ID == req.RoleID {
+					continue
+				}
+				if err := h.roleRepo.RemoveRoleFromUser(c.Request.Context(), userID, role.ID); err != nil {
+					log.Printf("Warning: Failed to remove existing role %s from user: %v", role.ID, err)
+				}
 			}
 		}
 	}
@@ -638,6 +677,99 @@ func (h *UserHandler) AssignRole(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
 }
 
+// SetRoles replaces a user's roles, so they hold exactly the roles listed
+// PUT /api/v1/users/:id/roles
+func (h *UserHandler) SetRoles(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	var req models.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+			Error:   errors.ErrValidation.Code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user")
+		return
+	}
+	if err := policy.FromContext(c).CanAccess(user.OrgID); err != nil {
+		respondForbidden(c, "Cannot assign roles to users outside your organization")
+		return
+	}
+
+	caller, err := authctx.CurrentUser(c)
+	if err != nil {
+		respondError(c, err, "User not authenticated")
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(req.RoleIDs))
+	roleIDs := make([]uuid.UUID, 0, len(req.RoleIDs))
+	for _, roleID := range req.RoleIDs {
+		if !seen[roleID] {
+			seen[roleID] = true
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+	if !h.checkAssignableRoles(c, user, roleIDs) {
+		return
+	}
+
+	if err := h.roleRepo.SetUserRoles(c.Request.Context(), userID, roleIDs, caller.ID); err != nil {
+		respondError(c, err, "Failed to set roles")
+		return
+	}
+
+	roles, err := h.userRepo.GetUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err, "Failed to get user roles")
+		return
+	}
+	if roles == nil {
+		roles = []*models.Role{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+// checkAssignableRoles answers 400 unless every role exists and is a system role or one of the
+// user's org
+func (h *UserHandler) checkAssignableRoles(c *gin.Context, user *models.User, roleIDs []uuid.UUID) bool {
+	for _, roleID := range roleIDs {
+		role, err := h.roleRepo.GetByID(c.Request.Context(), roleID)
+		if err == errors.ErrNotFound {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Role %s not found", roleID),
+			})
+			return false
+		}
+		if err != nil {
+			respondError(c, err, "Failed to get role")
+			return false
+		}
+		if role.OrgID != nil && (user.OrgID == nil || *role.OrgID != *user.OrgID) {
+			c.JSON(http.StatusBadRequest, errors.ErrorResponse{
+				Error:   errors.ErrValidation.Code,
+				Message: fmt.Sprintf("Role %q belongs to another organization", role.Name),
+			})
+			return false
+		}
+	}
+	return true
+}
+
 // RemoveRole removes a role from a user
 func (h *UserHandler) RemoveRole(c *gin.Context) {
 	userID, err := uuid.Parse(c.Param("id"))
@@ -661,24 +793,9 @@ func (h *UserHandler) RemoveRole(c *gin.Context) {
 	// Check if user is in same org (unless super admin)
 	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
 	if err == nil {
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		userOrgID, _ := c.Get("org_id")
-		if isSuperAdmin == nil || !isSuperAdmin.(bool) {
-			if user.OrgID == nil || userOrgID == nil {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot remove roles from users outside your organization",
-				})
-				return
-			}
-			uid, _ := uuid.Parse(userOrgID.(string))
-			if user.OrgID.String() != uid.String() {
-				c.JSON(http.StatusForbidden, errors.ErrorResponse{
-					Error:   errors.ErrForbidden.Code,
-					Message: "Cannot remove roles from users outside your organization",
-				})
-				return
-			}
+		if err := policy.FromContext(c).CanAccess(user.OrgID); err != nil {
+			respondForbidden(c, "Cannot remove roles from users outside your organization")
+			return
 		}
 	}
 
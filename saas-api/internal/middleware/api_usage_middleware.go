@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/orgtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// apiUsageKey identifies a single rollup row (org-local hour bucket, org, endpoint)
+type apiUsageKey struct {
+	bucketStart time.Time
+	orgID       uuid.UUID
+	method      string
+	route       string
+}
+
+// APIUsageMiddleware records per-endpoint request counts, errors and latency by org.
+// Samples are aggregated in memory and flushed to api_usage_rollups periodically.
+type APIUsageMiddleware struct {
+	usageRepo     *repositories.APIUsageRepository
+	timezones     *orgtime.Resolver // Buckets follow the org's local hours; nil means UTC
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	pending map[apiUsageKey]*models.APIUsageRollup
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewAPIUsageMiddleware(usageRepo *repositories.APIUsageRepository, timezones *orgtime.Resolver, flushInterval time.Duration) *APIUsageMiddleware {
+	if flushInterval <= 0 {
+		flushInterval = time.Minute
+	}
+	return &APIUsageMiddleware{
+		usageRepo:     usageRepo,
+		timezones:     timezones,
+		flushInterval: flushInterval,
+		pending:       make(map[apiUsageKey]*models.APIUsageRollup),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Track must be registered globally; org/user context set by auth middleware is read after the handler chain runs
+func (m *APIUsageMiddleware) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Unmatched routes would otherwise create one row per scanned URL
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+
+		var orgID uuid.UUID
+		if orgIDStr, exists := c.Get("org_id"); exists && orgIDStr != nil {
+			if parsed, err := uuid.Parse(orgIDStr.(string)); err == nil {
+				orgID = parsed
+			}
+		}
+
+		// Half-hour offset zones start their hours at :30 UTC, so daily totals in the org's
+		// timezone never split an hour bucket
+		loc := m.timezones.Location(c.Request.Context(), &orgID)
+
+		m.record(apiUsageKey{
+			bucketStart: orgtime.StartOfHour(start, loc).UTC(),
+			orgID:       orgID,
+			method:      c.Request.Method,
+			route:       route,
+		}, time.Since(start), c.Writer.Status() >= 400)
+	}
+}
+
+func (m *APIUsageMiddleware) record(key apiUsageKey, latency time.Duration, isError bool) {
+	latencyMs := latency.Milliseconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rollup, ok := m.pending[key]
+	if !ok {
+		rollup = &models.APIUsageRollup{
+			BucketStart:    key.bucketStart,
+			Method:         key.method,
+			Route:          key.route,
+			LatencyBuckets: make([]int64, len(models.APIUsageLatencyBoundsMs)+1),
+		}
+		if key.orgID != uuid.Nil {
+			orgID := key.orgID
+			rollup.OrgID = &orgID
+		}
+		m.pending[key] = rollup
+	}
+
+	rollup.RequestCount++
+	if isError {
+		rollup.ErrorCount++
+	}
+	rollup.TotalLatencyMs += latencyMs
+	if latencyMs > rollup.MaxLatencyMs {
+		rollup.MaxLatencyMs = latencyMs
+	}
+
+	bucket := len(models.APIUsageLatencyBoundsMs)
+	for i, bound := range models.APIUsageLatencyBoundsMs {
+		if latencyMs <= bound {
+			bucket = i
+			break
+		}
+	}
+	rollup.LatencyBuckets[bucket]++
+}
+
+// Schedule describes usage bucketing for an organization in the given timezone
+func (m *APIUsageMiddleware) Schedule(now time.Time, loc *time.Location) []models.ScheduledJob {
+	return []models.ScheduledJob{{
+		Name:            "api_usage_rollups",
+		Enabled:         true,
+		IntervalMinutes: int(m.flushInterval / time.Minute),
+		Description:     fmt.Sprintf("Requests are counted in local hours starting at %s", orgtime.StartOfHour(now, loc).Format("15:04 MST")),
+	}}
+}
+
+// Start launches the background flush loop
+func (m *APIUsageMiddleware) Start() {
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.Flush(context.Background())
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the flush loop and writes any remaining samples
+func (m *APIUsageMiddleware) Stop(ctx context.Context) {
+	close(m.stop)
+	<-m.done
+	m.Flush(ctx)
+}
+
+// Flush persists pending rollups. On failure the samples are merged back so they are retried on the next flush.
+func (m *APIUsageMiddleware) Flush(ctx context.Context) {
+	m.mu.Lock()
+	if len(m.pending) == 0 {
+		m.mu.Unlock()
+		return
+	}
+	batch := m.pending
+	m.pending = make(map[apiUsageKey]*models.APIUsageRollup)
+	m.mu.Unlock()
+
+	rollups := make([]*models.APIUsageRollup, 0, len(batch))
+	for _, rollup := range batch {
+		rollups = append(rollups, rollup)
+	}
+
+	if err := m.usageRepo.UpsertRollups(ctx, rollups); err != nil {
+		log.Printf("Failed to flush API usage rollups: %v", err)
+		m.requeue(batch)
+	}
+}
+
+func (m *APIUsageMiddleware) requeue(batch map[apiUsageKey]*models.APIUsageRollup) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, rollup := range batch {
+		existing, ok := m.pending[key]
+		if !ok {
+			m.pending[key] = rollup
+			continue
+		}
+		existing.RequestCount += rollup.RequestCount
+		existing.ErrorCount += rollup.ErrorCount
+		existing.TotalLatencyMs += rollup.TotalLatencyMs
+		if rollup.MaxLatencyMs > existing.MaxLatencyMs {
+			existing.MaxLatencyMs = rollup.MaxLatencyMs
+		}
+		for i := range existing.LatencyBuckets {
+			existing.LatencyBuckets[i] += rollup.LatencyBuckets[i]
+		}
+	}
+}
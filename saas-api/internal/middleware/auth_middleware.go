@@ -6,19 +6,45 @@ import (
 	"strings"
 
 	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 )
 
+// APIKeyAuthenticator resolves the key in an `Authorization: ApiKey <key>` header to the key and the
+// user it acts as (see services.APIKeyService)
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key, ipAddress string) (*models.APIKey, *models.User, error)
+}
+
+// PersonalTokenAuthenticator resolves a personal access token sent as `Authorization: Bearer pat_...`
+// to the token and its user (see services.PersonalAccessTokenService)
+type PersonalTokenAuthenticator interface {
+	Authenticate(ctx context.Context, token, ipAddress string) (*models.PersonalAccessToken, *models.User, error)
+}
+
 type AuthMiddleware struct {
-	tokenService *auth.TokenService
+	tokenService   *auth.TokenService
+	apiKeys        APIKeyAuthenticator        // nil: only bearer tokens are accepted
+	personalTokens PersonalTokenAuthenticator // nil: pat_ bearer tokens are rejected like invalid JWTs
 }
 
 func NewAuthMiddleware(tokenService *auth.TokenService) *AuthMiddleware {
 	return &AuthMiddleware{tokenService: tokenService}
 }
 
+// SetAPIKeys makes RequireAuth accept API keys as well as bearer tokens
+func (m *AuthMiddleware) SetAPIKeys(apiKeys APIKeyAuthenticator) {
+	m.apiKeys = apiKeys
+}
+
+// SetPersonalTokens makes RequireAuth accept personal access tokens as bearer tokens
+func (m *AuthMiddleware) SetPersonalTokens(personalTokens PersonalTokenAuthenticator) {
+	m.personalTokens = personalTokens
+}
+
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -31,6 +57,11 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if m.apiKeys != nil && strings.HasPrefix(authHeader, "ApiKey ") {
+			m.authenticateAPIKey(c, strings.TrimSpace(strings.TrimPrefix(authHeader, "ApiKey ")))
+			return
+		}
+
 		tokenString, err := m.tokenService.ExtractTokenFromHeader(authHeader)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
@@ -41,6 +72,11 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if m.personalTokens != nil && strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			m.authenticatePersonalToken(c, tokenString)
+			return
+		}
+
 		claims, err := m.tokenService.ValidateToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
@@ -58,6 +94,10 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			c.Set("org_id", claims.OrgID.String())
 		}
 		c.Set("is_super_admin", claims.IsSuperAdmin)
+		if claims.AuthTime != nil {
+			// Checked by StepUpMiddleware
+			c.Set("auth_time", claims.AuthTime.Time)
+		}
 
 		// Set RLS context variables for PostgreSQL
 		ctx := c.Request.Context()
@@ -67,12 +107,160 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			ctx = context.WithValue(ctx, "org_id", claims.OrgID.String())
 		}
 		ctx = context.WithValue(ctx, "is_super_admin", claims.IsSuperAdmin)
+		ctx = tagImpersonation(ctx, c, claims)
+		tagClient(c, claims)
 		c.Request = c.Request.WithContext(ctx)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIKey sets the same context as a bearer token would for the key's user, plus the key's
+// ID and scopes, which PermissionMiddleware enforces. Keys never carry super admin rights.
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, user, err := m.apiKeys.Authenticate(c.Request.Context(), key, m.GetClientIP(c))
+	if err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if !ok || appErr.Status != http.StatusUnauthorized {
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: "Failed to verify API key",
+			})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error:   appErr.Code,
+			Message: appErr.Message,
+		})
+		c.Abort()
+		return
+	}
+
+	// Set user context
+	c.Set("user_id", user.ID.String())
+	c.Set("email", user.Email)
+	c.Set("org_id", apiKey.OrgID.String())
+	c.Set("is_super_admin", false)
+	c.Set("api_key_id", apiKey.ID.String())
+	c.Set("api_key_scopes", apiKey.Scopes)
+
+	// Set RLS context variables for PostgreSQL
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "user_id", user.ID.String())
+	ctx = context.WithValue(ctx, "org_id", apiKey.OrgID.String())
+	ctx = context.WithValue(ctx, "is_super_admin", false)
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+}
+
+// authenticatePersonalToken sets the same context as a bearer token would for the token's user, plus
+// the token's ID and scopes, which PermissionMiddleware enforces like an API key's. Tokens never
+// carry super admin rights.
+func (m *AuthMiddleware) authenticatePersonalToken(c *gin.Context, plaintext string) {
+	token, user, err := m.personalTokens.Authenticate(c.Request.Context(), plaintext, m.GetClientIP(c))
+	if err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if !ok || appErr.Status != http.StatusUnauthorized {
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: "Failed to verify personal access token",
+			})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error:   appErr.Code,
+			Message: appErr.Message,
+		})
+		c.Abort()
+		return
+	}
+
+	// Set user context
+	c.Set("user_id", user.ID.String())
+	c.Set("email", user.Email)
+	if token.OrgID != nil {
+		c.Set("org_id", token.OrgID.String())
+	}
+	c.Set("is_super_admin", false)
+	c.Set("personal_token_id", token.ID.String())
+	c.Set("api_key_scopes", token.Scopes)
+
+	// Set RLS context variables for PostgreSQL
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "user_id", user.ID.String())
+	ctx = context.WithValue(ctx, "org_id", "")
+	if token.OrgID != nil {
+		ctx = context.WithValue(ctx, "org_id", token.OrgID.String())
+	}
+	ctx = context.WithValue(ctx, "is_super_admin", false)
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+}
+
+// RequireAPIKey authenticates with an API key only, for machine clients such as SCIM provisioning.
+// Clients that can only send bearer tokens may pass the key as `Authorization: Bearer <key>`.
+func (m *AuthMiddleware) RequireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		scheme, key, _ := strings.Cut(authHeader, " ")
+		if m.apiKeys == nil || (scheme != "ApiKey" && scheme != "Bearer") || strings.TrimSpace(key) == "" {
+			c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+				Error:   errors.ErrUnauthorized.Code,
+				Message: "An API key is required (Authorization: Bearer <key>)",
+			})
+			c.Abort()
+			return
+		}
+		m.authenticateAPIKey(c, strings.TrimSpace(key))
+	}
+}
+
+// RequireUserToken refuses requests authenticated with an API key, an OAuth client token, a personal
+// access token or an impersonation token, for endpoints only the signed-in person themselves should
+// reach: managing API keys, passkeys and 2FA, or anything else that could turn a scoped key or
+// borrowed identity into a full session
+func (m *AuthMiddleware) RequireUserToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("api_key_id"); isAPIKey {
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   errors.ErrForbidden.Code,
+				Message: "This endpoint cannot be used with an API key",
+			})
+			c.Abort()
+			return
+		}
+		if _, isPersonalToken := c.Get("personal_token_id"); isPersonalToken {
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   errors.ErrForbidden.Code,
+				Message: "This endpoint cannot be used with a personal access token",
+			})
+			c.Abort()
+			return
+		}
+		if _, isClient := c.Get("oauth_client_id"); isClient {
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   errors.ErrForbidden.Code,
+				Message: "This endpoint cannot be used with an OAuth client token",
+			})
+			c.Abort()
+			return
+		}
+		if _, impersonating := c.Get("impersonator_id"); impersonating {
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   errors.ErrForbidden.Code,
+				Message: "This endpoint cannot be used while impersonating a user",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // RequireAuthForStatic is similar to RequireAuth but also accepts tokens from query parameters or cookies
 // This is needed for static file requests that can't send Authorization headers (e.g., window.open, img src)
 func (m *AuthMiddleware) RequireAuthForStatic() gin.HandlerFunc {
@@ -104,6 +292,8 @@ func (m *AuthMiddleware) RequireAuthForStatic() gin.HandlerFunc {
 						ctx = context.WithValue(ctx, "org_id", claims.OrgID.String())
 					}
 					ctx = context.WithValue(ctx, "is_super_admin", claims.IsSuperAdmin)
+					ctx = tagImpersonation(ctx, c, claims)
+					tagClient(c, claims)
 					c.Request = c.Request.WithContext(ctx)
 
 					c.Next()
@@ -137,6 +327,8 @@ func (m *AuthMiddleware) RequireAuthForStatic() gin.HandlerFunc {
 					ctx = context.WithValue(ctx, "org_id", claims.OrgID.String())
 				}
 				ctx = context.WithValue(ctx, "is_super_admin", claims.IsSuperAdmin)
+				ctx = tagImpersonation(ctx, c, claims)
+				tagClient(c, claims)
 				c.Request = c.Request.WithContext(ctx)
 
 				c.Next()
@@ -169,6 +361,8 @@ func (m *AuthMiddleware) RequireAuthForStatic() gin.HandlerFunc {
 					ctx = context.WithValue(ctx, "org_id", claims.OrgID.String())
 				}
 				ctx = context.WithValue(ctx, "is_super_admin", claims.IsSuperAdmin)
+				ctx = tagImpersonation(ctx, c, claims)
+				tagClient(c, claims)
 				c.Request = c.Request.WithContext(ctx)
 
 				c.Next()
@@ -185,10 +379,34 @@ func (m *AuthMiddleware) RequireAuthForStatic() gin.HandlerFunc {
 	}
 }
 
+// ImpersonatedByHeader is set on responses to impersonation tokens so clients can show who is acting
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// tagImpersonation marks a request made with an impersonation token: "impersonator_id" goes on the
+// gin and request contexts, where the request log, audit logs and tenant headers pick it up
+func tagImpersonation(ctx context.Context, c *gin.Context, claims *auth.Claims) context.Context {
+	if claims.ImpersonatorID == nil {
+		return ctx
+	}
+	impersonatorID := claims.ImpersonatorID.String()
+	c.Set("impersonator_id", impersonatorID)
+	c.Writer.Header().Set(ImpersonatedByHeader, impersonatorID)
+	return context.WithValue(ctx, "impersonator_id", impersonatorID)
+}
+
+// tagClient marks a request made with a client_credentials token: "oauth_client_id" goes on the gin
+// context, and the token's scopes limit it the way an API key's do
+func tagClient(c *gin.Context, claims *auth.Claims) {
+	if claims.ClientID == nil {
+		return
+	}
+	c.Set("oauth_client_id", claims.ClientID.String())
+	c.Set("api_key_scopes", strings.Fields(claims.Scope))
+}
+
 func (m *AuthMiddleware) RequireSuperAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isSuperAdmin, exists := c.Get("is_super_admin")
-		if !exists || !isSuperAdmin.(bool) {
+		if err := policy.FromContext(c).RequireSuperAdmin(); err != nil {
 			c.JSON(http.StatusForbidden, errors.ErrorResponse{
 				Error:   errors.ErrForbidden.Code,
 				Message: "Super admin access required",
@@ -200,15 +418,9 @@ func (m *AuthMiddleware) RequireSuperAdmin() gin.HandlerFunc {
 	}
 }
 
+// GetClientIP is the client's address. X-Forwarded-For and X-Real-IP only count when the request
+// comes from one of SERVER_TRUSTED_PROXIES (see router.SetTrustedProxies); otherwise a client could
+// send a new address with every request to escape per-IP rate limits.
 func (m *AuthMiddleware) GetClientIP(c *gin.Context) string {
-	ip := c.GetHeader("X-Forwarded-For")
-	if ip != "" {
-		ips := strings.Split(ip, ",")
-		return strings.TrimSpace(ips[0])
-	}
-	ip = c.GetHeader("X-Real-IP")
-	if ip != "" {
-		return ip
-	}
 	return c.ClientIP()
 }
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FeatureResolver tells whether an org has a feature (see services.FeatureService)
+type FeatureResolver interface {
+	Feature(ctx context.Context, orgID uuid.UUID, feature string) (*models.FeatureState, error)
+}
+
+// FeatureMiddleware gates endpoints on the caller's organization: its subscription plan and
+// feature flags (see policy.HasFeature). It runs alongside RequirePermission, which decides what
+// the user may do; this decides what the org has paid for.
+type FeatureMiddleware struct {
+	features FeatureResolver
+}
+
+func NewFeatureMiddleware(features FeatureResolver) *FeatureMiddleware {
+	return &FeatureMiddleware{features: features}
+}
+
+// RequireFeature answers 403 FEATURE_NOT_AVAILABLE unless the caller's org has the feature. Super
+// admins, who act across orgs, are not checked.
+func (m *FeatureMiddleware) RequireFeature(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := policy.FromContext(c)
+		if subject.IsSuperAdmin {
+			c.Next()
+			return
+		}
+
+		if subject.OrgID == nil {
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   errors.ErrForbidden.Code,
+				Message: "Organization context required",
+			})
+			c.Abort()
+			return
+		}
+
+		state, err := m.features.Feature(c.Request.Context(), *subject.OrgID, feature)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
+				Error:   errors.ErrInternalServer.Code,
+				Message: "Failed to check organization features",
+			})
+			c.Abort()
+			return
+		}
+
+		if !state.Enabled {
+			log.Printf("Feature %s denied for org %s (%s)", feature, *subject.OrgID, state.Source)
+			message := "Your organization's plan does not include " + strings.ReplaceAll(feature, "_", " ") + ". Please upgrade to use it."
+			if state.Source == "flag" {
+				message = "The " + strings.ReplaceAll(feature, "_", " ") + " feature is turned off for your organization."
+			}
+			c.JSON(http.StatusForbidden, errors.ErrorResponse{
+				Error:   "FEATURE_NOT_AVAILABLE",
+				Message: message,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
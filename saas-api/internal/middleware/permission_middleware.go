@@ -1,8 +1,14 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
+	"saas-api/internal/authctx"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
 	"saas-api/internal/repositories"
 	"saas-api/pkg/errors"
 
@@ -20,8 +26,41 @@ func NewPermissionMiddleware(userRepo *repositories.UserRepository) *PermissionM
 	}
 }
 
-// RequirePermission checks if the authenticated user has a specific permission
+// RequirePermission checks if the authenticated user has a specific permission. Callers using an API
+// key or an OAuth client token also need the permission among its scopes. A permission or scope with
+// the wildcard action (documents:*) grants every action on its resource.
 func (m *PermissionMiddleware) RequirePermission(resource, action string) gin.HandlerFunc {
+	return m.requireAny([]models.PermissionCheck{{Resource: resource, Action: action}})
+}
+
+// RequireAnyPermission is RequirePermission for routes open to holders of any one of several
+// permissions, given as resource:action (e.g. "documents:update", "folders:*"). An API key or OAuth
+// client token must be scoped for a permission its user holds.
+func (m *PermissionMiddleware) RequireAnyPermission(permissions ...string) gin.HandlerFunc {
+	if len(permissions) == 0 {
+		panic("RequireAnyPermission: no permissions given")
+	}
+	checks := make([]models.PermissionCheck, len(permissions))
+	for i, permission := range permissions {
+		resource, action, ok := strings.Cut(permission, ":")
+		if !ok || resource == "" || action == "" {
+			panic(fmt.Sprintf("RequireAnyPermission: %q is not resource:action", permission))
+		}
+		checks[i] = models.PermissionCheck{Resource: resource, Action: action}
+	}
+	return m.requireAny(checks)
+}
+
+func (m *PermissionMiddleware) requireAny(checks []models.PermissionCheck) gin.HandlerFunc {
+	names := make([]string, len(checks))
+	for i, check := range checks {
+		names[i] = check.Resource + ":" + check.Action
+	}
+	required := strings.Join(names, ", ")
+	if len(checks) > 1 {
+		required = "any of " + required
+	}
+
 	return func(c *gin.Context) {
 		userIDStr, exists := c.Get("user_id")
 		if !exists {
@@ -44,13 +83,34 @@ func (m *PermissionMiddleware) RequirePermission(resource, action string) gin.Ha
 		}
 
 		// Super admins bypass permission checks
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		if isSuperAdmin != nil && isSuperAdmin.(bool) {
+		if policy.FromContext(c).IsSuperAdmin {
 			c.Next()
 			return
 		}
 
-		hasPermission, err := m.userRepo.HasPermission(c.Request.Context(), userID, resource, action)
+		// A key only reaches the permissions in its scopes
+		candidates := checks
+		if scopes, isAPIKey := authctx.APIKeyScopes(c); isAPIKey {
+			candidates = slices.DeleteFunc(slices.Clone(checks), func(check models.PermissionCheck) bool {
+				return !policy.ScopesAllow(scopes, check.Resource, check.Action)
+			})
+			if len(candidates) == 0 {
+				holder := "API key"
+				if authctx.OAuthClientID(c) != nil {
+					holder = "OAuth client token"
+				} else if authctx.PersonalTokenID(c) != nil {
+					holder = "Personal access token"
+				}
+				c.JSON(http.StatusForbidden, errors.ErrorResponse{
+					Error:   errors.ErrForbidden.Code,
+					Message: holder + " is not scoped for " + required,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		allowed, err := m.userRepo.HasPermissions(c.Request.Context(), userID, candidates)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
 				Error:   errors.ErrInternalServer.Code,
@@ -60,7 +120,7 @@ func (m *PermissionMiddleware) RequirePermission(resource, action string) gin.Ha
 			return
 		}
 
-		if !hasPermission {
+		if !slices.Contains(allowed, true) {
 			c.JSON(http.StatusForbidden, errors.ErrorResponse{
 				Error:   errors.ErrForbidden.Code,
 				Message: "You do not have permission to perform this action",
@@ -77,14 +137,13 @@ func (m *PermissionMiddleware) RequirePermission(resource, action string) gin.Ha
 func (m *PermissionMiddleware) RequireSameOrg() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Super admins bypass org checks
-		isSuperAdmin, _ := c.Get("is_super_admin")
-		if isSuperAdmin != nil && isSuperAdmin.(bool) {
+		subject := policy.FromContext(c)
+		if subject.IsSuperAdmin {
 			c.Next()
 			return
 		}
 
-		userOrgID, exists := c.Get("org_id")
-		if !exists || userOrgID == nil || userOrgID == "" {
+		if _, err := subject.ListScope(nil); err != nil {
 			c.JSON(http.StatusForbidden, errors.ErrorResponse{
 				Error:   errors.ErrForbidden.Code,
 				Message: "Organization context required",
@@ -94,7 +153,7 @@ func (m *PermissionMiddleware) RequireSameOrg() gin.HandlerFunc {
 		}
 
 		// Store org_id for handlers to use
-		c.Set("required_org_id", userOrgID)
+		c.Set("required_org_id", subject.OrgID.String())
 		c.Next()
 	}
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is set by the auth proxy so one ID follows a request through proxy, LibreChat and saas-api
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIDMiddleware keeps the inbound X-Request-ID (or generates one), stores it as "request_id"
+// in the gin context and echoes it on the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set("request_id", requestID)
+		c.Writer.Header().Set(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// RequestLogger is gin's default request log line with the request ID appended, and the
+// impersonator for requests made with an impersonation token
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		requestID, _ := p.Keys["request_id"].(string)
+		if impersonatorID, _ := p.Keys["impersonator_id"].(string); impersonatorID != "" {
+			requestID += " impersonator=" + impersonatorID
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | request_id=%s\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			p.StatusCode,
+			p.Latency.Round(time.Microsecond),
+			p.ClientIP,
+			p.Method,
+			p.Path,
+			requestID,
+			p.ErrorMessage,
+		)
+	})
+}
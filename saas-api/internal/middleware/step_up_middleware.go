@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/authctx"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StepUpMiddleware guards sensitive operations with a recent authentication: the access token's
+// auth_time must be at most STEP_UP_MAX_AGE minutes old. Callers re-authenticate at
+// /auth/step-up for a fresh token. API keys and tokens without an auth_time never pass.
+type StepUpMiddleware struct {
+	maxAge time.Duration // 0 disables the check
+}
+
+func NewStepUpMiddleware(cfg config.StepUpConfig) *StepUpMiddleware {
+	return &StepUpMiddleware{maxAge: time.Duration(cfg.MaxAge) * time.Minute}
+}
+
+// RequireRecentAuth answers 401 STEP_UP_REQUIRED, with a WWW-Authenticate challenge naming the
+// max_age (RFC 9470), unless the caller authenticated recently. It runs after RequireAuth.
+func (m *StepUpMiddleware) RequireRecentAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.maxAge <= 0 {
+			c.Next()
+			return
+		}
+
+		if authTime, ok := authctx.AuthTime(c); ok && time.Since(authTime) <= m.maxAge {
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="Recent authentication required", max_age=%d`, int(m.maxAge.Seconds())))
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{
+			Error:   "STEP_UP_REQUIRED",
+			Message: "Please confirm your password or a one-time code to continue",
+		})
+		c.Abort()
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -67,9 +68,32 @@ type CreateOrganizationRequest struct {
 	SubscriptionPlan    *string                `json:"subscription_plan"`
 	MaxUsers            *int                   `json:"max_users"`
 	MaxStorageGB        *int                   `json:"max_storage_gb"`
+	Timezone            *string                `json:"timezone"` // IANA name, e.g. "Asia/Kolkata"; defaults to UTC
 	Settings            map[string]interface{} `json:"settings"`
 }
 
+// OrganizationSchedule reports the timezone an organization's schedules and reporting periods use
+type OrganizationSchedule struct {
+	OrgID          uuid.UUID      `json:"org_id"`
+	Timezone       string         `json:"timezone"`   // Effective IANA name; UTC when unset or invalid
+	UTCOffset      string         `json:"utc_offset"` // In effect now, e.g. "+05:30"
+	LocalTime      time.Time      `json:"local_time"`
+	DayStart       time.Time      `json:"day_start"`
+	NextDayStart   time.Time      `json:"next_day_start"`
+	MonthStart     time.Time      `json:"month_start"`
+	NextMonthStart time.Time      `json:"next_month_start"`
+	Jobs           []ScheduledJob `json:"jobs"`
+}
+
+// ScheduledJob describes a background job as it applies to one organization
+type ScheduledJob struct {
+	Name            string     `json:"name"`
+	Enabled         bool       `json:"enabled"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	Cutoff          *time.Time `json:"cutoff,omitempty"` // Org-local midnight; items older than this are handled by the next run
+	Description     string     `json:"description"`
+}
+
 type UpdateOrganizationRequest struct {
 	Name                *string                `json:"name"`
 	LegalName           *string                `json:"legal_name"`
@@ -86,6 +110,7 @@ type UpdateOrganizationRequest struct {
 	BillingEmail        *string                `json:"billing_email"`
 	SubscriptionPlan    *string                `json:"subscription_plan"`
 	Status              *string                `json:"status"`
+	Timezone            *string                `json:"timezone"`
 	Settings            map[string]interface{} `json:"settings"`
 }
 
@@ -116,6 +141,9 @@ type User struct {
 	Locale              string                 `json:"locale"`
 	Metadata            map[string]interface{} `json:"metadata"`
 	Roles               []Role                 `json:"roles,omitempty"` // User's roles (fetched separately) - determines permissions
+	InvitedBy           *uuid.UUID             `json:"invited_by,omitempty"`
+	PendingExpiredAt    *time.Time             `json:"pending_expired_at,omitempty"` // Set when a stale pending invitation is flagged
+	PendingAgeDays      *int                   `json:"pending_age_days,omitempty"`   // Days since creation, only for pending users (listing)
 	CreatedAt           time.Time              `json:"created_at"`
 	UpdatedAt           time.Time              `json:"updated_at"`
 	DeletedAt           *time.Time             `json:"deleted_at,omitempty"`
@@ -150,26 +178,34 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// LoginResponse carries the tokens of a completed sign-in. A sign-in that still needs a second
+// factor carries only MFAToken, with MFARequired (enter a 2FA code at /auth/2fa/verify) or
+// MFAEnrollmentRequired (the org requires 2FA; enroll at /auth/2fa/enroll) set.
 type LoginResponse struct {
-	AccessToken  string       `json:"access_token"`
-	RefreshToken string       `json:"refresh_token"`
-	TokenType    string       `json:"token_type"`
-	ExpiresIn    int          `json:"expires_in"`
-	User         *User        `json:"user"`
-	Permissions  []Permission `json:"permissions,omitempty"` // User's permissions for frontend
+	AccessToken           string       `json:"access_token,omitempty"`
+	RefreshToken          string       `json:"refresh_token,omitempty"`
+	TokenType             string       `json:"token_type,omitempty"`
+	ExpiresIn             int          `json:"expires_in,omitempty"`
+	User                  *User        `json:"user,omitempty"`
+	Permissions           []Permission `json:"permissions,omitempty"` // User's permissions for frontend
+	Features              []string     `json:"features,omitempty"`    // Features the user's org has, see GET /features
+	MFARequired           bool         `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool         `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string       `json:"mfa_token,omitempty"`
 }
 
 // Role models
 type Role struct {
-	ID          uuid.UUID  `json:"id"`
-	OrgID       *uuid.UUID `json:"org_id,omitempty"`
-	Name        string     `json:"name"`
-	Type        string     `json:"type"`
-	Description *string    `json:"description,omitempty"`
-	IsDefault   bool       `json:"is_default"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	ID          uuid.UUID   `json:"id"`
+	OrgID       *uuid.UUID  `json:"org_id,omitempty"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description *string     `json:"description,omitempty"`
+	IsDefault   bool        `json:"is_default"`
+	ParentIDs   []uuid.UUID `json:"parent_ids,omitempty"` // Roles whose permissions this one inherits
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	CreatedBy   *uuid.UUID  `json:"created_by,omitempty"`
 }
 
 type CreateRoleRequest struct {
@@ -178,6 +214,12 @@ type CreateRoleRequest struct {
 	IsDefault     *bool       `json:"is_default"`
 	OrgID         *uuid.UUID  `json:"org_id,omitempty"` // Optional: Super admin can specify org_id
 	PermissionIDs []uuid.UUID `json:"permission_ids,omitempty"`
+	ParentIDs     []uuid.UUID `json:"parent_ids,omitempty"`
+}
+
+// SetRoleParentsRequest replaces the roles a role inherits from; an empty list removes them all
+type SetRoleParentsRequest struct {
+	ParentIDs []uuid.UUID `json:"parent_ids" binding:"required"`
 }
 
 type UpdateRoleRequest struct {
@@ -196,6 +238,23 @@ type Permission struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// PermissionWildcard as the action of a permission or scope grants every action on its resource,
+// e.g. documents:*
+const PermissionWildcard = "*"
+
+// CreatePermissionRequest registers a permission; resource and action are lowercase identifiers,
+// or the action is PermissionWildcard
+type CreatePermissionRequest struct {
+	Resource    string  `json:"resource" binding:"required,max=50"`
+	Action      string  `json:"action" binding:"required,max=20"`
+	Description *string `json:"description"`
+}
+
+// UpdatePermissionRequest changes a permission's description; null clears it
+type UpdatePermissionRequest struct {
+	Description *string `json:"description"`
+}
+
 // UserRole models
 type UserRole struct {
 	UserID     uuid.UUID  `json:"user_id"`
@@ -205,9 +264,73 @@ type UserRole struct {
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 }
 
+// AssignRoleRequest assigns a role to a user. Mode "replace", the default, removes the user's other
+// roles; "add" keeps them, so the user holds several roles.
 type AssignRoleRequest struct {
 	RoleID    uuid.UUID  `json:"role_id" binding:"required"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Mode      string     `json:"mode,omitempty" binding:"omitempty,oneof=replace add"`
+}
+
+// SetUserRolesRequest makes role_ids a user's only roles; an empty list removes them all
+type SetUserRolesRequest struct {
+	RoleIDs []uuid.UUID `json:"role_ids" binding:"required,max=50"`
+}
+
+// RoleAssignmentExpiry is a user's role assignment with an expiry. OrgID is the user's org.
+type RoleAssignmentExpiry struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	UserEmail  string     `json:"user_email"`
+	UserName   string     `json:"user_name"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	RoleID     uuid.UUID  `json:"role_id"`
+	RoleName   string     `json:"role_name"`
+	AssignedBy *uuid.UUID `json:"assigned_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// PermissionCheck is one resource:action pair of a batch permission check
+type PermissionCheck struct {
+	Resource string `json:"resource" binding:"required"`
+	Action   string `json:"action" binding:"required"`
+}
+
+type CheckPermissionsRequest struct {
+	Permissions []PermissionCheck `json:"permissions" binding:"required,min=1,max=100,dive"`
+}
+
+// PermissionCheckResult answers a PermissionCheck
+type PermissionCheckResult struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Allowed  bool   `json:"allowed"`
+}
+
+// PermissionExplanation shows how a user's permission check for resource:action is decided.
+// Reason is super_admin, granted (the action is held), wildcard (resource:* is held) or denied.
+// Requires lists the actions the check's dependency rule asks for, and Missing those no current
+// grant provides.
+type PermissionExplanation struct {
+	Resource      string            `json:"resource"`
+	Action        string            `json:"action"`
+	Allowed       bool              `json:"allowed"`
+	Reason        string            `json:"reason"`
+	Requires      []string          `json:"requires"`
+	Missing       []string          `json:"missing"`
+	Grants        []PermissionGrant `json:"grants"`
+	ExpiredGrants []PermissionGrant `json:"expired_grants"`
+}
+
+// PermissionGrant is a role that gives a user one permission. AssignedRole is the role the user
+// was assigned; it is the granting role itself unless that is inherited through parents.
+type PermissionGrant struct {
+	Action           string     `json:"action"`
+	RoleID           uuid.UUID  `json:"role_id"`
+	RoleName         string     `json:"role_name"`
+	AssignedRoleID   uuid.UUID  `json:"assigned_role_id"`
+	AssignedRoleName string     `json:"assigned_role_name"`
+	Inherited        bool       `json:"inherited"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
 }
 
 // RefreshToken models
@@ -230,6 +353,83 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"` // Optional - can also come from cookie
 }
 
+// LogoutAllResponse reports what POST /auth/logout-all ended
+type LogoutAllResponse struct {
+	RevokedSessions    int64 `json:"revoked_sessions"`
+	LibreChatSessions  int64 `json:"librechat_sessions"`
+	LibreChatSignedOut bool  `json:"librechat_signed_out"` // false when LibreChat's MongoDB could not be reached
+	ProxySignedOut     bool  `json:"proxy_signed_out"`     // false when the proxy could not be reached or PROXY_URL is unset
+}
+
+// ProxyTokenExchangeRequest carries the proxy session cookie (JWT) to be exchanged for a short-lived access token
+type ProxyTokenExchangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// IntrospectRequest is an RFC 7662 introspection request, sent as a form or as JSON
+type IntrospectRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"` // only access_token tokens are introspected
+}
+
+// IntrospectionResponse describes an access token (RFC 7662). Tokens that are invalid, expired, not
+// access tokens or whose user may no longer sign in only have "active": false.
+type IntrospectionResponse struct {
+	Active         bool       `json:"active"`
+	TokenType      string     `json:"token_type,omitempty"`
+	Issuer         string     `json:"iss,omitempty"`
+	Subject        string     `json:"sub,omitempty"`
+	Username       string     `json:"username,omitempty"`
+	ExpiresAt      int64      `json:"exp,omitempty"`
+	IssuedAt       int64      `json:"iat,omitempty"`
+	NotBefore      int64      `json:"nbf,omitempty"`
+	AuthTime       int64      `json:"auth_time,omitempty"`
+	UserID         *uuid.UUID `json:"user_id,omitempty"`
+	Email          string     `json:"email,omitempty"`
+	OrgID          *uuid.UUID `json:"org_id,omitempty"`
+	OrgRole        string     `json:"org_role,omitempty"`
+	IsSuperAdmin   bool       `json:"is_super_admin,omitempty"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"`
+	ClientID       *uuid.UUID `json:"client_id,omitempty"`   // client_credentials tokens only
+	Scope          string     `json:"scope,omitempty"`       // client_credentials tokens only
+	Permissions    []string   `json:"permissions,omitempty"` // "resource:action"
+	Features       []string   `json:"features,omitempty"`    // Features the user's org has, e.g. "mcp"
+}
+
+// ImpersonateRequest optionally says why a super admin signs in as a user; it goes in the audit log
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ImpersonationResponse carries an access token acting as User for ImpersonatorID. There is no
+// refresh token: impersonation ends when the token expires.
+type ImpersonationResponse struct {
+	AccessToken    string    `json:"access_token"`
+	TokenType      string    `json:"token_type"`
+	ExpiresIn      int       `json:"expires_in"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	User           *User     `json:"user"`
+	ImpersonatorID uuid.UUID `json:"impersonator_id"`
+}
+
+// StepUpRequest re-authenticates a signed-in user with exactly one of: their password, the OTP sent by
+// /auth/step-up/send-otp, or a 2FA code (authenticator app or backup code)
+type StepUpRequest struct {
+	Password string `json:"password,omitempty"`
+	OTP      string `json:"otp,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// StepUpResponse carries an access token whose auth_time is AuthTime. The refresh token is unchanged,
+// so access tokens refreshed later keep the sign-in's auth_time.
+type StepUpResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	AuthTime    time.Time `json:"auth_time"`
+	Method      string    `json:"method"` // password, otp or 2fa
+}
+
 // OTP models
 type SendOTPRequest struct {
 	Email string `json:"email" binding:"required,email"`
@@ -238,6 +438,7 @@ type SendOTPRequest struct {
 type SendOTPResponse struct {
 	Message string `json:"message"`
 	Email   string `json:"email"`
+	Channel string `json:"channel,omitempty"` // "email" or "sms": where the code went
 }
 
 type VerifyOTPRequest struct {
@@ -249,6 +450,19 @@ type ResendOTPRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
 
+// Channels sign-in OTP codes are delivered on
+const (
+	OTPChannelEmail = "email"
+	OTPChannelSMS   = "sms"
+)
+
+// UserMetadataOTPChannel is the users.metadata key holding the user's preferred OTP channel
+const UserMetadataOTPChannel = "otp_channel"
+
+type SetOTPChannelRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email sms"`
+}
+
 // AuditLog models
 type AuditLog struct {
 	ID           int64                  `json:"id"`
@@ -279,9 +493,21 @@ type OrgInvitation struct {
 	AcceptedBy  *uuid.UUID `json:"accepted_by,omitempty"`
 	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
 	CancelledBy *uuid.UUID `json:"cancelled_by,omitempty"`
+	Status      string     `json:"status"` // pending, accepted, cancelled or expired
 }
 
+// Invitation statuses, derived from the timestamps
+const (
+	InvitationPending   = "pending"
+	InvitationAccepted  = "accepted"
+	InvitationCancelled = "cancelled"
+	InvitationExpired   = "expired"
+)
+
+// CreateInvitationRequest invites an email into the org with a role of that org. OrgID is for super
+// admins; ExpiresAt defaults to INVITATION_TTL days.
 type CreateInvitationRequest struct {
+	OrgID     *uuid.UUID `json:"org_id,omitempty"`
 	Email     string     `json:"email" binding:"required,email"`
 	RoleID    uuid.UUID  `json:"role_id" binding:"required"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
@@ -432,6 +658,31 @@ type AssignFolderPermissionRequest struct {
 	Permission string    `json:"permission" binding:"required,oneof=read write delete move share"`
 }
 
+type FolderMetadataRule struct {
+	ID                uuid.UUID              `json:"id"`
+	FolderID          uuid.UUID              `json:"folder_id"`
+	Tags              []string               `json:"tags"`
+	DefaultMetadata   map[string]interface{} `json:"default_metadata"`
+	ApplyToSubfolders bool                   `json:"apply_to_subfolders"`
+	CreatedBy         *uuid.UUID             `json:"created_by,omitempty"`
+	UpdatedBy         *uuid.UUID             `json:"updated_by,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+	// Joined fields
+	FolderPath *string `json:"folder_path,omitempty"`
+}
+
+type SetFolderMetadataRuleRequest struct {
+	Tags              []string               `json:"tags"`
+	DefaultMetadata   map[string]interface{} `json:"default_metadata"`
+	ApplyToSubfolders *bool                  `json:"apply_to_subfolders"`
+}
+
+type ApplyFolderMetadataRulesRequest struct {
+	// Overwrite replaces existing document values with rule defaults instead of only filling missing keys
+	Overwrite bool `json:"overwrite"`
+}
+
 // File models
 type File struct {
 	ID         int64      `json:"id"` // Changed from uuid.UUID to int64 to match documents table
@@ -472,6 +723,25 @@ type FileUploadResponse struct {
 	Message   string  `json:"message"`
 }
 
+// PresignUploadRequest reserves a document for a file uploaded straight to storage
+type PresignUploadRequest struct {
+	Filename    string                 `json:"filename" binding:"required"`
+	SizeBytes   int64                  `json:"size_bytes" binding:"required"`
+	ContentType string                 `json:"content_type"` // The upload must send it; default from the extension
+	FolderID    *uuid.UUID             `json:"folder_id,omitempty"`
+	OrgID       *uuid.UUID             `json:"org_id,omitempty"` // Optional: for super admins to specify org
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// CreateUploadSessionRequest opens a resumable upload; the file is then sent in chunks
+type CreateUploadSessionRequest struct {
+	Filename  string                 `json:"filename" binding:"required"`
+	SizeBytes int64                  `json:"size_bytes" binding:"required"`
+	FolderID  *uuid.UUID             `json:"folder_id,omitempty"`
+	OrgID     *uuid.UUID             `json:"org_id,omitempty"` // Optional: for super admins to specify org
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
 // Screener (Settings) models
 type Screener struct {
 	ID           uuid.UUID  `json:"id"`
@@ -504,3 +774,788 @@ type ListScreenersResponse struct {
 	Status string      `json:"status"`
 	Data   []*Screener `json:"data"`
 }
+
+// API usage models
+type APIUsageRollup struct {
+	BucketStart    time.Time  `json:"bucket_start"`
+	OrgID          *uuid.UUID `json:"org_id,omitempty"`
+	Method         string     `json:"method"`
+	Route          string     `json:"route"`
+	RequestCount   int64      `json:"request_count"`
+	ErrorCount     int64      `json:"error_count"`
+	TotalLatencyMs int64      `json:"total_latency_ms"`
+	MaxLatencyMs   int64      `json:"max_latency_ms"`
+	// LatencyBuckets holds request counts per upper bound in APIUsageLatencyBoundsMs,
+	// with one trailing overflow bucket
+	LatencyBuckets []int64 `json:"-"`
+}
+
+// APIUsageLatencyBoundsMs are the histogram upper bounds used for API usage latency tracking
+var APIUsageLatencyBoundsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+type APIUsageReportRow struct {
+	PeriodStart  *time.Time `json:"period_start,omitempty"` // Set when the report is grouped by day or month
+	OrgID        *uuid.UUID `json:"org_id,omitempty"`
+	OrgName      *string    `json:"org_name,omitempty"`
+	Method       string     `json:"method"`
+	Route        string     `json:"route"`
+	RequestCount int64      `json:"request_count"`
+	ErrorCount   int64      `json:"error_count"`
+	ErrorRate    float64    `json:"error_rate"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	P95LatencyMs int64      `json:"p95_latency_ms"`
+	MaxLatencyMs int64      `json:"max_latency_ms"`
+}
+
+type APIUsageReportFilter struct {
+	From     time.Time
+	To       time.Time
+	OrgID    *uuid.UUID
+	Route    *string
+	SortBy   string
+	Limit    int
+	Interval string // "", "day" or "month"
+	Timezone string // IANA name periods are computed in; defaults to UTC
+}
+
+// Access review models
+type AccessReview struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	OrgID       *uuid.UUID          `json:"org_id,omitempty"` // nil: every organization
+	Users       []*AccessReviewUser `json:"users"`
+}
+
+// AccessReviewUser is one user's access at the time of the review. Super admins are listed in every
+// organization-scoped review, because they can access all organizations.
+type AccessReviewUser struct {
+	UserID       uuid.UUID                 `json:"user_id"`
+	OrgID        *uuid.UUID                `json:"org_id,omitempty"`
+	OrgName      *string                   `json:"org_name,omitempty"`
+	Email        string                    `json:"email"`
+	FullName     string                    `json:"full_name"`
+	Status       string                    `json:"status"`
+	OrgRole      *string                   `json:"org_role,omitempty"`
+	IsSuperAdmin bool                      `json:"is_super_admin"`
+	LastLoginAt  *time.Time                `json:"last_login_at,omitempty"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	Roles        []AccessReviewRole        `json:"roles"`
+	Permissions  []string                  `json:"permissions"` // Effective "resource:action" from all active roles
+	FolderGrants []AccessReviewFolderGrant `json:"folder_grants"`
+}
+
+type AccessReviewRole struct {
+	RoleID     uuid.UUID  `json:"role_id"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	AssignedAt time.Time  `json:"assigned_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+type AccessReviewFolderGrant struct {
+	FolderID   uuid.UUID `json:"folder_id"`
+	FolderPath string    `json:"folder_path"`
+	Permission string    `json:"permission"`
+	ViaRole    string    `json:"via_role"`
+}
+
+// AccessReviewJob tracks an asynchronous access review export
+type AccessReviewJob struct {
+	ID          uuid.UUID  `json:"id"`
+	OrgID       *uuid.UUID `json:"org_id,omitempty"`
+	Format      string     `json:"format"`     // json or csv
+	Status      string     `json:"status"`     // running, completed or failed
+	UserCount   int        `json:"user_count"` // Set once completed
+	Error       string     `json:"error,omitempty"`
+	RequestedBy uuid.UUID  `json:"requested_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // The export is discarded afterwards
+}
+
+// Search feedback models
+type SearchFeedback struct {
+	ID         int64      `json:"id"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	UserID     uuid.UUID  `json:"user_id"`
+	DocumentID int64      `json:"document_id"`
+	ChunkID    string     `json:"chunk_id"`
+	Query      string     `json:"query"`
+	QueryID    *string    `json:"query_id,omitempty"`
+	Helpful    bool       `json:"helpful"`
+	Comment    *string    `json:"comment,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CreateSearchFeedbackRequest struct {
+	DocumentID int64   `json:"document_id" binding:"required"`
+	ChunkID    string  `json:"chunk_id" binding:"required"`
+	Query      string  `json:"query" binding:"required"`
+	QueryID    *string `json:"query_id,omitempty"`
+	Helpful    *bool   `json:"helpful" binding:"required"`
+	Comment    *string `json:"comment,omitempty"`
+}
+
+// SearchFeedbackChunkStats aggregates votes for one chunk; Score is SearchFeedbackScore of the counts
+type SearchFeedbackChunkStats struct {
+	ChunkID        string    `json:"chunk_id"`
+	HelpfulCount   int64     `json:"helpful_count"`
+	UnhelpfulCount int64     `json:"unhelpful_count"`
+	Score          float64   `json:"score"`
+	LastFeedbackAt time.Time `json:"last_feedback_at"`
+}
+
+type SearchFeedbackDocumentStats struct {
+	DocumentID     int64                       `json:"document_id"`
+	DocumentName   string                      `json:"document_name"`
+	HelpfulCount   int64                       `json:"helpful_count"`
+	UnhelpfulCount int64                       `json:"unhelpful_count"`
+	Score          float64                     `json:"score"`
+	Chunks         []*SearchFeedbackChunkStats `json:"chunks,omitempty"`
+}
+
+// SearchFeedbackScore is (helpful - unhelpful) / (votes + 2), in (-1, 1). The +2 prior keeps a single
+// vote from dominating, so a search scorer can use it as a boost/penalty without trusting sparse feedback.
+func SearchFeedbackScore(helpful, unhelpful int64) float64 {
+	return float64(helpful-unhelpful) / float64(helpful+unhelpful+2)
+}
+
+// Subscription models
+type SubscriptionPlanLimits struct {
+	Rank         int      `json:"rank"` // Orders plans for upgrade/downgrade checks
+	MaxUsers     int      `json:"max_users"`
+	MaxStorageGB int      `json:"max_storage_gb"`
+	Features     []string `json:"features"` // Plan features the org may use, see policy.HasFeature
+}
+
+// Plan features, gated per endpoint with FeatureMiddleware.RequireFeature and overridden per org with
+// feature flags
+const (
+	FeatureDocuments      = "documents"       // the documents API: listing, searching and downloading
+	FeatureDocumentUpload = "document_upload" // uploading, importing and replacing documents
+	FeatureScreeners      = "screeners"       // saving and running screeners
+	FeatureMCP            = "mcp"             // the MCP document search server, checked by it through introspection
+)
+
+type FeatureDefinition struct {
+	Name        string
+	Description string
+}
+
+// Features describes every plan feature, in the order GET /features lists them
+var Features = []FeatureDefinition{
+	{FeatureDocuments, "List, search and download documents"},
+	{FeatureDocumentUpload, "Upload, import and replace documents"},
+	{FeatureScreeners, "Save and run screeners"},
+	{FeatureMCP, "Search documents from LibreChat through the MCP server"},
+}
+
+// FeatureFlag grants (enabled) or withdraws a feature for one org whatever its plan
+type FeatureFlag struct {
+	OrgID     uuid.UUID  `json:"org_id"`
+	Feature   string     `json:"feature"`
+	Enabled   bool       `json:"enabled"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// FeatureState tells whether an org has a feature and why: "plan", "flag" or "super_admin"
+type FeatureState struct {
+	Feature     string `json:"feature"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"`
+	InPlan      bool   `json:"in_plan"` // Whether the plan includes it, whatever the flag says
+}
+
+// OrgFeatures lists the features of an org (GET /features)
+type OrgFeatures struct {
+	OrgID    *uuid.UUID      `json:"org_id,omitempty"`
+	Plan     string          `json:"plan,omitempty"`
+	Features []*FeatureState `json:"features"`
+}
+
+// SetFeatureFlagRequest sets an org's feature flag; PUT /organizations/:id/features/:feature
+type SetFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SubscriptionPlans lists the plans of the subscription_plan enum with the limits applied on a plan change.
+// "trial" ranks with starter so converting a trial to any paid plan counts as an upgrade.
+var SubscriptionPlans = map[string]SubscriptionPlanLimits{
+	"free":       {Rank: 0, MaxUsers: 5, MaxStorageGB: 1, Features: []string{FeatureDocuments, FeatureScreeners}},
+	"trial":      {Rank: 1, MaxUsers: 10, MaxStorageGB: 5, Features: []string{FeatureDocuments, FeatureDocumentUpload, FeatureScreeners, FeatureMCP}},
+	"starter":    {Rank: 1, MaxUsers: 25, MaxStorageGB: 10, Features: []string{FeatureDocuments, FeatureDocumentUpload, FeatureScreeners, FeatureMCP}},
+	"pro":        {Rank: 2, MaxUsers: 100, MaxStorageGB: 100, Features: []string{FeatureDocuments, FeatureDocumentUpload, FeatureScreeners, FeatureMCP}},
+	"enterprise": {Rank: 3, MaxUsers: 1000, MaxStorageGB: 1000, Features: []string{FeatureDocuments, FeatureDocumentUpload, FeatureScreeners, FeatureMCP}},
+}
+
+type ChangeSubscriptionRequest struct {
+	Plan string `json:"plan" binding:"required"`
+}
+
+type CancelSubscriptionRequest struct {
+	Reason    *string `json:"reason,omitempty"`
+	Immediate bool    `json:"immediate"` // End now instead of at subscription_ends_at
+}
+
+// BillingEvent is emitted for every subscription change (recorded in audit_logs as "subscription.<type>")
+type BillingEvent struct {
+	Type       string     `json:"type"` // upgraded, downgraded, trial_converted, cancelled
+	OrgID      uuid.UUID  `json:"org_id"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+	FromPlan   string     `json:"from_plan"`
+	ToPlan     string     `json:"to_plan"`
+	FromStatus string     `json:"from_status"`
+	ToStatus   string     `json:"to_status"`
+	Reason     *string    `json:"reason,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+// Org secret models. The value is write-only: responses carry metadata, never the secret itself.
+type OrgSecret struct {
+	ID          uuid.UUID  `json:"id"`
+	OrgID       uuid.UUID  `json:"org_id"`
+	Name        string     `json:"name"`
+	Description *string    `json:"description,omitempty"`
+	Version     int        `json:"version"`
+	KeyID       string     `json:"-"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedBy   *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	RotatedAt   *time.Time `json:"rotated_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+type CreateOrgSecretRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Value       string  `json:"value" binding:"required,max=16384"`
+	Description *string `json:"description,omitempty"`
+}
+
+type RotateOrgSecretRequest struct {
+	Value string `json:"value" binding:"required,max=16384"`
+}
+
+// ResolveOrgSecretRequest is sent by trusted services (the LibreChat proxy) with X-Proxy-Secret
+type ResolveOrgSecretRequest struct {
+	OrgID uuid.UUID `json:"org_id" binding:"required"`
+	Name  string    `json:"name" binding:"required"`
+}
+
+// ProxyLoginEventRequest is a login attempt on the LibreChat proxy, sent with X-Proxy-Secret. Email
+// is empty when the attempt was rejected before its body was read.
+type ProxyLoginEventRequest struct {
+	Email     string `json:"email" binding:"omitempty,max=255"`
+	IPAddress string `json:"ip_address" binding:"omitempty,max=64"`
+	UserAgent string `json:"user_agent" binding:"omitempty,max=1024"`
+	Outcome   string `json:"outcome" binding:"required,oneof=success failure"`
+	Reason    string `json:"reason" binding:"omitempty,max=100"`
+}
+
+// OIDC single sign-on models. An org has at most one provider; its client secret is an org secret
+// referenced by ClientSecretName.
+type OrgOIDCProvider struct {
+	ID               uuid.UUID        `json:"id"`
+	OrgID            uuid.UUID        `json:"org_id"`
+	IssuerURL        string           `json:"issuer_url"`
+	ClientID         string           `json:"client_id"`
+	ClientSecretName *string          `json:"client_secret_name,omitempty"`
+	Scopes           []string         `json:"scopes"`
+	AllowedDomains   []string         `json:"allowed_domains"`
+	AutoProvision    bool             `json:"auto_provision"`
+	DefaultOrgRole   string           `json:"default_org_role"`
+	RoleClaim        string           `json:"role_claim"`
+	RoleMappings     []SSORoleMapping `json:"role_mappings"`
+	Enabled          bool             `json:"enabled"`
+	CreatedBy        *uuid.UUID       `json:"created_by,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedBy        *uuid.UUID       `json:"updated_by,omitempty"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// SSORoleMapping applies when the provider's role claim (OIDC) or role attribute (SAML) holds Claim.
+// The first matching mapping with an OrgRole sets the user's org role; Role names a role in the org
+// granted while the value matches.
+type SSORoleMapping struct {
+	Claim   string  `json:"claim" binding:"required"`
+	OrgRole *string `json:"org_role,omitempty" binding:"omitempty,oneof=admin user viewer"`
+	Role    *string `json:"role,omitempty"`
+}
+
+// SetOrgOIDCProviderRequest creates or replaces an org's provider. Omitted fields default to scopes
+// openid, email and profile, any email domain, auto provisioning, org role "user", role claim "groups"
+// and enabled.
+type SetOrgOIDCProviderRequest struct {
+	IssuerURL        string           `json:"issuer_url" binding:"required,url"`
+	ClientID         string           `json:"client_id" binding:"required,max=255"`
+	ClientSecretName *string          `json:"client_secret_name,omitempty" binding:"omitempty,max=100"`
+	Scopes           []string         `json:"scopes,omitempty"`
+	AllowedDomains   []string         `json:"allowed_domains,omitempty"`
+	AutoProvision    *bool            `json:"auto_provision,omitempty"`
+	DefaultOrgRole   *string          `json:"default_org_role,omitempty" binding:"omitempty,oneof=admin user viewer"`
+	RoleClaim        *string          `json:"role_claim,omitempty" binding:"omitempty,max=100"`
+	RoleMappings     []SSORoleMapping `json:"role_mappings,omitempty" binding:"dive"`
+	Enabled          *bool            `json:"enabled,omitempty"`
+}
+
+// SAML single sign-on models. IdPMetadata is the identity provider's metadata XML as uploaded (or
+// fetched); the service provider fields are derived from the org and not stored.
+type OrgSAMLProvider struct {
+	ID                 uuid.UUID        `json:"id"`
+	OrgID              uuid.UUID        `json:"org_id"`
+	IdPMetadata        string           `json:"idp_metadata"`
+	IdPEntityID        string           `json:"idp_entity_id"`
+	IdPSSOURL          string           `json:"idp_sso_url"`
+	EmailAttribute     *string          `json:"email_attribute,omitempty"`
+	FirstNameAttribute *string          `json:"first_name_attribute,omitempty"`
+	LastNameAttribute  *string          `json:"last_name_attribute,omitempty"`
+	RoleAttribute      string           `json:"role_attribute"`
+	RoleMappings       []SSORoleMapping `json:"role_mappings"`
+	AllowedDomains     []string         `json:"allowed_domains"`
+	AutoProvision      bool             `json:"auto_provision"`
+	DefaultOrgRole     string           `json:"default_org_role"`
+	Enforced           bool             `json:"enforced"`
+	Enabled            bool             `json:"enabled"`
+	CreatedBy          *uuid.UUID       `json:"created_by,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedBy          *uuid.UUID       `json:"updated_by,omitempty"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	SPEntityID         string           `json:"sp_entity_id"`
+	SPACSURL           string           `json:"sp_acs_url"`
+}
+
+// SetOrgSAMLProviderRequest creates or replaces an org's provider from IdP metadata, given inline or
+// as a URL to fetch it from. Omitted attributes are looked up under their common names (email, mail,
+// givenName, sn and their OID and claims URI forms, falling back to an email NameID). Other omitted
+// fields default to role attribute "groups", any email domain, auto provisioning, org role "user",
+// not enforced and enabled.
+type SetOrgSAMLProviderRequest struct {
+	IdPMetadata        *string          `json:"idp_metadata,omitempty" binding:"required_without=IdPMetadataURL"`
+	IdPMetadataURL     *string          `json:"idp_metadata_url,omitempty" binding:"omitempty,url"`
+	EmailAttribute     *string          `json:"email_attribute,omitempty" binding:"omitempty,max=255"`
+	FirstNameAttribute *string          `json:"first_name_attribute,omitempty" binding:"omitempty,max=255"`
+	LastNameAttribute  *string          `json:"last_name_attribute,omitempty" binding:"omitempty,max=255"`
+	RoleAttribute      *string          `json:"role_attribute,omitempty" binding:"omitempty,max=255"`
+	RoleMappings       []SSORoleMapping `json:"role_mappings,omitempty" binding:"dive"`
+	AllowedDomains     []string         `json:"allowed_domains,omitempty"`
+	AutoProvision      *bool            `json:"auto_provision,omitempty"`
+	DefaultOrgRole     *string          `json:"default_org_role,omitempty" binding:"omitempty,oneof=admin user viewer"`
+	Enforced           *bool            `json:"enforced,omitempty"`
+	Enabled            *bool            `json:"enabled,omitempty"`
+}
+
+// UserIdentity.Provider values, one per kind of org identity provider
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderSAML = "saml"
+)
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Provider    string     `json:"provider"`
+	ProviderID  uuid.UUID  `json:"provider_id"`
+	Subject     string     `json:"subject"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// UserTOTP is a user's TOTP authenticator; the secret itself is only handled sealed (see
+// repositories.TwoFactorRepository). 2FA is on once ConfirmedAt is set.
+type UserTOTP struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	KeyID        string     `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep *int64     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TwoFactorStatus is the caller's 2FA state. Required is set when the user's org requires 2FA, in
+// which case it cannot be turned off.
+type TwoFactorStatus struct {
+	Enabled              bool       `json:"enabled"`
+	EnabledAt            *time.Time `json:"enabled_at,omitempty"`
+	Pending              bool       `json:"pending"` // setup started but not confirmed
+	Required             bool       `json:"required"`
+	BackupCodesRemaining int        `json:"backup_codes_remaining"`
+}
+
+// TwoFactorSetupResponse is a new authenticator secret; QRCode is a PNG data URL of OTPAuthURL
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	QRCode     string `json:"qr_code"`
+}
+
+// TwoFactorCodeRequest carries a code from the authenticator app, or a backup code where accepted
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorVerifyRequest completes a sign-in that returned mfa_required
+type TwoFactorVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollRequest starts enrollment for a sign-in that returned mfa_enrollment_required
+type TwoFactorEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// BackupCodesResponse lists new backup codes; they are shown once and stored hashed
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// TwoFactorEnrollResponse completes an enrollment required at sign-in: the tokens and the backup codes
+type TwoFactorEnrollResponse struct {
+	*LoginResponse
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// UserPasskey is a WebAuthn credential a user signs in with. Credential is the serialized
+// credential record, only read by the passkey service.
+type UserPasskey struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	CredentialID   []byte     `json:"-"`
+	Credential     []byte     `json:"-"`
+	Name           string     `json:"name"`
+	AAGUID         *uuid.UUID `json:"aaguid,omitempty"`
+	Transports     []string   `json:"transports"`
+	Attachment     *string    `json:"attachment,omitempty"`
+	BackupEligible bool       `json:"backup_eligible"` // synced passkey
+	UserAgent      *string    `json:"user_agent,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP     *string    `json:"last_used_ip,omitempty"`
+}
+
+// PasskeyCeremonyResponse starts a registration or sign-in. Options is {"publicKey": {...}} with
+// binary fields base64url encoded (PublicKeyCredential.parseCreationOptionsFromJSON() and
+// parseRequestOptionsFromJSON() read them); SessionToken comes back with the browser's result.
+type PasskeyCeremonyResponse struct {
+	Options      interface{} `json:"options"`
+	SessionToken string      `json:"session_token"`
+}
+
+// FinishPasskeyRegistrationRequest carries the browser's PublicKeyCredential from navigator.credentials.create()
+type FinishPasskeyRegistrationRequest struct {
+	SessionToken string          `json:"session_token" binding:"required"`
+	Name         string          `json:"name" binding:"max=100"`
+	Credential   json.RawMessage `json:"credential" binding:"required"`
+}
+
+// FinishPasskeyLoginRequest carries the browser's PublicKeyCredential from navigator.credentials.get()
+type FinishPasskeyLoginRequest struct {
+	SessionToken string          `json:"session_token" binding:"required"`
+	Credential   json.RawMessage `json:"credential" binding:"required"`
+}
+
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// APIKey is a key an integration authenticates with instead of a user token. It acts as UserID
+// (a service account, or the user who created it) and only within Scopes.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	OrgID      uuid.UUID  `json:"org_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  *uuid.UUID `json:"revoked_by,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyRequest issues a key. UserID is the service account the key acts as (defaults to the
+// caller); Scopes are resource:action pairs the user must hold.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	OrgID     *uuid.UUID `json:"org_id,omitempty"` // super admins only
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse carries the key itself, which cannot be retrieved again
+type CreateAPIKeyResponse struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells them apart from JWTs
+// in an `Authorization: Bearer` header
+const PersonalAccessTokenPrefix = "pat_"
+
+// PersonalAccessToken is a long-lived token a user mints for their own scripts and tools. It acts
+// as UserID, only within Scopes, and never with super admin rights. OrgID is the user's org when
+// it was minted.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  *uuid.UUID `json:"revoked_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatePersonalAccessTokenRequest mints a token; Scopes are resource:action pairs the user must hold
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatePersonalAccessTokenResponse carries the token itself, which cannot be retrieved again
+type CreatePersonalAccessTokenResponse struct {
+	*PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// OAuthClient is a confidential client that gets access tokens with the OAuth2 client_credentials
+// grant. Its ID is the client_id. Like an API key it acts as UserID, and only within Scopes.
+type OAuthClient struct {
+	ID           uuid.UUID  `json:"client_id"`
+	OrgID        uuid.UUID  `json:"org_id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	Name         string     `json:"name"`
+	SecretPrefix string     `json:"secret_prefix"`
+	SecretHash   string     `json:"-"`
+	Scopes       []string   `json:"scopes"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP   *string    `json:"last_used_ip,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *uuid.UUID `json:"revoked_by,omitempty"`
+	CreatedBy    *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CreateOAuthClientRequest registers a client. UserID is the service account its tokens act as
+// (defaults to the caller); Scopes are resource:action pairs the user must hold.
+type CreateOAuthClientRequest struct {
+	Name   string     `json:"name" binding:"required,max=100"`
+	Scopes []string   `json:"scopes" binding:"required,min=1"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	OrgID  *uuid.UUID `json:"org_id,omitempty"` // super admins only
+}
+
+// CreateOAuthClientResponse carries the client secret, which cannot be retrieved again
+type CreateOAuthClientResponse struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret"`
+}
+
+// OAuthTokenRequest is a token request (RFC 6749 section 4.4), sent as a form. The client may
+// authenticate with HTTP Basic instead of client_id and client_secret.
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"` // space-separated; empty asks for all the client's scopes
+}
+
+// OAuthTokenResponse is a successful token response
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+	Scope       string `json:"scope"`
+}
+
+// OAuthErrorResponse is an RFC 6749 error response, which token endpoint clients expect instead of
+// ErrorResponse
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Sign-in methods recorded in login history
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodOTP       = "otp"
+	LoginMethodLoginLink = "login_link"
+	LoginMethodPasskey   = "passkey"
+	LoginMethod2FA       = "2fa"
+)
+
+// LoginEvent is one sign-in attempt on a user's account. FailureReason is the error code of a failed
+// attempt; MFA_REQUIRED when the first factor passed and the second is recorded as its own event.
+type LoginEvent struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	OrgID         *uuid.UUID `json:"org_id,omitempty"`
+	Method        string     `json:"method"` // password, otp, login_link, passkey, 2fa, oidc or saml
+	Success       bool       `json:"success"`
+	FailureReason *string    `json:"failure_reason,omitempty"`
+	IPAddress     *string    `json:"ip_address,omitempty"`
+	UserAgent     *string    `json:"user_agent,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PasswordReset is a reset link sent by email; only the hash of its token is stored
+type PasswordReset struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	IPAddress *string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// EmailVerification is a confirmation link sent to a user's new email address; only the hash of
+// its token is stored
+type EmailVerification struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	TokenHash  string     `json:"-"`
+	Email      string     `json:"email"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email,max=255"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// LoginLink is a one-time sign-in link sent by email; only the hash of its token is stored
+type LoginLink struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	IPAddress *string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type SendLoginLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyLoginLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// SCIM 2.0 (RFC 7643/7644) schema URNs
+const (
+	SCIMSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// UserMetadataSCIMExternalID is the users.metadata key holding the identity provider's externalId
+const UserMetadataSCIMExternalID = "scim_external_id"
+
+// SCIMUser is a user as identity providers provision it. userName is the email address.
+type SCIMUser struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *SCIMName    `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []SCIMEmail  `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Groups      []SCIMMember `json:"groups,omitempty"` // Read-only; membership is managed through Groups
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMGroup is one of the org's roles; its members are the users holding it
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations" binding:"required,min=1"`
+}
+
+// SCIMPatchOperation is one PATCH operation. Value is kept raw because its shape depends on the path,
+// and identity providers differ in it (Azure AD sends booleans as "True"/"False" strings).
+type SCIMPatchOperation struct {
+	Op    string          `json:"op" binding:"required"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"slices"
+	"time"
+
+	"saas-api/internal/models"
+)
+
+// HasFeature decides whether an org may use a plan feature. The org's feature flag wins (see
+// FeatureFlagRepository); otherwise the feature must come with its subscription plan. A cancelled
+// subscription past its subscription_ends_at, or a trial past trial_ends_at, keeps only the free
+// plan's features.
+func HasFeature(org *models.Organization, flags map[string]*models.FeatureFlag, feature string, now time.Time) bool {
+	return Feature(org, flags, feature, now).Enabled
+}
+
+// Feature is HasFeature with the reason, for GET /features
+func Feature(org *models.Organization, flags map[string]*models.FeatureFlag, feature string, now time.Time) *models.FeatureState {
+	plan := org.SubscriptionPlan
+	if subscriptionLapsed(org, now) {
+		plan = "free"
+	}
+	state := &models.FeatureState{
+		Feature: feature,
+		InPlan:  slices.Contains(models.SubscriptionPlans[plan].Features, feature),
+		Source:  "plan",
+	}
+	state.Enabled = state.InPlan
+	if flag, ok := flags[feature]; ok {
+		state.Enabled = flag.Enabled
+		state.Source = "flag"
+	}
+	return state
+}
+
+func subscriptionLapsed(org *models.Organization, now time.Time) bool {
+	switch {
+	case org.SubscriptionStatus == "cancelled":
+		return org.SubscriptionEndsAt == nil || !now.Before(*org.SubscriptionEndsAt)
+	case org.SubscriptionPlan == "trial" || org.SubscriptionStatus == "trialing":
+		return org.TrialEndsAt != nil && !now.Before(*org.TrialEndsAt)
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Package policy centralises tenancy and super admin authorization rules.
+// Handlers and middleware ask the policy for a decision instead of inspecting
+// is_super_admin / org_id themselves, so every rule lives (and is reviewed) here.
+package policy
+
+import (
+	"log"
+	"net/http"
+
+	"saas-api/internal/authctx"
+	"saas-api/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrgContextRequired = errors.NewError("FORBIDDEN", "Organization context required", http.StatusForbidden)
+	ErrCrossOrgAccess     = errors.NewError("FORBIDDEN", "Resource belongs to another organization", http.StatusForbidden)
+	ErrSuperAdminRequired = errors.NewError("FORBIDDEN", "Super admin access required", http.StatusForbidden)
+	ErrOrgRequired        = errors.NewError("VALIDATION_ERROR", "Organization must be specified. Provide 'org_id' in the request", http.StatusBadRequest)
+)
+
+// Subject is the authenticated caller a decision is made for
+type Subject struct {
+	UserID       uuid.UUID
+	OrgID        *uuid.UUID
+	IsSuperAdmin bool
+}
+
+// FromContext builds the subject from the values set by AuthMiddleware
+func FromContext(c *gin.Context) Subject {
+	s := Subject{
+		OrgID:        authctx.CurrentOrg(c),
+		IsSuperAdmin: authctx.IsSuperAdmin(c),
+	}
+	if userID := authctx.UserID(c); userID != nil {
+		s.UserID = *userID
+	}
+	return s
+}
+
+// RequireSuperAdmin allows only super admins
+func (s Subject) RequireSuperAdmin() error {
+	if s.IsSuperAdmin {
+		return nil
+	}
+	return s.deny(ErrSuperAdminRequired, "super admin required", nil)
+}
+
+// CanAccess decides whether the subject may read or modify a resource owned by resourceOrgID.
+// Super admins may access any resource; everyone else only resources in their own org.
+// Resources without an org (system/global) are super admin only.
+func (s Subject) CanAccess(resourceOrgID *uuid.UUID) error {
+	if s.IsSuperAdmin {
+		return nil
+	}
+	if s.OrgID == nil {
+		return s.deny(ErrOrgContextRequired, "no org context", resourceOrgID)
+	}
+	if resourceOrgID == nil || *resourceOrgID != *s.OrgID {
+		return s.deny(ErrCrossOrgAccess, "cross-org access", resourceOrgID)
+	}
+	return nil
+}
+
+// ListScope resolves the org filter for list endpoints.
+// Super admins get the requested org (nil means all orgs); everyone else is pinned to their own org
+// and any requested org is ignored.
+func (s Subject) ListScope(requested *uuid.UUID) (*uuid.UUID, error) {
+	if s.IsSuperAdmin {
+		return requested, nil
+	}
+	if s.OrgID == nil {
+		return nil, s.deny(ErrOrgContextRequired, "no org context", requested)
+	}
+	return s.OrgID, nil
+}
+
+// OwnerOrg resolves the owning org for a new resource.
+// Super admins may target any org, fall back to their own, or create global resources (nil).
+// Everyone else always creates in their own org, regardless of what was requested.
+func (s Subject) OwnerOrg(requested *uuid.UUID) (*uuid.UUID, error) {
+	if s.IsSuperAdmin {
+		if requested != nil {
+			return requested, nil
+		}
+		return s.OrgID, nil
+	}
+	if s.OrgID == nil {
+		return nil, s.deny(ErrOrgContextRequired, "no org context", requested)
+	}
+	return s.OrgID, nil
+}
+
+// TargetOrg is like OwnerOrg but an org is mandatory (e.g. users and folders always belong to an org)
+func (s Subject) TargetOrg(requested *uuid.UUID) (uuid.UUID, error) {
+	orgID, err := s.OwnerOrg(requested)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if orgID == nil {
+		return uuid.Nil, ErrOrgRequired
+	}
+	return *orgID, nil
+}
+
+// DocumentViewer returns the user whose roles' folder permissions limit the documents the subject
+// may list and fetch, or nil for super admins, who see every folder
+func (s Subject) DocumentViewer() *uuid.UUID {
+	if s.IsSuperAdmin {
+		return nil
+	}
+	return &s.UserID
+}
+
+// deny logs every refusal so policy decisions can be audited from one place
+func (s Subject) deny(err *errors.AppError, reason string, resourceOrgID *uuid.UUID) error {
+	target := "none"
+	if resourceOrgID != nil {
+		target = resourceOrgID.String()
+	}
+	log.Printf("policy: denied user=%s reason=%q target_org=%s", s.UserID, reason, target)
+	return err
+}
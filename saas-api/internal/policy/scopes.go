@@ -0,0 +1,20 @@
+package policy
+
+import (
+	"strings"
+
+	"saas-api/internal/models"
+)
+
+// ScopesAllow decides whether API key or OAuth client scopes cover resource:action: a scope must
+// name the pair, or be the resource's wildcard (documents:* covers documents:read). The wildcard
+// itself is only covered by the wildcard.
+func ScopesAllow(scopes []string, resource, action string) bool {
+	for _, scope := range scopes {
+		scopeResource, scopeAction, ok := strings.Cut(scope, ":")
+		if ok && scopeResource == resource && (scopeAction == action || scopeAction == models.PermissionWildcard) {
+			return true
+		}
+	}
+	return false
+}
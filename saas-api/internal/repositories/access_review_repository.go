@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// AccessReviewRepository reads who can access what for compliance access reviews
+type AccessReviewRepository struct {
+	db *database.DB
+}
+
+func NewAccessReviewRepository(db *database.DB) *AccessReviewRepository {
+	return &AccessReviewRepository{db: db}
+}
+
+// accessReviewScope selects the reviewed users: everyone, or an org's users plus all super admins.
+// It is RECURSIVE so queries can add recursive CTEs after it.
+const accessReviewScope = `
+	WITH RECURSIVE scope AS (
+		SELECT id FROM users
+		WHERE deleted_at IS NULL AND ($1::uuid IS NULL OR org_id = $1 OR is_super_admin)
+	)`
+
+// accessReviewHeldRoles follows accessReviewScope with the roles each user in scope holds: the
+// active assignments and the roles those inherit from
+const accessReviewHeldRoles = `,
+	held(user_id, role_id) AS (
+		SELECT ur.user_id, ur.role_id
+		FROM user_roles ur
+		JOIN scope s ON s.id = ur.user_id
+		WHERE ur.expires_at IS NULL OR ur.expires_at > NOW()
+		UNION
+		SELECT h.user_id, rp.parent_role_id FROM role_parents rp JOIN held h ON rp.role_id = h.role_id
+	)`
+
+// Collect returns every user in scope with their active roles, effective permissions and folder
+// grants. Each part is one query over the whole scope, so large orgs cost four round trips.
+func (r *AccessReviewRepository) Collect(ctx context.Context, orgID *uuid.UUID) ([]*models.AccessReviewUser, error) {
+	users, byID, err := r.listUsers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	if err := r.collectRoles(ctx, orgID, byID); err != nil {
+		return nil, err
+	}
+	if err := r.collectPermissions(ctx, orgID, byID); err != nil {
+		return nil, err
+	}
+	if err := r.collectFolderGrants(ctx, orgID, byID); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *AccessReviewRepository) listUsers(ctx context.Context, orgID *uuid.UUID) ([]*models.AccessReviewUser, map[uuid.UUID]*models.AccessReviewUser, error) {
+	query := accessReviewScope + `
+		SELECT u.id, u.org_id, o.name, u.email, u.full_name, u.status, u.org_role,
+			u.is_super_admin, u.last_login_at, u.created_at
+		FROM users u
+		JOIN scope s ON s.id = u.id
+		LEFT JOIN organizations o ON o.id = u.org_id
+		ORDER BY o.name NULLS FIRST, u.email
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list users for access review", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	users := make([]*models.AccessReviewUser, 0)
+	byID := make(map[uuid.UUID]*models.AccessReviewUser)
+	for rows.Next() {
+		user := &models.AccessReviewUser{
+			Roles:        []models.AccessReviewRole{},
+			Permissions:  []string{},
+			FolderGrants: []models.AccessReviewFolderGrant{},
+		}
+		if err := rows.Scan(
+			&user.UserID, &user.OrgID, &user.OrgName, &user.Email, &user.FullName, &user.Status,
+			&user.OrgRole, &user.IsSuperAdmin, &user.LastLoginAt, &user.CreatedAt,
+		); err != nil {
+			return nil, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan access review user", errors.ErrInternalServer.Status)
+		}
+		users = append(users, user)
+		byID[user.UserID] = user
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate access review users", errors.ErrInternalServer.Status)
+	}
+	return users, byID, nil
+}
+
+func (r *AccessReviewRepository) collectRoles(ctx context.Context, orgID *uuid.UUID, byID map[uuid.UUID]*models.AccessReviewUser) error {
+	query := accessReviewScope + `
+		SELECT ur.user_id, ro.id, ro.name, ro.type, ur.assigned_at, ur.expires_at
+		FROM user_roles ur
+		JOIN scope s ON s.id = ur.user_id
+		JOIN roles ro ON ro.id = ur.role_id
+		WHERE ur.expires_at IS NULL OR ur.expires_at > NOW()
+		ORDER BY ur.user_id, ro.name
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to list roles for access review", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var role models.AccessReviewRole
+		if err := rows.Scan(&userID, &role.RoleID, &role.Name, &role.Type, &role.AssignedAt, &role.ExpiresAt); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan access review role", errors.ErrInternalServer.Status)
+		}
+		if user, ok := byID[userID]; ok {
+			user.Roles = append(user.Roles, role)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate access review roles", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+func (r *AccessReviewRepository) collectPermissions(ctx context.Context, orgID *uuid.UUID, byID map[uuid.UUID]*models.AccessReviewUser) error {
+	query := accessReviewScope + accessReviewHeldRoles + `
+		SELECT DISTINCT h.user_id, p.resource, p.action
+		FROM held h
+		JOIN role_permissions rp ON rp.role_id = h.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY h.user_id, p.resource, p.action
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to list permissions for access review", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var resource, action string
+		if err := rows.Scan(&userID, &resource, &action); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan access review permission", errors.ErrInternalServer.Status)
+		}
+		if user, ok := byID[userID]; ok {
+			user.Permissions = append(user.Permissions, fmt.Sprintf("%s:%s", resource, action))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate access review permissions", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// collectFolderGrants lists folder permissions granted to the users' roles, held or inherited, as
+// document access checks them. In an org-scoped review only that org's folders are listed, also for
+// super admins from other orgs.
+func (r *AccessReviewRepository) collectFolderGrants(ctx context.Context, orgID *uuid.UUID, byID map[uuid.UUID]*models.AccessReviewUser) error {
+	query := accessReviewScope + accessReviewHeldRoles + `
+		SELECT h.user_id, f.id, f.path, fp.permission, ro.name
+		FROM held h
+		JOIN folder_permissions fp ON fp.role_id = h.role_id
+		JOIN folders f ON f.id = fp.folder_id
+		JOIN roles ro ON ro.id = h.role_id
+		WHERE $1::uuid IS NULL OR f.org_id = $1
+		ORDER BY h.user_id, f.path, fp.permission
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to list folder grants for access review", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var grant models.AccessReviewFolderGrant
+		if err := rows.Scan(&userID, &grant.FolderID, &grant.FolderPath, &grant.Permission, &grant.ViaRole); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan access review folder grant", errors.ErrInternalServer.Status)
+		}
+		if user, ok := byID[userID]; ok {
+			user.FolderGrants = append(user.FolderGrants, grant)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate access review folder grants", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKeyRepository stores API keys by the SHA-256 hash of the key (see services.APIKeyService)
+type APIKeyRepository struct {
+	db *database.DB
+}
+
+func NewAPIKeyRepository(db *database.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, org_id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, last_used_ip,
+	revoked_at, revoked_by, created_by, created_at`
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(
+		&key.ID, &key.OrgID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes, &key.ExpiresAt,
+		&key.LastUsedAt, &key.LastUsedIP, &key.RevokedAt, &key.RevokedBy, &key.CreatedBy, &key.CreatedAt,
+	)
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (org_id, user_id, name, prefix, key_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + apiKeyColumns
+
+	err := scanAPIKey(r.db.Pool.QueryRow(ctx, query,
+		key.OrgID, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt, key.CreatedBy,
+	), key)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewError("CONFLICT", "API key collision, please retry", errors.ErrConflict.Status)
+		}
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create API key", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// GetByHash returns the key with this hash, including revoked and expired keys
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key := &models.APIKey{}
+	err := scanAPIKey(r.db.Pool.QueryRow(ctx, query, keyHash), key)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get API key", errors.ErrInternalServer.Status)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, orgID, id uuid.UUID) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1 AND org_id = $2`
+
+	key := &models.APIKey{}
+	err := scanAPIKey(r.db.Pool.QueryRow(ctx, query, id, orgID), key)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get API key", errors.ErrInternalServer.Status)
+	}
+
+	return key, nil
+}
+
+// List returns an org's keys, newest first
+func (r *APIKeyRepository) List(ctx context.Context, orgID uuid.UUID) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE org_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list API keys", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key := &models.APIKey{}
+		if err := scanAPIKey(rows, key); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan API key", errors.ErrInternalServer.Status)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke marks a key revoked; revoking an already revoked key returns errors.ErrNotFound
+func (r *APIKeyRepository) Revoke(ctx context.Context, orgID, id uuid.UUID, revokedBy *uuid.UUID) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys SET revoked_at = NOW(), revoked_by = $1
+		WHERE id = $2 AND org_id = $3 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+
+	key := &models.APIKey{}
+	err := scanAPIKey(r.db.Pool.QueryRow(ctx, query, revokedBy, id, orgID), key)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke API key", errors.ErrInternalServer.Status)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, ipAddress string) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $1 WHERE id = $2`, ipAddress, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update API key", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
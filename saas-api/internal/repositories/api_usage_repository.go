@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+)
+
+type APIUsageRepository struct {
+	db *database.DB
+}
+
+func NewAPIUsageRepository(db *database.DB) *APIUsageRepository {
+	return &APIUsageRepository{db: db}
+}
+
+// UpsertRollups merges in-memory rollups into api_usage_rollups, adding counts and latency histograms.
+// The batch is applied in a single transaction so a failed flush can be retried without double counting.
+func (r *APIUsageRepository) UpsertRollups(ctx context.Context, rollups []*models.APIUsageRollup) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to start transaction", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO api_usage_rollups (
+			bucket_start, org_id, method, route, request_count, error_count,
+			total_latency_ms, max_latency_ms, latency_buckets
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (bucket_start, COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), method, route)
+		DO UPDATE SET
+			request_count = api_usage_rollups.request_count + EXCLUDED.request_count,
+			error_count = api_usage_rollups.error_count + EXCLUDED.error_count,
+			total_latency_ms = api_usage_rollups.total_latency_ms + EXCLUDED.total_latency_ms,
+			max_latency_ms = GREATEST(api_usage_rollups.max_latency_ms, EXCLUDED.max_latency_ms),
+			latency_buckets = ARRAY(
+				SELECT COALESCE(a, 0) + COALESCE(b, 0)
+				FROM unnest(api_usage_rollups.latency_buckets, EXCLUDED.latency_buckets) AS t(a, b)
+			),
+			updated_at = NOW()
+	`
+
+	for _, rollup := range rollups {
+		_, err := tx.Exec(ctx, query,
+			rollup.BucketStart,
+			rollup.OrgID,
+			rollup.Method,
+			rollup.Route,
+			rollup.RequestCount,
+			rollup.ErrorCount,
+			rollup.TotalLatencyMs,
+			rollup.MaxLatencyMs,
+			rollup.LatencyBuckets,
+		)
+		if err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to upsert API usage rollup", errors.ErrInternalServer.Status)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// Report aggregates rollups in the requested window per org and endpoint
+func (r *APIUsageRepository) Report(ctx context.Context, filter models.APIUsageReportFilter) ([]*models.APIUsageReportRow, error) {
+	whereClause := "WHERE bucket_start >= $1 AND bucket_start < $2"
+	args := []interface{}{filter.From, filter.To}
+	argIndex := 3
+
+	if filter.OrgID != nil {
+		whereClause += fmt.Sprintf(" AND org_id = $%d", argIndex)
+		args = append(args, *filter.OrgID)
+		argIndex++
+	}
+
+	if filter.Route != nil && *filter.Route != "" {
+		whereClause += fmt.Sprintf(" AND route = $%d", argIndex)
+		args = append(args, *filter.Route)
+		argIndex++
+	}
+
+	orderBy := "request_count DESC"
+	switch filter.SortBy {
+	case "errors":
+		orderBy = "error_count DESC"
+	case "latency":
+		orderBy = "avg_latency_ms DESC"
+	}
+
+	// Buckets are stored as UTC timestamps; periods are local days or months in filter.Timezone
+	period := "NULL::timestamptz"
+	if filter.Interval == "day" || filter.Interval == "month" {
+		timezone := filter.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		period = fmt.Sprintf("date_trunc('%s', (bucket_start AT TIME ZONE 'UTC') AT TIME ZONE $%d) AT TIME ZONE $%d",
+			filter.Interval, argIndex, argIndex)
+		args = append(args, timezone)
+		argIndex++
+		orderBy = "period_start, " + orderBy
+	}
+
+	query := fmt.Sprintf(`
+		WITH filtered AS (
+			SELECT %s AS period_start, org_id, method, route, request_count, error_count,
+				total_latency_ms, max_latency_ms, latency_buckets
+			FROM api_usage_rollups
+			%s
+		),
+		hist AS (
+			SELECT f.period_start, f.org_id, f.method, f.route, b.idx, SUM(b.cnt) AS cnt
+			FROM filtered f, unnest(f.latency_buckets) WITH ORDINALITY AS b(cnt, idx)
+			GROUP BY f.period_start, f.org_id, f.method, f.route, b.idx
+		)
+		SELECT f.period_start, f.org_id, o.name, f.method, f.route,
+			SUM(f.request_count)::bigint AS request_count,
+			SUM(f.error_count)::bigint AS error_count,
+			COALESCE(SUM(f.total_latency_ms)::float8 / NULLIF(SUM(f.request_count), 0), 0) AS avg_latency_ms,
+			MAX(f.max_latency_ms)::bigint AS max_latency_ms,
+			(
+				SELECT COALESCE(array_agg(h.cnt::bigint ORDER BY h.idx), '{}')
+				FROM hist h
+				WHERE h.period_start IS NOT DISTINCT FROM f.period_start
+					AND h.org_id IS NOT DISTINCT FROM f.org_id AND h.method = f.method AND h.route = f.route
+			) AS latency_buckets
+		FROM filtered f
+		LEFT JOIN organizations o ON f.org_id = o.id
+		GROUP BY f.period_start, f.org_id, o.name, f.method, f.route
+		ORDER BY %s
+		LIMIT $%d`, period, whereClause, orderBy, argIndex)
+
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to query API usage", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	report := make([]*models.APIUsageReportRow, 0)
+	for rows.Next() {
+		row := &models.APIUsageReportRow{}
+		var latencyBuckets []int64
+
+		err := rows.Scan(
+			&row.PeriodStart,
+			&row.OrgID,
+			&row.OrgName,
+			&row.Method,
+			&row.Route,
+			&row.RequestCount,
+			&row.ErrorCount,
+			&row.AvgLatencyMs,
+			&row.MaxLatencyMs,
+			&latencyBuckets,
+		)
+		if err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan API usage row", errors.ErrInternalServer.Status)
+		}
+
+		if row.RequestCount > 0 {
+			row.ErrorRate = float64(row.ErrorCount) / float64(row.RequestCount)
+		}
+		row.P95LatencyMs = histogramPercentile(latencyBuckets, row.MaxLatencyMs, 0.95)
+
+		report = append(report, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate API usage rows", errors.ErrInternalServer.Status)
+	}
+
+	return report, nil
+}
+
+// histogramPercentile returns the upper bound of the bucket containing the requested percentile.
+// The overflow bucket reports the observed maximum instead of an unbounded value.
+func histogramPercentile(buckets []int64, maxLatencyMs int64, percentile float64) int64 {
+	var total int64
+	for _, count := range buckets {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := int64(float64(total)*percentile + 0.5)
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i, count := range buckets {
+		seen += count
+		if seen >= target {
+			if i < len(models.APIUsageLatencyBoundsMs) {
+				bound := models.APIUsageLatencyBoundsMs[i]
+				if maxLatencyMs > 0 && maxLatencyMs < bound {
+					return maxLatencyMs
+				}
+				return bound
+			}
+			return maxLatencyMs
+		}
+	}
+
+	return maxLatencyMs
+}
@@ -7,6 +7,7 @@ import (
 	"saas-api/internal/database"
 	"saas-api/internal/models"
 	"saas-api/pkg/errors"
+	"saas-api/pkg/tenant"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -127,7 +128,8 @@ func (r *AuditLogRepository) List(ctx context.Context, orgID *uuid.UUID, userID
 	return logs, total, nil
 }
 
-// Create creates a new audit log entry
+// Create creates a new audit log entry. Entries written during an impersonated request get the
+// impersonator's ID in their metadata.
 func (r *AuditLogRepository) Create(ctx context.Context, log *models.AuditLog) error {
 	query := `
 		INSERT INTO audit_logs (
@@ -137,6 +139,15 @@ func (r *AuditLogRepository) Create(ctx context.Context, log *models.AuditLog) e
 		RETURNING id, created_at
 	`
 
+	if impersonatorID := tenant.FromContext(ctx).ImpersonatorID; impersonatorID != "" {
+		if log.Metadata == nil {
+			log.Metadata = map[string]interface{}{}
+		}
+		if _, ok := log.Metadata["impersonator_id"]; !ok {
+			log.Metadata["impersonator_id"] = impersonatorID
+		}
+	}
+
 	var metadataJSON []byte
 	var err error
 	if log.Metadata != nil {
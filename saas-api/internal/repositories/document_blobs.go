@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// blobScope is the unique-index expression of document_blobs: org uploads are deduplicated per org,
+// superadmin uploads without an org share one scope
+const blobScope = `COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid)`
+
+// DocumentBlob is the stored bytes of an uploaded file, shared by every document in the org with the
+// same checksum
+type DocumentBlob struct {
+	ID          int64      `json:"id"`
+	OrgID       *uuid.UUID `json:"org_id,omitempty"`
+	Checksum    string     `json:"checksum"`
+	StoragePath string     `json:"storage_path"` // Storage key of the shared file
+	SizeBytes   int64      `json:"size_bytes"`
+	RefCount    int        `json:"ref_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+const documentBlobColumns = `id, org_id, checksum, storage_path, size_bytes, ref_count, created_at, updated_at`
+
+func scanDocumentBlob(row pgx.Row, blob *DocumentBlob, extra ...interface{}) error {
+	dest := []interface{}{
+		&blob.ID, &blob.OrgID, &blob.Checksum, &blob.StoragePath, &blob.SizeBytes, &blob.RefCount, &blob.CreatedAt, &blob.UpdatedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// AcquireBlob takes a reference on the org's blob with this checksum, creating it at storagePath when
+// the org has none. created reports whether the new blob is the caller's bytes; otherwise the caller
+// should discard its copy and use blob.StoragePath.
+func (r *DocumentRepository) AcquireBlob(ctx context.Context, orgID *uuid.UUID, checksum, storagePath string, sizeBytes int64) (*DocumentBlob, bool, error) {
+	query := `
+		INSERT INTO document_blobs (org_id, checksum, storage_path, size_bytes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT ((` + blobScope + `), checksum)
+		DO UPDATE SET ref_count = document_blobs.ref_count + 1, updated_at = NOW()
+		RETURNING ` + documentBlobColumns + `, (xmax = 0)
+	`
+
+	blob := &DocumentBlob{}
+	var created bool
+	if err := scanDocumentBlob(r.dbWriter.QueryRow(ctx, query, orgID, checksum, storagePath, sizeBytes), blob, &created); err != nil {
+		return nil, false, fmt.Errorf("failed to acquire document blob: %w", err)
+	}
+
+	return blob, created, nil
+}
+
+// ReleaseBlob drops a reference on the org's blob with this checksum. deleted reports that it was the
+// last one: the row is gone and the caller must remove the file. Returns a nil blob when there is none
+// (documents uploaded before deduplication).
+func (r *DocumentRepository) ReleaseBlob(ctx context.Context, orgID *uuid.UUID, checksum string) (*DocumentBlob, bool, error) {
+	query := `
+		UPDATE document_blobs
+		SET ref_count = ref_count - 1, updated_at = NOW()
+		WHERE ` + blobScope + ` = COALESCE($1, '00000000-0000-0000-0000-000000000000'::uuid) AND checksum = $2
+		RETURNING ` + documentBlobColumns
+
+	blob := &DocumentBlob{}
+	err := scanDocumentBlob(r.dbWriter.QueryRow(ctx, query, orgID, checksum), blob)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to release document blob: %w", err)
+	}
+	if blob.RefCount > 0 {
+		return blob, false, nil
+	}
+
+	// A concurrent upload may have taken a new reference since; then the row (and the file) stay
+	result, err := r.dbWriter.Exec(ctx, `DELETE FROM document_blobs WHERE id = $1 AND ref_count <= 0`, blob.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to delete document blob: %w", err)
+	}
+
+	return blob, result.RowsAffected() == 1, nil
+}
+
+// GetProcessedByChecksum returns the most recent completed document in the org with this checksum,
+// other than excludeID, whose processing output can be reused. Returns nil when there is none.
+func (r *DocumentRepository) GetProcessedByChecksum(ctx context.Context, orgID *uuid.UUID, checksum string, excludeID int64) (*Document, error) {
+	query := `
+		SELECT id
+		FROM documents
+		WHERE content->>'checksum' = $1
+		  AND org_id IS NOT DISTINCT FROM $2
+		  AND id <> $3
+		  AND status = 'completed'
+		  AND json_file_path IS NOT NULL AND json_file_path <> ''
+		  AND deleted_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	var id int64
+	err := r.db.QueryRow(ctx, query, checksum, orgID, excludeID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document by checksum: %w", err)
+	}
+
+	return r.GetByID(ctx, id)
+}
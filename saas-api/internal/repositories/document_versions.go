@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Document version statuses. A replacement goes pending -> processing -> embedding -> active, or ends
+// failed; the version it replaces becomes superseded.
+const (
+	DocumentVersionPending    = "pending"
+	DocumentVersionProcessing = "processing"
+	DocumentVersionEmbedding  = "embedding"
+	DocumentVersionActive     = "active"
+	DocumentVersionSuperseded = "superseded"
+	DocumentVersionFailed     = "failed"
+)
+
+// DocumentVersion is one file a document has held (see migrations/10_create_document_versions.sql)
+type DocumentVersion struct {
+	ID           int64      `json:"id"`
+	DocumentID   int64      `json:"document_id"`
+	Version      int        `json:"version"`
+	Filename     string     `json:"filename"`
+	FilePath     *string    `json:"file_path,omitempty"`
+	JsonFilePath *string    `json:"json_file_path,omitempty"`
+	Checksum     *string    `json:"checksum,omitempty"`
+	SizeBytes    *int64     `json:"size_bytes,omitempty"`
+	Status       string     `json:"status"`
+	ErrorMessage *string    `json:"error_message,omitempty"`
+	CreatedBy    *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ActivatedAt  *time.Time `json:"activated_at,omitempty"`
+	SupersededAt *time.Time `json:"superseded_at,omitempty"`
+}
+
+const documentVersionColumns = `id, document_id, version, filename, file_path, json_file_path, checksum, size_bytes,
+	status, error_message, created_by, created_at, activated_at, superseded_at`
+
+func scanDocumentVersion(row pgx.Row, v *DocumentVersion) error {
+	return row.Scan(
+		&v.ID, &v.DocumentID, &v.Version, &v.Filename, &v.FilePath, &v.JsonFilePath, &v.Checksum, &v.SizeBytes,
+		&v.Status, &v.ErrorMessage, &v.CreatedBy, &v.CreatedAt, &v.ActivatedAt, &v.SupersededAt,
+	)
+}
+
+// RecordCurrentVersion adds the document's current content to its history as the active version,
+// unless it is already there. Documents get their first row when their content is first replaced.
+func (r *DocumentRepository) RecordCurrentVersion(ctx context.Context, doc *Document) error {
+	version := 1
+	if doc.Content.Version != nil && *doc.Content.Version > 0 {
+		version = *doc.Content.Version
+	}
+
+	query := `
+		INSERT INTO document_versions (document_id, version, filename, file_path, json_file_path, checksum, size_bytes,
+			status, created_by, created_at, activated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, $10))
+		ON CONFLICT (document_id, version) DO NOTHING
+	`
+
+	_, err := r.dbWriter.Exec(ctx, query,
+		doc.ID, version, doc.Name, doc.FilePath, doc.JsonFilePath, doc.Content.Checksum, doc.Content.SizeBytes,
+		DocumentVersionActive, doc.CreatedBy, doc.CreatedAt, doc.ProcessedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record document version: %w", err)
+	}
+	return nil
+}
+
+// CreateVersion adds a pending version numbered after every earlier one and fills in v.ID, v.Version
+// and v.CreatedAt. created is false when the document already has a replacement in flight.
+func (r *DocumentRepository) CreateVersion(ctx context.Context, v *DocumentVersion) (bool, error) {
+	query := `
+		INSERT INTO document_versions (document_id, version, filename, file_path, json_file_path, checksum, size_bytes,
+			status, created_by)
+		SELECT $1, COALESCE(MAX(version), 1) + 1, $2, $3, $4, $5, $6, $7, $8
+		FROM document_versions
+		WHERE document_id = $1
+		ON CONFLICT DO NOTHING
+		RETURNING id, version, created_at
+	`
+
+	v.Status = DocumentVersionPending
+	err := r.dbWriter.QueryRow(ctx, query,
+		v.DocumentID, v.Filename, v.FilePath, v.JsonFilePath, v.Checksum, v.SizeBytes, v.Status, v.CreatedBy,
+	).Scan(&v.ID, &v.Version, &v.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create document version: %w", err)
+	}
+	return true, nil
+}
+
+// GetVersion returns one version of a document
+func (r *DocumentRepository) GetVersion(ctx context.Context, documentID int64, version int) (*DocumentVersion, error) {
+	query := `SELECT ` + documentVersionColumns + ` FROM document_versions WHERE document_id = $1 AND version = $2`
+
+	v := &DocumentVersion{}
+	if err := scanDocumentVersion(r.db.QueryRow(ctx, query, documentID, version), v); err != nil {
+		return nil, fmt.Errorf("document version not found: %w", err)
+	}
+	return v, nil
+}
+
+// GetLatestReplacement returns the document's newest version when it is not live: in flight or
+// failed. Returns nil when the newest version is the active one or the document has no history.
+func (r *DocumentRepository) GetLatestReplacement(ctx context.Context, documentID int64) (*DocumentVersion, error) {
+	query := `
+		SELECT ` + documentVersionColumns + `
+		FROM document_versions
+		WHERE document_id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`
+
+	v := &DocumentVersion{}
+	err := scanDocumentVersion(r.db.QueryRow(ctx, query, documentID), v)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest document version: %w", err)
+	}
+	if v.Status == DocumentVersionActive || v.Status == DocumentVersionSuperseded {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// ListVersions returns a document's versions, newest first
+func (r *DocumentRepository) ListVersions(ctx context.Context, documentID int64) ([]*DocumentVersion, error) {
+	query := `SELECT ` + documentVersionColumns + ` FROM document_versions WHERE document_id = $1 ORDER BY version DESC`
+
+	rows, err := r.db.Query(ctx, query, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]*DocumentVersion, 0)
+	for rows.Next() {
+		v := &DocumentVersion{}
+		if err := scanDocumentVersion(rows, v); err != nil {
+			return nil, fmt.Errorf("failed to scan document version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// SetVersionStatus moves an in-flight version along; errorMessage is kept for failed versions
+func (r *DocumentRepository) SetVersionStatus(ctx context.Context, documentID int64, version int, status string, errorMessage *string) error {
+	query := `
+		UPDATE document_versions
+		SET status = $3, error_message = $4
+		WHERE document_id = $1 AND version = $2 AND status IN ('pending', 'processing', 'embedding')
+	`
+
+	if _, err := r.dbWriter.Exec(ctx, query, documentID, version, status, errorMessage); err != nil {
+		return fmt.Errorf("failed to update document version status: %w", err)
+	}
+	return nil
+}
+
+// ReopenVersion puts a superseded version back in flight (pending), to be activated again. reopened
+// is false when the version is not superseded or the document already has a version in flight.
+func (r *DocumentRepository) ReopenVersion(ctx context.Context, documentID int64, version int) (bool, error) {
+	query := `
+		UPDATE document_versions
+		SET status = $3, error_message = NULL
+		WHERE document_id = $1 AND version = $2 AND status = $4
+		  AND NOT EXISTS (
+		      SELECT 1 FROM document_versions
+		      WHERE document_id = $1 AND status IN ('pending', 'processing', 'embedding')
+		  )
+	`
+
+	result, err := r.dbWriter.Exec(ctx, query, documentID, version, DocumentVersionPending, DocumentVersionSuperseded)
+	if err != nil {
+		return false, fmt.Errorf("failed to reopen document version: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ActivateVersion makes a version the document's content in one transaction: the document row takes
+// its file, chunks file, checksum and version number, and the previously active version is superseded.
+// swap runs before the commit, so the database only changes when swap succeeds.
+func (r *DocumentRepository) ActivateVersion(ctx context.Context, v *DocumentVersion, updatedBy *uuid.UUID, swap func() error) error {
+	tx, err := r.dbWriter.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE document_versions
+		SET status = $2, superseded_at = NOW()
+		WHERE document_id = $1 AND status = $3
+	`, v.DocumentID, DocumentVersionSuperseded, DocumentVersionActive)
+	if err != nil {
+		return fmt.Errorf("failed to supersede document version: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE document_versions
+		SET status = $3, error_message = NULL, activated_at = NOW()
+		WHERE document_id = $1 AND version = $2 AND status IN ('pending', 'processing', 'embedding')
+	`, v.DocumentID, v.Version, DocumentVersionActive)
+	if err != nil {
+		return fmt.Errorf("failed to activate document version: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("document version %d of document %d is not in flight", v.Version, v.DocumentID)
+	}
+
+	// The snippet and artifact purge marker describe the old content, so they go
+	result, err = tx.Exec(ctx, `
+		UPDATE documents
+		SET file_path = $2, json_file_path = $3, status = 'completed', error_message = NULL,
+		    content = (COALESCE(content, '{}'::jsonb) || jsonb_build_object(
+		        'version', $4::int, 'checksum', $5::text, 'size_bytes', $6::bigint, 'error_message', NULL,
+		        'processing_data', COALESCE(content->'processing_data', '{}'::jsonb) - 'snippet' - 'artifacts_purged_at'
+		    )),
+		    processed_at = NOW(), updated_by = $7, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, v.DocumentID, v.FilePath, v.JsonFilePath, v.Version, v.Checksum, v.SizeBytes, updatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to update document content: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("document not found: %d", v.DocumentID)
+	}
+
+	if err := swap(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
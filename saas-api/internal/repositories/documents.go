@@ -20,6 +20,7 @@ const (
 	DocumentStatusEmbedding  DocumentStatus = "embedding"
 	DocumentStatusCompleted  DocumentStatus = "completed"
 	DocumentStatusFailed     DocumentStatus = "failed"
+	DocumentStatusUploading  DocumentStatus = "uploading" // Reserved for a direct upload to storage that is not finalized yet
 )
 
 // DocumentContent represents the content JSONB structure
@@ -81,7 +82,7 @@ func (r *DocumentRepository) CreateSchema(ctx context.Context) error {
 	query := `
 		-- Create document_status enum if it doesn't exist
 		DO $$ BEGIN
-			CREATE TYPE document_status AS ENUM ('pending', 'processing', 'embedding', 'completed', 'failed');
+			CREATE TYPE document_status AS ENUM ('pending', 'processing', 'embedding', 'completed', 'failed', 'uploading');
 		EXCEPTION
 			WHEN duplicate_object THEN null;
 		END $$;
@@ -319,6 +320,20 @@ func (r *DocumentRepository) MarkProcessed(ctx context.Context, id uuid.UUID) er
 	return nil
 }
 
+// TransitionStatus moves a document from one status to another, reporting false when it was not in
+// the from status (e.g. because a concurrent request moved it first)
+func (r *DocumentRepository) TransitionStatus(ctx context.Context, id int64, from, to DocumentStatus) (bool, error) {
+	result, err := r.dbWriter.Exec(ctx, `
+		UPDATE documents
+		SET status = $3, updated_at = NOW()
+		WHERE id = $1 AND status = $2 AND deleted_at IS NULL
+	`, id, from, to)
+	if err != nil {
+		return false, fmt.Errorf("failed to update document status: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
 // MarkFailed marks a document as failed with an error message
 func (r *DocumentRepository) MarkFailed(ctx context.Context, id uuid.UUID, errorMessage string) error {
 	query := `
@@ -341,9 +356,40 @@ func (r *DocumentRepository) MarkFailed(ctx context.Context, id uuid.UUID, error
 	return nil
 }
 
+// folderDenied is a condition on the folder aliased f: true when f or a folder above it has folder
+// permissions, none of them for a role the user in param holds. Folders without permissions are
+// open to the whole org.
+func folderDenied(param string) string {
+	return `EXISTS (
+		SELECT 1 FROM folders a
+		WHERE a.org_id = f.org_id AND (f.path = a.path OR f.path LIKE a.path || '/%')
+		AND EXISTS (SELECT 1 FROM folder_permissions fp WHERE fp.folder_id = a.id)
+		AND NOT EXISTS (
+			SELECT 1 FROM folder_permissions fp
+			WHERE fp.folder_id = a.id AND fp.role_id IN ` + heldRoleIDs(param) + `
+		)
+	)`
+}
+
+// FolderAccessible reports whether viewerID's roles may see the documents in a folder. Documents
+// outside folders, and a nil viewer (super admins), are never restricted.
+func (r *DocumentRepository) FolderAccessible(ctx context.Context, folderID *uuid.UUID, viewerID *uuid.UUID) (bool, error) {
+	if folderID == nil || viewerID == nil {
+		return true, nil
+	}
+
+	var denied bool
+	query := `SELECT EXISTS (SELECT 1 FROM folders f WHERE f.id = $1 AND ` + folderDenied("$2") + `)`
+	if err := r.db.QueryRow(ctx, query, *folderID, *viewerID).Scan(&denied); err != nil {
+		return false, fmt.Errorf("failed to check folder permissions: %w", err)
+	}
+	return !denied, nil
+}
+
 // ListAll retrieves ALL documents for an organization with pagination (files only, not folders)
-// Excludes documents in the Reports folder
-func (r *DocumentRepository) ListAll(ctx context.Context, orgID uuid.UUID, page, limit int) ([]*Document, int64, error) {
+// Excludes documents in the Reports folder, and when viewerID is set, those in folders its roles
+// are not granted (see folderDenied)
+func (r *DocumentRepository) ListAll(ctx context.Context, orgID uuid.UUID, viewerID *uuid.UUID, page, limit int) ([]*Document, int64, error) {
 	offset := (page - 1) * limit
 
 	// Count query - all documents for this org, exclude folders, deleted, and Reports folder
@@ -362,6 +408,10 @@ func (r *DocumentRepository) ListAll(ctx context.Context, orgID uuid.UUID, page,
 		countQuery += " AND d.org_id = $1"
 		args = append(args, orgID)
 	}
+	if viewerID != nil {
+		countQuery += " AND NOT " + folderDenied(fmt.Sprintf("$%d", len(args)+1))
+		args = append(args, *viewerID)
+	}
 
 	var totalCount int64
 	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
@@ -393,6 +443,11 @@ func (r *DocumentRepository) ListAll(ctx context.Context, orgID uuid.UUID, page,
 		queryArgs = append(queryArgs, orgID)
 		argIndex++
 	}
+	if viewerID != nil {
+		query += " AND NOT " + folderDenied(fmt.Sprintf("$%d", argIndex))
+		queryArgs = append(queryArgs, *viewerID)
+		argIndex++
+	}
 
 	query += fmt.Sprintf(" ORDER BY d.created_at DESC LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
 	queryArgs = append(queryArgs, limit, offset)
@@ -439,8 +494,9 @@ func (r *DocumentRepository) ListAll(ctx context.Context, orgID uuid.UUID, page,
 }
 
 // ListByFolder retrieves documents by folder ID and org_id with pagination (files only, not folders)
-// Excludes documents in the Reports folder unless specifically querying the Reports folder
-func (r *DocumentRepository) ListByFolder(ctx context.Context, folderID *uuid.UUID, orgID uuid.UUID, page, limit int) ([]*Document, int64, error) {
+// Excludes documents in the Reports folder unless specifically querying the Reports folder. When
+// viewerID is set, a folder its roles are not granted lists no documents (see folderDenied).
+func (r *DocumentRepository) ListByFolder(ctx context.Context, folderID *uuid.UUID, orgID uuid.UUID, viewerID *uuid.UUID, page, limit int) ([]*Document, int64, error) {
 	offset := (page - 1) * limit
 
 	// Count query - filter by folder_id and org_id (handle zero UUID for "all orgs"), exclude folders, deleted, and Reports folder
@@ -465,6 +521,11 @@ func (r *DocumentRepository) ListByFolder(ctx context.Context, folderID *uuid.UU
 		countQuery += fmt.Sprintf(" AND d.folder_id = $%d", argIndex)
 		args = append(args, *folderID)
 		argIndex++
+		if viewerID != nil {
+			countQuery += " AND NOT " + folderDenied(fmt.Sprintf("$%d", argIndex))
+			args = append(args, *viewerID)
+			argIndex++
+		}
 	} else {
 		// Root level - exclude Reports folder
 		countQuery += " AND d.folder_id IS NULL"
@@ -510,6 +571,11 @@ func (r *DocumentRepository) ListByFolder(ctx context.Context, folderID *uuid.UU
 		query += fmt.Sprintf(" AND d.folder_id = $%d", queryArgIndex)
 		queryArgs = append(queryArgs, *folderID)
 		queryArgIndex++
+		if viewerID != nil {
+			query += " AND NOT " + folderDenied(fmt.Sprintf("$%d", queryArgIndex))
+			queryArgs = append(queryArgs, *viewerID)
+			queryArgIndex++
+		}
 	} else {
 		// Root level - exclude Reports folder
 		query += " AND d.folder_id IS NULL"
@@ -914,6 +980,180 @@ func (r *DocumentRepository) Update(ctx context.Context, doc *Document) error {
 	return nil
 }
 
+// UpdateMetadata replaces the metadata JSONB of a document
+func (r *DocumentRepository) UpdateMetadata(ctx context.Context, id int64, metadata map[string]interface{}, updatedBy *uuid.UUID) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document metadata: %w", err)
+	}
+
+	query := `
+		UPDATE documents
+		SET metadata = $1, updated_by = $2, updated_at = NOW()
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+
+	result, err := r.dbWriter.Exec(ctx, query, metadataJSON, updatedBy, id)
+	if err != nil {
+		return fmt.Errorf("failed to update document metadata: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("document not found: %d", id)
+	}
+
+	return nil
+}
+
+// SetProcessingData sets one key of content.processing_data without touching the rest of the row
+// (updated_at is left alone: this is derived data, not a user edit)
+func (r *DocumentRepository) SetProcessingData(ctx context.Context, id int64, key string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal processing data: %w", err)
+	}
+
+	query := `
+		UPDATE documents
+		SET content = COALESCE(content, '{}'::jsonb) || jsonb_build_object(
+			'processing_data',
+			COALESCE(content->'processing_data', '{}'::jsonb) || jsonb_build_object($1::text, $2::jsonb)
+		)
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+
+	result, err := r.dbWriter.Exec(ctx, query, key, valueJSON, id)
+	if err != nil {
+		return fmt.Errorf("failed to update document processing data: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("document not found: %d", id)
+	}
+
+	return nil
+}
+
+// RemoveProcessingData deletes one key of content.processing_data
+func (r *DocumentRepository) RemoveProcessingData(ctx context.Context, id int64, key string) error {
+	query := `
+		UPDATE documents
+		SET content = jsonb_set(content, '{processing_data}', (content->'processing_data') - $1::text)
+		WHERE id = $2 AND content->'processing_data' ? $1::text
+	`
+
+	if _, err := r.dbWriter.Exec(ctx, query, key, id); err != nil {
+		return fmt.Errorf("failed to remove document processing data: %w", err)
+	}
+	return nil
+}
+
+// DocumentProcessingEntry is one document's value for a content.processing_data key
+type DocumentProcessingEntry struct {
+	DocumentID int64
+	OrgID      *uuid.UUID
+	Name       string
+	Value      json.RawMessage
+}
+
+// ListByProcessingKey returns the documents, soft-deleted ones included, that have a value for a
+// content.processing_data key, in ID order
+func (r *DocumentRepository) ListByProcessingKey(ctx context.Context, key string) ([]*DocumentProcessingEntry, error) {
+	query := `
+		SELECT id, org_id, name, content->'processing_data'->$1::text
+		FROM documents
+		WHERE content->'processing_data' ? $1::text
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents by processing data: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*DocumentProcessingEntry, 0)
+	for rows.Next() {
+		entry := &DocumentProcessingEntry{}
+		if err := rows.Scan(&entry.DocumentID, &entry.OrgID, &entry.Name, &entry.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan document processing data: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// SampleProcessed returns up to limit random processed documents that still have their source file,
+// optionally restricted to one org. Only id, org_id, name, file_path and created_by are loaded.
+func (r *DocumentRepository) SampleProcessed(ctx context.Context, orgID *uuid.UUID, limit int) ([]*Document, error) {
+	query := `
+		SELECT id, org_id, name, file_path, created_by
+		FROM documents
+		WHERE status = $1 AND deleted_at IS NULL
+		AND file_path IS NOT NULL AND file_path <> ''
+		AND (content->>'is_folder' IS NULL OR (content->>'is_folder')::boolean = false)
+		AND ($2::uuid IS NULL OR org_id = $2)
+		ORDER BY random()
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, DocumentStatusCompleted, orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := make([]*Document, 0)
+	for rows.Next() {
+		doc := &Document{}
+		if err := rows.Scan(&doc.ID, &doc.OrgID, &doc.Name, &doc.FilePath, &doc.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		documents = append(documents, doc)
+	}
+
+	return documents, rows.Err()
+}
+
+// DocumentArtifact is a document's processing output (the *_chunks.json file) as seen by the
+// artifact lifecycle job
+type DocumentArtifact struct {
+	DocumentID   int64
+	OrgID        *uuid.UUID
+	JsonFilePath string
+	Deleted      bool // Soft-deleted document: its artifacts are no longer needed
+}
+
+// ListArtifacts returns documents that have a processing artifact path, soft-deleted ones included,
+// in ID order starting after afterID
+func (r *DocumentRepository) ListArtifacts(ctx context.Context, afterID int64, limit int) ([]*DocumentArtifact, error) {
+	query := `
+		SELECT id, org_id, json_file_path, deleted_at IS NOT NULL
+		FROM documents
+		WHERE id > $1 AND json_file_path IS NOT NULL AND json_file_path <> ''
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	artifacts := make([]*DocumentArtifact, 0)
+	for rows.Next() {
+		artifact := &DocumentArtifact{}
+		if err := rows.Scan(&artifact.DocumentID, &artifact.OrgID, &artifact.JsonFilePath, &artifact.Deleted); err != nil {
+			return nil, fmt.Errorf("failed to scan document artifact: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, rows.Err()
+}
+
 // GetByFolder retrieves documents by folder ID (for folder handler)
 func (r *DocumentRepository) GetByFolder(ctx context.Context, folderID uuid.UUID) ([]*Document, error) {
 	query := `
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EmailVerificationRepository stores the confirmation links sent to new email addresses (see
+// services.EmailChangeService)
+type EmailVerificationRepository struct {
+	db *database.DB
+}
+
+func NewEmailVerificationRepository(db *database.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+func (r *EmailVerificationRepository) Create(ctx context.Context, v *models.EmailVerification) error {
+	query := `
+		INSERT INTO email_verification_tokens (id, user_id, token_hash, email, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query, v.ID, v.UserID, v.TokenHash, v.Email, v.ExpiresAt).Scan(&v.CreatedAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create email verification", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// CountSince returns how many confirmation links were sent for the user since the given time
+func (r *EmailVerificationRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM email_verification_tokens WHERE user_id = $1 AND created_at >= $2`,
+		userID, since).Scan(&count)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count email verifications", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
+
+// Consume marks an unused, unexpired link verified and returns it; any other link returns
+// errors.ErrNotFound
+func (r *EmailVerificationRepository) Consume(ctx context.Context, id uuid.UUID, tokenHash string) (*models.EmailVerification, error) {
+	query := `
+		UPDATE email_verification_tokens SET verified_at = NOW()
+		WHERE id = $1 AND token_hash = $2 AND verified_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, email, expires_at, verified_at, created_at
+	`
+
+	v := &models.EmailVerification{}
+	err := r.db.Pool.QueryRow(ctx, query, id, tokenHash).Scan(
+		&v.ID, &v.UserID, &v.TokenHash, &v.Email, &v.ExpiresAt, &v.VerifiedAt, &v.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to use email verification", errors.ErrInternalServer.Status)
+	}
+
+	return v, nil
+}
+
+// InvalidateForUser closes the user's other outstanding links, once the email changed (or a newer
+// change was requested)
+func (r *EmailVerificationRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE email_verification_tokens SET verified_at = NOW() WHERE user_id = $1 AND verified_at IS NULL`, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to invalidate email verifications", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// FeatureFlagRepository stores the per-org feature overrides of services.FeatureService
+type FeatureFlagRepository struct {
+	db *database.DB
+}
+
+func NewFeatureFlagRepository(db *database.DB) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+// ListForOrg returns an org's feature flags by feature name
+func (r *FeatureFlagRepository) ListForOrg(ctx context.Context, orgID uuid.UUID) (map[string]*models.FeatureFlag, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT org_id, feature, enabled, updated_by, updated_at FROM feature_flags WHERE org_id = $1`, orgID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list feature flags", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	flags := make(map[string]*models.FeatureFlag)
+	for rows.Next() {
+		flag := &models.FeatureFlag{}
+		if err := rows.Scan(&flag.OrgID, &flag.Feature, &flag.Enabled, &flag.UpdatedBy, &flag.UpdatedAt); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan feature flag", errors.ErrInternalServer.Status)
+		}
+		flags[flag.Feature] = flag
+	}
+
+	return flags, rows.Err()
+}
+
+// Set creates or replaces an org's flag for a feature
+func (r *FeatureFlagRepository) Set(ctx context.Context, flag *models.FeatureFlag) error {
+	query := `
+		INSERT INTO feature_flags (org_id, feature, enabled, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (org_id, feature) DO UPDATE
+		SET enabled = $3, updated_by = $4, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	if err := r.db.Pool.QueryRow(ctx, query, flag.OrgID, flag.Feature, flag.Enabled, flag.UpdatedBy).Scan(&flag.UpdatedAt); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to set feature flag", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// Delete removes an org's flag for a feature, returning errors.ErrNotFound when it has none
+func (r *FeatureFlagRepository) Delete(ctx context.Context, orgID uuid.UUID, feature string) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM feature_flags WHERE org_id = $1 AND feature = $2`, orgID, feature)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete feature flag", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"path/filepath"
 	"saas-api/internal/database"
@@ -354,3 +355,222 @@ func (r *FolderRepository) RemovePermission(ctx context.Context, folderID uuid.U
 
 	return nil
 }
+
+// GetDescendantIDs returns the IDs of all folders nested below the given folder
+func (r *FolderRepository) GetDescendantIDs(ctx context.Context, folder *models.Folder) ([]uuid.UUID, error) {
+	query := `SELECT id FROM folders WHERE org_id = $1 AND path LIKE $2 || '/%' ORDER BY path ASC`
+
+	rows, err := r.db.Pool.Query(ctx, query, folder.OrgID, folder.Path)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list descendant folders", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan folder ID", errors.ErrInternalServer.Status)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (r *FolderRepository) GetMetadataRule(ctx context.Context, folderID uuid.UUID) (*models.FolderMetadataRule, error) {
+	query := `
+		SELECT r.id, r.folder_id, r.tags, r.default_metadata, r.apply_to_subfolders,
+			r.created_by, r.updated_by, r.created_at, r.updated_at, f.path
+		FROM folder_metadata_rules r
+		JOIN folders f ON r.folder_id = f.id
+		WHERE r.folder_id = $1
+	`
+
+	rule, err := scanFolderMetadataRule(r.db.Pool.QueryRow(ctx, query, folderID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, errors.ErrNotFound
+		}
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get folder metadata rule", errors.ErrInternalServer.Status)
+	}
+
+	return rule, nil
+}
+
+// UpsertMetadataRule creates or replaces the metadata rule for a folder
+func (r *FolderRepository) UpsertMetadataRule(ctx context.Context, rule *models.FolderMetadataRule) error {
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+	if rule.DefaultMetadata == nil {
+		rule.DefaultMetadata = make(map[string]interface{})
+	}
+
+	metadataJSON, err := json.Marshal(rule.DefaultMetadata)
+	if err != nil {
+		return errors.WrapError(err, "VALIDATION_ERROR", "Invalid default metadata", errors.ErrValidation.Status)
+	}
+
+	query := `
+		INSERT INTO folder_metadata_rules (id, folder_id, tags, default_metadata, apply_to_subfolders, created_by, updated_by)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $5)
+		ON CONFLICT (folder_id) DO UPDATE SET
+			tags = EXCLUDED.tags,
+			default_metadata = EXCLUDED.default_metadata,
+			apply_to_subfolders = EXCLUDED.apply_to_subfolders,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = now()
+		RETURNING id, created_by, created_at, updated_at
+	`
+
+	err = r.db.Pool.QueryRow(ctx, query,
+		rule.FolderID, rule.Tags, metadataJSON, rule.ApplyToSubfolders, rule.UpdatedBy,
+	).Scan(&rule.ID, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save folder metadata rule", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+func (r *FolderRepository) DeleteMetadataRule(ctx context.Context, folderID uuid.UUID) error {
+	query := `DELETE FROM folder_metadata_rules WHERE folder_id = $1`
+	result, err := r.db.Pool.Exec(ctx, query, folderID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete folder metadata rule", errors.ErrInternalServer.Status)
+	}
+
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetEffectiveMetadataRules returns the folder's own rule plus inherited ancestor rules,
+// ordered from the outermost ancestor to the folder itself so closer rules win when merged
+func (r *FolderRepository) GetEffectiveMetadataRules(ctx context.Context, folderID uuid.UUID) ([]*models.FolderMetadataRule, error) {
+	query := `
+		SELECT r.id, r.folder_id, r.tags, r.default_metadata, r.apply_to_subfolders,
+			r.created_by, r.updated_by, r.created_at, r.updated_at, f.path
+		FROM folders target
+		JOIN folders f ON f.org_id = target.org_id
+		JOIN folder_metadata_rules r ON r.folder_id = f.id
+		WHERE target.id = $1
+		AND (f.id = target.id OR (r.apply_to_subfolders AND target.path LIKE f.path || '/%'))
+		ORDER BY length(f.path) ASC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, folderID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get folder metadata rules", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	var rules []*models.FolderMetadataRule
+	for rows.Next() {
+		rule, err := scanFolderMetadataRule(rows)
+		if err != nil {
+			log.Printf("Error scanning folder metadata rule: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func scanFolderMetadataRule(row pgx.Row) (*models.FolderMetadataRule, error) {
+	rule := &models.FolderMetadataRule{}
+	var metadataJSON []byte
+	var folderPath *string
+
+	err := row.Scan(
+		&rule.ID, &rule.FolderID, &rule.Tags, &metadataJSON, &rule.ApplyToSubfolders,
+		&rule.CreatedBy, &rule.UpdatedBy, &rule.CreatedAt, &rule.UpdatedAt, &folderPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &rule.DefaultMetadata); err != nil {
+			rule.DefaultMetadata = make(map[string]interface{})
+		}
+	} else {
+		rule.DefaultMetadata = make(map[string]interface{})
+	}
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+	rule.FolderPath = folderPath
+
+	return rule, nil
+}
+
+// ApplyMetadataRules merges folder rules into document metadata. Rules must be ordered outermost first.
+// Tags are unioned into metadata["tags"]; default keys only fill gaps unless overwrite is set.
+func ApplyMetadataRules(metadata map[string]interface{}, rules []*models.FolderMetadataRule, overwrite bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	if len(rules) == 0 {
+		return result
+	}
+
+	// Explicit keys from the caller are protected; later rules may still override earlier rule defaults
+	explicit := make(map[string]bool, len(metadata))
+	if !overwrite {
+		for k := range metadata {
+			explicit[k] = true
+		}
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	addTag := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			return
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	switch existing := result["tags"].(type) {
+	case []interface{}:
+		for _, t := range existing {
+			if tag, ok := t.(string); ok {
+				addTag(tag)
+			}
+		}
+	case []string:
+		for _, tag := range existing {
+			addTag(tag)
+		}
+	case string:
+		for _, tag := range strings.Split(existing, ",") {
+			addTag(tag)
+		}
+	}
+
+	for _, rule := range rules {
+		for k, v := range rule.DefaultMetadata {
+			if k == "tags" || explicit[k] {
+				continue
+			}
+			result[k] = v
+		}
+		for _, tag := range rule.Tags {
+			addTag(tag)
+		}
+	}
+
+	if len(tags) > 0 {
+		result["tags"] = tags
+	}
+
+	return result
+}
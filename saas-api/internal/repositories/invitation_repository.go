@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// InvitationRepository stores org invitations by the SHA-256 hash of their link token (see
+// services.InvitationService)
+type InvitationRepository struct {
+	db *database.DB
+}
+
+func NewInvitationRepository(db *database.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// invitationStatusSQL derives models.OrgInvitation.Status; an invitation that is neither accepted nor
+// cancelled is pending until it expires
+const invitationStatusSQL = `CASE
+	WHEN accepted_at IS NOT NULL THEN 'accepted'
+	WHEN cancelled_at IS NOT NULL THEN 'cancelled'
+	WHEN expires_at <= NOW() THEN 'expired'
+	ELSE 'pending' END`
+
+const invitationColumns = `id, org_id, email, role_id, token_hash, invited_by, invited_at, expires_at,
+	accepted_at, accepted_by, cancelled_at, cancelled_by, ` + invitationStatusSQL
+
+// invitationOpen matches invitations that are neither accepted nor cancelled, expired or not
+const invitationOpen = `accepted_at IS NULL AND cancelled_at IS NULL`
+
+func scanInvitation(row pgx.Row, inv *models.OrgInvitation) error {
+	return row.Scan(
+		&inv.ID, &inv.OrgID, &inv.Email, &inv.RoleID, &inv.TokenHash, &inv.InvitedBy, &inv.InvitedAt, &inv.ExpiresAt,
+		&inv.AcceptedAt, &inv.AcceptedBy, &inv.CancelledAt, &inv.CancelledBy, &inv.Status,
+	)
+}
+
+func (r *InvitationRepository) Create(ctx context.Context, inv *models.OrgInvitation) error {
+	query := `
+		INSERT INTO org_invitations (id, org_id, email, role_id, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + invitationColumns
+
+	err := scanInvitation(r.db.Pool.QueryRow(ctx, query,
+		inv.ID, inv.OrgID, inv.Email, inv.RoleID, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt,
+	), inv)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create invitation", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+func (r *InvitationRepository) GetByID(ctx context.Context, orgID, id uuid.UUID) (*models.OrgInvitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM org_invitations WHERE id = $1 AND org_id = $2`
+
+	inv := &models.OrgInvitation{}
+	err := scanInvitation(r.db.Pool.QueryRow(ctx, query, id, orgID), inv)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get invitation", errors.ErrInternalServer.Status)
+	}
+
+	return inv, nil
+}
+
+// List returns the org's invitations, newest first; a non-empty status keeps only those in it
+func (r *InvitationRepository) List(ctx context.Context, orgID uuid.UUID, status string) ([]*models.OrgInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM org_invitations
+		WHERE org_id = $1 AND ($2 = '' OR (` + invitationStatusSQL + `) = $2)
+		ORDER BY invited_at DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID, status)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list invitations", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	invitations := []*models.OrgInvitation{}
+	for rows.Next() {
+		inv := &models.OrgInvitation{}
+		if err := scanInvitation(rows, inv); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan invitation", errors.ErrInternalServer.Status)
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+// HasPending reports whether the email has an invitation to the org that can still be accepted
+func (r *InvitationRepository) HasPending(ctx context.Context, orgID uuid.UUID, email string) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM org_invitations
+			WHERE org_id = $1 AND LOWER(email) = $2 AND `+invitationOpen+` AND expires_at > NOW()
+		)`, orgID, strings.ToLower(email)).Scan(&exists)
+	if err != nil {
+		return false, errors.WrapError(err, "INTERNAL_ERROR", "Failed to check invitations", errors.ErrInternalServer.Status)
+	}
+	return exists, nil
+}
+
+// Cancel closes an invitation that was neither accepted nor cancelled; any other returns errors.ErrNotFound
+func (r *InvitationRepository) Cancel(ctx context.Context, orgID, id, cancelledBy uuid.UUID) (*models.OrgInvitation, error) {
+	query := `
+		UPDATE org_invitations SET cancelled_at = NOW(), cancelled_by = $3
+		WHERE id = $1 AND org_id = $2 AND ` + invitationOpen + `
+		RETURNING ` + invitationColumns
+
+	inv := &models.OrgInvitation{}
+	err := scanInvitation(r.db.Pool.QueryRow(ctx, query, id, orgID, cancelledBy), inv)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to cancel invitation", errors.ErrInternalServer.Status)
+	}
+
+	return inv, nil
+}
+
+// Renew replaces the link of an open invitation, expired ones included, and restarts its validity;
+// the previous link stops working. Any other invitation returns errors.ErrNotFound.
+func (r *InvitationRepository) Renew(ctx context.Context, orgID, id uuid.UUID, tokenHash string, expiresAt time.Time) (*models.OrgInvitation, error) {
+	query := `
+		UPDATE org_invitations SET token_hash = $3, invited_at = NOW(), expires_at = $4
+		WHERE id = $1 AND org_id = $2 AND ` + invitationOpen + `
+		RETURNING ` + invitationColumns
+
+	inv := &models.OrgInvitation{}
+	err := scanInvitation(r.db.Pool.QueryRow(ctx, query, id, orgID, tokenHash, expiresAt), inv)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to renew invitation", errors.ErrInternalServer.Status)
+	}
+
+	return inv, nil
+}
+
+// Claim marks a pending, unexpired invitation with this token accepted and returns it, so concurrent
+// accepts of one link create a single account; any other returns errors.ErrNotFound
+func (r *InvitationRepository) Claim(ctx context.Context, id uuid.UUID, tokenHash string) (*models.OrgInvitation, error) {
+	query := `
+		UPDATE org_invitations SET accepted_at = NOW()
+		WHERE id = $1 AND token_hash = $2 AND ` + invitationOpen + ` AND expires_at > NOW()
+		RETURNING ` + invitationColumns
+
+	inv := &models.OrgInvitation{}
+	err := scanInvitation(r.db.Pool.QueryRow(ctx, query, id, tokenHash), inv)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to accept invitation", errors.ErrInternalServer.Status)
+	}
+
+	return inv, nil
+}
+
+// Release reopens a claimed invitation whose account could not be created
+func (r *InvitationRepository) Release(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE org_invitations SET accepted_at = NULL WHERE id = $1 AND accepted_by IS NULL`, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to release invitation", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// SetAcceptedBy records the account created from a claimed invitation
+func (r *InvitationRepository) SetAcceptedBy(ctx context.Context, id, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE org_invitations SET accepted_by = $2 WHERE id = $1`, id, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update invitation", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
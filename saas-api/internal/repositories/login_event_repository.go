@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LoginEventRepository stores users' sign-in attempts (see services.LoginHistoryService)
+type LoginEventRepository struct {
+	db *database.DB
+}
+
+func NewLoginEventRepository(db *database.DB) *LoginEventRepository {
+	return &LoginEventRepository{db: db}
+}
+
+const loginEventColumns = `id, user_id, org_id, method, success, failure_reason, ip_address, user_agent, created_at`
+
+func scanLoginEvent(row pgx.Row, event *models.LoginEvent) error {
+	return row.Scan(
+		&event.ID, &event.UserID, &event.OrgID, &event.Method, &event.Success, &event.FailureReason,
+		&event.IPAddress, &event.UserAgent, &event.CreatedAt,
+	)
+}
+
+func (r *LoginEventRepository) Create(ctx context.Context, event *models.LoginEvent) error {
+	query := `
+		INSERT INTO login_events (user_id, org_id, method, success, failure_reason, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + loginEventColumns
+
+	err := scanLoginEvent(r.db.Pool.QueryRow(ctx, query,
+		event.UserID, event.OrgID, event.Method, event.Success, event.FailureReason, event.IPAddress, event.UserAgent,
+	), event)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to record login event", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// ListForUser returns a page of the user's sign-in attempts, newest first, and how many there are
+func (r *LoginEventRepository) ListForUser(ctx context.Context, userID uuid.UUID, page, limit int) ([]*models.LoginEvent, int, error) {
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM login_events WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count login events", errors.ErrInternalServer.Status)
+	}
+
+	query := `
+		SELECT ` + loginEventColumns + `
+		FROM login_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list login events", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	events := []*models.LoginEvent{}
+	for rows.Next() {
+		event := &models.LoginEvent{}
+		if err := scanLoginEvent(rows, event); err != nil {
+			return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan login event", errors.ErrInternalServer.Status)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list login events", errors.ErrInternalServer.Status)
+	}
+
+	return events, total, nil
+}
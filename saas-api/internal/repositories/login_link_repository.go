@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LoginLinkRepository stores emailed sign-in links (see services.LoginLinkService)
+type LoginLinkRepository struct {
+	db *database.DB
+}
+
+func NewLoginLinkRepository(db *database.DB) *LoginLinkRepository {
+	return &LoginLinkRepository{db: db}
+}
+
+// Create stores a link and closes the user's earlier ones, so only the newest link works
+func (r *LoginLinkRepository) Create(ctx context.Context, link *models.LoginLink) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to begin transaction", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE login_links SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, link.UserID); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to supersede login links", errors.ErrInternalServer.Status)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO login_links (id, user_id, token_hash, expires_at, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, link.ID, link.UserID, link.TokenHash, link.ExpiresAt, link.IPAddress).Scan(&link.CreatedAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create login link", errors.ErrInternalServer.Status)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// CountSince returns how many links were sent to the user since the given time
+func (r *LoginLinkRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM login_links WHERE user_id = $1 AND created_at >= $2`, userID, since).Scan(&count)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count login links", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
+
+// Consume marks an unused, unexpired link used and returns it; any other link returns
+// errors.ErrNotFound
+func (r *LoginLinkRepository) Consume(ctx context.Context, id uuid.UUID, tokenHash string) (*models.LoginLink, error) {
+	query := `
+		UPDATE login_links SET used_at = NOW()
+		WHERE id = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, expires_at, used_at, ip_address, created_at
+	`
+
+	link := &models.LoginLink{}
+	err := r.db.Pool.QueryRow(ctx, query, id, tokenHash).Scan(
+		&link.ID, &link.UserID, &link.TokenHash, &link.ExpiresAt, &link.UsedAt, &link.IPAddress, &link.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to use login link", errors.ErrInternalServer.Status)
+	}
+
+	return link, nil
+}
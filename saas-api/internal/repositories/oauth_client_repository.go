@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// OAuthClientRepository stores OAuth2 clients with the SHA-256 hash of their secret (see
+// services.OAuthClientService)
+type OAuthClientRepository struct {
+	db *database.DB
+}
+
+func NewOAuthClientRepository(db *database.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{db: db}
+}
+
+const oauthClientColumns = `id, org_id, user_id, name, secret_prefix, secret_hash, scopes, last_used_at, last_used_ip,
+	revoked_at, revoked_by, created_by, created_at`
+
+func scanOAuthClient(row pgx.Row, client *models.OAuthClient) error {
+	return row.Scan(
+		&client.ID, &client.OrgID, &client.UserID, &client.Name, &client.SecretPrefix, &client.SecretHash, &client.Scopes,
+		&client.LastUsedAt, &client.LastUsedIP, &client.RevokedAt, &client.RevokedBy, &client.CreatedBy, &client.CreatedAt,
+	)
+}
+
+func (r *OAuthClientRepository) Create(ctx context.Context, client *models.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (org_id, user_id, name, secret_prefix, secret_hash, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + oauthClientColumns
+
+	err := scanOAuthClient(r.db.Pool.QueryRow(ctx, query,
+		client.OrgID, client.UserID, client.Name, client.SecretPrefix, client.SecretHash, client.Scopes, client.CreatedBy,
+	), client)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create OAuth client", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// Get returns the client with this client_id in any org, including revoked clients, for the token
+// endpoint
+func (r *OAuthClientRepository) Get(ctx context.Context, id uuid.UUID) (*models.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE id = $1`
+
+	client := &models.OAuthClient{}
+	err := scanOAuthClient(r.db.Pool.QueryRow(ctx, query, id), client)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get OAuth client", errors.ErrInternalServer.Status)
+	}
+
+	return client, nil
+}
+
+func (r *OAuthClientRepository) GetByID(ctx context.Context, orgID, id uuid.UUID) (*models.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE id = $1 AND org_id = $2`
+
+	client := &models.OAuthClient{}
+	err := scanOAuthClient(r.db.Pool.QueryRow(ctx, query, id, orgID), client)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get OAuth client", errors.ErrInternalServer.Status)
+	}
+
+	return client, nil
+}
+
+// List returns an org's clients, newest first
+func (r *OAuthClientRepository) List(ctx context.Context, orgID uuid.UUID) ([]*models.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE org_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list OAuth clients", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	clients := []*models.OAuthClient{}
+	for rows.Next() {
+		client := &models.OAuthClient{}
+		if err := scanOAuthClient(rows, client); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan OAuth client", errors.ErrInternalServer.Status)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// Revoke marks a client revoked; revoking an already revoked client returns errors.ErrNotFound
+func (r *OAuthClientRepository) Revoke(ctx context.Context, orgID, id uuid.UUID, revokedBy *uuid.UUID) (*models.OAuthClient, error) {
+	query := `
+		UPDATE oauth_clients SET revoked_at = NOW(), revoked_by = $1
+		WHERE id = $2 AND org_id = $3 AND revoked_at IS NULL
+		RETURNING ` + oauthClientColumns
+
+	client := &models.OAuthClient{}
+	err := scanOAuthClient(r.db.Pool.QueryRow(ctx, query, revokedBy, id, orgID), client)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke OAuth client", errors.ErrInternalServer.Status)
+	}
+
+	return client, nil
+}
+
+func (r *OAuthClientRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, ipAddress string) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE oauth_clients SET last_used_at = NOW(), last_used_ip = $1 WHERE id = $2`, ipAddress, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update OAuth client", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// OIDCProviderRepository stores the organizations' OpenID Connect identity providers
+type OIDCProviderRepository struct {
+	db *database.DB
+}
+
+func NewOIDCProviderRepository(db *database.DB) *OIDCProviderRepository {
+	return &OIDCProviderRepository{db: db}
+}
+
+const oidcProviderColumns = `id, org_id, issuer_url, client_id, client_secret_name, scopes, allowed_domains,
+	auto_provision, default_org_role, role_claim, role_mappings, enabled, created_by, created_at, updated_by, updated_at`
+
+func scanOIDCProvider(row pgx.Row, p *models.OrgOIDCProvider) error {
+	return row.Scan(
+		&p.ID, &p.OrgID, &p.IssuerURL, &p.ClientID, &p.ClientSecretName, &p.Scopes, &p.AllowedDomains,
+		&p.AutoProvision, &p.DefaultOrgRole, &p.RoleClaim, &p.RoleMappings, &p.Enabled,
+		&p.CreatedBy, &p.CreatedAt, &p.UpdatedBy, &p.UpdatedAt,
+	)
+}
+
+func (r *OIDCProviderRepository) GetByOrg(ctx context.Context, orgID uuid.UUID) (*models.OrgOIDCProvider, error) {
+	query := `SELECT ` + oidcProviderColumns + ` FROM org_oidc_providers WHERE org_id = $1`
+
+	p := &models.OrgOIDCProvider{}
+	err := scanOIDCProvider(r.db.Pool.QueryRow(ctx, query, orgID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get OIDC provider", errors.ErrInternalServer.Status)
+	}
+
+	return p, nil
+}
+
+// Upsert creates the org's provider or replaces its settings; p.UpdatedBy is the actor either way
+func (r *OIDCProviderRepository) Upsert(ctx context.Context, p *models.OrgOIDCProvider) error {
+	query := `
+		INSERT INTO org_oidc_providers (org_id, issuer_url, client_id, client_secret_name, scopes, allowed_domains,
+			auto_provision, default_org_role, role_claim, role_mappings, enabled, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		ON CONFLICT (org_id) DO UPDATE
+		SET issuer_url = EXCLUDED.issuer_url, client_id = EXCLUDED.client_id,
+		    client_secret_name = EXCLUDED.client_secret_name, scopes = EXCLUDED.scopes,
+		    allowed_domains = EXCLUDED.allowed_domains, auto_provision = EXCLUDED.auto_provision,
+		    default_org_role = EXCLUDED.default_org_role, role_claim = EXCLUDED.role_claim,
+		    role_mappings = EXCLUDED.role_mappings, enabled = EXCLUDED.enabled,
+		    updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING ` + oidcProviderColumns
+
+	err := scanOIDCProvider(r.db.Pool.QueryRow(ctx, query,
+		p.OrgID, p.IssuerURL, p.ClientID, p.ClientSecretName, p.Scopes, p.AllowedDomains,
+		p.AutoProvision, p.DefaultOrgRole, p.RoleClaim, p.RoleMappings, p.Enabled, p.UpdatedBy,
+	), p)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save OIDC provider", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// Delete removes the org's provider; identities linked through it are removed with it
+func (r *OIDCProviderRepository) Delete(ctx context.Context, orgID uuid.UUID) (*models.OrgOIDCProvider, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete OIDC provider", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	p := &models.OrgOIDCProvider{}
+	err = scanOIDCProvider(tx.QueryRow(ctx, `DELETE FROM org_oidc_providers WHERE org_id = $1 RETURNING `+oidcProviderColumns, orgID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete OIDC provider", errors.ErrInternalServer.Status)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE provider = $1 AND provider_id = $2`, models.IdentityProviderOIDC, p.ID); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete linked identities", errors.ErrInternalServer.Status)
+	}
+
+	return p, tx.Commit(ctx)
+}
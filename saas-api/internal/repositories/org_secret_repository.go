@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/envelope"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// OrgSecretRepository stores envelope-encrypted org secrets. It never sees plaintext: callers seal
+// and open values with the envelope keyring (see services.OrgSecretService).
+type OrgSecretRepository struct {
+	db *database.DB
+}
+
+func NewOrgSecretRepository(db *database.DB) *OrgSecretRepository {
+	return &OrgSecretRepository{db: db}
+}
+
+const orgSecretColumns = `id, org_id, name, description, version, key_id, created_by, created_at,
+	updated_by, updated_at, rotated_at, last_used_at`
+
+func scanOrgSecret(row pgx.Row, secret *models.OrgSecret, extra ...interface{}) error {
+	dest := []interface{}{
+		&secret.ID, &secret.OrgID, &secret.Name, &secret.Description, &secret.Version, &secret.KeyID,
+		&secret.CreatedBy, &secret.CreatedAt, &secret.UpdatedBy, &secret.UpdatedAt, &secret.RotatedAt, &secret.LastUsedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+func (r *OrgSecretRepository) Create(ctx context.Context, secret *models.OrgSecret, sealed *envelope.Sealed) error {
+	query := `
+		INSERT INTO org_secrets (org_id, name, description, key_id, wrapped_key, ciphertext, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING ` + orgSecretColumns
+
+	err := scanOrgSecret(r.db.Pool.QueryRow(ctx, query,
+		secret.OrgID, secret.Name, secret.Description, sealed.KeyID, sealed.WrappedKey, sealed.Ciphertext, secret.CreatedBy,
+	), secret)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewError("CONFLICT", fmt.Sprintf("A secret named '%s' already exists; rotate it instead", secret.Name), errors.ErrConflict.Status)
+		}
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create secret", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// List returns secret metadata for an org, by name
+func (r *OrgSecretRepository) List(ctx context.Context, orgID uuid.UUID) ([]*models.OrgSecret, error) {
+	query := `SELECT ` + orgSecretColumns + ` FROM org_secrets WHERE org_id = $1 ORDER BY name`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list secrets", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	secrets := []*models.OrgSecret{}
+	for rows.Next() {
+		secret := &models.OrgSecret{}
+		if err := scanOrgSecret(rows, secret); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan secret", errors.ErrInternalServer.Status)
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// GetSealed returns a secret's metadata and its encrypted value
+func (r *OrgSecretRepository) GetSealed(ctx context.Context, orgID uuid.UUID, name string) (*models.OrgSecret, *envelope.Sealed, error) {
+	query := `SELECT ` + orgSecretColumns + `, wrapped_key, ciphertext FROM org_secrets WHERE org_id = $1 AND name = $2`
+
+	secret := &models.OrgSecret{}
+	sealed := &envelope.Sealed{}
+	err := scanOrgSecret(r.db.Pool.QueryRow(ctx, query, orgID, name), secret, &sealed.WrappedKey, &sealed.Ciphertext)
+	if err == pgx.ErrNoRows {
+		return nil, nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get secret", errors.ErrInternalServer.Status)
+	}
+	sealed.KeyID = secret.KeyID
+
+	return secret, sealed, nil
+}
+
+// Rotate replaces the value and bumps the version
+func (r *OrgSecretRepository) Rotate(ctx context.Context, secret *models.OrgSecret, sealed *envelope.Sealed) error {
+	query := `
+		UPDATE org_secrets
+		SET key_id = $1, wrapped_key = $2, ciphertext = $3, version = version + 1,
+		    updated_by = $4, updated_at = NOW(), rotated_at = NOW()
+		WHERE org_id = $5 AND name = $6
+		RETURNING ` + orgSecretColumns
+
+	err := scanOrgSecret(r.db.Pool.QueryRow(ctx, query,
+		sealed.KeyID, sealed.WrappedKey, sealed.Ciphertext, secret.UpdatedBy, secret.OrgID, secret.Name,
+	), secret)
+	if err == pgx.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to rotate secret", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// Rewrap stores a data key re-wrapped under a new key-encryption key; the value and version are unchanged
+func (r *OrgSecretRepository) Rewrap(ctx context.Context, id uuid.UUID, sealed *envelope.Sealed) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE org_secrets SET key_id = $1, wrapped_key = $2 WHERE id = $3`,
+		sealed.KeyID, sealed.WrappedKey, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to rewrap secret", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+func (r *OrgSecretRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE org_secrets SET last_used_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func (r *OrgSecretRepository) Delete(ctx context.Context, orgID uuid.UUID, name string) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM org_secrets WHERE org_id = $1 AND name = $2`, orgID, name)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete secret", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
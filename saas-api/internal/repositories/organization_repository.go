@@ -204,6 +204,7 @@ func (r *OrganizationRepository) Update(ctx context.Context, org *models.Organiz
 			subscription_plan = COALESCE($15, subscription_plan),
 			status = COALESCE($16, status),
 			settings = COALESCE($17::jsonb, settings),
+			timezone = COALESCE(NULLIF($20, ''), timezone),
 			updated_at = NOW(),
 			updated_by = $18
 		WHERE id = $19 AND deleted_at IS NULL
@@ -221,7 +222,7 @@ func (r *OrganizationRepository) Update(ctx context.Context, org *models.Organiz
 		org.Name, org.LegalName, org.LogoURL, org.Website,
 		org.AddressLine1, org.AddressLine2, org.City, org.StateProvince, org.PostalCode, org.Country,
 		org.PrimaryContactName, org.PrimaryContactEmail, org.PrimaryContactPhone, org.BillingEmail,
-		org.SubscriptionPlan, org.Status, settingsJSON, org.UpdatedBy, org.ID,
+		org.SubscriptionPlan, org.Status, settingsJSON, org.UpdatedBy, org.ID, org.Timezone,
 	).Scan(&org.UpdatedAt)
 
 	if err == pgx.ErrNoRows {
@@ -237,6 +238,69 @@ func (r *OrganizationRepository) Update(ctx context.Context, org *models.Organiz
 	return nil
 }
 
+// UpdateSubscription persists plan, status, subscription dates and plan limits (see SubscriptionService)
+func (r *OrganizationRepository) UpdateSubscription(ctx context.Context, org *models.Organization) error {
+	query := `
+		UPDATE organizations
+		SET subscription_plan = $1,
+			subscription_status = $2,
+			trial_ends_at = $3,
+			subscription_starts_at = $4,
+			subscription_ends_at = $5,
+			max_users = $6,
+			max_storage_gb = $7,
+			updated_at = NOW(),
+			updated_by = $8
+		WHERE id = $9 AND deleted_at IS NULL
+		RETURNING updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		org.SubscriptionPlan, org.SubscriptionStatus, org.TrialEndsAt,
+		org.SubscriptionStartsAt, org.SubscriptionEndsAt,
+		org.MaxUsers, org.MaxStorageGB, org.UpdatedBy, org.ID,
+	).Scan(&org.UpdatedAt)
+
+	if err == pgx.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update subscription", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// GetTimezone returns the organization's configured timezone (an IANA name such as "Asia/Kolkata")
+func (r *OrganizationRepository) GetTimezone(ctx context.Context, id uuid.UUID) (string, error) {
+	var timezone *string
+	err := r.db.Pool.QueryRow(ctx, `SELECT timezone FROM organizations WHERE id = $1`, id).Scan(&timezone)
+	if err == pgx.ErrNoRows {
+		return "", errors.ErrNotFound
+	}
+	if err != nil {
+		return "", errors.WrapError(err, "INTERNAL_ERROR", "Failed to get organization timezone", errors.ErrInternalServer.Status)
+	}
+	if timezone == nil {
+		return "", nil
+	}
+	return *timezone, nil
+}
+
+// StorageUsedBytes sums the size of the organization's documents
+func (r *OrganizationRepository) StorageUsedBytes(ctx context.Context, orgID uuid.UUID) (int64, error) {
+	var used int64
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM((content->>'size_bytes')::bigint), 0)::bigint
+		FROM documents
+		WHERE org_id = $1
+	`, orgID).Scan(&used)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to calculate storage usage", errors.ErrInternalServer.Status)
+	}
+	return used, nil
+}
+
 func (r *OrganizationRepository) List(ctx context.Context, page, limit int) ([]*models.Organization, int64, error) {
 	var orgs []*models.Organization
 	var total int64
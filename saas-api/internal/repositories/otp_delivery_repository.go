@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// OTPDeliveryRepository records the OTP codes sent per channel (see services.OTPDelivery)
+type OTPDeliveryRepository struct {
+	db *database.DB
+}
+
+func NewOTPDeliveryRepository(db *database.DB) *OTPDeliveryRepository {
+	return &OTPDeliveryRepository{db: db}
+}
+
+// Record notes a code sent to the user on channel
+func (r *OTPDeliveryRepository) Record(ctx context.Context, userID uuid.UUID, channel string) error {
+	if _, err := r.db.Pool.Exec(ctx,
+		`INSERT INTO otp_deliveries (user_id, channel) VALUES ($1, $2)`, userID, channel); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to record OTP delivery", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// CountSince returns how many codes were sent to the user on channel since the given time
+func (r *OTPDeliveryRepository) CountSince(ctx context.Context, userID uuid.UUID, channel string, since time.Time) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM otp_deliveries WHERE user_id = $1 AND channel = $2 AND created_at >= $3`,
+		userID, channel, since).Scan(&count)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count OTP deliveries", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
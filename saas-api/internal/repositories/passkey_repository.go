@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PasskeyRepository stores users' WebAuthn credentials
+type PasskeyRepository struct {
+	db *database.DB
+}
+
+func NewPasskeyRepository(db *database.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+const passkeyColumns = `id, user_id, credential_id, credential, name, aaguid, transports, attachment, backup_eligible,
+	user_agent, created_at, last_used_at, last_used_ip`
+
+func scanPasskey(row pgx.Row, p *models.UserPasskey) error {
+	return row.Scan(
+		&p.ID, &p.UserID, &p.CredentialID, &p.Credential, &p.Name, &p.AAGUID, &p.Transports, &p.Attachment,
+		&p.BackupEligible, &p.UserAgent, &p.CreatedAt, &p.LastUsedAt, &p.LastUsedIP,
+	)
+}
+
+func (r *PasskeyRepository) Create(ctx context.Context, p *models.UserPasskey) error {
+	query := `
+		INSERT INTO user_passkeys (user_id, credential_id, credential, name, aaguid, transports, attachment,
+			backup_eligible, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + passkeyColumns
+
+	err := scanPasskey(r.db.Pool.QueryRow(ctx, query,
+		p.UserID, p.CredentialID, p.Credential, p.Name, p.AAGUID, p.Transports, p.Attachment, p.BackupEligible, p.UserAgent,
+	), p)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewError("CONFLICT", "This passkey is already registered", errors.ErrConflict.Status)
+		}
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save passkey", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// ListByUser returns the user's passkeys, oldest first
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.UserPasskey, error) {
+	query := `SELECT ` + passkeyColumns + ` FROM user_passkeys WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list passkeys", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	passkeys := []*models.UserPasskey{}
+	for rows.Next() {
+		p := &models.UserPasskey{}
+		if err := scanPasskey(rows, p); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan passkey", errors.ErrInternalServer.Status)
+		}
+		passkeys = append(passkeys, p)
+	}
+
+	return passkeys, rows.Err()
+}
+
+// RecordUse stores the credential record after a sign-in (its sign count moves) and when and where
+// the passkey was used
+func (r *PasskeyRepository) RecordUse(ctx context.Context, id uuid.UUID, credential []byte, ipAddress string) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE user_passkeys SET credential = $1, last_used_at = NOW(), last_used_ip = $2 WHERE id = $3`,
+		credential, ipAddress, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update passkey", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) Rename(ctx context.Context, userID, id uuid.UUID, name string) (*models.UserPasskey, error) {
+	query := `UPDATE user_passkeys SET name = $1 WHERE id = $2 AND user_id = $3 RETURNING ` + passkeyColumns
+
+	p := &models.UserPasskey{}
+	err := scanPasskey(r.db.Pool.QueryRow(ctx, query, name, id, userID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to rename passkey", errors.ErrInternalServer.Status)
+	}
+
+	return p, nil
+}
+
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) (*models.UserPasskey, error) {
+	query := `DELETE FROM user_passkeys WHERE id = $1 AND user_id = $2 RETURNING ` + passkeyColumns
+
+	p := &models.UserPasskey{}
+	err := scanPasskey(r.db.Pool.QueryRow(ctx, query, id, userID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete passkey", errors.ErrInternalServer.Status)
+	}
+
+	return p, nil
+}
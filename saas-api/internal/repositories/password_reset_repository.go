@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PasswordResetRepository stores emailed password reset links (see services.PasswordResetService)
+type PasswordResetRepository struct {
+	db *database.DB
+}
+
+func NewPasswordResetRepository(db *database.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+func (r *PasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (id, user_id, token_hash, expires_at, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		reset.ID, reset.UserID, reset.TokenHash, reset.ExpiresAt, reset.IPAddress,
+	).Scan(&reset.CreatedAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create password reset", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// CountSince returns how many resets were requested for the user since the given time
+func (r *PasswordResetRepository) CountSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM password_resets WHERE user_id = $1 AND created_at >= $2`, userID, since,
+	).Scan(&count)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count password resets", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
+
+// RecordRequest stores a forgot-password request from the IP address, known email or not, and
+// returns how many the IP made since the given time, this one included. Rows older than a day are
+// removed on the way.
+func (r *PasswordResetRepository) RecordRequest(ctx context.Context, ipAddress string, since time.Time) (int, error) {
+	query := `
+		WITH pruned AS (
+			DELETE FROM password_reset_requests WHERE created_at < NOW() - INTERVAL '1 day'
+		), inserted AS (
+			INSERT INTO password_reset_requests (ip_address) VALUES ($1)
+		)
+		SELECT COUNT(*) + 1 FROM password_reset_requests WHERE ip_address = $1 AND created_at >= $2
+	`
+
+	var count int
+	if err := r.db.Pool.QueryRow(ctx, query, ipAddress, since).Scan(&count); err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to record password reset request", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
+
+// Consume marks an unused, unexpired reset used and returns it; any other reset returns
+// errors.ErrNotFound. The token hash must match, so a row ID alone is not enough.
+func (r *PasswordResetRepository) Consume(ctx context.Context, id uuid.UUID, tokenHash string) (*models.PasswordReset, error) {
+	query := `
+		UPDATE password_resets SET used_at = NOW()
+		WHERE id = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, expires_at, used_at, ip_address::TEXT, created_at
+	`
+
+	reset := &models.PasswordReset{}
+	err := r.db.Pool.QueryRow(ctx, query, id, tokenHash).Scan(
+		&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.IPAddress, &reset.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to use password reset", errors.ErrInternalServer.Status)
+	}
+
+	return reset, nil
+}
+
+// InvalidateForUser marks the user's other outstanding reset links used, once the password changed
+func (r *PasswordResetRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to invalidate password resets", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
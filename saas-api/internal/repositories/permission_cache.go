@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/pkg/memorydb"
+	"saas-api/pkg/tenant"
+
+	"github.com/google/uuid"
+)
+
+const permissionCacheGenerationKey = "permissions:generation"
+
+// PermissionCache keeps each user's resolved permissions in Redis, so permission checks do not run
+// the recursive roles query on every request. Every entry records the generation it was loaded
+// under, and any change to roles, their permissions or parents, role assignments or permissions
+// starts a new generation: one change to a role can reach users of any org through inheritance,
+// so all entries are dropped at once rather than working out whose permissions changed. Changes
+// made outside the API (seeding, SQL) show once entries expire.
+type PermissionCache struct {
+	redis *memorydb.RedisClient // nil disables caching
+	ttl   time.Duration
+}
+
+// permissionCacheEntry is a user's permissions as cached
+type permissionCacheEntry struct {
+	Generation  string               `json:"generation"`
+	Permissions []*models.Permission `json:"permissions"`
+}
+
+// NewPermissionCache caches permissions for ttl; a nil client or a ttl of 0 disables the cache
+func NewPermissionCache(redis *memorydb.RedisClient, ttl time.Duration) *PermissionCache {
+	if ttl <= 0 {
+		redis = nil
+	}
+	return &PermissionCache{
+		redis: redis,
+		ttl:   ttl,
+	}
+}
+
+func (c *PermissionCache) enabled() bool {
+	return c != nil && c.redis != nil
+}
+
+// get returns the user's cached permissions, if current, and the generation to store a fresh load
+// under. The generation is read before the database so a change committed during the load makes
+// its entry stale.
+func (c *PermissionCache) get(ctx context.Context, userID uuid.UUID) ([]*models.Permission, string, bool) {
+	if !c.enabled() {
+		return nil, "", false
+	}
+
+	values, err := c.redis.MGet(permissionCacheContext(ctx), permissionCacheGenerationKey, permissionCacheKey(userID))
+	if err != nil {
+		log.Printf("Warning: Failed to read cached permissions for user %s: %v", userID, err)
+		return nil, "", false
+	}
+
+	generation, _ := values[0].(string)
+	raw, _ := values[1].(string)
+	var entry permissionCacheEntry
+	if raw == "" || json.Unmarshal([]byte(raw), &entry) != nil || entry.Generation != generation {
+		return nil, generation, false
+	}
+	return entry.Permissions, generation, true
+}
+
+// set caches permissions loaded under generation, for no longer than until the first of the
+// user's role assignments expires
+func (c *PermissionCache) set(ctx context.Context, userID uuid.UUID, generation string, permissions []*models.Permission, nextExpiry *time.Time) {
+	if !c.enabled() {
+		return
+	}
+
+	ttl := c.ttl
+	if nextExpiry != nil {
+		if untilExpiry := time.Until(*nextExpiry); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	raw, err := json.Marshal(permissionCacheEntry{Generation: generation, Permissions: permissions})
+	if err != nil {
+		return
+	}
+	if err := c.redis.Set(permissionCacheContext(ctx), permissionCacheKey(userID), raw, ttl); err != nil {
+		log.Printf("Warning: Failed to cache permissions for user %s: %v", userID, err)
+	}
+}
+
+// Invalidate drops every cached permission set by starting a new generation
+func (c *PermissionCache) Invalidate(ctx context.Context) {
+	if !c.enabled() {
+		return
+	}
+	if err := c.redis.Set(permissionCacheContext(ctx), permissionCacheGenerationKey, uuid.NewString(), 0); err != nil {
+		log.Printf("Warning: Failed to invalidate cached permissions: %v", err)
+	}
+}
+
+func permissionCacheKey(userID uuid.UUID) string {
+	return "permissions:user:" + userID.String()
+}
+
+// permissionCacheContext keeps the cache keys out of the caller's org prefix: the generation is
+// shared by every org, and a super admin's change must reach the entries of users in other orgs
+func permissionCacheContext(ctx context.Context) context.Context {
+	info := tenant.FromContext(ctx)
+	info.OrgID = ""
+	return tenant.WithTenant(ctx, info)
+}
@@ -12,13 +12,19 @@ import (
 )
 
 type PermissionRepository struct {
-	db *database.DB
+	db              *database.DB
+	permissionCache *PermissionCache // invalidated by updating and deleting permissions
 }
 
 func NewPermissionRepository(db *database.DB) *PermissionRepository {
 	return &PermissionRepository{db: db}
 }
 
+// SetPermissionCache makes updating and deleting permissions invalidate the cache
+func (r *PermissionRepository) SetPermissionCache(cache *PermissionCache) {
+	r.permissionCache = cache
+}
+
 func (r *PermissionRepository) List(ctx context.Context) ([]*models.Permission, error) {
 	var permissions []*models.Permission
 	
@@ -95,3 +101,76 @@ func (r *PermissionRepository) GetByResourceAction(ctx context.Context, resource
 	return perm, nil
 }
 
+
+// Create adds a permission that is not a system one. Returns errors.ErrConflict when the resource
+// and action are taken.
+func (r *PermissionRepository) Create(ctx context.Context, perm *models.Permission) error {
+	query := `
+		INSERT INTO permissions (resource, action, description, is_system)
+		VALUES ($1, $2, $3, false)
+		ON CONFLICT (resource, action) DO NOTHING
+		RETURNING id, resource, action, description, is_system, created_at`
+
+	err := r.db.Pool.QueryRow(ctx, query, perm.Resource, perm.Action, perm.Description).Scan(
+		&perm.ID, &perm.Resource, &perm.Action, &perm.Description,
+		&perm.IsSystem, &perm.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return errors.ErrConflict
+	}
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create permission", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+func (r *PermissionRepository) UpdateDescription(ctx context.Context, id uuid.UUID, description *string) (*models.Permission, error) {
+	perm := &models.Permission{}
+	query := `
+		UPDATE permissions SET description = $2
+		WHERE id = $1
+		RETURNING id, resource, action, description, is_system, created_at`
+
+	err := r.db.Pool.QueryRow(ctx, query, id, description).Scan(
+		&perm.ID, &perm.Resource, &perm.Action, &perm.Description,
+		&perm.IsSystem, &perm.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to update permission", errors.ErrInternalServer.Status)
+	}
+
+	r.permissionCache.Invalidate(ctx)
+	return perm, nil
+}
+
+// Delete removes a permission that is not a system one, and with it the roles' grants of it.
+// Returns how many roles had it.
+func (r *PermissionRepository) Delete(ctx context.Context, id uuid.UUID) (int64, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete permission", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM role_permissions WHERE permission_id = $1`, id)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete permission", errors.ErrInternalServer.Status)
+	}
+	deleted, err := tx.Exec(ctx, `DELETE FROM permissions WHERE id = $1 AND is_system = false`, id)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete permission", errors.ErrInternalServer.Status)
+	}
+	if deleted.RowsAffected() == 0 {
+		return 0, errors.ErrNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete permission", errors.ErrInternalServer.Status)
+	}
+	r.permissionCache.Invalidate(ctx)
+	return result.RowsAffected(), nil
+}
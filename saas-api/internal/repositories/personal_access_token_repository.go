@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PersonalAccessTokenRepository stores personal access tokens by the SHA-256 hash of the token (see
+// services.PersonalAccessTokenService)
+type PersonalAccessTokenRepository struct {
+	db *database.DB
+}
+
+func NewPersonalAccessTokenRepository(db *database.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+const personalAccessTokenColumns = `id, user_id, org_id, name, prefix, token_hash, scopes, expires_at, last_used_at,
+	last_used_ip, revoked_at, revoked_by, created_at`
+
+func scanPersonalAccessToken(row pgx.Row, token *models.PersonalAccessToken) error {
+	return row.Scan(
+		&token.ID, &token.UserID, &token.OrgID, &token.Name, &token.Prefix, &token.TokenHash, &token.Scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.RevokedAt, &token.RevokedBy, &token.CreatedAt,
+	)
+}
+
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, org_id, name, prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + personalAccessTokenColumns
+
+	err := scanPersonalAccessToken(r.db.Pool.QueryRow(ctx, query,
+		token.UserID, token.OrgID, token.Name, token.Prefix, token.TokenHash, token.Scopes, token.ExpiresAt,
+	), token)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewError("CONFLICT", "Token collision, please retry", errors.ErrConflict.Status)
+		}
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to create personal access token", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// GetByHash returns the token with this hash, including revoked and expired tokens
+func (r *PersonalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = $1`
+
+	token := &models.PersonalAccessToken{}
+	err := scanPersonalAccessToken(r.db.Pool.QueryRow(ctx, query, tokenHash), token)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get personal access token", errors.ErrInternalServer.Status)
+	}
+
+	return token, nil
+}
+
+// ListForUser returns a user's tokens, newest first
+func (r *PersonalAccessTokenRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list personal access tokens", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	tokens := []*models.PersonalAccessToken{}
+	for rows.Next() {
+		token := &models.PersonalAccessToken{}
+		if err := scanPersonalAccessToken(rows, token); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan personal access token", errors.ErrInternalServer.Status)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Revoke marks one of the user's tokens revoked; revoking an already revoked token returns
+// errors.ErrNotFound
+func (r *PersonalAccessTokenRepository) Revoke(ctx context.Context, userID, id uuid.UUID, revokedBy uuid.UUID) (*models.PersonalAccessToken, error) {
+	query := `
+		UPDATE personal_access_tokens SET revoked_at = NOW(), revoked_by = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+		RETURNING ` + personalAccessTokenColumns
+
+	token := &models.PersonalAccessToken{}
+	err := scanPersonalAccessToken(r.db.Pool.QueryRow(ctx, query, revokedBy, id, userID), token)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke personal access token", errors.ErrInternalServer.Status)
+	}
+
+	return token, nil
+}
+
+func (r *PersonalAccessTokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, ipAddress string) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE personal_access_tokens SET last_used_at = NOW(), last_used_ip = $1 WHERE id = $2`, ipAddress, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update personal access token", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
@@ -113,6 +113,93 @@ func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uu
 	return err
 }
 
+// ListActiveForUser returns the user's unrevoked, unexpired refresh tokens (their sessions), most
+// recently used first
+func (r *RefreshTokenRepository) ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, device_info, ip_address::TEXT, user_agent, expires_at, created_at, last_used_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list sessions", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	tokens := []*models.RefreshToken{}
+	for rows.Next() {
+		token := &models.RefreshToken{}
+		var deviceInfoJSON []byte
+		if err := rows.Scan(
+			&token.ID, &token.UserID, &deviceInfoJSON, &token.IPAddress, &token.UserAgent,
+			&token.ExpiresAt, &token.CreatedAt, &token.LastUsedAt,
+		); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan session", errors.ErrInternalServer.Status)
+		}
+		token.DeviceInfo = make(map[string]interface{})
+		if len(deviceInfoJSON) > 0 {
+			json.Unmarshal(deviceInfoJSON, &token.DeviceInfo)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// RevokeForUser revokes one of the user's active refresh tokens; errors.ErrNotFound when the user
+// has no such active token
+func (r *RefreshTokenRepository) RevokeForUser(ctx context.Context, userID, tokenID, revokedBy uuid.UUID, reason string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW(), revoked_by = $1, revoked_reason = $2
+		WHERE id = $3 AND user_id = $4 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, revokedBy, reason, tokenID, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke session", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// RevokeAllForUserWithReason is RevokeAllForUser with the reason recorded, returning how many tokens
+// it revoked
+func (r *RefreshTokenRepository) RevokeAllForUserWithReason(ctx context.Context, userID, revokedBy uuid.UUID, reason string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW(), revoked_by = $1, revoked_reason = $2
+		WHERE user_id = $3 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, revokedBy, reason, userID)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke sessions", errors.ErrInternalServer.Status)
+	}
+	return result.RowsAffected(), nil
+}
+
+// KnownDevice reports whether the user signed in before (hasSessions) and whether one of those
+// sessions, revoked and expired ones included, came from the device fingerprint or the IP address
+func (r *RefreshTokenRepository) KnownDevice(ctx context.Context, userID uuid.UUID, fingerprint, ipAddress string) (hasSessions, known bool, err error) {
+	query := `
+		SELECT COUNT(*) > 0,
+			COALESCE(BOOL_OR(device_info->>'fingerprint' = $2 OR host(ip_address) = $3), false)
+		FROM refresh_tokens
+		WHERE user_id = $1
+	`
+
+	if err := r.db.Pool.QueryRow(ctx, query, userID, fingerprint, ipAddress).Scan(&hasSessions, &known); err != nil {
+		return false, false, errors.WrapError(err, "INTERNAL_ERROR", "Failed to check known devices", errors.ErrInternalServer.Status)
+	}
+	return hasSessions, known, nil
+}
+
 func (r *RefreshTokenRepository) CleanupExpired(ctx context.Context) error {
 	query := `DELETE FROM refresh_tokens WHERE expires_at < NOW() AND revoked_at IS NULL`
 	_, err := r.db.Pool.Exec(ctx, query)
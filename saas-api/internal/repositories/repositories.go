@@ -18,6 +18,8 @@ type Repositories struct {
 	Persona      *PersonaRepository
 	AuditLog     *AuditLogRepository
 	Screener     *ScreenerRepository
+	APIUsage     *APIUsageRepository
+	Feedback     *SearchFeedbackRepository
 }
 
 // NewRepositories creates and returns all repository instances
@@ -35,6 +37,8 @@ func NewRepositories(db *postgres.DB, dbWriter *postgres.DB) *Repositories {
 		Persona:      NewPersonaRepository(db),
 		AuditLog:     NewAuditLogRepository(db),
 		Screener:     NewScreenerRepository(db),
+		APIUsage:     NewAPIUsageRepository(db),
+		Feedback:     NewSearchFeedbackRepository(db),
 	}
 }
 
@@ -13,13 +13,43 @@ import (
 )
 
 type RoleRepository struct {
-	db *database.DB
+	db              *database.DB
+	permissionCache *PermissionCache // invalidated by changes to roles and their assignments
 }
 
 func NewRoleRepository(db *database.DB) *RoleRepository {
 	return &RoleRepository{db: db}
 }
 
+// SetPermissionCache makes changes to roles, their permissions and parents, and role assignments
+// invalidate the cache
+func (r *RoleRepository) SetPermissionCache(cache *PermissionCache) {
+	r.permissionCache = cache
+}
+
+// heldRolesCTE is the roles user $1 holds: those assigned and not expired, and every role they
+// inherit from, however far up. UNION drops repeats, so it ends even if the parents form a cycle.
+var heldRolesCTE = `
+	WITH RECURSIVE ` + heldRoles("$1")
+
+// heldRoleIDs is a subquery of the IDs of the roles held by the user in param, for queries whose
+// $1 is something else
+func heldRoleIDs(param string) string {
+	return `(WITH RECURSIVE ` + heldRoles(param) + ` SELECT role_id FROM held)`
+}
+
+func heldRoles(param string) string {
+	return `held(role_id) AS (
+		SELECT role_id FROM user_roles
+		WHERE user_id = ` + param + ` AND (expires_at IS NULL OR expires_at > NOW())
+		UNION
+		SELECT rp.parent_role_id FROM role_parents rp JOIN held h ON rp.role_id = h.role_id
+	)`
+}
+
+// roleParentIDs selects a role's parents, for the parent_ids of roles queries
+const roleParentIDs = `ARRAY(SELECT parent_role_id FROM role_parents WHERE role_id = roles.id ORDER BY created_at, parent_role_id)`
+
 func (r *RoleRepository) Create(ctx context.Context, role *models.Role) error {
 	query := `
 		INSERT INTO roles (id, org_id, name, type, description, is_default, created_by)
@@ -42,14 +72,14 @@ func (r *RoleRepository) Create(ctx context.Context, role *models.Role) error {
 func (r *RoleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
 	role := &models.Role{}
 	query := `
-		SELECT id, org_id, name, type, description, is_default, created_at, updated_at, created_by
+		SELECT id, org_id, name, type, description, is_default, ` + roleParentIDs + `, created_at, updated_at, created_by
 		FROM roles
 		WHERE id = $1
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&role.ID, &role.OrgID, &role.Name, &role.Type, &role.Description,
-		&role.IsDefault, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
+		&role.IsDefault, &role.ParentIDs, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -65,7 +95,7 @@ func (r *RoleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Rol
 func (r *RoleRepository) GetByName(ctx context.Context, name string, orgID *uuid.UUID) (*models.Role, error) {
 	role := &models.Role{}
 	query := `
-		SELECT id, org_id, name, type, description, is_default, created_at, updated_at, created_by
+		SELECT id, org_id, name, type, description, is_default, ` + roleParentIDs + `, created_at, updated_at, created_by
 		FROM roles
 		WHERE name = $1 AND ($2::uuid IS NULL OR org_id = $2)
 		ORDER BY CASE WHEN org_id = $2 THEN 0 ELSE 1 END
@@ -74,7 +104,7 @@ func (r *RoleRepository) GetByName(ctx context.Context, name string, orgID *uuid
 
 	err := r.db.Pool.QueryRow(ctx, query, name, orgID).Scan(
 		&role.ID, &role.OrgID, &role.Name, &role.Type, &role.Description,
-		&role.IsDefault, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
+		&role.IsDefault, &role.ParentIDs, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -91,7 +121,7 @@ func (r *RoleRepository) List(ctx context.Context, orgID *uuid.UUID) ([]*models.
 	var roles []*models.Role
 
 	query := `
-		SELECT id, org_id, name, type, description, is_default, created_at, updated_at, created_by
+		SELECT id, org_id, name, type, description, is_default, ` + roleParentIDs + `, created_at, updated_at, created_by
 		FROM roles
 		WHERE ($1::uuid IS NULL OR org_id = $1)
 		ORDER BY created_at
@@ -107,7 +137,7 @@ func (r *RoleRepository) List(ctx context.Context, orgID *uuid.UUID) ([]*models.
 		role := &models.Role{}
 		err := rows.Scan(
 			&role.ID, &role.OrgID, &role.Name, &role.Type, &role.Description,
-			&role.IsDefault, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
+			&role.IsDefault, &role.ParentIDs, &role.CreatedAt, &role.UpdatedAt, &role.CreatedBy,
 		)
 		if err != nil {
 			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan role", errors.ErrInternalServer.Status)
@@ -155,6 +185,7 @@ func (r *RoleRepository) Delete(ctx context.Context, id uuid.UUID) error {
 		return errors.ErrNotFound
 	}
 
+	r.permissionCache.Invalidate(ctx)
 	return nil
 }
 
@@ -189,6 +220,90 @@ func (r *RoleRepository) GetPermissions(ctx context.Context, roleID uuid.UUID) (
 	return permissions, nil
 }
 
+// GetEffectivePermissions returns the role's permissions with those it inherits
+func (r *RoleRepository) GetEffectivePermissions(ctx context.Context, roleID uuid.UUID) ([]*models.Permission, error) {
+	query := `
+		WITH RECURSIVE lineage(role_id) AS (
+			SELECT $1::uuid
+			UNION
+			SELECT rp.parent_role_id FROM role_parents rp JOIN lineage l ON rp.role_id = l.role_id
+		)
+		SELECT DISTINCT p.id, p.resource, p.action, p.description, p.is_system, p.created_at
+		FROM permissions p
+		INNER JOIN role_permissions rp ON p.id = rp.permission_id
+		INNER JOIN lineage l ON rp.role_id = l.role_id
+		ORDER BY p.resource, p.action
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, roleID)
+	if err != nil {
+		return []*models.Permission{}, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get role permissions", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	permissions := make([]*models.Permission, 0)
+	for rows.Next() {
+		perm := &models.Permission{}
+		err := rows.Scan(
+			&perm.ID, &perm.Resource, &perm.Action, &perm.Description,
+			&perm.IsSystem, &perm.CreatedAt,
+		)
+		if err != nil {
+			return []*models.Permission{}, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan permission", errors.ErrInternalServer.Status)
+		}
+		permissions = append(permissions, perm)
+	}
+
+	return permissions, nil
+}
+
+// SetParents makes parentIDs the roles the role inherits from. It refuses parents that are the
+// role itself or already inherit from it, which would make a cycle.
+func (r *RoleRepository) SetParents(ctx context.Context, roleID uuid.UUID, parentIDs []uuid.UUID, createdBy uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to begin transaction", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM role_parents WHERE role_id = $1`, roleID); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to remove role parents", errors.ErrInternalServer.Status)
+	}
+
+	if len(parentIDs) > 0 {
+		var cycle bool
+		err := tx.QueryRow(ctx, `
+			WITH RECURSIVE ancestors(role_id) AS (
+				SELECT unnest($2::uuid[])
+				UNION
+				SELECT rp.parent_role_id FROM role_parents rp JOIN ancestors a ON rp.role_id = a.role_id
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE role_id = $1)
+		`, roleID, parentIDs).Scan(&cycle)
+		if err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to check role inheritance", errors.ErrInternalServer.Status)
+		}
+		if cycle {
+			return errors.NewError("VALIDATION_ERROR", "A role cannot inherit from itself or from a role that inherits from it", errors.ErrValidation.Status)
+		}
+	}
+
+	for _, parentID := range parentIDs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO role_parents (role_id, parent_role_id, created_by) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, roleID, parentID, createdBy); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to add role parent", errors.ErrInternalServer.Status)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
+	}
+	r.permissionCache.Invalidate(ctx)
+	return nil
+}
+
 func (r *RoleRepository) AssignPermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID, grantedBy uuid.UUID) error {
 	if len(permissionIDs) == 0 {
 		return nil
@@ -215,7 +330,11 @@ func (r *RoleRepository) AssignPermissions(ctx context.Context, roleID uuid.UUID
 		}
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.permissionCache.Invalidate(ctx)
+	return nil
 }
 
 func (r *RoleRepository) AssignRoleToUser(ctx context.Context, userID, roleID, assignedBy uuid.UUID, expiresAt *time.Time) error {
@@ -223,7 +342,7 @@ func (r *RoleRepository) AssignRoleToUser(ctx context.Context, userID, roleID, a
 		INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (user_id, role_id) DO UPDATE
-		SET assigned_by = $3, assigned_at = NOW(), expires_at = $4
+		SET assigned_by = $3, assigned_at = NOW(), expires_at = $4, expiry_notified_at = NULL
 	`
 
 	_, err := r.db.Pool.Exec(ctx, query, userID, roleID, assignedBy, expiresAt)
@@ -231,6 +350,7 @@ func (r *RoleRepository) AssignRoleToUser(ctx context.Context, userID, roleID, a
 		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to assign role to user", errors.ErrInternalServer.Status)
 	}
 
+	r.permissionCache.Invalidate(ctx)
 	return nil
 }
 
@@ -246,5 +366,197 @@ func (r *RoleRepository) RemoveRoleFromUser(ctx context.Context, userID, roleID
 		return errors.ErrNotFound
 	}
 
+	r.permissionCache.Invalidate(ctx)
+	return nil
+}
+
+// SetUserRoles makes roleIDs the user's only roles. Roles the user holds keep their assignment as it
+// is; expired assignments are renewed without an expiry.
+func (r *RoleRepository) SetUserRoles(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID, assignedBy uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to begin transaction", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM user_roles WHERE user_id = $1 AND NOT (role_id = ANY(COALESCE($2::uuid[], '{}')))`, userID, roleIDs); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to remove user roles", errors.ErrInternalServer.Status)
+	}
+	for _, roleID := range roleIDs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role_id, assigned_by)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, role_id) DO UPDATE
+			SET assigned_by = $3, assigned_at = NOW(), expires_at = NULL, expiry_notified_at = NULL
+			WHERE user_roles.expires_at <= NOW()
+		`, userID, roleID, assignedBy); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to assign role to user", errors.ErrInternalServer.Status)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
+	}
+	r.permissionCache.Invalidate(ctx)
+	return nil
+}
+
+// roleAssignmentExpiryColumns selects a RoleAssignmentExpiry from user_roles ur, users u and roles r
+const roleAssignmentExpiryColumns = `ur.user_id, u.email, u.full_name, u.org_id, ur.role_id, r.name, ur.assigned_by, ur.expires_at`
+
+func scanRoleAssignmentExpiries(rows pgx.Rows) ([]*models.RoleAssignmentExpiry, error) {
+	defer rows.Close()
+
+	assignments := []*models.RoleAssignmentExpiry{}
+	for rows.Next() {
+		a := &models.RoleAssignmentExpiry{}
+		if err := rows.Scan(&a.UserID, &a.UserEmail, &a.UserName, &a.OrgID, &a.RoleID, &a.RoleName, &a.AssignedBy, &a.ExpiresAt); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan role assignment", errors.ErrInternalServer.Status)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+// ListExpiringAssignments returns the role assignments of an org's users (every org's when orgID is
+// nil) that expire before the given time and have not expired yet, soonest first
+func (r *RoleRepository) ListExpiringAssignments(ctx context.Context, orgID *uuid.UUID, before time.Time, page, limit int) ([]*models.RoleAssignmentExpiry, int, error) {
+	where := `ur.expires_at > NOW() AND ur.expires_at <= $1 AND u.deleted_at IS NULL AND ($2::uuid IS NULL OR u.org_id = $2)`
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM user_roles ur INNER JOIN users u ON u.id = ur.user_id WHERE `+where,
+		before, orgID,
+	).Scan(&total); err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count expiring role assignments", errors.ErrInternalServer.Status)
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+roleAssignmentExpiryColumns+`
+		FROM user_roles ur
+		INNER JOIN users u ON u.id = ur.user_id
+		INNER JOIN roles r ON r.id = ur.role_id
+		WHERE `+where+`
+		ORDER BY ur.expires_at, u.email, r.name
+		LIMIT $3 OFFSET $4
+	`, before, orgID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list expiring role assignments", errors.ErrInternalServer.Status)
+	}
+	assignments, err := scanRoleAssignmentExpiries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return assignments, total, nil
+}
+
+// RevokeExpiredAssignments deletes up to limit expired role assignments and returns them. Expired
+// assignments already grant nothing; this keeps user_roles from filling up with them and lets the
+// caller record and report each one once.
+func (r *RoleRepository) RevokeExpiredAssignments(ctx context.Context, limit int) ([]*models.RoleAssignmentExpiry, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		DELETE FROM user_roles ur
+		USING users u, roles r
+		WHERE u.id = ur.user_id AND r.id = ur.role_id
+			AND (ur.user_id, ur.role_id) IN (
+				SELECT user_id, role_id FROM user_roles WHERE expires_at <= NOW() LIMIT $1
+			)
+		RETURNING `+roleAssignmentExpiryColumns,
+		limit)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to revoke expired role assignments", errors.ErrInternalServer.Status)
+	}
+	assignments, err := scanRoleAssignmentExpiries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(assignments) > 0 {
+		r.permissionCache.Invalidate(ctx)
+	}
+	return assignments, nil
+}
+
+// ClaimExpiryNotices marks up to limit assignments expiring before the given time as notified and
+// returns them; each assignment is claimed once until it is renewed, so instances sharing the
+// database do not send the same notice twice
+func (r *RoleRepository) ClaimExpiryNotices(ctx context.Context, before time.Time, limit int) ([]*models.RoleAssignmentExpiry, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE user_roles ur SET expiry_notified_at = NOW()
+		FROM users u, roles r
+		WHERE u.id = ur.user_id AND r.id = ur.role_id
+			AND (ur.user_id, ur.role_id) IN (
+				SELECT pending.user_id, pending.role_id
+				FROM user_roles pending INNER JOIN users pu ON pu.id = pending.user_id
+				WHERE pending.expires_at > NOW() AND pending.expires_at <= $1
+					AND pending.expiry_notified_at IS NULL AND pu.deleted_at IS NULL
+				LIMIT $2
+			)
+		RETURNING `+roleAssignmentExpiryColumns,
+		before, limit)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to claim role expiry notices", errors.ErrInternalServer.Status)
+	}
+	return scanRoleAssignmentExpiries(rows)
+}
+
+// ListMembers returns the users currently holding the role (ID, email and name only)
+func (r *RoleRepository) ListMembers(ctx context.Context, roleID uuid.UUID) ([]*models.User, error) {
+	query := `
+		SELECT u.id, u.org_id, u.email, u.full_name
+		FROM users u
+		INNER JOIN user_roles ur ON u.id = ur.user_id
+		WHERE ur.role_id = $1 AND u.deleted_at IS NULL AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		ORDER BY ur.assigned_at, u.id
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, roleID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list role members", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.OrgID, &user.Email, &user.FullName); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan role member", errors.ErrInternalServer.Status)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// ReplaceMembers makes userIDs the role's only members. Current members keep their assignment as it
+// is; expired assignments are renewed without an expiry.
+func (r *RoleRepository) ReplaceMembers(ctx context.Context, roleID uuid.UUID, userIDs []uuid.UUID, assignedBy uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to begin transaction", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM user_roles WHERE role_id = $1 AND NOT (user_id = ANY(COALESCE($2::uuid[], '{}')))`, roleID, userIDs); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to remove role members", errors.ErrInternalServer.Status)
+	}
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role_id, assigned_by)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, role_id) DO UPDATE
+			SET assigned_by = $3, assigned_at = NOW(), expires_at = NULL, expiry_notified_at = NULL
+			WHERE user_roles.expires_at <= NOW()
+		`, userID, roleID, assignedBy); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to assign role to user", errors.ErrInternalServer.Status)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
+	}
+	r.permissionCache.Invalidate(ctx)
 	return nil
 }
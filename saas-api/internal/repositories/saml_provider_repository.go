@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SAMLProviderRepository stores the organizations' SAML identity providers
+type SAMLProviderRepository struct {
+	db *database.DB
+}
+
+func NewSAMLProviderRepository(db *database.DB) *SAMLProviderRepository {
+	return &SAMLProviderRepository{db: db}
+}
+
+const samlProviderColumns = `id, org_id, idp_metadata, idp_entity_id, idp_sso_url, email_attribute, first_name_attribute,
+	last_name_attribute, role_attribute, role_mappings, allowed_domains, auto_provision, default_org_role, enforced, enabled,
+	created_by, created_at, updated_by, updated_at`
+
+func scanSAMLProvider(row pgx.Row, p *models.OrgSAMLProvider) error {
+	return row.Scan(
+		&p.ID, &p.OrgID, &p.IdPMetadata, &p.IdPEntityID, &p.IdPSSOURL, &p.EmailAttribute, &p.FirstNameAttribute,
+		&p.LastNameAttribute, &p.RoleAttribute, &p.RoleMappings, &p.AllowedDomains, &p.AutoProvision, &p.DefaultOrgRole,
+		&p.Enforced, &p.Enabled, &p.CreatedBy, &p.CreatedAt, &p.UpdatedBy, &p.UpdatedAt,
+	)
+}
+
+func (r *SAMLProviderRepository) GetByOrg(ctx context.Context, orgID uuid.UUID) (*models.OrgSAMLProvider, error) {
+	query := `SELECT ` + samlProviderColumns + ` FROM org_saml_providers WHERE org_id = $1`
+
+	p := &models.OrgSAMLProvider{}
+	err := scanSAMLProvider(r.db.Pool.QueryRow(ctx, query, orgID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get SAML provider", errors.ErrInternalServer.Status)
+	}
+
+	return p, nil
+}
+
+// Upsert creates the org's provider or replaces its settings; p.UpdatedBy is the actor either way
+func (r *SAMLProviderRepository) Upsert(ctx context.Context, p *models.OrgSAMLProvider) error {
+	query := `
+		INSERT INTO org_saml_providers (org_id, idp_metadata, idp_entity_id, idp_sso_url, email_attribute,
+			first_name_attribute, last_name_attribute, role_attribute, role_mappings, allowed_domains, auto_provision,
+			default_org_role, enforced, enabled, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $15)
+		ON CONFLICT (org_id) DO UPDATE
+		SET idp_metadata = EXCLUDED.idp_metadata, idp_entity_id = EXCLUDED.idp_entity_id,
+		    idp_sso_url = EXCLUDED.idp_sso_url, email_attribute = EXCLUDED.email_attribute,
+		    first_name_attribute = EXCLUDED.first_name_attribute, last_name_attribute = EXCLUDED.last_name_attribute,
+		    role_attribute = EXCLUDED.role_attribute, role_mappings = EXCLUDED.role_mappings,
+		    allowed_domains = EXCLUDED.allowed_domains, auto_provision = EXCLUDED.auto_provision,
+		    default_org_role = EXCLUDED.default_org_role, enforced = EXCLUDED.enforced, enabled = EXCLUDED.enabled,
+		    updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING ` + samlProviderColumns
+
+	err := scanSAMLProvider(r.db.Pool.QueryRow(ctx, query,
+		p.OrgID, p.IdPMetadata, p.IdPEntityID, p.IdPSSOURL, p.EmailAttribute, p.FirstNameAttribute,
+		p.LastNameAttribute, p.RoleAttribute, p.RoleMappings, p.AllowedDomains, p.AutoProvision,
+		p.DefaultOrgRole, p.Enforced, p.Enabled, p.UpdatedBy,
+	), p)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save SAML provider", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// Delete removes the org's provider; identities linked through it are removed with it
+func (r *SAMLProviderRepository) Delete(ctx context.Context, orgID uuid.UUID) (*models.OrgSAMLProvider, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete SAML provider", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	p := &models.OrgSAMLProvider{}
+	err = scanSAMLProvider(tx.QueryRow(ctx, `DELETE FROM org_saml_providers WHERE org_id = $1 RETURNING `+samlProviderColumns, orgID), p)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete SAML provider", errors.ErrInternalServer.Status)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE provider = $1 AND provider_id = $2`, models.IdentityProviderSAML, p.ID); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete linked identities", errors.ErrInternalServer.Status)
+	}
+
+	return p, tx.Commit(ctx)
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+type SearchFeedbackRepository struct {
+	db *database.DB
+}
+
+func NewSearchFeedbackRepository(db *database.DB) *SearchFeedbackRepository {
+	return &SearchFeedbackRepository{db: db}
+}
+
+// Upsert records a vote. A user voting again on the same chunk for the same query replaces the earlier vote.
+func (r *SearchFeedbackRepository) Upsert(ctx context.Context, feedback *models.SearchFeedback) error {
+	query := `
+		INSERT INTO search_feedback (org_id, user_id, document_id, chunk_id, query, query_id, helpful, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, document_id, chunk_id, md5(query))
+		DO UPDATE SET
+			query_id = EXCLUDED.query_id,
+			helpful = EXCLUDED.helpful,
+			comment = EXCLUDED.comment,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		feedback.OrgID,
+		feedback.UserID,
+		feedback.DocumentID,
+		feedback.ChunkID,
+		feedback.Query,
+		feedback.QueryID,
+		feedback.Helpful,
+		feedback.Comment,
+	).Scan(&feedback.ID, &feedback.CreatedAt, &feedback.UpdatedAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save search feedback", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
+
+// DocumentStats aggregates votes per document, most voted first. orgID nil means all orgs (super admin).
+func (r *SearchFeedbackRepository) DocumentStats(ctx context.Context, orgID *uuid.UUID, limit int) ([]*models.SearchFeedbackDocumentStats, error) {
+	whereClause := ""
+	args := []interface{}{}
+	argIndex := 1
+
+	if orgID != nil {
+		whereClause = fmt.Sprintf("WHERE f.org_id = $%d", argIndex)
+		args = append(args, *orgID)
+		argIndex++
+	}
+
+	query := fmt.Sprintf(`
+		SELECT f.document_id, d.name,
+			COUNT(*) FILTER (WHERE f.helpful) AS helpful_count,
+			COUNT(*) FILTER (WHERE NOT f.helpful) AS unhelpful_count
+		FROM search_feedback f
+		JOIN documents d ON d.id = f.document_id
+		%s
+		GROUP BY f.document_id, d.name
+		ORDER BY COUNT(*) DESC
+		LIMIT $%d`, whereClause, argIndex)
+
+	args = append(args, limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to query search feedback", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	stats := make([]*models.SearchFeedbackDocumentStats, 0)
+	for rows.Next() {
+		s := &models.SearchFeedbackDocumentStats{}
+		if err := rows.Scan(&s.DocumentID, &s.DocumentName, &s.HelpfulCount, &s.UnhelpfulCount); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan search feedback", errors.ErrInternalServer.Status)
+		}
+		s.Score = models.SearchFeedbackScore(s.HelpfulCount, s.UnhelpfulCount)
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate search feedback", errors.ErrInternalServer.Status)
+	}
+
+	return stats, nil
+}
+
+// ChunkStats aggregates votes per chunk of one document, best scored first
+func (r *SearchFeedbackRepository) ChunkStats(ctx context.Context, documentID int64) ([]*models.SearchFeedbackChunkStats, error) {
+	query := `
+		SELECT chunk_id,
+			COUNT(*) FILTER (WHERE helpful) AS helpful_count,
+			COUNT(*) FILTER (WHERE NOT helpful) AS unhelpful_count,
+			MAX(updated_at) AS last_feedback_at
+		FROM search_feedback
+		WHERE document_id = $1
+		GROUP BY chunk_id
+		ORDER BY (COUNT(*) FILTER (WHERE helpful) - COUNT(*) FILTER (WHERE NOT helpful))::float8 / (COUNT(*) + 2) DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, documentID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to query search feedback", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	stats := make([]*models.SearchFeedbackChunkStats, 0)
+	for rows.Next() {
+		s := &models.SearchFeedbackChunkStats{}
+		if err := rows.Scan(&s.ChunkID, &s.HelpfulCount, &s.UnhelpfulCount, &s.LastFeedbackAt); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan search feedback", errors.ErrInternalServer.Status)
+		}
+		s.Score = models.SearchFeedbackScore(s.HelpfulCount, s.UnhelpfulCount)
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate search feedback", errors.ErrInternalServer.Status)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/envelope"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TwoFactorRepository stores users' TOTP authenticators and backup codes. Like OrgSecretRepository
+// it never sees plaintext: secrets arrive sealed and backup codes hashed (see services.TwoFactorService).
+type TwoFactorRepository struct {
+	db *database.DB
+}
+
+func NewTwoFactorRepository(db *database.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+const userTOTPColumns = `user_id, key_id, confirmed_at, last_used_step, created_at, updated_at`
+
+func scanUserTOTP(row pgx.Row, t *models.UserTOTP, extra ...interface{}) error {
+	dest := []interface{}{&t.UserID, &t.KeyID, &t.ConfirmedAt, &t.LastUsedStep, &t.CreatedAt, &t.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// Get returns the user's authenticator and its sealed secret
+func (r *TwoFactorRepository) Get(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, *envelope.Sealed, error) {
+	query := `SELECT ` + userTOTPColumns + `, wrapped_key, ciphertext FROM user_totp WHERE user_id = $1`
+
+	t := &models.UserTOTP{}
+	sealed := &envelope.Sealed{}
+	err := scanUserTOTP(r.db.Pool.QueryRow(ctx, query, userID), t, &sealed.WrappedKey, &sealed.Ciphertext)
+	if err == pgx.ErrNoRows {
+		return nil, nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get two-factor settings", errors.ErrInternalServer.Status)
+	}
+	sealed.KeyID = t.KeyID
+
+	return t, sealed, nil
+}
+
+// UpsertPending stores a new, unconfirmed secret, replacing an earlier unconfirmed one. Returns
+// errors.ErrConflict when the user already has a confirmed authenticator.
+func (r *TwoFactorRepository) UpsertPending(ctx context.Context, userID uuid.UUID, sealed *envelope.Sealed) error {
+	query := `
+		INSERT INTO user_totp (user_id, key_id, wrapped_key, ciphertext)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET key_id = EXCLUDED.key_id, wrapped_key = EXCLUDED.wrapped_key, ciphertext = EXCLUDED.ciphertext,
+		    last_used_step = NULL, created_at = NOW(), updated_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL`
+
+	result, err := r.db.Pool.Exec(ctx, query, userID, sealed.KeyID, sealed.WrappedKey, sealed.Ciphertext)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save two-factor secret", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrConflict
+	}
+	return nil
+}
+
+// Confirm turns 2FA on; step is the time step of the code that proved the authenticator
+func (r *TwoFactorRepository) Confirm(ctx context.Context, userID uuid.UUID, step int64) error {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to enable two-factor authentication", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// UseStep records that a code for step was accepted. It reports false when a code for that step or
+// a later one was accepted already, so each code signs in once even under concurrent requests.
+func (r *TwoFactorRepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE user_totp SET last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`, userID, step)
+	if err != nil {
+		return false, errors.WrapError(err, "INTERNAL_ERROR", "Failed to record two-factor code", errors.ErrInternalServer.Status)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// Rewrap stores a data key re-wrapped under a new key-encryption key; the secret is unchanged
+func (r *TwoFactorRepository) Rewrap(ctx context.Context, userID uuid.UUID, sealed *envelope.Sealed) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE user_totp SET key_id = $1, wrapped_key = $2 WHERE user_id = $3`,
+		sealed.KeyID, sealed.WrappedKey, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to rewrap two-factor secret", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+// Delete turns 2FA off: the authenticator and the backup codes go together
+func (r *TwoFactorRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to disable two-factor authentication", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to disable two-factor authentication", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_backup_codes WHERE user_id = $1`, userID); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to delete backup codes", errors.ErrInternalServer.Status)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReplaceBackupCodes swaps the user's backup codes for a new set of hashes
+func (r *TwoFactorRepository) ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save backup codes", errors.ErrInternalServer.Status)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_backup_codes WHERE user_id = $1`, userID); err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save backup codes", errors.ErrInternalServer.Status)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `INSERT INTO user_backup_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return errors.WrapError(err, "INTERNAL_ERROR", "Failed to save backup codes", errors.ErrInternalServer.Status)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseBackupCode spends an unused backup code; it reports false when the user has no such code
+func (r *TwoFactorRepository) UseBackupCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE user_backup_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return false, errors.WrapError(err, "INTERNAL_ERROR", "Failed to use backup code", errors.ErrInternalServer.Status)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// CountBackupCodes counts the user's unused backup codes
+func (r *TwoFactorRepository) CountBackupCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM user_backup_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count backup codes", errors.ErrInternalServer.Status)
+	}
+	return count, nil
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Upload session statuses. A session takes chunks while active; completing claims it while its
+// chunks are joined into a document.
+const (
+	UploadSessionActive     = "active"
+	UploadSessionCompleting = "completing"
+)
+
+// UploadSession is a resumable upload in progress (see migrations/25_create_upload_sessions.sql)
+type UploadSession struct {
+	ID          uuid.UUID              `json:"id"`
+	OrgID       *uuid.UUID             `json:"org_id,omitempty"`
+	UserID      uuid.UUID              `json:"user_id"`
+	Filename    string                 `json:"filename"`
+	FilePath    string                 `json:"file_path"` // Storage key the completed file is saved at
+	FolderID    *uuid.UUID             `json:"folder_id,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	SizeBytes   int64                  `json:"size_bytes"`
+	OffsetBytes int64                  `json:"offset_bytes"` // Bytes received so far
+	ChunkKeys   []string               `json:"-"`            // Storage keys of the chunks received, in order
+	Status      string                 `json:"status"`
+	ExpiresAt   time.Time              `json:"expires_at"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+const uploadSessionColumns = `id, org_id, user_id, filename, file_path, folder_id, metadata, size_bytes, offset_bytes,
+	chunk_keys, status, expires_at, created_at, updated_at`
+
+func scanUploadSession(row pgx.Row, s *UploadSession) error {
+	var metadataJSON []byte
+	if err := row.Scan(
+		&s.ID, &s.OrgID, &s.UserID, &s.Filename, &s.FilePath, &s.FolderID, &metadataJSON, &s.SizeBytes, &s.OffsetBytes,
+		&s.ChunkKeys, &s.Status, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(metadataJSON, &s.Metadata); err != nil {
+		s.Metadata = make(map[string]interface{})
+	}
+	return nil
+}
+
+// CreateUploadSession inserts an active session with no bytes received and fills in s.ID, s.Status,
+// s.CreatedAt and s.UpdatedAt
+func (r *DocumentRepository) CreateUploadSession(ctx context.Context, s *UploadSession) error {
+	metadataJSON, err := json.Marshal(s.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if s.Metadata == nil {
+		metadataJSON = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO upload_sessions (org_id, user_id, filename, file_path, folder_id, metadata, size_bytes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, status, created_at, updated_at
+	`
+
+	err = r.dbWriter.QueryRow(ctx, query,
+		s.OrgID, s.UserID, s.Filename, s.FilePath, s.FolderID, metadataJSON, s.SizeBytes, s.ExpiresAt,
+	).Scan(&s.ID, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return nil
+}
+
+// GetUploadSession returns a session, or nil when there is none with that ID. The writer is used, so
+// a client resuming right after a chunk sees the offset it was given.
+func (r *DocumentRepository) GetUploadSession(ctx context.Context, id uuid.UUID) (*UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE id = $1`
+
+	s := &UploadSession{}
+	err := scanUploadSession(r.dbWriter.QueryRow(ctx, query, id), s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	return s, nil
+}
+
+// AppendUploadChunk records a chunk of size bytes stored at chunkKey, written at offset, and pushes
+// the session's expiry to expiresAt. appended is false when the session is no longer active at that
+// offset: another chunk got there first, or the session is being completed.
+func (r *DocumentRepository) AppendUploadChunk(ctx context.Context, id uuid.UUID, offset, size int64, chunkKey string, expiresAt time.Time) (bool, error) {
+	query := `
+		UPDATE upload_sessions
+		SET offset_bytes = offset_bytes + $3,
+		    chunk_keys = array_append(chunk_keys, $4),
+		    expires_at = $5,
+		    updated_at = NOW()
+		WHERE id = $1 AND offset_bytes = $2 AND status = $6 AND offset_bytes + $3 <= size_bytes
+	`
+
+	result, err := r.dbWriter.Exec(ctx, query, id, offset, size, chunkKey, expiresAt, UploadSessionActive)
+	if err != nil {
+		return false, fmt.Errorf("failed to record upload chunk: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// TransitionUploadSession moves a session from one status to another, only if it is still in from;
+// it reports whether it did, so concurrent completions claim a session once
+func (r *DocumentRepository) TransitionUploadSession(ctx context.Context, id uuid.UUID, from, to string) (bool, error) {
+	query := `UPDATE upload_sessions SET status = $3, updated_at = NOW() WHERE id = $1 AND status = $2`
+
+	result, err := r.dbWriter.Exec(ctx, query, id, from, to)
+	if err != nil {
+		return false, fmt.Errorf("failed to update upload session: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// DeleteUploadSession removes a session row; its chunks are the caller's to delete
+func (r *DocumentRepository) DeleteUploadSession(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.dbWriter.Exec(ctx, `DELETE FROM upload_sessions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+	return nil
+}
+
+// ListExpiredUploadSessions returns up to limit sessions that expired before cutoff, oldest first.
+// Sessions being completed are left out unless they were claimed before staleBefore, when the
+// completion must have died with its process.
+func (r *DocumentRepository) ListExpiredUploadSessions(ctx context.Context, cutoff, staleBefore time.Time, limit int) ([]*UploadSession, error) {
+	query := `
+		SELECT ` + uploadSessionColumns + `
+		FROM upload_sessions
+		WHERE expires_at < $1 AND (status = $2 OR updated_at < $3)
+		ORDER BY expires_at
+		LIMIT $4
+	`
+
+	rows, err := r.dbWriter.Query(ctx, query, cutoff, UploadSessionActive, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired upload sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*UploadSession
+	for rows.Next() {
+		s := &UploadSession{}
+		if err := scanUploadSession(rows, s); err != nil {
+			return nil, fmt.Errorf("failed to scan upload session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
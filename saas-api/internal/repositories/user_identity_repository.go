@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+
+	"saas-api/internal/database"
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// UserIdentityRepository links users to their accounts at external identity providers
+type UserIdentityRepository struct {
+	db *database.DB
+}
+
+func NewUserIdentityRepository(db *database.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+const userIdentityColumns = `id, user_id, provider, provider_id, subject, email, created_at, last_login_at`
+
+// GetBySubject finds the identity of an account at a provider
+func (r *UserIdentityRepository) GetBySubject(ctx context.Context, provider string, providerID uuid.UUID, subject string) (*models.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE provider = $1 AND provider_id = $2 AND subject = $3`
+
+	identity := &models.UserIdentity{}
+	err := r.db.Pool.QueryRow(ctx, query, provider, providerID, subject).Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.ProviderID, &identity.Subject,
+		&identity.Email, &identity.CreatedAt, &identity.LastLoginAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get identity", errors.ErrInternalServer.Status)
+	}
+
+	return identity, nil
+}
+
+// RecordLogin links the account to identity.UserID, or refreshes the email and last login of the
+// existing link
+func (r *UserIdentityRepository) RecordLogin(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, provider_id, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (provider, provider_id, subject) DO UPDATE
+		SET email = EXCLUDED.email, last_login_at = NOW()
+		RETURNING id, created_at, last_login_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		identity.UserID, identity.Provider, identity.ProviderID, identity.Subject, identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt, &identity.LastLoginAt)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to record identity login", errors.ErrInternalServer.Status)
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"saas-api/internal/database"
 	"saas-api/internal/models"
 	"saas-api/pkg/errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,26 +16,32 @@ import (
 )
 
 type UserRepository struct {
-	db *database.DB
+	db              *database.DB
+	permissionCache *PermissionCache // nil: permissions are read from the database every time
 }
 
 func NewUserRepository(db *database.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// SetPermissionCache serves GetUserPermissions from cache; deleting a user invalidates it
+func (r *UserRepository) SetPermissionCache(cache *PermissionCache) {
+	r.permissionCache = cache
+}
+
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
 		INSERT INTO users (
 			id, org_id, email, password_hash, first_name, last_name,
-			phone, is_super_admin, org_role, status, email_verified, timezone, locale
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			phone, is_super_admin, org_role, status, email_verified, timezone, locale, invited_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING created_at, updated_at
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query,
 		user.ID, user.OrgID, user.Email, user.PasswordHash,
 		user.FirstName, user.LastName, user.Phone, user.IsSuperAdmin,
-		user.OrgRole, user.Status, user.EmailVerified, user.Timezone, user.Locale,
+		user.OrgRole, user.Status, user.EmailVerified, user.Timezone, user.Locale, user.InvitedBy,
 	).Scan(&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
@@ -252,6 +260,29 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, p
 	return nil
 }
 
+// UpdateEmail sets a confirmed new email address, which also marks the email verified
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET email = $1, email_verified = true, email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, email, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.NewError("CONFLICT", "Email already exists", errors.ErrConflict.Status)
+		}
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update email", errors.ErrInternalServer.Status)
+	}
+
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
 func (r *UserRepository) UpdateLoginInfo(ctx context.Context, userID uuid.UUID, ipAddress string) error {
 	query := `
 		UPDATE users
@@ -263,19 +294,44 @@ func (r *UserRepository) UpdateLoginInfo(ctx context.Context, userID uuid.UUID,
 	return err
 }
 
-func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, userID uuid.UUID) error {
+// IncrementFailedLoginAttempts counts a failed sign-in, locking the account for lockFor once it
+// reaches maxAttempts (0 never locks). It reports whether the account is locked now.
+func (r *UserRepository) IncrementFailedLoginAttempts(ctx context.Context, userID uuid.UUID, maxAttempts int, lockFor time.Duration) (bool, error) {
 	query := `
 		UPDATE users
 		SET failed_login_attempts = failed_login_attempts + 1,
 			locked_until = CASE
-				WHEN failed_login_attempts + 1 >= 5 THEN NOW() + INTERVAL '30 minutes'
+				WHEN $2::INT > 0 AND failed_login_attempts + 1 >= $2::INT THEN NOW() + $3::INT * INTERVAL '1 second'
 				ELSE locked_until
 			END
 		WHERE id = $1
+		RETURNING COALESCE(locked_until > NOW(), false)
 	`
 
-	_, err := r.db.Pool.Exec(ctx, query, userID)
-	return err
+	var locked bool
+	err := r.db.Pool.QueryRow(ctx, query, userID, maxAttempts, int64(lockFor.Seconds())).Scan(&locked)
+	if err == pgx.ErrNoRows {
+		return false, errors.ErrNotFound
+	}
+	return locked, err
+}
+
+// Unlock clears a lockout and the failed sign-in count
+func (r *UserRepository) Unlock(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
 }
 
 func (r *UserRepository) List(ctx context.Context, orgID *uuid.UUID, page, limit int) ([]*models.User, int64, error) {
@@ -302,7 +358,9 @@ func (r *UserRepository) List(ctx context.Context, orgID *uuid.UUID, page, limit
 	query := `
 		SELECT id, org_id, email, first_name, last_name, full_name,
 			avatar_url, phone, is_super_admin, org_role, status, email_verified,
-			last_login_at, timezone, locale, created_at, updated_at
+			last_login_at, timezone, locale, invited_by, pending_expired_at,
+			CASE WHEN status = 'pending' THEN EXTRACT(DAY FROM NOW() - created_at)::int END AS pending_age_days,
+			created_at, updated_at
 		FROM users
 		WHERE deleted_at IS NULL
 	`
@@ -330,7 +388,8 @@ func (r *UserRepository) List(ctx context.Context, orgID *uuid.UUID, page, limit
 			&user.ID, &user.OrgID, &user.Email, &user.FirstName, &user.LastName,
 			&user.FullName, &user.AvatarURL, &user.Phone, &user.IsSuperAdmin,
 			&user.OrgRole, &user.Status, &user.EmailVerified, &user.LastLoginAt,
-			&user.Timezone, &user.Locale, &user.CreatedAt, &user.UpdatedAt,
+			&user.Timezone, &user.Locale, &user.InvitedBy, &user.PendingExpiredAt,
+			&user.PendingAgeDays, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan user", errors.ErrInternalServer.Status)
@@ -352,6 +411,77 @@ func (r *UserRepository) List(ctx context.Context, orgID *uuid.UUID, page, limit
 	return users, total, nil
 }
 
+// ListInOrg returns a page of the org's users other than super admins, for SCIM provisioning. email
+// and externalID narrow it to that user (empty matches any). Oldest first, so pages stay stable.
+func (r *UserRepository) ListInOrg(ctx context.Context, orgID uuid.UUID, email, externalID string, offset, limit int) ([]*models.User, int, error) {
+	where := `
+		WHERE org_id = $1 AND deleted_at IS NULL AND is_super_admin = false
+			AND ($2 = '' OR LOWER(email) = LOWER($2))
+			AND ($3 = '' OR metadata->>'` + models.UserMetadataSCIMExternalID + `' = $3)
+	`
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`+where, orgID, email, externalID).Scan(&total); err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to count users", errors.ErrInternalServer.Status)
+	}
+
+	query := `
+		SELECT id, org_id, email, first_name, last_name, full_name, status, metadata, created_at, updated_at
+		FROM users` + where + `
+		ORDER BY created_at, id
+		LIMIT $4 OFFSET $5
+	`
+	rows, err := r.db.Pool.Query(ctx, query, orgID, email, externalID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list users", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(
+			&user.ID, &user.OrgID, &user.Email, &user.FirstName, &user.LastName,
+			&user.FullName, &user.Status, &user.Metadata, &user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan user", errors.ErrInternalServer.Status)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+// SetSCIMExternalID stores the identity provider's externalId for the user; empty removes it
+func (r *UserRepository) SetSCIMExternalID(ctx context.Context, userID uuid.UUID, externalID string) error {
+	return r.setMetadataKey(ctx, userID, models.UserMetadataSCIMExternalID, externalID)
+}
+
+// SetOTPChannel stores the channel the user wants sign-in codes on
+func (r *UserRepository) SetOTPChannel(ctx context.Context, userID uuid.UUID, channel string) error {
+	return r.setMetadataKey(ctx, userID, models.UserMetadataOTPChannel, channel)
+}
+
+// setMetadataKey sets one users.metadata key, leaving the others alone; an empty value removes it
+func (r *UserRepository) setMetadataKey(ctx context.Context, userID uuid.UUID, key, value string) error {
+	query := `
+		UPDATE users
+		SET metadata = CASE WHEN $3 = '' THEN COALESCE(metadata, '{}'::jsonb) - $2::text
+			ELSE COALESCE(metadata, '{}'::jsonb) || jsonb_build_object($2::text, $3::text) END,
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, userID, key, value)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to update user", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	// Hard delete: Remove all related data first, then delete the user
 	// This is a cascading hard delete to ensure complete removal
@@ -413,10 +543,88 @@ func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	if err := tx.Commit(ctx); err != nil {
 		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to commit transaction", errors.ErrInternalServer.Status)
 	}
+	r.permissionCache.Invalidate(ctx)
 
 	return nil
 }
 
+// ListOrgAdmins returns the active users with org_role "admin" in an org (ID, email and name only)
+func (r *UserRepository) ListOrgAdmins(ctx context.Context, orgID uuid.UUID) ([]*models.User, error) {
+	query := `
+		SELECT id, org_id, email, full_name
+		FROM users
+		WHERE org_id = $1 AND org_role = 'admin' AND status = 'active' AND deleted_at IS NULL
+		ORDER BY email
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list org admins", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.OrgID, &user.Email, &user.FullName); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan user", errors.ErrInternalServer.Status)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// ListStalePendingUsers returns pending users created before the cutoff that have not been flagged yet
+func (r *UserRepository) ListStalePendingUsers(ctx context.Context, createdBefore time.Time, limit int) ([]*models.User, error) {
+	query := `
+		SELECT id, org_id, email, first_name, last_name, full_name, status, invited_by, created_at, updated_at
+		FROM users
+		WHERE status = 'pending' AND deleted_at IS NULL AND pending_expired_at IS NULL AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, createdBefore, limit)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list stale pending users", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(
+			&user.ID, &user.OrgID, &user.Email, &user.FirstName, &user.LastName,
+			&user.FullName, &user.Status, &user.InvitedBy, &user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan user", errors.ErrInternalServer.Status)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to iterate stale pending users", errors.ErrInternalServer.Status)
+	}
+
+	return users, nil
+}
+
+// FlagPendingExpired marks a pending user as expired without deleting it
+func (r *UserRepository) FlagPendingExpired(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool.Exec(ctx, `
+		UPDATE users SET pending_expired_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND pending_expired_at IS NULL
+	`, id)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to flag pending user", errors.ErrInternalServer.Status)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
 func (r *UserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error) {
 	query := `
 		SELECT r.id, r.org_id, r.name, r.type, r.description, r.is_default,
@@ -449,13 +657,39 @@ func (r *UserRepository) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]
 	return roles, nil
 }
 
+// GetUserPermissions returns the permissions the user's roles grant, from the permission cache
+// when one is set
 func (r *UserRepository) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]*models.Permission, error) {
-	query := `
+	permissions, generation, ok := r.permissionCache.get(ctx, userID)
+	if ok {
+		return permissions, nil
+	}
+
+	permissions, err := r.loadUserPermissions(ctx, userID)
+	if err != nil || !r.permissionCache.enabled() {
+		return permissions, err
+	}
+
+	// An expiring role assignment must not outlive its expiry in the cache
+	var nextExpiry *time.Time
+	err = r.db.Pool.QueryRow(ctx,
+		`SELECT MIN(expires_at) FROM user_roles WHERE user_id = $1 AND expires_at > NOW()`, userID,
+	).Scan(&nextExpiry)
+	if err != nil {
+		log.Printf("Warning: Failed to get role expiry for user %s, not caching permissions: %v", userID, err)
+		return permissions, nil
+	}
+	r.permissionCache.set(ctx, userID, generation, permissions, nextExpiry)
+	return permissions, nil
+}
+
+func (r *UserRepository) loadUserPermissions(ctx context.Context, userID uuid.UUID) ([]*models.Permission, error) {
+	// Roles grant their own permissions and those of the roles they inherit from
+	query := heldRolesCTE + `
 		SELECT DISTINCT p.id, p.resource, p.action, p.description, p.is_system, p.created_at
 		FROM permissions p
 		INNER JOIN role_permissions rp ON p.id = rp.permission_id
-		INNER JOIN user_roles ur ON rp.role_id = ur.role_id
-		WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		INNER JOIN held h ON rp.role_id = h.role_id
 		ORDER BY p.resource, p.action
 	`
 
@@ -493,7 +727,113 @@ func (r *UserRepository) HasPermission(ctx context.Context, userID uuid.UUID, re
 	if err != nil {
 		return false, err
 	}
+	return permissionsAllow(permissions, resource, action), nil
+}
+
+// HasPermissions runs HasPermission for each check on one load of the user's permissions, and
+// returns the answers in the order of checks
+func (r *UserRepository) HasPermissions(ctx context.Context, userID uuid.UUID, checks []models.PermissionCheck) ([]bool, error) {
+	permissions, err := r.GetUserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]bool, len(checks))
+	for i, check := range checks {
+		allowed[i] = permissionsAllow(permissions, check.Resource, check.Action)
+	}
+	return allowed, nil
+}
+
+// permissionDependencies are the actions HasPermission's dependency rules require
+var permissionDependencies = map[string][]string{
+	"update": {"read", "update"},
+	"create": {"read", "update", "create"},
+	"delete": {"read", "delete"},
+}
+
+// ExplainPermission shows which of the user's roles, assigned or inherited, grant the permissions
+// HasPermission looks at for resource:action, and how it decides. Grants come from the database,
+// not the permission cache, and include expired role assignments, which no longer count.
+func (r *UserRepository) ExplainPermission(ctx context.Context, userID uuid.UUID, resource, action string) (*models.PermissionExplanation, error) {
+	query := `
+		WITH RECURSIVE held(role_id, assigned_role_id, expires_at) AS (
+			SELECT role_id, role_id, expires_at FROM user_roles WHERE user_id = $1
+			UNION
+			SELECT rp.parent_role_id, h.assigned_role_id, h.expires_at
+			FROM role_parents rp JOIN held h ON rp.role_id = h.role_id
+		)
+		SELECT p.action, r.id, r.name, ar.id, ar.name, h.expires_at
+		FROM held h
+		INNER JOIN role_permissions rp ON rp.role_id = h.role_id
+		INNER JOIN permissions p ON p.id = rp.permission_id
+		INNER JOIN roles r ON r.id = h.role_id
+		INNER JOIN roles ar ON ar.id = h.assigned_role_id
+		WHERE p.resource = $2 AND p.action = ANY($3)
+		ORDER BY p.action, ar.name, r.name
+	`
+
+	actions := append([]string{action, models.PermissionWildcard}, permissionDependencies[action]...)
+	rows, err := r.db.Pool.Query(ctx, query, userID, resource, actions)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to explain permission", errors.ErrInternalServer.Status)
+	}
+	defer rows.Close()
+
+	explanation := &models.PermissionExplanation{
+		Resource:      resource,
+		Action:        action,
+		Missing:       []string{},
+		Grants:        []models.PermissionGrant{},
+		ExpiredGrants: []models.PermissionGrant{},
+	}
+	var held []*models.Permission
+	now := time.Now()
+	for rows.Next() {
+		var grant models.PermissionGrant
+		if err := rows.Scan(&grant.Action, &grant.RoleID, &grant.RoleName, &grant.AssignedRoleID, &grant.AssignedRoleName, &grant.ExpiresAt); err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to scan permission grant", errors.ErrInternalServer.Status)
+		}
+		grant.Inherited = grant.RoleID != grant.AssignedRoleID
+		if grant.ExpiresAt != nil && !grant.ExpiresAt.After(now) {
+			explanation.ExpiredGrants = append(explanation.ExpiredGrants, grant)
+			continue
+		}
+		explanation.Grants = append(explanation.Grants, grant)
+		held = append(held, &models.Permission{Resource: resource, Action: grant.Action})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to explain permission", errors.ErrInternalServer.Status)
+	}
 
+	explanation.Allowed = permissionsAllow(held, resource, action)
+	switch {
+	case slices.ContainsFunc(held, func(p *models.Permission) bool { return p.Action == action }):
+		explanation.Reason = "granted"
+	case slices.ContainsFunc(held, func(p *models.Permission) bool { return p.Action == models.PermissionWildcard }):
+		explanation.Reason = "wildcard"
+	default:
+		explanation.Reason = "denied"
+	}
+
+	required := permissionDependencies[action]
+	if required == nil {
+		required = []string{action}
+	}
+	explanation.Requires = required
+	if explanation.Reason == "denied" {
+		for _, dependency := range required {
+			if !slices.ContainsFunc(held, func(p *models.Permission) bool { return p.Action == dependency }) {
+				explanation.Missing = append(explanation.Missing, dependency)
+			}
+		}
+	}
+
+	return explanation, nil
+}
+
+// permissionsAllow applies HasPermission's rules to the user's permissions
+func permissionsAllow(permissions []*models.Permission, resource, action string) bool {
 	// Build a map of user's permissions for this resource
 	resourcePerms := make(map[string]bool)
 	for _, perm := range permissions {
@@ -502,36 +842,21 @@ func (r *UserRepository) HasPermission(ctx context.Context, userID uuid.UUID, re
 		}
 	}
 
-	// Check if user has the required action
-	if resourcePerms[action] {
-		return true, nil
+	// Check if user has the required action, or every action on the resource
+	if resourcePerms[action] || resourcePerms[models.PermissionWildcard] {
+		return true
 	}
 
-	// Check dependencies based on action type
-	switch action {
-	case "read":
-		// Read is standalone, no dependencies
-		return false, nil
-	case "update":
-		// Update requires read + update
-		if resourcePerms["read"] && resourcePerms["update"] {
-			return true, nil
-		}
-		return false, nil
-	case "create":
-		// Create requires read + update + create
-		if resourcePerms["read"] && resourcePerms["update"] && resourcePerms["create"] {
-			return true, nil
-		}
-		return false, nil
-	case "delete":
-		// Delete requires read + delete
-		if resourcePerms["read"] && resourcePerms["delete"] {
-			return true, nil
+	// Check dependencies based on action type; read and other actions have no rule and need
+	// the action itself
+	required, ok := permissionDependencies[action]
+	if !ok {
+		return false
+	}
+	for _, dependency := range required {
+		if !resourcePerms[dependency] {
+			return false
 		}
-		return false, nil
-	default:
-		// For other actions, just check if user has it
-		return resourcePerms[action], nil
 	}
+	return true
 }
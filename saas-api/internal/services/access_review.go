@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// accessReviewRetention is how long a finished asynchronous export can be downloaded
+	accessReviewRetention = time.Hour
+
+	// accessReviewMaxRunning caps concurrent asynchronous exports; each holds its result in memory
+	accessReviewMaxRunning = 3
+
+	accessReviewTimeout = 10 * time.Minute
+)
+
+var (
+	ErrInvalidReviewFormat = errors.NewError("VALIDATION_ERROR", "Invalid format, expected json or csv", http.StatusBadRequest)
+	ErrTooManyReviews      = errors.NewError("CONFLICT", "Too many access reviews are already running, try again later", http.StatusConflict)
+	ErrReviewNotReady      = errors.NewError("CONFLICT", "Access review is not completed", http.StatusConflict)
+)
+
+// accessReviewColumns is the CSV header; multi-valued fields are joined with "; "
+var accessReviewColumns = []string{
+	"org_id", "org_name", "user_id", "email", "full_name", "status", "org_role", "is_super_admin",
+	"last_login_at", "created_at", "roles", "permissions", "folder_grants",
+}
+
+type accessReviewJob struct {
+	job    models.AccessReviewJob
+	output []byte
+}
+
+// AccessReviewService builds "who can access what" reports for periodic compliance access reviews,
+// either inline or as a background job for large organizations. Every export is audit-logged.
+type AccessReviewService struct {
+	repo         *repositories.AccessReviewRepository
+	auditLogRepo *repositories.AuditLogRepository
+
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*accessReviewJob
+}
+
+func NewAccessReviewService(repo *repositories.AccessReviewRepository, auditLogRepo *repositories.AuditLogRepository) *AccessReviewService {
+	return &AccessReviewService{
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+		jobs:         make(map[uuid.UUID]*accessReviewJob),
+	}
+}
+
+// ValidReviewFormat reports whether format is a supported export format
+func ValidReviewFormat(format string) bool {
+	return format == "json" || format == "csv"
+}
+
+// Generate builds the review for one organization, or for all of them when orgID is nil
+func (s *AccessReviewService) Generate(ctx context.Context, orgID *uuid.UUID, actorID uuid.UUID, format string) (*models.AccessReview, error) {
+	users, err := s.repo.Collect(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	review := &models.AccessReview{
+		GeneratedAt: time.Now().UTC(),
+		OrgID:       orgID,
+		Users:       users,
+	}
+	s.recordExport(ctx, orgID, actorID, format, len(users))
+	return review, nil
+}
+
+// Write renders a review as JSON or CSV
+func (s *AccessReviewService) Write(w io.Writer, review *models.AccessReview, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(review)
+	case "csv":
+		return writeAccessReviewCSV(w, review)
+	default:
+		return ErrInvalidReviewFormat
+	}
+}
+
+// StartJob generates a review in the background. The export stays downloadable for an hour after
+// it completes.
+func (s *AccessReviewService) StartJob(orgID *uuid.UUID, actorID uuid.UUID, format string) (models.AccessReviewJob, error) {
+	if !ValidReviewFormat(format) {
+		return models.AccessReviewJob{}, ErrInvalidReviewFormat
+	}
+
+	s.mu.Lock()
+	now := time.Now().UTC()
+	running := 0
+	for id, j := range s.jobs {
+		if j.job.ExpiresAt != nil && now.After(*j.job.ExpiresAt) {
+			delete(s.jobs, id)
+		} else if j.job.Status == "running" {
+			running++
+		}
+	}
+	if running >= accessReviewMaxRunning {
+		s.mu.Unlock()
+		return models.AccessReviewJob{}, ErrTooManyReviews
+	}
+
+	j := &accessReviewJob{job: models.AccessReviewJob{
+		ID:          uuid.New(),
+		OrgID:       orgID,
+		Format:      format,
+		Status:      "running",
+		RequestedBy: actorID,
+		CreatedAt:   now,
+	}}
+	s.jobs[j.job.ID] = j
+	s.mu.Unlock()
+
+	go s.runJob(j.job.ID, orgID, actorID, format)
+	return j.job, nil
+}
+
+func (s *AccessReviewService) runJob(id uuid.UUID, orgID *uuid.UUID, actorID uuid.UUID, format string) {
+	ctx, cancel := context.WithTimeout(context.Background(), accessReviewTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	review, err := s.Generate(ctx, orgID, actorID, format)
+	if err == nil {
+		err = s.Write(&buf, review, format)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	completedAt := time.Now().UTC()
+	expiresAt := completedAt.Add(accessReviewRetention)
+	j.job.CompletedAt = &completedAt
+	j.job.ExpiresAt = &expiresAt
+	if err != nil {
+		log.Printf("Access review %s failed: %v", id, err)
+		j.job.Status = "failed"
+		j.job.Error = err.Error()
+		return
+	}
+	j.job.Status = "completed"
+	j.job.UserCount = len(review.Users)
+	j.output = buf.Bytes()
+}
+
+// Job returns an asynchronous review's status
+func (s *AccessReviewService) Job(id uuid.UUID) (models.AccessReviewJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || (j.job.ExpiresAt != nil && time.Now().After(*j.job.ExpiresAt)) {
+		return models.AccessReviewJob{}, errors.ErrNotFound
+	}
+	return j.job, nil
+}
+
+// JobOutput returns a completed review's export
+func (s *AccessReviewService) JobOutput(id uuid.UUID) (models.AccessReviewJob, []byte, error) {
+	job, err := s.Job(id)
+	if err != nil {
+		return job, nil, err
+	}
+	if job.Status != "completed" {
+		return job, nil, ErrReviewNotReady
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job, s.jobs[id].output, nil
+}
+
+func (s *AccessReviewService) recordExport(ctx context.Context, orgID *uuid.UUID, actorID uuid.UUID, format string, userCount int) {
+	resourceType := "access_review"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        orgID,
+		Action:       "access_review.exported",
+		ResourceType: &resourceType,
+		ResourceID:   orgID,
+		Status:       "success",
+		Metadata: map[string]interface{}{
+			"format":     format,
+			"user_count": userCount,
+		},
+	}); err != nil {
+		log.Printf("Failed to audit access review export: %v", err)
+	}
+}
+
+func writeAccessReviewCSV(w io.Writer, review *models.AccessReview) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(accessReviewColumns); err != nil {
+		return err
+	}
+
+	for _, user := range review.Users {
+		roles := make([]string, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			entry := role.Name
+			if role.ExpiresAt != nil {
+				entry += " (expires " + role.ExpiresAt.UTC().Format(time.RFC3339) + ")"
+			}
+			roles = append(roles, entry)
+		}
+		grants := make([]string, 0, len(user.FolderGrants))
+		for _, grant := range user.FolderGrants {
+			grants = append(grants, fmt.Sprintf("%s:%s (via %s)", grant.FolderPath, grant.Permission, grant.ViaRole))
+		}
+
+		record := []string{
+			optionalUUID(user.OrgID),
+			optionalString(user.OrgName),
+			user.UserID.String(),
+			user.Email,
+			user.FullName,
+			user.Status,
+			optionalString(user.OrgRole),
+			strconv.FormatBool(user.IsSuperAdmin),
+			optionalTime(user.LastLoginAt),
+			user.CreatedAt.UTC().Format(time.RFC3339),
+			strings.Join(roles, "; "),
+			strings.Join(user.Permissions, "; "),
+			strings.Join(grants, "; "),
+		}
+		for i, value := range record {
+			record[i] = csvSafe(value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe keeps spreadsheet apps from evaluating user-controlled values (names, folder paths) as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func optionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise (and to scan for)
+const APIKeyPrefix = "sk_"
+
+var (
+	ErrInvalidAPIKey      = errors.NewError("UNAUTHORIZED", "Invalid, expired or revoked API key", http.StatusUnauthorized)
+	ErrInvalidAPIKeyScope = errors.NewError("VALIDATION_ERROR", "Scopes must be resource:action pairs, e.g. documents:read", http.StatusBadRequest)
+)
+
+var apiKeyScopePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*:([a-z][a-z0-9_]*|\*)$`)
+
+// apiKeyTouchInterval keeps last_used_at roughly current without a write on every request
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService issues and verifies API keys. A key is shown once, when it is created; only its
+// SHA-256 hash is stored, like refresh tokens.
+type APIKeyService struct {
+	repo         *repositories.APIKeyRepository
+	userRepo     *repositories.UserRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewAPIKeyService(repo *repositories.APIKeyRepository, userRepo *repositories.UserRepository, auditLogRepo *repositories.AuditLogRepository) *APIKeyService {
+	return &APIKeyService{
+		repo:         repo,
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+func (s *APIKeyService) List(ctx context.Context, orgID uuid.UUID) ([]*models.APIKey, error) {
+	return s.repo.List(ctx, orgID)
+}
+
+func (s *APIKeyService) Get(ctx context.Context, orgID, id uuid.UUID) (*models.APIKey, error) {
+	return s.repo.GetByID(ctx, orgID, id)
+}
+
+// Create issues a key for the org. The key acts as req.UserID (or the caller), who must be an active
+// member of the org and already hold every requested scope: a key can narrow a user's access but
+// never widen it. Only org admins and super admins may name another user, and the caller must hold
+// the scopes too, so a key cannot reach further than the person creating it.
+func (s *APIKeyService) Create(ctx context.Context, orgID uuid.UUID, req models.CreateAPIKeyRequest, actorID uuid.UUID) (*models.CreateAPIKeyResponse, error) {
+	principalID := actorID
+	if req.UserID != nil {
+		principalID = *req.UserID
+	}
+	principal, err := s.userRepo.GetByID(ctx, principalID)
+	if err == errors.ErrNotFound {
+		return nil, errors.NewError("VALIDATION_ERROR", "User not found", http.StatusBadRequest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if principal.OrgID == nil || *principal.OrgID != orgID {
+		return nil, errors.NewError("VALIDATION_ERROR", "API keys can only act as a user of the organization", http.StatusBadRequest)
+	}
+	if principal.Status != "active" {
+		return nil, errors.NewError("VALIDATION_ERROR", "API keys can only act as an active user", http.StatusBadRequest)
+	}
+
+	scopes, err := checkScopes(ctx, s.userRepo, principal.ID, req.Scopes, "key's")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActorGrants(ctx, s.userRepo, orgID, actorID, principal.ID, scopes, "API keys"); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.NewError("VALIDATION_ERROR", "expires_at must be in the future", http.StatusBadRequest)
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate API key", errors.ErrInternalServer.Status)
+	}
+	plaintext := APIKeyPrefix + strings.TrimRight(secret, "=")
+
+	key := &models.APIKey{
+		OrgID:     orgID,
+		UserID:    principal.ID,
+		Name:      req.Name,
+		Prefix:    plaintext[:len(APIKeyPrefix)+8],
+		KeyHash:   utils.HashToken(plaintext),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: &actorID,
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, key, "api_key.created", &actorID)
+	return &models.CreateAPIKeyResponse{APIKey: key, Key: plaintext}, nil
+}
+
+// Revoke stops a key from authenticating; the row is kept so its use stays attributable
+func (s *APIKeyService) Revoke(ctx context.Context, orgID, id uuid.UUID, actorID *uuid.UUID) (*models.APIKey, error) {
+	key, err := s.repo.Revoke(ctx, orgID, id, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, key, "api_key.revoked", actorID)
+	return key, nil
+}
+
+// Authenticate resolves the key from an `Authorization: ApiKey` header to the key and the user it
+// acts as. Unknown, revoked and expired keys, and keys whose user is no longer an active member of
+// the key's org, all return ErrInvalidAPIKey.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext, ipAddress string) (*models.APIKey, *models.User, error) {
+	if !strings.HasPrefix(plaintext, APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByHash(ctx, utils.HashToken(plaintext))
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(ctx, key.UserID)
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Status != "active" || user.OrgID == nil || *user.OrgID != key.OrgID {
+		log.Printf("API key %s rejected: user %s is %s in org %v", key.ID, user.ID, user.Status, user.OrgID)
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, ipAddress); err != nil {
+			log.Printf("Warning: Failed to update last_used_at for API key %s: %v", key.ID, err)
+		}
+	}
+
+	return key, user, nil
+}
+
+// checkScopes validates and de-duplicates the scopes of an API key or OAuth client, refusing any its
+// user does not hold; holder names it in the error ("key's", "client's")
+func checkScopes(ctx context.Context, userRepo *repositories.UserRepository, userID uuid.UUID, requested []string, holder string) ([]string, error) {
+	seen := make(map[string]bool, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !apiKeyScopePattern.MatchString(scope) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+
+		resource, action, _ := strings.Cut(scope, ":")
+		allowed, err := userRepo.HasPermission(ctx, userID, resource, action)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errors.NewError("FORBIDDEN", "The "+holder+" user does not have the "+scope+" permission", http.StatusForbidden)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// checkActorGrants stops the caller from handing out access they do not have through an API key or
+// OAuth client: acting as another user takes an org admin or super admin, and every scope must be one
+// of the caller's own permissions. kind names the credential in the errors ("API keys").
+func checkActorGrants(ctx context.Context, userRepo *repositories.UserRepository, orgID, actorID, principalID uuid.UUID, scopes []string, kind string) error {
+	if principalID == actorID {
+		return nil // checkScopes already held the scopes against the caller
+	}
+	actor, err := userRepo.GetByID(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	if actor.IsSuperAdmin {
+		return nil
+	}
+	if actor.OrgID == nil || *actor.OrgID != orgID || actor.OrgRole == nil || *actor.OrgRole != "admin" {
+		return errors.NewError("FORBIDDEN", "Only org admins can create "+kind+" that act as another user", http.StatusForbidden)
+	}
+	for _, scope := range scopes {
+		resource, action, _ := strings.Cut(scope, ":")
+		allowed, err := userRepo.HasPermission(ctx, actorID, resource, action)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.NewError("FORBIDDEN", "You do not have the "+scope+" permission", http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+func (s *APIKeyService) audit(ctx context.Context, key *models.APIKey, action string, actorID *uuid.UUID) {
+	resourceType := "api_key"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       actorID,
+		OrgID:        &key.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &key.ID,
+		Status:       "success",
+		Metadata: map[string]interface{}{
+			"name":    key.Name,
+			"prefix":  key.Prefix,
+			"user_id": key.UserID.String(),
+			"scopes":  key.Scopes,
+		},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for API key %s: %v", action, key.ID, err)
+	}
+}
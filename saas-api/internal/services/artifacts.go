@@ -0,0 +1,356 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/orgtime"
+
+	"github.com/google/uuid"
+)
+
+const (
+	artifactBatchSize = 500
+
+	// artifactGzipSuffix is appended to an artifact's path once it has been compressed
+	artifactGzipSuffix = ".gz"
+
+	// artifactsPurgedKey records in content.processing_data when a document's artifacts were removed
+	artifactsPurgedKey = "artifacts_purged_at"
+)
+
+// readArtifact reads a processing artifact, transparently using its compressed copy
+func readArtifact(jsonFilePath string) ([]byte, error) {
+	data, err := os.ReadFile(jsonFilePath)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+
+	f, gzErr := os.Open(jsonFilePath + artifactGzipSuffix)
+	if gzErr != nil {
+		return nil, err // Report the original path as missing
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// removeArtifact deletes an artifact and its compressed copy, returning the bytes freed.
+// Missing files are not an error.
+func removeArtifact(jsonFilePath string) (int64, error) {
+	var freed int64
+	for _, p := range []string{jsonFilePath, jsonFilePath + artifactGzipSuffix} {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return freed, fmt.Errorf("failed to remove artifact %s: %w", p, err)
+		}
+		freed += info.Size()
+	}
+	return freed, nil
+}
+
+// compressArtifact replaces an artifact with a gzip copy. The copy keeps the original modification
+// time, so retention is still counted from when the document was processed.
+func compressArtifact(jsonFilePath string, modTime time.Time) error {
+	src, err := os.Open(jsonFilePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	gzPath := jsonFilePath + artifactGzipSuffix
+	tmpPath := gzPath + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmpPath, modTime, modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, gzPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(jsonFilePath)
+}
+
+// artifactFile is an artifact found on disk during a sweep
+type artifactFile struct {
+	documentID int64
+	jsonPath   string // Uncompressed path, as stored on the document
+	compressed bool
+	size       int64
+	modTime    time.Time
+}
+
+// ArtifactSweepResult summarizes one lifecycle pass
+type ArtifactSweepResult struct {
+	Compressed int
+	Deleted    int
+	FreedBytes int64
+}
+
+// ArtifactLifecycleJob periodically compresses old processing artifacts, deletes them after the
+// retention period (or as soon as their document is deleted) and enforces the per-org quota by
+// deleting an org's oldest artifacts first. Source files and Weaviate embeddings are never touched.
+// Ages are counted in full days of the document's organization timezone.
+type ArtifactLifecycleJob struct {
+	docRepo   *repositories.DocumentRepository
+	timezones *orgtime.Resolver
+	cfg       config.ArtifactConfig
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewArtifactLifecycleJob(docRepo *repositories.DocumentRepository, timezones *orgtime.Resolver, cfg config.ArtifactConfig) *ArtifactLifecycleJob {
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = 360
+	}
+	return &ArtifactLifecycleJob{
+		docRepo:   docRepo,
+		timezones: timezones,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the background loop; it is a no-op when compression, retention and quotas are all disabled
+func (j *ArtifactLifecycleJob) Start() {
+	if j.cfg.CompressAfterDays <= 0 && j.cfg.RetentionDays <= 0 && j.cfg.OrgQuotaMB <= 0 {
+		log.Println("Artifact lifecycle disabled (ARTIFACT_COMPRESS_AFTER_DAYS, ARTIFACT_RETENTION_DAYS and ARTIFACT_ORG_QUOTA_MB are 0)")
+		close(j.done)
+		return
+	}
+
+	log.Printf("Artifact lifecycle enabled: compress after %d days, retain %d days, org quota %d MB (every %d minutes; 0 = off)",
+		j.cfg.CompressAfterDays, j.cfg.RetentionDays, j.cfg.OrgQuotaMB, j.cfg.SweepInterval)
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(time.Duration(j.cfg.SweepInterval) * time.Minute)
+		defer ticker.Stop()
+
+		j.RunOnce(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce(context.Background())
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop
+func (j *ArtifactLifecycleJob) Stop() {
+	select {
+	case <-j.done:
+		return
+	default:
+	}
+	close(j.stop)
+	<-j.done
+}
+
+// RunOnce makes one lifecycle pass over every document's artifacts
+func (j *ArtifactLifecycleJob) RunOnce(ctx context.Context) ArtifactSweepResult {
+	var result ArtifactSweepResult
+	now := time.Now()
+	byOrg := make(map[uuid.UUID][]*artifactFile)
+
+	var afterID int64
+	for {
+		artifacts, err := j.docRepo.ListArtifacts(ctx, afterID, artifactBatchSize)
+		if err != nil {
+			log.Printf("Artifact lifecycle: failed to list artifacts: %v", err)
+			return result
+		}
+		if len(artifacts) == 0 {
+			break
+		}
+		afterID = artifacts[len(artifacts)-1].DocumentID
+
+		for _, artifact := range artifacts {
+			file := statArtifact(artifact)
+			if file == nil {
+				continue
+			}
+			loc := j.timezones.Location(ctx, artifact.OrgID)
+			retainAfter := orgtime.DaysBefore(now, loc, j.cfg.RetentionDays)
+			compressBefore := orgtime.DaysBefore(now, loc, j.cfg.CompressAfterDays)
+
+			if artifact.Deleted || (j.cfg.RetentionDays > 0 && file.modTime.Before(retainAfter)) {
+				j.purge(ctx, file, !artifact.Deleted, &result)
+				continue
+			}
+
+			if j.cfg.CompressAfterDays > 0 && !file.compressed && file.modTime.Before(compressBefore) {
+				if err := compressArtifact(file.jsonPath, file.modTime); err != nil {
+					log.Printf("Artifact lifecycle: failed to compress %s: %v", file.jsonPath, err)
+				} else {
+					result.Compressed++
+					if info, err := os.Stat(file.jsonPath + artifactGzipSuffix); err == nil {
+						file.size = info.Size()
+					}
+				}
+			}
+
+			if artifact.OrgID != nil {
+				byOrg[*artifact.OrgID] = append(byOrg[*artifact.OrgID], file)
+			}
+		}
+
+		if len(artifacts) < artifactBatchSize {
+			break
+		}
+	}
+
+	if j.cfg.OrgQuotaMB > 0 {
+		quota := int64(j.cfg.OrgQuotaMB) << 20
+		for orgID, files := range byOrg {
+			j.enforceQuota(ctx, orgID, files, quota, &result)
+		}
+	}
+
+	if result.Compressed > 0 || result.Deleted > 0 {
+		log.Printf("Artifact lifecycle: compressed %d, deleted %d (%d bytes freed)", result.Compressed, result.Deleted, result.FreedBytes)
+	}
+	return result
+}
+
+// Schedule describes compression and retention for an organization in the given timezone
+func (j *ArtifactLifecycleJob) Schedule(now time.Time, loc *time.Location) []models.ScheduledJob {
+	jobs := []models.ScheduledJob{
+		{
+			Name:            "artifact_compression",
+			Enabled:         j.cfg.CompressAfterDays > 0,
+			IntervalMinutes: j.cfg.SweepInterval,
+			Description:     fmt.Sprintf("Processing artifacts older than %d full local days are gzip-compressed", j.cfg.CompressAfterDays),
+		},
+		{
+			Name:            "artifact_retention",
+			Enabled:         j.cfg.RetentionDays > 0,
+			IntervalMinutes: j.cfg.SweepInterval,
+			Description:     fmt.Sprintf("Processing artifacts older than %d full local days are deleted", j.cfg.RetentionDays),
+		},
+	}
+	for i, days := range []int{j.cfg.CompressAfterDays, j.cfg.RetentionDays} {
+		if days > 0 {
+			cutoff := orgtime.DaysBefore(now, loc, days)
+			jobs[i].Cutoff = &cutoff
+		}
+	}
+	return jobs
+}
+
+// enforceQuota deletes an org's oldest artifacts until its total is within quota
+func (j *ArtifactLifecycleJob) enforceQuota(ctx context.Context, orgID uuid.UUID, files []*artifactFile, quota int64, result *ArtifactSweepResult) {
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+	if total <= quota {
+		return
+	}
+
+	sort.Slice(files, func(a, b int) bool { return files[a].modTime.Before(files[b].modTime) })
+	deleted := 0
+	for _, file := range files {
+		if total <= quota {
+			break
+		}
+		total -= file.size
+		j.purge(ctx, file, true, result)
+		deleted++
+	}
+	log.Printf("Artifact lifecycle: org %s over its %d MB quota, deleted its %d oldest artifacts", orgID, j.cfg.OrgQuotaMB, deleted)
+}
+
+func (j *ArtifactLifecycleJob) purge(ctx context.Context, file *artifactFile, record bool, result *ArtifactSweepResult) {
+	freed, err := removeArtifact(file.jsonPath)
+	result.FreedBytes += freed
+	if err != nil {
+		log.Printf("Artifact lifecycle: %v", err)
+		return
+	}
+	result.Deleted++
+	if record {
+		if err := j.docRepo.SetProcessingData(ctx, file.documentID, artifactsPurgedKey, time.Now().UTC()); err != nil {
+			log.Printf("Artifact lifecycle: failed to record purge for document %d: %v", file.documentID, err)
+		}
+	}
+}
+
+// statArtifact finds an artifact on disk: the plain file, or its compressed copy. Returns nil when
+// neither exists (never processed, or already purged).
+func statArtifact(artifact *repositories.DocumentArtifact) *artifactFile {
+	for _, compressed := range []bool{false, true} {
+		p := artifact.JsonFilePath
+		if compressed {
+			p += artifactGzipSuffix
+		}
+		if info, err := os.Stat(p); err == nil {
+			return &artifactFile{
+				documentID: artifact.DocumentID,
+				jsonPath:   artifact.JsonFilePath,
+				compressed: compressed,
+				size:       info.Size(),
+				modTime:    info.ModTime(),
+			}
+		}
+	}
+	return nil
+}
+
+// PurgeArtifacts deletes a document's processing artifacts, keeping the source file and its
+// embeddings, and returns the bytes freed. Snippets already cached stay available.
+func (s *DocumentService) PurgeArtifacts(ctx context.Context, documentID int64) (int64, error) {
+	doc, err := s.repositories.Document.GetByID(ctx, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("document not found: %w", err)
+	}
+	if doc.JsonFilePath == nil || *doc.JsonFilePath == "" {
+		return 0, nil
+	}
+
+	freed, err := removeArtifact(*doc.JsonFilePath)
+	if err != nil {
+		return freed, err
+	}
+	if err := s.repositories.Document.SetProcessingData(ctx, documentID, artifactsPurgedKey, time.Now().UTC()); err != nil {
+		fmt.Printf("⚠️  Failed to record artifact purge for document %d: %v\n", documentID, err)
+	}
+	return freed, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"strings"
@@ -22,6 +23,19 @@ type AuthService struct {
 	tokenRepo    *repositories.RefreshTokenRepository
 	tokenService *auth.TokenService
 	config       *config.Config
+	ssoPolicy    SSOPolicy              // nil: no org enforces single sign-on
+	twoFactor    *TwoFactorService      // nil: no second factor is asked for
+	lockout      *LockoutService        // nil: the LOCKOUT_* defaults apply to every org
+	otpDelivery  *OTPDelivery           // nil: OTP codes are emailed, without limits
+	deviceAlerts *NewDeviceAlertService // nil: sign-ins from new devices are not alerted
+	loginHistory *LoginHistoryService   // nil: sign-in attempts are not recorded
+	features     *FeatureService        // nil: login responses list no features
+	proxy        *ProxySessions         // nil: proxy sessions outlive password resets and email changes
+}
+
+// SSOPolicy tells whether an org requires its users to sign in through its identity provider
+type SSOPolicy interface {
+	SSORequired(ctx context.Context, orgID uuid.UUID) bool
 }
 
 func NewAuthService(
@@ -38,6 +52,107 @@ func NewAuthService(
 	}
 }
 
+// SetSSOPolicy turns password and OTP sign-in off for the users of orgs that enforce single sign-on
+func (s *AuthService) SetSSOPolicy(policy SSOPolicy) {
+	s.ssoPolicy = policy
+}
+
+// SetTwoFactor makes password and OTP sign-ins of users with 2FA (or whose org requires it) stop at
+// an MFA token instead of returning the tokens
+func (s *AuthService) SetTwoFactor(twoFactor *TwoFactorService) {
+	s.twoFactor = twoFactor
+}
+
+// SetLockout applies each org's lockout policy to failed sign-ins
+func (s *AuthService) SetLockout(lockout *LockoutService) {
+	s.lockout = lockout
+}
+
+// SetOTPDelivery sends OTP codes on each user's channel (email or SMS), within per-channel limits
+func (s *AuthService) SetOTPDelivery(otpDelivery *OTPDelivery) {
+	s.otpDelivery = otpDelivery
+}
+
+// SetNewDeviceAlerts emails users who sign in from a device and IP address not seen before
+func (s *AuthService) SetNewDeviceAlerts(deviceAlerts *NewDeviceAlertService) {
+	s.deviceAlerts = deviceAlerts
+}
+
+// SetLoginHistory records every sign-in attempt in the user's login history
+func (s *AuthService) SetLoginHistory(loginHistory *LoginHistoryService) {
+	s.loginHistory = loginHistory
+}
+
+// SetFeatures lists the features of the user's org in login responses and token introspection
+func (s *AuthService) SetFeatures(features *FeatureService) {
+	s.features = features
+}
+
+// SetProxySessions ends the user's LibreChat proxy sessions along with their saas-api sessions
+// when their password is reset or their email changes
+func (s *AuthService) SetProxySessions(proxy *ProxySessions) {
+	s.proxy = proxy
+}
+
+// enabledFeatures names the features the user may use, for the frontend and sibling services
+func (s *AuthService) enabledFeatures(ctx context.Context, user *models.User) []string {
+	if s.features == nil {
+		return nil
+	}
+	return s.features.Enabled(ctx, user)
+}
+
+// recordLogin adds a sign-in attempt to the login history of user; attempts without a user are not
+// recorded
+func (s *AuthService) recordLogin(ctx context.Context, method string, user *models.User, ipAddress, userAgent string, response *models.LoginResponse, err error) {
+	if s.loginHistory == nil || user == nil {
+		return
+	}
+	s.loginHistory.Record(ctx, method, user, ipAddress, userAgent, response, err)
+}
+
+// recordFailedLogin counts a wrong password or 2FA code towards locking the user's account
+func (s *AuthService) recordFailedLogin(ctx context.Context, user *models.User) {
+	if s.lockout != nil {
+		s.lockout.RecordFailure(ctx, user)
+		return
+	}
+	lockFor := time.Duration(s.config.Lockout.Duration) * time.Minute
+	_, _ = s.userRepo.IncrementFailedLoginAttempts(ctx, user.ID, s.config.Lockout.MaxAttempts, lockFor)
+}
+
+// finishLogin completes a sign-in that passed its first factor: it returns the tokens, or the MFA
+// token when a second factor is needed still
+func (s *AuthService) finishLogin(ctx context.Context, user *models.User, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	if s.twoFactor != nil {
+		challenge, err := s.twoFactor.challenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if challenge != nil {
+			return challenge, nil
+		}
+	}
+
+	// Update login info
+	_ = s.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+
+	return s.issueTokens(ctx, user, ipAddress, userAgent)
+}
+
+// checkSSOPolicy refuses password and OTP sign-in to users whose org enforces single sign-on. Super
+// admins and org admins are exempt, so a broken IdP cannot lock an org out.
+func (s *AuthService) checkSSOPolicy(ctx context.Context, user *models.User) error {
+	if s.ssoPolicy == nil || user.OrgID == nil || user.IsSuperAdmin || (user.OrgRole != nil && *user.OrgRole == "admin") {
+		return nil
+	}
+	if s.ssoPolicy.SSORequired(ctx, *user.OrgID) {
+		log.Printf("Login refused - org of %s enforces single sign-on", user.Email)
+		return errors.NewError("SSO_REQUIRED", "Your organization requires signing in through single sign-on", 403)
+	}
+	return nil
+}
+
 // canLoginViaOTP checks if a user can login via OTP
 // Returns true if user is:
 // - Super admin (if status is active), OR
@@ -86,6 +201,13 @@ func (s *AuthService) canLoginViaOTP(ctx context.Context, user *models.User) (bo
 }
 
 func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	user, response, err := s.login(ctx, req, ipAddress, userAgent)
+	s.recordLogin(ctx, models.LoginMethodPassword, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// login is Login; it also returns the user the email belongs to, if any, for the login history
+func (s *AuthService) login(ctx context.Context, req *models.LoginRequest, ipAddress, userAgent string) (*models.User, *models.LoginResponse, error) {
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
@@ -93,87 +215,52 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ipAdd
 		log.Printf("Login failed - GetByEmail error for %s: %v", req.Email, err)
 		// Return unauthorized for both user not found and other errors
 		// to prevent user enumeration attacks
-		return nil, errors.ErrUnauthorized
+		return nil, nil, errors.ErrUnauthorized
 	}
 
 	// Check if account is locked
 	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
-		return nil, errors.NewError("ACCOUNT_LOCKED", "Account is locked due to failed login attempts", 423)
+		return user, nil, errors.NewError("ACCOUNT_LOCKED", "Account is locked due to failed login attempts", 423)
 	}
 
 	// Verify password
 	passwordValid := utils.CheckPasswordHash(req.Password, user.PasswordHash)
 	if !passwordValid {
 		log.Printf("Login failed - Password mismatch for user %s", req.Email)
-		_ = s.userRepo.IncrementFailedLoginAttempts(ctx, user.ID)
-		return nil, errors.ErrUnauthorized
+		s.recordFailedLogin(ctx, user)
+		return user, nil, errors.ErrUnauthorized
 	}
 
-	// Check user status - suspended and pending users cannot login
-	if user.Status == "suspended" {
-		log.Printf("Login failed - User %s is suspended", req.Email)
-		return nil, errors.NewError("ACCOUNT_SUSPENDED", "Your account has been suspended. Please contact your administrator.", 403)
+	if err := checkAccountStatus(user); err != nil {
+		return user, nil, err
 	}
 
-	if user.Status == "pending" {
-		log.Printf("Login failed - User %s is pending approval", req.Email)
-		return nil, errors.NewError("ACCOUNT_PENDING", "Your account is pending approval. Please contact your administrator.", 403)
+	if err := s.checkSSOPolicy(ctx, user); err != nil {
+		return user, nil, err
 	}
 
-	// Only active users can login
-	if user.Status != "active" {
-		log.Printf("Login failed - User %s has invalid status: %s", req.Email, user.Status)
-		return nil, errors.NewError("ACCOUNT_INACTIVE", "Your account is not active. Please contact your administrator.", 403)
-	}
-
-	// Update login info
-	_ = s.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
-
-	// Generate tokens
-	accessToken, err := s.tokenService.GenerateAccessToken(user)
-	if err != nil {
-		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
-	}
-
-	refreshToken, err := s.tokenService.GenerateRefreshToken(user.ID)
-	if err != nil {
-		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate refresh token", errors.ErrInternalServer.Status)
-	}
+	response, err := s.finishLogin(ctx, user, ipAddress, userAgent)
+	return user, response, err
+}
 
-	// Store refresh token
-	tokenHash := utils.HashToken(refreshToken)
-	refreshTokenModel := &models.RefreshToken{
-		ID:         uuid.New(),
-		UserID:     user.ID,
-		TokenHash:  tokenHash,
-		DeviceInfo: map[string]interface{}{"user_agent": userAgent},
-		IPAddress:  &ipAddress,
-		UserAgent:  &userAgent,
-		ExpiresAt:  time.Now().Add(time.Duration(s.config.JWT.RefreshTokenTTL) * 24 * time.Hour),
+// checkAccountStatus refuses sign-in to users who are not active: suspended and pending users cannot login
+func checkAccountStatus(user *models.User) error {
+	if user.Status == "suspended" {
+		log.Printf("Login failed - User %s is suspended", user.Email)
+		return errors.NewError("ACCOUNT_SUSPENDED", "Your account has been suspended. Please contact your administrator.", 403)
 	}
 
-	if err := s.tokenRepo.Create(ctx, refreshTokenModel); err != nil {
-		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to store refresh token", errors.ErrInternalServer.Status)
+	if user.Status == "pending" {
+		log.Printf("Login failed - User %s is pending approval", user.Email)
+		return errors.NewError("ACCOUNT_PENDING", "Your account is pending approval. Please contact your administrator.", 403)
 	}
 
-	// Clear password hash from response
-	user.PasswordHash = ""
-
-	// Get user permissions
-	permissions, err := s.userRepo.GetUserPermissions(ctx, user.ID)
-	if err != nil {
-		log.Printf("Warning: Failed to get user permissions for %s: %v", user.Email, err)
-		permissions = []*models.Permission{} // Return empty permissions on error
+	// Only active users can login
+	if user.Status != "active" {
+		log.Printf("Login failed - User %s has invalid status: %s", user.Email, user.Status)
+		return errors.NewError("ACCOUNT_INACTIVE", "Your account is not active. Please contact your administrator.", 403)
 	}
-
-	return &models.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    s.config.JWT.AccessTokenTTL * 60, // seconds
-		User:         user,
-		Permissions:  convertPermissions(permissions),
-	}, nil
+	return nil
 }
 
 func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, ipAddress string) (*models.LoginResponse, error) {
@@ -247,7 +334,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, ipA
 	_ = s.tokenRepo.UpdateLastUsed(ctx, storedToken.ID)
 
 	// Generate new access token
-	accessToken, err := s.tokenService.GenerateAccessToken(user)
+	accessToken, err := s.tokenService.GenerateRefreshedAccessToken(user, claims)
 	if err != nil {
 		log.Printf("RefreshToken: Failed to generate access token for user %s: %v", user.Email, err)
 		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
@@ -270,6 +357,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, ipA
 		ExpiresIn:    s.config.JWT.AccessTokenTTL * 60,
 		User:         user,
 		Permissions:  convertPermissions(permissions),
+		Features:     s.enabledFeatures(ctx, user),
 	}, nil
 }
 
@@ -287,6 +375,56 @@ func (s *AuthService) Logout(ctx context.Context, refreshToken string, userID uu
 	return nil
 }
 
+// ExchangeProxyToken trades the LibreChat proxy session token for a short-lived access token.
+// Only callers presenting the shared proxy secret may exchange; this is a server-to-server call.
+func (s *AuthService) ExchangeProxyToken(ctx context.Context, proxySecret, proxyToken string) (*models.LoginResponse, error) {
+	if s.config.Proxy.SharedSecret == "" {
+		return nil, errors.NewError("NOT_FOUND", "Proxy token exchange is not enabled", 404)
+	}
+	if subtle.ConstantTimeCompare([]byte(proxySecret), []byte(s.config.Proxy.SharedSecret)) != 1 {
+		return nil, errors.ErrUnauthorized
+	}
+
+	claims, err := s.tokenService.ValidateToken(proxyToken)
+	if err != nil || claims.Email == "" {
+		return nil, errors.NewError("UNAUTHORIZED", "Invalid or expired proxy token", 401)
+	}
+	// Exchanging would drop the impersonator and outlive the impersonation token
+	if claims.ImpersonatorID != nil {
+		return nil, errors.NewError("UNAUTHORIZED", "Impersonation tokens cannot be exchanged", 401)
+	}
+	// Exchanging would drop the client's scopes
+	if claims.ClientID != nil {
+		return nil, errors.NewError("UNAUTHORIZED", "OAuth client tokens cannot be exchanged", 401)
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	if err != nil {
+		log.Printf("Proxy token exchange failed - GetByEmail error for %s: %v", claims.Email, err)
+		return nil, errors.ErrUnauthorized
+	}
+
+	if user.Status != "active" {
+		return nil, errors.NewError("ACCOUNT_INACTIVE", "Your account is not active. Please contact your administrator.", 403)
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return nil, errors.NewError("ACCOUNT_LOCKED", "Account is locked due to failed login attempts", 423)
+	}
+
+	ttl := time.Duration(s.config.Proxy.ExchangeTokenTTL) * time.Second
+	accessToken, err := s.tokenService.GenerateAccessTokenWithTTL(user, ttl)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
+	}
+
+	return &models.LoginResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		User:        user,
+	}, nil
+}
+
 func (s *AuthService) GetClientIP(r interface{}) string {
 	// This will be implemented in middleware
 	return ""
@@ -313,6 +451,15 @@ func (s *AuthService) SendOTP(ctx context.Context, email string) (*models.SendOT
 		log.Printf("SendOTP: User %s is not authorized to login via OTP", email)
 		return nil, errors.NewError("UNAUTHORIZED", "Only super admins, organization admins, or verified active users can login via OTP", 403)
 	}
+	if err := s.checkSSOPolicy(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Pick the channels before replacing the code, so a rate-limited request keeps the last one valid
+	channels, err := s.otpChannels(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate OTP
 	otp, err := utils.GenerateOTP()
@@ -326,53 +473,66 @@ func (s *AuthService) SendOTP(ctx context.Context, email string) (*models.SendOT
 		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to store OTP", errors.ErrInternalServer.Status)
 	}
 
-	// Send OTP via email
-	log.Printf("SendOTP: Attempting to send OTP email to %s", email)
-	if err := utils.SendOTPEmail(email, otp); err != nil {
-		log.Printf("SendOTP: Failed to send OTP email to %s", email)
+	// Send OTP on the user's channel, falling back to email
+	log.Printf("SendOTP: Attempting to send OTP to %s via %v", email, channels)
+	channel, err := s.deliverOTP(ctx, user, channels, otp)
+	if err != nil {
+		log.Printf("SendOTP: Failed to send OTP to %s", email)
 		log.Printf("SendOTP: Error details: %v", err)
 		log.Printf("SendOTP: OTP generated was: %s (stored in database)", otp)
 		// Return a more descriptive error
 		return nil, errors.WrapError(err, "EMAIL_SEND_FAILED", fmt.Sprintf("Failed to send OTP email: %v", err), errors.ErrInternalServer.Status)
 	}
-	log.Printf("SendOTP: OTP email sent successfully to %s", email)
+	log.Printf("SendOTP: OTP sent successfully to %s via %s", email, channel)
 
-	return &models.SendOTPResponse{
-		Message: "OTP sent to your email",
-		Email:   email,
-	}, nil
+	return otpSentResponse(email, channel, "OTP sent"), nil
 }
 
 // VerifyOTP verifies OTP and returns login tokens
 func (s *AuthService) VerifyOTP(ctx context.Context, email, otp, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	user, response, err := s.verifyOTP(ctx, email, otp, ipAddress, userAgent)
+	s.recordLogin(ctx, models.LoginMethodOTP, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// verifyOTP is VerifyOTP; it also returns the user the email belongs to, if any, for the login history
+func (s *AuthService) verifyOTP(ctx context.Context, email, otp, ipAddress, userAgent string) (*models.User, *models.LoginResponse, error) {
 	// Verify OTP (this will increment attempts if invalid)
 	if err := s.userRepo.VerifyOTP(ctx, email, otp); err != nil {
+		user, _ := s.userRepo.GetByEmail(ctx, email)
 		if appErr, ok := err.(*errors.AppError); ok {
-			return nil, appErr
+			return user, nil, appErr
 		}
-		return nil, errors.NewError("INVALID_OTP", "Invalid OTP", 401)
+		return user, nil, errors.NewError("INVALID_OTP", "Invalid OTP", 401)
 	}
 
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
-		return nil, errors.ErrUnauthorized
+		return nil, nil, errors.ErrUnauthorized
 	}
 
 	// Check if user can login via OTP (super admin, org admin, or verified+active)
 	canLogin, err := s.canLoginViaOTP(ctx, user)
 	if err != nil {
-		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to check user permissions", errors.ErrInternalServer.Status)
+		return user, nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to check user permissions", errors.ErrInternalServer.Status)
 	}
 	if !canLogin {
 		log.Printf("VerifyOTP: User %s is not authorized to login via OTP", email)
-		return nil, errors.NewError("UNAUTHORIZED", "Only super admins, organization admins, or verified active users can login via OTP", 403)
+		return user, nil, errors.NewError("UNAUTHORIZED", "Only super admins, organization admins, or verified active users can login via OTP", 403)
 	}
 
-	// Update login info
-	_ = s.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+	if err := s.checkSSOPolicy(ctx, user); err != nil {
+		return user, nil, err
+	}
+
+	response, err := s.finishLogin(ctx, user, ipAddress, userAgent)
+	return user, response, err
+}
 
-	// Generate tokens
+// issueTokens signs a user in: it issues an access token and a stored refresh token, and returns
+// them with the user's permissions
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, ipAddress, userAgent string) (*models.LoginResponse, error) {
 	accessToken, err := s.tokenService.GenerateAccessToken(user)
 	if err != nil {
 		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
@@ -383,13 +543,18 @@ func (s *AuthService) VerifyOTP(ctx context.Context, email, otp, ipAddress, user
 		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate refresh token", errors.ErrInternalServer.Status)
 	}
 
+	fingerprint := deviceFingerprint(userAgent)
+	if s.deviceAlerts != nil {
+		s.deviceAlerts.Check(ctx, user, fingerprint, ipAddress, userAgent)
+	}
+
 	// Store refresh token
 	tokenHash := utils.HashToken(refreshToken)
 	refreshTokenModel := &models.RefreshToken{
 		ID:         uuid.New(),
 		UserID:     user.ID,
 		TokenHash:  tokenHash,
-		DeviceInfo: map[string]interface{}{"user_agent": userAgent},
+		DeviceInfo: map[string]interface{}{"user_agent": userAgent, "fingerprint": fingerprint},
 		IPAddress:  &ipAddress,
 		UserAgent:  &userAgent,
 		ExpiresAt:  time.Now().Add(time.Duration(s.config.JWT.RefreshTokenTTL) * 24 * time.Hour),
@@ -416,6 +581,7 @@ func (s *AuthService) VerifyOTP(ctx context.Context, email, otp, ipAddress, user
 		ExpiresIn:    s.config.JWT.AccessTokenTTL * 60, // seconds
 		User:         user,
 		Permissions:  convertPermissions(permissions),
+		Features:     s.enabledFeatures(ctx, user),
 	}, nil
 }
 
@@ -449,6 +615,14 @@ func (s *AuthService) ResendOTP(ctx context.Context, email string) (*models.Send
 		log.Printf("ResendOTP: User %s is not authorized to login via OTP", email)
 		return nil, errors.NewError("UNAUTHORIZED", "Only super admins, organization admins, or verified active users can login via OTP", 403)
 	}
+	if err := s.checkSSOPolicy(ctx, user); err != nil {
+		return nil, err
+	}
+
+	channels, err := s.otpChannels(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate new OTP
 	otp, err := utils.GenerateOTP()
@@ -462,14 +636,54 @@ func (s *AuthService) ResendOTP(ctx context.Context, email string) (*models.Send
 		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to store OTP", errors.ErrInternalServer.Status)
 	}
 
-	// Send OTP via email
-	if err := utils.SendOTPEmail(email, otp); err != nil {
-		log.Printf("Failed to send OTP email to %s: %v", email, err)
+	// Send OTP on the user's channel, falling back to email
+	channel, err := s.deliverOTP(ctx, user, channels, otp)
+	if err != nil {
+		log.Printf("Failed to send OTP to %s: %v", email, err)
 		// Still return success to prevent revealing email issues
+		channel = models.OTPChannelEmail
 	}
 
+	return otpSentResponse(email, channel, "OTP resent"), nil
+}
+
+// SetOTPChannel stores the channel (email or sms) the user wants sign-in codes on
+func (s *AuthService) SetOTPChannel(ctx context.Context, userID uuid.UUID, channel string) error {
+	if s.otpDelivery == nil {
+		if channel == models.OTPChannelSMS {
+			return ErrSMSUnavailable
+		}
+		return s.userRepo.SetOTPChannel(ctx, userID, channel)
+	}
+	return s.otpDelivery.SetPreference(ctx, userID, channel)
+}
+
+// otpChannels returns the channels to try for the user's code, in order
+func (s *AuthService) otpChannels(ctx context.Context, user *models.User) ([]string, error) {
+	if s.otpDelivery == nil {
+		return []string{models.OTPChannelEmail}, nil
+	}
+	return s.otpDelivery.Channels(ctx, user)
+}
+
+// deliverOTP sends the code on the first of channels that works and returns that channel
+func (s *AuthService) deliverOTP(ctx context.Context, user *models.User, channels []string, code string) (string, error) {
+	if s.otpDelivery == nil {
+		return models.OTPChannelEmail, utils.SendOTPEmail(user.Email, code)
+	}
+	return s.otpDelivery.Send(ctx, user, channels, code)
+}
+
+// otpSentResponse tells where the code went: "OTP sent to your email", or to the phone for SMS
+func otpSentResponse(email, channel, message string) *models.SendOTPResponse {
+	if channel == models.OTPChannelSMS {
+		message += " to your phone"
+	} else {
+		message += " to your email"
+	}
 	return &models.SendOTPResponse{
-		Message: "OTP resent to your email",
+		Message: message,
 		Email:   email,
-	}, nil
+		Channel: channel,
+	}
 }
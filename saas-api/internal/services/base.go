@@ -45,10 +45,11 @@ func (s *BaseService) GetWeaviateClient() *weaviate.WeaviateClient {
 
 // Services holds all service instances
 type Services struct {
-	base     *BaseService // Store base service for repository access
-	Health   *HealthService
-	Auth     *AuthService
-	Document *DocumentService
+	base       *BaseService // Store base service for repository access
+	Health     *HealthService
+	Auth       *AuthService
+	Document   *DocumentService
+	Permission *PermissionService
 }
 
 func NewServices(base *BaseService, userRepo *repositories.UserRepository, tokenRepo *repositories.RefreshTokenRepository, tokenService *auth.TokenService, cfg *configs.Config) *Services {
@@ -62,10 +63,12 @@ func NewServices(base *BaseService, userRepo *repositories.UserRepository, token
 	// We need to check what config type is needed
 	authService := NewAuthService(userRepo, tokenRepo, tokenService, nil) // TODO: Fix config conversion
 
+	repos := base.GetRepositories()
 	return &Services{
-		base:     base, // Store base service
-		Auth:     authService,
-		Document: documentService,
+		base:       base, // Store base service
+		Auth:       authService,
+		Document:   documentService,
+		Permission: NewPermissionService(repos.Permission, repos.AuditLog),
 	}
 }
 
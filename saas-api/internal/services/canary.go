@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/tenant"
+	"saas-api/pkg/weaviate"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// canaryKeyPrefix + version is the content.processing_data key holding a document's canary result
+	canaryKeyPrefix = "canary_"
+
+	// canaryAlpha matches the search endpoint's default, so comparisons reflect what users see
+	canaryAlpha = float32(0.6)
+)
+
+var canaryVersionPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+
+// CanaryRun is the progress of a shadow run of the canary pipeline over a sample of documents
+type CanaryRun struct {
+	Version     string     `json:"version"`
+	Status      string     `json:"status"` // running, completed
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Failed      int        `json:"failed"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// CanaryResult is stored per document under content.processing_data.canary_<version>
+type CanaryResult struct {
+	Status       string    `json:"status"` // completed, failed
+	Chunks       int       `json:"chunks"`
+	TableChunks  int       `json:"table_chunks"`
+	JsonFilePath string    `json:"json_file_path,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ProcessedAt  time.Time `json:"processed_at"`
+}
+
+// CanaryQueryComparison compares one benchmark query on a document's live and canary classes
+type CanaryQueryComparison struct {
+	Query            string  `json:"query"`
+	BaselineHits     int     `json:"baseline_hits"`
+	CanaryHits       int     `json:"canary_hits"`
+	BaselineTopScore float64 `json:"baseline_top_score"`
+	CanaryTopScore   float64 `json:"canary_top_score"`
+	PageOverlap      float64 `json:"page_overlap"` // Jaccard index of the pages the two result sets point at
+	Error            string  `json:"error,omitempty"`
+}
+
+// CanaryDocumentComparison compares one sampled document's live and canary output
+type CanaryDocumentComparison struct {
+	DocumentID          int64                   `json:"document_id"`
+	Name                string                  `json:"name"`
+	Status              string                  `json:"status"`
+	Error               string                  `json:"error,omitempty"`
+	BaselineChunks      int                     `json:"baseline_chunks"`
+	CanaryChunks        int                     `json:"canary_chunks"`
+	BaselineTableChunks int                     `json:"baseline_table_chunks"`
+	CanaryTableChunks   int                     `json:"canary_table_chunks"`
+	Queries             []CanaryQueryComparison `json:"queries,omitempty"`
+}
+
+// CanarySummary aggregates a comparison over the completed documents
+type CanarySummary struct {
+	Documents          int     `json:"documents"`
+	Completed          int     `json:"completed"`
+	Failed             int     `json:"failed"`
+	BaselineChunks     int     `json:"baseline_chunks"`
+	CanaryChunks       int     `json:"canary_chunks"`
+	AvgPageOverlap     float64 `json:"avg_page_overlap"`
+	AvgTopScoreDelta   float64 `json:"avg_top_score_delta"` // canary minus baseline
+	QueriesCompared    int     `json:"queries_compared"`
+	QueriesWithoutHits int     `json:"queries_without_hits"` // the canary found nothing where the live pipeline did
+}
+
+// CanaryComparison is the report of a canary version against the live pipeline
+type CanaryComparison struct {
+	Version   string                     `json:"version"`
+	Run       *CanaryRun                 `json:"run,omitempty"`
+	Queries   []string                   `json:"queries"`
+	Summary   CanarySummary              `json:"summary"`
+	Documents []CanaryDocumentComparison `json:"documents"`
+}
+
+// SetCanary configures the canary pipeline. An invalid version disables canary runs, since it would
+// not make a valid Weaviate class name.
+func (s *DocumentService) SetCanary(canary config.PipelineCanaryConfig) {
+	if canary.Version != "" && !canaryVersionPattern.MatchString(canary.Version) {
+		fmt.Printf("⚠️  PIPELINE_CANARY_VERSION %q must be 1-32 letters, digits or _; canary runs disabled\n", canary.Version)
+		canary.Version = ""
+	}
+	s.Canary = canary
+}
+
+// StartCanary reprocesses a random sample of processed documents with the canary pipeline in the
+// background. Results go to versioned Weaviate classes next to the live ones, so search is untouched.
+func (s *DocumentService) StartCanary(ctx context.Context, orgID *uuid.UUID, sampleSize int) (*CanaryRun, error) {
+	version := s.Canary.Version
+	if version == "" {
+		return nil, errors.NewError("CANARY_DISABLED", "No canary pipeline is configured (PIPELINE_CANARY_VERSION)", http.StatusConflict)
+	}
+
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+	if s.canaryRun != nil && s.canaryRun.Status == "running" {
+		return nil, errors.NewError(errors.ErrConflict.Code, fmt.Sprintf("Canary run for version %s is already in progress", s.canaryRun.Version), errors.ErrConflict.Status)
+	}
+
+	docs, err := s.repositories.Document.SampleProcessed(ctx, orgID, sampleSize)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to sample documents", errors.ErrInternalServer.Status)
+	}
+
+	run := &CanaryRun{Version: version, Status: "running", Total: len(docs), StartedAt: time.Now().UTC()}
+	s.canaryRun = run
+	go s.runCanary(version, docs)
+
+	fmt.Printf("🐤 Canary run for pipeline %s started on %d document(s)\n", version, len(docs))
+	progress := *run
+	return &progress, nil
+}
+
+// CanaryProgress returns the latest canary run, or nil if none ran since startup
+func (s *DocumentService) CanaryProgress() *CanaryRun {
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+	if s.canaryRun == nil {
+		return nil
+	}
+	progress := *s.canaryRun
+	return &progress
+}
+
+// runCanary processes the sample one document at a time, so the canary never competes with live
+// uploads for more than one Python process
+func (s *DocumentService) runCanary(version string, docs []*repositories.Document) {
+	ctx := s.WorkerPool.ctx
+	for _, doc := range docs {
+		if ctx.Err() != nil {
+			break
+		}
+		result := s.processCanaryDocument(ctx, version, doc)
+		if err := s.repositories.Document.SetProcessingData(ctx, doc.ID, canaryKeyPrefix+version, result); err != nil {
+			fmt.Printf("⚠️  Failed to record canary result for document %d: %v\n", doc.ID, err)
+		}
+
+		s.canaryMu.Lock()
+		s.canaryRun.Processed++
+		if result.Status != "completed" {
+			s.canaryRun.Failed++
+		}
+		s.canaryMu.Unlock()
+	}
+
+	s.canaryMu.Lock()
+	completedAt := time.Now().UTC()
+	s.canaryRun.Status = "completed"
+	s.canaryRun.CompletedAt = &completedAt
+	processed, failed := s.canaryRun.Processed, s.canaryRun.Failed
+	s.canaryMu.Unlock()
+	fmt.Printf("🐤 Canary run for pipeline %s finished: %d processed, %d failed\n", version, processed, failed)
+}
+
+// processCanaryDocument runs the canary pipeline on one document and loads its chunks into the
+// document's canary classes, replacing those of an earlier run
+func (s *DocumentService) processCanaryDocument(ctx context.Context, version string, doc *repositories.Document) CanaryResult {
+	result := CanaryResult{
+		Status:       "failed",
+		JsonFilePath: path.Join(s.JsonBasePath, "canary", version, fmt.Sprintf("%d_chunks.json", doc.ID)),
+	}
+	fail := func(err error) CanaryResult {
+		fmt.Printf("⚠️  Canary %s failed for document %d: %v\n", version, doc.ID, err)
+		result.Error = err.Error()
+		result.ProcessedAt = time.Now().UTC()
+		return result
+	}
+
+	job := &DocumentJob{
+		ID:           doc.ID,
+		FilePath:     *doc.FilePath,
+		JsonFilePath: result.JsonFilePath,
+	}
+	if doc.OrgID != nil {
+		job.Tenant.OrgID = doc.OrgID.String()
+	}
+	if doc.CreatedBy != nil {
+		job.Tenant.UserID = doc.CreatedBy.String()
+	}
+	ctx = tenant.WithTenant(ctx, job.Tenant)
+
+	if err := os.MkdirAll(path.Dir(result.JsonFilePath), 0755); err != nil {
+		return fail(fmt.Errorf("failed to create canary directory: %w", err))
+	}
+	if _, err := s.WorkerPool.execPipeline(s.Canary.Script, job, 0); err != nil {
+		return fail(err)
+	}
+
+	data, err := os.ReadFile(result.JsonFilePath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read canary chunks: %w", err))
+	}
+	var chunks []weaviate.Chunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return fail(fmt.Errorf("failed to decode canary chunks: %w", err))
+	}
+
+	textClass, tableClass := weaviate.DocumentClassNames(doc.ID, version)
+	if err := s.GetWeaviateClient().DeleteClasses(ctx, textClass, tableClass); err != nil {
+		return fail(err)
+	}
+	if err := s.GetWeaviateClient().InsertChunksIntoClasses(ctx, chunks, weaviate.DefaultPopulateConfig(), doc.ID, textClass, tableClass); err != nil {
+		return fail(err)
+	}
+
+	for _, chunk := range chunks {
+		if chunk.ContentType == "table" {
+			result.TableChunks++
+		} else {
+			result.Chunks++
+		}
+	}
+	result.Status = "completed"
+	result.Error = ""
+	result.ProcessedAt = time.Now().UTC()
+	return result
+}
+
+// CompareCanary reports, for every document processed by a canary version, the live and canary chunk
+// counts and how the benchmark queries (the configured ones when queries is empty) fare on each
+func (s *DocumentService) CompareCanary(ctx context.Context, version string, queries []string) (*CanaryComparison, error) {
+	if !canaryVersionPattern.MatchString(version) {
+		return nil, errors.NewError(errors.ErrValidation.Code, "version must be 1-32 letters, digits or _", errors.ErrValidation.Status)
+	}
+	if len(queries) == 0 {
+		queries = s.Canary.Queries
+	}
+
+	entries, err := s.repositories.Document.ListByProcessingKey(ctx, canaryKeyPrefix+version)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to load canary results", errors.ErrInternalServer.Status)
+	}
+
+	comparison := &CanaryComparison{
+		Version:   version,
+		Queries:   queries,
+		Documents: make([]CanaryDocumentComparison, 0, len(entries)),
+	}
+	if run := s.CanaryProgress(); run != nil && run.Version == version {
+		comparison.Run = run
+	}
+
+	var overlapSum, scoreDeltaSum float64
+	summary := &comparison.Summary
+	for _, entry := range entries {
+		var result CanaryResult
+		if err := json.Unmarshal(entry.Value, &result); err != nil {
+			continue
+		}
+		docComparison := CanaryDocumentComparison{
+			DocumentID:        entry.DocumentID,
+			Name:              entry.Name,
+			Status:            result.Status,
+			Error:             result.Error,
+			CanaryChunks:      result.Chunks,
+			CanaryTableChunks: result.TableChunks,
+		}
+		summary.Documents++
+		if result.Status != "completed" {
+			summary.Failed++
+			comparison.Documents = append(comparison.Documents, docComparison)
+			continue
+		}
+		summary.Completed++
+
+		docTenant := tenant.Info{}
+		if entry.OrgID != nil {
+			docTenant.OrgID = entry.OrgID.String()
+		}
+		docCtx := tenant.WithTenant(ctx, docTenant)
+
+		liveText, liveTable := weaviate.DocumentClassNames(entry.DocumentID, "")
+		canaryText, _ := weaviate.DocumentClassNames(entry.DocumentID, version)
+		docComparison.BaselineChunks, _ = s.GetWeaviateClient().CountObjects(docCtx, graphQLClassName(liveText))
+		docComparison.BaselineTableChunks, _ = s.GetWeaviateClient().CountObjects(docCtx, graphQLClassName(liveTable))
+		summary.BaselineChunks += docComparison.BaselineChunks
+		summary.CanaryChunks += docComparison.CanaryChunks
+
+		for _, query := range queries {
+			queryComparison := s.compareCanaryQuery(docCtx, query, graphQLClassName(liveText), graphQLClassName(canaryText))
+			docComparison.Queries = append(docComparison.Queries, queryComparison)
+			if queryComparison.Error != "" {
+				continue
+			}
+			summary.QueriesCompared++
+			overlapSum += queryComparison.PageOverlap
+			scoreDeltaSum += queryComparison.CanaryTopScore - queryComparison.BaselineTopScore
+			if queryComparison.BaselineHits > 0 && queryComparison.CanaryHits == 0 {
+				summary.QueriesWithoutHits++
+			}
+		}
+		comparison.Documents = append(comparison.Documents, docComparison)
+	}
+
+	if summary.QueriesCompared > 0 {
+		summary.AvgPageOverlap = overlapSum / float64(summary.QueriesCompared)
+		summary.AvgTopScoreDelta = scoreDeltaSum / float64(summary.QueriesCompared)
+	}
+	return comparison, nil
+}
+
+// compareCanaryQuery runs one query against a document's live and canary text classes
+func (s *DocumentService) compareCanaryQuery(ctx context.Context, query, liveClass, canaryClass string) CanaryQueryComparison {
+	comparison := CanaryQueryComparison{Query: query}
+
+	baseline, err := s.GetWeaviateClient().QueryHybridWithCollection(ctx, query, liveClass, 0, canaryAlpha)
+	if err != nil {
+		comparison.Error = fmt.Sprintf("baseline search failed: %v", err)
+		return comparison
+	}
+	canary, err := s.GetWeaviateClient().QueryHybridWithCollection(ctx, query, canaryClass, 0, canaryAlpha)
+	if err != nil {
+		comparison.Error = fmt.Sprintf("canary search failed: %v", err)
+		return comparison
+	}
+
+	comparison.BaselineHits, comparison.CanaryHits = len(baseline), len(canary)
+	comparison.BaselineTopScore, comparison.CanaryTopScore = topScore(baseline), topScore(canary)
+	comparison.PageOverlap = pageOverlap(baseline, canary)
+	return comparison
+}
+
+// DeleteCanary drops everything a canary version produced: its Weaviate classes, chunks files and
+// per-document results. Returns the number of documents cleaned up.
+func (s *DocumentService) DeleteCanary(ctx context.Context, version string) (int, error) {
+	if !canaryVersionPattern.MatchString(version) {
+		return 0, errors.NewError(errors.ErrValidation.Code, "version must be 1-32 letters, digits or _", errors.ErrValidation.Status)
+	}
+	if run := s.CanaryProgress(); run != nil && run.Version == version && run.Status == "running" {
+		return 0, errors.NewError(errors.ErrConflict.Code, "Canary run for this version is still in progress", errors.ErrConflict.Status)
+	}
+
+	entries, err := s.repositories.Document.ListByProcessingKey(ctx, canaryKeyPrefix+version)
+	if err != nil {
+		return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to load canary results", errors.ErrInternalServer.Status)
+	}
+
+	for _, entry := range entries {
+		s.removeCanaryOutput(ctx, entry.DocumentID, version, entry.Value)
+		if err := s.repositories.Document.RemoveProcessingData(ctx, entry.DocumentID, canaryKeyPrefix+version); err != nil {
+			return 0, errors.WrapError(err, "INTERNAL_ERROR", "Failed to remove canary results", errors.ErrInternalServer.Status)
+		}
+	}
+	os.Remove(path.Join(s.JsonBasePath, "canary", version)) // Only succeeds once the directory is empty
+
+	fmt.Printf("🐤 Canary %s deleted from %d document(s)\n", version, len(entries))
+	return len(entries), nil
+}
+
+// removeDocumentCanaries drops the canary output of every version a document was part of
+func (s *DocumentService) removeDocumentCanaries(ctx context.Context, doc *repositories.Document) {
+	for key, value := range doc.Content.ProcessingData {
+		version := strings.TrimPrefix(key, canaryKeyPrefix)
+		if version == key || !canaryVersionPattern.MatchString(version) {
+			continue
+		}
+		raw, _ := json.Marshal(value)
+		s.removeCanaryOutput(ctx, doc.ID, version, raw)
+	}
+}
+
+// removeCanaryOutput deletes one document's canary classes and chunks file
+func (s *DocumentService) removeCanaryOutput(ctx context.Context, documentID int64, version string, raw json.RawMessage) {
+	textClass, tableClass := weaviate.DocumentClassNames(documentID, version)
+	if err := s.GetWeaviateClient().DeleteClasses(ctx, textClass, tableClass); err != nil {
+		fmt.Printf("Warning: Failed to delete canary classes of document %d: %v\n", documentID, err)
+	}
+
+	var result CanaryResult
+	if json.Unmarshal(raw, &result) == nil && result.JsonFilePath != "" {
+		if _, err := removeArtifact(result.JsonFilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove canary chunks of document %d: %v\n", documentID, err)
+		}
+	}
+}
+
+// graphQLClassName is how Weaviate exposes a class in GraphQL: with its first letter capitalized
+func graphQLClassName(className string) string {
+	if className == "" {
+		return className
+	}
+	return strings.ToUpper(className[:1]) + className[1:]
+}
+
+func topScore(chunks []weaviate.Chunk) float64 {
+	var top float64
+	for _, chunk := range chunks {
+		if chunk.Score > top {
+			top = chunk.Score
+		}
+	}
+	return top
+}
+
+// pageOverlap is the Jaccard index of the page numbers two result sets point at. Chunk IDs and text
+// change with the pipeline, pages do not, so this shows whether a query still lands in the same place.
+func pageOverlap(a, b []weaviate.Chunk) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	pagesA := make(map[int]bool, len(a))
+	for _, chunk := range a {
+		pagesA[chunk.PageNumber] = true
+	}
+	pagesB := make(map[int]bool, len(b))
+	for _, chunk := range b {
+		pagesB[chunk.PageNumber] = true
+	}
+
+	intersection := 0
+	for page := range pagesA {
+		if pagesB[page] {
+			intersection++
+		}
+	}
+	union := len(pagesA) + len(pagesB) - intersection
+	return float64(intersection) / float64(union)
+}
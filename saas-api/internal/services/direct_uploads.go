@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// maxDirectUploadBytes is the largest file S3 accepts in a single PUT
+const maxDirectUploadBytes = 5 << 30
+
+var (
+	ErrDirectUploadUnsupported = errors.NewError("NOT_SUPPORTED", "Direct uploads need a storage backend that signs URLs (STORAGE_BACKEND=s3)", http.StatusNotImplemented)
+	ErrNotAwaitingUpload       = errors.NewError("CONFLICT", "Document is not awaiting an upload", http.StatusConflict)
+	ErrUploadMissing           = errors.NewError("CONFLICT", "The file has not been uploaded to storage yet", http.StatusConflict)
+)
+
+// DirectUpload is a document reserved for a file the client uploads straight to storage. The client
+// PUTs the file to UploadURL with Headers, then finalizes the upload.
+type DirectUpload struct {
+	DocumentID int64             `json:"document_id"`
+	Filename   string            `json:"filename"`
+	StorageKey string            `json:"storage_key"`
+	Status     string            `json:"status"`
+	UploadURL  string            `json:"upload_url"`
+	Method     string            `json:"method"`
+	Headers    map[string]string `json:"headers"` // Signed: the upload must send them as they are
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
+
+// PresignUpload checks the upload fits the org's storage quota, reserves a document in the uploading
+// status and signs a URL the client uploads the file to. req.FilePath is the wanted storage key; the
+// key gets a random suffix so concurrent uploads of the same name cannot overwrite each other.
+func (s *DocumentService) PresignUpload(ctx context.Context, req *UploadDocumentRequest, contentType string) (*DirectUpload, error) {
+	if req.SizeBytes <= 0 || req.SizeBytes > maxDirectUploadBytes {
+		return nil, errors.NewError("VALIDATION_ERROR", fmt.Sprintf("size_bytes must be between 1 and %d", int64(maxDirectUploadBytes)), http.StatusBadRequest)
+	}
+	if err := s.checkStorageQuota(ctx, req.OrgID, req.SizeBytes); err != nil {
+		return nil, err
+	}
+	userUUID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	key := checksumSuffixed(req.FilePath, strings.ReplaceAll(uuid.NewString(), "-", ""))
+	uploadURL, err := s.Storage.SignedUploadURL(ctx, key, contentType, req.SizeBytes, s.SignedURLTTL)
+	if err == storage.ErrSignedURLNotSupported {
+		return nil, ErrDirectUploadUnsupported
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to sign upload URL", errors.ErrInternalServer.Status)
+	}
+	ttl := s.SignedURLTTL
+	if ttl <= 0 {
+		ttl = storage.DefaultSignedURLTTL
+	}
+
+	filename := req.Filename
+	if filename == "" {
+		filename = path.Base(req.FilePath)
+	}
+	folderUUID := s.uploadFolder(ctx, req, &userUUID)
+	s.applyFolderRules(ctx, folderUUID, req)
+
+	// Direct uploads are deduplicated when finalized, once their checksum is known
+	jsonFilePath := s.chunksFilePath(key, true)
+	doc := &repositories.Document{
+		OrgID:        req.OrgID,
+		Name:         filename,
+		FilePath:     &key,
+		JsonFilePath: &jsonFilePath,
+		FolderID:     folderUUID,
+		Metadata:     req.Metadata,
+		Status:       repositories.DocumentStatusUploading,
+		CreatedBy:    &userUUID,
+	}
+	// Counted against the quota while the upload is in flight
+	doc.Content.SizeBytes = &req.SizeBytes
+	if contentType != "" {
+		doc.Content.MimeType = &contentType
+	}
+	if err := s.repositories.Document.Create(ctx, doc); err != nil {
+		return nil, fmt.Errorf("failed to create document record: %w", err)
+	}
+
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	return &DirectUpload{
+		DocumentID: doc.ID,
+		Filename:   filename,
+		StorageKey: key,
+		Status:     string(doc.Status),
+		UploadURL:  uploadURL,
+		Method:     http.MethodPut,
+		Headers:    headers,
+		ExpiresAt:  time.Now().Add(ttl).UTC(),
+	}, nil
+}
+
+// FinalizeUpload ingests a direct upload once the client has put the file in storage: it checksums
+// the file for deduplication, as StoreUpload does, and queues it for processing like an upload.
+// Until then the document stays in the uploading status, so a failed call may be retried.
+func (s *DocumentService) FinalizeUpload(ctx context.Context, documentID int64, userID uuid.UUID) (*UploadDocumentResponse, error) {
+	startTime := time.Now()
+
+	doc, err := s.repositories.Document.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Status != repositories.DocumentStatusUploading || doc.FilePath == nil {
+		return nil, ErrNotAwaitingUpload
+	}
+	key := *doc.FilePath
+	exists, err := s.Storage.Exists(ctx, key)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to check the uploaded file", errors.ErrInternalServer.Status)
+	}
+	if !exists {
+		return nil, ErrUploadMissing
+	}
+
+	// Claim the document, so concurrent calls do not ingest the file twice
+	claimed, err := s.repositories.Document.TransitionStatus(ctx, documentID, repositories.DocumentStatusUploading, repositories.DocumentStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrNotAwaitingUpload
+	}
+
+	stored, err := s.adoptUpload(ctx, doc.OrgID, key)
+	if err != nil {
+		if _, revertErr := s.repositories.Document.TransitionStatus(ctx, documentID, repositories.DocumentStatusPending, repositories.DocumentStatusUploading); revertErr != nil {
+			fmt.Printf("⚠️  Failed to reopen direct upload of document %d: %v\n", documentID, revertErr)
+		}
+		return nil, err
+	}
+
+	doc.FilePath = &stored.FilePath
+	doc.Content.Checksum = &stored.Checksum
+	doc.Content.SizeBytes = &stored.SizeBytes
+	doc.Status = repositories.DocumentStatusPending
+	isInReportsFolder := s.inReportsFolder(ctx, doc.FolderID)
+	if isInReportsFolder {
+		doc.Status = repositories.DocumentStatusCompleted
+	}
+	doc.UpdatedBy = &userID
+	if err := s.repositories.Document.Update(ctx, doc); err != nil {
+		s.releaseBlob(ctx, doc.OrgID, stored.Checksum)
+		return nil, fmt.Errorf("failed to update document record: %w", err)
+	}
+
+	if !isInReportsFolder {
+		req := &UploadDocumentRequest{
+			UserID:   userID.String(),
+			OrgID:    doc.OrgID,
+			Checksum: stored.Checksum,
+			Metadata: doc.Metadata,
+		}
+		if doc.FolderID != nil {
+			folderID := doc.FolderID.String()
+			req.FolderID = &folderID
+		}
+		s.submitProcessing(ctx, doc, req)
+	} else {
+		fmt.Printf("📋 Skipped processing for Reports folder document: %s\n", doc.Name)
+	}
+
+	return &UploadDocumentResponse{
+		DocumentID:      doc.ID,
+		Filename:        doc.Name,
+		Status:          string(doc.Status),
+		ProcessingJobID: doc.ID,
+		TimeTaken:       time.Since(startTime),
+	}, nil
+}
+
+// adoptUpload checksums a file already in storage at key and takes a blob reference for it. When
+// the org already has those bytes the uploaded copy is removed and the existing file is used.
+func (s *DocumentService) adoptUpload(ctx context.Context, orgID *uuid.UUID, key string) (*StoredUpload, error) {
+	src, err := s.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to read the uploaded file", errors.ErrInternalServer.Status)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, src)
+	src.Close()
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to read the uploaded file", errors.ErrInternalServer.Status)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	blob, created, err := s.repositories.Document.AcquireBlob(ctx, orgID, checksum, key, size)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		return &StoredUpload{FilePath: key, Checksum: checksum, SizeBytes: size}, nil
+	}
+
+	if exists, err := s.Storage.Exists(ctx, blob.StoragePath); err != nil || !exists {
+		// The shared file went missing; this upload restores it
+		fmt.Printf("⚠️  Blob file %s is missing, restoring it from this upload\n", blob.StoragePath)
+		if err := s.copyStored(ctx, key, blob.StoragePath); err != nil {
+			s.releaseBlob(ctx, orgID, checksum)
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to save file", errors.ErrInternalServer.Status)
+		}
+	}
+	if err := s.Storage.Delete(ctx, key); err != nil {
+		fmt.Printf("Warning: Failed to remove duplicate upload %s: %v\n", key, err)
+	}
+	fmt.Printf("♻️  Upload is a duplicate of %s (%d references)\n", blob.StoragePath, blob.RefCount)
+	return &StoredUpload{FilePath: blob.StoragePath, Checksum: checksum, SizeBytes: size, Duplicate: true}, nil
+}
+
+// copyStored copies the stored file at from to the key to
+func (s *DocumentService) copyStored(ctx context.Context, from, to string) error {
+	src, err := s.Storage.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return s.Storage.Put(ctx, to, src)
+}
+
+// checkStorageQuota rejects an upload of sizeBytes that would take the org past its plan's storage.
+// Uploads without an org (super admin files) are not limited.
+func (s *DocumentService) checkStorageQuota(ctx context.Context, orgID *uuid.UUID, sizeBytes int64) error {
+	if orgID == nil {
+		return nil
+	}
+	org, err := s.repositories.Organization.GetByID(ctx, *orgID)
+	if err != nil {
+		return err
+	}
+	used, err := s.repositories.Organization.StorageUsedBytes(ctx, *orgID)
+	if err != nil {
+		return err
+	}
+	if limit := int64(org.MaxStorageGB) * bytesPerGB; used+sizeBytes > limit {
+		return errors.NewError("PLAN_LIMIT_EXCEEDED",
+			fmt.Sprintf("The upload would exceed the organization's %d GB of storage (%.2f GB used)", org.MaxStorageGB, float64(used)/bytesPerGB),
+			http.StatusRequestEntityTooLarge)
+	}
+	return nil
+}
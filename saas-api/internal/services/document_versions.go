@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/tenant"
+	"saas-api/pkg/weaviate"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDocumentBusy      = errors.NewError(errors.ErrConflict.Code, "The document is still being processed, try again once it completes", errors.ErrConflict.Status)
+	ErrContentUnchanged  = errors.NewError(errors.ErrConflict.Code, "The file is identical to the document's current content", errors.ErrConflict.Status)
+	ErrDocumentHasNoFile = errors.NewError(errors.ErrValidation.Code, "The document has no file to replace", errors.ErrValidation.Status)
+
+	ErrVersionNotFound      = errors.NewError(errors.ErrNotFound.Code, "Document version not found", errors.ErrNotFound.Status)
+	ErrVersionIsCurrent     = errors.NewError(errors.ErrConflict.Code, "The version is already the document's content", errors.ErrConflict.Status)
+	ErrVersionNotRestorable = errors.NewError(errors.ErrConflict.Code, "Only earlier versions whose file is kept can be restored", errors.ErrConflict.Status)
+)
+
+// ReplaceContentRequest is a new file for an existing document
+type ReplaceContentRequest struct {
+	DocumentID int64
+	UserID     uuid.UUID
+	Filename   string
+	File       io.Reader
+}
+
+// contentVersionClasses returns the Weaviate classes a replacement version is embedded into. The
+// document's live class names become aliases of them once the version is active.
+func contentVersionClasses(documentID int64, version int) (string, string) {
+	return weaviate.DocumentClassNames(documentID, fmt.Sprintf("r%d", version))
+}
+
+// ReplaceContent stores a new file for a document as its next version and processes it in the
+// background. Until the version is embedded, search, downloads and the document row keep serving the
+// current content; then both switch in one step (see activateVersion). The document ID never changes.
+func (s *DocumentService) ReplaceContent(ctx context.Context, req *ReplaceContentRequest) (*repositories.DocumentVersion, error) {
+	doc, err := s.repositories.Document.GetByID(ctx, req.DocumentID)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	if doc.FilePath == nil || *doc.FilePath == "" {
+		return nil, ErrDocumentHasNoFile
+	}
+	switch doc.Status {
+	case repositories.DocumentStatusPending, repositories.DocumentStatusProcessing, repositories.DocumentStatusEmbedding:
+		return nil, ErrDocumentBusy
+	}
+
+	// The new file goes next to the current one, which stays for the version history; StoreUpload
+	// never overwrites an existing file
+	stored, err := s.StoreUpload(ctx, doc.OrgID, req.File, path.Join(path.Dir(*doc.FilePath), req.Filename))
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to save file", errors.ErrInternalServer.Status)
+	}
+	if doc.Content.Checksum != nil && *doc.Content.Checksum == stored.Checksum {
+		s.releaseBlob(ctx, doc.OrgID, stored.Checksum)
+		return nil, ErrContentUnchanged
+	}
+
+	if err := s.repositories.Document.RecordCurrentVersion(ctx, doc); err != nil {
+		s.releaseBlob(ctx, doc.OrgID, stored.Checksum)
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to record document version", errors.ErrInternalServer.Status)
+	}
+
+	filePathWithoutExtension := strings.TrimSuffix(stored.FilePath, path.Ext(stored.FilePath))
+	jsonFilePath := path.Join(s.JsonBasePath, path.Base(filePathWithoutExtension)+"_"+uuid.NewString()[:8]+"_chunks.json")
+	version := &repositories.DocumentVersion{
+		DocumentID:   doc.ID,
+		Filename:     req.Filename,
+		FilePath:     &stored.FilePath,
+		JsonFilePath: &jsonFilePath,
+		Checksum:     &stored.Checksum,
+		SizeBytes:    &stored.SizeBytes,
+		CreatedBy:    &req.UserID,
+	}
+	created, err := s.repositories.Document.CreateVersion(ctx, version)
+	if err != nil || !created {
+		s.releaseBlob(ctx, doc.OrgID, stored.Checksum)
+		if err != nil {
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to create document version", errors.ErrInternalServer.Status)
+		}
+		return nil, ErrDocumentBusy
+	}
+
+	// Attribute the job's Weaviate calls to the document's org, as for uploads
+	job := &DocumentJob{ID: doc.ID, Version: version.Version, Tenant: tenant.Info{UserID: req.UserID.String()}}
+	if doc.OrgID != nil {
+		job.Tenant.OrgID = doc.OrgID.String()
+	}
+	ctx = tenant.WithTenant(ctx, job.Tenant)
+
+	if s.inReportsFolder(ctx, doc.FolderID) {
+		// Reports are never processed, so the new file goes live right away
+		if err := s.activate(ctx, job, false); err != nil {
+			s.failVersion(ctx, job, err)
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to activate document version", errors.ErrInternalServer.Status)
+		}
+	} else {
+		duplicate := s.processedDuplicate(ctx, doc.OrgID, stored.Checksum, doc.ID)
+		if _, err := s.WorkerPool.SubmitVersionJob(ctx, doc.ID, version.Version, stored.FilePath, jsonFilePath, duplicate); err != nil {
+			s.failVersion(ctx, job, err)
+			return nil, errors.NewError("SERVICE_UNAVAILABLE", err.Error(), http.StatusServiceUnavailable)
+		}
+	}
+
+	fmt.Printf("📝 Version %d of document %d (%s) submitted\n", version.Version, doc.ID, req.Filename)
+	return s.repositories.Document.GetVersion(ctx, doc.ID, version.Version)
+}
+
+// DocumentVersions returns a document's content history, newest first. A document whose content was
+// never replaced has no recorded history: its current content is returned as its only version.
+func (s *DocumentService) DocumentVersions(ctx context.Context, documentID int64) ([]*repositories.DocumentVersion, error) {
+	doc, err := s.repositories.Document.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	versions, err := s.repositories.Document.ListVersions(ctx, documentID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to list document versions", errors.ErrInternalServer.Status)
+	}
+	if len(versions) == 0 {
+		if current := currentVersion(doc); current != nil {
+			versions = append(versions, current)
+		}
+	}
+	return versions, nil
+}
+
+// DocumentVersion returns one version of a document, falling back to the current content as
+// DocumentVersions does
+func (s *DocumentService) DocumentVersion(ctx context.Context, documentID int64, version int) (*repositories.DocumentVersion, error) {
+	versions, err := s.DocumentVersions(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+// currentVersion describes a document's content as the version it would be recorded as (see
+// RecordCurrentVersion); nil while the document has no file yet
+func currentVersion(doc *repositories.Document) *repositories.DocumentVersion {
+	if doc.FilePath == nil || *doc.FilePath == "" || doc.Status == repositories.DocumentStatusUploading {
+		return nil
+	}
+	version := 1
+	if doc.Content.Version != nil && *doc.Content.Version > 0 {
+		version = *doc.Content.Version
+	}
+	activatedAt := doc.CreatedAt
+	if doc.ProcessedAt != nil {
+		activatedAt = *doc.ProcessedAt
+	}
+	return &repositories.DocumentVersion{
+		DocumentID:   doc.ID,
+		Version:      version,
+		Filename:     doc.Name,
+		FilePath:     doc.FilePath,
+		JsonFilePath: doc.JsonFilePath,
+		Checksum:     doc.Content.Checksum,
+		SizeBytes:    doc.Content.SizeBytes,
+		Status:       repositories.DocumentVersionActive,
+		CreatedBy:    doc.CreatedBy,
+		CreatedAt:    doc.CreatedAt,
+		ActivatedAt:  &activatedAt,
+	}
+}
+
+// RestoreVersion makes an earlier version the document's content again. The version keeps its number
+// and files, and the content it replaces is superseded, so that can be restored in turn. When the
+// version's Weaviate classes still hold its chunks it goes live at once; otherwise (the first
+// version's classes are dropped by the first replacement) it is embedded again from its chunks file,
+// or processed again if they were purged, and goes live once done, like a replacement.
+func (s *DocumentService) RestoreVersion(ctx context.Context, documentID int64, version int, userID uuid.UUID) (*repositories.DocumentVersion, error) {
+	doc, err := s.repositories.Document.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+	switch doc.Status {
+	case repositories.DocumentStatusPending, repositories.DocumentStatusProcessing, repositories.DocumentStatusEmbedding, repositories.DocumentStatusUploading:
+		return nil, ErrDocumentBusy
+	}
+
+	v, err := s.repositories.Document.GetVersion(ctx, documentID, version)
+	if err != nil {
+		if current := currentVersion(doc); current != nil && current.Version == version {
+			return nil, ErrVersionIsCurrent
+		}
+		return nil, ErrVersionNotFound
+	}
+	switch v.Status {
+	case repositories.DocumentVersionActive:
+		return nil, ErrVersionIsCurrent
+	case repositories.DocumentVersionSuperseded:
+	default:
+		return nil, ErrVersionNotRestorable
+	}
+
+	isInReportsFolder := s.inReportsFolder(ctx, doc.FolderID)
+	if v.FilePath == nil || *v.FilePath == "" || (v.JsonFilePath == nil && !isInReportsFolder) {
+		return nil, ErrVersionNotRestorable
+	}
+	if !path.IsAbs(*v.FilePath) {
+		if exists, err := s.Storage.Exists(ctx, *v.FilePath); err != nil || !exists {
+			return nil, ErrVersionNotRestorable
+		}
+	}
+
+	reopened, err := s.repositories.Document.ReopenVersion(ctx, documentID, version)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to restore document version", errors.ErrInternalServer.Status)
+	}
+	if !reopened {
+		return nil, ErrDocumentBusy
+	}
+
+	job := &DocumentJob{ID: doc.ID, Version: version, Tenant: tenant.Info{UserID: userID.String()}}
+	if doc.OrgID != nil {
+		job.Tenant.OrgID = doc.OrgID.String()
+	}
+	ctx = tenant.WithTenant(ctx, job.Tenant)
+
+	if isInReportsFolder || s.versionEmbedded(ctx, documentID, version) {
+		if err := s.activate(ctx, job, !isInReportsFolder); err != nil {
+			s.supersedeVersion(ctx, job, err)
+			return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to restore document version", errors.ErrInternalServer.Status)
+		}
+	} else {
+		// The version's own chunks are reused when they are still there, and the embeddings of an
+		// identical document when there is one
+		var duplicate ProcessedDuplicate
+		if v.Checksum != nil {
+			duplicate = s.processedDuplicate(ctx, doc.OrgID, *v.Checksum, doc.ID)
+		}
+		duplicate.ChunksFile = *v.JsonFilePath
+		if _, err := s.WorkerPool.SubmitVersionJob(ctx, doc.ID, version, *v.FilePath, *v.JsonFilePath, duplicate); err != nil {
+			s.supersedeVersion(ctx, job, err)
+			return nil, errors.NewError("SERVICE_UNAVAILABLE", err.Error(), http.StatusServiceUnavailable)
+		}
+	}
+
+	fmt.Printf("⏪ Version %d of document %d restored by %s\n", version, doc.ID, userID)
+	return s.repositories.Document.GetVersion(ctx, doc.ID, version)
+}
+
+// versionEmbedded tells whether a version's Weaviate classes still hold its chunks
+func (s *DocumentService) versionEmbedded(ctx context.Context, documentID int64, version int) bool {
+	textClass, _ := contentVersionClasses(documentID, version)
+	count, err := s.GetWeaviateClient().CountObjects(ctx, textClass)
+	return err == nil && count > 0
+}
+
+// supersedeVersion puts a version whose restore failed back in the history, with its files, and
+// records why the restore failed
+func (s *DocumentService) supersedeVersion(ctx context.Context, job *DocumentJob, cause error) {
+	message := cause.Error()
+	if err := s.repositories.Document.SetVersionStatus(ctx, job.ID, job.Version, repositories.DocumentVersionSuperseded, &message); err != nil {
+		fmt.Printf("⚠️  Failed to return version %d of document %d to its history: %v\n", job.Version, job.ID, err)
+	}
+	fmt.Printf("⚠️  Restoring version %d of document %d failed: %s\n", job.Version, job.ID, message)
+}
+
+// deleteVersionClasses drops the Weaviate classes of a version that did not go live. Classes the
+// document's live names still point at are kept: a switch that failed partway may have left an alias
+// on them, and they are then the document's only search data.
+func (s *DocumentService) deleteVersionClasses(ctx context.Context, job *DocumentJob) {
+	client := s.GetWeaviateClient()
+	live, err := client.DocumentAliasTargets(ctx, job.ID)
+	if err != nil {
+		fmt.Printf("Warning: Kept the classes of version %d of document %d, its live classes are unknown: %v\n", job.Version, job.ID, err)
+		return
+	}
+
+	textClass, tableClass := contentVersionClasses(job.ID, job.Version)
+	var unused []string
+	for _, class := range []string{textClass, tableClass} {
+		inUse := false
+		for _, target := range live {
+			inUse = inUse || strings.EqualFold(target, class)
+		}
+		if inUse {
+			fmt.Printf("⚠️  Kept class %s of failed version %d: document %d still searches it\n", class, job.Version, job.ID)
+			continue
+		}
+		unused = append(unused, class)
+	}
+	if err := client.DeleteClasses(ctx, unused...); err != nil {
+		fmt.Printf("Warning: Failed to delete classes of version %d of document %d: %v\n", job.Version, job.ID, err)
+	}
+}
+
+// activateVersion is called by the worker pool once a replacement is embedded
+func (s *DocumentService) activateVersion(ctx context.Context, job *DocumentJob) error {
+	return s.activate(ctx, job, true)
+}
+
+// activate makes a version the document's content. With embedded set, the document's live Weaviate
+// names are switched to the version's classes inside the same database transaction, so search and
+// the document row never disagree about which file is current. Superseded classes are kept with
+// their version; the original upload's are dropped by the first switch (see PointDocumentClasses).
+func (s *DocumentService) activate(ctx context.Context, job *DocumentJob, embedded bool) error {
+	version, err := s.repositories.Document.GetVersion(ctx, job.ID, job.Version)
+	if err != nil {
+		return err
+	}
+
+	// PointDocumentClasses undoes a switch that fails partway; previous undoes one whose transaction
+	// then fails to commit
+	var previous []string
+	swap := func() error {
+		if !embedded {
+			return nil
+		}
+		textClass, tableClass := contentVersionClasses(job.ID, job.Version)
+		var err error
+		previous, err = s.GetWeaviateClient().PointDocumentClasses(ctx, job.ID, textClass, tableClass)
+		return err
+	}
+	if err := s.repositories.Document.ActivateVersion(ctx, version, version.CreatedBy, swap); err != nil {
+		if len(previous) == 2 {
+			if _, undoErr := s.GetWeaviateClient().PointDocumentClasses(ctx, job.ID, previous[0], previous[1]); undoErr != nil {
+				fmt.Printf("⚠️  Failed to point document %d back at its current classes: %v\n", job.ID, undoErr)
+			}
+		}
+		return err
+	}
+
+	if embedded && version.JsonFilePath != nil {
+		if snippet, err := ExtractSnippet(*version.JsonFilePath); err == nil {
+			if err := s.repositories.Document.SetProcessingData(ctx, job.ID, snippetProcessingKey, snippet); err != nil {
+				fmt.Printf("⚠️  Failed to cache snippet for document %d: %v\n", job.ID, err)
+			}
+		}
+	}
+
+	fmt.Printf("✅ Version %d of document %d is live\n", job.Version, job.ID)
+	return nil
+}
+
+// failVersion marks a replacement failed and drops what it produced: its Weaviate classes, chunks
+// file and reference on the uploaded bytes. The document's current content is untouched.
+func (s *DocumentService) failVersion(ctx context.Context, job *DocumentJob, cause error) {
+	// A version that was live before is being restored: it keeps its files (see supersedeVersion)
+	version, err := s.repositories.Document.GetVersion(ctx, job.ID, job.Version)
+	restoring := err == nil && version.ActivatedAt != nil
+
+	message := cause.Error()
+	if !restoring {
+		if err := s.repositories.Document.SetVersionStatus(ctx, job.ID, job.Version, repositories.DocumentVersionFailed, &message); err != nil {
+			fmt.Printf("⚠️  Failed to mark version %d of document %d failed: %v\n", job.Version, job.ID, err)
+		}
+	}
+
+	// Embedding may have stopped partway; the classes must not look complete to a later restore
+	s.deleteVersionClasses(ctx, job)
+	if restoring {
+		s.supersedeVersion(ctx, job, cause)
+		return
+	}
+
+	// The document may have been deleted meanwhile, taking its versions and their files with it
+	doc, err := s.repositories.Document.GetByID(ctx, job.ID)
+	if err != nil {
+		return
+	}
+	version, err = s.repositories.Document.GetVersion(ctx, job.ID, job.Version)
+	if err != nil {
+		return
+	}
+	s.removeVersionFiles(ctx, doc.OrgID, version)
+	fmt.Printf("⚠️  Version %d of document %d failed: %s\n", job.Version, job.ID, message)
+}
+
+// removeDocumentVersions drops the output of every version but the active one, whose files and
+// classes are the document's own
+func (s *DocumentService) removeDocumentVersions(ctx context.Context, doc *repositories.Document) {
+	versions, err := s.repositories.Document.ListVersions(ctx, doc.ID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to list versions of document %d: %v\n", doc.ID, err)
+		return
+	}
+
+	for _, version := range versions {
+		if version.Status == repositories.DocumentVersionActive {
+			continue
+		}
+		textClass, tableClass := contentVersionClasses(doc.ID, version.Version)
+		if err := s.GetWeaviateClient().DeleteClasses(ctx, textClass, tableClass); err != nil {
+			fmt.Printf("Warning: Failed to delete classes of version %d of document %d: %v\n", version.Version, doc.ID, err)
+		}
+		s.removeVersionFiles(ctx, doc.OrgID, version)
+	}
+}
+
+// removeVersionFiles removes a version's chunks file and gives back its reference on the uploaded
+// bytes. Versions recorded from documents uploaded before deduplication have no blob; their file is
+// left alone, as it is for such documents.
+func (s *DocumentService) removeVersionFiles(ctx context.Context, orgID *uuid.UUID, version *repositories.DocumentVersion) {
+	if version.JsonFilePath != nil && *version.JsonFilePath != "" {
+		if _, err := removeArtifact(*version.JsonFilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove chunks of version %d of document %d: %v\n", version.Version, version.DocumentID, err)
+		}
+	}
+	if version.Checksum != nil && *version.Checksum != "" {
+		s.releaseBlob(ctx, orgID, *version.Checksum)
+	}
+}
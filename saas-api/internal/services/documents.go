@@ -7,10 +7,14 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"saas-api/config"
 	"saas-api/internal/models"
 	"saas-api/internal/repositories"
+	"saas-api/pkg/storage"
+	"saas-api/pkg/tenant"
 	"saas-api/pkg/weaviate"
 
 	"github.com/google/uuid"
@@ -22,6 +26,14 @@ type DocumentService struct {
 	ResourcesBasePath string
 	JsonBasePath      string
 	WorkerPool        *DocumentWorkerPool
+	Storage           storage.Storage             // Uploaded files, keyed by their paths; set with SetStorage
+	SignedURLTTL      time.Duration               // Lifetime of download redirects to Storage, when it signs URLs
+	Canary            config.PipelineCanaryConfig // Set with SetCanary; empty Version disables canary runs
+	FolderImport      config.FolderImportConfig   // Limits of ImportFolderZip
+	Uploads           config.UploadSessionConfig  // Limits of resumable uploads
+
+	canaryMu  sync.Mutex
+	canaryRun *CanaryRun
 }
 
 // NewDocumentService creates a new document service
@@ -47,12 +59,23 @@ func NewDocumentService(base *BaseService) *DocumentService {
 	workerPool.Start()
 	fmt.Printf("🚀 Document worker pool started with %d workers\n", DefaultWorkerPoolConfig().WorkerCount)
 
-	return &DocumentService{
+	service := &DocumentService{
 		BaseService:       base,
 		ResourcesBasePath: resourcesBasePath,
 		JsonBasePath:      jsonBasePath,
 		WorkerPool:        workerPool,
 	}
+	service.SetStorage(storage.NewLocal(resourcesBasePath), storage.DefaultSignedURLTTL)
+	workerPool.versions = service
+	return service
+}
+
+// SetStorage moves uploaded files to another backend (see pkg/storage); without it they stay on
+// the local disk under ResourcesBasePath. Must be called before documents are uploaded.
+func (s *DocumentService) SetStorage(st storage.Storage, signedURLTTL time.Duration) {
+	s.Storage = st
+	s.SignedURLTTL = signedURLTTL
+	s.WorkerPool.storage = st
 }
 
 // InitSchema initializes the database schema for documents
@@ -72,11 +95,14 @@ func (s *DocumentService) StopWorkers() {
 
 // UploadDocumentRequest represents the request for uploading a document
 type UploadDocumentRequest struct {
-	UserID   string     // UUID as string
-	OrgID    *uuid.UUID // Nullable for superadmins
-	FilePath string
-	FolderID *string
-	Metadata map[string]interface{}
+	UserID    string     // UUID as string
+	OrgID     *uuid.UUID // Nullable for superadmins
+	FilePath  string
+	Filename  string // Display name; defaults to the base of FilePath (which a duplicate shares with the original)
+	Checksum  string // From StoreUpload; empty for files that are not deduplicated
+	SizeBytes int64
+	FolderID  *string
+	Metadata  map[string]interface{}
 }
 
 // UploadDocumentResponse represents the response after uploading a document
@@ -100,6 +126,11 @@ type DocumentInfo struct {
 	CreatedAt    time.Time              `json:"created_at"`
 	UploadedAt   *time.Time             `json:"uploaded_at,omitempty"`
 	ProcessedAt  *time.Time             `json:"processed_at,omitempty"`
+	Snippet      *string                `json:"snippet,omitempty"` // Only with include=snippet
+
+	// Only from GetJobStatus: the live content version, and a newer one being processed or failed
+	Version     int                           `json:"version,omitempty"`
+	Replacement *repositories.DocumentVersion `json:"replacement,omitempty"`
 }
 
 // UploadDocument handles the document upload business logic asynchronously
@@ -107,59 +138,26 @@ func (s *DocumentService) UploadDocument(ctx context.Context, req *UploadDocumen
 	startTime := time.Now()
 
 	// Prepare file paths
-	filename := path.Base(req.FilePath)
-	filePathWithoutExtension := strings.TrimSuffix(req.FilePath, path.Ext(req.FilePath))
-	jsonFilePath := path.Join(s.JsonBasePath, path.Base(filePathWithoutExtension)+"_chunks.json")
+	filename := req.Filename
+	if filename == "" {
+		filename = path.Base(req.FilePath)
+	}
+	jsonFilePath := s.chunksFilePath(req.FilePath, req.Checksum != "")
 
 	// Create document record in database first to get the ID
 	// Parse user ID
 	userUUID, err := uuid.Parse(req.UserID)
 	if err != nil {
+		s.releaseUpload(ctx, req)
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 
-	// Convert folder ID from *string to *uuid.UUID
-	var folderUUID *uuid.UUID
-	if req.FolderID != nil && *req.FolderID != "" {
-		parsed, err := uuid.Parse(*req.FolderID)
-		if err == nil {
-			folderUUID = &parsed
-			fmt.Printf("📁 Using specified folder: %s\n", *req.FolderID)
-		} else {
-			fmt.Printf("⚠️  Invalid folder ID format: %s\n", *req.FolderID)
-		}
-	}
-
-	// Only auto-assign to Resources folder if:
-	// 1. No folder specified (folderUUID is nil AND req.FolderID is nil or empty)
-	// 2. Org ID exists
-	if folderUUID == nil && req.OrgID != nil && (req.FolderID == nil || *req.FolderID == "") {
-		resourcesFolderID, err := s.getOrCreateResourcesFolder(ctx, *req.OrgID, &userUUID)
-		if err != nil {
-			fmt.Printf("⚠️  Failed to get/create Resources folder: %v\n", err)
-			// Continue without folder - don't fail the upload
-		} else {
-			folderUUID = resourcesFolderID
-			fmt.Printf("📁 Auto-assigned to Resources folder: %s\n", *resourcesFolderID)
-		}
-	} else if folderUUID != nil {
-		fmt.Printf("📁 Document will be saved to folder: %s\n", *folderUUID)
-	} else {
-		fmt.Printf("📁 Document will be saved without folder (root level)\n")
-	}
+	folderUUID := s.uploadFolder(ctx, req, &userUUID)
 
 	// Check if document is in Reports folder - if so, don't process it
-	isInReportsFolder := false
-	if folderUUID != nil {
-		folder, err := s.repositories.Folder.GetByID(ctx, *folderUUID)
-		if err == nil {
-			// Check if this is the Reports folder or a subfolder of Reports
-			if strings.ToLower(folder.Name) == "reports" || strings.Contains(strings.ToLower(folder.Path), "/reports") {
-				isInReportsFolder = true
-				fmt.Printf("📋 Document is in Reports folder (%s) - will not be processed for AI\n", folder.Name)
-			}
-		}
-	}
+	isInReportsFolder := s.inReportsFolder(ctx, folderUUID)
+
+	s.applyFolderRules(ctx, folderUUID, req)
 
 	// Set status based on whether it's in Reports folder
 	// Reports folder documents are marked as completed immediately (no processing needed)
@@ -179,30 +177,20 @@ func (s *DocumentService) UploadDocument(ctx context.Context, req *UploadDocumen
 		Status:       docStatus,
 		CreatedBy:    &userUUID,
 	}
+	if req.Checksum != "" {
+		doc.Content.Checksum = &req.Checksum
+		doc.Content.SizeBytes = &req.SizeBytes
+	}
 
 	err = s.repositories.Document.Create(ctx, doc)
 	if err != nil {
+		s.releaseUpload(ctx, req)
 		return nil, fmt.Errorf("failed to create document record: %w", err)
 	}
 
 	// Only submit job to worker pool if NOT in Reports folder
 	if !isInReportsFolder {
-		if s.WorkerPool != nil {
-			// Construct full disk path for Python worker (needs absolute path to read file)
-			// req.FilePath is relative (e.g., "org_id/folder/file.pdf")
-			// Worker needs full path (e.g., "uploads/org_id/folder/file.pdf")
-			fullDiskPath := path.Join(s.ResourcesBasePath, req.FilePath)
-
-			_, err = s.WorkerPool.SubmitJob(doc.ID, fullDiskPath, jsonFilePath, req.FolderID, req.Metadata)
-			if err != nil {
-				fmt.Printf("⚠️  Failed to submit job to worker pool: %v\n", err)
-				// Don't fail the upload, just log the warning
-			} else {
-				fmt.Printf("✅ Job %d submitted to worker pool for processing\n", doc.ID)
-			}
-		} else {
-			fmt.Printf("⚠️  Worker pool not initialized, document will remain in pending status\n")
-		}
+		s.submitProcessing(ctx, doc, req)
 	} else {
 		fmt.Printf("📋 Skipped processing for Reports folder document: %s\n", filename)
 	}
@@ -216,6 +204,89 @@ func (s *DocumentService) UploadDocument(ctx context.Context, req *UploadDocumen
 	}, nil
 }
 
+// chunksFilePath is where the pipeline writes the chunks of the file at filePath. Deduplicated files
+// may be shared by several documents, so each of those gets its own chunks file.
+func (s *DocumentService) chunksFilePath(filePath string, deduplicated bool) string {
+	filePathWithoutExtension := strings.TrimSuffix(filePath, path.Ext(filePath))
+	if deduplicated {
+		return path.Join(s.JsonBasePath, path.Base(filePathWithoutExtension)+"_"+uuid.NewString()[:8]+"_chunks.json")
+	}
+	return path.Join(s.JsonBasePath, path.Base(filePathWithoutExtension)+"_chunks.json")
+}
+
+// uploadFolder resolves the folder an upload goes to: req.FolderID, or the org's Resources folder
+// when none is given
+func (s *DocumentService) uploadFolder(ctx context.Context, req *UploadDocumentRequest, userID *uuid.UUID) *uuid.UUID {
+	// Convert folder ID from *string to *uuid.UUID
+	var folderUUID *uuid.UUID
+	if req.FolderID != nil && *req.FolderID != "" {
+		parsed, err := uuid.Parse(*req.FolderID)
+		if err == nil {
+			folderUUID = &parsed
+			fmt.Printf("📁 Using specified folder: %s\n", *req.FolderID)
+		} else {
+			fmt.Printf("⚠️  Invalid folder ID format: %s\n", *req.FolderID)
+		}
+	}
+
+	// Only auto-assign to Resources folder if:
+	// 1. No folder specified (folderUUID is nil AND req.FolderID is nil or empty)
+	// 2. Org ID exists
+	if folderUUID == nil && req.OrgID != nil && (req.FolderID == nil || *req.FolderID == "") {
+		resourcesFolderID, err := s.getOrCreateResourcesFolder(ctx, *req.OrgID, userID)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to get/create Resources folder: %v\n", err)
+			// Continue without folder - don't fail the upload
+		} else {
+			folderUUID = resourcesFolderID
+			fmt.Printf("📁 Auto-assigned to Resources folder: %s\n", *resourcesFolderID)
+		}
+	} else if folderUUID != nil {
+		fmt.Printf("📁 Document will be saved to folder: %s\n", *folderUUID)
+	} else {
+		fmt.Printf("📁 Document will be saved without folder (root level)\n")
+	}
+	return folderUUID
+}
+
+// applyFolderRules applies the folder's auto-tagging / default metadata rules to req.Metadata
+// (explicit upload metadata wins)
+func (s *DocumentService) applyFolderRules(ctx context.Context, folderUUID *uuid.UUID, req *UploadDocumentRequest) {
+	if folderUUID != nil {
+		rules, err := s.repositories.Folder.GetEffectiveMetadataRules(ctx, *folderUUID)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to load folder metadata rules: %v\n", err)
+		} else if len(rules) > 0 {
+			req.Metadata = repositories.ApplyMetadataRules(req.Metadata, rules, false)
+			fmt.Printf("🏷️  Applied %d folder metadata rule(s)\n", len(rules))
+		}
+	}
+}
+
+// submitProcessing queues the document's file for the Python pipeline and embedding
+func (s *DocumentService) submitProcessing(ctx context.Context, doc *repositories.Document, req *UploadDocumentRequest) {
+	if s.WorkerPool != nil {
+		// Attribute the job's Weaviate calls to the document's org, which may differ from the caller's for super admins
+		jobTenant := tenant.Info{UserID: req.UserID}
+		if req.OrgID != nil {
+			jobTenant.OrgID = req.OrgID.String()
+		}
+
+		// An identical document already processed in this org lends its chunks and embeddings
+		duplicate := s.processedDuplicate(ctx, doc.OrgID, req.Checksum, doc.ID)
+
+		_, err := s.WorkerPool.SubmitJob(tenant.WithTenant(ctx, jobTenant), doc.ID, *doc.FilePath, *doc.JsonFilePath, duplicate, req.FolderID, req.Metadata)
+		if err != nil {
+			fmt.Printf("⚠️  Failed to submit job to worker pool: %v\n", err)
+			// Don't fail the upload, just log the warning
+		} else {
+			fmt.Printf("✅ Job %d submitted to worker pool for processing\n", doc.ID)
+		}
+	} else {
+		fmt.Printf("⚠️  Worker pool not initialized, document will remain in pending status\n")
+	}
+}
+
 // GetJobStatus returns the status of a document processing job
 func (s *DocumentService) GetJobStatus(ctx context.Context, jobID string) (*DocumentInfo, error) {
 	// Parse jobID as int64
@@ -241,6 +312,15 @@ func (s *DocumentService) GetJobStatus(ctx context.Context, jobID string) (*Docu
 		folderIDStr = &folderID
 	}
 
+	version := 1
+	if doc.Content.Version != nil && *doc.Content.Version > 0 {
+		version = *doc.Content.Version
+	}
+	replacement, err := s.repositories.Document.GetLatestReplacement(ctx, doc.ID)
+	if err != nil {
+		fmt.Printf("⚠️  Failed to look up replacement of document %d: %v\n", doc.ID, err)
+	}
+
 	return &DocumentInfo{
 		DocumentID:   doc.ID,
 		Name:         doc.Name,
@@ -251,6 +331,8 @@ func (s *DocumentService) GetJobStatus(ctx context.Context, jobID string) (*Docu
 		ErrorMessage: doc.ErrorMessage,
 		UploadedAt:   doc.UploadedAt,
 		ProcessedAt:  doc.ProcessedAt,
+		Version:      version,
+		Replacement:  replacement,
 	}, nil
 }
 
@@ -263,8 +345,11 @@ func (s *DocumentService) GetAllJobs(ctx context.Context) []*DocumentJob {
 type GetDocumentsRequest struct {
 	FolderID *string
 	OrgID    *uuid.UUID // Nullable for superadmins
+	ViewerID *uuid.UUID // Whose folder permissions apply; nil for superadmins
 	Page     int
 	Limit    int
+
+	IncludeSnippet bool // Attach the first ~500 characters of extracted text to each document
 }
 
 // GetDocumentsResponse represents paginated document response
@@ -279,7 +364,7 @@ type GetDocumentsResponse struct {
 func (s *DocumentService) GetDocuments(ctx context.Context) ([]DocumentInfo, error) {
 	// Use zero UUID for "all orgs" query
 	var zeroUUID uuid.UUID
-	docs, _, err := s.repositories.Document.ListByFolder(ctx, nil, zeroUUID, 1, 100)
+	docs, _, err := s.repositories.Document.ListByFolder(ctx, nil, zeroUUID, nil, 1, 100)
 	if err != nil {
 		return nil, err
 	}
@@ -333,13 +418,13 @@ func (s *DocumentService) GetDocumentsWithFilter(ctx context.Context, req *GetDo
 		parsed, parseErr := uuid.Parse(*req.FolderID)
 		if parseErr == nil {
 			folderUUID := &parsed
-			docs, totalCount, err = s.repositories.Document.ListByFolder(ctx, folderUUID, orgID, req.Page, req.Limit)
+			docs, totalCount, err = s.repositories.Document.ListByFolder(ctx, folderUUID, orgID, req.ViewerID, req.Page, req.Limit)
 		} else {
 			return nil, fmt.Errorf("invalid folder ID: %w", parseErr)
 		}
 	} else {
 		// No folder specified - return ALL documents for this org (chat documents selector)
-		docs, totalCount, err = s.repositories.Document.ListAll(ctx, orgID, req.Page, req.Limit)
+		docs, totalCount, err = s.repositories.Document.ListAll(ctx, orgID, req.ViewerID, req.Page, req.Limit)
 	}
 	if err != nil {
 		return nil, err
@@ -375,6 +460,9 @@ func (s *DocumentService) GetDocumentsWithFilter(ctx context.Context, req *GetDo
 			UploadedAt:   doc.UploadedAt,
 			ProcessedAt:  doc.ProcessedAt,
 		}
+		if req.IncludeSnippet {
+			result[i].Snippet = s.documentSnippet(ctx, doc)
+		}
 	}
 
 	return &GetDocumentsResponse{
@@ -385,6 +473,27 @@ func (s *DocumentService) GetDocumentsWithFilter(ctx context.Context, req *GetDo
 	}, nil
 }
 
+// documentSnippet returns the preview cached at processing time. Documents processed before
+// snippets existed get theirs extracted from the chunks file now and cached for the next listing.
+func (s *DocumentService) documentSnippet(ctx context.Context, doc *repositories.Document) *string {
+	if snippet, ok := cachedSnippet(doc.Content.ProcessingData); ok {
+		return &snippet
+	}
+	if doc.Status != repositories.DocumentStatusCompleted || doc.JsonFilePath == nil || *doc.JsonFilePath == "" {
+		return nil
+	}
+
+	snippet, err := ExtractSnippet(*doc.JsonFilePath)
+	if err != nil {
+		// Reports and other unprocessed documents have no chunks file
+		return nil
+	}
+	if err := s.repositories.Document.SetProcessingData(ctx, doc.ID, snippetProcessingKey, snippet); err != nil {
+		fmt.Printf("⚠️  Failed to cache snippet for document %d: %v\n", doc.ID, err)
+	}
+	return &snippet
+}
+
 // SearchDocuments searches for documents in Weaviate
 func (s *DocumentService) SearchDocuments(ctx context.Context,
 	query string,
@@ -416,16 +525,55 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, documentID int64)
 		fmt.Printf("Warning: Failed to delete document from Weaviate: %v\n", err)
 	}
 
+	// Shadow copies from pipeline canary runs go too, and so do earlier and pending versions
+	s.removeDocumentCanaries(ctx, doc)
+	s.removeDocumentVersions(ctx, doc)
+
 	// Delete from PostgreSQL
 	err = s.repositories.Document.Delete(ctx, documentID)
 	if err != nil {
 		return fmt.Errorf("failed to delete document from database: %w", err)
 	}
 
+	// Deduplicated uploads share their source file; it goes with the last document using it
+	if doc.Content.Checksum != nil && *doc.Content.Checksum != "" {
+		s.releaseBlob(ctx, doc.OrgID, *doc.Content.Checksum)
+	}
+	// A direct upload that was never finalized has no blob yet, only the file the client put
+	if doc.Status == repositories.DocumentStatusUploading && doc.FilePath != nil {
+		if err := s.Storage.Delete(ctx, *doc.FilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove unfinished upload %s: %v\n", *doc.FilePath, err)
+		}
+	}
+
+	// The row is gone, so the lifecycle job would never find the chunks file again
+	if doc.JsonFilePath != nil && *doc.JsonFilePath != "" {
+		if _, err := removeArtifact(*doc.JsonFilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove processing artifacts: %v\n", err)
+		}
+	}
+
 	fmt.Printf("Document %s (ID: %d) deleted successfully\n", doc.Name, documentID)
 	return nil
 }
 
+// inReportsFolder reports whether a folder is the Reports folder or one of its subfolders. Documents
+// there are not processed for AI.
+func (s *DocumentService) inReportsFolder(ctx context.Context, folderID *uuid.UUID) bool {
+	if folderID == nil {
+		return false
+	}
+	folder, err := s.repositories.Folder.GetByID(ctx, *folderID)
+	if err != nil {
+		return false
+	}
+	if strings.ToLower(folder.Name) == "reports" || strings.Contains(strings.ToLower(folder.Path), "/reports") {
+		fmt.Printf("📋 Document is in Reports folder (%s) - will not be processed for AI\n", folder.Name)
+		return true
+	}
+	return false
+}
+
 // getOrCreateResourcesFolder gets or creates a "Resources" root folder for the organization
 func (s *DocumentService) getOrCreateResourcesFolder(ctx context.Context, orgID uuid.UUID, createdBy *uuid.UUID) (*uuid.UUID, error) {
 	// Try to find existing "Resources" folder (root level, no parent)
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailChangeDisabled = errors.NewError("SERVICE_UNAVAILABLE", "Email change is not configured (EMAIL_CHANGE_URL is not set)", http.StatusServiceUnavailable)
+	ErrInvalidEmailChange  = errors.NewError("INVALID_TOKEN", "This confirmation link is invalid, expired or already used", http.StatusBadRequest)
+	ErrEmailAlreadyInUse   = errors.NewError("CONFLICT", "Email already exists", http.StatusConflict)
+	ErrTooManyEmailChanges = errors.NewError("RATE_LIMITED", "Too many email change requests. Please try again later.", http.StatusTooManyRequests)
+)
+
+// emailChangeMaxPerHour limits the confirmation emails one user can have sent
+const emailChangeMaxPerHour = 5
+
+// EmailChangeService changes a user's email in two steps: the request sends a signed, single-use link
+// to the new address, and users.email only changes when that link is followed. The account keeps
+// signing in with its current email until then.
+type EmailChangeService struct {
+	authService   *AuthService
+	repo          *repositories.EmailVerificationRepository
+	auditLogRepo  *repositories.AuditLogRepository
+	libreChatSync *LibreChatSync
+	config        config.EmailChangeConfig
+}
+
+func NewEmailChangeService(authService *AuthService, repo *repositories.EmailVerificationRepository, auditLogRepo *repositories.AuditLogRepository, libreChatSync *LibreChatSync, cfg config.EmailChangeConfig) *EmailChangeService {
+	return &EmailChangeService{
+		authService:   authService,
+		repo:          repo,
+		auditLogRepo:  auditLogRepo,
+		libreChatSync: libreChatSync,
+		config:        cfg,
+	}
+}
+
+// Enabled reports whether there is a frontend page for the emailed links
+func (s *EmailChangeService) Enabled() bool {
+	return s.config.URL != ""
+}
+
+// RequestChange sends a confirmation link to newEmail. A new request replaces any earlier one.
+func (s *EmailChangeService) RequestChange(ctx context.Context, userID uuid.UUID, newEmail, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrEmailChangeDisabled
+	}
+
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	newEmail = strings.ToLower(strings.TrimSpace(newEmail))
+	if strings.EqualFold(newEmail, user.Email) {
+		return errors.NewError("VALIDATION_ERROR", "This is already your email address", http.StatusBadRequest)
+	}
+	if _, err := s.authService.userRepo.GetByEmail(ctx, newEmail); err == nil {
+		return ErrEmailAlreadyInUse
+	}
+
+	count, err := s.repo.CountSince(ctx, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= emailChangeMaxPerHour {
+		return ErrTooManyEmailChanges
+	}
+	if err := s.repo.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	ttl := time.Duration(s.config.TTL) * time.Minute
+	verification := &models.EmailVerification{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Email:     newEmail,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	token, err := s.authService.tokenService.GenerateEmailChangeToken(user.ID, verification.ID, newEmail, ttl)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate confirmation token", errors.ErrInternalServer.Status)
+	}
+	verification.TokenHash = utils.HashToken(token)
+	if err := s.repo.Create(ctx, verification); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.URL, "EMAIL_CHANGE_URL", token)
+	if err != nil {
+		return err
+	}
+	if err := utils.SendEmailChangeEmail(newEmail, link, s.config.TTL); err != nil {
+		log.Printf("RequestEmailChange: Failed to send confirmation email to %s: %v", newEmail, err)
+		return errors.WrapError(err, "EMAIL_SEND_FAILED", "Failed to send confirmation email", errors.ErrInternalServer.Status)
+	}
+
+	s.audit(ctx, user, "user.email_change_requested", ipAddress, map[string]interface{}{
+		"email":     user.Email,
+		"new_email": newEmail,
+	})
+	return nil
+}
+
+// ConfirmChange applies the change from a confirmation link and revokes every refresh token, so the
+// user signs in again with the new email. Access tokens already issued carry the old email until
+// they expire.
+func (s *EmailChangeService) ConfirmChange(ctx context.Context, token, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrEmailChangeDisabled
+	}
+
+	claims, err := s.authService.tokenService.ValidateEmailChangeToken(token)
+	if err != nil {
+		return ErrInvalidEmailChange
+	}
+	verificationID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return ErrInvalidEmailChange
+	}
+	verification, err := s.repo.Consume(ctx, verificationID, utils.HashToken(token))
+	if err == errors.ErrNotFound {
+		return ErrInvalidEmailChange
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.authService.userRepo.GetByID(ctx, verification.UserID)
+	if err != nil {
+		return ErrInvalidEmailChange
+	}
+	oldEmail := user.Email
+
+	if err := s.authService.userRepo.UpdateEmail(ctx, user.ID, verification.Email); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == "CONFLICT" {
+			return ErrEmailAlreadyInUse
+		}
+		return err
+	}
+	user.Email = verification.Email
+
+	if err := s.authService.tokenRepo.RevokeAllForUser(ctx, user.ID, user.ID); err != nil {
+		log.Printf("Warning: Failed to revoke refresh tokens for user %s: %v", user.ID, err)
+	}
+	// Proxy sessions carry the email they were issued to
+	s.authService.proxy.revokeLogged(ctx, oldEmail, "email change")
+	s.libreChatSync.SyncEmailAsync(oldEmail, user.Email)
+
+	s.audit(ctx, user, "user.email_changed", ipAddress, map[string]interface{}{
+		"old_email": oldEmail,
+		"email":     user.Email,
+	})
+	return nil
+}
+
+func (s *EmailChangeService) audit(ctx context.Context, user *models.User, action, ipAddress string, metadata map[string]interface{}) {
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		IPAddress:    &ipAddress,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
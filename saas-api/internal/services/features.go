@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// FeatureService resolves which features (models.Features) an org has: those of its subscription
+// plan, overridden by the org's feature flags. Only super admins set flags.
+type FeatureService struct {
+	orgRepo      *repositories.OrganizationRepository
+	flagRepo     *repositories.FeatureFlagRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewFeatureService(orgRepo *repositories.OrganizationRepository, flagRepo *repositories.FeatureFlagRepository, auditLogRepo *repositories.AuditLogRepository) *FeatureService {
+	return &FeatureService{
+		orgRepo:      orgRepo,
+		flagRepo:     flagRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// Features lists every feature with whether the org has it
+func (s *FeatureService) Features(ctx context.Context, orgID uuid.UUID) (*models.OrgFeatures, error) {
+	org, flags, err := s.load(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &models.OrgFeatures{OrgID: &org.ID, Plan: org.SubscriptionPlan, Features: make([]*models.FeatureState, 0, len(models.Features))}
+	for _, f := range models.Features {
+		state := policy.Feature(org, flags, f.Name, now)
+		state.Description = f.Description
+		result.Features = append(result.Features, state)
+	}
+	return result, nil
+}
+
+// SuperAdminFeatures lists every feature as enabled, as super admins are not checked
+func (s *FeatureService) SuperAdminFeatures() *models.OrgFeatures {
+	result := &models.OrgFeatures{Features: make([]*models.FeatureState, 0, len(models.Features))}
+	for _, f := range models.Features {
+		result.Features = append(result.Features, &models.FeatureState{
+			Feature: f.Name, Description: f.Description, Enabled: true, Source: "super_admin",
+		})
+	}
+	return result
+}
+
+// Feature tells whether the org has one feature, for FeatureMiddleware
+func (s *FeatureService) Feature(ctx context.Context, orgID uuid.UUID, feature string) (*models.FeatureState, error) {
+	org, flags, err := s.load(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Feature(org, flags, feature, time.Now()), nil
+}
+
+// Enabled names the features a user may use, for login responses and token introspection: every
+// feature for super admins, none for users without an org. Lookup failures are logged and list none.
+func (s *FeatureService) Enabled(ctx context.Context, user *models.User) []string {
+	var names []string
+	if user.IsSuperAdmin {
+		for _, f := range models.Features {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+	if user.OrgID == nil {
+		return nil
+	}
+
+	features, err := s.Features(ctx, *user.OrgID)
+	if err != nil {
+		log.Printf("Warning: Failed to resolve features of org %s for %s: %v", *user.OrgID, user.Email, err)
+		return nil
+	}
+	for _, f := range features.Features {
+		if f.Enabled {
+			names = append(names, f.Feature)
+		}
+	}
+	return names
+}
+
+// SetFlag grants (enabled) or withdraws a feature for the org whatever its plan
+func (s *FeatureService) SetFlag(ctx context.Context, orgID uuid.UUID, feature string, enabled bool, actorID uuid.UUID) (*models.FeatureState, error) {
+	if err := checkFeature(feature); err != nil {
+		return nil, err
+	}
+	if _, err := s.orgRepo.GetByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	flag := &models.FeatureFlag{OrgID: orgID, Feature: feature, Enabled: enabled, UpdatedBy: &actorID}
+	if err := s.flagRepo.Set(ctx, flag); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, orgID, "feature_flag.set", feature, &enabled, actorID)
+	return s.Feature(ctx, orgID, feature)
+}
+
+// ClearFlag removes the org's flag for a feature, so it has the feature only if its plan does
+func (s *FeatureService) ClearFlag(ctx context.Context, orgID uuid.UUID, feature string, actorID uuid.UUID) (*models.FeatureState, error) {
+	if err := checkFeature(feature); err != nil {
+		return nil, err
+	}
+	if err := s.flagRepo.Delete(ctx, orgID, feature); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, orgID, "feature_flag.cleared", feature, nil, actorID)
+	return s.Feature(ctx, orgID, feature)
+}
+
+func (s *FeatureService) load(ctx context.Context, orgID uuid.UUID) (*models.Organization, map[string]*models.FeatureFlag, error) {
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	flags, err := s.flagRepo.ListForOrg(ctx, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, flags, nil
+}
+
+func checkFeature(feature string) error {
+	for _, f := range models.Features {
+		if f.Name == feature {
+			return nil
+		}
+	}
+	return errors.NewError("VALIDATION_ERROR", fmt.Sprintf("Unknown feature %q", feature), http.StatusBadRequest)
+}
+
+func (s *FeatureService) audit(ctx context.Context, orgID uuid.UUID, action, feature string, enabled *bool, actorID uuid.UUID) {
+	resourceType := "organization"
+	metadata := map[string]interface{}{"feature": feature}
+	if enabled != nil {
+		metadata["enabled"] = *enabled
+	}
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        &orgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &orgID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for org %s: %v", action, orgID, err)
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"saas-api/internal/models"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const importLimitCode = "IMPORT_LIMIT_EXCEEDED"
+
+var ErrInvalidArchive = errors.NewError(errors.ErrValidation.Code, "The file is not a valid ZIP archive", errors.ErrValidation.Status)
+
+// Folder import entry types and results
+const (
+	FolderImportFolder = "folder"
+	FolderImportFile   = "file"
+
+	FolderImportCreated  = "created"
+	FolderImportExisting = "existing" // a folder of that name was already there; its contents are merged
+	FolderImportSkipped  = "skipped"
+	FolderImportFailed   = "failed"
+)
+
+// FolderImportRequest is a ZIP archive to unpack into an org's folders
+type FolderImportRequest struct {
+	OrgID    uuid.UUID
+	ParentID *uuid.UUID // Folder the archive's top level goes into; nil for the org's top level
+	UserID   uuid.UUID
+	Archive  io.ReaderAt
+	Size     int64
+}
+
+// FolderImportEntry is what became of one archive entry
+type FolderImportEntry struct {
+	Path           string     `json:"path"`
+	Type           string     `json:"type"`
+	Status         string     `json:"status"`
+	FolderID       *uuid.UUID `json:"folder_id,omitempty"`
+	DocumentID     *int64     `json:"document_id,omitempty"`
+	DocumentStatus string     `json:"document_status,omitempty"`
+	Message        string     `json:"message,omitempty"`
+}
+
+// FolderImportResult lists every archive entry in archive order, with totals
+type FolderImportResult struct {
+	FoldersCreated   int                  `json:"folders_created"`
+	DocumentsCreated int                  `json:"documents_created"`
+	Skipped          int                  `json:"skipped"`
+	Failed           int                  `json:"failed"`
+	Entries          []*FolderImportEntry `json:"entries"`
+}
+
+func (r *FolderImportResult) add(entry *FolderImportEntry) {
+	switch {
+	case entry.Status == FolderImportCreated && entry.Type == FolderImportFolder:
+		r.FoldersCreated++
+	case entry.Status == FolderImportCreated:
+		r.DocumentsCreated++
+	case entry.Status == FolderImportSkipped:
+		r.Skipped++
+	case entry.Status == FolderImportFailed:
+		r.Failed++
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+// ImportFolderZip recreates the archive's directory tree as folders under req.ParentID and uploads its
+// files as documents, which are processed like any upload. Folders that already exist are reused, so
+// an archive can be imported again to add what was missing. The limits in FolderImport are checked
+// before anything is created; files of other types, macOS and Windows metadata files and entries
+// pointing outside the archive are skipped. A file that fails does not stop the import.
+func (s *DocumentService) ImportFolderZip(ctx context.Context, req *FolderImportRequest) (*FolderImportResult, error) {
+	limits := s.FolderImport
+	if req.Size > int64(limits.MaxArchiveMB)<<20 {
+		return nil, errors.NewError(importLimitCode, fmt.Sprintf("The archive is larger than %d MB", limits.MaxArchiveMB), http.StatusRequestEntityTooLarge)
+	}
+	archive, err := zip.NewReader(req.Archive, req.Size)
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+
+	imp := &folderImporter{s: s, req: req, result: &FolderImportResult{Entries: make([]*FolderImportEntry, 0, len(archive.File))}}
+	imp.folders = map[string]*models.Folder{"": nil}
+	if req.ParentID != nil {
+		parent, err := s.repositories.Folder.GetByID(ctx, *req.ParentID)
+		if err != nil || parent.OrgID != req.OrgID {
+			return nil, errors.NewError(errors.ErrNotFound.Code, "Parent folder not found", errors.ErrNotFound.Status)
+		}
+		imp.folders[""] = parent
+	}
+
+	files, total := 0, uint64(0)
+	for _, f := range archive.File {
+		if _, skip := importEntry(f, limits.Extensions); skip == "" && !f.FileInfo().IsDir() {
+			files++
+			total += f.UncompressedSize64
+		}
+	}
+	if files > limits.MaxFiles {
+		return nil, errors.NewError(importLimitCode, fmt.Sprintf("The archive has %d files, more than the %d allowed per import", files, limits.MaxFiles), http.StatusRequestEntityTooLarge)
+	}
+	if total > uint64(limits.MaxTotalMB)<<20 {
+		return nil, errors.NewError(importLimitCode, fmt.Sprintf("The archive's files add up to more than %d MB", limits.MaxTotalMB), http.StatusRequestEntityTooLarge)
+	}
+
+	for _, f := range archive.File {
+		name, skip := importEntry(f, limits.Extensions)
+		switch {
+		case skip != "":
+			entryType := FolderImportFile
+			if f.FileInfo().IsDir() {
+				entryType = FolderImportFolder
+			}
+			imp.result.add(&FolderImportEntry{Path: f.Name, Type: entryType, Status: FolderImportSkipped, Message: skip})
+		case f.FileInfo().IsDir():
+			imp.folder(ctx, name)
+		default:
+			imp.file(ctx, f, name)
+		}
+	}
+
+	fmt.Printf("📦 Imported archive into org %s: %d folders and %d documents created, %d skipped, %d failed\n",
+		req.OrgID, imp.result.FoldersCreated, imp.result.DocumentsCreated, imp.result.Skipped, imp.result.Failed)
+	return imp.result, nil
+}
+
+// importEntry returns the entry's cleaned path, or why it is skipped
+func importEntry(f *zip.File, extensions []string) (string, string) {
+	name := strings.ReplaceAll(f.Name, "\\", "/")
+	cleaned := path.Clean(name)
+	if strings.HasPrefix(name, "/") || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", "path outside the archive"
+	}
+
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == "__MACOSX" || strings.HasPrefix(segment, "._") {
+			return "", "system file"
+		}
+	}
+	if f.FileInfo().IsDir() {
+		return cleaned, ""
+	}
+	switch strings.ToLower(path.Base(cleaned)) {
+	case ".ds_store", "thumbs.db", "desktop.ini":
+		return "", "system file"
+	}
+
+	ext := strings.ToLower(path.Ext(cleaned))
+	for _, allowed := range extensions {
+		if ext == allowed {
+			return cleaned, ""
+		}
+	}
+	return "", "unsupported file type"
+}
+
+// folderImporter carries one import's folders, keyed by their path in the archive ("" is the parent)
+type folderImporter struct {
+	s       *DocumentService
+	req     *FolderImportRequest
+	folders map[string]*models.Folder
+	failed  map[string]error
+	result  *FolderImportResult
+}
+
+// folder returns the folder for an archive directory, finding or creating it and its parents. Each
+// directory is reported once, the first time it is needed.
+func (imp *folderImporter) folder(ctx context.Context, dir string) (*models.Folder, error) {
+	if folder, ok := imp.folders[dir]; ok {
+		return folder, nil
+	}
+	if err, ok := imp.failed[dir]; ok {
+		return nil, err
+	}
+
+	parentDir := path.Dir(dir)
+	if parentDir == "." {
+		parentDir = ""
+	}
+	parent, err := imp.folder(ctx, parentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, status, err := imp.findOrCreate(ctx, parent, path.Base(dir))
+	if err != nil {
+		if imp.failed == nil {
+			imp.failed = make(map[string]error)
+		}
+		imp.failed[dir] = err
+		imp.result.add(&FolderImportEntry{Path: dir + "/", Type: FolderImportFolder, Status: FolderImportFailed, Message: err.Error()})
+		return nil, err
+	}
+	imp.folders[dir] = folder
+	imp.result.add(&FolderImportEntry{Path: dir + "/", Type: FolderImportFolder, Status: status, FolderID: &folder.ID})
+	return folder, nil
+}
+
+func (imp *folderImporter) findOrCreate(ctx context.Context, parent *models.Folder, name string) (*models.Folder, string, error) {
+	var parentID *uuid.UUID
+	if parent != nil {
+		parentID = &parent.ID
+	}
+
+	siblings, err := imp.s.repositories.Folder.List(ctx, imp.req.OrgID, parentID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, sibling := range siblings {
+		if sibling.Name == name {
+			return sibling, FolderImportExisting, nil
+		}
+	}
+
+	folder := &models.Folder{
+		ID:        uuid.New(),
+		OrgID:     imp.req.OrgID,
+		Name:      name,
+		ParentID:  parentID,
+		CreatedBy: &imp.req.UserID,
+	}
+	if err := imp.s.repositories.Folder.Create(ctx, folder); err != nil {
+		return nil, "", err
+	}
+	return folder, FolderImportCreated, nil
+}
+
+// file uploads one archive file into its directory's folder, stored and named like a regular upload
+func (imp *folderImporter) file(ctx context.Context, f *zip.File, name string) {
+	entry := &FolderImportEntry{Path: f.Name, Type: FolderImportFile}
+	defer imp.result.add(entry)
+
+	dir := path.Dir(name)
+	if dir == "." {
+		dir = ""
+	}
+	folder, err := imp.folder(ctx, dir)
+	if err != nil {
+		entry.Status, entry.Message = FolderImportFailed, "folder could not be created"
+		return
+	}
+
+	filename := strings.ReplaceAll(path.Base(name), " ", "_")
+	filename = strings.ReplaceAll(filename, "'", "")
+	dbFilePath := path.Join(imp.req.OrgID.String(), filename)
+	var folderID *string
+	if folder != nil {
+		dbFilePath = path.Join(imp.req.OrgID.String(), strings.TrimPrefix(folder.Path, "/"), filename)
+		id := folder.ID.String()
+		folderID = &id
+	}
+
+	// archive/zip fails the read if an entry inflates past its declared size, so the checked total holds
+	src, err := f.Open()
+	if err != nil {
+		entry.Status, entry.Message = FolderImportFailed, err.Error()
+		return
+	}
+	defer src.Close()
+
+	orgID := imp.req.OrgID
+	stored, err := imp.s.StoreUpload(ctx, &orgID, src, dbFilePath)
+	if err != nil {
+		entry.Status, entry.Message = FolderImportFailed, "failed to save file"
+		return
+	}
+
+	response, err := imp.s.UploadDocument(ctx, &UploadDocumentRequest{
+		UserID:    imp.req.UserID.String(),
+		OrgID:     &orgID,
+		FilePath:  stored.FilePath,
+		Filename:  filename,
+		Checksum:  stored.Checksum,
+		SizeBytes: stored.SizeBytes,
+		FolderID:  folderID,
+	})
+	if err != nil {
+		entry.Status, entry.Message = FolderImportFailed, err.Error()
+		return
+	}
+	entry.Status = FolderImportCreated
+	entry.DocumentID = &response.DocumentID
+	entry.DocumentStatus = response.Status
+	if folder != nil {
+		entry.FolderID = &folder.ID
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImpersonateSelf       = errors.NewError("VALIDATION_ERROR", "You cannot impersonate yourself", http.StatusBadRequest)
+	ErrImpersonateSuperAdmin = errors.NewError("FORBIDDEN", "Super admins cannot be impersonated", http.StatusForbidden)
+	ErrImpersonateInactive   = errors.NewError("ACCOUNT_INACTIVE", "Only active users can be impersonated", http.StatusForbidden)
+	ErrImpersonationDisabled = errors.NewError("SERVICE_UNAVAILABLE", "Impersonation is disabled (JWT_IMPERSONATION_TTL is 0)", http.StatusServiceUnavailable)
+)
+
+// ImpersonationService lets super admins act as a user to support them. The impersonation token is
+// a short-lived access token for the user that also names the super admin; AuthMiddleware tags every
+// request made with it, and audit logs written during those requests carry the impersonator's ID.
+type ImpersonationService struct {
+	userRepo     *repositories.UserRepository
+	tokenService *auth.TokenService
+	auditLogRepo *repositories.AuditLogRepository
+	ttl          time.Duration
+}
+
+// NewImpersonationService issues tokens valid for ttl; 0 disables impersonation
+func NewImpersonationService(userRepo *repositories.UserRepository, tokenService *auth.TokenService, auditLogRepo *repositories.AuditLogRepository, ttl time.Duration) *ImpersonationService {
+	return &ImpersonationService{
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		auditLogRepo: auditLogRepo,
+		ttl:          ttl,
+	}
+}
+
+// Impersonate issues a token acting as the user for the super admin impersonatorID, and records it
+// in the audit log with both identities. Super admins, inactive users and the caller themselves
+// cannot be impersonated.
+func (s *ImpersonationService) Impersonate(ctx context.Context, impersonatorID, userID uuid.UUID, reason, ipAddress, userAgent string) (*models.ImpersonationResponse, error) {
+	if s.ttl <= 0 {
+		return nil, ErrImpersonationDisabled
+	}
+	if impersonatorID == userID {
+		return nil, ErrImpersonateSelf
+	}
+
+	impersonator, err := s.userRepo.GetByID(ctx, impersonatorID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsSuperAdmin {
+		return nil, ErrImpersonateSuperAdmin
+	}
+	if user.Status != "active" {
+		return nil, ErrImpersonateInactive
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	accessToken, err := s.tokenService.GenerateImpersonationToken(user, impersonatorID, s.ttl)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
+	}
+
+	metadata := map[string]interface{}{
+		"impersonator_id":    impersonatorID.String(),
+		"impersonator_email": impersonator.Email,
+		"user_id":            user.ID.String(),
+		"email":              user.Email,
+		"expires_at":         expiresAt.UTC().Format(time.RFC3339),
+	}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &impersonatorID,
+		OrgID:        user.OrgID,
+		IPAddress:    &ipAddress,
+		UserAgent:    &userAgent,
+		Action:       "user.impersonated",
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		// No token without a trail
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to record impersonation", errors.ErrInternalServer.Status)
+	}
+
+	return &models.ImpersonationResponse{
+		AccessToken:    accessToken,
+		TokenType:      "Bearer",
+		ExpiresIn:      int(s.ttl.Seconds()),
+		ExpiresAt:      expiresAt,
+		User:           user,
+		ImpersonatorID: impersonatorID,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"slices"
+	"strings"
+
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/pkg/errors"
+)
+
+// Introspect describes an access token for sibling services (the proxy, the MCP server), so they need
+// not validate JWTs themselves. Like the proxy token exchange it is a server-to-server call that needs
+// the shared proxy secret. The user, org and permissions are read now, not taken from the token, so
+// suspended users and revoked roles show at once.
+func (s *AuthService) Introspect(ctx context.Context, proxySecret string, req models.IntrospectRequest) (*models.IntrospectionResponse, error) {
+	if s.config.Proxy.SharedSecret == "" {
+		return nil, errors.NewError("NOT_FOUND", "Token introspection is not enabled", 404)
+	}
+	if subtle.ConstantTimeCompare([]byte(proxySecret), []byte(s.config.Proxy.SharedSecret)) != 1 {
+		return nil, errors.ErrUnauthorized
+	}
+
+	inactive := &models.IntrospectionResponse{Active: false}
+	if req.TokenTypeHint != "" && req.TokenTypeHint != "access_token" {
+		return inactive, nil
+	}
+	claims, err := s.tokenService.ValidateToken(req.Token)
+	// Refresh tokens carry no email
+	if err != nil || claims.Email == "" {
+		return inactive, nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if user.Status != "active" || checkLocked(user) != nil {
+		return inactive, nil
+	}
+
+	permissions, err := s.userRepo.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to get permissions", errors.ErrInternalServer.Status)
+	}
+	// A client token only has the permissions in its scope; a wildcard permission only the scopes it
+	// covers
+	scopes := strings.Fields(claims.Scope)
+	names := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		name := p.Resource + ":" + p.Action
+		switch {
+		case claims.ClientID == nil || policy.ScopesAllow(scopes, p.Resource, p.Action):
+			names = append(names, name)
+		case p.Action == models.PermissionWildcard:
+			for _, scope := range scopes {
+				if strings.HasPrefix(scope, p.Resource+":") {
+					names = append(names, scope)
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	response := &models.IntrospectionResponse{
+		Active:         true,
+		TokenType:      "access_token",
+		Issuer:         claims.Issuer,
+		Subject:        claims.Subject,
+		Username:       user.Email,
+		UserID:         &user.ID,
+		Email:          user.Email,
+		OrgID:          user.OrgID,
+		IsSuperAdmin:   user.IsSuperAdmin,
+		ImpersonatorID: claims.ImpersonatorID,
+		ClientID:       claims.ClientID,
+		Scope:          claims.Scope,
+		Permissions:    names,
+		Features:       s.enabledFeatures(ctx, user),
+	}
+	if user.OrgRole != nil {
+		response.OrgRole = *user.OrgRole
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response.NotBefore = claims.NotBefore.Unix()
+	}
+	if claims.AuthTime != nil {
+		response.AuthTime = claims.AuthTime.Unix()
+	}
+	return response, nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvitationsDisabled  = errors.NewError("SERVICE_UNAVAILABLE", "Invitations are not configured (INVITATION_URL is not set)", http.StatusServiceUnavailable)
+	ErrInvalidInvitation    = errors.NewError("INVALID_TOKEN", "This invitation is invalid, expired or already used", http.StatusUnauthorized)
+	ErrInvitationNotFound   = errors.NewError("NOT_FOUND", "Invitation not found", http.StatusNotFound)
+	ErrInvitationClosed     = errors.NewError("CONFLICT", "This invitation was already accepted or cancelled", http.StatusConflict)
+	ErrInvitationPending    = errors.NewError("CONFLICT", "This email already has a pending invitation", http.StatusConflict)
+	ErrInvitationRole       = errors.NewError("VALIDATION_ERROR", "The role must belong to the organization", http.StatusBadRequest)
+	ErrInvitationUserExists = errors.NewError("CONFLICT", "A user with this email already exists", http.StatusConflict)
+)
+
+// InvitationService invites people into an org by email. The invitation names a role of the org; the
+// emailed link is signed and single-use, and accepting it creates an active account with that role.
+type InvitationService struct {
+	repo         *repositories.InvitationRepository
+	userRepo     *repositories.UserRepository
+	roleRepo     *repositories.RoleRepository
+	orgRepo      *repositories.OrganizationRepository
+	auditLogRepo *repositories.AuditLogRepository
+	tokenService *auth.TokenService
+	config       config.InvitationConfig
+}
+
+func NewInvitationService(repo *repositories.InvitationRepository, userRepo *repositories.UserRepository, roleRepo *repositories.RoleRepository, orgRepo *repositories.OrganizationRepository, auditLogRepo *repositories.AuditLogRepository, tokenService *auth.TokenService, cfg config.InvitationConfig) *InvitationService {
+	return &InvitationService{
+		repo:         repo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		orgRepo:      orgRepo,
+		auditLogRepo: auditLogRepo,
+		tokenService: tokenService,
+		config:       cfg,
+	}
+}
+
+// Enabled reports whether there is a frontend page for the emailed links
+func (s *InvitationService) Enabled() bool {
+	return s.config.URL != ""
+}
+
+// Create invites email into the org and sends the link. The email must not have an account or a
+// pending invitation to the org yet.
+func (s *InvitationService) Create(ctx context.Context, orgID uuid.UUID, req models.CreateInvitationRequest, invitedBy uuid.UUID, ipAddress string) (*models.OrgInvitation, error) {
+	if !s.Enabled() {
+		return nil, ErrInvitationsDisabled
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, ErrInvitationUserExists
+	}
+	pending, err := s.repo.HasPending(ctx, orgID, email)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrInvitationPending
+	}
+
+	role, err := s.roleRepo.GetByID(ctx, req.RoleID)
+	if err != nil || role.OrgID == nil || *role.OrgID != orgID {
+		return nil, ErrInvitationRole
+	}
+
+	expiresAt, err := s.expiresAt(req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.OrgInvitation{
+		ID:        uuid.New(),
+		OrgID:     orgID,
+		Email:     email,
+		RoleID:    role.ID,
+		InvitedBy: invitedBy,
+		ExpiresAt: expiresAt,
+	}
+	token, err := s.tokenService.GenerateInvitationToken(invitation.ID, orgID, email, expiresAt)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate invitation token", errors.ErrInternalServer.Status)
+	}
+	invitation.TokenHash = utils.HashToken(token)
+	if err := s.repo.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	if err := s.send(ctx, invitation, token); err != nil {
+		// Nobody can accept it, so it should not block inviting the email again
+		if _, cancelErr := s.repo.Cancel(ctx, orgID, invitation.ID, invitedBy); cancelErr != nil {
+			log.Printf("Warning: Failed to cancel unsent invitation %s: %v", invitation.ID, cancelErr)
+		}
+		return nil, err
+	}
+
+	s.audit(ctx, invitedBy, invitation, "invitation.created", ipAddress, map[string]interface{}{
+		"role_id":    role.ID.String(),
+		"role_name":  role.Name,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+	return invitation, nil
+}
+
+// List returns the org's invitations; status filters by pending, accepted, cancelled or expired
+func (s *InvitationService) List(ctx context.Context, orgID uuid.UUID, status string) ([]*models.OrgInvitation, error) {
+	switch status {
+	case "", models.InvitationPending, models.InvitationAccepted, models.InvitationCancelled, models.InvitationExpired:
+	default:
+		return nil, errors.NewError("VALIDATION_ERROR", "status must be one of: pending, accepted, cancelled, expired", http.StatusBadRequest)
+	}
+	return s.repo.List(ctx, orgID, status)
+}
+
+// Cancel stops the invitation's link from working
+func (s *InvitationService) Cancel(ctx context.Context, orgID, id, cancelledBy uuid.UUID, ipAddress string) (*models.OrgInvitation, error) {
+	if _, err := s.get(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.repo.Cancel(ctx, orgID, id, cancelledBy)
+	if err == errors.ErrNotFound {
+		return nil, ErrInvitationClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, cancelledBy, invitation, "invitation.cancelled", ipAddress, nil)
+	return invitation, nil
+}
+
+// Resend emails a new link for an invitation that was neither accepted nor cancelled, expired ones
+// included, valid for another INVITATION_TTL days. The previous link stops working.
+func (s *InvitationService) Resend(ctx context.Context, orgID, id, resentBy uuid.UUID, ipAddress string) (*models.OrgInvitation, error) {
+	if !s.Enabled() {
+		return nil, ErrInvitationsDisabled
+	}
+	current, err := s.get(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := s.expiresAt(nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.tokenService.GenerateInvitationToken(current.ID, orgID, current.Email, expiresAt)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate invitation token", errors.ErrInternalServer.Status)
+	}
+	invitation, err := s.repo.Renew(ctx, orgID, id, utils.HashToken(token), expiresAt)
+	if err == errors.ErrNotFound {
+		return nil, ErrInvitationClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.send(ctx, invitation, token); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, resentBy, invitation, "invitation.resent", ipAddress, map[string]interface{}{
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+	return invitation, nil
+}
+
+// Accept creates the invited account from the link's token, with the invitation's email and role,
+// and uses the invitation up. The email counts as verified, since the link was sent to it.
+func (s *InvitationService) Accept(ctx context.Context, req models.AcceptInvitationRequest, ipAddress string) (*models.User, error) {
+	if !s.Enabled() {
+		return nil, ErrInvitationsDisabled
+	}
+
+	claims, err := s.tokenService.ValidateInvitationToken(req.Token)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	invitationID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	invitation, err := s.repo.Claim(ctx, invitationID, utils.HashToken(req.Token))
+	if err == errors.ErrNotFound {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.createUser(ctx, invitation, req)
+	if err != nil {
+		// The link stays usable, e.g. after a password the hashing rejected
+		if releaseErr := s.repo.Release(ctx, invitation.ID); releaseErr != nil {
+			log.Printf("Warning: Failed to release invitation %s: %v", invitation.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := s.repo.SetAcceptedBy(ctx, invitation.ID, user.ID); err != nil {
+		log.Printf("Warning: Failed to record the account of invitation %s: %v", invitation.ID, err)
+	}
+	invitation.AcceptedBy = &user.ID
+
+	s.audit(ctx, user.ID, invitation, "invitation.accepted", ipAddress, map[string]interface{}{
+		"user_id": user.ID.String(),
+	})
+	user.PasswordHash = ""
+	return user, nil
+}
+
+func (s *InvitationService) createUser(ctx context.Context, invitation *models.OrgInvitation, req models.AcceptInvitationRequest) (*models.User, error) {
+	if _, err := s.userRepo.GetByEmail(ctx, invitation.Email); err == nil {
+		return nil, ErrInvitationUserExists
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to hash password", errors.ErrInternalServer.Status)
+	}
+
+	orgRole := "user"
+	user := &models.User{
+		ID:            uuid.New(),
+		OrgID:         &invitation.OrgID,
+		Email:         invitation.Email,
+		PasswordHash:  passwordHash,
+		FirstName:     req.FirstName,
+		LastName:      req.LastName,
+		OrgRole:       &orgRole,
+		Status:        "active",
+		EmailVerified: true,
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		Metadata:      map[string]interface{}{"invitation_id": invitation.ID.String()},
+		InvitedBy:     &invitation.InvitedBy,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.AssignRoleToUser(ctx, user.ID, invitation.RoleID, invitation.InvitedBy, nil); err != nil {
+		// The account exists either way; an admin can assign the role from the users API
+		log.Printf("Warning: Failed to assign role %s to invited user %s: %v", invitation.RoleID, user.ID, err)
+	}
+	return user, nil
+}
+
+// get returns the org's invitation if it is neither accepted nor cancelled
+func (s *InvitationService) get(ctx context.Context, orgID, id uuid.UUID) (*models.OrgInvitation, error) {
+	invitation, err := s.repo.GetByID(ctx, orgID, id)
+	if err == errors.ErrNotFound {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status == models.InvitationAccepted || invitation.Status == models.InvitationCancelled {
+		return nil, ErrInvitationClosed
+	}
+	return invitation, nil
+}
+
+// expiresAt applies INVITATION_TTL, or the requested expiry within INVITATION_MAX_TTL
+func (s *InvitationService) expiresAt(requested *time.Time) (time.Time, error) {
+	now := time.Now()
+	if requested == nil {
+		return now.Add(time.Duration(s.config.TTL) * 24 * time.Hour), nil
+	}
+	maxTTL := time.Duration(s.config.MaxTTL) * 24 * time.Hour
+	if !requested.After(now) || requested.After(now.Add(maxTTL)) {
+		return time.Time{}, errors.NewError("VALIDATION_ERROR", "expires_at must be in the future and within INVITATION_MAX_TTL days", http.StatusBadRequest)
+	}
+	return *requested, nil
+}
+
+// send emails the invitation's link, naming the org and who invited
+func (s *InvitationService) send(ctx context.Context, invitation *models.OrgInvitation, token string) error {
+	org, err := s.orgRepo.GetByID(ctx, invitation.OrgID)
+	if err != nil {
+		return err
+	}
+	inviterName := "A member of your team"
+	if inviter, err := s.userRepo.GetByID(ctx, invitation.InvitedBy); err == nil {
+		inviterName = inviter.Email
+		if inviter.FullName != "" {
+			inviterName = inviter.FullName
+		}
+	}
+
+	link, err := tokenLink(s.config.URL, "INVITATION_URL", token)
+	if err != nil {
+		return err
+	}
+	if err := utils.SendInvitationEmail(invitation.Email, org.Name, inviterName, link, invitation.ExpiresAt); err != nil {
+		log.Printf("Invitation: Failed to send invitation email to %s: %v", invitation.Email, err)
+		return errors.WrapError(err, "EMAIL_SEND_FAILED", "Failed to send invitation email", errors.ErrInternalServer.Status)
+	}
+	return nil
+}
+
+func (s *InvitationService) audit(ctx context.Context, actorID uuid.UUID, invitation *models.OrgInvitation, action, ipAddress string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["email"] = invitation.Email
+	resourceType := "invitation"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        &invitation.OrgID,
+		IPAddress:    &ipAddress,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &invitation.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for invitation %s: %v", action, invitation.ID, err)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const libreChatSyncTimeout = 10 * time.Second
+
+// LibreChatSync copies profile changes (name, username, avatar) to the matching LibreChat Mongo user,
+// so the chat UI does not show stale values until the next proxy login.
+type LibreChatSync struct {
+	cfg config.LibreChatConfig
+
+	mu     sync.Mutex
+	client *mongo.Client
+}
+
+func NewLibreChatSync(cfg config.LibreChatConfig) *LibreChatSync {
+	return &LibreChatSync{cfg: cfg}
+}
+
+// SyncProfileAsync pushes the user's profile in the background; failures are logged, never returned,
+// because LibreChat being unavailable must not fail the saas-api update.
+func (s *LibreChatSync) SyncProfileAsync(user *models.User) {
+	if s == nil || !s.cfg.ProfileSync {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), libreChatSyncTimeout)
+		defer cancel()
+		if err := s.SyncProfile(ctx, user); err != nil {
+			log.Printf("LibreChat sync: failed to update profile for %s: %v", user.Email, err)
+		}
+	}()
+}
+
+// SyncProfile updates the LibreChat user with the same email. Users that never logged in to
+// LibreChat have no document yet; the proxy creates it with current values on first login.
+func (s *LibreChatSync) SyncProfile(ctx context.Context, user *models.User) error {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	username, name := libreChatNames(user)
+	collection := client.Database(libreChatDatabaseName(s.cfg.MongoURI)).Collection("users")
+	result, err := collection.UpdateOne(ctx, bson.M{"email": user.Email}, bson.M{
+		"$set": bson.M{
+			"name":      name,
+			"username":  username,
+			"avatar":    user.AvatarURL,
+			"updatedAt": time.Now(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update LibreChat user: %w", err)
+	}
+
+	if result.MatchedCount > 0 {
+		log.Printf("LibreChat sync: updated profile for %s", user.Email)
+	}
+	return nil
+}
+
+// SyncEmailAsync moves the LibreChat user to the user's new email in the background, so the proxy
+// keeps finding it (LibreChat users are matched by email). Unlike profile syncs it does not depend on
+// LIBRECHAT_PROFILE_SYNC, since a stale email orphans the user's chats. Failures are logged.
+func (s *LibreChatSync) SyncEmailAsync(oldEmail, newEmail string) {
+	if s == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), libreChatSyncTimeout)
+		defer cancel()
+		client, err := s.getClient(ctx)
+		if err != nil {
+			log.Printf("LibreChat sync: failed to update email for %s: %v", oldEmail, err)
+			return
+		}
+		collection := client.Database(libreChatDatabaseName(s.cfg.MongoURI)).Collection("users")
+		result, err := collection.UpdateOne(ctx, bson.M{"email": oldEmail}, bson.M{
+			"$set": bson.M{"email": newEmail, "updatedAt": time.Now()},
+		})
+		if err != nil {
+			log.Printf("LibreChat sync: failed to update email for %s: %v", oldEmail, err)
+			return
+		}
+		if result.MatchedCount > 0 {
+			log.Printf("LibreChat sync: moved %s to %s", oldEmail, newEmail)
+		}
+	}()
+}
+
+// DeleteSessions signs the user out of LibreChat by deleting every session of the LibreChat user
+// with the email, and returns how many there were. Users that never logged in to LibreChat have none.
+func (s *LibreChatSync) DeleteSessions(ctx context.Context, email string) (int64, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	db := client.Database(libreChatDatabaseName(s.cfg.MongoURI))
+	var libreUser struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err = db.Collection("users").FindOne(ctx, bson.M{"email": email}, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&libreUser)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find LibreChat user: %w", err)
+	}
+
+	result, err := db.Collection("sessions").DeleteMany(ctx, bson.M{"user": libreUser.ID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete LibreChat sessions: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// Close disconnects the Mongo client (on shutdown)
+func (s *LibreChatSync) Close(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		_ = s.client.Disconnect(ctx)
+		s.client = nil
+	}
+}
+
+func (s *LibreChatSync) getClient(ctx context.Context) (*mongo.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(s.cfg.MongoURI).SetMaxPoolSize(10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	s.client = client
+	return client, nil
+}
+
+// libreChatNames derives username and display name the same way the proxy does on login
+func libreChatNames(user *models.User) (username, name string) {
+	username = strings.Split(user.Email, "@")[0]
+
+	switch {
+	case user.FirstName != nil && user.LastName != nil:
+		name = strings.TrimSpace(*user.FirstName + " " + *user.LastName)
+	case user.FirstName != nil:
+		name = *user.FirstName
+	case user.LastName != nil:
+		name = *user.LastName
+	default:
+		name = user.FullName
+	}
+	if name == "" {
+		name = username
+	}
+	return username, name
+}
+
+// libreChatDatabaseName extracts the database from a mongodb://host:port/database URI, defaulting to LibreChat
+func libreChatDatabaseName(mongoURI string) string {
+	parsedURI, err := url.Parse(mongoURI)
+	if err == nil {
+		if path := strings.TrimPrefix(parsedURI.Path, "/"); path != "" {
+			return path
+		}
+	}
+	return "LibreChat"
+}
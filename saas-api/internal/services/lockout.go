@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// LockoutService applies the account lockout policy: after MaxAttempts failed sign-ins in a row an
+// account is locked for Duration. LOCKOUT_MAX_ATTEMPTS and LOCKOUT_DURATION set the defaults, and an
+// org overrides them with its lockout_max_attempts and lockout_duration_minutes settings. Admins lift
+// a lockout early with Unlock.
+type LockoutService struct {
+	userRepo     *repositories.UserRepository
+	orgRepo      *repositories.OrganizationRepository
+	auditLogRepo *repositories.AuditLogRepository
+	config       config.LockoutConfig
+}
+
+func NewLockoutService(userRepo *repositories.UserRepository, orgRepo *repositories.OrganizationRepository, auditLogRepo *repositories.AuditLogRepository, cfg config.LockoutConfig) *LockoutService {
+	return &LockoutService{
+		userRepo:     userRepo,
+		orgRepo:      orgRepo,
+		auditLogRepo: auditLogRepo,
+		config:       cfg,
+	}
+}
+
+// Policy returns the lockout policy of the user's org: the failed sign-ins that lock the account
+// (0: never) and for how long
+func (s *LockoutService) Policy(ctx context.Context, user *models.User) (int, time.Duration) {
+	maxAttempts, minutes := s.config.MaxAttempts, s.config.Duration
+	if user.OrgID != nil {
+		org, err := s.orgRepo.GetByID(ctx, *user.OrgID)
+		if err != nil {
+			log.Printf("Warning: Failed to load org %s for its lockout policy: %v", *user.OrgID, err)
+		} else {
+			if n, ok := settingInt(org.Settings, "lockout_max_attempts"); ok {
+				maxAttempts = n
+			}
+			if n, ok := settingInt(org.Settings, "lockout_duration_minutes"); ok && n > 0 {
+				minutes = n
+			}
+		}
+	}
+	return maxAttempts, time.Duration(minutes) * time.Minute
+}
+
+// RecordFailure counts a failed sign-in (a wrong password or 2FA code) and locks the account when
+// it reaches the policy's limit
+func (s *LockoutService) RecordFailure(ctx context.Context, user *models.User) {
+	maxAttempts, lockFor := s.Policy(ctx, user)
+	locked, err := s.userRepo.IncrementFailedLoginAttempts(ctx, user.ID, maxAttempts, lockFor)
+	if err != nil {
+		log.Printf("Warning: Failed to record failed sign-in for user %s: %v", user.ID, err)
+		return
+	}
+	if locked {
+		s.audit(ctx, user, nil, "user.locked", map[string]interface{}{
+			"max_attempts":     maxAttempts,
+			"duration_minutes": int(lockFor.Minutes()),
+		})
+	}
+}
+
+// User loads the user an admin unlocks, for the caller's org check
+func (s *LockoutService) User(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+// Unlock lifts the user's lockout, if any, and resets their failed sign-in count; actorID is the admin
+func (s *LockoutService) Unlock(ctx context.Context, user *models.User, actorID uuid.UUID) error {
+	if err := s.userRepo.Unlock(ctx, user.ID); err != nil {
+		return err
+	}
+
+	metadata := map[string]interface{}{"failed_login_attempts": user.FailedLoginAttempts}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		metadata["locked_until"] = user.LockedUntil.UTC().Format(time.RFC3339)
+	}
+	s.audit(ctx, user, &actorID, "user.unlocked", metadata)
+	return nil
+}
+
+// settingInt reads a whole number from org settings; JSON numbers decode as float64
+func settingInt(settings map[string]interface{}, key string) (int, bool) {
+	switch v := settings[key].(type) {
+	case float64:
+		if v >= 0 && v == float64(int(v)) {
+			return int(v), true
+		}
+	case int:
+		if v >= 0 {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func (s *LockoutService) audit(ctx context.Context, user *models.User, actorID *uuid.UUID, action string, metadata map[string]interface{}) {
+	resourceType := "user"
+	if actorID == nil {
+		actorID = &user.ID
+	}
+	metadata["email"] = user.Email
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       actorID,
+		OrgID:        user.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// LoginHistoryService records every sign-in attempt on an account, successful or not, so users and
+// their admins can see where and how it was signed into. Attempts that name no account (unknown
+// emails, invalid links and MFA tokens) are not recorded.
+type LoginHistoryService struct {
+	repo     *repositories.LoginEventRepository
+	userRepo *repositories.UserRepository
+}
+
+func NewLoginHistoryService(repo *repositories.LoginEventRepository, userRepo *repositories.UserRepository) *LoginHistoryService {
+	return &LoginHistoryService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// Record stores the outcome of a sign-in by user. A response with an MFA token is recorded as
+// failed with MFA_REQUIRED or MFA_ENROLLMENT_REQUIRED; the second factor records its own attempt.
+func (s *LoginHistoryService) Record(ctx context.Context, method string, user *models.User, ipAddress, userAgent string, response *models.LoginResponse, err error) {
+	event := &models.LoginEvent{
+		UserID:    user.ID,
+		OrgID:     user.OrgID,
+		Method:    method,
+		IPAddress: &ipAddress,
+		UserAgent: &userAgent,
+	}
+	var reason string
+	switch {
+	case err != nil:
+		// The innermost code, so a wrapped ACCOUNT_SUSPENDED is not recorded as INTERNAL_ERROR
+		reason = errors.ErrInternalServer.Code
+		for appErr, ok := err.(*errors.AppError); ok; appErr, ok = appErr.Err.(*errors.AppError) {
+			reason = appErr.Code
+		}
+	case response != nil && response.MFARequired:
+		reason = "MFA_REQUIRED"
+	case response != nil && response.MFAEnrollmentRequired:
+		reason = "MFA_ENROLLMENT_REQUIRED"
+	default:
+		event.Success = true
+	}
+	if reason != "" {
+		event.FailureReason = &reason
+	}
+
+	if err := s.repo.Create(ctx, event); err != nil {
+		log.Printf("Warning: Failed to record %s sign-in for user %s: %v", method, user.ID, err)
+	}
+}
+
+// User loads the user whose login history an admin reads, for the caller's org check
+func (s *LoginHistoryService) User(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *LoginHistoryService) List(ctx context.Context, userID uuid.UUID, page, limit int) ([]*models.LoginEvent, int, error) {
+	return s.repo.ListForUser(ctx, userID, page, limit)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLoginLinkDisabled = errors.NewError("SERVICE_UNAVAILABLE", "Sign-in links are not configured (LOGIN_LINK_URL is not set)", http.StatusServiceUnavailable)
+	ErrInvalidLoginLink  = errors.NewError("INVALID_TOKEN", "This sign-in link is invalid, expired or already used", http.StatusUnauthorized)
+)
+
+// LoginLinkService signs users in without a password through a signed, single-use link sent to their
+// email. It is the link counterpart of the OTP flow: the same users may use it, and the same SSO and
+// two-factor rules apply.
+type LoginLinkService struct {
+	authService  *AuthService
+	repo         *repositories.LoginLinkRepository
+	auditLogRepo *repositories.AuditLogRepository
+	config       config.LoginLinkConfig
+}
+
+func NewLoginLinkService(authService *AuthService, repo *repositories.LoginLinkRepository, auditLogRepo *repositories.AuditLogRepository, cfg config.LoginLinkConfig) *LoginLinkService {
+	return &LoginLinkService{
+		authService:  authService,
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+		config:       cfg,
+	}
+}
+
+// Enabled reports whether there is a frontend page for the emailed links
+func (s *LoginLinkService) Enabled() bool {
+	return s.config.URL != ""
+}
+
+// SendLink emails a sign-in link when the address belongs to a user who may sign in via OTP. The
+// caller gets the same answer either way, so the endpoint does not reveal which emails have accounts.
+// A new link replaces the user's earlier ones.
+func (s *LoginLinkService) SendLink(ctx context.Context, email, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrLoginLinkDisabled
+	}
+
+	user, err := s.authService.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		log.Printf("SendLoginLink: No account for %s", email)
+		return nil
+	}
+	if canLogin, err := s.authService.canLoginViaOTP(ctx, user); err != nil || !canLogin {
+		log.Printf("SendLoginLink: %s may not sign in by link, no link sent", email)
+		return nil
+	}
+	if err := s.authService.checkSSOPolicy(ctx, user); err != nil {
+		return nil
+	}
+
+	count, err := s.repo.CountSince(ctx, user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= s.config.MaxPerHour {
+		log.Printf("SendLoginLink: Rate limit reached for %s", email)
+		return nil
+	}
+
+	ttl := time.Duration(s.config.TTL) * time.Minute
+	link := &models.LoginLink{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(ttl),
+		IPAddress: &ipAddress,
+	}
+	token, err := s.authService.tokenService.GenerateLoginLinkToken(user.ID, link.ID, ttl)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate sign-in token", errors.ErrInternalServer.Status)
+	}
+	link.TokenHash = utils.HashToken(token)
+	if err := s.repo.Create(ctx, link); err != nil {
+		return err
+	}
+
+	url, err := tokenLink(s.config.URL, "LOGIN_LINK_URL", token)
+	if err != nil {
+		return err
+	}
+	// Sent in the background so the response time does not tell known emails from unknown ones
+	go func() {
+		if err := utils.SendLoginLinkEmail(user.Email, url, s.config.TTL); err != nil {
+			log.Printf("SendLoginLink: Failed to send sign-in email to %s: %v", user.Email, err)
+		}
+	}()
+
+	s.audit(ctx, user, "auth.login_link_sent", ipAddress)
+	return nil
+}
+
+// VerifyLink uses up a sign-in link and signs its user in. The account is checked again, since it may
+// have been suspended or locked after the link was sent.
+func (s *LoginLinkService) VerifyLink(ctx context.Context, token, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	user, response, err := s.verifyLink(ctx, token, ipAddress, userAgent)
+	s.authService.recordLogin(ctx, models.LoginMethodLoginLink, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// verifyLink is VerifyLink; it also returns the link's user, once known, for the login history
+func (s *LoginLinkService) verifyLink(ctx context.Context, token, ipAddress, userAgent string) (*models.User, *models.LoginResponse, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrLoginLinkDisabled
+	}
+
+	claims, err := s.authService.tokenService.ValidateLoginLinkToken(token)
+	if err != nil {
+		return nil, nil, ErrInvalidLoginLink
+	}
+	linkID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, nil, ErrInvalidLoginLink
+	}
+	link, err := s.repo.Consume(ctx, linkID, utils.HashToken(token))
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrInvalidLoginLink
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.authService.userRepo.GetByID(ctx, link.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidLoginLink
+	}
+	if err := checkLocked(user); err != nil {
+		return user, nil, err
+	}
+	canLogin, err := s.authService.canLoginViaOTP(ctx, user)
+	if err != nil {
+		return user, nil, err
+	}
+	if !canLogin {
+		return user, nil, errors.NewError("UNAUTHORIZED", "Only super admins, organization admins, or verified active users can sign in by link", 403)
+	}
+	if err := s.authService.checkSSOPolicy(ctx, user); err != nil {
+		return user, nil, err
+	}
+
+	s.audit(ctx, user, "auth.login_link", ipAddress)
+	response, err := s.authService.finishLogin(ctx, user, ipAddress, userAgent)
+	return user, response, err
+}
+
+func (s *LoginLinkService) audit(ctx context.Context, user *models.User, action, ipAddress string) {
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		IPAddress:    &ipAddress,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     map[string]interface{}{"email": user.Email},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/utils"
+)
+
+// NewDeviceAlertService emails users when they sign in from a device and IP address none of their
+// sessions came from before, so they notice sign-ins that are not theirs. Orgs turn the alerts off
+// with "new_device_alerts": false in their settings.
+type NewDeviceAlertService struct {
+	tokenRepo    *repositories.RefreshTokenRepository
+	orgRepo      *repositories.OrganizationRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewNewDeviceAlertService(tokenRepo *repositories.RefreshTokenRepository, orgRepo *repositories.OrganizationRepository, auditLogRepo *repositories.AuditLogRepository) *NewDeviceAlertService {
+	return &NewDeviceAlertService{
+		tokenRepo:    tokenRepo,
+		orgRepo:      orgRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// userAgentVersions matches the version numbers in a user agent, which change with every browser
+// and OS update
+var userAgentVersions = regexp.MustCompile(`[0-9][0-9._]*`)
+
+// deviceFingerprint identifies the device a user agent comes from: the hash of the user agent
+// without its version numbers, so updates do not make a device new
+func deviceFingerprint(userAgent string) string {
+	normalized := strings.ToLower(userAgentVersions.ReplaceAllString(userAgent, ""))
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(normalized), " ")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Check alerts the user when neither the fingerprint nor the IP address is among those of their
+// sessions. It runs before the new session is stored; a user's first sign-in is not alerted.
+func (s *NewDeviceAlertService) Check(ctx context.Context, user *models.User, fingerprint, ipAddress, userAgent string) {
+	if !s.enabled(ctx, user) {
+		return
+	}
+	hasSessions, known, err := s.tokenRepo.KnownDevice(ctx, user.ID, fingerprint, ipAddress)
+	if err != nil {
+		log.Printf("Warning: Failed to check the sign-in device of user %s: %v", user.ID, err)
+		return
+	}
+	if !hasSessions || known {
+		return
+	}
+
+	signedInAt := time.Now()
+	email := user.Email
+	go func() {
+		if err := utils.SendNewDeviceEmail(email, ipAddress, userAgent, signedInAt); err != nil {
+			log.Printf("NewDeviceAlert: Failed to send alert to %s: %v", email, err)
+		}
+	}()
+
+	s.audit(ctx, user, fingerprint, ipAddress, userAgent)
+}
+
+// enabled reports whether the user's org wants new-device alerts; users without an org get them
+func (s *NewDeviceAlertService) enabled(ctx context.Context, user *models.User) bool {
+	if user.OrgID == nil {
+		return true
+	}
+	org, err := s.orgRepo.GetByID(ctx, *user.OrgID)
+	if err != nil {
+		log.Printf("Warning: Failed to load org %s for its new-device alert setting: %v", *user.OrgID, err)
+		return true
+	}
+	enabled, ok := org.Settings["new_device_alerts"].(bool)
+	return !ok || enabled
+}
+
+func (s *NewDeviceAlertService) audit(ctx context.Context, user *models.User, fingerprint, ipAddress, userAgent string) {
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		IPAddress:    &ipAddress,
+		UserAgent:    &userAgent,
+		Action:       "auth.new_device_login",
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     map[string]interface{}{"fingerprint": fingerprint},
+	}); err != nil {
+		log.Printf("Warning: Failed to record auth.new_device_login for user %s: %v", user.ID, err)
+	}
+}
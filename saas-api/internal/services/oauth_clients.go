@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/policy"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClientSecretPrefix starts every client secret, so leaked secrets are easy to recognise
+const OAuthClientSecretPrefix = "cs_"
+
+// Token endpoint errors; their codes are the RFC 6749 error codes the handler returns
+var (
+	ErrOAuthInvalidRequest       = errors.NewError("invalid_request", "grant_type, client_id and client_secret are required, and the client must authenticate one way only", http.StatusBadRequest)
+	ErrOAuthUnsupportedGrantType = errors.NewError("unsupported_grant_type", "Only the client_credentials grant is supported", http.StatusBadRequest)
+	ErrOAuthInvalidClient        = errors.NewError("invalid_client", "Unknown or revoked client, or wrong client secret", http.StatusUnauthorized)
+	ErrOAuthInvalidScope         = errors.NewError("invalid_scope", "The client may not request one of the scopes", http.StatusBadRequest)
+)
+
+// OAuthClientService registers OAuth2 confidential clients and issues them access tokens with the
+// client_credentials grant. A client acts as one user of its org, usually a service account, within
+// its scopes, like an API key; the difference is that it trades its secret for short-lived bearer
+// tokens. The secret is shown once, when the client is created; only its SHA-256 hash is stored.
+type OAuthClientService struct {
+	repo         *repositories.OAuthClientRepository
+	userRepo     *repositories.UserRepository
+	auditLogRepo *repositories.AuditLogRepository
+	tokenService *auth.TokenService
+	config       config.OAuthConfig
+}
+
+func NewOAuthClientService(repo *repositories.OAuthClientRepository, userRepo *repositories.UserRepository, auditLogRepo *repositories.AuditLogRepository, tokenService *auth.TokenService, cfg config.OAuthConfig) *OAuthClientService {
+	return &OAuthClientService{
+		repo:         repo,
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+		tokenService: tokenService,
+		config:       cfg,
+	}
+}
+
+func (s *OAuthClientService) List(ctx context.Context, orgID uuid.UUID) ([]*models.OAuthClient, error) {
+	return s.repo.List(ctx, orgID)
+}
+
+func (s *OAuthClientService) Get(ctx context.Context, orgID, id uuid.UUID) (*models.OAuthClient, error) {
+	return s.repo.GetByID(ctx, orgID, id)
+}
+
+// Create registers a client for the org. Its tokens act as req.UserID (or the caller), who must be
+// an active member of the org and already hold every requested scope. As with API keys, only org
+// admins and super admins may name another user, and the caller must hold the scopes too.
+func (s *OAuthClientService) Create(ctx context.Context, orgID uuid.UUID, req models.CreateOAuthClientRequest, actorID uuid.UUID) (*models.CreateOAuthClientResponse, error) {
+	principalID := actorID
+	if req.UserID != nil {
+		principalID = *req.UserID
+	}
+	principal, err := s.userRepo.GetByID(ctx, principalID)
+	if err == errors.ErrNotFound {
+		return nil, errors.NewError("VALIDATION_ERROR", "User not found", http.StatusBadRequest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if principal.OrgID == nil || *principal.OrgID != orgID {
+		return nil, errors.NewError("VALIDATION_ERROR", "OAuth clients can only act as a user of the organization", http.StatusBadRequest)
+	}
+	if principal.Status != "active" {
+		return nil, errors.NewError("VALIDATION_ERROR", "OAuth clients can only act as an active user", http.StatusBadRequest)
+	}
+
+	scopes, err := checkScopes(ctx, s.userRepo, principal.ID, req.Scopes, "client's")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkActorGrants(ctx, s.userRepo, orgID, actorID, principal.ID, scopes, "OAuth clients"); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate client secret", errors.ErrInternalServer.Status)
+	}
+	plaintext := OAuthClientSecretPrefix + strings.TrimRight(secret, "=")
+
+	client := &models.OAuthClient{
+		OrgID:        orgID,
+		UserID:       principal.ID,
+		Name:         req.Name,
+		SecretPrefix: plaintext[:len(OAuthClientSecretPrefix)+8],
+		SecretHash:   utils.HashToken(plaintext),
+		Scopes:       scopes,
+		CreatedBy:    &actorID,
+	}
+	if err := s.repo.Create(ctx, client); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, client, "oauth_client.created", &actorID)
+	return &models.CreateOAuthClientResponse{OAuthClient: client, ClientSecret: plaintext}, nil
+}
+
+// Revoke stops a client from getting tokens; tokens it already has stay valid until they expire
+func (s *OAuthClientService) Revoke(ctx context.Context, orgID, id uuid.UUID, actorID *uuid.UUID) (*models.OAuthClient, error) {
+	client, err := s.repo.Revoke(ctx, orgID, id, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, client, "oauth_client.revoked", actorID)
+	return client, nil
+}
+
+// IssueToken serves the client_credentials grant. clientID and clientSecret come from the form or
+// from HTTP Basic authentication (basicAuth), never both.
+func (s *OAuthClientService) IssueToken(ctx context.Context, req models.OAuthTokenRequest, basicAuth bool, clientID, clientSecret, ipAddress string) (*models.OAuthTokenResponse, error) {
+	if req.GrantType == "" {
+		return nil, ErrOAuthInvalidRequest
+	}
+	if req.GrantType != "client_credentials" {
+		return nil, ErrOAuthUnsupportedGrantType
+	}
+	if basicAuth && (req.ClientID != "" || req.ClientSecret != "") {
+		return nil, ErrOAuthInvalidRequest
+	}
+	if !basicAuth {
+		clientID, clientSecret = req.ClientID, req.ClientSecret
+	}
+	if clientID == "" || clientSecret == "" {
+		return nil, ErrOAuthInvalidRequest
+	}
+
+	client, user, err := s.authenticate(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := client.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		for _, scope := range requested {
+			resource, action, ok := strings.Cut(scope, ":")
+			if !ok || action == "" || !policy.ScopesAllow(client.Scopes, resource, action) {
+				return nil, ErrOAuthInvalidScope
+			}
+		}
+		scopes = slices.Compact(slices.Sorted(slices.Values(requested)))
+	}
+
+	ttl := time.Duration(s.config.TokenTTL) * time.Minute
+	accessToken, err := s.tokenService.GenerateClientToken(user, client.ID, scopes, ttl)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate access token", errors.ErrInternalServer.Status)
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, client.ID, ipAddress); err != nil {
+		log.Printf("Warning: Failed to update last_used_at for OAuth client %s: %v", client.ID, err)
+	}
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// authenticate resolves a client and the user it acts as. Unknown and revoked clients, wrong
+// secrets, and clients whose user is no longer an active member of the client's org, all return
+// ErrOAuthInvalidClient.
+func (s *OAuthClientService) authenticate(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, *models.User, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, nil, ErrOAuthInvalidClient
+	}
+	client, err := s.repo.Get(ctx, id)
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrOAuthInvalidClient
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(utils.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, nil, ErrOAuthInvalidClient
+	}
+
+	user, err := s.userRepo.GetByID(ctx, client.UserID)
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrOAuthInvalidClient
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Status != "active" || user.OrgID == nil || *user.OrgID != client.OrgID {
+		log.Printf("OAuth client %s rejected: user %s is %s in org %v", client.ID, user.ID, user.Status, user.OrgID)
+		return nil, nil, ErrOAuthInvalidClient
+	}
+	return client, user, nil
+}
+
+func (s *OAuthClientService) audit(ctx context.Context, client *models.OAuthClient, action string, actorID *uuid.UUID) {
+	resourceType := "oauth_client"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       actorID,
+		OrgID:        &client.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &client.ID,
+		Status:       "success",
+		Metadata: map[string]interface{}{
+			"name":          client.Name,
+			"secret_prefix": client.SecretPrefix,
+			"user_id":       client.UserID.String(),
+			"scopes":        client.Scopes,
+		},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for OAuth client %s: %v", action, client.ID, err)
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// OIDCStateTTL is how long a user has to sign in at the identity provider
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrOIDCDisabled      = errors.NewError("SERVICE_UNAVAILABLE", "Single sign-on is not configured (OIDC_REDIRECT_URL is not set)", http.StatusServiceUnavailable)
+	ErrOIDCNotConfigured = errors.NewError("NOT_FOUND", "Single sign-on is not enabled for this organization", http.StatusNotFound)
+	ErrOIDCInvalidState  = errors.NewError("OIDC_INVALID_STATE", "The sign-in session is invalid or has expired. Please sign in again.", http.StatusBadRequest)
+)
+
+// OIDCService signs users in through their organization's OpenID Connect identity provider (see
+// ssoLogin for how users are matched, provisioned and given roles)
+type OIDCService struct {
+	*ssoLogin
+	client       *auth.OIDCClient
+	providerRepo *repositories.OIDCProviderRepository
+	orgRepo      *repositories.OrganizationRepository
+	secrets      SecretResolver
+	config       config.OIDCConfig
+}
+
+func NewOIDCService(
+	authService *AuthService,
+	providerRepo *repositories.OIDCProviderRepository,
+	identityRepo *repositories.UserIdentityRepository,
+	orgRepo *repositories.OrganizationRepository,
+	roleRepo *repositories.RoleRepository,
+	auditLogRepo *repositories.AuditLogRepository,
+	secrets SecretResolver,
+	cfg config.OIDCConfig,
+) *OIDCService {
+	return &OIDCService{
+		ssoLogin: &ssoLogin{
+			authService:  authService,
+			identityRepo: identityRepo,
+			roleRepo:     roleRepo,
+			auditLogRepo: auditLogRepo,
+		},
+		client:       auth.NewOIDCClient(cfg.RedirectURL),
+		providerRepo: providerRepo,
+		orgRepo:      orgRepo,
+		secrets:      secrets,
+		config:       cfg,
+	}
+}
+
+// Enabled reports whether OIDC_REDIRECT_URL is configured
+func (s *OIDCService) Enabled() bool {
+	return s.config.RedirectURL != ""
+}
+
+// FrontendURL is where the browser is sent once signed in
+func (s *OIDCService) FrontendURL() string {
+	return s.config.FrontendURL
+}
+
+func (s *OIDCService) GetProvider(ctx context.Context, orgID uuid.UUID) (*models.OrgOIDCProvider, error) {
+	return s.providerRepo.GetByOrg(ctx, orgID)
+}
+
+// SetProvider creates or replaces the org's identity provider. The issuer's discovery document, the
+// client secret and the mapped roles are checked first, so a broken configuration is rejected here
+// rather than at the next sign-in.
+func (s *OIDCService) SetProvider(ctx context.Context, orgID uuid.UUID, req models.SetOrgOIDCProviderRequest, actorID *uuid.UUID) (*models.OrgOIDCProvider, error) {
+	p := &models.OrgOIDCProvider{
+		OrgID:            orgID,
+		IssuerURL:        strings.TrimSuffix(req.IssuerURL, "/"),
+		ClientID:         req.ClientID,
+		ClientSecretName: req.ClientSecretName,
+		Scopes:           req.Scopes,
+		AllowedDomains:   normalizeDomains(req.AllowedDomains),
+		AutoProvision:    req.AutoProvision == nil || *req.AutoProvision,
+		DefaultOrgRole:   "user",
+		RoleClaim:        "groups",
+		RoleMappings:     req.RoleMappings,
+		Enabled:          req.Enabled == nil || *req.Enabled,
+		UpdatedBy:        actorID,
+	}
+	if len(p.Scopes) == 0 {
+		p.Scopes = []string{"openid", "email", "profile"}
+	} else if !slices.Contains(p.Scopes, "openid") {
+		p.Scopes = append([]string{"openid"}, p.Scopes...)
+	}
+	if req.DefaultOrgRole != nil {
+		p.DefaultOrgRole = *req.DefaultOrgRole
+	}
+	if req.RoleClaim != nil && *req.RoleClaim != "" {
+		p.RoleClaim = *req.RoleClaim
+	}
+	if p.RoleMappings == nil {
+		p.RoleMappings = []models.SSORoleMapping{}
+	}
+
+	if _, err := s.client.Discover(ctx, p.IssuerURL); err != nil {
+		return nil, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest)
+	}
+	if p.ClientSecretName != nil {
+		if _, err := s.secrets.Resolve(ctx, orgID, *p.ClientSecretName); err != nil {
+			return nil, errors.WrapError(err, "VALIDATION_ERROR", "client_secret_name must name an existing org secret", http.StatusBadRequest)
+		}
+	}
+	if err := s.checkGrantAuthority(ctx, orgID, p.RoleMappings, p.DefaultOrgRole, actorID); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleMappings(ctx, orgID, p.RoleMappings); err != nil {
+		return nil, err
+	}
+
+	if err := s.providerRepo.Upsert(ctx, p); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &models.AuditLog{
+		UserID:   actorID,
+		OrgID:    &orgID,
+		Action:   "oidc_provider.updated",
+		Metadata: map[string]interface{}{"issuer_url": p.IssuerURL, "client_id": p.ClientID, "enabled": p.Enabled},
+	}, "oidc_provider", &p.ID)
+	return p, nil
+}
+
+// DeleteProvider turns single sign-on off for the org and unlinks the IdP accounts; users keep their
+// other ways of signing in
+func (s *OIDCService) DeleteProvider(ctx context.Context, orgID uuid.UUID, actorID *uuid.UUID) error {
+	p, err := s.providerRepo.Delete(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, &models.AuditLog{
+		UserID:   actorID,
+		OrgID:    &orgID,
+		Action:   "oidc_provider.deleted",
+		Metadata: map[string]interface{}{"issuer_url": p.IssuerURL, "client_id": p.ClientID},
+	}, "oidc_provider", &p.ID)
+	return nil
+}
+
+// Start begins a sign-in with the identity provider of the org with orgSlug. It returns the IdP's
+// authorization URL and the signed state the callback needs back, to be kept in a cookie.
+func (s *OIDCService) Start(ctx context.Context, orgSlug, returnTo string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	org, err := s.orgRepo.GetBySlug(ctx, orgSlug)
+	if err != nil {
+		return "", "", ErrOIDCNotConfigured
+	}
+	p, settings, err := s.provider(ctx, org.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	st := auth.NewOIDCState(p.OrgID, returnTo)
+	authURL, err := s.client.AuthCodeURL(ctx, settings, st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		log.Printf("OIDC: cannot start sign-in for org %s: %v", org.ID, err)
+		return "", "", errors.NewError("OIDC_PROVIDER_UNAVAILABLE", "The identity provider is unavailable", http.StatusBadGateway)
+	}
+	cookie, err := s.authService.tokenService.GenerateOIDCState(st, OIDCStateTTL)
+	if err != nil {
+		return "", "", errors.WrapError(err, "INTERNAL_ERROR", "Failed to start sign-in", errors.ErrInternalServer.Status)
+	}
+
+	return authURL, cookie, nil
+}
+
+// Callback completes a sign-in: it checks state against the cookie Start issued, redeems code and
+// signs the user in. It also returns the return_to path given to Start.
+func (s *OIDCService) Callback(ctx context.Context, stateCookie, state, code, ipAddress, userAgent string) (*models.LoginResponse, string, error) {
+	if !s.Enabled() {
+		return nil, "", ErrOIDCDisabled
+	}
+	st, err := s.authService.tokenService.ValidateOIDCState(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(st.State)) != 1 {
+		return nil, "", ErrOIDCInvalidState
+	}
+	p, settings, err := s.provider(ctx, st.OrgID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	identity, err := s.client.Exchange(ctx, settings, code, st.Verifier, st.Nonce)
+	if err != nil {
+		log.Printf("OIDC: sign-in for org %s failed: %v", p.OrgID, err)
+		return nil, "", errors.NewError("OIDC_LOGIN_FAILED", "The identity provider did not confirm the sign-in", http.StatusUnauthorized)
+	}
+
+	response, err := s.complete(ctx, ssoProviderFromOIDC(p), ssoIdentityFromOIDC(p, identity), ipAddress, userAgent)
+	if err != nil {
+		return nil, "", err
+	}
+	return response, st.ReturnTo, nil
+}
+
+// provider returns the org's enabled provider and the settings the OIDC flow needs
+func (s *OIDCService) provider(ctx context.Context, orgID uuid.UUID) (*models.OrgOIDCProvider, auth.OIDCProviderSettings, error) {
+	p, err := s.providerRepo.GetByOrg(ctx, orgID)
+	if err != nil || !p.Enabled {
+		return nil, auth.OIDCProviderSettings{}, ErrOIDCNotConfigured
+	}
+
+	settings := auth.OIDCProviderSettings{IssuerURL: p.IssuerURL, ClientID: p.ClientID, Scopes: p.Scopes}
+	if p.ClientSecretName != nil {
+		secret, err := s.secrets.Resolve(ctx, orgID, *p.ClientSecretName)
+		if err != nil {
+			log.Printf("OIDC: cannot resolve client secret %q for org %s: %v", *p.ClientSecretName, orgID, err)
+			return nil, auth.OIDCProviderSettings{}, errors.NewError("OIDC_PROVIDER_UNAVAILABLE", "Single sign-on is misconfigured for this organization", http.StatusBadGateway)
+		}
+		settings.ClientSecret = secret
+	}
+	return p, settings, nil
+}
+
+func ssoProviderFromOIDC(p *models.OrgOIDCProvider) *ssoProvider {
+	return &ssoProvider{
+		Kind:           models.IdentityProviderOIDC,
+		ID:             p.ID,
+		OrgID:          p.OrgID,
+		Issuer:         p.IssuerURL,
+		AutoProvision:  p.AutoProvision,
+		AllowedDomains: p.AllowedDomains,
+		DefaultOrgRole: p.DefaultOrgRole,
+		RoleMappings:   p.RoleMappings,
+	}
+}
+
+func ssoIdentityFromOIDC(p *models.OrgOIDCProvider, identity *auth.OIDCIdentity) *ssoIdentity {
+	id := &ssoIdentity{
+		Subject:    identity.Subject,
+		Email:      identity.Email,
+		GivenName:  identity.GivenName,
+		FamilyName: identity.FamilyName,
+		RoleValues: claimValues(identity.Claims[p.RoleClaim]),
+	}
+	if _, sent := identity.Claims["email_verified"]; sent {
+		id.EmailVerified = &identity.EmailVerified
+	}
+	return id
+}
+
+// claimValues reads a claim holding a string or a list of strings
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
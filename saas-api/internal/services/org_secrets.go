@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/envelope"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSecretsDisabled   = errors.NewError("SERVICE_UNAVAILABLE", "Org secrets are not configured (SECRETS_ENCRYPTION_KEYS is not set)", http.StatusServiceUnavailable)
+	ErrInvalidSecretName = errors.NewError("VALIDATION_ERROR", "Secret names must be lowercase letters, digits, '_', '-' or '.', starting with a letter or digit", http.StatusBadRequest)
+)
+
+var secretNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// SecretResolver returns the plaintext of an org secret, for server-side consumers (the document
+// pipeline, the LibreChat proxy via the internal resolve endpoint). Returns errors.ErrNotFound when
+// the org has no secret with that name.
+type SecretResolver interface {
+	Resolve(ctx context.Context, orgID uuid.UUID, name string) (string, error)
+}
+
+// OrgSecretService manages per-org integration credentials. Values are envelope-encrypted before
+// they reach the repository and are write-only over the API: only Resolve ever decrypts them.
+type OrgSecretService struct {
+	repo         *repositories.OrgSecretRepository
+	auditLogRepo *repositories.AuditLogRepository
+	keyring      *envelope.Keyring // nil when SECRETS_ENCRYPTION_KEYS is unset
+}
+
+func NewOrgSecretService(repo *repositories.OrgSecretRepository, auditLogRepo *repositories.AuditLogRepository, keyring *envelope.Keyring) *OrgSecretService {
+	return &OrgSecretService{
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+		keyring:      keyring,
+	}
+}
+
+// Enabled reports whether encryption keys are configured
+func (s *OrgSecretService) Enabled() bool {
+	return s.keyring != nil
+}
+
+func (s *OrgSecretService) List(ctx context.Context, orgID uuid.UUID) ([]*models.OrgSecret, error) {
+	if !s.Enabled() {
+		return nil, ErrSecretsDisabled
+	}
+	return s.repo.List(ctx, orgID)
+}
+
+func (s *OrgSecretService) Create(ctx context.Context, orgID uuid.UUID, req models.CreateOrgSecretRequest, actorID *uuid.UUID) (*models.OrgSecret, error) {
+	if !s.Enabled() {
+		return nil, ErrSecretsDisabled
+	}
+	if !secretNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidSecretName
+	}
+
+	sealed, err := s.keyring.Seal([]byte(req.Value), secretAAD(orgID, req.Name))
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to encrypt secret", errors.ErrInternalServer.Status)
+	}
+
+	secret := &models.OrgSecret{
+		OrgID:       orgID,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   actorID,
+	}
+	if err := s.repo.Create(ctx, secret, sealed); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, secret, "org_secret.created", actorID)
+	return secret, nil
+}
+
+// Rotate replaces a secret's value; consumers pick up the new value on their next Resolve
+func (s *OrgSecretService) Rotate(ctx context.Context, orgID uuid.UUID, name, value string, actorID *uuid.UUID) (*models.OrgSecret, error) {
+	if !s.Enabled() {
+		return nil, ErrSecretsDisabled
+	}
+
+	sealed, err := s.keyring.Seal([]byte(value), secretAAD(orgID, name))
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to encrypt secret", errors.ErrInternalServer.Status)
+	}
+
+	secret := &models.OrgSecret{OrgID: orgID, Name: name, UpdatedBy: actorID}
+	if err := s.repo.Rotate(ctx, secret, sealed); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, secret, "org_secret.rotated", actorID)
+	return secret, nil
+}
+
+func (s *OrgSecretService) Delete(ctx context.Context, orgID uuid.UUID, name string, actorID *uuid.UUID) error {
+	if !s.Enabled() {
+		return ErrSecretsDisabled
+	}
+	secret, _, err := s.repo.GetSealed(ctx, orgID, name)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, orgID, name); err != nil {
+		return err
+	}
+
+	s.audit(ctx, secret, "org_secret.deleted", actorID)
+	return nil
+}
+
+// Resolve decrypts a secret. Values sealed under a retired key-encryption key are re-wrapped under
+// the current one as they are read, so old keys can be dropped once every secret has been used.
+func (s *OrgSecretService) Resolve(ctx context.Context, orgID uuid.UUID, name string) (string, error) {
+	if !s.Enabled() {
+		return "", ErrSecretsDisabled
+	}
+
+	secret, sealed, err := s.repo.GetSealed(ctx, orgID, name)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := s.keyring.Open(sealed, secretAAD(orgID, name))
+	if err != nil {
+		log.Printf("Failed to decrypt org secret %s for org %s (key %s): %v", name, orgID, sealed.KeyID, err)
+		return "", errors.NewError("INTERNAL_ERROR", "Failed to decrypt secret", errors.ErrInternalServer.Status)
+	}
+
+	if sealed.KeyID != s.keyring.CurrentKeyID() {
+		if rewrapped, err := s.keyring.Rewrap(sealed); err == nil {
+			if err := s.repo.Rewrap(ctx, secret.ID, rewrapped); err != nil {
+				log.Printf("Warning: Failed to rewrap org secret %s: %v", secret.ID, err)
+			}
+		}
+	}
+	if err := s.repo.TouchLastUsed(ctx, secret.ID); err != nil {
+		log.Printf("Warning: Failed to update last_used_at for org secret %s: %v", secret.ID, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// secretAAD binds a ciphertext to its org and name, so it cannot be moved to another row
+func secretAAD(orgID uuid.UUID, name string) []byte {
+	return []byte(orgID.String() + "/" + name)
+}
+
+// audit records the change; the metadata carries the secret's name and version, never its value
+func (s *OrgSecretService) audit(ctx context.Context, secret *models.OrgSecret, action string, actorID *uuid.UUID) {
+	resourceType := "org_secret"
+	metadata := map[string]interface{}{
+		"name":    secret.Name,
+		"version": secret.Version,
+	}
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       actorID,
+		OrgID:        &secret.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &secret.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for org %s: %v", action, secret.OrgID, err)
+	}
+}
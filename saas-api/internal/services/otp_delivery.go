@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/sms"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOTPRateLimited    = errors.NewError("RATE_LIMITED", "Too many codes requested. Please try again later.", http.StatusTooManyRequests)
+	ErrSMSUnavailable    = errors.NewError("SERVICE_UNAVAILABLE", "SMS delivery is not configured (OTP_SMS_PROVIDER is not set)", http.StatusServiceUnavailable)
+	ErrNoPhoneForOTP     = errors.NewError("VALIDATION_ERROR", "Set a phone number in international format (+14155550123) to receive codes by SMS", http.StatusBadRequest)
+	ErrInvalidOTPChannel = errors.NewError("VALIDATION_ERROR", "Channel must be email or sms", http.StatusBadRequest)
+)
+
+// OTPSender delivers a sign-in code to a user on one channel
+type OTPSender interface {
+	Send(ctx context.Context, user *models.User, code string) error
+}
+
+// emailOTPSender emails the code
+type emailOTPSender struct{}
+
+func (emailOTPSender) Send(ctx context.Context, user *models.User, code string) error {
+	return utils.SendOTPEmail(user.Email, code)
+}
+
+// smsOTPSender texts the code to the user's phone
+type smsOTPSender struct {
+	sender sms.Sender
+}
+
+func (s smsOTPSender) Send(ctx context.Context, user *models.User, code string) error {
+	phone, ok := otpPhone(user)
+	if !ok {
+		return ErrNoPhoneForOTP
+	}
+	body := fmt.Sprintf("%s is your sign-in code. It expires in %d minutes. Do not share it with anyone.", code, utils.OTPExpiryMinutes)
+	return s.sender.Send(ctx, phone, body)
+}
+
+// OTPDelivery picks the channel for a user's sign-in code and sends it. The org's otp_channel setting
+// decides when set, otherwise the user's preference; codes go by email when SMS is not configured,
+// the user has no usable phone number, texting fails or the SMS limit is reached. Each channel has
+// its own hourly limit per account.
+type OTPDelivery struct {
+	userRepo *repositories.UserRepository
+	orgRepo  *repositories.OrganizationRepository
+	repo     *repositories.OTPDeliveryRepository
+	senders  map[string]OTPSender
+	limits   map[string]int
+}
+
+// NewOTPDelivery sends by email, and by SMS through smsSender when it is not nil
+func NewOTPDelivery(userRepo *repositories.UserRepository, orgRepo *repositories.OrganizationRepository, repo *repositories.OTPDeliveryRepository, smsSender sms.Sender, cfg config.OTPConfig) *OTPDelivery {
+	d := &OTPDelivery{
+		userRepo: userRepo,
+		orgRepo:  orgRepo,
+		repo:     repo,
+		senders:  map[string]OTPSender{models.OTPChannelEmail: emailOTPSender{}},
+		limits: map[string]int{
+			models.OTPChannelEmail: cfg.EmailMaxPerHour,
+			models.OTPChannelSMS:   cfg.SMSMaxPerHour,
+		},
+	}
+	if smsSender != nil {
+		d.senders[models.OTPChannelSMS] = smsOTPSender{sender: smsSender}
+	}
+	return d
+}
+
+// SMSEnabled reports whether codes can be texted
+func (d *OTPDelivery) SMSEnabled() bool {
+	_, ok := d.senders[models.OTPChannelSMS]
+	return ok
+}
+
+// Channels returns the channels to try for the user, in order, leaving out those whose hourly limit
+// is reached. It returns ErrOTPRateLimited when none is left.
+func (d *OTPDelivery) Channels(ctx context.Context, user *models.User) ([]string, error) {
+	var channels []string
+	if d.preferred(ctx, user) == models.OTPChannelSMS {
+		if _, ok := otpPhone(user); ok && d.SMSEnabled() {
+			channels = append(channels, models.OTPChannelSMS)
+		}
+	}
+	channels = append(channels, models.OTPChannelEmail)
+
+	since := time.Now().Add(-time.Hour)
+	allowed := channels[:0]
+	for _, channel := range channels {
+		if limit := d.limits[channel]; limit > 0 {
+			sent, err := d.repo.CountSince(ctx, user.ID, channel, since)
+			if err != nil {
+				return nil, err
+			}
+			if sent >= limit {
+				log.Printf("OTP %s limit reached for user %s", channel, user.ID)
+				continue
+			}
+		}
+		allowed = append(allowed, channel)
+	}
+	if len(allowed) == 0 {
+		return nil, ErrOTPRateLimited
+	}
+	return allowed, nil
+}
+
+// Send tries channels in order until one delivers the code, and returns that channel. The error is
+// the last channel's when none does.
+func (d *OTPDelivery) Send(ctx context.Context, user *models.User, channels []string, code string) (string, error) {
+	var err error
+	for _, channel := range channels {
+		if err = d.senders[channel].Send(ctx, user, code); err != nil {
+			log.Printf("Warning: Failed to send OTP by %s to user %s: %v", channel, user.ID, err)
+			continue
+		}
+		if err := d.repo.Record(ctx, user.ID, channel); err != nil {
+			log.Printf("Warning: Failed to record OTP delivery for user %s: %v", user.ID, err)
+		}
+		return channel, nil
+	}
+	return "", err
+}
+
+// SetPreference stores the channel the user wants codes on. SMS needs a provider and a phone number.
+func (d *OTPDelivery) SetPreference(ctx context.Context, userID uuid.UUID, channel string) error {
+	switch channel {
+	case models.OTPChannelEmail:
+	case models.OTPChannelSMS:
+		if !d.SMSEnabled() {
+			return ErrSMSUnavailable
+		}
+		user, err := d.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if _, ok := otpPhone(user); !ok {
+			return ErrNoPhoneForOTP
+		}
+	default:
+		return ErrInvalidOTPChannel
+	}
+	return d.userRepo.SetOTPChannel(ctx, userID, channel)
+}
+
+// preferred is the org's otp_channel setting when set, otherwise the user's preference
+func (d *OTPDelivery) preferred(ctx context.Context, user *models.User) string {
+	if user.OrgID != nil {
+		org, err := d.orgRepo.GetByID(ctx, *user.OrgID)
+		if err != nil {
+			log.Printf("Warning: Failed to load org %s for its OTP channel: %v", *user.OrgID, err)
+		} else if channel, _ := org.Settings["otp_channel"].(string); channel == models.OTPChannelEmail || channel == models.OTPChannelSMS {
+			return channel
+		}
+	}
+	channel, _ := user.Metadata[models.UserMetadataOTPChannel].(string)
+	return channel
+}
+
+// otpPhone returns the user's phone number in E.164 format, if they have a usable one
+func otpPhone(user *models.User) (string, bool) {
+	if user.Phone == nil {
+		return "", false
+	}
+	return sms.NormalizePhone(*user.Phone)
+}
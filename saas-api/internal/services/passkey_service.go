@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// PasskeyStateTTL is how long a user has to answer the browser's passkey prompt
+const PasskeyStateTTL = 5 * time.Minute
+
+var (
+	ErrPasskeysDisabled      = errors.NewError("SERVICE_UNAVAILABLE", "Passkeys are not configured (WEBAUTHN_RP_ID is not set)", http.StatusServiceUnavailable)
+	ErrPasskeyInvalidSession = errors.NewError("PASSKEY_INVALID_SESSION", "The passkey request is invalid or has expired. Please try again.", http.StatusBadRequest)
+	ErrPasskeyRejected       = errors.NewError("PASSKEY_REJECTED", "The passkey could not be verified", http.StatusUnauthorized)
+)
+
+// PasskeyService registers WebAuthn credentials and signs users in with them. Passkeys are
+// discoverable credentials that require user verification, so a sign-in needs no email, password or
+// second factor: the device proves possession and the user's PIN or biometric. The ceremony state
+// travels in a signed session token (see auth.PasskeyState).
+type PasskeyService struct {
+	authService  *AuthService
+	repo         *repositories.PasskeyRepository
+	auditLogRepo *repositories.AuditLogRepository
+	webAuthn     *webauthn.WebAuthn // nil when WEBAUTHN_RP_ID is unset
+}
+
+func NewPasskeyService(
+	authService *AuthService,
+	repo *repositories.PasskeyRepository,
+	auditLogRepo *repositories.AuditLogRepository,
+	cfg config.WebAuthnConfig,
+) (*PasskeyService, error) {
+	s := &PasskeyService{
+		authService:  authService,
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+	}
+	if cfg.RPID == "" {
+		return s, nil
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: PasskeyStateTTL, TimeoutUVD: PasskeyStateTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: PasskeyStateTTL, TimeoutUVD: PasskeyStateTTL},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.webAuthn = w
+	return s, nil
+}
+
+// Enabled reports whether WEBAUTHN_RP_ID is configured
+func (s *PasskeyService) Enabled() bool {
+	return s.webAuthn != nil
+}
+
+func (s *PasskeyService) List(ctx context.Context, userID uuid.UUID) ([]*models.UserPasskey, error) {
+	if !s.Enabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// BeginRegistration starts adding a passkey for the user. Passkeys the user has are excluded, so the
+// same authenticator is not registered twice.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*models.PasskeyCeremonyResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to start passkey registration", errors.ErrInternalServer.Status)
+	}
+	return s.ceremony(auth.PasskeyPurposeRegister, creation, session)
+}
+
+// FinishRegistration verifies the browser's attestation and stores the passkey. An empty name
+// defaults to one derived from the browser.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID uuid.UUID, req models.FinishPasskeyRegistrationRequest, userAgent string) (*models.UserPasskey, error) {
+	if !s.Enabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	session, err := s.session(req.SessionToken, auth.PasskeyPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return nil, errors.NewError("VALIDATION_ERROR", "Invalid passkey credential: "+protocolErrorDetails(err), http.StatusBadRequest)
+	}
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		log.Printf("Passkey registration failed for %s: %s", user.Email, protocolErrorDetails(err))
+		return nil, ErrPasskeyRejected
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to save passkey", errors.ErrInternalServer.Status)
+	}
+	passkey := &models.UserPasskey{
+		UserID:         user.ID,
+		CredentialID:   credential.ID,
+		Credential:     record,
+		Name:           strings.TrimSpace(req.Name),
+		Transports:     []string{},
+		BackupEligible: credential.Flags.BackupEligible,
+	}
+	if passkey.Name == "" {
+		passkey.Name = defaultPasskeyName(userAgent)
+	}
+	if aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID); err == nil && aaguid != uuid.Nil {
+		passkey.AAGUID = &aaguid
+	}
+	for _, t := range credential.Transport {
+		passkey.Transports = append(passkey.Transports, string(t))
+	}
+	if credential.Authenticator.Attachment != "" {
+		attachment := string(credential.Authenticator.Attachment)
+		passkey.Attachment = &attachment
+	}
+	if userAgent != "" {
+		passkey.UserAgent = &userAgent
+	}
+	if err := s.repo.Create(ctx, passkey); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, user.User, "user.passkey_registered", passkey)
+	return passkey, nil
+}
+
+func (s *PasskeyService) Rename(ctx context.Context, userID, passkeyID uuid.UUID, name string) (*models.UserPasskey, error) {
+	if !s.Enabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	return s.repo.Rename(ctx, userID, passkeyID, strings.TrimSpace(name))
+}
+
+func (s *PasskeyService) Delete(ctx context.Context, userID, passkeyID uuid.UUID) error {
+	if !s.Enabled() {
+		return ErrPasskeysDisabled
+	}
+	passkey, err := s.repo.Delete(ctx, userID, passkeyID)
+	if err != nil {
+		return err
+	}
+
+	if user, err := s.authService.userRepo.GetByID(ctx, userID); err == nil {
+		s.audit(ctx, user, "user.passkey_deleted", passkey)
+	}
+	return nil
+}
+
+// BeginLogin starts a passkey sign-in. The browser offers the user the passkeys it has for this
+// site, so the request names no account and reveals nothing about which accounts exist.
+func (s *PasskeyService) BeginLogin() (*models.PasskeyCeremonyResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to start passkey sign-in", errors.ErrInternalServer.Status)
+	}
+	return s.ceremony(auth.PasskeyPurposeLogin, assertion, session)
+}
+
+// FinishLogin verifies the browser's assertion and signs its user in. The account rules of password
+// sign-in apply (status, lockout, enforced single sign-on), but no 2FA code is asked for.
+func (s *PasskeyService) FinishLogin(ctx context.Context, req models.FinishPasskeyLoginRequest, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	user, response, err := s.finishLogin(ctx, req, ipAddress, userAgent)
+	s.authService.recordLogin(ctx, models.LoginMethodPasskey, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// finishLogin is FinishLogin; it also returns the passkey's user, once known, for the login history
+func (s *PasskeyService) finishLogin(ctx context.Context, req models.FinishPasskeyLoginRequest, ipAddress, userAgent string) (*models.User, *models.LoginResponse, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrPasskeysDisabled
+	}
+	session, err := s.session(req.SessionToken, auth.PasskeyPurposeLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return nil, nil, errors.NewError("VALIDATION_ERROR", "Invalid passkey credential: "+protocolErrorDetails(err), http.StatusBadRequest)
+	}
+
+	var user *passkeyUser
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		user, err = s.loadUser(ctx, userID)
+		return user, err
+	}, *session, parsed)
+	if err != nil {
+		log.Printf("Passkey sign-in failed: %s", protocolErrorDetails(err))
+		// The user handle named an account whose passkey did not verify
+		if user != nil {
+			return user.User, nil, ErrPasskeyRejected
+		}
+		return nil, nil, ErrPasskeyRejected
+	}
+	if credential.Authenticator.CloneWarning {
+		log.Printf("Passkey sign-in refused - sign count of a passkey of %s went backwards (cloned authenticator?)", user.Email)
+		return user.User, nil, ErrPasskeyRejected
+	}
+
+	if err := checkLocked(user.User); err != nil {
+		return user.User, nil, err
+	}
+	if err := checkAccountStatus(user.User); err != nil {
+		return user.User, nil, err
+	}
+	if err := s.authService.checkSSOPolicy(ctx, user.User); err != nil {
+		return user.User, nil, err
+	}
+
+	passkey := user.passkey(credential.ID)
+	if record, err := json.Marshal(credential); err == nil && passkey != nil {
+		if err := s.repo.RecordUse(ctx, passkey.ID, record, ipAddress); err != nil {
+			log.Printf("Warning: Failed to record use of passkey %s: %v", passkey.ID, err)
+		}
+	}
+	_ = s.authService.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+
+	response, err := s.authService.issueTokens(ctx, user.User, ipAddress, userAgent)
+	if err != nil {
+		return user.User, nil, err
+	}
+	if passkey != nil {
+		s.audit(ctx, user.User, "auth.passkey_login", passkey)
+	}
+	return user.User, response, nil
+}
+
+// ceremony signs the session of a ceremony into the token the browser sends back with its result
+func (s *PasskeyService) ceremony(purpose string, options interface{}, session *webauthn.SessionData) (*models.PasskeyCeremonyResponse, error) {
+	token, err := s.authService.tokenService.GeneratePasskeyState(purpose, session, PasskeyStateTTL)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to sign passkey session", errors.ErrInternalServer.Status)
+	}
+	return &models.PasskeyCeremonyResponse{Options: options, SessionToken: token}, nil
+}
+
+func (s *PasskeyService) session(token, purpose string) (*webauthn.SessionData, error) {
+	st, err := s.authService.tokenService.ValidatePasskeyState(token)
+	if err != nil || st.Purpose != purpose {
+		return nil, ErrPasskeyInvalidSession
+	}
+	return &st.Session, nil
+}
+
+// passkeyUser is a user as the WebAuthn library sees it. The user handle is the user's ID, which is
+// how a discoverable sign-in finds the account.
+type passkeyUser struct {
+	*models.User
+	passkeys    []*models.UserPasskey
+	credentials []webauthn.Credential
+}
+
+func (s *PasskeyService) loadUser(ctx context.Context, userID uuid.UUID) (*passkeyUser, error) {
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &passkeyUser{User: user, passkeys: passkeys}
+	for _, p := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(p.Credential, &credential); err != nil {
+			log.Printf("Warning: Skipping unreadable passkey %s of user %s: %v", p.ID, userID, err)
+			continue
+		}
+		u.credentials = append(u.credentials, credential)
+	}
+	return u, nil
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	name := strings.TrimSpace(strings.Join([]string{deref(u.FirstName), deref(u.LastName)}, " "))
+	if name == "" {
+		return u.Email
+	}
+	return name
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *passkeyUser) passkey(credentialID []byte) *models.UserPasskey {
+	for _, p := range u.passkeys {
+		if string(p.CredentialID) == string(credentialID) {
+			return p
+		}
+	}
+	return nil
+}
+
+// defaultPasskeyName names a passkey after the platform it was created on
+func defaultPasskeyName(userAgent string) string {
+	for _, platform := range []struct{ match, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"}, {"Mac OS X", "Mac"},
+		{"Windows", "Windows"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, platform.match) {
+			return "Passkey on " + platform.name
+		}
+	}
+	return "Passkey"
+}
+
+// protocolErrorDetails is the reason the WebAuthn library gives for refusing a credential
+func protocolErrorDetails(err error) string {
+	perr, ok := err.(*protocol.Error)
+	if !ok {
+		return err.Error()
+	}
+	if perr.DevInfo != "" {
+		return perr.Details + ": " + perr.DevInfo
+	}
+	return perr.Details
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (s *PasskeyService) audit(ctx context.Context, user *models.User, action string, passkey *models.UserPasskey) {
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     map[string]interface{}{"email": user.Email, "passkey_id": passkey.ID, "passkey_name": passkey.Name},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPasswordResetDisabled = errors.NewError("SERVICE_UNAVAILABLE", "Password reset is not configured (PASSWORD_RESET_URL is not set)", http.StatusServiceUnavailable)
+	ErrInvalidResetToken     = errors.NewError("INVALID_TOKEN", "This password reset link is invalid, expired or already used", http.StatusBadRequest)
+	ErrTooManyResetRequests  = errors.NewError("RATE_LIMITED", "Too many password reset requests. Please try again later.", http.StatusTooManyRequests)
+)
+
+// PasswordResetService runs the forgot-password flow: a signed, single-use link is emailed to the
+// account, and following it sets a new password and signs the account out everywhere.
+type PasswordResetService struct {
+	authService  *AuthService
+	repo         *repositories.PasswordResetRepository
+	auditLogRepo *repositories.AuditLogRepository
+	config       config.PasswordResetConfig
+}
+
+func NewPasswordResetService(authService *AuthService, repo *repositories.PasswordResetRepository, auditLogRepo *repositories.AuditLogRepository, cfg config.PasswordResetConfig) *PasswordResetService {
+	return &PasswordResetService{
+		authService:  authService,
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+		config:       cfg,
+	}
+}
+
+// Enabled reports whether there is a frontend page for the emailed links
+func (s *PasswordResetService) Enabled() bool {
+	return s.config.URL != ""
+}
+
+// ForgotPassword emails a reset link when the address belongs to an active account that may sign in
+// with a password. The caller gets the same answer either way, so the endpoint does not reveal which
+// emails have accounts; only the per-IP limit is reported, and it counts every request before the
+// account is looked up.
+func (s *PasswordResetService) ForgotPassword(ctx context.Context, email, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrPasswordResetDisabled
+	}
+
+	since := time.Now().Add(-time.Hour)
+	byIP, err := s.repo.RecordRequest(ctx, ipAddress, since)
+	if err != nil {
+		return err
+	}
+	if byIP > s.config.MaxPerIP {
+		log.Printf("ForgotPassword: Rate limit reached for IP %s", ipAddress)
+		return ErrTooManyResetRequests
+	}
+
+	user, err := s.authService.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		log.Printf("ForgotPassword: No account for %s", email)
+		return nil
+	}
+
+	byUser, err := s.repo.CountSince(ctx, user.ID, since)
+	if err != nil {
+		return err
+	}
+	if byUser >= s.config.MaxPerUser {
+		log.Printf("ForgotPassword: Rate limit reached for %s", email)
+		return nil
+	}
+	if user.Status != "active" {
+		log.Printf("ForgotPassword: %s is %s, no reset link sent", email, user.Status)
+		return nil
+	}
+	if err := s.authService.checkSSOPolicy(ctx, user); err != nil {
+		return nil
+	}
+
+	ttl := time.Duration(s.config.TTL) * time.Minute
+	reset := &models.PasswordReset{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(ttl),
+		IPAddress: &ipAddress,
+	}
+	token, err := s.authService.tokenService.GeneratePasswordResetToken(user.ID, reset.ID, ttl)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate reset token", errors.ErrInternalServer.Status)
+	}
+	reset.TokenHash = utils.HashToken(token)
+	if err := s.repo.Create(ctx, reset); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.URL, "PASSWORD_RESET_URL", token)
+	if err != nil {
+		return err
+	}
+	// Sent in the background so the response time does not tell known emails from unknown ones
+	go func() {
+		if err := utils.SendPasswordResetEmail(user.Email, link, s.config.TTL); err != nil {
+			log.Printf("ForgotPassword: Failed to send reset email to %s: %v", user.Email, err)
+		}
+	}()
+
+	s.audit(ctx, user, "user.password_reset_requested", ipAddress)
+	return nil
+}
+
+// ResetPassword sets a new password from a reset link. It uses up the link, invalidates the user's
+// other links and revokes every refresh token, so sessions opened with the old password end.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrPasswordResetDisabled
+	}
+
+	claims, err := s.authService.tokenService.ValidatePasswordResetToken(token)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	resetID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	reset, err := s.repo.Consume(ctx, resetID, utils.HashToken(token))
+	if err == errors.ErrNotFound {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.authService.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	if err := checkAccountStatus(user); err != nil {
+		return err
+	}
+
+	passwordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return errors.WrapError(err, "INTERNAL_ERROR", "Failed to hash password", errors.ErrInternalServer.Status)
+	}
+	if err := s.authService.userRepo.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return err
+	}
+
+	if err := s.repo.InvalidateForUser(ctx, user.ID); err != nil {
+		log.Printf("Warning: Failed to invalidate password resets for user %s: %v", user.ID, err)
+	}
+	if err := s.authService.tokenRepo.RevokeAllForUser(ctx, user.ID, user.ID); err != nil {
+		log.Printf("Warning: Failed to revoke refresh tokens for user %s: %v", user.ID, err)
+	}
+	s.authService.proxy.revokeLogged(ctx, user.Email, "password reset")
+
+	s.audit(ctx, user, "user.password_reset", ipAddress)
+	return nil
+}
+
+// tokenLink appends the token of an emailed link to the frontend page configured in setting, keeping
+// any query the page already has
+func tokenLink(pageURL, setting, token string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", errors.WrapError(err, "INTERNAL_ERROR", "Invalid "+setting, errors.ErrInternalServer.Status)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (s *PasswordResetService) audit(ctx context.Context, user *models.User, action, ipAddress string) {
+	resourceType := "user"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		IPAddress:    &ipAddress,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     map[string]interface{}{"email": user.Email},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
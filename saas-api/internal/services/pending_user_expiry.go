@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/orgtime"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+const pendingExpiryBatchSize = 500
+
+// PendingUserExpiryJob periodically flags or deletes users that stayed "pending" longer than the
+// configured number of days, and notifies the admin who invited them. Days are counted in the
+// user's organization timezone: an invite expires at local midnight once ExpiryDays full days passed.
+type PendingUserExpiryJob struct {
+	userRepo  *repositories.UserRepository
+	timezones *orgtime.Resolver
+	cfg       config.PendingUserConfig
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewPendingUserExpiryJob(userRepo *repositories.UserRepository, timezones *orgtime.Resolver, cfg config.PendingUserConfig) *PendingUserExpiryJob {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 60
+	}
+	if cfg.Action != "delete" {
+		cfg.Action = "flag"
+	}
+	return &PendingUserExpiryJob{
+		userRepo:  userRepo,
+		timezones: timezones,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the background loop; it is a no-op when ExpiryDays is 0
+func (j *PendingUserExpiryJob) Start() {
+	if j.cfg.ExpiryDays <= 0 {
+		log.Println("Pending user expiry disabled (PENDING_USER_EXPIRY_DAYS=0)")
+		close(j.done)
+		return
+	}
+
+	log.Printf("Pending user expiry enabled: %s users pending for more than %d days (every %d minutes)",
+		j.cfg.Action, j.cfg.ExpiryDays, j.cfg.CheckInterval)
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(time.Duration(j.cfg.CheckInterval) * time.Minute)
+		defer ticker.Stop()
+
+		j.RunOnce(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce(context.Background())
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop
+func (j *PendingUserExpiryJob) Stop() {
+	select {
+	case <-j.done:
+		return
+	default:
+	}
+	close(j.stop)
+	<-j.done
+}
+
+// RunOnce expires all stale pending users and returns how many were processed
+func (j *PendingUserExpiryJob) RunOnce(ctx context.Context) int {
+	now := time.Now()
+	// Every org's local cutoff is before this; users created between it and their own cutoff are skipped
+	cutoff := now.Add(-time.Duration(j.cfg.ExpiryDays)*24*time.Hour + time.Hour)
+	deleted := j.cfg.Action == "delete"
+	expiredByInviter := make(map[uuid.UUID][]string)
+	processed := 0
+
+	for {
+		users, err := j.userRepo.ListStalePendingUsers(ctx, cutoff, pendingExpiryBatchSize)
+		if err != nil {
+			log.Printf("Pending user expiry: failed to list stale users: %v", err)
+			break
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		batchProcessed := 0
+		for _, user := range users {
+			if !user.CreatedAt.Before(j.cutoff(ctx, now, user.OrgID)) {
+				continue
+			}
+			if err := j.expire(ctx, user, deleted); err != nil {
+				log.Printf("Pending user expiry: failed to %s user %s: %v", j.cfg.Action, user.Email, err)
+				continue
+			}
+			batchProcessed++
+			if user.InvitedBy != nil {
+				expiredByInviter[*user.InvitedBy] = append(expiredByInviter[*user.InvitedBy], user.Email)
+			}
+		}
+		processed += batchProcessed
+
+		// Stop if nothing in the batch could be expired, otherwise the same rows are listed again
+		if batchProcessed == 0 || len(users) < pendingExpiryBatchSize {
+			break
+		}
+	}
+
+	if processed > 0 {
+		log.Printf("Pending user expiry: %s %d users pending for more than %d days", j.cfg.Action, processed, j.cfg.ExpiryDays)
+	}
+
+	j.notifyInviters(ctx, expiredByInviter, deleted)
+	return processed
+}
+
+// cutoff is local midnight ExpiryDays days ago in the org's timezone
+func (j *PendingUserExpiryJob) cutoff(ctx context.Context, now time.Time, orgID *uuid.UUID) time.Time {
+	return orgtime.DaysBefore(now, j.timezones.Location(ctx, orgID), j.cfg.ExpiryDays)
+}
+
+// Schedule describes the job for an organization in the given timezone
+func (j *PendingUserExpiryJob) Schedule(now time.Time, loc *time.Location) []models.ScheduledJob {
+	job := models.ScheduledJob{
+		Name:            "pending_user_expiry",
+		Enabled:         j.cfg.ExpiryDays > 0,
+		IntervalMinutes: j.cfg.CheckInterval,
+		Description:     fmt.Sprintf("%s users still pending after %d full local days", j.cfg.Action, j.cfg.ExpiryDays),
+	}
+	if job.Enabled {
+		cutoff := orgtime.DaysBefore(now, loc, j.cfg.ExpiryDays)
+		job.Cutoff = &cutoff
+	}
+	return []models.ScheduledJob{job}
+}
+
+func (j *PendingUserExpiryJob) expire(ctx context.Context, user *models.User, deleted bool) error {
+	if deleted {
+		return j.userRepo.Delete(ctx, user.ID)
+	}
+	return j.userRepo.FlagPendingExpired(ctx, user.ID)
+}
+
+func (j *PendingUserExpiryJob) notifyInviters(ctx context.Context, expiredByInviter map[uuid.UUID][]string, deleted bool) {
+	for inviterID, emails := range expiredByInviter {
+		inviter, err := j.userRepo.GetByID(ctx, inviterID)
+		if err != nil {
+			log.Printf("Pending user expiry: inviter %s not found, skipping notification: %v", inviterID, err)
+			continue
+		}
+		if err := utils.SendPendingUsersExpiredEmail(inviter.Email, emails, j.cfg.ExpiryDays, deleted); err != nil {
+			log.Printf("Pending user expiry: failed to notify %s: %v", inviter.Email, err)
+		}
+	}
+}
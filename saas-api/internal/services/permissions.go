@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPermissionExists = errors.NewError("CONFLICT", "A permission with this resource and action already exists", http.StatusConflict)
+	ErrSystemPermission = errors.NewError("FORBIDDEN", "System permissions cannot be changed or deleted", http.StatusForbidden)
+)
+
+// permissionName is the form of resources and actions: they are joined as resource:action in API
+// key and OAuth client scopes, so they cannot contain a colon. An action may also be the wildcard.
+var permissionName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// PermissionService registers permissions at runtime, so new resources and actions can be granted
+// to roles (POST /roles/:id/permissions) without a migration. System permissions, the ones the API
+// itself checks, are seeded by db_setup.sql and cannot be changed. A permission's resource and
+// action are its identity: scopes refer to it by them, so they cannot be edited after creation.
+type PermissionService struct {
+	repo         *repositories.PermissionRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewPermissionService(repo *repositories.PermissionRepository, auditLogRepo *repositories.AuditLogRepository) *PermissionService {
+	return &PermissionService{
+		repo:         repo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+func (s *PermissionService) List(ctx context.Context) ([]*models.Permission, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *PermissionService) Create(ctx context.Context, req models.CreatePermissionRequest, actorID uuid.UUID) (*models.Permission, error) {
+	if !permissionName.MatchString(req.Resource) || (!permissionName.MatchString(req.Action) && req.Action != models.PermissionWildcard) {
+		return nil, errors.NewError("VALIDATION_ERROR", "resource and action must be lowercase letters, digits and underscores, starting with a letter; action may also be *", http.StatusBadRequest)
+	}
+
+	perm := &models.Permission{
+		Resource:    req.Resource,
+		Action:      req.Action,
+		Description: req.Description,
+	}
+	if err := s.repo.Create(ctx, perm); err != nil {
+		if err == errors.ErrConflict {
+			return nil, ErrPermissionExists
+		}
+		return nil, err
+	}
+
+	s.audit(ctx, perm, "permission.created", actorID, nil)
+	return perm, nil
+}
+
+// Update changes the description of a permission
+func (s *PermissionService) Update(ctx context.Context, id uuid.UUID, req models.UpdatePermissionRequest, actorID uuid.UUID) (*models.Permission, error) {
+	if _, err := s.custom(ctx, id); err != nil {
+		return nil, err
+	}
+
+	perm, err := s.repo.UpdateDescription(ctx, id, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, perm, "permission.updated", actorID, nil)
+	return perm, nil
+}
+
+// Delete removes a permission and takes it away from every role that has it
+func (s *PermissionService) Delete(ctx context.Context, id uuid.UUID, actorID uuid.UUID) error {
+	perm, err := s.custom(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	roles, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, perm, "permission.deleted", actorID, map[string]interface{}{"roles": roles})
+	return nil
+}
+
+// custom loads a permission that may be changed, which is any but a system one
+func (s *PermissionService) custom(ctx context.Context, id uuid.UUID) (*models.Permission, error) {
+	perm, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if perm.IsSystem {
+		return nil, ErrSystemPermission
+	}
+	return perm, nil
+}
+
+func (s *PermissionService) audit(ctx context.Context, perm *models.Permission, action string, actorID uuid.UUID, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["resource"] = perm.Resource
+	metadata["action"] = perm.Action
+
+	resourceType := "permission"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &perm.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for permission %s: %v", action, perm.ID, err)
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPersonalAccessToken = errors.NewError("UNAUTHORIZED", "Invalid, expired or revoked personal access token", http.StatusUnauthorized)
+
+// PersonalAccessTokenService mints and verifies personal access tokens. Like an API key, a token is
+// shown once and only its SHA-256 hash is stored; unlike one, users mint tokens for themselves, with
+// no admin permission, and a token always acts as the user who minted it.
+type PersonalAccessTokenService struct {
+	repo         *repositories.PersonalAccessTokenRepository
+	userRepo     *repositories.UserRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewPersonalAccessTokenService(repo *repositories.PersonalAccessTokenRepository, userRepo *repositories.UserRepository, auditLogRepo *repositories.AuditLogRepository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		repo:         repo,
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// User loads a token owner, for handlers that check the caller may manage their tokens
+func (s *PersonalAccessTokenService) User(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *PersonalAccessTokenService) List(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	return s.repo.ListForUser(ctx, userID)
+}
+
+// Create mints a token for the user, who must already hold every requested scope: a token can
+// narrow the user's access but never widen it
+func (s *PersonalAccessTokenService) Create(ctx context.Context, userID uuid.UUID, req models.CreatePersonalAccessTokenRequest) (*models.CreatePersonalAccessTokenResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != "active" {
+		return nil, errors.NewError("VALIDATION_ERROR", "Only active users can mint personal access tokens", http.StatusBadRequest)
+	}
+
+	scopes, err := checkScopes(ctx, s.userRepo, user.ID, req.Scopes, "token's")
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.NewError("VALIDATION_ERROR", "expires_at must be in the future", http.StatusBadRequest)
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate personal access token", errors.ErrInternalServer.Status)
+	}
+	plaintext := models.PersonalAccessTokenPrefix + strings.TrimRight(secret, "=")
+
+	token := &models.PersonalAccessToken{
+		UserID:    user.ID,
+		OrgID:     user.OrgID,
+		Name:      req.Name,
+		Prefix:    plaintext[:len(models.PersonalAccessTokenPrefix)+8],
+		TokenHash: utils.HashToken(plaintext),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, token, "personal_access_token.created", user.ID)
+	return &models.CreatePersonalAccessTokenResponse{PersonalAccessToken: token, Token: plaintext}, nil
+}
+
+// Revoke stops one of the user's tokens from authenticating; the row is kept so its use stays
+// attributable
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, userID, id, actorID uuid.UUID) (*models.PersonalAccessToken, error) {
+	token, err := s.repo.Revoke(ctx, userID, id, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, token, "personal_access_token.revoked", actorID)
+	return token, nil
+}
+
+// Authenticate resolves a `pat_` bearer token to the token and its user. Unknown, revoked and
+// expired tokens, and tokens whose user is no longer active, is locked or has left the org the
+// token was minted in, all return ErrInvalidPersonalAccessToken.
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, plaintext, ipAddress string) (*models.PersonalAccessToken, *models.User, error) {
+	if !strings.HasPrefix(plaintext, models.PersonalAccessTokenPrefix) {
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	token, err := s.repo.GetByHash(ctx, utils.HashToken(plaintext))
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(time.Now())) {
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err == errors.ErrNotFound {
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sameOrg := (user.OrgID == nil && token.OrgID == nil) || (user.OrgID != nil && token.OrgID != nil && *user.OrgID == *token.OrgID)
+	if user.Status != "active" || checkLocked(user) != nil || !sameOrg {
+		log.Printf("Personal access token %s rejected: user %s is %s in org %v", token.ID, user.ID, user.Status, user.OrgID)
+		return nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchLastUsed(ctx, token.ID, ipAddress); err != nil {
+			log.Printf("Warning: Failed to update last_used_at for personal access token %s: %v", token.ID, err)
+		}
+	}
+
+	return token, user, nil
+}
+
+func (s *PersonalAccessTokenService) audit(ctx context.Context, token *models.PersonalAccessToken, action string, actorID uuid.UUID) {
+	resourceType := "personal_access_token"
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        token.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &token.ID,
+		Status:       "success",
+		Metadata: map[string]interface{}{
+			"name":    token.Name,
+			"prefix":  token.Prefix,
+			"user_id": token.UserID.String(),
+			"scopes":  token.Scopes,
+		},
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for personal access token %s: %v", action, token.ID, err)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/config"
+)
+
+// proxyRevokeTimeout bounds a call to the proxy, so an unreachable proxy does not hold up a sign-out
+const proxyRevokeTimeout = 5 * time.Second
+
+// ProxySessions ends users' LibreChat proxy sessions through the proxy's POST /internal/revoke.
+// Revoking saas-api refresh tokens does not reach them: a proxy session JWT stays valid for up to 6
+// hours and can start new LibreChat sessions.
+type ProxySessions struct {
+	revokeURL string
+	secret    string
+	client    *http.Client
+}
+
+// NewProxySessions returns nil when PROXY_URL or PROXY_SHARED_SECRET is unset; a nil
+// *ProxySessions revokes nothing
+func NewProxySessions(cfg config.ProxyConfig) *ProxySessions {
+	if cfg.URL == "" || cfg.SharedSecret == "" {
+		return nil
+	}
+	return &ProxySessions{
+		revokeURL: strings.TrimRight(cfg.URL, "/") + "/internal/revoke",
+		secret:    cfg.SharedSecret,
+		client:    &http.Client{Timeout: proxyRevokeTimeout},
+	}
+}
+
+// RevokeUser refuses every proxy session issued to email so far
+func (p *ProxySessions) RevokeUser(ctx context.Context, email string) error {
+	if p == nil {
+		return nil
+	}
+	body, err := json.Marshal(map[string]string{"email": email})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.revokeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Proxy-Secret", p.secret)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// revokeLogged revokes the user's proxy sessions and logs a failure; it reports whether they were
+// revoked. Callers have already revoked the saas-api sessions, so a proxy outage does not fail them.
+func (p *ProxySessions) revokeLogged(ctx context.Context, email, reason string) bool {
+	if p == nil {
+		return false
+	}
+	if err := p.RevokeUser(ctx, email); err != nil {
+		log.Printf("Warning: Failed to revoke proxy sessions of %s (%s): %v", email, reason, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+const roleExpiryBatchSize = 500
+
+// RoleExpiryJob periodically revokes expired role assignments and warns org admins of assignments
+// about to expire. Expired assignments already grant nothing (permission checks skip them); revoking
+// them records the expiry in the audit log and tells the admins once.
+type RoleExpiryJob struct {
+	roleRepo     *repositories.RoleRepository
+	userRepo     *repositories.UserRepository
+	auditLogRepo *repositories.AuditLogRepository
+	cfg          config.RoleExpiryConfig
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRoleExpiryJob(roleRepo *repositories.RoleRepository, userRepo *repositories.UserRepository, auditLogRepo *repositories.AuditLogRepository, cfg config.RoleExpiryConfig) *RoleExpiryJob {
+	return &RoleExpiryJob{
+		roleRepo:     roleRepo,
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+		cfg:          cfg,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start launches the background loop; it is a no-op when CheckInterval is 0
+func (j *RoleExpiryJob) Start() {
+	if j.cfg.CheckInterval <= 0 {
+		log.Println("Role expiry disabled (ROLE_EXPIRY_INTERVAL=0)")
+		close(j.done)
+		return
+	}
+
+	log.Printf("Role expiry enabled: expired role assignments revoked every %d minutes, admins warned %d days ahead",
+		j.cfg.CheckInterval, j.cfg.NoticeDays)
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(time.Duration(j.cfg.CheckInterval) * time.Minute)
+		defer ticker.Stop()
+
+		j.RunOnce(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce(context.Background())
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop
+func (j *RoleExpiryJob) Stop() {
+	select {
+	case <-j.done:
+		return
+	default:
+	}
+	close(j.stop)
+	<-j.done
+}
+
+// roleExpiryNotice collects what one org's admins are told in a run
+type roleExpiryNotice struct {
+	expired  []string
+	expiring []string
+}
+
+// RunOnce revokes expired assignments and claims the notices due, then emails each org's admins;
+// it returns how many assignments were revoked and how many are about to expire
+func (j *RoleExpiryJob) RunOnce(ctx context.Context) (revoked, expiring int) {
+	notices := make(map[uuid.UUID]*roleExpiryNotice)
+	noticeFor := func(a *models.RoleAssignmentExpiry) *roleExpiryNotice {
+		if a.OrgID == nil {
+			return nil
+		}
+		if notices[*a.OrgID] == nil {
+			notices[*a.OrgID] = &roleExpiryNotice{}
+		}
+		return notices[*a.OrgID]
+	}
+
+	for {
+		assignments, err := j.roleRepo.RevokeExpiredAssignments(ctx, roleExpiryBatchSize)
+		if err != nil {
+			log.Printf("Role expiry: failed to revoke expired assignments: %v", err)
+			break
+		}
+		for _, a := range assignments {
+			j.audit(ctx, a)
+			if notice := noticeFor(a); notice != nil {
+				notice.expired = append(notice.expired, describeRoleAssignment(a, "expired"))
+			}
+		}
+		revoked += len(assignments)
+		if len(assignments) < roleExpiryBatchSize {
+			break
+		}
+	}
+
+	if j.cfg.NoticeDays > 0 {
+		before := time.Now().AddDate(0, 0, j.cfg.NoticeDays)
+		for {
+			assignments, err := j.roleRepo.ClaimExpiryNotices(ctx, before, roleExpiryBatchSize)
+			if err != nil {
+				log.Printf("Role expiry: failed to claim expiry notices: %v", err)
+				break
+			}
+			for _, a := range assignments {
+				if notice := noticeFor(a); notice != nil {
+					notice.expiring = append(notice.expiring, describeRoleAssignment(a, "expires"))
+				}
+			}
+			expiring += len(assignments)
+			if len(assignments) < roleExpiryBatchSize {
+				break
+			}
+		}
+	}
+
+	if revoked > 0 || expiring > 0 {
+		log.Printf("Role expiry: revoked %d expired role assignments, %d expiring within %d days", revoked, expiring, j.cfg.NoticeDays)
+	}
+
+	j.notifyAdmins(ctx, notices)
+	return revoked, expiring
+}
+
+// Schedule describes the job for an organization in the given timezone
+func (j *RoleExpiryJob) Schedule(now time.Time, loc *time.Location) []models.ScheduledJob {
+	return []models.ScheduledJob{{
+		Name:            "role_expiry",
+		Enabled:         j.cfg.CheckInterval > 0,
+		IntervalMinutes: j.cfg.CheckInterval,
+		Description:     fmt.Sprintf("Expired role assignments are revoked; org admins are warned %d days before assignments expire", j.cfg.NoticeDays),
+	}}
+}
+
+func (j *RoleExpiryJob) notifyAdmins(ctx context.Context, notices map[uuid.UUID]*roleExpiryNotice) {
+	for orgID, notice := range notices {
+		admins, err := j.userRepo.ListOrgAdmins(ctx, orgID)
+		if err != nil {
+			log.Printf("Role expiry: failed to list admins of org %s, skipping notification: %v", orgID, err)
+			continue
+		}
+		for _, admin := range admins {
+			if err := utils.SendRoleAssignmentsExpiringEmail(admin.Email, notice.expired, notice.expiring, j.cfg.NoticeDays); err != nil {
+				log.Printf("Role expiry: failed to notify %s: %v", admin.Email, err)
+			}
+		}
+	}
+}
+
+func (j *RoleExpiryJob) audit(ctx context.Context, a *models.RoleAssignmentExpiry) {
+	resourceType := "user"
+	if err := j.auditLogRepo.Create(ctx, &models.AuditLog{
+		OrgID:        a.OrgID,
+		Action:       "user.role_expired",
+		ResourceType: &resourceType,
+		ResourceID:   &a.UserID,
+		Status:       "success",
+		Metadata: map[string]interface{}{
+			"email":      a.UserEmail,
+			"role_id":    a.RoleID.String(),
+			"role_name":  a.RoleName,
+			"expires_at": a.ExpiresAt.UTC().Format(time.RFC3339),
+		},
+	}); err != nil {
+		log.Printf("Warning: Failed to record user.role_expired for user %s: %v", a.UserID, err)
+	}
+}
+
+// describeRoleAssignment is an email line such as "ana@acme.com - Editor (expires January 2, 2026)"
+func describeRoleAssignment(a *models.RoleAssignmentExpiry, verb string) string {
+	return fmt.Sprintf("%s - %s (%s %s)", a.UserEmail, a.RoleName, verb, a.ExpiresAt.UTC().Format("January 2, 2006"))
+}
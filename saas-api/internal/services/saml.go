@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+)
+
+// SAMLStateTTL is how long a user has to sign in at the identity provider
+const SAMLStateTTL = 10 * time.Minute
+
+var (
+	ErrSAMLDisabled      = errors.NewError("SERVICE_UNAVAILABLE", "SAML single sign-on is not configured (SAML_BASE_URL is not set)", http.StatusServiceUnavailable)
+	ErrSAMLNotConfigured = errors.NewError("NOT_FOUND", "SAML single sign-on is not enabled for this organization", http.StatusNotFound)
+	ErrSAMLInvalidState  = errors.NewError("SAML_INVALID_STATE", "The sign-in session is invalid or has expired. Please sign in again.", http.StatusBadRequest)
+)
+
+// Attribute names IdPs commonly use, tried in order when the provider names none
+var (
+	samlEmailAttributes     = []string{"email", "mail", "emailaddress", "urn:oid:0.9.2342.19200300.100.1.3", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	samlFirstNameAttributes = []string{"givenName", "firstName", "first_name", "urn:oid:2.5.4.42", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname"}
+	samlLastNameAttributes  = []string{"sn", "surname", "lastName", "last_name", "urn:oid:2.5.4.4", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname"}
+)
+
+// SAMLService signs users in through their organization's SAML 2.0 identity provider (see ssoLogin
+// for how users are matched, provisioned and given roles). Only SP-initiated sign-in is accepted, so
+// every response must answer an authentication request this API made. An org can enforce its IdP,
+// which turns password and OTP sign-in off for its users (see AuthService.SetSSOPolicy).
+type SAMLService struct {
+	*ssoLogin
+	client       *auth.SAMLClient
+	providerRepo *repositories.SAMLProviderRepository
+	orgRepo      *repositories.OrganizationRepository
+	config       config.SAMLConfig
+}
+
+func NewSAMLService(
+	authService *AuthService,
+	providerRepo *repositories.SAMLProviderRepository,
+	identityRepo *repositories.UserIdentityRepository,
+	orgRepo *repositories.OrganizationRepository,
+	roleRepo *repositories.RoleRepository,
+	auditLogRepo *repositories.AuditLogRepository,
+	cfg config.SAMLConfig,
+) (*SAMLService, error) {
+	client, err := auth.NewSAMLClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SAMLService{
+		ssoLogin: &ssoLogin{
+			authService:  authService,
+			identityRepo: identityRepo,
+			roleRepo:     roleRepo,
+			auditLogRepo: auditLogRepo,
+		},
+		client:       client,
+		providerRepo: providerRepo,
+		orgRepo:      orgRepo,
+		config:       cfg,
+	}, nil
+}
+
+// Enabled reports whether SAML_BASE_URL is configured
+func (s *SAMLService) Enabled() bool {
+	return s.config.BaseURL != ""
+}
+
+// FrontendURL is where the browser is sent once signed in
+func (s *SAMLService) FrontendURL() string {
+	return s.config.FrontendURL
+}
+
+// GetProvider returns the org's identity provider, with the entity ID and ACS URL to register at it
+func (s *SAMLService) GetProvider(ctx context.Context, orgID uuid.UUID) (*models.OrgSAMLProvider, error) {
+	p, err := s.providerRepo.GetByOrg(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.describeServiceProvider(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetProvider creates or replaces the org's identity provider from its metadata, given inline or
+// fetched from req.IdPMetadataURL. The metadata and the mapped roles are checked first, so a broken
+// configuration is rejected here rather than at the next sign-in.
+func (s *SAMLService) SetProvider(ctx context.Context, orgID uuid.UUID, req models.SetOrgSAMLProviderRequest, actorID *uuid.UUID) (*models.OrgSAMLProvider, error) {
+	var metadata []byte
+	switch {
+	case req.IdPMetadata != nil && strings.TrimSpace(*req.IdPMetadata) != "":
+		metadata = []byte(strings.TrimSpace(*req.IdPMetadata))
+	case req.IdPMetadataURL != nil:
+		data, err := s.client.FetchSAMLMetadata(ctx, *req.IdPMetadataURL)
+		if err != nil {
+			return nil, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest)
+		}
+		metadata = data
+	default:
+		return nil, errors.NewError("VALIDATION_ERROR", "idp_metadata or idp_metadata_url is required", http.StatusBadRequest)
+	}
+	idp, err := auth.ParseSAMLMetadata(metadata)
+	if err != nil {
+		return nil, errors.NewError("VALIDATION_ERROR", err.Error(), http.StatusBadRequest)
+	}
+
+	p := &models.OrgSAMLProvider{
+		OrgID:              orgID,
+		IdPMetadata:        string(metadata),
+		IdPEntityID:        idp.EntityID,
+		IdPSSOURL:          auth.SAMLSSOURL(idp),
+		EmailAttribute:     nonEmpty(req.EmailAttribute),
+		FirstNameAttribute: nonEmpty(req.FirstNameAttribute),
+		LastNameAttribute:  nonEmpty(req.LastNameAttribute),
+		RoleAttribute:      "groups",
+		RoleMappings:       req.RoleMappings,
+		AllowedDomains:     normalizeDomains(req.AllowedDomains),
+		AutoProvision:      req.AutoProvision == nil || *req.AutoProvision,
+		DefaultOrgRole:     "user",
+		Enforced:           req.Enforced != nil && *req.Enforced,
+		Enabled:            req.Enabled == nil || *req.Enabled,
+		UpdatedBy:          actorID,
+	}
+	if req.RoleAttribute != nil && *req.RoleAttribute != "" {
+		p.RoleAttribute = *req.RoleAttribute
+	}
+	if req.DefaultOrgRole != nil {
+		p.DefaultOrgRole = *req.DefaultOrgRole
+	}
+	if p.RoleMappings == nil {
+		p.RoleMappings = []models.SSORoleMapping{}
+	}
+	if err := s.checkGrantAuthority(ctx, orgID, p.RoleMappings, p.DefaultOrgRole, actorID); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleMappings(ctx, orgID, p.RoleMappings); err != nil {
+		return nil, err
+	}
+
+	if err := s.providerRepo.Upsert(ctx, p); err != nil {
+		return nil, err
+	}
+	if err := s.describeServiceProvider(ctx, p); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &models.AuditLog{
+		UserID:   actorID,
+		OrgID:    &orgID,
+		Action:   "saml_provider.updated",
+		Metadata: map[string]interface{}{"idp_entity_id": p.IdPEntityID, "enabled": p.Enabled, "enforced": p.Enforced},
+	}, "saml_provider", &p.ID)
+	return p, nil
+}
+
+// DeleteProvider turns SAML single sign-on off for the org and unlinks the IdP accounts; users keep
+// their other ways of signing in
+func (s *SAMLService) DeleteProvider(ctx context.Context, orgID uuid.UUID, actorID *uuid.UUID) error {
+	p, err := s.providerRepo.Delete(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	s.audit(ctx, &models.AuditLog{
+		UserID:   actorID,
+		OrgID:    &orgID,
+		Action:   "saml_provider.deleted",
+		Metadata: map[string]interface{}{"idp_entity_id": p.IdPEntityID},
+	}, "saml_provider", &p.ID)
+	return nil
+}
+
+// Metadata is the SP metadata XML for the org with orgSlug. It is served before the org has a
+// provider, since most IdPs want it when the application is created there.
+func (s *SAMLService) Metadata(ctx context.Context, orgSlug string) ([]byte, error) {
+	if !s.Enabled() {
+		return nil, ErrSAMLDisabled
+	}
+	org, err := s.orgRepo.GetBySlug(ctx, orgSlug)
+	if err != nil {
+		return nil, errors.ErrNotFound
+	}
+
+	data, err := xml.MarshalIndent(s.client.SPMetadata(org.Slug), "", "  ")
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to build SAML metadata", errors.ErrInternalServer.Status)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Start begins a sign-in with the identity provider of the org with orgSlug. It returns the IdP's
+// sign-in URL and the signed state the ACS needs back, to be kept in a cookie.
+func (s *SAMLService) Start(ctx context.Context, orgSlug, returnTo string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrSAMLDisabled
+	}
+	org, err := s.orgRepo.GetBySlug(ctx, orgSlug)
+	if err != nil {
+		return "", "", ErrSAMLNotConfigured
+	}
+	_, sp, err := s.serviceProvider(ctx, org.ID, org.Slug)
+	if err != nil {
+		return "", "", err
+	}
+
+	st := auth.NewSAMLState(org.ID, returnTo)
+	redirectURL, requestID, err := s.client.AuthnRequestURL(sp, st.RelayState)
+	if err != nil {
+		log.Printf("SAML: cannot start sign-in for org %s: %v", org.ID, err)
+		return "", "", errors.WrapError(err, "INTERNAL_ERROR", "Failed to start sign-in", errors.ErrInternalServer.Status)
+	}
+	st.RequestID = requestID
+	cookie, err := s.authService.tokenService.GenerateSAMLState(st, SAMLStateTTL)
+	if err != nil {
+		return "", "", errors.WrapError(err, "INTERNAL_ERROR", "Failed to start sign-in", errors.ErrInternalServer.Status)
+	}
+
+	return redirectURL, cookie, nil
+}
+
+// ACS completes a sign-in: it checks relayState against the cookie Start issued, verifies the
+// SAMLResponse the IdP posted and signs the user in. It also returns the return_to path given to
+// Start.
+func (s *SAMLService) ACS(ctx context.Context, orgSlug, stateCookie, relayState, samlResponse, ipAddress, userAgent string) (*models.LoginResponse, string, error) {
+	if !s.Enabled() {
+		return nil, "", ErrSAMLDisabled
+	}
+	st, err := s.authService.tokenService.ValidateSAMLState(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(relayState), []byte(st.RelayState)) != 1 {
+		return nil, "", ErrSAMLInvalidState
+	}
+	org, err := s.orgRepo.GetBySlug(ctx, orgSlug)
+	if err != nil || org.ID != st.OrgID {
+		return nil, "", ErrSAMLInvalidState
+	}
+	p, sp, err := s.serviceProvider(ctx, org.ID, org.Slug)
+	if err != nil {
+		return nil, "", err
+	}
+
+	assertion, err := s.client.ParseResponse(sp, samlResponse, st.RequestID)
+	if err != nil {
+		log.Printf("SAML: sign-in for org %s failed: %v", org.ID, err)
+		return nil, "", errors.NewError("SAML_LOGIN_FAILED", "The identity provider did not confirm the sign-in", http.StatusUnauthorized)
+	}
+
+	response, err := s.complete(ctx, ssoProviderFromSAML(p), ssoIdentityFromSAML(p, assertion), ipAddress, userAgent)
+	if err != nil {
+		return nil, "", err
+	}
+	return response, st.ReturnTo, nil
+}
+
+// SSORequired reports whether the org enforces its SAML identity provider
+func (s *SAMLService) SSORequired(ctx context.Context, orgID uuid.UUID) bool {
+	if !s.Enabled() {
+		return false
+	}
+	p, err := s.providerRepo.GetByOrg(ctx, orgID)
+	return err == nil && p.Enabled && p.Enforced
+}
+
+// serviceProvider returns the org's enabled provider and the SP that trusts it
+func (s *SAMLService) serviceProvider(ctx context.Context, orgID uuid.UUID, orgSlug string) (*models.OrgSAMLProvider, *saml.ServiceProvider, error) {
+	p, err := s.providerRepo.GetByOrg(ctx, orgID)
+	if err != nil || !p.Enabled {
+		return nil, nil, ErrSAMLNotConfigured
+	}
+	idp, err := auth.ParseSAMLMetadata([]byte(p.IdPMetadata))
+	if err != nil {
+		log.Printf("SAML: stored metadata of org %s is invalid: %v", orgID, err)
+		return nil, nil, errors.NewError("SAML_PROVIDER_UNAVAILABLE", "Single sign-on is misconfigured for this organization", http.StatusBadGateway)
+	}
+	return p, s.client.ServiceProvider(orgSlug, idp), nil
+}
+
+// describeServiceProvider fills in the SP entity ID and ACS URL of p's org
+func (s *SAMLService) describeServiceProvider(ctx context.Context, p *models.OrgSAMLProvider) error {
+	org, err := s.orgRepo.GetByID(ctx, p.OrgID)
+	if err != nil {
+		return err
+	}
+	p.SPEntityID = s.client.EntityID(org.Slug)
+	p.SPACSURL = s.client.ACSURL(org.Slug)
+	return nil
+}
+
+func ssoProviderFromSAML(p *models.OrgSAMLProvider) *ssoProvider {
+	return &ssoProvider{
+		Kind:           models.IdentityProviderSAML,
+		ID:             p.ID,
+		OrgID:          p.OrgID,
+		Issuer:         p.IdPEntityID,
+		AutoProvision:  p.AutoProvision,
+		AllowedDomains: p.AllowedDomains,
+		DefaultOrgRole: p.DefaultOrgRole,
+		RoleMappings:   p.RoleMappings,
+	}
+}
+
+// ssoIdentityFromSAML reads the assertion through the provider's attribute mapping. A transient
+// NameID changes with every sign-in, so it is not kept as the account's subject.
+func ssoIdentityFromSAML(p *models.OrgSAMLProvider, assertion *auth.SAMLIdentity) *ssoIdentity {
+	id := &ssoIdentity{
+		Email:      assertion.Attribute(attributeNames(p.EmailAttribute, samlEmailAttributes)...),
+		GivenName:  assertion.Attribute(attributeNames(p.FirstNameAttribute, samlFirstNameAttributes)...),
+		FamilyName: assertion.Attribute(attributeNames(p.LastNameAttribute, samlLastNameAttributes)...),
+		RoleValues: assertion.Attributes[p.RoleAttribute],
+	}
+	if assertion.NameIDFormat != string(saml.TransientNameIDFormat) {
+		id.Subject = assertion.NameID
+	}
+	if id.Email == "" && p.EmailAttribute == nil && strings.Contains(assertion.NameID, "@") {
+		id.Email = assertion.NameID
+	}
+	return id
+}
+
+// attributeNames is the configured attribute, or the common names when there is none
+func attributeNames(configured *string, common []string) []string {
+	if configured != nil {
+		return []string{*configured}
+	}
+	return common
+}
+
+// nonEmpty trims s, and is nil when nothing is left
+func nonEmpty(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	return &trimmed
+}
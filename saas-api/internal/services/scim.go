@@ -0,0 +1,836 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSCIMUserNotFound  = errors.NewError("NOT_FOUND", "User not found", http.StatusNotFound)
+	ErrSCIMGroupNotFound = errors.NewError("NOT_FOUND", "Group not found", http.StatusNotFound)
+	ErrSCIMUserExists    = errors.NewError("CONFLICT", "A user with this userName already exists", http.StatusConflict)
+	ErrSCIMGroupExists   = errors.NewError("CONFLICT", "A group with this displayName already exists", http.StatusConflict)
+	ErrSCIMInvalidFilter = errors.NewError("INVALID_FILTER", `Only filters of the form attribute eq "value" are supported, on userName, externalId or emails.value for Users and displayName for Groups`, http.StatusBadRequest)
+	ErrSCIMInvalidPath   = errors.NewError("INVALID_PATH", "Unsupported PATCH operation or path", http.StatusBadRequest)
+	ErrSCIMSelf          = errors.NewError("MUTABILITY", "The API key's own user cannot be deactivated or deleted through SCIM", http.StatusBadRequest)
+)
+
+const (
+	SCIMDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+var (
+	scimFilterPattern     = regexp.MustCompile(`^\s*([A-Za-z][\w.]*)\s+(?i:eq)\s+"([^"]*)"\s*$`)
+	scimMemberPathPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+)
+
+// SCIMService provisions an org's users and groups from its identity provider over SCIM 2.0. Users
+// are the org's users (never super admins) and groups are the org's own roles, so group membership
+// is role assignment. Provisioned users are active, verified, and sign in through the IdP.
+type SCIMService struct {
+	userRepo     *repositories.UserRepository
+	roleRepo     *repositories.RoleRepository
+	tokenRepo    *repositories.RefreshTokenRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewSCIMService(userRepo *repositories.UserRepository, roleRepo *repositories.RoleRepository, tokenRepo *repositories.RefreshTokenRepository, auditLogRepo *repositories.AuditLogRepository) *SCIMService {
+	return &SCIMService{
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		tokenRepo:    tokenRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// scimUserChange is what a PUT or PATCH sets on a user; nil fields are left alone
+type scimUserChange struct {
+	Email      *string
+	GivenName  *string
+	FamilyName *string
+	Active     *bool
+	ExternalID *string // "" removes it
+}
+
+func (s *SCIMService) ListUsers(ctx context.Context, orgID uuid.UUID, filter string, startIndex, count int) (*models.SCIMListResponse, error) {
+	var email, externalID string
+	if filter != "" {
+		attr, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(attr) {
+		case "username", "emails.value":
+			email = value
+		case "externalid":
+			externalID = value
+		default:
+			return nil, ErrSCIMInvalidFilter
+		}
+		if value == "" {
+			return scimList([]*models.SCIMUser{}, 0, startIndex), nil
+		}
+	}
+
+	startIndex, count = scimPage(startIndex, count)
+	users, total, err := s.userRepo.ListInOrg(ctx, orgID, email, externalID, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*models.SCIMUser, 0, len(users))
+	for _, user := range users {
+		roles, err := s.userRepo.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, scimUser(user, roles))
+	}
+	return scimList(resources, total, startIndex), nil
+}
+
+func (s *SCIMService) GetUser(ctx context.Context, orgID, id uuid.UUID) (*models.SCIMUser, error) {
+	user, err := s.orgUser(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.userResource(ctx, user)
+}
+
+// CreateUser provisions an active, verified user into the org. The password is random and never
+// shown, like users provisioned at SSO sign-in.
+func (s *SCIMService) CreateUser(ctx context.Context, orgID, actorID uuid.UUID, in *models.SCIMUser) (*models.SCIMUser, error) {
+	email, err := scimUserEmail(in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, ErrSCIMUserExists
+	}
+
+	password, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to provision user", errors.ErrInternalServer.Status)
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to provision user", errors.ErrInternalServer.Status)
+	}
+
+	orgRole := "user"
+	user := &models.User{
+		ID:            uuid.New(),
+		OrgID:         &orgID,
+		Email:         email,
+		PasswordHash:  passwordHash,
+		OrgRole:       &orgRole,
+		Status:        "active",
+		EmailVerified: true,
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		Metadata:      make(map[string]interface{}),
+	}
+	if in.Active != nil && !*in.Active {
+		user.Status = "suspended"
+	}
+	if in.Name != nil {
+		if in.Name.GivenName != "" {
+			user.FirstName = &in.Name.GivenName
+		}
+		if in.Name.FamilyName != "" {
+			user.LastName = &in.Name.FamilyName
+		}
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	if in.ExternalID != "" {
+		if err := s.userRepo.SetSCIMExternalID(ctx, user.ID, in.ExternalID); err != nil {
+			return nil, err
+		}
+		user.Metadata[models.UserMetadataSCIMExternalID] = in.ExternalID
+	}
+
+	log.Printf("SCIM: provisioned %s into org %s", email, orgID)
+	s.audit(ctx, orgID, actorID, "scim.user_created", "user", user.ID, map[string]interface{}{
+		"email":       email,
+		"external_id": in.ExternalID,
+		"status":      user.Status,
+	})
+	return scimUser(user, nil), nil
+}
+
+// ReplaceUser applies a full user representation (PUT). Names cannot be cleared, only replaced.
+func (s *SCIMService) ReplaceUser(ctx context.Context, orgID, actorID, id uuid.UUID, in *models.SCIMUser) (*models.SCIMUser, error) {
+	user, err := s.orgUser(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	email, err := scimUserEmail(in)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &scimUserChange{Email: &email, Active: in.Active, ExternalID: &in.ExternalID}
+	if in.Name != nil {
+		if in.Name.GivenName != "" {
+			change.GivenName = &in.Name.GivenName
+		}
+		if in.Name.FamilyName != "" {
+			change.FamilyName = &in.Name.FamilyName
+		}
+	}
+	return s.applyUserChange(ctx, user, change, actorID)
+}
+
+// PatchUser applies PATCH operations. Attributes this API does not keep (title, phone numbers,
+// enterprise extension, ...) are accepted and ignored, so IdP attribute mappings do not fail.
+func (s *SCIMService) PatchUser(ctx context.Context, orgID, actorID, id uuid.UUID, ops []models.SCIMPatchOperation) (*models.SCIMUser, error) {
+	user, err := s.orgUser(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &scimUserChange{}
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := change.set(op.Path, op.Value); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return nil, scimInvalidValue("value must be an object when the operation has no path")
+			}
+			for path, value := range values {
+				if err := change.set(path, value); err != nil {
+					return nil, err
+				}
+			}
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				removed := ""
+				change.ExternalID = &removed
+			}
+		default:
+			return nil, ErrSCIMInvalidPath
+		}
+	}
+	return s.applyUserChange(ctx, user, change, actorID)
+}
+
+// DeleteUser deletes the user like DELETE /api/v1/users/:id does
+func (s *SCIMService) DeleteUser(ctx context.Context, orgID, actorID, id uuid.UUID) error {
+	user, err := s.orgUser(ctx, orgID, id)
+	if err != nil {
+		return err
+	}
+	if user.ID == actorID {
+		return ErrSCIMSelf
+	}
+	if err := s.userRepo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+
+	log.Printf("SCIM: deleted %s from org %s", user.Email, orgID)
+	s.audit(ctx, orgID, actorID, "scim.user_deleted", "user", user.ID, map[string]interface{}{"email": user.Email})
+	return nil
+}
+
+// applyUserChange saves change. Deactivating suspends the user and revokes their refresh tokens;
+// reactivating makes them active again (a pending user too).
+func (s *SCIMService) applyUserChange(ctx context.Context, user *models.User, change *scimUserChange, actorID uuid.UUID) (*models.SCIMUser, error) {
+	metadata := map[string]interface{}{}
+
+	if change.Email != nil && !strings.EqualFold(*change.Email, user.Email) {
+		if existing, err := s.userRepo.GetByEmail(ctx, *change.Email); err == nil && existing.ID != user.ID {
+			return nil, ErrSCIMUserExists
+		}
+		if err := s.userRepo.UpdateEmail(ctx, user.ID, *change.Email); err != nil {
+			return nil, err
+		}
+		metadata["old_email"] = user.Email
+		user.Email = *change.Email
+	}
+
+	update, deactivated := false, false
+	if change.GivenName != nil && (user.FirstName == nil || *user.FirstName != *change.GivenName) {
+		user.FirstName, update = change.GivenName, true
+	}
+	if change.FamilyName != nil && (user.LastName == nil || *user.LastName != *change.FamilyName) {
+		user.LastName, update = change.FamilyName, true
+	}
+	if change.Active != nil {
+		status := "active"
+		if !*change.Active {
+			status = "suspended"
+		}
+		if status != user.Status {
+			if status == "suspended" && user.ID == actorID {
+				return nil, ErrSCIMSelf
+			}
+			metadata["old_status"] = user.Status
+			user.Status, update, deactivated = status, true, status == "suspended"
+			if status == "active" {
+				user.EmailVerified = true
+			}
+		}
+	}
+	if update {
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Metadata == nil {
+		user.Metadata = make(map[string]interface{})
+	}
+	current, _ := user.Metadata[models.UserMetadataSCIMExternalID].(string)
+	if change.ExternalID != nil && *change.ExternalID != current {
+		if err := s.userRepo.SetSCIMExternalID(ctx, user.ID, *change.ExternalID); err != nil {
+			return nil, err
+		}
+		user.Metadata[models.UserMetadataSCIMExternalID] = *change.ExternalID
+	}
+
+	action := "scim.user_updated"
+	if deactivated {
+		action = "scim.user_deactivated"
+		if err := s.tokenRepo.RevokeAllForUser(ctx, user.ID, actorID); err != nil {
+			log.Printf("Warning: Failed to revoke refresh tokens for user %s: %v", user.ID, err)
+		}
+		log.Printf("SCIM: deactivated %s in org %v", user.Email, user.OrgID)
+	}
+	metadata["email"] = user.Email
+	metadata["status"] = user.Status
+	s.audit(ctx, *user.OrgID, actorID, action, "user", user.ID, metadata)
+
+	return s.userResource(ctx, user)
+}
+
+func (c *scimUserChange) set(path string, value json.RawMessage) error {
+	switch strings.ToLower(path) {
+	case "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		c.Active = &active
+	case "username":
+		userName, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		email, err := scimUserEmail(&models.SCIMUser{UserName: userName})
+		if err != nil {
+			return err
+		}
+		c.Email = &email
+	case "externalid":
+		externalID, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		c.ExternalID = &externalID
+	case "name.givenname":
+		givenName, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		c.GivenName = &givenName
+	case "name.familyname":
+		familyName, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		c.FamilyName = &familyName
+	case "name":
+		var name models.SCIMName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return scimInvalidValue("name must be an object")
+		}
+		if name.GivenName != "" {
+			c.GivenName = &name.GivenName
+		}
+		if name.FamilyName != "" {
+			c.FamilyName = &name.FamilyName
+		}
+	}
+	return nil
+}
+
+// orgUser loads a user of the org; other orgs' users and super admins do not exist for SCIM
+func (s *SCIMService) orgUser(ctx context.Context, orgID, id uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err == errors.ErrNotFound {
+		return nil, ErrSCIMUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.OrgID == nil || *user.OrgID != orgID || user.IsSuperAdmin {
+		return nil, ErrSCIMUserNotFound
+	}
+	return user, nil
+}
+
+func (s *SCIMService) userResource(ctx context.Context, user *models.User) (*models.SCIMUser, error) {
+	roles, err := s.userRepo.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return scimUser(user, roles), nil
+}
+
+// ListGroups returns the org's roles. Members are left out when withMembers is false
+// (excludedAttributes=members), which IdPs ask for when they only need the groups.
+func (s *SCIMService) ListGroups(ctx context.Context, orgID uuid.UUID, filter string, startIndex, count int, withMembers bool) (*models.SCIMListResponse, error) {
+	var displayName string
+	if filter != "" {
+		attr, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(attr, "displayName") {
+			return nil, ErrSCIMInvalidFilter
+		}
+		if value == "" {
+			return scimList([]*models.SCIMGroup{}, 0, startIndex), nil
+		}
+		displayName = value
+	}
+
+	roles, err := s.roleRepo.List(ctx, &orgID)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*models.Role
+	for _, role := range roles {
+		if displayName == "" || strings.EqualFold(role.Name, displayName) {
+			matched = append(matched, role)
+		}
+	}
+
+	startIndex, count = scimPage(startIndex, count)
+	page := matched[min(startIndex-1, len(matched)):min(startIndex-1+count, len(matched))]
+	resources := make([]*models.SCIMGroup, 0, len(page))
+	for _, role := range page {
+		group, err := s.groupResource(ctx, role, withMembers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, group)
+	}
+	return scimList(resources, len(matched), startIndex), nil
+}
+
+func (s *SCIMService) GetGroup(ctx context.Context, orgID, id uuid.UUID, withMembers bool) (*models.SCIMGroup, error) {
+	role, err := s.orgRole(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(ctx, role, withMembers)
+}
+
+// CreateGroup creates an org role without permissions; an admin grants it permissions as usual
+func (s *SCIMService) CreateGroup(ctx context.Context, orgID, actorID uuid.UUID, in *models.SCIMGroup) (*models.SCIMGroup, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, scimInvalidValue("displayName is required")
+	}
+	if _, err := s.roleRepo.GetByName(ctx, name, &orgID); err == nil {
+		return nil, ErrSCIMGroupExists
+	}
+	memberIDs, err := s.memberIDs(ctx, orgID, in.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Provisioned through SCIM"
+	role := &models.Role{
+		ID:          uuid.New(),
+		OrgID:       &orgID,
+		Name:        name,
+		Type:        "org_defined",
+		Description: &description,
+		CreatedBy:   &actorID,
+	}
+	if err := s.roleRepo.Create(ctx, role); err != nil {
+		return nil, err
+	}
+	if len(memberIDs) > 0 {
+		if err := s.roleRepo.ReplaceMembers(ctx, role.ID, memberIDs, actorID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit(ctx, orgID, actorID, "scim.group_created", "role", role.ID, map[string]interface{}{
+		"name":    role.Name,
+		"members": len(memberIDs),
+	})
+	return s.groupResource(ctx, role, true)
+}
+
+// ReplaceGroup applies a full group representation (PUT): its name and its complete member list
+func (s *SCIMService) ReplaceGroup(ctx context.Context, orgID, actorID, id uuid.UUID, in *models.SCIMGroup) (*models.SCIMGroup, error) {
+	role, err := s.orgRole(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs, err := s.memberIDs(ctx, orgID, in.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renameGroup(ctx, role, in.DisplayName); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.ReplaceMembers(ctx, role.ID, memberIDs, actorID); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, orgID, actorID, "scim.group_updated", "role", role.ID, map[string]interface{}{
+		"name":    role.Name,
+		"members": len(memberIDs),
+	})
+	return s.groupResource(ctx, role, true)
+}
+
+// PatchGroup applies PATCH operations: renames and member additions, removals and replacements
+func (s *SCIMService) PatchGroup(ctx context.Context, orgID, actorID, id uuid.UUID, ops []models.SCIMPatchOperation) (*models.SCIMGroup, error) {
+	role, err := s.orgRole(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var added, removed []string
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		path := strings.ToLower(op.Path)
+
+		switch {
+		case opName == "remove" && scimMemberPathPattern.MatchString(op.Path):
+			memberID, err := uuid.Parse(scimMemberPathPattern.FindStringSubmatch(op.Path)[1])
+			if err != nil {
+				return nil, scimInvalidValue("Member value must be a user ID")
+			}
+			if err := s.removeMember(ctx, role.ID, memberID); err != nil {
+				return nil, err
+			}
+			removed = append(removed, memberID.String())
+
+		case path == "members":
+			var members []models.SCIMMember
+			if len(op.Value) > 0 {
+				if err := json.Unmarshal(op.Value, &members); err != nil {
+					return nil, scimInvalidValue("members must be a list of {\"value\": \"<user id>\"}")
+				}
+			}
+			switch opName {
+			case "add":
+				memberIDs, err := s.memberIDs(ctx, orgID, members)
+				if err != nil {
+					return nil, err
+				}
+				for _, memberID := range memberIDs {
+					if err := s.roleRepo.AssignRoleToUser(ctx, memberID, role.ID, actorID, nil); err != nil {
+						return nil, err
+					}
+					added = append(added, memberID.String())
+				}
+			case "remove":
+				// Removals only need valid IDs: the user may have left the org already
+				memberIDs, err := parseSCIMMembers(members)
+				if err != nil {
+					return nil, err
+				}
+				if len(op.Value) == 0 {
+					if err := s.roleRepo.ReplaceMembers(ctx, role.ID, nil, actorID); err != nil {
+						return nil, err
+					}
+					removed = append(removed, "*")
+				}
+				for _, memberID := range memberIDs {
+					if err := s.removeMember(ctx, role.ID, memberID); err != nil {
+						return nil, err
+					}
+					removed = append(removed, memberID.String())
+				}
+			case "replace":
+				memberIDs, err := s.memberIDs(ctx, orgID, members)
+				if err != nil {
+					return nil, err
+				}
+				if err := s.roleRepo.ReplaceMembers(ctx, role.ID, memberIDs, actorID); err != nil {
+					return nil, err
+				}
+				removed = append(removed, "*")
+				for _, memberID := range memberIDs {
+					added = append(added, memberID.String())
+				}
+			default:
+				return nil, ErrSCIMInvalidPath
+			}
+
+		case (opName == "add" || opName == "replace") && path == "displayname":
+			name, err := scimString(op.Value)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.renameGroup(ctx, role, name); err != nil {
+				return nil, err
+			}
+
+		case (opName == "add" || opName == "replace") && path == "":
+			var values struct {
+				DisplayName string `json:"displayName"`
+			}
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return nil, scimInvalidValue("value must be an object when the operation has no path")
+			}
+			if values.DisplayName != "" {
+				if err := s.renameGroup(ctx, role, values.DisplayName); err != nil {
+					return nil, err
+				}
+			}
+
+		case path == "externalid":
+			// Group externalIds are not kept
+
+		default:
+			return nil, ErrSCIMInvalidPath
+		}
+	}
+
+	s.audit(ctx, orgID, actorID, "scim.group_updated", "role", role.ID, map[string]interface{}{
+		"name":            role.Name,
+		"members_added":   added,
+		"members_removed": removed,
+	})
+	return s.groupResource(ctx, role, true)
+}
+
+// DeleteGroup deletes the role, which removes it from all its members
+func (s *SCIMService) DeleteGroup(ctx context.Context, orgID, actorID, id uuid.UUID) error {
+	role, err := s.orgRole(ctx, orgID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepo.Delete(ctx, role.ID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, orgID, actorID, "scim.group_deleted", "role", role.ID, map[string]interface{}{"name": role.Name})
+	return nil
+}
+
+func (s *SCIMService) renameGroup(ctx context.Context, role *models.Role, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || name == role.Name {
+		return nil
+	}
+	if existing, err := s.roleRepo.GetByName(ctx, name, role.OrgID); err == nil && existing.ID != role.ID {
+		return ErrSCIMGroupExists
+	}
+	role.Name = name
+	return s.roleRepo.Update(ctx, role)
+}
+
+func (s *SCIMService) removeMember(ctx context.Context, roleID, userID uuid.UUID) error {
+	if err := s.roleRepo.RemoveRoleFromUser(ctx, userID, roleID); err != nil && err != errors.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// memberIDs resolves SCIM members to users of the org
+func (s *SCIMService) memberIDs(ctx context.Context, orgID uuid.UUID, members []models.SCIMMember) ([]uuid.UUID, error) {
+	ids, err := parseSCIMMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, err := s.orgUser(ctx, orgID, id); err != nil {
+			if err == ErrSCIMUserNotFound {
+				return nil, scimInvalidValue("Member " + id.String() + " is not a user of the organization")
+			}
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func parseSCIMMembers(members []models.SCIMMember) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		id, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, scimInvalidValue("Member value must be a user ID")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// orgRole loads one of the org's own roles; system roles are not SCIM groups
+func (s *SCIMService) orgRole(ctx context.Context, orgID, id uuid.UUID) (*models.Role, error) {
+	role, err := s.roleRepo.GetByID(ctx, id)
+	if err == errors.ErrNotFound {
+		return nil, ErrSCIMGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if role.OrgID == nil || *role.OrgID != orgID {
+		return nil, ErrSCIMGroupNotFound
+	}
+	return role, nil
+}
+
+func (s *SCIMService) groupResource(ctx context.Context, role *models.Role, withMembers bool) (*models.SCIMGroup, error) {
+	group := &models.SCIMGroup{
+		Schemas:     []string{models.SCIMSchemaGroup},
+		ID:          role.ID.String(),
+		DisplayName: role.Name,
+		Meta:        &models.SCIMMeta{ResourceType: "Group", Created: role.CreatedAt, LastModified: role.UpdatedAt},
+	}
+	if !withMembers {
+		return group, nil
+	}
+
+	members, err := s.roleRepo.ListMembers(ctx, role.ID)
+	if err != nil {
+		return nil, err
+	}
+	group.Members = make([]models.SCIMMember, 0, len(members))
+	for _, member := range members {
+		group.Members = append(group.Members, models.SCIMMember{Value: member.ID.String(), Display: member.Email})
+	}
+	return group, nil
+}
+
+func (s *SCIMService) audit(ctx context.Context, orgID, actorID uuid.UUID, action, resourceType string, resourceID uuid.UUID, metadata map[string]interface{}) {
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        &orgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &resourceID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for %s %s: %v", action, resourceType, resourceID, err)
+	}
+}
+
+func scimUser(user *models.User, roles []*models.Role) *models.SCIMUser {
+	active := user.Status == "active"
+	out := &models.SCIMUser{
+		Schemas:     []string{models.SCIMSchemaUser},
+		ID:          user.ID.String(),
+		UserName:    user.Email,
+		Name:        &models.SCIMName{Formatted: user.FullName},
+		DisplayName: user.FullName,
+		Emails:      []models.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta:        &models.SCIMMeta{ResourceType: "User", Created: user.CreatedAt, LastModified: user.UpdatedAt},
+	}
+	if externalID, ok := user.Metadata[models.UserMetadataSCIMExternalID].(string); ok {
+		out.ExternalID = externalID
+	}
+	if user.FirstName != nil {
+		out.Name.GivenName = *user.FirstName
+	}
+	if user.LastName != nil {
+		out.Name.FamilyName = *user.LastName
+	}
+	for _, role := range roles {
+		if role.OrgID != nil {
+			out.Groups = append(out.Groups, models.SCIMMember{Value: role.ID.String(), Display: role.Name})
+		}
+	}
+	return out
+}
+
+func scimList[T any](resources []T, total, startIndex int) *models.SCIMListResponse {
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   max(startIndex, 1),
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// scimPage clamps startIndex (1-based) and count to the supported range
+func scimPage(startIndex, count int) (int, int) {
+	return max(startIndex, 1), min(max(count, 0), scimMaxCount)
+}
+
+func parseSCIMFilter(filter string) (string, string, error) {
+	m := scimFilterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", ErrSCIMInvalidFilter
+	}
+	return m[1], m[2], nil
+}
+
+// scimUserEmail is the user's email: userName when it is an address, else the primary email
+func scimUserEmail(in *models.SCIMUser) (string, error) {
+	email := in.UserName
+	if !strings.Contains(email, "@") {
+		email = ""
+		for _, e := range in.Emails {
+			if e.Primary || email == "" {
+				email = e.Value
+			}
+		}
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return "", scimInvalidValue("userName must be an email address")
+	}
+	return email, nil
+}
+
+func scimString(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", scimInvalidValue("value must be a string")
+	}
+	return s, nil
+}
+
+// scimBool accepts JSON booleans and, for Azure AD, their string forms
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, scimInvalidValue("active must be a boolean")
+}
+
+func scimInvalidValue(message string) error {
+	return errors.NewError("VALIDATION_ERROR", message, http.StatusBadRequest)
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.NewError("NOT_FOUND", "Session not found", http.StatusNotFound)
+
+// SessionService lists and revokes users' sessions, which are their refresh tokens. Revoking a
+// session stops it from refreshing; its access token stays valid until it expires. Revoking all of
+// them also ends the user's LibreChat proxy sessions.
+type SessionService struct {
+	tokenRepo     *repositories.RefreshTokenRepository
+	userRepo      *repositories.UserRepository
+	auditLogRepo  *repositories.AuditLogRepository
+	libreChatSync *LibreChatSync
+	proxy         *ProxySessions // nil: proxy sessions run until they expire
+}
+
+func NewSessionService(tokenRepo *repositories.RefreshTokenRepository, userRepo *repositories.UserRepository, auditLogRepo *repositories.AuditLogRepository, libreChatSync *LibreChatSync, proxy *ProxySessions) *SessionService {
+	return &SessionService{
+		tokenRepo:     tokenRepo,
+		userRepo:      userRepo,
+		auditLogRepo:  auditLogRepo,
+		libreChatSync: libreChatSync,
+		proxy:         proxy,
+	}
+}
+
+// User loads the user whose sessions an admin manages, for the caller's org check
+func (s *SessionService) User(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *SessionService) List(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
+	return s.tokenRepo.ListActiveForUser(ctx, userID)
+}
+
+// Revoke ends one of the user's sessions; actorID is the user or the admin doing it
+func (s *SessionService) Revoke(ctx context.Context, userID, sessionID, actorID uuid.UUID) error {
+	if err := s.tokenRepo.RevokeForUser(ctx, userID, sessionID, actorID, revokeReason(userID, actorID)); err != nil {
+		if err == errors.ErrNotFound {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	s.audit(ctx, userID, actorID, "user.session_revoked", map[string]interface{}{"session_id": sessionID.String()})
+	return nil
+}
+
+// RevokeAll ends every session of the user, proxy sessions included, and returns how many refresh
+// tokens there were
+func (s *SessionService) RevokeAll(ctx context.Context, userID, actorID uuid.UUID) (int64, error) {
+	revoked, err := s.tokenRepo.RevokeAllForUserWithReason(ctx, userID, actorID, revokeReason(userID, actorID))
+	if err != nil {
+		return 0, err
+	}
+
+	metadata := map[string]interface{}{"revoked": revoked}
+	if s.proxy != nil {
+		if user, err := s.userRepo.GetByID(ctx, userID); err == nil {
+			metadata["proxy_signed_out"] = s.proxy.revokeLogged(ctx, user.Email, revokeReason(userID, actorID))
+		}
+	}
+	s.audit(ctx, userID, actorID, "user.sessions_revoked", metadata)
+	return revoked, nil
+}
+
+// LogoutAll signs the user out everywhere: it revokes every refresh token, deletes their LibreChat
+// sessions and revokes their proxy sessions, so neither the API nor the chat UI can be refreshed
+// into. A LibreChat or proxy failure is logged and reported in the response rather than failing the
+// call, as the API sessions are already revoked.
+func (s *SessionService) LogoutAll(ctx context.Context, userID uuid.UUID) (*models.LogoutAllResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.tokenRepo.RevokeAllForUserWithReason(ctx, userID, userID, "User logged out of all devices")
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LogoutAllResponse{RevokedSessions: revoked}
+	if s.libreChatSync != nil {
+		libreCtx, cancel := context.WithTimeout(ctx, libreChatSyncTimeout)
+		deleted, err := s.libreChatSync.DeleteSessions(libreCtx, user.Email)
+		cancel()
+		if err != nil {
+			log.Printf("LogoutAll: Failed to delete LibreChat sessions of %s: %v", user.Email, err)
+		} else {
+			response.LibreChatSessions = deleted
+			response.LibreChatSignedOut = true
+		}
+	}
+	response.ProxySignedOut = s.proxy.revokeLogged(ctx, user.Email, "logout everywhere")
+
+	s.audit(ctx, userID, userID, "auth.logout_all", map[string]interface{}{
+		"revoked":              revoked,
+		"librechat_sessions":   response.LibreChatSessions,
+		"librechat_signed_out": response.LibreChatSignedOut,
+		"proxy_signed_out":     response.ProxySignedOut,
+	})
+	return response, nil
+}
+
+func revokeReason(userID, actorID uuid.UUID) string {
+	if userID == actorID {
+		return "Session revoked by user"
+	}
+	return "Session revoked by administrator"
+}
+
+func (s *SessionService) audit(ctx context.Context, userID, actorID uuid.UUID, action string, metadata map[string]interface{}) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, userID, err)
+		return
+	}
+	resourceType := "user"
+	metadata["email"] = user.Email
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &actorID,
+		OrgID:        user.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &userID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, userID, err)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"saas-api/pkg/weaviate"
+)
+
+const (
+	// DocumentSnippetLength is the preview size (in characters) returned with include=snippet
+	DocumentSnippetLength = 500
+
+	// snippetProcessingKey is where the snippet is cached in content.processing_data
+	snippetProcessingKey = "snippet"
+)
+
+// ExtractSnippet builds a plain-text preview from a document's chunks JSON: the text chunks in
+// order, whitespace collapsed, cut at a word boundary near DocumentSnippetLength characters.
+// Tables are only used when the document has no other text.
+func ExtractSnippet(jsonFilePath string) (string, error) {
+	data, err := readArtifact(jsonFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read chunks file: %w", err)
+	}
+
+	var chunks []weaviate.Chunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return "", fmt.Errorf("failed to decode chunks file: %w", err)
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].ChunkIndex < chunks[j].ChunkIndex })
+
+	snippet := joinChunkText(chunks, false)
+	if snippet == "" {
+		snippet = joinChunkText(chunks, true)
+	}
+	return truncateSnippet(snippet, DocumentSnippetLength), nil
+}
+
+func joinChunkText(chunks []weaviate.Chunk, includeTables bool) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		if !includeTables && strings.EqualFold(chunk.ContentType, "table") {
+			continue
+		}
+		for _, word := range strings.Fields(chunk.Content) {
+			// Drop markdown heading markers so previews read as prose
+			if strings.Trim(word, "#") == "" {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(word)
+		}
+		// Enough text collected; a little extra lets truncateSnippet find a word boundary
+		if b.Len() > DocumentSnippetLength*2 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func truncateSnippet(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)*4/5 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-") + "…"
+}
+
+// cachedSnippet returns the snippet cached in processing_data, if any
+func cachedSnippet(processingData map[string]interface{}) (string, bool) {
+	snippet, ok := processingData[snippetProcessingKey].(string)
+	return snippet, ok
+}
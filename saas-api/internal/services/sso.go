@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// ssoProvider is an org's identity provider as sign-in sees it, whichever protocol it speaks
+type ssoProvider struct {
+	Kind           string // models.IdentityProviderOIDC or models.IdentityProviderSAML
+	ID             uuid.UUID
+	OrgID          uuid.UUID
+	Issuer         string // Issuer URL or IdP entity ID, for logs and audit entries
+	AutoProvision  bool
+	AllowedDomains []string
+	DefaultOrgRole string
+	RoleMappings   []models.SSORoleMapping
+}
+
+// ssoIdentity is the account an identity provider vouched for
+type ssoIdentity struct {
+	Subject       string // Stable account ID at the IdP; empty when it has none, which leaves the account unlinked
+	Email         string
+	EmailVerified *bool // nil when the IdP does not say
+	GivenName     string
+	FamilyName    string
+	RoleValues    []string // Values of the role claim or attribute, matched against the role mappings
+}
+
+// ssoLogin completes sign-ins through the orgs' identity providers. Users are matched by their IdP
+// account, then by email within the org, and provisioned into the org on their first sign-in when
+// the provider allows it. Org role and roles follow the provider's role mappings on every sign-in.
+type ssoLogin struct {
+	authService  *AuthService
+	identityRepo *repositories.UserIdentityRepository
+	roleRepo     *repositories.RoleRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+// complete signs the user identity belongs to in, records the sign-in as auth.<kind>_login, and adds
+// the attempt to the login history
+func (l *ssoLogin) complete(ctx context.Context, p *ssoProvider, identity *ssoIdentity, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	user, err := l.resolveUser(ctx, p, identity)
+	if err != nil {
+		return nil, err
+	}
+	response, err := l.signIn(ctx, p, user, identity, ipAddress, userAgent)
+	l.authService.recordLogin(ctx, p.Kind, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// signIn signs in the user an IdP account resolved to
+func (l *ssoLogin) signIn(ctx context.Context, p *ssoProvider, user *models.User, identity *ssoIdentity, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	if err := l.checkUserStatus(ctx, user); err != nil {
+		return nil, err
+	}
+	l.applyRoleMappings(ctx, p, user, identity)
+
+	if identity.Subject != "" {
+		email := identity.Email
+		if err := l.identityRepo.RecordLogin(ctx, &models.UserIdentity{
+			UserID:     user.ID,
+			Provider:   p.Kind,
+			ProviderID: p.ID,
+			Subject:    identity.Subject,
+			Email:      &email,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	_ = l.authService.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+
+	response, err := l.authService.issueTokens(ctx, user, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	l.audit(ctx, &models.AuditLog{
+		UserID:    &user.ID,
+		OrgID:     user.OrgID,
+		IPAddress: &ipAddress,
+		UserAgent: &userAgent,
+		Action:    "auth." + p.Kind + "_login",
+		Metadata:  map[string]interface{}{"email": user.Email, "issuer": p.Issuer, "subject": identity.Subject},
+	}, "user", &user.ID)
+	return response, nil
+}
+
+// resolveUser finds the user the IdP account belongs to: the one it is linked to, else the org's
+// user with its email, else a new one when the provider provisions users
+func (l *ssoLogin) resolveUser(ctx context.Context, p *ssoProvider, identity *ssoIdentity) (*models.User, error) {
+	userRepo := l.authService.userRepo
+
+	if identity.Subject != "" {
+		if link, err := l.identityRepo.GetBySubject(ctx, p.Kind, p.ID, identity.Subject); err == nil {
+			if user, err := userRepo.GetByID(ctx, link.UserID); err == nil {
+				return user, nil
+			}
+		}
+	}
+
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" {
+		return nil, errors.NewError("SSO_NO_EMAIL", "The identity provider did not share an email address", http.StatusForbidden)
+	}
+	// Providers that do not say (Azure AD, most SAML IdPs) vouch for the address by issuing it
+	if identity.EmailVerified != nil && !*identity.EmailVerified {
+		return nil, errors.NewError("SSO_EMAIL_NOT_VERIFIED", "Your email address is not verified with the identity provider", http.StatusForbidden)
+	}
+
+	if user, err := userRepo.GetByEmail(ctx, email); err == nil {
+		if user.OrgID == nil || *user.OrgID != p.OrgID {
+			log.Printf("SSO: %s signed in through org %s but belongs to another organization", email, p.OrgID)
+			return nil, errors.NewError("SSO_ORG_MISMATCH", "This account belongs to another organization", http.StatusForbidden)
+		}
+		return user, nil
+	}
+
+	if !p.AutoProvision {
+		return nil, errors.NewError("SSO_USER_NOT_FOUND", "No account exists for this email. Ask your administrator to invite you.", http.StatusForbidden)
+	}
+	if len(p.AllowedDomains) > 0 {
+		_, domain, _ := strings.Cut(email, "@")
+		if !slices.Contains(p.AllowedDomains, domain) {
+			return nil, errors.NewError("SSO_DOMAIN_NOT_ALLOWED", "Accounts for this email domain cannot be created through single sign-on", http.StatusForbidden)
+		}
+	}
+	return l.provisionUser(ctx, p, identity, email)
+}
+
+// provisionUser creates an active user in the provider's org. The password is random and never
+// shown, so the user signs in through the IdP (or resets it).
+func (l *ssoLogin) provisionUser(ctx context.Context, p *ssoProvider, identity *ssoIdentity, email string) (*models.User, error) {
+	password, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to provision user", errors.ErrInternalServer.Status)
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to provision user", errors.ErrInternalServer.Status)
+	}
+
+	orgRole := p.DefaultOrgRole
+	user := &models.User{
+		ID:            uuid.New(),
+		OrgID:         &p.OrgID,
+		Email:         email,
+		PasswordHash:  passwordHash,
+		OrgRole:       &orgRole,
+		Status:        "active",
+		EmailVerified: true,
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		Metadata:      make(map[string]interface{}),
+	}
+	if identity.GivenName != "" {
+		user.FirstName = &identity.GivenName
+	}
+	if identity.FamilyName != "" {
+		user.LastName = &identity.FamilyName
+	}
+	if err := l.authService.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("SSO: provisioned %s into org %s through %s", email, p.OrgID, p.Kind)
+	l.audit(ctx, &models.AuditLog{
+		UserID:   &user.ID,
+		OrgID:    &p.OrgID,
+		Action:   "user.sso_provisioned",
+		Metadata: map[string]interface{}{"email": email, "provider": p.Kind, "issuer": p.Issuer, "subject": identity.Subject},
+	}, "user", &user.ID)
+	return user, nil
+}
+
+// checkUserStatus applies the login rules to an SSO sign-in. A pending (invited) user is activated:
+// signing in through the org's IdP accepts the invitation and proves the email address.
+func (l *ssoLogin) checkUserStatus(ctx context.Context, user *models.User) error {
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return errors.NewError("ACCOUNT_LOCKED", "Account is locked due to failed login attempts", 423)
+	}
+	switch user.Status {
+	case "active":
+		return nil
+	case "pending":
+		user.Status, user.EmailVerified = "active", true
+		if err := l.authService.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+		log.Printf("SSO: activated pending user %s", user.Email)
+		return nil
+	case "suspended":
+		return errors.NewError("ACCOUNT_SUSPENDED", "Your account has been suspended. Please contact your administrator.", 403)
+	}
+	return errors.NewError("ACCOUNT_INACTIVE", "Your account is not active. Please contact your administrator.", 403)
+}
+
+// applyRoleMappings sets the user's org role from the first mapping whose value the IdP sent (users
+// no mapping matches keep theirs), and grants the roles of the matching mappings. Roles named by
+// mappings that no longer match are removed; other roles are left alone.
+func (l *ssoLogin) applyRoleMappings(ctx context.Context, p *ssoProvider, user *models.User, identity *ssoIdentity) {
+	if len(p.RoleMappings) == 0 {
+		return
+	}
+
+	var orgRole *string
+	granted := map[string]bool{}
+	for _, m := range p.RoleMappings {
+		matched := slices.Contains(identity.RoleValues, m.Claim)
+		if m.Role != nil {
+			granted[*m.Role] = granted[*m.Role] || matched
+		}
+		if matched && m.OrgRole != nil && orgRole == nil {
+			orgRole = m.OrgRole
+		}
+	}
+
+	userRepo := l.authService.userRepo
+	if orgRole != nil && (user.OrgRole == nil || *user.OrgRole != *orgRole) {
+		previous := user.OrgRole
+		user.OrgRole = orgRole
+		if err := userRepo.Update(ctx, user); err != nil {
+			log.Printf("Warning: SSO: failed to set org role of %s to %s: %v", user.Email, *orgRole, err)
+			user.OrgRole = previous
+		}
+	}
+
+	current := map[uuid.UUID]bool{}
+	if roles, err := userRepo.GetUserRoles(ctx, user.ID); err == nil {
+		for _, role := range roles {
+			current[role.ID] = true
+		}
+	}
+	for name, grant := range granted {
+		role, err := l.roleRepo.GetByName(ctx, name, &p.OrgID)
+		if err != nil {
+			log.Printf("Warning: SSO: role mapping names role %q, which org %s does not have", name, p.OrgID)
+			continue
+		}
+		switch {
+		case grant && !current[role.ID]:
+			err = l.roleRepo.AssignRoleToUser(ctx, user.ID, role.ID, user.ID, nil)
+		case !grant && current[role.ID]:
+			err = l.roleRepo.RemoveRoleFromUser(ctx, user.ID, role.ID)
+		}
+		if err != nil {
+			log.Printf("Warning: SSO: failed to sync role %q for %s: %v", name, user.Email, err)
+		}
+	}
+}
+
+// checkRoleMappings rejects mappings that name a role the org does not have
+func (l *ssoLogin) checkRoleMappings(ctx context.Context, orgID uuid.UUID, mappings []models.SSORoleMapping) error {
+	for _, m := range mappings {
+		if m.Role == nil {
+			continue
+		}
+		if _, err := l.roleRepo.GetByName(ctx, *m.Role, &orgID); err != nil {
+			return errors.NewError("VALIDATION_ERROR", "Role '"+*m.Role+"' not found in organization", http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// checkGrantAuthority refuses role mappings, or a default org role of admin, from a caller who could
+// not grant those roles themselves: anyone who controls the IdP can claim the mapped groups. Org
+// admins, super admins and holders of roles:assign may save them.
+func (l *ssoLogin) checkGrantAuthority(ctx context.Context, orgID uuid.UUID, mappings []models.SSORoleMapping, defaultOrgRole string, actorID *uuid.UUID) error {
+	grants := defaultOrgRole == "admin"
+	for _, m := range mappings {
+		if m.Role != nil || m.OrgRole != nil {
+			grants = true
+		}
+	}
+	if !grants {
+		return nil
+	}
+
+	denied := errors.NewError("FORBIDDEN", "Only org admins or holders of roles:assign may map IdP groups to roles or make provisioned users admins", http.StatusForbidden)
+	if actorID == nil {
+		return denied
+	}
+	actor, err := l.authService.userRepo.GetByID(ctx, *actorID)
+	if err != nil {
+		return err
+	}
+	if actor.IsSuperAdmin {
+		return nil
+	}
+	if actor.OrgID != nil && *actor.OrgID == orgID && actor.OrgRole != nil && *actor.OrgRole == "admin" {
+		return nil
+	}
+	allowed, err := l.authService.userRepo.HasPermission(ctx, actor.ID, "roles", "assign")
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return denied
+	}
+	return nil
+}
+
+// normalizeDomains lowercases email domains and drops a leading @
+func normalizeDomains(domains []string) []string {
+	normalized := []string{}
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+func (l *ssoLogin) audit(ctx context.Context, entry *models.AuditLog, resourceType string, resourceID *uuid.UUID) {
+	entry.ResourceType = &resourceType
+	entry.ResourceID = resourceID
+	entry.Status = "success"
+	if err := l.auditLogRepo.Create(ctx, entry); err != nil {
+		log.Printf("Warning: Failed to record %s: %v", entry.Action, err)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const bytesPerGB = 1 << 30
+
+var (
+	ErrUnknownPlan        = errors.NewError("VALIDATION_ERROR", "Unknown subscription plan", http.StatusBadRequest)
+	ErrNotAnUpgrade       = errors.NewError("VALIDATION_ERROR", "Target plan is not an upgrade of the current plan", http.StatusBadRequest)
+	ErrNotADowngrade      = errors.NewError("VALIDATION_ERROR", "Target plan is not a downgrade of the current plan", http.StatusBadRequest)
+	ErrAlreadyCancelled   = errors.NewError("CONFLICT", "Subscription is already cancelled", http.StatusConflict)
+	ErrDowngradeCancelled = errors.NewError("CONFLICT", "Cancelled subscriptions can only be reactivated with an upgrade", http.StatusConflict)
+)
+
+// SubscriptionService owns organization plan changes: it validates the transition, applies the
+// target plan's limits and emits a billing event for each change.
+type SubscriptionService struct {
+	orgRepo      *repositories.OrganizationRepository
+	auditLogRepo *repositories.AuditLogRepository
+}
+
+func NewSubscriptionService(orgRepo *repositories.OrganizationRepository, auditLogRepo *repositories.AuditLogRepository) *SubscriptionService {
+	return &SubscriptionService{
+		orgRepo:      orgRepo,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// Upgrade moves the org to a higher plan. A trial upgraded to a paid plan is converted: the trial
+// ends and the paid subscription starts now. Cancelled subscriptions are reactivated by upgrading.
+// Limits are only ever raised, so custom (e.g. enterprise) limits survive an upgrade.
+func (s *SubscriptionService) Upgrade(ctx context.Context, org *models.Organization, plan string, actorID *uuid.UUID) (*models.BillingEvent, error) {
+	target, ok := models.SubscriptionPlans[plan]
+	if !ok || plan == "trial" {
+		return nil, ErrUnknownPlan
+	}
+
+	current := models.SubscriptionPlans[org.SubscriptionPlan]
+	isTrial := org.SubscriptionPlan == "trial" || org.SubscriptionStatus == "trialing"
+	reactivating := org.SubscriptionStatus == "cancelled"
+	// Trials and cancelled subscriptions may also (re)start on a plan of the same rank
+	if target.Rank < current.Rank || (target.Rank == current.Rank && !isTrial && !reactivating) {
+		return nil, ErrNotAnUpgrade
+	}
+
+	eventType := "upgraded"
+	if isTrial {
+		eventType = "trial_converted"
+	}
+
+	now := time.Now()
+	return s.apply(ctx, org, eventType, actorID, nil, func() {
+		org.SubscriptionPlan = plan
+		org.SubscriptionStatus = "active"
+		org.MaxUsers = max(org.MaxUsers, target.MaxUsers)
+		org.MaxStorageGB = max(org.MaxStorageGB, target.MaxStorageGB)
+		if isTrial || reactivating {
+			org.TrialEndsAt = nil
+			org.SubscriptionStartsAt = &now
+			org.SubscriptionEndsAt = nil
+		}
+	})
+}
+
+// Downgrade moves the org to a lower plan. It is refused while current usage (users or storage)
+// exceeds the target plan's limits, listing what has to be reduced first.
+func (s *SubscriptionService) Downgrade(ctx context.Context, org *models.Organization, plan string, actorID *uuid.UUID) (*models.BillingEvent, error) {
+	target, ok := models.SubscriptionPlans[plan]
+	if !ok || plan == "trial" {
+		return nil, ErrUnknownPlan
+	}
+	if org.SubscriptionStatus == "cancelled" {
+		return nil, ErrDowngradeCancelled
+	}
+
+	current := models.SubscriptionPlans[org.SubscriptionPlan]
+	if target.Rank >= current.Rank {
+		return nil, ErrNotADowngrade
+	}
+
+	usedBytes, err := s.orgRepo.StorageUsedBytes(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if org.CurrentUsers > target.MaxUsers {
+		problems = append(problems, fmt.Sprintf("%d users (plan %s allows %d)", org.CurrentUsers, plan, target.MaxUsers))
+	}
+	if usedBytes > int64(target.MaxStorageGB)*bytesPerGB {
+		problems = append(problems, fmt.Sprintf("%.2f GB of storage (plan %s allows %d GB)", float64(usedBytes)/bytesPerGB, plan, target.MaxStorageGB))
+	}
+	if len(problems) > 0 {
+		return nil, errors.NewError("PLAN_LIMIT_EXCEEDED",
+			fmt.Sprintf("Cannot downgrade while the organization uses %s", strings.Join(problems, " and ")), http.StatusConflict)
+	}
+
+	return s.apply(ctx, org, "downgraded", actorID, nil, func() {
+		org.SubscriptionPlan = plan
+		org.SubscriptionStatus = "active"
+		org.TrialEndsAt = nil
+		org.MaxUsers = target.MaxUsers
+		org.MaxStorageGB = target.MaxStorageGB
+	})
+}
+
+// Cancel marks the subscription cancelled. Unless immediate, an existing future subscription_ends_at
+// is kept so the org stays usable until the end of the paid period.
+func (s *SubscriptionService) Cancel(ctx context.Context, org *models.Organization, req models.CancelSubscriptionRequest, actorID *uuid.UUID) (*models.BillingEvent, error) {
+	if org.SubscriptionStatus == "cancelled" {
+		return nil, ErrAlreadyCancelled
+	}
+
+	now := time.Now()
+	return s.apply(ctx, org, "cancelled", actorID, req.Reason, func() {
+		org.SubscriptionStatus = "cancelled"
+		org.TrialEndsAt = nil
+		if req.Immediate || org.SubscriptionEndsAt == nil || org.SubscriptionEndsAt.Before(now) {
+			org.SubscriptionEndsAt = &now
+		}
+	})
+}
+
+// apply mutates org, persists the subscription and emits the billing event
+func (s *SubscriptionService) apply(ctx context.Context, org *models.Organization, eventType string, actorID *uuid.UUID, reason *string, mutate func()) (*models.BillingEvent, error) {
+	event := &models.BillingEvent{
+		Type:       eventType,
+		OrgID:      org.ID,
+		ActorID:    actorID,
+		FromPlan:   org.SubscriptionPlan,
+		FromStatus: org.SubscriptionStatus,
+		Reason:     reason,
+	}
+
+	mutate()
+	org.UpdatedBy = actorID
+	if err := s.orgRepo.UpdateSubscription(ctx, org); err != nil {
+		return nil, err
+	}
+
+	event.ToPlan = org.SubscriptionPlan
+	event.ToStatus = org.SubscriptionStatus
+	event.OccurredAt = org.UpdatedAt
+	s.emit(ctx, event)
+	return event, nil
+}
+
+// emit records the billing event in audit_logs. Failures are logged only: the plan change is already committed.
+func (s *SubscriptionService) emit(ctx context.Context, event *models.BillingEvent) {
+	log.Printf("Billing event: subscription.%s org=%s %s/%s -> %s/%s",
+		event.Type, event.OrgID, event.FromPlan, event.FromStatus, event.ToPlan, event.ToStatus)
+
+	if s.auditLogRepo == nil {
+		return
+	}
+	resourceType := "organization"
+	metadata := map[string]interface{}{
+		"from_plan":   event.FromPlan,
+		"to_plan":     event.ToPlan,
+		"from_status": event.FromStatus,
+		"to_status":   event.ToStatus,
+	}
+	if event.Reason != nil {
+		metadata["reason"] = *event.Reason
+	}
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       event.ActorID,
+		OrgID:        &event.OrgID,
+		Action:       "subscription." + event.Type,
+		ResourceType: &resourceType,
+		ResourceID:   &event.OrgID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record billing event for org %s: %v", event.OrgID, err)
+	}
+}
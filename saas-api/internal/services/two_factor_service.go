@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/auth"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/envelope"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/utils"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrTwoFactorDisabled       = errors.NewError("SERVICE_UNAVAILABLE", "Two-factor authentication is not configured (SECRETS_ENCRYPTION_KEYS is not set)", http.StatusServiceUnavailable)
+	ErrTwoFactorNotEnabled     = errors.NewError("TWO_FACTOR_NOT_ENABLED", "Two-factor authentication is not enabled", http.StatusBadRequest)
+	ErrTwoFactorAlreadyEnabled = errors.NewError("TWO_FACTOR_ALREADY_ENABLED", "Two-factor authentication is already enabled", http.StatusConflict)
+	ErrTwoFactorSetupRequired  = errors.NewError("TWO_FACTOR_SETUP_REQUIRED", "Start two-factor setup first", http.StatusBadRequest)
+	ErrTwoFactorRequired       = errors.NewError("TWO_FACTOR_REQUIRED", "Your organization requires two-factor authentication", http.StatusForbidden)
+	ErrInvalidTwoFactorCode    = errors.NewError("INVALID_2FA_CODE", "Invalid two-factor authentication code", http.StatusUnauthorized)
+	ErrInvalidMFAToken         = errors.NewError("INVALID_MFA_TOKEN", "The sign-in session is invalid or has expired. Please sign in again.", http.StatusUnauthorized)
+)
+
+// TwoFactorService manages TOTP two-factor authentication. Password and OTP sign-ins of users with
+// 2FA stop at an MFA token (see AuthService.SetTwoFactor) that Verify exchanges, with a code from
+// the authenticator app or a backup code, for the tokens. Orgs require 2FA with the require_2fa
+// setting: their users without it are sent through Enroll and EnrollConfirm at sign-in, and cannot
+// turn it off. SSO sign-ins are not challenged; the identity provider is responsible for the
+// second factor there.
+//
+// Secrets are envelope encrypted with the org secrets keyring, so 2FA needs SECRETS_ENCRYPTION_KEYS.
+type TwoFactorService struct {
+	authService  *AuthService
+	repo         *repositories.TwoFactorRepository
+	orgRepo      *repositories.OrganizationRepository
+	auditLogRepo *repositories.AuditLogRepository
+	keyring      *envelope.Keyring // nil when SECRETS_ENCRYPTION_KEYS is unset
+	config       config.TwoFactorConfig
+}
+
+func NewTwoFactorService(
+	authService *AuthService,
+	repo *repositories.TwoFactorRepository,
+	orgRepo *repositories.OrganizationRepository,
+	auditLogRepo *repositories.AuditLogRepository,
+	keyring *envelope.Keyring,
+	cfg config.TwoFactorConfig,
+) *TwoFactorService {
+	return &TwoFactorService{
+		authService:  authService,
+		repo:         repo,
+		orgRepo:      orgRepo,
+		auditLogRepo: auditLogRepo,
+		keyring:      keyring,
+		config:       cfg,
+	}
+}
+
+// Enabled reports whether encryption keys are configured
+func (s *TwoFactorService) Enabled() bool {
+	return s.keyring != nil
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userID uuid.UUID) (*models.TwoFactorStatus, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.TwoFactorStatus{Required: s.orgRequires(ctx, user)}
+
+	t, _, err := s.repo.Get(ctx, user.ID)
+	if err == errors.ErrNotFound {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = t.ConfirmedAt != nil
+	status.EnabledAt = t.ConfirmedAt
+	status.Pending = t.ConfirmedAt == nil
+	if status.Enabled {
+		if status.BackupCodesRemaining, err = s.repo.CountBackupCodes(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Setup starts enrollment with a new secret, replacing one that was never confirmed. 2FA is not on
+// until Confirm proves the authenticator app has the secret.
+func (s *TwoFactorService) Setup(ctx context.Context, userID uuid.UUID) (*models.TwoFactorSetupResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.setup(ctx, user)
+}
+
+func (s *TwoFactorService) setup(ctx context.Context, user *models.User) (*models.TwoFactorSetupResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate two-factor secret", errors.ErrInternalServer.Status)
+	}
+	sealed, err := s.keyring.Seal([]byte(secret), totpAAD(user.ID))
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to encrypt two-factor secret", errors.ErrInternalServer.Status)
+	}
+	if err := s.repo.UpsertPending(ctx, user.ID, sealed); err != nil {
+		if err == errors.ErrConflict {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	otpauthURL := utils.TOTPURL(s.config.Issuer, user.Email, secret)
+	png, err := qrcode.Encode(otpauthURL, qrcode.Medium, 256)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to render QR code", errors.ErrInternalServer.Status)
+	}
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: otpauthURL,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Confirm turns 2FA on with a code from the authenticator app set up by Setup, and returns the
+// user's backup codes
+func (s *TwoFactorService) Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirm(ctx, user, code)
+}
+
+func (s *TwoFactorService) confirm(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if err := checkLocked(user); err != nil {
+		return nil, err
+	}
+
+	t, sealed, err := s.repo.Get(ctx, user.ID)
+	if err == errors.ErrNotFound {
+		return nil, ErrTwoFactorSetupRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := s.openSecret(ctx, user.ID, t, sealed)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, s.rejectCode(ctx, user)
+	}
+	if err := s.repo.Confirm(ctx, user.ID, step); err != nil {
+		if err == errors.ErrNotFound {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	codes, err := s.replaceBackupCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, user, "user.2fa_enabled", nil)
+	return codes, nil
+}
+
+// Disable turns 2FA off; it takes a current code (or a backup code), and is refused when the
+// user's org requires 2FA
+func (s *TwoFactorService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	if !s.Enabled() {
+		return ErrTwoFactorDisabled
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.orgRequires(ctx, user) {
+		return ErrTwoFactorRequired
+	}
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, user, "user.2fa_disabled", nil)
+	return nil
+}
+
+// RegenerateBackupCodes replaces the user's backup codes; it takes a current code (or a backup code)
+func (s *TwoFactorService) RegenerateBackupCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceBackupCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, user, "user.2fa_backup_codes_regenerated", nil)
+	return codes, nil
+}
+
+// challenge is called once a password or OTP sign-in passed its first factor. It returns the MFA
+// response the sign-in stops at, or nil when the user needs no second factor.
+func (s *TwoFactorService) challenge(ctx context.Context, user *models.User) (*models.LoginResponse, error) {
+	t, _, err := s.repo.Get(ctx, user.ID)
+	if err != nil && err != errors.ErrNotFound {
+		return nil, err
+	}
+
+	purpose := ""
+	switch {
+	case t != nil && t.ConfirmedAt != nil:
+		// Never wave a user with 2FA through because the keys are gone
+		if !s.Enabled() {
+			log.Printf("Login refused - %s has two-factor authentication but SECRETS_ENCRYPTION_KEYS is not set", user.Email)
+			return nil, ErrTwoFactorDisabled
+		}
+		purpose = auth.MFAPurposeVerify
+	case s.Enabled() && s.orgRequires(ctx, user):
+		purpose = auth.MFAPurposeEnroll
+	default:
+		return nil, nil
+	}
+
+	token, err := s.authService.tokenService.GenerateMFAToken(user.ID, purpose)
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate MFA token", errors.ErrInternalServer.Status)
+	}
+	return &models.LoginResponse{
+		MFARequired:           purpose == auth.MFAPurposeVerify,
+		MFAEnrollmentRequired: purpose == auth.MFAPurposeEnroll,
+		MFAToken:              token,
+	}, nil
+}
+
+// Verify completes a sign-in that stopped at mfa_required with a code from the authenticator app or
+// a backup code
+func (s *TwoFactorService) Verify(ctx context.Context, mfaToken, code, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.pendingUser(ctx, mfaToken, auth.MFAPurposeVerify)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		s.authService.recordLogin(ctx, models.LoginMethod2FA, user, ipAddress, userAgent, nil, err)
+		return nil, err
+	}
+
+	_ = s.authService.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+	response, err := s.authService.issueTokens(ctx, user, ipAddress, userAgent)
+	s.authService.recordLogin(ctx, models.LoginMethod2FA, user, ipAddress, userAgent, response, err)
+	return response, err
+}
+
+// Enroll starts the enrollment required at sign-in (see Setup)
+func (s *TwoFactorService) Enroll(ctx context.Context, mfaToken string) (*models.TwoFactorSetupResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.pendingUser(ctx, mfaToken, auth.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return s.setup(ctx, user)
+}
+
+// EnrollConfirm turns 2FA on (see Confirm) and completes the sign-in
+func (s *TwoFactorService) EnrollConfirm(ctx context.Context, mfaToken, code, ipAddress, userAgent string) (*models.TwoFactorEnrollResponse, error) {
+	if !s.Enabled() {
+		return nil, ErrTwoFactorDisabled
+	}
+	user, err := s.pendingUser(ctx, mfaToken, auth.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := s.confirm(ctx, user, code)
+	if err != nil {
+		s.authService.recordLogin(ctx, models.LoginMethod2FA, user, ipAddress, userAgent, nil, err)
+		return nil, err
+	}
+
+	_ = s.authService.userRepo.UpdateLoginInfo(ctx, user.ID, ipAddress)
+	response, err := s.authService.issueTokens(ctx, user, ipAddress, userAgent)
+	s.authService.recordLogin(ctx, models.LoginMethod2FA, user, ipAddress, userAgent, response, err)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrollResponse{LoginResponse: response, BackupCodes: codes}, nil
+}
+
+// pendingUser loads the user of an MFA token, who must still be allowed to sign in
+func (s *TwoFactorService) pendingUser(ctx context.Context, mfaToken, purpose string) (*models.User, error) {
+	claims, err := s.authService.tokenService.ValidateMFAToken(mfaToken)
+	if err != nil || claims.Purpose != purpose {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := s.authService.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if user.Status != "active" {
+		return nil, errors.NewError("ACCOUNT_INACTIVE", "Your account is not active. Please contact your administrator.", 403)
+	}
+	if err := checkLocked(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verifyCode checks a code from the user's authenticator app, or spends a backup code. Wrong codes
+// count as failed sign-ins, so guessing locks the account like guessing a password does.
+func (s *TwoFactorService) verifyCode(ctx context.Context, user *models.User, code string) error {
+	if err := checkLocked(user); err != nil {
+		return err
+	}
+
+	t, sealed, err := s.repo.Get(ctx, user.ID)
+	if err == errors.ErrNotFound || (err == nil && t.ConfirmedAt == nil) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	backupCode := utils.NormalizeBackupCode(code)
+	if len(backupCode) == 11 {
+		used, err := s.repo.UseBackupCode(ctx, user.ID, utils.HashToken(backupCode))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return s.rejectCode(ctx, user)
+		}
+		remaining, _ := s.repo.CountBackupCodes(ctx, user.ID)
+		s.audit(ctx, user, "user.2fa_backup_code_used", map[string]interface{}{"remaining": remaining})
+		return nil
+	}
+
+	secret, err := s.openSecret(ctx, user.ID, t, sealed)
+	if err != nil {
+		return err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return s.rejectCode(ctx, user)
+	}
+	// A code is good for one sign-in, even within its time step
+	fresh, err := s.repo.UseStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return s.rejectCode(ctx, user)
+	}
+	return nil
+}
+
+func (s *TwoFactorService) rejectCode(ctx context.Context, user *models.User) error {
+	log.Printf("Two-factor code rejected for user %s", user.Email)
+	s.authService.recordFailedLogin(ctx, user)
+	return ErrInvalidTwoFactorCode
+}
+
+// openSecret decrypts the user's TOTP secret, re-wrapping it under the current key-encryption key
+// when it was sealed under a retired one (as OrgSecretService.Resolve does)
+func (s *TwoFactorService) openSecret(ctx context.Context, userID uuid.UUID, t *models.UserTOTP, sealed *envelope.Sealed) (string, error) {
+	plaintext, err := s.keyring.Open(sealed, totpAAD(userID))
+	if err != nil {
+		log.Printf("Failed to decrypt TOTP secret of user %s (key %s): %v", userID, t.KeyID, err)
+		return "", errors.NewError("INTERNAL_ERROR", "Failed to decrypt two-factor secret", errors.ErrInternalServer.Status)
+	}
+
+	if sealed.KeyID != s.keyring.CurrentKeyID() {
+		if rewrapped, err := s.keyring.Rewrap(sealed); err == nil {
+			if err := s.repo.Rewrap(ctx, userID, rewrapped); err != nil {
+				log.Printf("Warning: Failed to rewrap TOTP secret of user %s: %v", userID, err)
+			}
+		}
+	}
+	return string(plaintext), nil
+}
+
+// replaceBackupCodes issues a new set of backup codes; only their hashes are kept
+func (s *TwoFactorService) replaceBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes, err := utils.GenerateBackupCodes()
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to generate backup codes", errors.ErrInternalServer.Status)
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	if err := s.repo.ReplaceBackupCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// orgRequires reports whether the user's org requires 2FA (settings.require_2fa)
+func (s *TwoFactorService) orgRequires(ctx context.Context, user *models.User) bool {
+	if user.OrgID == nil {
+		return false
+	}
+	org, err := s.orgRepo.GetByID(ctx, *user.OrgID)
+	if err != nil {
+		log.Printf("Warning: Failed to load org %s for its 2FA policy: %v", *user.OrgID, err)
+		return false
+	}
+	required, _ := org.Settings["require_2fa"].(bool)
+	return required
+}
+
+// totpAAD binds a sealed secret to its user, so it cannot be moved to another row
+func totpAAD(userID uuid.UUID) []byte {
+	return []byte("user_totp:" + userID.String())
+}
+
+func checkLocked(user *models.User) error {
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return errors.NewError("ACCOUNT_LOCKED", "Account is locked due to failed login attempts", 423)
+	}
+	return nil
+}
+
+func (s *TwoFactorService) audit(ctx context.Context, user *models.User, action string, metadata map[string]interface{}) {
+	resourceType := "user"
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["email"] = user.Email
+	if err := s.auditLogRepo.Create(ctx, &models.AuditLog{
+		UserID:       &user.ID,
+		OrgID:        user.OrgID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &user.ID,
+		Status:       "success",
+		Metadata:     metadata,
+	}); err != nil {
+		log.Printf("Warning: Failed to record %s for user %s: %v", action, user.ID, err)
+	}
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"saas-api/config"
+	"saas-api/internal/models"
+	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadSessionKeyPrefix is where chunks are stored, under the session ID, until completion
+	uploadSessionKeyPrefix = ".upload-sessions/"
+
+	defaultUploadSessionTTL = 24 // hours
+	defaultUploadChunkMB    = 64
+	uploadSessionBatchSize  = 100
+
+	// StatusChecksumMismatch is the tus status for a chunk that does not match its Upload-Checksum
+	StatusChecksumMismatch = 460
+)
+
+var (
+	ErrUploadSessionNotFound = errors.NewError("NOT_FOUND", "Upload session not found", http.StatusNotFound)
+	ErrUploadOffsetMismatch  = errors.NewError("CONFLICT", "Upload-Offset does not match the bytes received so far", http.StatusConflict)
+	ErrUploadSessionBusy     = errors.NewError("CONFLICT", "The upload is being completed", http.StatusConflict)
+	ErrUploadIncomplete      = errors.NewError("CONFLICT", "The upload has not received all its bytes yet", http.StatusConflict)
+	ErrUploadChecksum        = errors.NewError("CHECKSUM_MISMATCH", "The chunk does not match its Upload-Checksum", StatusChecksumMismatch)
+)
+
+// uploadSessionTTL is how long a session stays open after its last chunk
+func (s *DocumentService) uploadSessionTTL() time.Duration {
+	hours := s.Uploads.TTL
+	if hours <= 0 {
+		hours = defaultUploadSessionTTL
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// MaxUploadChunkBytes is the largest chunk AppendUploadChunk accepts
+func (s *DocumentService) MaxUploadChunkBytes() int64 {
+	mb := s.Uploads.MaxChunkMB
+	if mb <= 0 {
+		mb = defaultUploadChunkMB
+	}
+	return int64(mb) << 20
+}
+
+// CreateUploadSession opens a resumable upload of req.SizeBytes bytes, checked against the org's
+// storage quota up front. req.FilePath is the storage key the completed file is saved at, as for
+// StoreUpload.
+func (s *DocumentService) CreateUploadSession(ctx context.Context, req *UploadDocumentRequest) (*repositories.UploadSession, error) {
+	if req.SizeBytes <= 0 {
+		return nil, errors.NewError("VALIDATION_ERROR", "size_bytes must be a positive number of bytes", http.StatusBadRequest)
+	}
+	if err := s.checkStorageQuota(ctx, req.OrgID, req.SizeBytes); err != nil {
+		return nil, err
+	}
+	userUUID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	session := &repositories.UploadSession{
+		OrgID:     req.OrgID,
+		UserID:    userUUID,
+		Filename:  req.Filename,
+		FilePath:  req.FilePath,
+		Metadata:  req.Metadata,
+		SizeBytes: req.SizeBytes,
+		ExpiresAt: time.Now().Add(s.uploadSessionTTL()),
+	}
+	if req.FolderID != nil {
+		if folderUUID, err := uuid.Parse(*req.FolderID); err == nil {
+			session.FolderID = &folderUUID
+		}
+	}
+	if err := s.repositories.Document.CreateUploadSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetUploadSession returns the caller's open session. Sessions belong to the user who created them;
+// anyone else, like a client resuming an expired session, gets ErrUploadSessionNotFound.
+func (s *DocumentService) GetUploadSession(ctx context.Context, id, userID uuid.UUID) (*repositories.UploadSession, error) {
+	session, err := s.repositories.Document.GetUploadSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userID || session.ExpiresAt.Before(time.Now()) {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// AppendUploadChunk stores the chunk read from body at offset, which must be the bytes received so
+// far. checksum is the chunk's Upload-Checksum header, "sha256 <base64 digest>"; a chunk that does not
+// match it is discarded, so the client sends it again. Each chunk pushes the session's expiry back.
+func (s *DocumentService) AppendUploadChunk(ctx context.Context, id, userID uuid.UUID, offset int64, checksum string, body io.Reader) (*repositories.UploadSession, error) {
+	session, err := s.GetUploadSession(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != repositories.UploadSessionActive {
+		return nil, ErrUploadSessionBusy
+	}
+	if offset != session.OffsetBytes {
+		return nil, ErrUploadOffsetMismatch
+	}
+	digest, err := parseUploadChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "upload-chunk-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chunk: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	maxChunk := s.MaxUploadChunkBytes()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(body, maxChunk+1))
+	if err != nil {
+		return nil, errors.WrapError(err, "BAD_REQUEST", "Failed to read the chunk", http.StatusBadRequest)
+	}
+	if size == 0 {
+		return nil, errors.NewError("VALIDATION_ERROR", "The chunk is empty", http.StatusBadRequest)
+	}
+	if size > maxChunk {
+		return nil, errors.NewError("PAYLOAD_TOO_LARGE", fmt.Sprintf("Chunks may be at most %d bytes", maxChunk), http.StatusRequestEntityTooLarge)
+	}
+	if offset+size > session.SizeBytes {
+		return nil, errors.NewError("PAYLOAD_TOO_LARGE", "The chunk goes past the upload's size_bytes", http.StatusRequestEntityTooLarge)
+	}
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		return nil, ErrUploadChecksum
+	}
+
+	// A random suffix, so a losing concurrent write at the same offset cannot remove the winner's chunk
+	chunkKey := fmt.Sprintf("%s%s/%020d-%s", uploadSessionKeyPrefix, session.ID, offset, strings.ReplaceAll(uuid.NewString(), "-", ""))
+	if _, err = tmp.Seek(0, io.SeekStart); err == nil {
+		err = s.Storage.Put(ctx, chunkKey, tmp)
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to save chunk", errors.ErrInternalServer.Status)
+	}
+
+	expiresAt := time.Now().Add(s.uploadSessionTTL())
+	appended, err := s.repositories.Document.AppendUploadChunk(ctx, session.ID, offset, size, chunkKey, expiresAt)
+	if err != nil || !appended {
+		if deleteErr := s.Storage.Delete(ctx, chunkKey); deleteErr != nil {
+			fmt.Printf("Warning: Failed to remove rejected chunk %s: %v\n", chunkKey, deleteErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrUploadOffsetMismatch
+	}
+
+	session.OffsetBytes += size
+	session.ChunkKeys = append(session.ChunkKeys, chunkKey)
+	session.ExpiresAt = expiresAt
+	return session, nil
+}
+
+// CompleteUploadSession joins the chunks of a fully received session into its file, deduplicated by
+// StoreUpload, and creates the document as an upload does. The session is claimed first, so it is
+// completed once; when completion fails it reopens and the call may be retried.
+func (s *DocumentService) CompleteUploadSession(ctx context.Context, id, userID uuid.UUID) (*UploadDocumentResponse, error) {
+	session, err := s.GetUploadSession(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != repositories.UploadSessionActive {
+		return nil, ErrUploadSessionBusy
+	}
+	if session.OffsetBytes < session.SizeBytes {
+		return nil, ErrUploadIncomplete
+	}
+	claimed, err := s.repositories.Document.TransitionUploadSession(ctx, session.ID, repositories.UploadSessionActive, repositories.UploadSessionCompleting)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrUploadSessionBusy
+	}
+
+	response, err := s.ingestUploadSession(ctx, session)
+	if err != nil {
+		if _, reopenErr := s.repositories.Document.TransitionUploadSession(ctx, session.ID, repositories.UploadSessionCompleting, repositories.UploadSessionActive); reopenErr != nil {
+			fmt.Printf("⚠️  Failed to reopen upload session %s: %v\n", session.ID, reopenErr)
+		}
+		return nil, err
+	}
+
+	s.removeUploadSession(ctx, session)
+	return response, nil
+}
+
+// ingestUploadSession stores the joined chunks and creates the document
+func (s *DocumentService) ingestUploadSession(ctx context.Context, session *repositories.UploadSession) (*UploadDocumentResponse, error) {
+	chunks := &chunkReader{ctx: ctx, store: s.Storage, keys: session.ChunkKeys}
+	stored, err := s.StoreUpload(ctx, session.OrgID, chunks, session.FilePath)
+	chunks.Close()
+	if err != nil {
+		return nil, errors.WrapError(err, "INTERNAL_ERROR", "Failed to save file", errors.ErrInternalServer.Status)
+	}
+	if stored.SizeBytes != session.SizeBytes {
+		// A chunk went missing from storage; the client has to upload the file again
+		s.releaseBlob(ctx, session.OrgID, stored.Checksum)
+		return nil, errors.NewError("INTERNAL_ERROR", fmt.Sprintf("The stored chunks hold %d of %d bytes", stored.SizeBytes, session.SizeBytes), errors.ErrInternalServer.Status)
+	}
+
+	req := &UploadDocumentRequest{
+		UserID:    session.UserID.String(),
+		OrgID:     session.OrgID,
+		FilePath:  stored.FilePath,
+		Filename:  session.Filename,
+		Checksum:  stored.Checksum,
+		SizeBytes: stored.SizeBytes,
+		Metadata:  session.Metadata,
+	}
+	if session.FolderID != nil {
+		folderID := session.FolderID.String()
+		req.FolderID = &folderID
+	}
+	return s.UploadDocument(ctx, req)
+}
+
+// CancelUploadSession abandons the caller's session and deletes the chunks received
+func (s *DocumentService) CancelUploadSession(ctx context.Context, id, userID uuid.UUID) error {
+	session, err := s.GetUploadSession(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	// Claimed like a completion, so no chunk lands after the chunks are deleted
+	claimed, err := s.repositories.Document.TransitionUploadSession(ctx, session.ID, repositories.UploadSessionActive, repositories.UploadSessionCompleting)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrUploadSessionBusy
+	}
+	s.removeUploadSession(ctx, session)
+	return nil
+}
+
+// removeUploadSession deletes a session's chunks and then the session
+func (s *DocumentService) removeUploadSession(ctx context.Context, session *repositories.UploadSession) {
+	removeUploadSession(ctx, s.Storage, s.repositories.Document, session)
+}
+
+func removeUploadSession(ctx context.Context, store storage.Storage, docRepo *repositories.DocumentRepository, session *repositories.UploadSession) error {
+	for _, key := range session.ChunkKeys {
+		if err := store.Delete(ctx, key); err != nil {
+			fmt.Printf("Warning: Failed to remove upload chunk %s: %v\n", key, err)
+		}
+	}
+	if err := docRepo.DeleteUploadSession(ctx, session.ID); err != nil {
+		fmt.Printf("Warning: Failed to remove upload session %s: %v\n", session.ID, err)
+		return err
+	}
+	return nil
+}
+
+// parseUploadChecksum decodes an Upload-Checksum header, "<algorithm> <base64 digest>"; only sha256
+// is supported
+func parseUploadChecksum(header string) ([]byte, error) {
+	if header == "" {
+		return nil, errors.NewError("VALIDATION_ERROR", "Upload-Checksum is required (sha256 <base64 digest>)", http.StatusBadRequest)
+	}
+	algorithm, encoded, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if !strings.EqualFold(algorithm, "sha256") {
+		return nil, errors.NewError("BAD_REQUEST", fmt.Sprintf("Unsupported checksum algorithm %q (want sha256)", algorithm), http.StatusBadRequest)
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(digest) != sha256.Size {
+		return nil, errors.NewError("VALIDATION_ERROR", "Upload-Checksum must hold a base64 SHA-256 digest", http.StatusBadRequest)
+	}
+	return digest, nil
+}
+
+// chunkReader reads stored chunks one after another, opening each only once the previous is read
+type chunkReader struct {
+	ctx   context.Context
+	store storage.Storage
+	keys  []string
+	cur   io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			chunk, err := r.store.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to read upload chunk %s: %w", r.keys[0], err)
+			}
+			r.cur = chunk
+			r.keys = r.keys[1:]
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
+
+// UploadSessionCleanupJob periodically removes expired upload sessions with their chunks: uploads
+// abandoned by their clients, and completions that died with their process.
+type UploadSessionCleanupJob struct {
+	docRepo *repositories.DocumentRepository
+	storage storage.Storage
+	cfg     config.UploadSessionConfig
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewUploadSessionCleanupJob(docRepo *repositories.DocumentRepository, store storage.Storage, cfg config.UploadSessionConfig) *UploadSessionCleanupJob {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultUploadSessionTTL
+	}
+	return &UploadSessionCleanupJob{
+		docRepo: docRepo,
+		storage: store,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start launches the background loop; it is a no-op when SweepInterval is 0
+func (j *UploadSessionCleanupJob) Start() {
+	if j.cfg.SweepInterval <= 0 {
+		log.Println("Upload session cleanup disabled (UPLOAD_SESSION_SWEEP_INTERVAL=0)")
+		close(j.done)
+		return
+	}
+
+	log.Printf("Upload session cleanup enabled: sessions idle for %d hours removed every %d minutes", j.cfg.TTL, j.cfg.SweepInterval)
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(time.Duration(j.cfg.SweepInterval) * time.Minute)
+		defer ticker.Stop()
+
+		j.RunOnce(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce(context.Background())
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop
+func (j *UploadSessionCleanupJob) Stop() {
+	select {
+	case <-j.done:
+		return
+	default:
+	}
+	close(j.stop)
+	<-j.done
+}
+
+// RunOnce removes the sessions expired so far and returns how many it removed. A completion that
+// has been running for a whole TTL is taken to have died.
+func (j *UploadSessionCleanupJob) RunOnce(ctx context.Context) int {
+	now := time.Now()
+	staleBefore := now.Add(-time.Duration(j.cfg.TTL) * time.Hour)
+
+	removed := 0
+	for {
+		sessions, err := j.docRepo.ListExpiredUploadSessions(ctx, now, staleBefore, uploadSessionBatchSize)
+		if err != nil {
+			log.Printf("Upload session cleanup: failed to list expired sessions: %v", err)
+			break
+		}
+		for _, session := range sessions {
+			if session.Status == repositories.UploadSessionActive {
+				// Claimed, so a chunk arriving now cannot land after the chunks are deleted
+				claimed, err := j.docRepo.TransitionUploadSession(ctx, session.ID, repositories.UploadSessionActive, repositories.UploadSessionCompleting)
+				if err != nil {
+					log.Printf("Upload session cleanup: failed to claim session %s: %v", session.ID, err)
+					return removed
+				}
+				if !claimed {
+					continue // Being completed or cancelled right now
+				}
+			}
+			if err := removeUploadSession(ctx, j.storage, j.docRepo, session); err != nil {
+				// Leave the rest for the next sweep rather than listing this session again
+				return removed
+			}
+			removed++
+		}
+		if len(sessions) < uploadSessionBatchSize {
+			break
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Upload session cleanup: removed %d expired upload sessions", removed)
+	}
+	return removed
+}
+
+// Schedule describes the job for an organization in the given timezone
+func (j *UploadSessionCleanupJob) Schedule(now time.Time, loc *time.Location) []models.ScheduledJob {
+	return []models.ScheduledJob{{
+		Name:            "upload_session_cleanup",
+		Enabled:         j.cfg.SweepInterval > 0,
+		IntervalMinutes: j.cfg.SweepInterval,
+		Description:     fmt.Sprintf("Resumable uploads idle for %d hours are removed with their chunks", j.cfg.TTL),
+	}}
+}
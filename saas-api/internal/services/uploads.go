@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// StoredUpload is where an uploaded file's bytes ended up
+type StoredUpload struct {
+	FilePath  string // Storage key; the existing copy's key for a duplicate
+	Checksum  string // Hex SHA-256
+	SizeBytes int64
+	Duplicate bool // The org already had these bytes; no new file was written
+}
+
+// StoreUpload saves an upload at filePath (a key in s.Storage), hashing it on the way. The upload is
+// spooled to a temporary file first, as the checksum decides where it goes. When the org already has
+// a file with the same checksum the new copy is discarded and the existing blob gains a reference,
+// so every document with that content shares one file.
+func (s *DocumentService) StoreUpload(ctx context.Context, orgID *uuid.UUID, src io.Reader, filePath string) (*StoredUpload, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	// Never overwrite a file that already exists there: it may be another blob's bytes
+	exists, err := s.Storage.Exists(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if exists {
+		filePath = checksumSuffixed(filePath, checksum)
+	}
+
+	blob, created, err := s.repositories.Document.AcquireBlob(ctx, orgID, checksum, filePath, size)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		exists, err := s.Storage.Exists(ctx, blob.StoragePath)
+		if err == nil && exists {
+			fmt.Printf("♻️  Upload is a duplicate of %s (%d references)\n", blob.StoragePath, blob.RefCount)
+			return &StoredUpload{FilePath: blob.StoragePath, Checksum: checksum, SizeBytes: size, Duplicate: true}, nil
+		}
+		// The shared file went missing; this upload restores it
+		fmt.Printf("⚠️  Blob file %s is missing, restoring it from this upload\n", blob.StoragePath)
+		filePath = blob.StoragePath
+	}
+
+	if _, err = tmp.Seek(0, io.SeekStart); err == nil {
+		err = s.Storage.Put(ctx, filePath, tmp)
+	}
+	if err != nil {
+		s.releaseBlob(ctx, orgID, checksum)
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return &StoredUpload{FilePath: filePath, Checksum: checksum, SizeBytes: size}, nil
+}
+
+// releaseBlob drops a document's reference on its deduplicated bytes, removing the file once no
+// document uses it. Documents uploaded before deduplication have no blob and are left alone.
+func (s *DocumentService) releaseBlob(ctx context.Context, orgID *uuid.UUID, checksum string) {
+	blob, deleted, err := s.repositories.Document.ReleaseBlob(ctx, orgID, checksum)
+	if err != nil {
+		fmt.Printf("Warning: Failed to release document blob %s: %v\n", checksum, err)
+		return
+	}
+	if blob == nil || !deleted {
+		return
+	}
+
+	if err := s.Storage.Delete(ctx, blob.StoragePath); err != nil {
+		fmt.Printf("Warning: Failed to remove blob file %s: %v\n", blob.StoragePath, err)
+		return
+	}
+	fmt.Printf("Removed blob file %s (no documents left)\n", blob.StoragePath)
+}
+
+// releaseUpload gives back the blob reference taken by StoreUpload when no document was created for it
+func (s *DocumentService) releaseUpload(ctx context.Context, req *UploadDocumentRequest) {
+	if req.Checksum != "" {
+		s.releaseBlob(ctx, req.OrgID, req.Checksum)
+	}
+}
+
+// ProcessedDuplicate is what new content can reuse from an identical document already processed
+type ProcessedDuplicate struct {
+	ChunksFile string // Copied instead of running the Python pipeline
+	DocumentID int64  // Its Weaviate objects are copied with their vectors instead of embedding the chunks
+}
+
+// processedDuplicate finds a document in the org, other than excludeID, with this checksum whose
+// processing output the new content can reuse. The zero value when there is none.
+func (s *DocumentService) processedDuplicate(ctx context.Context, orgID *uuid.UUID, checksum string, excludeID int64) ProcessedDuplicate {
+	if checksum == "" {
+		return ProcessedDuplicate{}
+	}
+	source, err := s.repositories.Document.GetProcessedByChecksum(ctx, orgID, checksum, excludeID)
+	if err != nil {
+		fmt.Printf("⚠️  Failed to look up processed duplicates: %v\n", err)
+		return ProcessedDuplicate{}
+	}
+	if source == nil || source.JsonFilePath == nil {
+		return ProcessedDuplicate{}
+	}
+	return ProcessedDuplicate{ChunksFile: *source.JsonFilePath, DocumentID: source.ID}
+}
+
+// checksumSuffixed inserts a checksum prefix before the extension: report.pdf -> report-1a2b3c4d5e6f.pdf
+func checksumSuffixed(filePath, checksum string) string {
+	ext := path.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "-" + checksum[:12] + ext
+}
@@ -3,24 +3,35 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"saas-api/cmd/defines"
 	"saas-api/internal/repositories"
+	"saas-api/pkg/errors"
+	"saas-api/pkg/storage"
+	"saas-api/pkg/tenant"
 	"saas-api/pkg/weaviate"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	fylogger "github.com/FyersDev/trading-logger-go"
 )
 
 // DocumentJob represents a document processing job
 type DocumentJob struct {
 	ID           int64
-	FilePath     string
+	FilePath     string // Storage key of the uploaded file
 	JsonFilePath string
+	ReuseChunks  string // Chunks file of an identical, already processed document; skips the Python pipeline
+	ReuseVectors int64  // Identical, already embedded document whose Weaviate objects are copied; skips embedding
+	Version      int    // Set for a content replacement: embedded into the version's classes, live once done
 	FolderID     *string
 	Metadata     map[string]interface{}
+	Tenant       tenant.Info // Org/user the document was uploaded by, attached to Weaviate calls
 	Status       defines.JobStatus
 	Error        error
 	CreatedAt    time.Time
@@ -35,6 +46,9 @@ type DocumentWorkerPool struct {
 	jobsMu         sync.RWMutex
 	weaviateClient *weaviate.WeaviateClient
 	documentRepo   *repositories.DocumentRepository
+	storage        storage.Storage // Where job.FilePath is; set by NewDocumentService and SetStorage
+	secrets        SecretResolver  // Optional: per-org credentials passed to the Python pipeline
+	versions       versionFinisher // Completes content replacements; set by NewDocumentService
 	workerCount    int
 	wg             sync.WaitGroup
 	ctx            context.Context
@@ -76,6 +90,24 @@ func NewDocumentWorkerPool(weaviateClient *weaviate.WeaviateClient, documentRepo
 	return pool
 }
 
+// versionFinisher makes a replacement version the document's content once it is embedded, or cleans
+// up after it failed. DocumentService implements it.
+type versionFinisher interface {
+	activateVersion(ctx context.Context, job *DocumentJob) error
+	failVersion(ctx context.Context, job *DocumentJob, err error)
+}
+
+// orgSecretEnv maps org secret names to the environment variables the Python pipeline reads them from
+var orgSecretEnv = map[string]string{
+	"openai_api_key": "OPENAI_API_KEY",
+}
+
+// SetSecretResolver lets jobs use their org's own credentials (see orgSecretEnv) instead of the
+// server-wide environment. Must be called before jobs are submitted.
+func (p *DocumentWorkerPool) SetSecretResolver(secrets SecretResolver) {
+	p.secrets = secrets
+}
+
 // Start initializes and starts all workers
 func (p *DocumentWorkerPool) Start() {
 	for i := 0; i < p.workerCount; i++ {
@@ -122,38 +154,22 @@ func (p *DocumentWorkerPool) processJob(job *DocumentJob, workerID int) {
 	p.updateJobStatus(job.ID, defines.JobStatusProcessing, nil)
 	job.StartedAt = &now
 
-	// Use virtual environment's Python to ensure all dependencies are available
-	// When running from cmd/api, we need to go up to the saas-api root
-	cmd := exec.Command("python", "../docling/document_process.py", job.FilePath, job.JsonFilePath)
-
-	// Capture both stdout and stderr to see what's happening
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		// Log the actual Python error output
-		errorDetails := fmt.Sprintf("Python stderr: %s, Python stdout: %s", stderr.String(), stdout.String())
-		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to process document. %s", workerID, errorDetails), err, nil)
-		p.updateJobStatus(job.ID, defines.JobStatusFailed, fmt.Errorf("%s: %w", errorDetails, err))
+	// Duplicates of an already processed document copy its chunks instead of running Python again
+	if !p.reuseChunks(job, workerID) && !p.runPipeline(job, workerID) {
 		return
 	}
 
-	// Log successful processing output if any
-	if stdout.Len() > 0 {
-		fylogger.InfoLog(p.ctx, fmt.Sprintf("Worker %d: Python output: %s", workerID, stdout.String()), nil)
+	// Cache a text preview for list responses (include=snippet); a failure only loses the preview.
+	// A replacement's preview is cached when it goes live.
+	if job.Version == 0 {
+		p.cacheSnippet(job, workerID)
 	}
 
 	// Mark as embedding (document processing complete, starting vectorization)
 	p.updateJobStatus(job.ID, defines.JobStatusEmbedding, nil)
 
 	// Populate Weaviate with chunks
-	err := p.weaviateClient.PopulateFromMarkdownChunks(
-		p.ctx,
-		job.JsonFilePath,
-		weaviate.DefaultPopulateConfig(),
-		job.ID,
-	)
+	err := p.populate(tenant.WithTenant(p.ctx, job.Tenant), job)
 
 	if err != nil {
 		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to populate Weaviate", workerID), err, nil)
@@ -161,6 +177,15 @@ func (p *DocumentWorkerPool) processJob(job *DocumentJob, workerID int) {
 		return
 	}
 
+	// A replacement switches search over to its classes before the job counts as completed
+	if job.Version > 0 && p.versions != nil {
+		if err := p.versions.activateVersion(tenant.WithTenant(p.ctx, job.Tenant), job); err != nil {
+			fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to activate version %d of document %d", workerID, job.Version, job.ID), err, nil)
+			p.updateJobStatus(job.ID, defines.JobStatusFailed, err)
+			return
+		}
+	}
+
 	// Mark as completed (embedding done)
 	completedAt := time.Now()
 	job.CompletedAt = &completedAt
@@ -169,18 +194,202 @@ func (p *DocumentWorkerPool) processJob(job *DocumentJob, workerID int) {
 	fylogger.InfoLog(p.ctx, fmt.Sprintf("Worker %d: Job %d completed successfully", workerID, job.ID), nil)
 }
 
-// SubmitJob adds a new job to the queue (ID must be pre-assigned from database)
-func (p *DocumentWorkerPool) SubmitJob(documentID int64, filePath, jsonFilePath string, folderID *string, metadata map[string]interface{}) (*DocumentJob, error) {
-	job := &DocumentJob{
+// populate loads the job's chunks into Weaviate: the document's live classes, or for a replacement
+// the version's own classes (emptied first, in case an earlier attempt left some behind)
+func (p *DocumentWorkerPool) populate(ctx context.Context, job *DocumentJob) error {
+	if p.copyVectors(ctx, job) {
+		return nil
+	}
+	if job.Version == 0 {
+		return p.weaviateClient.PopulateFromMarkdownChunks(ctx, job.JsonFilePath, weaviate.DefaultPopulateConfig(), job.ID)
+	}
+
+	data, err := os.ReadFile(job.JsonFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read chunks: %w", err)
+	}
+	var chunks []weaviate.Chunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return fmt.Errorf("failed to decode chunks: %w", err)
+	}
+
+	textClass, tableClass := contentVersionClasses(job.ID, job.Version)
+	if err := p.weaviateClient.DeleteClasses(ctx, textClass, tableClass); err != nil {
+		return err
+	}
+	return p.weaviateClient.InsertChunksIntoClasses(ctx, chunks, weaviate.DefaultPopulateConfig(), job.ID, textClass, tableClass)
+}
+
+// copyVectors copies the Weaviate objects of job.ReuseVectors, vectors included, into the classes
+// populate would fill. Returns false when there is nothing to copy or the copy failed, after
+// clearing what was copied, so the chunks are embedded as usual.
+func (p *DocumentWorkerPool) copyVectors(ctx context.Context, job *DocumentJob) bool {
+	if job.ReuseVectors == 0 {
+		return false
+	}
+	textClass, tableClass := weaviate.DocumentClassNames(job.ID, "")
+	if job.Version > 0 {
+		textClass, tableClass = contentVersionClasses(job.ID, job.Version)
+	}
+	if err := p.weaviateClient.DeleteClasses(ctx, textClass, tableClass); err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Failed to clear classes of document %d before copying embeddings", job.ID), err, nil)
+		return false
+	}
+
+	copied, err := p.weaviateClient.CopyDocumentObjects(ctx, job.ReuseVectors, textClass, tableClass)
+	if err == nil && copied > 0 {
+		fylogger.InfoLog(p.ctx, fmt.Sprintf("Job %d copied %d embedded chunks from document %d", job.ID, copied, job.ReuseVectors), nil)
+		return true
+	}
+	if err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Failed to copy embeddings from document %d, embedding document %d instead", job.ReuseVectors, job.ID), err, nil)
+	}
+	if err := p.weaviateClient.DeleteClasses(ctx, textClass, tableClass); err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Failed to clear copied embeddings of document %d", job.ID), err, nil)
+	}
+	return false
+}
+
+// pipelineScript is the live document processor. When running from cmd/api, we need to go up to
+// the saas-api root.
+const pipelineScript = "../docling/document_process.py"
+
+// runPipeline runs the Python document processor, which writes the chunks file. On failure the job
+// is marked failed and false is returned.
+func (p *DocumentWorkerPool) runPipeline(job *DocumentJob, workerID int) bool {
+	output, err := p.execPipeline(pipelineScript, job, workerID)
+	if err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to process document", workerID), err, nil)
+		p.updateJobStatus(job.ID, defines.JobStatusFailed, err)
+		return false
+	}
+
+	// Log successful processing output if any
+	if output != "" {
+		fylogger.InfoLog(p.ctx, fmt.Sprintf("Worker %d: Python output: %s", workerID, output), nil)
+	}
+	return true
+}
+
+// execPipeline runs a Python processor script on job.FilePath, writing chunks to job.JsonFilePath.
+// The script reads files from disk, so files on remote storage are downloaded for the run. It
+// returns the script's stdout; on failure the error carries both stdout and stderr.
+func (p *DocumentWorkerPool) execPipeline(script string, job *DocumentJob, workerID int) (string, error) {
+	filePath, cleanup, err := storage.LocalFile(p.ctx, p.storage, job.FilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s from storage: %w", job.FilePath, err)
+	}
+	defer cleanup()
+
+	// Use virtual environment's Python to ensure all dependencies are available
+	cmd := exec.Command("python", script, filePath, job.JsonFilePath)
+	cmd.Env = p.jobEnv(job, workerID)
+
+	// Capture both stdout and stderr to see what's happening
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		errorDetails := fmt.Sprintf("Python stderr: %s, Python stdout: %s", stderr.String(), stdout.String())
+		return "", fmt.Errorf("%s: %w", errorDetails, err)
+	}
+	return stdout.String(), nil
+}
+
+// reuseChunks copies job.ReuseChunks (possibly compressed by the artifact lifecycle) to the job's
+// own chunks file. Returns false when there is nothing to reuse, so the document is processed normally.
+func (p *DocumentWorkerPool) reuseChunks(job *DocumentJob, workerID int) bool {
+	if job.ReuseChunks == "" {
+		return false
+	}
+	data, err := readArtifact(job.ReuseChunks)
+	if err == nil {
+		err = os.WriteFile(job.JsonFilePath, data, 0644)
+	}
+	if err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to reuse chunks from %s, processing document %d instead", workerID, job.ReuseChunks, job.ID), err, nil)
+		return false
+	}
+
+	fylogger.InfoLog(p.ctx, fmt.Sprintf("Worker %d: Job %d reused chunks from %s", workerID, job.ID, job.ReuseChunks), nil)
+	return true
+}
+
+// jobEnv is the server environment with the job's org secrets layered on top. Orgs without a given
+// secret fall back to the server-wide value.
+func (p *DocumentWorkerPool) jobEnv(job *DocumentJob, workerID int) []string {
+	env := os.Environ()
+	if p.secrets == nil || job.Tenant.OrgID == "" {
+		return env
+	}
+	orgID, err := uuid.Parse(job.Tenant.OrgID)
+	if err != nil {
+		return env
+	}
+
+	for name, envVar := range orgSecretEnv {
+		value, err := p.secrets.Resolve(p.ctx, orgID, name)
+		if err != nil {
+			if err != errors.ErrNotFound {
+				fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to resolve org secret %s for document %d", workerID, name, job.ID), err, nil)
+			}
+			continue
+		}
+		env = append(env, envVar+"="+value)
+	}
+	return env
+}
+
+// cacheSnippet stores the document's preview text in content.processing_data
+func (p *DocumentWorkerPool) cacheSnippet(job *DocumentJob, workerID int) {
+	if p.documentRepo == nil {
+		return
+	}
+	snippet, err := ExtractSnippet(job.JsonFilePath)
+	if err == nil {
+		err = p.documentRepo.SetProcessingData(p.ctx, job.ID, snippetProcessingKey, snippet)
+	}
+	if err != nil {
+		fylogger.ErrorLog(p.ctx, fmt.Sprintf("Worker %d: Failed to cache snippet for document %d", workerID, job.ID), err, nil)
+	}
+}
+
+// SubmitJob adds a new job to the queue (ID must be pre-assigned from database). duplicate is
+// optional: a document with identical content whose chunks and embeddings are copied instead of
+// re-processing.
+func (p *DocumentWorkerPool) SubmitJob(ctx context.Context, documentID int64, filePath, jsonFilePath string, duplicate ProcessedDuplicate, folderID *string, metadata map[string]interface{}) (*DocumentJob, error) {
+	return p.submit(&DocumentJob{
 		ID:           documentID,
 		FilePath:     filePath,
 		JsonFilePath: jsonFilePath,
+		ReuseChunks:  duplicate.ChunksFile,
+		ReuseVectors: duplicate.DocumentID,
 		FolderID:     folderID,
 		Metadata:     metadata,
+		Tenant:       tenant.FromContext(ctx),
 		Status:       defines.JobStatusPending,
 		CreatedAt:    time.Now(),
-	}
+	})
+}
 
+// SubmitVersionJob queues the processing of a replacement version of a document's content. The
+// document keeps serving its current content until the job completes.
+func (p *DocumentWorkerPool) SubmitVersionJob(ctx context.Context, documentID int64, version int, filePath, jsonFilePath string, duplicate ProcessedDuplicate) (*DocumentJob, error) {
+	return p.submit(&DocumentJob{
+		ID:           documentID,
+		FilePath:     filePath,
+		JsonFilePath: jsonFilePath,
+		ReuseChunks:  duplicate.ChunksFile,
+		ReuseVectors: duplicate.DocumentID,
+		Version:      version,
+		Tenant:       tenant.FromContext(ctx),
+		Status:       defines.JobStatusPending,
+		CreatedAt:    time.Now(),
+	})
+}
+
+func (p *DocumentWorkerPool) submit(job *DocumentJob) (*DocumentJob, error) {
 	// Store job for status tracking
 	p.jobsMu.Lock()
 	p.jobs[job.ID] = job
@@ -218,6 +427,7 @@ func (p *DocumentWorkerPool) updateJobStatus(jobID int64, status defines.JobStat
 	p.jobsMu.Lock()
 	defer p.jobsMu.Unlock()
 
+	version := 0
 	if job, exists := p.jobs[jobID]; exists {
 		job.Status = status
 		job.Error = err
@@ -225,6 +435,13 @@ func (p *DocumentWorkerPool) updateJobStatus(jobID int64, status defines.JobStat
 			now := time.Now()
 			job.CompletedAt = &now
 		}
+		version = job.Version
+	}
+
+	// A replacement reports to its version; the document row keeps describing the live content
+	if version > 0 {
+		p.updateVersionStatus(p.jobs[jobID], status, err)
+		return
 	}
 
 	// Update status in database
@@ -314,6 +531,24 @@ func (p *DocumentWorkerPool) updateJobStatus(jobID int64, status defines.JobStat
 	}
 }
 
+// updateVersionStatus records a replacement job's progress on its document version. Completion is
+// recorded by activateVersion.
+func (p *DocumentWorkerPool) updateVersionStatus(job *DocumentJob, status defines.JobStatus, err error) {
+	switch status {
+	case defines.JobStatusProcessing, defines.JobStatusEmbedding:
+		if p.documentRepo == nil {
+			return
+		}
+		if updateErr := p.documentRepo.SetVersionStatus(p.ctx, job.ID, job.Version, string(status), nil); updateErr != nil {
+			fmt.Printf("Failed to update version %d of document %d to %s: %v\n", job.Version, job.ID, status, updateErr)
+		}
+	case defines.JobStatusFailed:
+		if p.versions != nil {
+			p.versions.failVersion(tenant.WithTenant(p.ctx, job.Tenant), job, err)
+		}
+	}
+}
+
 // GetAllJobs returns all jobs (for monitoring/debugging)
 func (p *DocumentWorkerPool) GetAllJobs() []*DocumentJob {
 	p.jobsMu.RLock()
@@ -0,0 +1,25 @@
+-- Migration: Create api_usage_rollups table
+-- Per-endpoint request counts, errors and latency histograms bucketed by hour and org
+
+CREATE TABLE IF NOT EXISTS api_usage_rollups (
+    id BIGSERIAL PRIMARY KEY,
+    bucket_start TIMESTAMP NOT NULL,
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(512) NOT NULL,
+    request_count BIGINT NOT NULL DEFAULT 0,
+    error_count BIGINT NOT NULL DEFAULT 0,
+    total_latency_ms BIGINT NOT NULL DEFAULT 0,
+    max_latency_ms BIGINT NOT NULL DEFAULT 0,
+    latency_buckets BIGINT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+-- NULL org_id (super admin / anonymous traffic) must still collapse into a single row per bucket
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_usage_rollups_bucket
+ON api_usage_rollups(bucket_start, COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid), method, route);
+
+CREATE INDEX IF NOT EXISTS idx_api_usage_rollups_org_bucket ON api_usage_rollups(org_id, bucket_start DESC);
+
+COMMENT ON TABLE api_usage_rollups IS 'Hourly API usage rollups per endpoint and organization';
+COMMENT ON COLUMN api_usage_rollups.latency_buckets IS 'Request counts per latency histogram bucket (5,10,25,50,100,250,500,1000,2500,5000,10000ms, overflow)';
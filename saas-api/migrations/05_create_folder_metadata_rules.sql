@@ -0,0 +1,19 @@
+-- Migration: Create folder_metadata_rules table
+-- Default metadata and auto-tags applied to documents uploaded into a folder
+
+CREATE TABLE IF NOT EXISTS folder_metadata_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    folder_id UUID NOT NULL UNIQUE REFERENCES folders(id) ON DELETE CASCADE,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    default_metadata JSONB NOT NULL DEFAULT '{}',
+    apply_to_subfolders BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+COMMENT ON TABLE folder_metadata_rules IS 'Per-folder auto-tagging and default metadata rules applied at document upload';
+COMMENT ON COLUMN folder_metadata_rules.tags IS 'Tags merged into document metadata.tags';
+COMMENT ON COLUMN folder_metadata_rules.default_metadata IS 'Key/value defaults; explicit upload metadata takes precedence';
+COMMENT ON COLUMN folder_metadata_rules.apply_to_subfolders IS 'Whether the rule is inherited by descendant folders';
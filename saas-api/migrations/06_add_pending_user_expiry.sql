@@ -0,0 +1,10 @@
+-- Migration: Track who invited a user and when a pending invitation expired
+-- Supports the stale pending-user expiry job
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invited_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_expired_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_pending_created_at ON users(created_at) WHERE status = 'pending';
+
+COMMENT ON COLUMN users.invited_by IS 'Admin who created the user; notified when the pending invitation expires';
+COMMENT ON COLUMN users.pending_expired_at IS 'Set by the pending-user expiry job when a pending user is flagged as expired';
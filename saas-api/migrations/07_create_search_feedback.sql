@@ -0,0 +1,27 @@
+-- Migration: Create search_feedback table
+-- Helpful/unhelpful votes on chunks returned by document search, used to tune retrieval scoring
+
+CREATE TABLE IF NOT EXISTS search_feedback (
+    id BIGSERIAL PRIMARY KEY,
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id BIGINT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    chunk_id VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    query_id VARCHAR(255),
+    helpful BOOLEAN NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+-- One vote per user, chunk and query; voting again replaces the previous vote
+CREATE UNIQUE INDEX IF NOT EXISTS idx_search_feedback_vote
+ON search_feedback(user_id, document_id, chunk_id, md5(query));
+
+CREATE INDEX IF NOT EXISTS idx_search_feedback_document ON search_feedback(document_id, chunk_id);
+CREATE INDEX IF NOT EXISTS idx_search_feedback_org_created ON search_feedback(org_id, created_at DESC);
+
+COMMENT ON TABLE search_feedback IS 'User feedback on search result chunks (helpful / unhelpful per query)';
+COMMENT ON COLUMN search_feedback.chunk_id IS 'Weaviate chunk ID as returned by GET /api/v1/documents/search';
+COMMENT ON COLUMN search_feedback.query_id IS 'Optional caller-side ID (e.g. chat message) to group votes for one search';
@@ -0,0 +1,30 @@
+-- Migration: Create org_secrets table
+-- Per-organization integration credentials (e.g. an org's own OpenAI key, webhook secrets).
+-- Values are envelope-encrypted by saas-api (pkg/envelope); only ciphertext is stored here.
+
+CREATE TABLE IF NOT EXISTS org_secrets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    key_id VARCHAR(64) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    version INTEGER DEFAULT 1 NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    rotated_at TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_secrets_org_name ON org_secrets(org_id, name);
+CREATE INDEX IF NOT EXISTS idx_org_secrets_key_id ON org_secrets(key_id);
+
+COMMENT ON TABLE org_secrets IS 'Encrypted per-organization integration credentials; values are never returned by the API';
+COMMENT ON COLUMN org_secrets.name IS 'Lowercase identifier, e.g. openai_api_key';
+COMMENT ON COLUMN org_secrets.key_id IS 'ID of the key-encryption key (SECRETS_ENCRYPTION_KEYS) that wrapped the data key';
+COMMENT ON COLUMN org_secrets.wrapped_key IS 'AES-256-GCM data key, encrypted with the key-encryption key';
+COMMENT ON COLUMN org_secrets.ciphertext IS 'AES-256-GCM value, encrypted with the data key and bound to org_id and name';
+COMMENT ON COLUMN org_secrets.version IS 'Incremented on each rotation';
@@ -0,0 +1,27 @@
+-- Migration: Create document_blobs table
+-- Upload deduplication: the bytes of an uploaded file are stored once per organization and shared by
+-- every document with the same SHA-256 checksum (content->>'checksum'). ref_count is the number of
+-- documents using the blob; saas-api removes the file when the last one is deleted.
+
+CREATE TABLE IF NOT EXISTS document_blobs (
+    id BIGSERIAL PRIMARY KEY,
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    checksum VARCHAR(64) NOT NULL,
+    storage_path VARCHAR(1024) NOT NULL,
+    size_bytes BIGINT DEFAULT 0 NOT NULL,
+    ref_count INTEGER DEFAULT 1 NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+-- One blob per checksum per org; superadmin uploads without an org share the nil-UUID scope
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_blobs_org_checksum
+    ON document_blobs((COALESCE(org_id, '00000000-0000-0000-0000-000000000000'::uuid)), checksum);
+
+CREATE INDEX IF NOT EXISTS idx_documents_checksum
+    ON documents((content->>'checksum')) WHERE content->>'checksum' IS NOT NULL;
+
+COMMENT ON TABLE document_blobs IS 'Deduplicated upload bytes, shared by documents with the same checksum in an organization';
+COMMENT ON COLUMN document_blobs.checksum IS 'Hex SHA-256 of the file, also stored in documents.content->>checksum';
+COMMENT ON COLUMN document_blobs.storage_path IS 'File path relative to RESOURCES_BASE_PATH; documents sharing the blob have it as file_path';
+COMMENT ON COLUMN document_blobs.ref_count IS 'Documents referencing the blob; the file is removed when it reaches zero';
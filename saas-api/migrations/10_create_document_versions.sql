@@ -0,0 +1,32 @@
+-- Migration: Create document_versions table
+-- In-place content replacement (PUT /api/v1/documents/:id/content): every file a document has held is
+-- a version. A new version is processed next to the live one, into its own Weaviate classes, and only
+-- becomes the document's content (file_path, json_file_path, content->>'version') once it is embedded.
+-- The document ID never changes, so citations and links keep working.
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    id BIGSERIAL PRIMARY KEY,
+    document_id BIGINT NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    filename VARCHAR(512) NOT NULL,
+    file_path VARCHAR(1024),
+    json_file_path VARCHAR(1024),
+    checksum VARCHAR(64),
+    size_bytes BIGINT,
+    status VARCHAR(20) DEFAULT 'pending' NOT NULL,
+    error_message TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    activated_at TIMESTAMP,
+    superseded_at TIMESTAMP,
+    UNIQUE (document_id, version)
+);
+
+-- One replacement in flight per document
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_versions_in_flight
+    ON document_versions(document_id) WHERE status IN ('pending', 'processing', 'embedding');
+
+COMMENT ON TABLE document_versions IS 'Content history of documents; the active row matches the document''s file_path and content->>version';
+COMMENT ON COLUMN document_versions.status IS 'pending, processing, embedding, active, superseded or failed';
+COMMENT ON COLUMN document_versions.file_path IS 'Source file relative to RESOURCES_BASE_PATH; deduplicated like uploads (document_blobs)';
+COMMENT ON COLUMN document_versions.json_file_path IS 'Chunks file of the version; versions after the first are embedded in document_<id>_v_r<version>';
@@ -0,0 +1,52 @@
+-- Migration: Create org_oidc_providers and user_identities tables
+-- Per-organization OpenID Connect identity providers (Okta, Azure AD, Google Workspace, ...) for single
+-- sign-on, and the IdP accounts linked to users. The client secret, when the IdP needs one, is an org
+-- secret (see 08_create_org_secrets.sql) referenced by name; it is never stored here.
+
+CREATE TABLE IF NOT EXISTS org_oidc_providers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    issuer_url TEXT NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret_name VARCHAR(100),
+    scopes TEXT[] DEFAULT ARRAY['openid', 'email', 'profile'] NOT NULL,
+    allowed_domains TEXT[] DEFAULT '{}' NOT NULL,
+    auto_provision BOOLEAN DEFAULT true NOT NULL,
+    default_org_role VARCHAR(20) DEFAULT 'user' NOT NULL,
+    role_claim VARCHAR(100) DEFAULT 'groups' NOT NULL,
+    role_mappings JSONB DEFAULT '[]' NOT NULL,
+    enabled BOOLEAN DEFAULT true NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_oidc_providers_org ON org_oidc_providers(org_id);
+
+COMMENT ON TABLE org_oidc_providers IS 'OpenID Connect identity provider of an organization, one per org';
+COMMENT ON COLUMN org_oidc_providers.issuer_url IS 'Issuer identifier; discovery is read from issuer_url/.well-known/openid-configuration';
+COMMENT ON COLUMN org_oidc_providers.client_secret_name IS 'Name of the org secret holding the client secret; NULL for public clients (PKCE only)';
+COMMENT ON COLUMN org_oidc_providers.allowed_domains IS 'Email domains users may be provisioned for; empty allows any';
+COMMENT ON COLUMN org_oidc_providers.role_claim IS 'ID token claim (string or list of strings) matched against role_mappings';
+COMMENT ON COLUMN org_oidc_providers.role_mappings IS 'Ordered [{"claim": "...", "org_role": "admin|user|viewer", "role": "<role name>"}]';
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    provider_id UUID NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    last_login_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_subject ON user_identities(provider, provider_id, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+COMMENT ON TABLE user_identities IS 'External identity provider accounts linked to users for single sign-on';
+COMMENT ON COLUMN user_identities.provider IS 'Protocol of the identity provider, e.g. oidc';
+COMMENT ON COLUMN user_identities.provider_id IS 'The identity provider row, e.g. org_oidc_providers.id';
+COMMENT ON COLUMN user_identities.subject IS 'Stable account ID at the identity provider (the sub claim)';
+COMMENT ON COLUMN user_identities.email IS 'Email the identity provider reported at the last sign-in';
@@ -0,0 +1,38 @@
+-- Migration: Create org_saml_providers table
+-- Per-organization SAML 2.0 identity providers (ADFS, Okta, OneLogin, ...) for single sign-on. The IdP
+-- metadata is kept as uploaded; signing certificates and the SSO URL are read from it at sign-in. IdP
+-- accounts are linked to users in user_identities (see 11_create_oidc_providers.sql) with provider 'saml'.
+
+CREATE TABLE IF NOT EXISTS org_saml_providers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    idp_metadata TEXT NOT NULL,
+    idp_entity_id TEXT NOT NULL,
+    idp_sso_url TEXT NOT NULL,
+    email_attribute VARCHAR(255),
+    first_name_attribute VARCHAR(255),
+    last_name_attribute VARCHAR(255),
+    role_attribute VARCHAR(255) DEFAULT 'groups' NOT NULL,
+    role_mappings JSONB DEFAULT '[]' NOT NULL,
+    allowed_domains TEXT[] DEFAULT '{}' NOT NULL,
+    auto_provision BOOLEAN DEFAULT true NOT NULL,
+    default_org_role VARCHAR(20) DEFAULT 'user' NOT NULL,
+    enforced BOOLEAN DEFAULT false NOT NULL,
+    enabled BOOLEAN DEFAULT true NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_saml_providers_org ON org_saml_providers(org_id);
+
+COMMENT ON TABLE org_saml_providers IS 'SAML 2.0 identity provider of an organization, one per org';
+COMMENT ON COLUMN org_saml_providers.idp_metadata IS 'IdP metadata XML (EntityDescriptor) as uploaded or fetched';
+COMMENT ON COLUMN org_saml_providers.idp_entity_id IS 'entityID from idp_metadata, the expected assertion issuer';
+COMMENT ON COLUMN org_saml_providers.idp_sso_url IS 'HTTP-Redirect SingleSignOnService location from idp_metadata';
+COMMENT ON COLUMN org_saml_providers.email_attribute IS 'Assertion attribute holding the email; NULL tries the common names, then an email NameID';
+COMMENT ON COLUMN org_saml_providers.role_attribute IS 'Assertion attribute (one or more values) matched against role_mappings';
+COMMENT ON COLUMN org_saml_providers.role_mappings IS 'Ordered [{"claim": "<attribute value>", "org_role": "admin|user|viewer", "role": "<role name>"}]';
+COMMENT ON COLUMN org_saml_providers.allowed_domains IS 'Email domains users may be provisioned for; empty allows any';
+COMMENT ON COLUMN org_saml_providers.enforced IS 'When true, org users other than org admins cannot sign in with a password or OTP';
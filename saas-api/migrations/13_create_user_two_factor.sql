@@ -0,0 +1,34 @@
+-- Migration: Create user_totp and user_backup_codes tables
+-- TOTP two-factor authentication. The TOTP secret is envelope encrypted like org secrets (see
+-- 08_create_org_secrets.sql), under the keys in SECRETS_ENCRYPTION_KEYS; backup codes are stored as
+-- SHA-256 hashes. Orgs require 2FA for their users with organizations.settings->'require_2fa'.
+
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    key_id VARCHAR(50) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+COMMENT ON TABLE user_totp IS 'TOTP authenticator of a user; 2FA is on once confirmed_at is set';
+COMMENT ON COLUMN user_totp.key_id IS 'Key-encryption key that wrapped the data key';
+COMMENT ON COLUMN user_totp.ciphertext IS 'Base32 TOTP secret, AES-256-GCM under the data key (AAD: user_totp:<user_id>)';
+COMMENT ON COLUMN user_totp.confirmed_at IS 'When the user proved the authenticator works; NULL while enrollment is pending';
+COMMENT ON COLUMN user_totp.last_used_step IS 'Time step of the last accepted code; codes for it or earlier steps are refused (replay)';
+
+CREATE TABLE IF NOT EXISTS user_backup_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_backup_codes_code ON user_backup_codes(user_id, code_hash);
+
+COMMENT ON TABLE user_backup_codes IS 'Single-use 2FA backup codes; a new set replaces the old one';
+COMMENT ON COLUMN user_backup_codes.code_hash IS 'SHA-256 (hex) of the code as issued (xxxxx-xxxxx)';
@@ -0,0 +1,31 @@
+-- Migration: Create user_passkeys table
+-- WebAuthn credentials (passkeys) users sign in with instead of a password or OTP. The credential
+-- record is kept as the WebAuthn library stores it; the other columns describe the device for the
+-- user's passkey list.
+
+CREATE TABLE IF NOT EXISTS user_passkeys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL,
+    credential JSONB NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    aaguid UUID,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    attachment VARCHAR(20),
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_passkeys_credential_id ON user_passkeys(credential_id);
+CREATE INDEX IF NOT EXISTS idx_user_passkeys_user_id ON user_passkeys(user_id);
+
+COMMENT ON TABLE user_passkeys IS 'WebAuthn credentials (passkeys) for passwordless sign-in';
+COMMENT ON COLUMN user_passkeys.credential_id IS 'Credential ID chosen by the authenticator; unique across all users';
+COMMENT ON COLUMN user_passkeys.credential IS 'Credential record (public key, sign count, flags) as serialized by go-webauthn';
+COMMENT ON COLUMN user_passkeys.aaguid IS 'Authenticator model, when the authenticator discloses it';
+COMMENT ON COLUMN user_passkeys.attachment IS 'platform (built into the device) or cross-platform (security key, phone)';
+COMMENT ON COLUMN user_passkeys.backup_eligible IS 'Whether the passkey can be synced to the user''s other devices';
+COMMENT ON COLUMN user_passkeys.user_agent IS 'Browser the passkey was registered from';
@@ -0,0 +1,32 @@
+-- Migration: Create api_keys table
+-- Keys machine-to-machine integrations send as `Authorization: ApiKey <key>`. A key acts as one user
+-- of the org (typically a service account user created for the integration) and is limited to its
+-- scopes on top of that user's permissions. Only a SHA-256 hash of the key is stored.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP,
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_org_id ON api_keys(org_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+COMMENT ON TABLE api_keys IS 'Scoped API keys for machine-to-machine integrations';
+COMMENT ON COLUMN api_keys.user_id IS 'User (service account) the key authenticates as';
+COMMENT ON COLUMN api_keys.prefix IS 'First characters of the key, shown so keys can be told apart';
+COMMENT ON COLUMN api_keys.key_hash IS 'SHA-256 hex digest of the key; the key itself is only returned when created';
+COMMENT ON COLUMN api_keys.scopes IS 'Permissions the key is limited to, as resource:action';
+COMMENT ON COLUMN api_keys.revoked_at IS 'Set when the key is revoked; revoked keys are kept for the audit trail';
@@ -0,0 +1,20 @@
+-- Migration: Create login_links table
+-- One-time sign-in links ("magic links") emailed by /auth/send-link. The link carries a signed token;
+-- this table makes it single-use. Only a SHA-256 hash of the token is stored.
+
+CREATE TABLE IF NOT EXISTS login_links (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_links_token_hash ON login_links(token_hash);
+CREATE INDEX IF NOT EXISTS idx_login_links_user_created ON login_links(user_id, created_at);
+
+COMMENT ON TABLE login_links IS 'One-time sign-in links sent by email';
+COMMENT ON COLUMN login_links.ip_address IS 'Client that asked for the link';
+COMMENT ON COLUMN login_links.used_at IS 'Set when the link is exchanged for tokens, or superseded by a newer link';
@@ -0,0 +1,15 @@
+-- Migration: Create otp_deliveries table
+-- One row per sign-in OTP code sent, with the channel it went out on (email or SMS). The per-channel
+-- hourly limits count these rows. The code itself is not stored here.
+
+CREATE TABLE IF NOT EXISTS otp_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'sms')),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_otp_deliveries_user_channel_created ON otp_deliveries(user_id, channel, created_at);
+
+COMMENT ON TABLE otp_deliveries IS 'Sign-in OTP codes sent, for per-channel rate limits';
+COMMENT ON COLUMN otp_deliveries.channel IS 'email or sms';
@@ -0,0 +1,33 @@
+-- Migration: Create oauth_clients table
+-- Confidential OAuth2 clients that get access tokens with the client_credentials grant at
+-- POST /api/v1/oauth/token. Like an API key, a client belongs to an org, acts as one of its users
+-- (typically a service account user) and is limited to its scopes on top of that user's
+-- permissions. The client ID is the row ID; only a SHA-256 hash of the secret is stored.
+
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    secret_prefix VARCHAR(20) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP,
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_org_id ON oauth_clients(org_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_user_id ON oauth_clients(user_id);
+
+COMMENT ON TABLE oauth_clients IS 'OAuth2 confidential clients for the client_credentials grant';
+COMMENT ON COLUMN oauth_clients.id IS 'The client_id';
+COMMENT ON COLUMN oauth_clients.user_id IS 'User (service account) the client''s tokens act as';
+COMMENT ON COLUMN oauth_clients.secret_prefix IS 'First characters of the secret, shown so secrets can be told apart';
+COMMENT ON COLUMN oauth_clients.secret_hash IS 'SHA-256 hex digest of the client secret; the secret itself is only returned when created';
+COMMENT ON COLUMN oauth_clients.scopes IS 'Permissions the client may request, as resource:action';
+COMMENT ON COLUMN oauth_clients.last_used_at IS 'Last time the client got a token';
+COMMENT ON COLUMN oauth_clients.revoked_at IS 'Set when the client is revoked; revoked clients are kept for the audit trail';
@@ -0,0 +1,22 @@
+-- Migration: Create login_events table
+-- One row per sign-in attempt on an existing account, successful or not, with how it was made.
+-- Attempts for unknown emails are not stored. Admins read a user's history at
+-- /users/:id/login-history; users read their own at /auth/me/logins.
+
+CREATE TABLE IF NOT EXISTS login_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('password', 'otp', 'login_link', 'passkey', '2fa', 'oidc', 'saml')),
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(50),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user_created ON login_events(user_id, created_at DESC);
+
+COMMENT ON TABLE login_events IS 'Sign-in attempts on existing accounts, for login history';
+COMMENT ON COLUMN login_events.method IS 'password, otp, login_link, passkey, 2fa, oidc or saml';
+COMMENT ON COLUMN login_events.failure_reason IS 'Error code of a failed attempt, e.g. UNAUTHORIZED or ACCOUNT_LOCKED; MFA_REQUIRED when the first factor passed';
@@ -0,0 +1,65 @@
+-- Migration: Create role_parents table
+-- Role inheritance: a role has the permissions of its parent roles, and of their parents, on top of
+-- its own (e.g. "Editor" extends "Viewer"). A role may have several parents; parents are system
+-- roles or roles of the same org, and cycles are refused by the API.
+
+CREATE TABLE IF NOT EXISTS role_parents (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    parent_role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (role_id, parent_role_id),
+    CONSTRAINT check_role_parent_not_self CHECK (role_id <> parent_role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_parents_parent ON role_parents(parent_role_id);
+
+COMMENT ON TABLE role_parents IS 'Roles inherit the permissions of their parent roles, transitively';
+
+-- The permission functions follow the inheritance chain too
+CREATE OR REPLACE FUNCTION user_has_permission(
+  p_user_id UUID,
+  p_resource VARCHAR,
+  p_action VARCHAR
+) RETURNS BOOLEAN AS $$
+BEGIN
+  RETURN EXISTS (
+    WITH RECURSIVE held(role_id) AS (
+      SELECT ur.role_id FROM user_roles ur
+      WHERE ur.user_id = p_user_id AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+      UNION
+      SELECT rp.parent_role_id FROM role_parents rp JOIN held h ON rp.role_id = h.role_id
+    )
+    SELECT 1
+    FROM held h
+    JOIN role_permissions rp ON h.role_id = rp.role_id
+    JOIN permissions p ON rp.permission_id = p.id
+    WHERE p.resource = p_resource
+      AND p.action = p_action
+  );
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION get_user_permissions(p_user_id UUID)
+RETURNS TABLE (
+  resource VARCHAR,
+  action VARCHAR,
+  permission_id UUID
+) AS $$
+BEGIN
+  RETURN QUERY
+  WITH RECURSIVE held(role_id) AS (
+    SELECT ur.role_id FROM user_roles ur
+    WHERE ur.user_id = p_user_id AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+    UNION
+    SELECT rp.parent_role_id FROM role_parents rp JOIN held h ON rp.role_id = h.role_id
+  )
+  SELECT DISTINCT
+    p.resource,
+    p.action,
+    p.id
+  FROM held h
+  JOIN role_permissions rp ON h.role_id = rp.role_id
+  JOIN permissions p ON rp.permission_id = p.id;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,30 @@
+-- Migration: Create personal_access_tokens table
+-- Long-lived tokens users mint for their own scripts and tools, sent as
+-- `Authorization: Bearer pat_<token>`. A token acts as its user, limited to its scopes on top of the
+-- user's permissions, and never with super admin rights. Only a SHA-256 hash of the token is stored.
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP,
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens(user_id);
+
+COMMENT ON TABLE personal_access_tokens IS 'Scoped long-lived tokens users mint for themselves';
+COMMENT ON COLUMN personal_access_tokens.org_id IS 'Org of the user when the token was minted; the token stops working if the user leaves it';
+COMMENT ON COLUMN personal_access_tokens.prefix IS 'First characters of the token, shown so tokens can be told apart';
+COMMENT ON COLUMN personal_access_tokens.token_hash IS 'SHA-256 hex digest of the token; the token itself is only returned when minted';
+COMMENT ON COLUMN personal_access_tokens.scopes IS 'Permissions the token is limited to, as resource:action';
+COMMENT ON COLUMN personal_access_tokens.revoked_at IS 'Set when the token is revoked; revoked tokens are kept for the audit trail';
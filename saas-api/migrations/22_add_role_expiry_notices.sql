@@ -0,0 +1,9 @@
+-- Migration: Track role expiry notices
+-- Supports the role expiry job, which revokes expired role assignments and tells org admins of
+-- assignments about to expire
+
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_expires_at ON user_roles(expires_at) WHERE expires_at IS NOT NULL;
+
+COMMENT ON COLUMN user_roles.expiry_notified_at IS 'Set when org admins were told the assignment is about to expire; cleared when it is renewed';
@@ -0,0 +1,30 @@
+-- Migration: Create feature_flags table
+-- Per-org overrides of the features that come with the subscription plan (documents, document_upload,
+-- screeners, mcp). A flag grants or withdraws its feature whatever the plan; orgs without one get the
+-- plan's. Flags set so far in organizations.settings->'features' are moved here.
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    feature VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (org_id, feature)
+);
+
+INSERT INTO feature_flags (org_id, feature, enabled)
+SELECT o.id, f.key, f.value::boolean
+FROM organizations o, jsonb_each(o.settings->'features') f
+WHERE jsonb_typeof(o.settings->'features') = 'object'
+    AND f.key IN ('documents', 'document_upload', 'screeners', 'mcp')
+    AND jsonb_typeof(f.value) = 'boolean'
+ON CONFLICT (org_id, feature) DO NOTHING;
+
+UPDATE organizations
+SET settings = jsonb_set(settings, '{features}',
+    (settings->'features') - 'documents' - 'document_upload' - 'screeners' - 'mcp')
+WHERE jsonb_typeof(settings->'features') = 'object'
+    AND (settings->'features') ?| ARRAY['documents', 'document_upload', 'screeners', 'mcp'];
+
+COMMENT ON TABLE feature_flags IS 'Per-org feature overrides; without a flag an org gets the features of its plan';
+COMMENT ON COLUMN feature_flags.enabled IS 'true grants the feature whatever the plan, false withdraws it';
@@ -0,0 +1,5 @@
+-- Migration: Add the uploading document status
+-- Documents created by POST /api/v1/documents/presign wait in this status until the client has
+-- uploaded the file straight to storage and finalized the upload
+
+ALTER TYPE document_status ADD VALUE IF NOT EXISTS 'uploading';
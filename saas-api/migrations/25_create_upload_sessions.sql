@@ -0,0 +1,25 @@
+-- Migration: Create upload_sessions table
+-- Resumable uploads (POST /api/v1/documents/uploads): the client sends the file in chunks, each stored
+-- on its own under .upload-sessions/<session id>/ until the upload is completed and the chunks are
+-- joined into the document's file. Sessions nobody completes are removed, with their chunks, once they
+-- expire.
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(512) NOT NULL,
+    file_path VARCHAR(1024) NOT NULL,
+    folder_id UUID REFERENCES folders(id) ON DELETE SET NULL,
+    metadata JSONB DEFAULT '{}'::jsonb NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    offset_bytes BIGINT DEFAULT 0 NOT NULL,
+    chunk_keys TEXT[] DEFAULT '{}' NOT NULL,
+    status VARCHAR(20) DEFAULT 'active' NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    CHECK (offset_bytes <= size_bytes)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
@@ -0,0 +1,14 @@
+-- Migration: Create password_reset_requests table
+-- One row per POST /api/v1/auth/forgot-password, whether or not the email has an account, so the
+-- per-IP limit (PASSWORD_RESET_MAX_PER_IP) counts every request. password_resets only holds the
+-- links that were sent, and counting those would answer 429 for known emails only.
+
+CREATE TABLE IF NOT EXISTS password_reset_requests (
+    id BIGSERIAL PRIMARY KEY,
+    ip_address VARCHAR(45) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_requests_ip_created ON password_reset_requests(ip_address, created_at DESC);
+
+COMMENT ON TABLE password_reset_requests IS 'Forgot-password requests by client IP, for the per-IP rate limit';
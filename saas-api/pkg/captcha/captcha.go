@@ -0,0 +1,122 @@
+// Package captcha verifies the tokens CAPTCHA widgets (Google reCAPTCHA or hCaptcha) hand the frontend
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RejectedError is returned for tokens the provider rejects: missing, expired, reused, solved for
+// another site or scored as a bot
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "captcha verification failed: " + e.Reason
+}
+
+// Verifier checks a CAPTCHA token solved by the client at remoteIP
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// Config selects and configures the provider
+type Config struct {
+	Provider  string  // "recaptcha" or "hcaptcha"
+	SecretKey string  // the site's secret key at the provider
+	MinScore  float64 // reCAPTCHA v3 and hCaptcha Enterprise: lowest score accepted (0-1); 0 ignores scores
+	Hostname  string  // when set, tokens must have been solved on this hostname
+}
+
+// Provider siteverify endpoints; both take the same form and answer the same JSON
+var verifyURLs = map[string]string{
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+}
+
+// New returns the verifier for cfg.Provider, or nil when no provider is set
+func New(cfg Config) (Verifier, error) {
+	provider := strings.ToLower(cfg.Provider)
+	if provider == "" {
+		return nil, nil
+	}
+	verifyURL, ok := verifyURLs[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown CAPTCHA provider %q (want recaptcha or hcaptcha)", cfg.Provider)
+	}
+	if cfg.SecretKey == "" {
+		return nil, fmt.Errorf("%s needs a secret key (CAPTCHA_SECRET_KEY)", provider)
+	}
+	if cfg.MinScore < 0 || cfg.MinScore > 1 {
+		return nil, fmt.Errorf("CAPTCHA_MIN_SCORE must be between 0 and 1")
+	}
+	return &siteVerifier{
+		provider:  provider,
+		verifyURL: verifyURL,
+		config:    cfg,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+type siteVerifier struct {
+	provider  string
+	verifyURL string
+	config    Config
+	client    *http.Client
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"`
+	Hostname   string   `json:"hostname"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify returns a *RejectedError for rejected tokens, and other errors when the provider could not
+// be asked
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return &RejectedError{Reason: "no token"}
+	}
+
+	form := url.Values{"secret": {v.config.SecretKey}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", v.provider, resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", v.provider, err)
+	}
+
+	switch {
+	case !result.Success:
+		return &RejectedError{Reason: strings.Join(result.ErrorCodes, ", ")}
+	case v.config.Hostname != "" && !strings.EqualFold(result.Hostname, v.config.Hostname):
+		return &RejectedError{Reason: fmt.Sprintf("solved on %q", result.Hostname)}
+	case v.config.MinScore > 0 && result.Score != nil && *result.Score < v.config.MinScore:
+		return &RejectedError{Reason: fmt.Sprintf("score %.2f", *result.Score)}
+	}
+	return nil
+}
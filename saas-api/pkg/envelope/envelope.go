@@ -0,0 +1,169 @@
+// Package envelope implements envelope encryption for values stored in the database.
+//
+// Every value is encrypted with its own random data key (AES-256-GCM). The data key is then
+// encrypted ("wrapped") with a key-encryption key from the Keyring, and only the wrapped data key is
+// stored next to the ciphertext. Key-encryption keys never touch the database; rotating them means
+// adding a new current key and re-wrapping data keys, without re-encrypting the values themselves.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const keySize = 32 // AES-256
+
+var (
+	ErrNoKeys     = errors.New("envelope: no encryption keys configured")
+	ErrUnknownKey = errors.New("envelope: value was sealed with an unknown key")
+	ErrDecrypt    = errors.New("envelope: decryption failed")
+)
+
+// Sealed is an encrypted value as stored: the ID of the key-encryption key, the wrapped data key
+// and the value ciphertext. Both ciphertexts carry their GCM nonce as a prefix.
+type Sealed struct {
+	KeyID      string
+	WrappedKey []byte
+	Ciphertext []byte
+}
+
+// Keyring holds the key-encryption keys. The current key seals new values; older keys stay
+// available to open values sealed before a rotation.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring reads "id:base64key[,id:base64key...]". The first entry is the current key; each key
+// must decode to 32 bytes (e.g. `openssl rand -base64 32`).
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("envelope: key entry must be id:base64key")
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != keySize {
+			return nil, fmt.Errorf("envelope: key %q must be %d bytes of base64", id, keySize)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("envelope: duplicate key id %q", id)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if k.current == "" {
+		return nil, ErrNoKeys
+	}
+	return k, nil
+}
+
+// CurrentKeyID is the key new values are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Seal encrypts plaintext under a fresh data key. aad (e.g. the owning row's identity) is
+// authenticated but not stored: Open fails unless it is given the same aad, so a ciphertext copied
+// to another row cannot be opened there.
+func (k *Keyring) Seal(plaintext, aad []byte) (*Sealed, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("envelope: failed to generate data key: %w", err)
+	}
+
+	valueAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(valueAEAD, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealed{KeyID: k.current, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts a value sealed with any key in the keyring
+func (k *Keyring) Open(s *Sealed, aad []byte) ([]byte, error) {
+	kek, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dataKey, err := open(kek, s.WrappedKey, []byte(s.KeyID))
+	if err != nil {
+		return nil, err
+	}
+	valueAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(valueAEAD, s.Ciphertext, aad)
+}
+
+// Rewrap re-encrypts the data key under the current key, leaving the value ciphertext unchanged
+func (k *Keyring) Rewrap(s *Sealed) (*Sealed, error) {
+	if s.KeyID == k.current {
+		return s, nil
+	}
+	kek, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dataKey, err := open(kek, s.WrappedKey, []byte(s.KeyID))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return nil, err
+	}
+	return &Sealed{KeyID: k.current, WrappedKey: wrapped, Ciphertext: s.Ciphertext}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("envelope: failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,15 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// Log writes messages to the log instead of sending them, for development. The log shows the
+// text body, which may hold codes and sign-in links.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg *Message) error {
+	log.Printf("Email (not sent, MAIL_DRIVER=log) to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
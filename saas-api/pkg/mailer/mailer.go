@@ -0,0 +1,84 @@
+// Package mailer sends email through a configurable provider (SMTP, Amazon SES or SendGrid), with
+// retries and messages rendered from the templates in templates/
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text and an HTML alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends messages
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config selects and configures the provider
+type Config struct {
+	Driver         string // "smtp" (default), "ses", "sendgrid", or "log" to only log messages (development)
+	FromEmail      string
+	FromName       string
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUsername   string
+	SMTPPassword   string
+	SESRegion      string // empty: the AWS SDK's default region
+	SendGridAPIKey string
+	Retries        int           // attempts after the first failed one
+	RetryDelay     time.Duration // before the first retry; doubles for each next one
+}
+
+// New returns the mailer for cfg.Driver, retrying failed sends cfg.Retries times
+func New(ctx context.Context, cfg Config) (Mailer, error) {
+	from := mail.Address{Name: cfg.FromName, Address: cfg.FromEmail}
+
+	var m Mailer
+	switch strings.ToLower(cfg.Driver) {
+	case "", "smtp":
+		m = &SMTP{host: cfg.SMTPHost, port: cfg.SMTPPort, username: cfg.SMTPUsername, password: cfg.SMTPPassword, from: from}
+	case "ses":
+		ses, err := NewSES(ctx, cfg.SESRegion, from)
+		if err != nil {
+			return nil, err
+		}
+		m = ses
+	case "sendgrid":
+		sendGrid, err := NewSendGrid(cfg.SendGridAPIKey, from)
+		if err != nil {
+			return nil, err
+		}
+		m = sendGrid
+	case "log":
+		m = Log{}
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q (want smtp, ses, sendgrid or log)", cfg.Driver)
+	}
+
+	if cfg.Retries > 0 {
+		m = &retrying{next: m, retries: cfg.Retries, delay: cfg.RetryDelay}
+	}
+	return m, nil
+}
+
+// permanentError marks a failure that sending again will not fix, such as a rejected address
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// retrying sends again after a failure, waiting delay, then twice as long each time
+type retrying struct {
+	next    Mailer
+	retries int
+	delay   time.Duration
+}
+
+func (r *retrying) Send(ctx context.Context, msg *Message) error {
+	delay := r.delay
+	for attempt := 0; ; attempt++ {
+		err := r.next.Send(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if attempt >= r.retries || errors.As(err, &permanent) {
+			return err
+		}
+
+		log.Printf("Email: Send to %s failed (attempt %d of %d), retrying in %s: %v", msg.To, attempt+1, r.retries+1, delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
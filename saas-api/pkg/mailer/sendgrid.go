@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+)
+
+const sendGridAPI = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends through the SendGrid v3 Mail Send API
+type SendGrid struct {
+	apiKey string
+	from   mail.Address
+	client *http.Client
+}
+
+func NewSendGrid(apiKey string, from mail.Address) (*SendGrid, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("sendgrid needs an API key (SENDGRID_API_KEY)")
+	}
+	return &SendGrid{apiKey: apiKey, from: from, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridRequest struct {
+	Personalizations []struct {
+		To []sendGridAddress `json:"to"`
+	} `json:"personalizations"`
+	From    sendGridAddress   `json:"from"`
+	Subject string            `json:"subject"`
+	Content []sendGridContent `json:"content"`
+}
+
+func (s *SendGrid) Send(ctx context.Context, msg *Message) error {
+	payload := sendGridRequest{
+		From:    sendGridAddress{Email: s.from.Address, Name: s.from.Name},
+		Subject: msg.Subject,
+		// text/plain must come first
+		Content: []sendGridContent{{Type: "text/plain", Value: msg.Text}, {Type: "text/html", Value: msg.HTML}},
+	}
+	payload.Personalizations = make([]struct {
+		To []sendGridAddress `json:"to"`
+	}, 1)
+	payload.Personalizations[0].To = []sendGridAddress{{Email: msg.To}}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridAPI, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("sendgrid: status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+		// Other 4xx answers (bad key, invalid address) fail the same way again
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
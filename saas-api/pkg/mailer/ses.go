@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// SES sends through Amazon SES with the AWS SDK's default credentials. The from address must be a
+// verified identity.
+type SES struct {
+	client *sesv2.Client
+	from   mail.Address
+}
+
+func NewSES(ctx context.Context, region string, from mail.Address) (*SES, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ses: failed to load AWS config: %w", err)
+	}
+	return &SES{client: sesv2.NewFromConfig(awsCfg), from: from}, nil
+}
+
+func (s *SES) Send(ctx context.Context, msg *Message) error {
+	utf8 := aws.String("UTF-8")
+	_, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from.String()),
+		Destination:      &types.Destination{ToAddresses: []string{msg.To}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(msg.Subject), Charset: utf8},
+				Body: &types.Body{
+					Text: &types.Content{Data: aws.String(msg.Text), Charset: utf8},
+					Html: &types.Content{Data: aws.String(msg.HTML), Charset: utf8},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("ses: %w", err)
+		var rejected *types.MessageRejected
+		var notVerified *types.MailFromDomainNotVerifiedException
+		var badRequest *types.BadRequestException
+		if errors.As(err, &rejected) || errors.As(err, &notVerified) || errors.As(err, &badRequest) {
+			return Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTP sends through an SMTP server with PLAIN authentication (STARTTLS when the server offers it)
+type SMTP struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     mail.Address
+}
+
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	if s.password == "" {
+		log.Printf("⚠️  SMTP_PASSWORD not set! Email to %s not sent: %s", msg.To, msg.Subject)
+		return Permanent(fmt.Errorf("SMTP_PASSWORD not configured"))
+	}
+
+	body, err := s.mime(msg)
+	if err != nil {
+		return err
+	}
+
+	auth := smtp.PlainAuth("", s.username, s.password, s.host)
+	addr := fmt.Sprintf("%s:%s", s.host, s.port)
+	log.Printf("Email: Sending to %s via %s", msg.To, addr)
+	if err := smtp.SendMail(addr, auth, s.from.Address, []string{msg.To}, body); err != nil {
+		err = fmt.Errorf("failed to send email: %w", err)
+		// 5xx replies (bad credentials, rejected recipient) do not get better with retrying
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return Permanent(err)
+		}
+		return err
+	}
+
+	log.Printf("Email: ✅ Email sent successfully to %s", msg.To)
+	return nil
+}
+
+// mime builds a multipart/alternative message with the text and HTML bodies
+func (s *SMTP) mime(msg *Message) ([]byte, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	boundary := hex.EncodeToString(random)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", boundary, s.host)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n\r\n", boundary, msg.Text)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n\r\n", boundary, msg.HTML)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
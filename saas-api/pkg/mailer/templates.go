@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Each email is templates/<name>.txt, defining "subject" and the text "body", and
+// templates/<name>.html, defining the "title" and HTML "content" of layout.html
+//
+//go:embed templates/*.html templates/*.txt
+var templateFS embed.FS
+
+var (
+	htmlLayout = htmltemplate.Must(htmltemplate.New("").Funcs(htmltemplate.FuncMap{"button": button}).
+			ParseFS(templateFS, "templates/layout.html"))
+	textLayout = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/layout.txt"))
+
+	htmlPages = map[string]*htmltemplate.Template{}
+	textPages = map[string]*texttemplate.Template{}
+)
+
+func init() {
+	for _, name := range []string{"otp", "password_reset", "login_link", "email_change", "invitation", "new_device", "pending_users_expired", "role_assignments_expiring"} {
+		htmlPages[name] = htmltemplate.Must(htmltemplate.Must(htmlLayout.Clone()).ParseFS(templateFS, "templates/"+name+".html"))
+		textPages[name] = texttemplate.Must(texttemplate.Must(textLayout.Clone()).ParseFS(templateFS, "templates/"+name+".txt"))
+	}
+}
+
+// button is the data of the "button" template: a link styled as a button
+func button(url, label string) map[string]string {
+	return map[string]string{"URL": url, "Label": label}
+}
+
+// Render fills the named email's templates with data and returns the message to send to to
+func Render(name, to string, data interface{}) (*Message, error) {
+	htmlPage, ok := htmlPages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	textPage := textPages[name]
+
+	var subject, text, html bytes.Buffer
+	if err := textPage.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("email template %s: %w", name, err)
+	}
+	if err := textPage.ExecuteTemplate(&text, "layout", data); err != nil {
+		return nil, fmt.Errorf("email template %s: %w", name, err)
+	}
+	if err := htmlPage.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, fmt.Errorf("email template %s: %w", name, err)
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
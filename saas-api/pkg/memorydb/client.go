@@ -2,7 +2,10 @@ package memorydb
 
 import (
 	"context"
+	"log"
+	"net"
 	"saas-api/cmd/configs"
+	"saas-api/pkg/tenant"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -23,6 +26,8 @@ func NewRedisClient(ctx context.Context, config *configs.Config) (*RedisClient,
 		PoolSize:     10,
 	})
 
+	client.AddHook(tenantHook{})
+
 	if err := client.Ping(ctx).Err(); err != nil {
 		client.Close()
 		return nil, err
@@ -35,22 +40,96 @@ func (r *RedisClient) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
-// Get retrieves a value from Redis
+// Get retrieves a value from Redis. Keys are scoped to the tenant in ctx (see tenant.Key).
 func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
-	return r.client.Get(ctx, key).Result()
+	return r.client.Get(ctx, tenant.Key(ctx, key)).Result()
 }
 
-// Set stores a value in Redis
+// Set stores a value in Redis under the tenant-scoped key
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return r.client.Set(ctx, key, value, expiration).Err()
+	return r.client.Set(ctx, tenant.Key(ctx, key), value, expiration).Err()
 }
 
-// Del deletes a key from Redis
+// Del deletes tenant-scoped keys from Redis
 func (r *RedisClient) Del(ctx context.Context, keys ...string) error {
-	return r.client.Del(ctx, keys...).Err()
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = tenant.Key(ctx, key)
+	}
+	return r.client.Del(ctx, scoped...).Err()
+}
+
+// MGet retrieves several tenant-scoped values in one round trip; missing keys come back as nil
+func (r *RedisClient) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = tenant.Key(ctx, key)
+	}
+	return r.client.MGet(ctx, scoped...).Result()
+}
+
+// Incr adds one to a tenant-scoped counter that expires window after its first increment, and
+// returns the new count with the time left until it resets (a fixed-window rate limit)
+func (r *RedisClient) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	key = tenant.Key(ctx, key)
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	remaining := ttl.Val()
+	if remaining < 0 {
+		// New counter (or one left without an expiry): start its window now
+		if err := r.client.PExpire(ctx, key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+		remaining = window
+	}
+	return incr.Val(), remaining, nil
 }
 
 // Close closes the Redis connection
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
+
+// Commands slower than this are logged with the tenant they were issued for
+const slowCommandThreshold = 100 * time.Millisecond
+
+// tenantHook attributes slow commands and pipelines to the org/user in the request context
+type tenantHook struct{}
+
+func (tenantHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tenantHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		logSlowCommand(ctx, cmd.Name(), 1, time.Since(start))
+		return err
+	}
+}
+
+func (tenantHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		logSlowCommand(ctx, "pipeline", len(cmds), time.Since(start))
+		return err
+	}
+}
+
+func logSlowCommand(ctx context.Context, name string, count int, elapsed time.Duration) {
+	if elapsed <= slowCommandThreshold {
+		return
+	}
+	info := tenant.FromContext(ctx)
+	log.Printf("Redis slow command: %s (%d cmds) took %s (org=%s user=%s)",
+		name, count, elapsed.Round(time.Millisecond), info.OrgLabel(), info.UserID)
+}
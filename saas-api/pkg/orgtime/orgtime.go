@@ -0,0 +1,167 @@
+// Package orgtime computes schedule and reporting boundaries (hours, days, months) in an
+// organization's timezone. Boundaries are built from wall-clock dates with time.Date, so days
+// around DST changes are 23 or 25 hours long instead of drifting by an hour.
+package orgtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata" // Containers often ship without /usr/share/zoneinfo
+
+	"github.com/google/uuid"
+)
+
+// DefaultTimezone applies to organizations without a valid timezone and to unscoped work
+const DefaultTimezone = "UTC"
+
+// lookupRetry is how long a failed lookup's UTC fallback is cached
+const lookupRetry = 30 * time.Second
+
+var locations sync.Map // name -> *time.Location
+
+// Valid reports whether name is an IANA timezone such as "Asia/Kolkata"
+func Valid(name string) bool {
+	_, err := load(name)
+	return err == nil && name != "" && name != "Local" // "Local" is the server's zone, not a fixed one
+}
+
+// Location returns the named timezone, or UTC when name is empty or unknown
+func Location(name string) *time.Location {
+	loc, err := load(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func load(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// StartOfHour returns the start of the local hour containing t. Zones offset by a fraction of an
+// hour (e.g. Asia/Kolkata, +05:30) start their hours at :30 UTC.
+func StartOfHour(t time.Time, loc *time.Location) time.Time {
+	_, offset := t.In(loc).Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(time.Hour).Add(-shift).In(loc)
+}
+
+// StartOfDay returns local midnight of the day containing t
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// StartOfMonth returns local midnight of the first day of the month containing t
+func StartOfMonth(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// DaysBefore returns local midnight n calendar days before the day containing t. Anything before it
+// is more than n full local days old.
+func DaysBefore(t time.Time, loc *time.Location, n int) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day()-n, 0, 0, 0, 0, loc)
+}
+
+// ParseDate parses a YYYY-MM-DD date as local midnight in loc
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// Offset formats the UTC offset in effect at t, e.g. "+05:30"
+func Offset(t time.Time, loc *time.Location) string {
+	_, offset := t.In(loc).Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// LookupFunc returns an organization's configured timezone name
+type LookupFunc func(ctx context.Context, orgID uuid.UUID) (string, error)
+
+type cachedZone struct {
+	name      string
+	loc       *time.Location
+	expiresAt time.Time
+}
+
+// Resolver caches each organization's timezone so hot paths (usage tracking, sweeps) do not query
+// the database per call. Timezone changes apply after ttl unless Invalidate is called.
+type Resolver struct {
+	lookup LookupFunc
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	zones map[uuid.UUID]cachedZone
+}
+
+func NewResolver(lookup LookupFunc, ttl time.Duration) *Resolver {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &Resolver{lookup: lookup, ttl: ttl, zones: make(map[uuid.UUID]cachedZone)}
+}
+
+// Zone returns the effective timezone name and location of an organization. A nil org, a failed
+// lookup or an invalid stored name falls back to DefaultTimezone.
+func (r *Resolver) Zone(ctx context.Context, orgID *uuid.UUID) (string, *time.Location) {
+	if r == nil || orgID == nil || *orgID == uuid.Nil {
+		return DefaultTimezone, time.UTC
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	if zone, ok := r.zones[*orgID]; ok && now.Before(zone.expiresAt) {
+		r.mu.Unlock()
+		return zone.name, zone.loc
+	}
+	r.mu.Unlock()
+
+	zone := cachedZone{name: DefaultTimezone, loc: time.UTC, expiresAt: now.Add(r.ttl)}
+	if name, err := r.lookup(ctx, *orgID); err != nil {
+		// Retry soon rather than reporting in UTC for a whole ttl after a transient failure
+		if retryAt := now.Add(lookupRetry); retryAt.Before(zone.expiresAt) {
+			zone.expiresAt = retryAt
+		}
+	} else if loc, err := load(name); err == nil && name != "" {
+		zone.name, zone.loc = name, loc
+	}
+
+	r.mu.Lock()
+	r.zones[*orgID] = zone
+	r.mu.Unlock()
+	return zone.name, zone.loc
+}
+
+// Location is Zone without the name
+func (r *Resolver) Location(ctx context.Context, orgID *uuid.UUID) *time.Location {
+	_, loc := r.Zone(ctx, orgID)
+	return loc
+}
+
+// Invalidate drops a cached timezone, e.g. after the organization was updated
+func (r *Resolver) Invalidate(orgID uuid.UUID) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.zones, orgID)
+	r.mu.Unlock()
+}
@@ -0,0 +1,61 @@
+// Package sms sends text messages through an SMS provider (Twilio or Amazon SNS)
+package sms
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Sender sends a text message to a phone number in E.164 format (+14155550123)
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone strips the spaces, dashes, dots and parentheses people type into phone numbers and
+// reports whether what is left is an E.164 number
+func NormalizePhone(phone string) (string, bool) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+	return phone, e164.MatchString(phone)
+}
+
+// Config selects and configures the provider
+type Config struct {
+	Provider         string // "twilio" or "sns"
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string // sending number or messaging service SID (MG...)
+	SNSRegion        string // empty: the AWS SDK's default region
+	SNSSenderID      string // alphanumeric sender ID, where the destination country supports it
+}
+
+// New returns the sender for cfg.Provider, or nil when no provider is set
+func New(ctx context.Context, cfg Config) (Sender, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "":
+		return nil, nil
+	case "twilio":
+		sender, err := NewTwilio(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFrom)
+		if err != nil {
+			return nil, err
+		}
+		return sender, nil
+	case "sns":
+		sender, err := NewSNS(ctx, cfg.SNSRegion, cfg.SNSSenderID)
+		if err != nil {
+			return nil, err
+		}
+		return sender, nil
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q (want twilio or sns)", cfg.Provider)
+	}
+}
@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// SNS sends messages as transactional SMS through Amazon SNS, with the AWS SDK's default credentials
+type SNS struct {
+	client   *sns.Client
+	senderID string
+}
+
+func NewSNS(ctx context.Context, region, senderID string) (*SNS, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("sns: failed to load AWS config: %w", err)
+	}
+	return &SNS{client: sns.NewFromConfig(awsCfg), senderID: senderID}, nil
+}
+
+func (s *SNS) Send(ctx context.Context, to, body string) error {
+	attributes := map[string]types.MessageAttributeValue{
+		"AWS.SNS.SMS.SMSType": {DataType: aws.String("String"), StringValue: aws.String("Transactional")},
+	}
+	if s.senderID != "" {
+		attributes["AWS.SNS.SMS.SenderID"] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(s.senderID)}
+	}
+
+	if _, err := s.client.Publish(ctx, &sns.PublishInput{
+		PhoneNumber:       aws.String(to),
+		Message:           aws.String(body),
+		MessageAttributes: attributes,
+	}); err != nil {
+		return fmt.Errorf("sns: %w", err)
+	}
+	return nil
+}
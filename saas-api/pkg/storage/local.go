@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local keeps files under a directory on the local disk, at <root>/<key>
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Path is where the file at key is on disk
+func (l *Local) Path(key string) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes to a temporary file next to the destination and renames it into place, so readers
+// never see a partial file
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	diskPath, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(diskPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(diskPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), diskPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	diskPath, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(diskPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	diskPath, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(diskPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	diskPath, err := l.Path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(diskPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SignedURL is not supported: local files are served by the API itself
+func (l *Local) SignedURL(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	return "", ErrSignedURLNotSupported
+}
+
+// SignedUploadURL is not supported: uploads to local storage go through the API
+func (l *Local) SignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	return "", ErrSignedURLNotSupported
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxSignedURLTTL is the longest S3 accepts for a presigned URL
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// S3 keeps files in an S3 bucket, at <prefix><key>, with the AWS SDK's default credentials. It talks
+// to the S3 REST API directly, with requests signed by the SDK's SigV4 signer.
+type S3 struct {
+	bucket    string
+	region    string
+	endpoint  *url.URL // scheme and host of the bucket's endpoint
+	pathStyle bool
+	prefix    string
+
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+func NewS3(ctx context.Context, bucket, region, endpoint, prefix string, pathStyle bool) (*S3, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("s3: a bucket is required (S3_BUCKET)")
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("s3: failed to load AWS config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("s3: no region configured (S3_REGION or AWS_REGION)")
+	}
+
+	if endpoint == "" {
+		endpoint = "https://s3." + awsCfg.Region + ".amazonaws.com"
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Scheme == "" || endpointURL.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", endpoint)
+	}
+
+	return &S3{
+		bucket:      bucket,
+		region:      awsCfg.Region,
+		endpoint:    endpointURL,
+		pathStyle:   pathStyle,
+		prefix:      prefix,
+		credentials: awsCfg.Credentials,
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			o.DisableURIPathEscaping = true // objectURL escapes the key as S3 expects
+		}),
+		client: &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+// Put uploads in a single PUT, which needs the length up front: readers that cannot seek are
+// spooled to a temporary file first
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	body, ok := r.(io.ReadSeeker)
+	if !ok {
+		tmp, err := os.CreateTemp("", "s3-put-*")
+		if err != nil {
+			return fmt.Errorf("s3: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return fmt.Errorf("s3: %w", err)
+		}
+		body = tmp
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+
+	var reqBody io.ReadCloser = http.NoBody
+	if end > start {
+		reqBody = io.NopCloser(body)
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, reqBody, nil)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = end - start
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.do(req)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// SignedURL presigns a GET that downloads the file as an attachment named filename
+func (s *S3) SignedURL(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	query := url.Values{}
+	if filename != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, query)
+	if err != nil {
+		return "", err
+	}
+	return s.presign(ctx, req, ttl)
+}
+
+// SignedUploadURL presigns a PUT; the signature covers the length and content type, so S3 rejects
+// uploads of any other size or type
+func (s *S3) SignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	req, err := s.newRequest(ctx, http.MethodPut, key, nil, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.presign(ctx, req, ttl)
+}
+
+// presign signs req into a URL valid for ttl
+func (s *S3) presign(ctx context.Context, req *http.Request, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = DefaultSignedURLTTL
+	}
+	if ttl > maxSignedURLTTL {
+		ttl = maxSignedURLTTL
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(ttl/time.Second), 10))
+	req.URL.RawQuery = query.Encode()
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("s3: failed to get AWS credentials: %w", err)
+	}
+	signed, _, err := s.signer.PresignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", s.region, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("s3: failed to presign: %w", err)
+	}
+	return signed, nil
+}
+
+// newRequest builds an unsigned request for the object at key
+func (s *S3) newRequest(ctx context.Context, method, key string, body io.ReadCloser, query url.Values) (*http.Request, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := *s.endpoint
+	objectPath := "/" + s.prefix + cleaned
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		objectURL.Host = s.bucket + "." + objectURL.Host
+	}
+	objectURL.Path = objectPath
+	objectURL.RawPath = escapeObjectPath(objectPath)
+	objectURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	// http.NewRequest re-parses the URL; keep the strict escaping the signature is computed over
+	req.URL.RawPath = objectURL.RawPath
+	return req, nil
+}
+
+// do signs and sends the request; 404 returns ErrNotFound and other failures the S3 error code
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	creds, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("s3: failed to get AWS credentials: %w", err)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if err := s.signer.SignHTTP(req.Context(), creds, req, "UNSIGNED-PAYLOAD", "s3", s.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("s3: failed to sign request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(raw, &apiErr) == nil && apiErr.Code != "" {
+		return nil, fmt.Errorf("s3: %s: %s", apiErr.Code, apiErr.Message)
+	}
+	return nil, fmt.Errorf("s3: unexpected status %d", resp.StatusCode)
+}
+
+// escapeObjectPath percent-encodes every byte of the path but unreserved characters and slashes,
+// the encoding S3 signs object keys with
+func escapeObjectPath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+// Package storage keeps uploaded files on a backend chosen by configuration: the local disk or an
+// S3 bucket (Amazon S3 or a compatible store such as MinIO)
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultSignedURLTTL is how long a signed URL stays valid when the caller passes no TTL
+const DefaultSignedURLTTL = 5 * time.Minute
+
+var (
+	// ErrNotFound is returned by Get for a key with no file
+	ErrNotFound = errors.New("storage: file not found")
+	// ErrSignedURLNotSupported is returned by SignedURL and SignedUploadURL when the backend cannot
+	// hand out URLs; the caller serves or receives the file itself instead
+	ErrSignedURLNotSupported = errors.New("storage: signed URLs are not supported by this backend")
+)
+
+// Storage stores files by key, a slash-separated relative path such as "<org_id>/folder/report.pdf"
+type Storage interface {
+	// Put stores the reader's bytes at key, replacing any file there
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the file at key; the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file at key; a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// Exists tells whether there is a file at key
+	Exists(ctx context.Context, key string) (bool, error)
+	// SignedURL returns a URL that downloads the file at key as filename until ttl has passed
+	// (DefaultSignedURLTTL when zero)
+	SignedURL(ctx context.Context, key, filename string, ttl time.Duration) (string, error)
+	// SignedUploadURL returns a URL that accepts a PUT of size bytes of contentType to key until ttl
+	// has passed (DefaultSignedURLTTL when zero)
+	SignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error)
+}
+
+// Config selects and configures the backend
+type Config struct {
+	Backend          string // "local" (default) or "s3"
+	LocalRoot        string // directory of the local backend
+	S3Bucket         string
+	S3Region         string // empty: the AWS SDK's default region
+	S3Endpoint       string // empty: Amazon S3; set for compatible stores, e.g. http://localhost:9000
+	S3Prefix         string // prepended to every key, e.g. "uploads/"
+	S3ForcePathStyle bool   // address the bucket in the path instead of the host name, as MinIO needs
+}
+
+// New returns the backend for cfg.Backend
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "local":
+		return NewLocal(cfg.LocalRoot), nil
+	case "s3":
+		store, err := NewS3(ctx, cfg.S3Bucket, cfg.S3Region, cfg.S3Endpoint, cfg.S3Prefix, cfg.S3ForcePathStyle)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (want local or s3)", cfg.Backend)
+	}
+}
+
+// LocalFile gives a path on disk holding the file at key, for tools that only read files, such as
+// the Python document pipeline. Local storage returns the file itself; other backends download it
+// to a temporary file, which cleanup removes. cleanup is always safe to call.
+func LocalFile(ctx context.Context, s Storage, key string) (filePath string, cleanup func(), err error) {
+	if local, ok := s.(*Local); ok {
+		filePath, err = local.Path(key)
+		return filePath, func() {}, err
+	}
+
+	src, err := s.Get(ctx, key)
+	if err != nil {
+		return "", func() {}, err
+	}
+	defer src.Close()
+
+	// Keep the extension: the pipeline picks its parser by it
+	tmp, err := os.CreateTemp("", "storage-*"+path.Ext(key))
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// cleanKey normalizes a key to a relative slash-separated path that cannot climb out of the root
+func cleanKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(key, "\\", "/")), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return cleaned, nil
+}
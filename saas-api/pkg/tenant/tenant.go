@@ -0,0 +1,92 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+)
+
+// Headers attached to outgoing Weaviate requests so slow-query logs can attribute load to a tenant
+const (
+	HeaderOrgID          = "X-Tenant-Org-ID"
+	HeaderUserID         = "X-Tenant-User-ID"
+	HeaderImpersonatorID = "X-Tenant-Impersonator-ID"
+)
+
+// Unscoped is used as the org label for calls without an org (super admins, startup tasks)
+const Unscoped = "none"
+
+// Info identifies the tenant a call is made on behalf of
+type Info struct {
+	OrgID          string
+	UserID         string
+	ImpersonatorID string // the super admin acting as UserID, when impersonating
+}
+
+type contextKey struct{}
+
+// WithTenant returns a context carrying info, for work that runs outside the request (e.g. document workers)
+func WithTenant(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the tenant set by WithTenant, falling back to the "org_id"/"user_id"/"impersonator_id"
+// values AuthMiddleware puts on the request context
+func FromContext(ctx context.Context) Info {
+	if ctx == nil {
+		return Info{}
+	}
+	if info, ok := ctx.Value(contextKey{}).(Info); ok {
+		return info
+	}
+	var info Info
+	if orgID, ok := ctx.Value("org_id").(string); ok {
+		info.OrgID = orgID
+	}
+	if userID, ok := ctx.Value("user_id").(string); ok {
+		info.UserID = userID
+	}
+	if impersonatorID, ok := ctx.Value("impersonator_id").(string); ok {
+		info.ImpersonatorID = impersonatorID
+	}
+	return info
+}
+
+// OrgLabel returns the org ID, or Unscoped when there is none
+func (i Info) OrgLabel() string {
+	if i.OrgID == "" {
+		return Unscoped
+	}
+	return i.OrgID
+}
+
+// Key prefixes a Redis key with the tenant's org ("tenant:{org_id}:{key}") so memory usage and
+// per-tenant quotas can be measured with SCAN/MEMORY USAGE on the prefix
+func Key(ctx context.Context, key string) string {
+	return "tenant:" + FromContext(ctx).OrgLabel() + ":" + key
+}
+
+// Transport adds the tenant headers from each request's context before delegating to Base
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	info := FromContext(req.Context())
+	if info.OrgID != "" || info.UserID != "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderOrgID, info.OrgLabel())
+		if info.UserID != "" {
+			req.Header.Set(HeaderUserID, info.UserID)
+		}
+		if info.ImpersonatorID != "" {
+			req.Header.Set(HeaderImpersonatorID, info.ImpersonatorID)
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,132 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	fylogger "github.com/FyersDev/trading-logger-go"
+	"github.com/weaviate/weaviate-go-client/v5/weaviate/alias"
+	"github.com/weaviate/weaviate-go-client/v5/weaviate/fault"
+)
+
+// PointDocumentClasses makes a document's live class names (DocumentClassNames(documentID, "")) aliases
+// of a version's classes, so search, MCP and canary lookups move to the new embeddings without
+// changing the names they query. It returns the text and table classes the aliases pointed at
+// before, or nil when the live names were still classes.
+//
+// Once the live names are aliases, each switch is a single alias update. The first time, they are
+// still the classes of the original upload: those are dropped right before the aliases are created,
+// which is the only moment searches can miss the document.
+//
+// When a switch fails partway, the aliases already moved are pointed back at their previous classes.
+// The original upload's classes cannot come back once dropped, so aliases created for them stay on
+// the version's classes; callers must not delete classes an alias still targets (see
+// DocumentAliasTargets).
+func (w *WeaviateClient) PointDocumentClasses(ctx context.Context, documentID int64, textClass, tableClass string) ([]string, error) {
+	liveText, liveTable := DocumentClassNames(documentID, "")
+
+	var previous []string
+	var moved []*alias.Alias // Aliases updated by this call, with the classes they pointed at
+	fail := func(err error) ([]string, error) {
+		for _, undo := range moved {
+			if undoErr := w.Client.Alias().AliasUpdater().WithAlias(undo).Do(ctx); undoErr != nil {
+				fylogger.ErrorLog(ctx, fmt.Sprintf("failed to point %s back at %s", undo.Alias, undo.Class), undoErr, nil)
+			}
+		}
+		return nil, err
+	}
+	for _, pair := range [][2]string{{liveText, textClass}, {liveTable, tableClass}} {
+		name, target := upperFirst(pair[0]), upperFirst(pair[1])
+
+		current, err := w.Client.Alias().AliasGetter().WithAliasName(name).Do(ctx)
+		if err == nil && current != nil && current.Class != "" {
+			previous = append(previous, current.Class)
+			if strings.EqualFold(current.Class, target) {
+				continue
+			}
+			if err := w.Client.Alias().AliasUpdater().WithAlias(&alias.Alias{Alias: name, Class: target}).Do(ctx); err != nil {
+				return fail(fmt.Errorf("failed to point %s at %s: %w", name, target, err))
+			}
+			moved = append(moved, &alias.Alias{Alias: name, Class: current.Class})
+			continue
+		}
+
+		if err := w.DeleteClasses(ctx, name); err != nil {
+			return fail(err)
+		}
+		if err := w.Client.Alias().AliasCreator().WithAlias(&alias.Alias{Alias: name, Class: target}).Do(ctx); err != nil {
+			return fail(fmt.Errorf("failed to create alias %s for %s: %w", name, target, err))
+		}
+	}
+	if len(previous) < 2 {
+		previous = nil
+	}
+
+	fylogger.InfoLog(ctx, "document classes switched", map[string]interface{}{
+		"document_id": documentID,
+		"text_class":  textClass,
+		"table_class": tableClass,
+	})
+	return previous, nil
+}
+
+// deleteDocumentAliases removes the live names of a document if they are aliases, together with the
+// classes they point at. Documents whose content was never replaced have none.
+func (w *WeaviateClient) deleteDocumentAliases(ctx context.Context, documentID int64) error {
+	liveText, liveTable := DocumentClassNames(documentID, "")
+	for _, name := range []string{upperFirst(liveText), upperFirst(liveTable)} {
+		current, err := w.Client.Alias().AliasGetter().WithAliasName(name).Do(ctx)
+		if err != nil || current == nil || current.Class == "" {
+			continue
+		}
+		if err := w.Client.Alias().AliasDeleter().WithAliasName(name).Do(ctx); err != nil {
+			return fmt.Errorf("failed to delete alias %s: %w", name, err)
+		}
+		if err := w.DeleteClasses(ctx, current.Class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DocumentAliasTargets returns the classes a document's live names point at while they are aliases.
+// Those classes hold the document's search data and must not be deleted.
+func (w *WeaviateClient) DocumentAliasTargets(ctx context.Context, documentID int64) ([]string, error) {
+	liveText, liveTable := DocumentClassNames(documentID, "")
+	var targets []string
+	for _, name := range []string{upperFirst(liveText), upperFirst(liveTable)} {
+		current, err := w.Client.Alias().AliasGetter().WithAliasName(name).Do(ctx)
+		var clientErr *fault.WeaviateClientError
+		if errors.As(err, &clientErr) && clientErr.StatusCode == http.StatusNotFound {
+			continue // Not an alias (yet): still the original upload's class
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up alias %s: %w", name, err)
+		}
+		if current != nil && current.Class != "" {
+			targets = append(targets, current.Class)
+		}
+	}
+	return targets, nil
+}
+
+// resolveAlias returns the class an alias points at, or the name itself when it is not an alias
+func (w *WeaviateClient) resolveAlias(ctx context.Context, name string) string {
+	name = upperFirst(name)
+	current, err := w.Client.Alias().AliasGetter().WithAliasName(name).Do(ctx)
+	if err == nil && current != nil && current.Class != "" {
+		return current.Class
+	}
+	return name
+}
+
+// upperFirst is how Weaviate stores class and alias names: with their first letter capitalized
+func upperFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -119,7 +119,8 @@ func (w *WeaviateClient) PopulateFromMarkdownChunks(
 func (w *WeaviateClient) CreateClass(ctx context.Context, className string) error {
 	err := w.Client.Schema().ClassCreator().
 		WithClass(&models.Class{
-			Class: className,
+			Class:      className,
+			Properties: chunkClassProperties(),
 			VectorConfig: map[string]models.VectorConfig{
 				"details_vector": {
 					Vectorizer: map[string]interface{}{
@@ -146,24 +147,54 @@ func (w *WeaviateClient) CreateClass(ctx context.Context, className string) erro
 	return nil
 }
 
+// chunkClassProperties declares ExpectedChunkProperties up front, so a class that has no objects
+// yet (a document without tables has an empty table class) does not read as drifted
+func chunkClassProperties() []*models.Property {
+	properties := make([]*models.Property, 0, len(ExpectedChunkProperties))
+	for _, expected := range ExpectedChunkProperties {
+		properties = append(properties, &models.Property{Name: expected.Name, DataType: []string{expected.DataType}})
+	}
+	return properties
+}
+
+// DocumentClassNames returns the text and table class names of a document. A non-empty version
+// names a shadow copy (e.g. from a pipeline canary) that lives next to the live classes.
+func DocumentClassNames(documentID int64, version string) (string, string) {
+	base := fmt.Sprintf("document_%d", documentID)
+	if version != "" {
+		base += "_v_" + version
+	}
+	return base, base + "_table"
+}
+
 // BatchInsertChunks inserts document chunks into Weaviate in batches
 func (w *WeaviateClient) BatchInsertChunks(
 	ctx context.Context,
 	chunks []Chunk,
 	config *PopulateConfig,
 	documentID int64,
+) error {
+	classNameText, classNameTable := DocumentClassNames(documentID, "")
+	return w.InsertChunksIntoClasses(ctx, chunks, config, documentID, classNameText, classNameTable)
+}
+
+// InsertChunksIntoClasses inserts chunks in batches, tables into classNameTable and the rest into classNameText
+func (w *WeaviateClient) InsertChunksIntoClasses(
+	ctx context.Context,
+	chunks []Chunk,
+	config *PopulateConfig,
+	documentID int64,
+	classNameText, classNameTable string,
 ) error {
 	if config == nil {
 		config = DefaultPopulateConfig()
 	}
 
-	classNameText := fmt.Sprintf("document_%d", documentID)
 	err := w.CreateClass(ctx, classNameText)
 	if err != nil {
 		return err
 	}
 
-	classNameTable := fmt.Sprintf("document_%d_table", documentID)
 	err = w.CreateClass(ctx, classNameTable)
 	if err != nil {
 		return err
@@ -173,14 +204,6 @@ func (w *WeaviateClient) BatchInsertChunks(
 
 	fmt.Println("Batch inserting chunks for document: ", documentID)
 	for i, chunk := range chunks {
-		// Prepare properties for Weaviate
-		properties := make(map[string]interface{})
-
-		properties["content"] = chunk.Content
-		properties["section_title"] = chunk.SectionTitle
-		properties["page_number"] = chunk.PageNumber
-		properties["content_type"] = chunk.ContentType
-
 		className := classNameText
 		if chunk.ContentType == "table" {
 			className = classNameTable
@@ -188,7 +211,7 @@ func (w *WeaviateClient) BatchInsertChunks(
 		batcher = batcher.WithObjects(&models.Object{
 			Class:      className,
 			ID:         strfmt.UUID(uuid.New().String()),
-			Properties: properties,
+			Properties: chunkProperties(chunk),
 		},
 		)
 
@@ -210,3 +233,103 @@ func (w *WeaviateClient) BatchInsertChunks(
 	fmt.Println("Batch insert completed successfully")
 	return nil
 }
+
+// chunkProperties are the Weaviate properties of a chunk
+func chunkProperties(chunk Chunk) map[string]interface{} {
+	return map[string]interface{}{
+		"content":       chunk.Content,
+		"section_title": chunk.SectionTitle,
+		"page_number":   chunk.PageNumber,
+		"content_type":  chunk.ContentType,
+	}
+}
+
+// objectChunk reads back the chunk an object was inserted from; JSON gives numbers as float64
+func objectChunk(object *models.Object) Chunk {
+	properties, _ := object.Properties.(map[string]interface{})
+	var chunk Chunk
+	chunk.Content, _ = properties["content"].(string)
+	chunk.SectionTitle, _ = properties["section_title"].(string)
+	chunk.ContentType, _ = properties["content_type"].(string)
+	if page, ok := properties["page_number"].(float64); ok {
+		chunk.PageNumber = int(page)
+	}
+	return chunk
+}
+
+// copyBatchSize is how many objects CopyDocumentObjects reads and writes at a time
+const copyBatchSize = 100
+
+// CopyDocumentObjects copies the chunks of an embedded document, with their vectors, into the given
+// classes. Weaviate does not vectorize objects that come with their vectors, so a document identical
+// to one already embedded costs no vectorization. It returns how many objects were copied; the
+// source's live names may be classes or aliases (see PointDocumentClasses).
+func (w *WeaviateClient) CopyDocumentObjects(ctx context.Context, sourceDocumentID int64, classNameText, classNameTable string) (int, error) {
+	sourceText, sourceTable := DocumentClassNames(sourceDocumentID, "")
+
+	copied := 0
+	for _, pair := range [][2]string{{sourceText, classNameText}, {sourceTable, classNameTable}} {
+		if err := w.CreateClass(ctx, pair[1]); err != nil {
+			return copied, err
+		}
+		n, err := w.copyClassObjects(ctx, w.resolveAlias(ctx, pair[0]), pair[1])
+		copied += n
+		if err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
+
+// copyClassObjects copies every object of one class into another, paging by object ID
+func (w *WeaviateClient) copyClassObjects(ctx context.Context, from, to string) (int, error) {
+	exists, err := w.Client.Schema().ClassExistenceChecker().WithClassName(from).Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check class %s: %w", from, err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	copied := 0
+	after := ""
+	for {
+		getter := w.Client.Data().ObjectsGetter().WithClassName(from).WithVector().WithLimit(copyBatchSize)
+		if after != "" {
+			getter = getter.WithAfter(after)
+		}
+		objects, err := getter.Do(ctx)
+		if err != nil {
+			return copied, fmt.Errorf("failed to read objects of %s: %w", from, err)
+		}
+		if len(objects) == 0 {
+			return copied, nil
+		}
+
+		batcher := w.Client.Batch().ObjectsBatcher()
+		for _, object := range objects {
+			batcher = batcher.WithObjects(&models.Object{
+				Class:      to,
+				ID:         strfmt.UUID(uuid.New().String()),
+				Properties: chunkProperties(objectChunk(object)),
+				Vector:     object.Vector,
+				Vectors:    object.Vectors,
+			})
+		}
+		results, err := batcher.Do(ctx)
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy objects of %s: %w", from, err)
+		}
+		for _, result := range results {
+			if result.Result != nil && result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+				return copied, fmt.Errorf("failed to copy an object of %s: %s", from, result.Result.Errors.Error[0].Message)
+			}
+		}
+
+		copied += len(objects)
+		if len(objects) < copyBatchSize {
+			return copied, nil
+		}
+		after = string(objects[len(objects)-1].ID)
+	}
+}
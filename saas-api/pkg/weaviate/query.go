@@ -107,10 +107,59 @@ func (w *WeaviateClient) QueryHybridWithCollection(ctx context.Context,
 	return nil, nil
 }
 
-// DeleteDocumentClasses deletes document classes from Weaviate
+// CountObjects returns how many objects a class holds; 0 when the class does not exist
+func (w *WeaviateClient) CountObjects(ctx context.Context, className string) (int, error) {
+	response, err := w.Client.GraphQL().Aggregate().
+		WithClassName(className).
+		WithFields(graphql.Field{Name: "meta", Fields: []graphql.Field{{Name: "count"}}}).
+		Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count objects in %s: %w", className, err)
+	}
+	if len(response.Errors) > 0 {
+		exists, _ := w.Client.Schema().ClassExistenceChecker().WithClassName(className).Do(ctx)
+		if !exists {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to count objects in %s: %s", className, response.Errors[0].Message)
+	}
+
+	aggregate, _ := response.Data["Aggregate"].(map[string]interface{})
+	for _, value := range aggregate {
+		groups, _ := value.([]interface{})
+		if len(groups) == 0 {
+			return 0, nil
+		}
+		group, _ := groups[0].(map[string]interface{})
+		meta, _ := group["meta"].(map[string]interface{})
+		count, _ := meta["count"].(float64)
+		return int(count), nil
+	}
+	return 0, nil
+}
+
+// DeleteClasses deletes classes, ignoring ones that do not exist
+func (w *WeaviateClient) DeleteClasses(ctx context.Context, classNames ...string) error {
+	for _, className := range classNames {
+		err := w.Client.Schema().ClassDeleter().WithClassName(className).Do(ctx)
+		if err == nil {
+			continue
+		}
+		if exists, _ := w.Client.Schema().ClassExistenceChecker().WithClassName(className).Do(ctx); exists {
+			return fmt.Errorf("failed to delete class %s: %w", className, err)
+		}
+	}
+	return nil
+}
+
+// DeleteDocumentClasses deletes document classes from Weaviate. When the document's content was
+// replaced, the live names are aliases; they go together with the classes they point at.
 func (w *WeaviateClient) DeleteDocumentClasses(ctx context.Context, documentID int64) error {
-	classNameText := fmt.Sprintf("document_%d", documentID)
-	classNameTable := fmt.Sprintf("document_%d_table", documentID)
+	classNameText, classNameTable := DocumentClassNames(documentID, "")
+
+	if err := w.deleteDocumentAliases(ctx, documentID); err != nil {
+		return err
+	}
 
 	// Delete text class
 	err := w.Client.Schema().ClassDeleter().
@@ -0,0 +1,152 @@
+package weaviate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// chunkClassPattern matches the per-document classes created by BatchInsertChunks.
+// Weaviate capitalizes class names, so "document_12" is stored as "Document_12".
+var chunkClassPattern = regexp.MustCompile(`^[Dd]ocument_[0-9]+(_table)?$`)
+
+// ExpectedProperty describes a property the query/populate code relies on
+type ExpectedProperty struct {
+	Name string
+	// DataType is created when auto-migrating; Compatible lists every live type the code can read
+	DataType   string
+	Compatible []string
+}
+
+// ExpectedChunkProperties must stay in sync with BatchInsertChunks and getFields
+var ExpectedChunkProperties = []ExpectedProperty{
+	{Name: "content", DataType: "text", Compatible: []string{"text", "string"}},
+	{Name: "section_title", DataType: "text", Compatible: []string{"text", "string"}},
+	{Name: "page_number", DataType: "int", Compatible: []string{"int", "number"}}, // auto-schema infers "number"
+	{Name: "content_type", DataType: "text", Compatible: []string{"text", "string"}},
+}
+
+// ExpectedChunkVector is the named vector created by CreateClass
+const ExpectedChunkVector = "details_vector"
+
+// ClassDrift lists the differences found for a single class
+type ClassDrift struct {
+	Class             string   `json:"class"`
+	MissingProperties []string `json:"missing_properties,omitempty"`
+	TypeMismatches    []string `json:"type_mismatches,omitempty"`
+	MissingVector     bool     `json:"missing_vector,omitempty"`
+	Migrated          []string `json:"migrated,omitempty"` // Properties added by auto-migration
+}
+
+// SchemaReport is the result of comparing the live schema against the expected class definitions
+type SchemaReport struct {
+	CheckedAt      time.Time    `json:"checked_at"`
+	ClassesChecked int          `json:"classes_checked"`
+	Drift          []ClassDrift `json:"drift,omitempty"`
+}
+
+// OK reports whether every checked class matches (after any auto-migration)
+func (r *SchemaReport) OK() bool {
+	for _, d := range r.Drift {
+		if len(d.MissingProperties) > 0 || len(d.TypeMismatches) > 0 || d.MissingVector {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifySchema compares the live schema of all document chunk classes against ExpectedChunkProperties.
+// With autoMigrate, missing properties are added (additive changes only); type mismatches and missing
+// vectors are only reported because fixing them requires re-creating the class.
+func (w *WeaviateClient) VerifySchema(ctx context.Context, autoMigrate bool) (*SchemaReport, error) {
+	dump, err := w.Client.Schema().Getter().Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch weaviate schema: %w", err)
+	}
+
+	report := &SchemaReport{CheckedAt: time.Now()}
+	for _, class := range dump.Classes {
+		if class == nil || !chunkClassPattern.MatchString(class.Class) {
+			continue
+		}
+		report.ClassesChecked++
+		if len(class.Properties) == 0 {
+			// Created before CreateClass declared its properties and never written to; auto-schema
+			// types the properties on the first insert, so there is nothing to read yet
+			continue
+		}
+
+		drift := diffChunkClass(class)
+		if autoMigrate && len(drift.MissingProperties) > 0 {
+			drift.MissingProperties, drift.Migrated = w.addMissingProperties(ctx, class.Class, drift.MissingProperties)
+		}
+
+		if len(drift.MissingProperties) > 0 || len(drift.TypeMismatches) > 0 || drift.MissingVector || len(drift.Migrated) > 0 {
+			report.Drift = append(report.Drift, drift)
+		}
+	}
+
+	return report, nil
+}
+
+func diffChunkClass(class *models.Class) ClassDrift {
+	drift := ClassDrift{Class: class.Class}
+
+	live := make(map[string][]string, len(class.Properties))
+	for _, prop := range class.Properties {
+		if prop != nil {
+			live[prop.Name] = prop.DataType
+		}
+	}
+
+	for _, expected := range ExpectedChunkProperties {
+		dataType, ok := live[expected.Name]
+		if !ok {
+			drift.MissingProperties = append(drift.MissingProperties, expected.Name)
+			continue
+		}
+		if len(dataType) == 0 || !containsString(expected.Compatible, dataType[0]) {
+			drift.TypeMismatches = append(drift.TypeMismatches,
+				fmt.Sprintf("%s: expected %s, found %s", expected.Name, strings.Join(expected.Compatible, "|"), strings.Join(dataType, ",")))
+		}
+	}
+
+	if _, ok := class.VectorConfig[ExpectedChunkVector]; !ok {
+		drift.MissingVector = true
+	}
+
+	return drift
+}
+
+// addMissingProperties creates the given properties and returns those that could not be added and those that were
+func (w *WeaviateClient) addMissingProperties(ctx context.Context, className string, names []string) (remaining, migrated []string) {
+	for _, expected := range ExpectedChunkProperties {
+		if !containsString(names, expected.Name) {
+			continue
+		}
+		err := w.Client.Schema().PropertyCreator().
+			WithClassName(className).
+			WithProperty(&models.Property{Name: expected.Name, DataType: []string{expected.DataType}}).
+			Do(ctx)
+		if err != nil {
+			fmt.Printf("Weaviate schema: failed to add property %s to %s: %v\n", expected.Name, className, err)
+			remaining = append(remaining, expected.Name)
+			continue
+		}
+		migrated = append(migrated, expected.Name)
+	}
+	return remaining, migrated
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
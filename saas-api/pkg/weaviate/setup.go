@@ -3,7 +3,11 @@ package weaviate
 import (
 	"context"
 	"fmt"
+	"log"
+	"net/http"
 	"saas-api/cmd/configs"
+	"saas-api/pkg/tenant"
+	"time"
 
 	"github.com/weaviate/weaviate-go-client/v5/weaviate"
 )
@@ -28,6 +32,10 @@ func NewWeaviateClient(config *configs.Config) *WeaviateClient {
 	cfg := weaviate.Config{
 		Host:   hostWithPort,
 		Scheme: scheme,
+		// Tag every request with the calling tenant (taken from the request context)
+		ConnectionClient: &http.Client{
+			Transport: &slowQueryTransport{base: &tenant.Transport{Base: http.DefaultTransport}},
+		},
 	}
 
 	client, err := weaviate.NewClient(cfg)
@@ -48,3 +56,21 @@ func NewWeaviateClient(config *configs.Config) *WeaviateClient {
 		Client: client,
 	}
 }
+
+// Requests slower than this are logged with the tenant they were made for
+const slowQueryThreshold = 2 * time.Second
+
+type slowQueryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *slowQueryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if elapsed := time.Since(start); elapsed > slowQueryThreshold {
+		info := tenant.FromContext(req.Context())
+		log.Printf("Weaviate slow request: %s %s took %s (org=%s user=%s)",
+			req.Method, req.URL.Path, elapsed.Round(time.Millisecond), info.OrgLabel(), info.UserID)
+	}
+	return resp, err
+}